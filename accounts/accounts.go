@@ -18,11 +18,13 @@
 package accounts
 
 import (
+	"fmt"
 	"math/big"
 
 	ethereum "github.com/tomochain/tomochain"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/event"
 )
 
@@ -128,6 +130,31 @@ type Wallet interface {
 	SignTxWithPassphrase(account Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
 }
 
+// TextSigner is implemented by the wallets able to sign a text as an Ethereum signed message.
+// Unlike SignHash, it is supported by hardware wallets, which display the text to the user
+// before signing it.
+type TextSigner interface {
+	// SignText requests the wallet to sign the hash of the given text, as computed by TextHash.
+	// The produced signature is in the [R || S || V] format where V is 0 or 1.
+	SignText(account Account, text []byte) ([]byte, error)
+}
+
+// TextHash returns the hash signed for a text as an Ethereum signed message:
+//
+//	keccak256("\x19Ethereum Signed Message:\n"${message length}${message})
+func TextHash(text []byte) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(text), text)))
+}
+
+// SignText signs a text as an Ethereum signed message with a wallet, through its TextSigner
+// implementation if it has one, or else by signing its TextHash.
+func SignText(wallet Wallet, account Account, text []byte) ([]byte, error) {
+	if signer, ok := wallet.(TextSigner); ok {
+		return signer.SignText(account, text)
+	}
+	return wallet.SignHash(account, TextHash(text))
+}
+
 // Backend is a "wallet provider" that may contain a batch of accounts they can
 // sign transactions with and upon request, do so.
 type Backend interface {
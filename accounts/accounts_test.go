@@ -0,0 +1,16 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+)
+
+func TestTextHash(t *testing.T) {
+	hash := TextHash([]byte("Hello Joe"))
+	want := hexutil.MustDecode("0xa080337ae51c4e064c189e113edd0ba391df9206e2f49db658bb32cf2911730b")
+	if common.BytesToHash(hash) != common.BytesToHash(want) {
+		t.Fatalf("wrong hash: have %x, want %x", hash, want)
+	}
+}
@@ -51,6 +51,7 @@ const (
 	ledgerOpRetrieveAddress  ledgerOpcode = 0x02 // Returns the public key and Ethereum address for a given BIP 32 path
 	ledgerOpSignTransaction  ledgerOpcode = 0x04 // Signs an Ethereum transaction after having the user validate the parameters
 	ledgerOpGetConfiguration ledgerOpcode = 0x06 // Returns specific wallet application configuration
+	ledgerOpSignMessage      ledgerOpcode = 0x08 // Signs an Ethereum message after having the user validate it
 
 	ledgerP1DirectlyFetchAddress    ledgerParam1 = 0x00 // Return address directly from the wallet
 	ledgerP1ConfirmFetchAddress     ledgerParam1 = 0x01 // Require a user confirmation before returning the address
@@ -170,6 +171,16 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	return w.ledgerSign(path, tx, chainID)
 }
 
+// SignText implements usbwallet.driver, sending the text to the Ledger and waiting
+// for the user to confirm or deny signing it.
+func (w *ledgerDriver) SignText(path accounts.DerivationPath, text []byte) (common.Address, []byte, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return common.Address{}, nil, accounts.ErrWalletClosed
+	}
+	return w.ledgerSignText(path, text)
+}
+
 // ledgerVersion retrieves the current version of the Ethereum wallet app running
 // on the Ledger wallet.
 //
@@ -365,6 +376,78 @@ func (w *ledgerDriver) ledgerSign(derivationPath []uint32, tx *types.Transaction
 	return sender, signed, nil
 }
 
+// ledgerSignText sends the text to the Ledger wallet, and waits for the user to
+// confirm or deny signing it as an Ethereum signed message.
+//
+// The message signing protocol is defined as follows:
+//
+//   CLA | INS | P1 | P2 | Lc  | Le
+//   ----+-----+----+----+-----+---
+//    E0 | 08  | 00 | 00 | var | 41
+//
+// Where the input for the first message chunk (P1 = 0x00) is:
+//
+//   Description                                      | Length
+//   -------------------------------------------------+----------
+//   Number of BIP 32 derivations to perform (max 10) | 1 byte
+//   First derivation index (big endian)              | 4 bytes
+//   ...                                              | 4 bytes
+//   Last derivation index (big endian)               | 4 bytes
+//   Message length (big endian)                      | 4 bytes
+//   Message chunk                                    | arbitrary
+//
+// And the input for subsequent message chunks (P1 = 0x80) is:
+//
+//   Description   | Length
+//   --------------+----------
+//   Message chunk | arbitrary
+//
+// And the output data is:
+//
+//   Description | Length
+//   ------------+---------
+//   signature V | 1 byte
+//   signature R | 32 bytes
+//   signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignText(derivationPath []uint32, text []byte) (common.Address, []byte, error) {
+	// Flatten the derivation path and the message length into the Ledger request
+	payload := make([]byte, 1+4*len(derivationPath)+4, 1+4*len(derivationPath)+4+len(text))
+	payload[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(payload[1+4*i:], component)
+	}
+	binary.BigEndian.PutUint32(payload[1+4*len(derivationPath):], uint32(len(text)))
+	payload = append(payload, text...)
+
+	// Send the request and wait for the response
+	var (
+		op    = ledgerP1InitTransactionData
+		reply []byte
+		err   error
+	)
+	for len(payload) > 0 {
+		// Calculate the size of the next data chunk
+		chunk := 255
+		if chunk > len(payload) {
+			chunk = len(payload)
+		}
+		// Send the chunk over, ensuring it's processed correctly
+		reply, err = w.ledgerExchange(ledgerOpSignMessage, op, 0, payload[:chunk])
+		if err != nil {
+			return common.Address{}, nil, err
+		}
+		// Shift the payload and ensure subsequent chunks are marked as such
+		payload = payload[chunk:]
+		op = ledgerP1ContTransactionData
+	}
+	// Extract the Ethereum signature and do a sanity validation
+	if len(reply) != 65 {
+		return common.Address{}, nil, errors.New("reply lacks signature")
+	}
+	signature := append(reply[1:], reply[0]-27)
+	return textSigner(text, signature)
+}
+
 // ledgerExchange performs a data exchange with the Ledger wallet, sending it a
 // message and retrieving the response.
 //
@@ -30,6 +30,7 @@ import (
 	"github.com/tomochain/tomochain/accounts"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/log"
 )
 
@@ -66,6 +67,10 @@ type driver interface {
 	// SignTx sends the transaction to the USB device and waits for the user to confirm
 	// or deny the transaction.
 	SignTx(path accounts.DerivationPath, tx *types.Transaction, chainID *big.Int) (common.Address, *types.Transaction, error)
+
+	// SignText sends the text to the USB device and waits for the user to confirm or
+	// deny signing it as an Ethereum signed message. The device displays the text.
+	SignText(path accounts.DerivationPath, text []byte) (common.Address, []byte, error)
 }
 
 // wallet represents the common functionality shared by all USB hardware
@@ -547,6 +552,55 @@ func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID
 	return signed, nil
 }
 
+// SignText implements accounts.TextSigner. It sends the text over to the hardware
+// wallet, which displays it, to request a confirmation from the user. It returns
+// either the signature or a failure if the user denied signing the text.
+func (w *wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	w.stateLock.RLock() // Comms have own mutex, this is for the state fields
+	defer w.stateLock.RUnlock()
+
+	// If the wallet is closed, abort
+	if w.device == nil {
+		return nil, accounts.ErrWalletClosed
+	}
+	// Make sure the requested account is contained within
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	<-w.commsLock
+	defer func() { w.commsLock <- struct{}{} }()
+
+	// Ensure the device isn't screwed with while user confirmation is pending
+	w.hub.commsLock.Lock()
+	w.hub.commsPend++
+	w.hub.commsLock.Unlock()
+
+	defer func() {
+		w.hub.commsLock.Lock()
+		w.hub.commsPend--
+		w.hub.commsLock.Unlock()
+	}()
+	// Sign the text and verify the signer to avoid hardware fault surprises
+	signer, signature, err := w.driver.SignText(path, text)
+	if err != nil {
+		return nil, err
+	}
+	if signer != account.Address {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address.Hex(), signer.Hex())
+	}
+	return signature, nil
+}
+
+// textSigner recovers the address signing a text as an Ethereum signed message.
+func textSigner(text []byte, signature []byte) (common.Address, []byte, error) {
+	pubkey, err := crypto.SigToPub(accounts.TextHash(text), signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), signature, nil
+}
+
 // SignHashWithPassphrase implements accounts.Wallet, however signing arbitrary
 // data is not supported for Ledger wallets, so this method will always return
 // an error.
@@ -0,0 +1,431 @@
+// Copyright 2019 The Tomochain Authors
+// This file is part of the Core Tomochain infrastructure
+// https://tomochain.com
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tomochain/tomochain/cmd/utils"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxDAO"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	lendingReplayFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block to replay",
+	}
+	lendingReplayToFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block to replay (default = current block)",
+	}
+	lendingExportFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: "Format of the exported lending trades (rlp, csv)",
+		Value: tomoxlending.TradeExportRLP,
+	}
+	lendingPruneKeepFlag = cli.Uint64Flag{
+		Name:  "keep",
+		Usage: "Number of recent checkpoints whose trading and lending state is retained",
+		Value: 2,
+	}
+	lendingPruneForceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Prune even if the SDK database misses lending items of the pruned blocks",
+	}
+	lendingCommand = cli.Command{
+		Name:     "lending",
+		Usage:    "Verify the TomoX lending engine against the chain",
+		Category: "BLOCKCHAIN COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:   "replay",
+				Usage:  "Replay the lending transactions of a range of blocks",
+				Action: utils.MigrateFlags(replayLending),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TomoXDataDirFlag,
+					lendingReplayFromFlag,
+					lendingReplayToFlag,
+				},
+				Description: `
+The replay command re-executes the order transactions of the blocks in the given
+range on top of the recorded state of their parent, the same way the validators
+verify them, and verifies the resulting lending state roots against the
+roots recorded in the chain. The state of the replayed blocks must still be
+available, so it usually requires an archive node.`,
+			},
+			{
+				Name:      "export-trades",
+				Usage:     "Export the lending trades changed by a range of blocks into a file",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(exportLendingTrades),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TomoXDataDirFlag,
+					lendingReplayFromFlag,
+					lendingReplayToFlag,
+					lendingExportFormatFlag,
+				},
+				Description: `
+The export-trades command streams the lending trades opened, updated or closed
+by each block of the given range into a file, for analytics pipelines which
+can't afford to go through the RPC API. Each record holds the block, the lending
+book, the change (OPEN, UPDATE or CLOSE) and the trade as found after the block,
+or as last found for closed trades.
+
+With --format rlp (the default) the file is a stream of RLP encoded records,
+with --format csv it has a header row and one row per record, amounts in base
+units. The changes are found by diffing the lending state of each block with
+the one of its parent, so it usually requires an archive node.`,
+			},
+		},
+	}
+	lendingStateCommand = cli.Command{
+		Name:     "lendingstate",
+		Usage:    "Export, import and prune the TomoX lending state",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Export the lending state (lending books, orders, trades and liquidation times)
+of a block into a snapshot file, and import it into another node to bootstrap
+a lending-enabled node without replaying the chain, or prune the lending state
+of old blocks.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Export the lending state of a block into a file",
+				ArgsUsage: "<blockHash | blockNum> <filename>",
+				Action:    utils.MigrateFlags(exportLendingState),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.LightModeFlag,
+					utils.TomoXDataDirFlag,
+				},
+				Description: `
+The export command writes the lending state of the given block into a versioned
+snapshot file.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import a lending state snapshot file",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(importLendingState),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.TomoXDataDirFlag,
+				},
+				Description: `
+The import command writes the lending state of a snapshot file into the TomoX
+database and verifies that it is complete.`,
+			},
+			{
+				Name:   "prune",
+				Usage:  "Remove the lending state of old blocks from the TomoX database",
+				Action: utils.MigrateFlags(pruneLendingState),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TomoXDataDirFlag,
+					utils.TomoXDBEngineFlag,
+					utils.TomoXDBNameFlag,
+					utils.TomoXDBConnectionUrlFlag,
+					utils.TomoXDBReplicaSetNameFlag,
+					lendingPruneKeepFlag,
+					lendingPruneForceFlag,
+				},
+				Description: `
+The prune command removes from the TomoX database the lending trie nodes which
+are unreachable from the state of the last --keep checkpoints (epoch blocks)
+and the blocks following them, to reclaim disk space on long-running nodes.
+The trading state shares the database and is pruned along: only the trading
+and lending state of the retained blocks found on disk is kept. The node must
+be stopped.
+
+On SDK nodes (--tomox.dbengine), the pruned state can no longer be used to
+rebuild the SDK database with 'tomo tomox reindex', so the command first checks
+that the SDK database holds the lending items of the last pruned epoch, unless
+--force is given.`,
+			},
+		},
+	}
+)
+
+func exportLendingState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	var block *types.Block
+	if arg := ctx.Args().First(); hashish(arg) {
+		block = chain.GetBlockByHash(common.HexToHash(arg))
+	} else {
+		num, _ := strconv.ParseUint(arg, 10, 64)
+		block = chain.GetBlockByNumber(num)
+	}
+	if block == nil {
+		utils.Fatalf("block not found")
+	}
+	author, err := chain.Engine().Author(block.Header())
+	if err != nil {
+		utils.Fatalf("Could not get block author: %v", err)
+	}
+	tomoX := tomox.New(&cfg.TomoX)
+	defer tomoX.GetLevelDB().Close()
+	lending := tomoxlending.New(tomoX)
+	root, err := lending.GetLendingStateRoot(block, author)
+	if err != nil {
+		utils.Fatalf("Could not get lending state root: %v", err)
+	}
+
+	fh, err := os.OpenFile(ctx.Args().Get(1), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		utils.Fatalf("Could not create file: %v", err)
+	}
+	defer fh.Close()
+	writer := bufio.NewWriter(fh)
+
+	start := time.Now()
+	header := lendingstate.SnapshotHeader{BlockNumber: block.NumberU64(), BlockHash: block.Hash(), Root: root}
+	stats, err := lendingstate.ExportSnapshot(lending.GetStateCache(), header, writer)
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	fmt.Printf("Exported lending state of block %d (root %x): %d lending books, %d orders, %d trades, %d liquidation times, %d nodes in %v\n",
+		block.NumberU64(), root, stats.LendingBooks, stats.LendingItems, stats.LendingTrades, stats.LiquidationTimes, stats.Nodes, time.Since(start))
+	return nil
+}
+
+func importLendingState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	_, cfg := makeConfigNode(ctx)
+	tomoX := tomox.New(&cfg.TomoX)
+	defer tomoX.GetLevelDB().Close()
+
+	fh, err := os.Open(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Could not open file: %v", err)
+	}
+	defer fh.Close()
+
+	start := time.Now()
+	header, stats, err := lendingstate.ImportSnapshot(tomoX.GetLevelDB(), bufio.NewReader(fh))
+	if err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	fmt.Printf("Imported lending state of block %d (root %x): %d lending books, %d orders, %d trades, %d liquidation times, %d nodes in %v\n",
+		header.BlockNumber, header.Root, stats.LendingBooks, stats.LendingItems, stats.LendingTrades, stats.LiquidationTimes, stats.Nodes, time.Since(start))
+	return nil
+}
+
+func replayLending(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	from, to := ctx.Uint64(lendingReplayFromFlag.Name), ctx.Uint64(lendingReplayToFlag.Name)
+	if !ctx.IsSet(lendingReplayToFlag.Name) {
+		to = chain.CurrentBlock().NumberU64()
+	}
+	if from == 0 || from > to {
+		utils.Fatalf("Invalid block range %d - %d", from, to)
+	}
+	tomoX := tomox.New(&cfg.TomoX)
+	defer tomoX.GetLevelDB().Close()
+	lending := tomoxlending.New(tomoX)
+
+	var (
+		start      = time.Now()
+		mismatches = 0
+	)
+	for number := from; number <= to; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			utils.Fatalf("Block #%d not found", number)
+		}
+		result, err := lending.ReplayBlock(chain, block)
+		if err != nil {
+			utils.Fatalf("Failed to replay block #%d: %v", number, err)
+		}
+		if !result.Match() {
+			mismatches++
+			fmt.Printf("Block #%d [%x]: lending state root mismatch, got %x, want %x (%d/%d lending items replayed)\n",
+				result.Number, result.Hash, result.Root, result.Expected, result.Replayed, result.Items)
+		}
+	}
+	fmt.Printf("Replayed %d blocks in %v, %d mismatches\n", to-from+1, time.Since(start), mismatches)
+	if mismatches > 0 {
+		return fmt.Errorf("%d blocks with a lending state mismatch", mismatches)
+	}
+	return nil
+}
+
+func exportLendingTrades(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	from, to := ctx.Uint64(lendingReplayFromFlag.Name), ctx.Uint64(lendingReplayToFlag.Name)
+	if !ctx.IsSet(lendingReplayToFlag.Name) {
+		to = chain.CurrentBlock().NumberU64()
+	}
+	if from == 0 || from > to {
+		utils.Fatalf("Invalid block range %d - %d", from, to)
+	}
+	file, err := os.Create(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to create the export file: %v", err)
+	}
+	defer file.Close()
+	out := bufio.NewWriter(file)
+	writer, err := tomoxlending.NewLendingTradeWriter(out, ctx.String(lendingExportFormatFlag.Name))
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	tomoX := tomox.New(&cfg.TomoX)
+	defer tomoX.GetLevelDB().Close()
+	lending := tomoxlending.New(tomoX)
+
+	start := time.Now()
+	written, err := lending.ExportLendingTrades(chain, from, to, writer)
+	if err != nil {
+		utils.Fatalf("Failed to export lending trades: %v", err)
+	}
+	if err := out.Flush(); err != nil {
+		utils.Fatalf("Failed to write the export file: %v", err)
+	}
+	fmt.Printf("Exported %d lending trade changes of blocks %d - %d in %v\n", written, from, to, time.Since(start))
+	return nil
+}
+
+func pruneLendingState(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	if chain.Config().Posv == nil {
+		utils.Fatalf("Only support posv consensus")
+	}
+	keep := ctx.Uint64(lendingPruneKeepFlag.Name)
+	if keep == 0 {
+		utils.Fatalf("At least one checkpoint must be retained")
+	}
+	var (
+		epoch = chain.Config().Posv.Epoch
+		head  = chain.CurrentBlock().NumberU64()
+		last  = head - head%epoch
+	)
+	if last < keep*epoch {
+		utils.Fatalf("Nothing to prune before the last %d checkpoints", keep)
+	}
+	oldest := last - (keep-1)*epoch
+
+	tomoX := tomox.New(&cfg.TomoX)
+	defer tomoX.GetLevelDB().Close()
+	defer tomoX.Stop()
+	lending := tomoxlending.New(tomoX)
+
+	if tomoX.IsSDKNode() {
+		defer tomoX.GetMongoDB().Close()
+		if !ctx.Bool(lendingPruneForceFlag.Name) {
+			if err := checkSDKLendingItems(chain, tomoX.GetMongoDB(), oldest-epoch+1, oldest); err != nil {
+				utils.Fatalf("SDK database check failed: %v, run 'tomo tomox reindex' first or use --%s", err, lendingPruneForceFlag.Name)
+			}
+		}
+	}
+
+	// retain the state of the blocks since the oldest retained checkpoint found on disk
+	var (
+		tradingRoots []common.Hash
+		lendingRoots []common.Hash
+		seen         = make(map[common.Hash]bool)
+	)
+	for number := oldest; number <= head; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			utils.Fatalf("Block #%d not found", number)
+		}
+		author, err := chain.Engine().Author(block.Header())
+		if err != nil {
+			utils.Fatalf("Could not get the author of block #%d: %v", number, err)
+		}
+		if !tomoX.HasTradingState(block, author) || !lending.HasLendingState(block, author) {
+			continue
+		}
+		tradingRoot, _ := tomoX.GetTradingStateRoot(block, author)
+		lendingRoot, _ := lending.GetLendingStateRoot(block, author)
+		if !seen[tradingRoot] {
+			seen[tradingRoot] = true
+			tradingRoots = append(tradingRoots, tradingRoot)
+		}
+		if !seen[lendingRoot] {
+			seen[lendingRoot] = true
+			lendingRoots = append(lendingRoots, lendingRoot)
+		}
+	}
+	if len(lendingRoots) == 0 {
+		utils.Fatalf("No trading and lending state found since block #%d", oldest)
+	}
+	log.Info("Pruning TomoX state", "from", oldest, "head", head, "tradingRoots", len(tradingRoots), "lendingRoots", len(lendingRoots))
+
+	start := time.Now()
+	stats, err := tomoxlending.PruneStateTries(tomoX.GetLevelDB(), tradingRoots, lendingRoots)
+	if err != nil {
+		utils.Fatalf("Prune error: %v", err)
+	}
+	fmt.Printf("Pruned the state before block %d: %d roots retained, %d/%d trie nodes removed (%v) in %v\n",
+		oldest, stats.Roots, stats.Deleted, stats.Scanned, stats.Size, time.Since(start))
+	return nil
+}
+
+// checkSDKLendingItems verifies that the SDK database holds the limit lending items sent in a
+// range of blocks.
+func checkSDKLendingItems(chain *core.BlockChain, db tomoxDAO.TomoXDAO, from, to uint64) error {
+	for number := from; number <= to; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("block #%d not found", number)
+		}
+		batches, err := core.ExtractLendingTransactions(block.Transactions())
+		if err != nil {
+			return err
+		}
+		for _, batch := range batches {
+			for _, item := range batch.Data {
+				if item.Type != lendingstate.Limit {
+					continue
+				}
+				if val, err := db.GetObject(item.Hash, &lendingstate.LendingItem{}); err != nil || val == nil {
+					return fmt.Errorf("lending item %x of block #%d missing", item.Hash, number)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -71,7 +71,32 @@ var (
 		utils.TomoXDBEngineFlag,
 		utils.TomoXDBConnectionUrlFlag,
 		utils.TomoXDBReplicaSetNameFlag,
+		utils.TomoXDBReadPreferenceFlag,
+		utils.TomoXDBPoolLimitFlag,
+		utils.TomoXDBWriteConcernFlag,
 		utils.TomoXDBNameFlag,
+		utils.TomoXLendingIndexFlag,
+		utils.TomoXCandleIndexFlag,
+		utils.TomoXWitnessFlag,
+		utils.TomoXEventSinkFlag,
+		utils.TomoXEventSinkTopicFlag,
+		utils.TomoXRetentionFlag,
+		utils.TomoXRetentionDryRunFlag,
+		utils.TomoXLendingGCModeFlag,
+		utils.TomoXLendingStateEpochsFlag,
+		utils.TomoXLendingRelayerSlotsFlag,
+		utils.TomoXLendingMatchWorkersFlag,
+		utils.TomoXOrderSenderWorkersFlag,
+		utils.TomoXLendingMatchBudgetFlag,
+		utils.TomoXSDKTimeoutFlag,
+		utils.TomoXSDKJournalDepthFlag,
+		utils.TomoXLendingGRPCFlag,
+		utils.TomoXLendingRESTFlag,
+		utils.TomoXGraphQLFlag,
+		utils.TomoXDevDBFlag,
+		utils.TomoXLendingCacheFlag,
+		utils.TomoXLendingRootsCacheFlag,
+		utils.TomoXLendingNoncesFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
@@ -136,6 +161,11 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.RPCAuthApiFlag,
+		utils.RPCAuthTokensFlag,
+		utils.RPCRateLimitApiFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateBurstFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -164,6 +194,11 @@ func init() {
 		exportCommand,
 		removedbCommand,
 		dumpCommand,
+		// See lendingcmd.go:
+		lendingCommand,
+		lendingStateCommand,
+		// See tomoxcmd.go:
+		tomoxCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
@@ -0,0 +1,158 @@
+// Copyright 2019 The Tomochain Authors
+// This file is part of the Core Tomochain infrastructure
+// https://tomochain.com
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tomochain/tomochain/cmd/utils"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	tomoxReindexFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block to reindex",
+	}
+	tomoxReindexToFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block to reindex (default = current block)",
+	}
+	tomoxRollbackToFlag = cli.Uint64Flag{
+		Name:  "rollback-to",
+		Usage: "Block whose SDK lending records are restored",
+	}
+	tomoxCommand = cli.Command{
+		Name:     "tomox",
+		Usage:    "Manage the TomoX SDK database",
+		Category: "BLOCKCHAIN COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:   "reindex",
+				Usage:  "Rebuild the SDK database from the chain data",
+				Action: utils.MigrateFlags(reindexTomoX),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.TomoXDataDirFlag,
+					utils.TomoXDBEngineFlag,
+					utils.TomoXDBNameFlag,
+					utils.TomoXDBConnectionUrlFlag,
+					utils.TomoXDBReplicaSetNameFlag,
+					tomoxReindexFromFlag,
+					tomoxReindexToFlag,
+				},
+				Description: `
+The reindex command re-executes the order transactions of the blocks in the
+given range on top of the recorded trading and lending state of their parent,
+and writes the resulting orders, trades, lending items and liquidations to the
+SDK database (--tomox.dbengine). It repopulates a corrupted SDK database, or
+one enabled after the initial sync. The state of the reindexed blocks must
+still be available, so it usually requires an archive node.`,
+			},
+			{
+				Name:   "rollback",
+				Usage:  "Rewind the SDK lending records to a recent block",
+				Action: utils.MigrateFlags(rollbackTomoX),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.TomoXDataDirFlag,
+					utils.TomoXDBEngineFlag,
+					utils.TomoXDBNameFlag,
+					utils.TomoXDBConnectionUrlFlag,
+					utils.TomoXDBReplicaSetNameFlag,
+					tomoxRollbackToFlag,
+				},
+				Description: `
+The rollback command restores the lending items and trades of the SDK database
+as they were after the block given by --rollback-to, using the journal of the
+lending transactions recorded by the last --tomox.sdkjournaldepth blocks. It
+recovers from reorgs deeper than the node could handle, or from a crash in the
+middle of one. The node must be stopped. The lending records of the canonical
+blocks above the given one can then be recorded again with 'tomo tomox reindex'.`,
+			},
+		},
+	}
+)
+
+func rollbackTomoX(ctx *cli.Context) error {
+	if !ctx.IsSet(tomoxRollbackToFlag.Name) {
+		utils.Fatalf("The rollback command requires --%s", tomoxRollbackToFlag.Name)
+	}
+	_, cfg := makeConfigNode(ctx)
+	tomoX := tomox.New(&cfg.TomoX)
+	defer tomoX.GetLevelDB().Close()
+	defer tomoX.Stop()
+	if !tomoX.IsSDKNode() {
+		utils.Fatalf("The rollback command requires a SDK database, see --%s", utils.TomoXDBEngineFlag.Name)
+	}
+	defer tomoX.GetMongoDB().Close()
+	lending := tomoxlending.New(tomoX)
+
+	to := ctx.Uint64(tomoxRollbackToFlag.Name)
+	rolledBack, err := lending.RollbackSDKData(to)
+	if err != nil {
+		utils.Fatalf("Failed to roll back the SDK lending records after %d blocks: %v", rolledBack, err)
+	}
+	fmt.Printf("Rolled back the SDK lending records of %d blocks to block #%d\n", rolledBack, to)
+	return nil
+}
+
+func reindexTomoX(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	from, to := ctx.Uint64(tomoxReindexFromFlag.Name), ctx.Uint64(tomoxReindexToFlag.Name)
+	if !ctx.IsSet(tomoxReindexToFlag.Name) {
+		to = chain.CurrentBlock().NumberU64()
+	}
+	if from == 0 || from > to {
+		utils.Fatalf("Invalid block range %d - %d", from, to)
+	}
+	engine, ok := chain.Engine().(*posv.Posv)
+	if !ok {
+		utils.Fatalf("Only support posv consensus")
+	}
+	tomoX := tomox.New(&cfg.TomoX)
+	defer tomoX.GetLevelDB().Close()
+	defer tomoX.Stop()
+	if !tomoX.IsSDKNode() {
+		utils.Fatalf("The reindex command requires a SDK database, see --%s", utils.TomoXDBEngineFlag.Name)
+	}
+	defer tomoX.GetMongoDB().Close()
+	lending := tomoxlending.New(tomoX)
+	engine.GetTomoXService = func() posv.TradingService {
+		return tomoX
+	}
+	engine.GetLendingService = func() posv.LendingService {
+		return lending
+	}
+
+	var (
+		start  = time.Now()
+		report = time.Now()
+	)
+	for number := from; number <= to; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			utils.Fatalf("Block #%d not found", number)
+		}
+		if err := chain.ReindexTomoXData(block); err != nil {
+			utils.Fatalf("Failed to reindex block #%d: %v", number, err)
+		}
+		if time.Since(report) > 8*time.Second {
+			log.Info("Reindexing TomoX data", "number", number, "to", to, "elapsed", time.Since(start))
+			report = time.Now()
+		}
+	}
+	fmt.Printf("Reindexed %d blocks in %v\n", to-from+1, time.Since(start))
+	return nil
+}
@@ -155,6 +155,11 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
 			utils.RPCVirtualHostsFlag,
+			utils.RPCAuthApiFlag,
+			utils.RPCAuthTokensFlag,
+			utils.RPCRateLimitApiFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCRateBurstFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -4,6 +4,7 @@ import (
 	"github.com/tomochain/tomochain/eth"
 	"github.com/tomochain/tomochain/eth/downloader"
 	"github.com/tomochain/tomochain/ethstats"
+	"github.com/tomochain/tomochain/graphql"
 	"github.com/tomochain/tomochain/les"
 	"github.com/tomochain/tomochain/node"
 	"github.com/tomochain/tomochain/tomox"
@@ -72,9 +73,18 @@ func RegisterTomoXService(stack *node.Node, cfg *tomox.Config) {
 	}
 
 	// register tomoxlending service
+	lending := tomoxlending.New(tomoX)
 	if err := stack.Register(func(n *node.ServiceContext) (node.Service, error) {
-		return tomoxlending.New(tomoX), nil
+		return lending, nil
 	}); err != nil {
 		Fatalf("Failed to register the TomoXLending service: %v", err)
 	}
+
+	if cfg.GraphQL {
+		if err := stack.Register(func(n *node.ServiceContext) (node.Service, error) {
+			return graphql.New(tomoX, lending)
+		}); err != nil {
+			Fatalf("Failed to register the GraphQL service: %v", err)
+		}
+	}
 }
@@ -37,6 +37,20 @@ var BlackListHFNumber = uint64(9349100)
 var TIPTomoX = big.NewInt(20581700)
 var TIPTomoXLending = big.NewInt(21430200)
 var TIPTomoXCancellationFee = big.NewInt(30915660)
+var TIPTomoXLendingV2 = big.NewInt(99999999999)               // not scheduled yet
+var TIPTomoXLendingEIP712 = big.NewInt(99999999999)           // not scheduled yet
+var TIPTomoXTextSigning = big.NewInt(99999999999)             // not scheduled yet
+var TIPTomoXLendingReplayProtection = big.NewInt(99999999999) // not scheduled yet
+var TIPTomoXStopOrders = big.NewInt(99999999999)              // not scheduled yet
+var TIPTomoXTrailingStopOrders = big.NewInt(99999999999)      // not scheduled yet
+var TIPTomoXPostOnly = big.NewInt(99999999999)                // not scheduled yet
+var TIPTomoXFeeTiers = big.NewInt(99999999999)                // not scheduled yet
+var TIPTomoXMinNotional = big.NewInt(99999999999)             // not scheduled yet
+var TIPTomoXMargin = big.NewInt(99999999999)                  // not scheduled yet
+var TIPTomoXPeriodicInterest = big.NewInt(99999999999)        // not scheduled yet
+var TIPTomoXPriceOracle = big.NewInt(99999999999)             // not scheduled yet
+var TIPTomoXTwap = big.NewInt(99999999999)                    // not scheduled yet
+var TIPTomoXPriceBand = big.NewInt(99999999999)               // not scheduled yet
 var TIPTomoXTestnet = big.NewInt(0)
 var IsTestnet bool = false
 var StoreRewardFolder string
@@ -65,6 +79,12 @@ var TRC21GasPrice = big.NewInt(250000000)
 var RateTopUp = big.NewInt(90) // 90%
 var BaseTopUp = big.NewInt(100)
 var BaseRecall = big.NewInt(100)
+var LendingAuctionDuration = uint64(6 * 60 * 60) // 6 hours
+var LendingAuctionStartRate = big.NewInt(110)    // liquidation auctions start at 110% of the collateral price
+var LendingAuctionFloorRate = big.NewInt(70)     // and decrease linearly down to 70%
+var BaseLendingAuction = big.NewInt(100)
+var LendingInsuranceRate = big.NewInt(10) // share of the borrowing fees paid to the insurance fund, 10%
+var BaseLendingInsurance = big.NewInt(100)
 var Blacklist = map[Address]bool{
 	HexToAddress("0x5248bfb72fd4f234e062d3e9bb76f08643004fcd"): true,
 	HexToAddress("0x5ac26105b35ea8935be382863a70281ec7a985e9"): true,
@@ -66,20 +66,24 @@ type Masternode struct {
 type TradingService interface {
 	GetTradingStateRoot(block *types.Block, author common.Address) (common.Hash, error)
 	GetTradingState(block *types.Block, author common.Address) (*tradingstate.TradingStateDB, error)
+	GetTradingStateFromWitness(block *types.Block, author common.Address, child common.Hash) (*tradingstate.TradingStateDB, error)
 	HasTradingState(block *types.Block, author common.Address) bool
 	GetStateCache() tradingstate.Database
 	GetTriegc() *prque.Prque
 	ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tomoXstatedb *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error)
 	UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB) error
 	IsSDKNode() bool
+	HasCandleIndex() bool
 	SyncDataToSDKNode(takerOrder *tradingstate.OrderItem, txHash common.Hash, txMatchTime time.Time, statedb *state.StateDB, trades []map[string]string, rejectedOrders []*tradingstate.OrderItem, dirtyOrderCount *uint64) error
 	RollbackReorgTxMatch(txhash common.Hash) error
 	GetTokenDecimal(chain consensus.ChainContext, statedb *state.StateDB, tokenAddr common.Address) (*big.Int, error)
+	IndexCandles(block *types.Block, trades []map[string]string) error
 }
 
 type LendingService interface {
 	GetLendingStateRoot(block *types.Block, author common.Address) (common.Hash, error)
 	GetLendingState(block *types.Block, author common.Address) (*lendingstate.LendingStateDB, error)
+	GetLendingStateFromWitness(block *types.Block, author common.Address, child common.Hash) (*lendingstate.LendingStateDB, error)
 	HasLendingState(block *types.Block, author common.Address) bool
 	GetStateCache() lendingstate.Database
 	GetTriegc() *prque.Prque
@@ -87,9 +91,18 @@ type LendingService interface {
 	GetCollateralPrices(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) (*big.Int, *big.Int, error)
 	GetMediumTradePriceBeforeEpoch(chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, baseToken common.Address, quoteToken common.Address) (*big.Int, error)
 	ProcessLiquidationData(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) (updatedTrades map[common.Hash]*lendingstate.LendingTrade, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades []*lendingstate.LendingTrade, err error)
+	SweepExpiredLendingItems(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingState *lendingstate.LendingStateDB) ([]*lendingstate.LendingItem, error)
 	SyncDataToSDKNode(chain consensus.ChainContext, state *state.StateDB, block *types.Block, takerOrderInTx *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedOrders []*lendingstate.LendingItem, dirtyOrderCount *uint64) error
 	UpdateLiquidatedTrade(blockTime uint64, result lendingstate.FinalizedResult, trades map[common.Hash]*lendingstate.LendingTrade) error
 	RollbackLendingData(txhash common.Hash) error
+	JournalLendingData(block *types.Block, txHashes []common.Hash) error
+	HasLendingIndex() bool
+	LendingStateRetention() (bool, uint64)
+	IndexLendingData(takerItem *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedItems []*lendingstate.LendingItem) error
+	IndexLiquidatedTrades(blockTime uint64, result lendingstate.FinalizedResult, trades map[common.Hash]*lendingstate.LendingTrade) error
+	IndexLendingLogs(block *types.Block, logs []*types.Log) error
+	IndexEpochReport(block *types.Block, items []*lendingstate.LendingItem, trades [][]*lendingstate.LendingTrade, finalizedTrades map[common.Hash]*lendingstate.LendingTrade) error
+	IndexStateDiff(block *types.Block, items []*lendingstate.LendingItem, trades [][]*lendingstate.LendingTrade, rejected [][]*lendingstate.LendingItem, finalizedTrades map[common.Hash]*lendingstate.LendingTrade) error
 }
 
 // Posv proof-of-stake-voting protocol constants.
@@ -135,7 +135,7 @@ func (v *BlockValidator) ValidateTradingOrder(statedb *state.StateDB, tomoxState
 			Rejects: newRejectedOrders,
 		}
 	}
-	if tomoXService.IsSDKNode() {
+	if tomoXService.IsSDKNode() || tomoXService.HasCandleIndex() {
 		v.bc.AddMatchingResult(txMatchBatch.TxHash, tradingResult)
 	}
 	return nil
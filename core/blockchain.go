@@ -122,6 +122,7 @@ type BlockChain struct {
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	scope         event.SubscriptionScope
+	tomoxEvents   TomoXEventBus
 	genesisBlock  *types.Block
 
 	mu      sync.RWMutex // global mutex for locking chain operations
@@ -249,6 +250,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	// Take ownership of this particular state
 	go bc.update()
+	bc.wg.Add(1)
+	go bc.tomoxSyncLoop(bc.newTomoXSyncer())
 	return bc, nil
 }
 
@@ -947,6 +950,7 @@ func (bc *BlockChain) Stop() {
 	}
 	// Unsubscribe all subscriptions registered from blockchain
 	bc.scope.Close()
+	bc.tomoxEvents.scope.Close()
 	close(bc.quit)
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 	bc.wg.Wait()
@@ -1154,6 +1158,21 @@ func (bc *BlockChain) WriteBlockWithoutState(block *types.Block, td *big.Int) (e
 	return nil
 }
 
+// lendingGCLimit returns the number of the last block whose lending state trie can be garbage
+// collected at the current block, when the state tries up to chosen are. The lending state of
+// the configured number of recent epochs is kept on top of the state tries in memory.
+func (bc *BlockChain) lendingGCLimit(lendingService posv.LendingService, current, chosen uint64) uint64 {
+	_, epochs := lendingService.LendingStateRetention()
+	keep := epochs * bc.chainConfig.Posv.Epoch
+	if keep <= triesInMemory {
+		return chosen
+	}
+	if current <= keep {
+		return 0
+	}
+	return current - keep
+}
+
 // WriteBlockWithState writes the block and all associated state to the database.
 func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) (status WriteStatus, err error) {
 	bc.wg.Add(1)
@@ -1240,11 +1259,22 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		if tradingService != nil {
 			tradingService.GetTriegc().Push(tradingRoot, -float32(block.NumberU64()))
 		}
-		if lendingTrieDb != nil {
-			lendingTrieDb.Reference(lendingRoot, common.Hash{})
-		}
+		lendingArchive := false
 		if lendingService != nil {
-			lendingService.GetTriegc().Push(lendingRoot, -float32(block.NumberU64()))
+			lendingArchive, _ = lendingService.LendingStateRetention()
+		}
+		if lendingArchive {
+			// Archive mode for the lending state only, always flush it
+			if err := lendingTrieDb.Commit(lendingRoot, false); err != nil {
+				return NonStatTy, err
+			}
+		} else {
+			if lendingTrieDb != nil {
+				lendingTrieDb.Reference(lendingRoot, common.Hash{})
+			}
+			if lendingService != nil {
+				lendingService.GetTriegc().Push(lendingRoot, -float32(block.NumberU64()))
+			}
 		}
 		if current := block.NumberU64(); current > triesInMemory {
 			// Find the next state trie we need to commit
@@ -1313,9 +1343,10 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 				}
 			}
 			if lendingService != nil {
+				lendingChosen := bc.lendingGCLimit(lendingService, current, chosen)
 				for !lendingService.GetTriegc().Empty() {
 					lendingRoot, number := lendingService.GetTriegc().Pop()
-					if uint64(-number) > chosen {
+					if uint64(-number) > lendingChosen {
 						lendingService.GetTriegc().Push(lendingRoot, number)
 						break
 					}
@@ -1547,12 +1578,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 					bc.reportBlock(block, nil, err)
 					return i, events, coalescedLogs, err
 				}
-				tradingState, err = tradingService.GetTradingState(parent, parentAuthor)
-				if err != nil {
-					bc.reportBlock(block, nil, err)
-					return i, events, coalescedLogs, err
-				}
-				lendingState, err = lendingService.GetLendingState(parent, parentAuthor)
+				tradingState, lendingState, err = parentTomoXStates(block, parent, parentAuthor, tradingService, lendingService)
 				if err != nil {
 					bc.reportBlock(block, nil, err)
 					return i, events, coalescedLogs, err
@@ -1584,6 +1610,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 							return i, events, coalescedLogs, err
 						}
 					}
+					if _, err := lendingService.SweepExpiredLendingItems(block.Header(), bc, statedb, lendingState); err != nil {
+						return i, events, coalescedLogs, fmt.Errorf("failed to sweep expired lending items. Err: %v ", err)
+					}
 					// liquidate / finalize open lendingTrades
 					if block.Number().Uint64()%bc.chainConfig.Posv.Epoch == common.LiquidateLendingTradeBlock {
 						finalizedTrades := map[common.Hash]*lendingstate.LendingTrade{}
@@ -1674,8 +1703,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			bc.gcproc += proctime
 			bc.UpdateBlocksHashCache(block)
 			if bc.chainConfig.IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch {
-				bc.logExchangeData(block)
-				bc.logLendingData(block)
+				bc.queueTomoXEvents(block)
 			}
 		case SideStatTy:
 			log.Debug("Inserted forked block from downloader", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
@@ -1835,12 +1863,7 @@ func (bc *BlockChain) getResultBlock(block *types.Block, verifiedM2 bool) (*Resu
 		lendingService = engine.GetLendingService()
 		if tradingService != nil && lendingService != nil {
 			isSDKNode = tradingService.IsSDKNode()
-			tradingState, err = tradingService.GetTradingState(parent, parentAuthor)
-			if err != nil {
-				bc.reportBlock(block, nil, err)
-				return nil, err
-			}
-			lendingState, err = lendingService.GetLendingState(parent, parentAuthor)
+			tradingState, lendingState, err = parentTomoXStates(block, parent, parentAuthor, tradingService, lendingService)
 			if err != nil {
 				bc.reportBlock(block, nil, err)
 				return nil, err
@@ -1876,6 +1899,9 @@ func (bc *BlockChain) getResultBlock(block *types.Block, verifiedM2 bool) (*Resu
 						return nil, err
 					}
 				}
+				if _, err := lendingService.SweepExpiredLendingItems(block.Header(), bc, statedb, lendingState); err != nil {
+					return nil, fmt.Errorf("failed to sweep expired lending items. Err: %v ", err)
+				}
 				// liquidate / finalize open lendingTrades
 				if block.Number().Uint64()%bc.chainConfig.Posv.Epoch == common.LiquidateLendingTradeBlock {
 					finalizedTrades := map[common.Hash]*lendingstate.LendingTrade{}
@@ -2017,8 +2043,7 @@ func (bc *BlockChain) insertBlock(block *types.Block) ([]interface{}, []*types.L
 		bc.gcproc += result.proctime
 		bc.UpdateBlocksHashCache(block)
 		if bc.chainConfig.IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch {
-			bc.logExchangeData(block)
-			bc.logLendingData(block)
+			bc.queueTomoXEvents(block)
 		}
 	case SideStatTy:
 		log.Debug("Inserted forked block from fetcher", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
@@ -2221,6 +2246,9 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 // posts them into the event feed.
 // TODO: Should not expose PostChainEvents. The chain events should be posted in WriteBlock.
 func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
+	// post the TomoX events queued by the insertion, now that the chain lock is released
+	bc.postTomoXQueue()
+
 	// post event logs for further processing
 	if logs != nil {
 		bc.logsFeed.Send(logs)
@@ -2511,178 +2539,108 @@ func (bc *BlockChain) UpdateM1() error {
 	return nil
 }
 
-func (bc *BlockChain) logExchangeData(block *types.Block) {
+// ReindexTomoXData re-executes the order transactions of a canonical block on top of the trading
+// and lending state of its parent, the same way insertChain verifies them, and writes the
+// resulting orders, trades, lending items and liquidations to the SDK database again.
+// It allows rebuilding the SDK database of a node without resyncing the chain.
+func (bc *BlockChain) ReindexTomoXData(block *types.Block) error {
 	engine, ok := bc.Engine().(*posv.Posv)
-	if !ok || engine == nil {
-		return
+	if !ok || engine == nil || bc.chainConfig.Posv == nil {
+		return ErrNotPoSV
 	}
-	tomoXService := engine.GetTomoXService()
-	if tomoXService == nil || !tomoXService.IsSDKNode() {
-		return
+	if engine.GetTomoXService == nil || engine.GetLendingService == nil {
+		return fmt.Errorf("tomox services not found")
 	}
-	txMatchBatchData, err := ExtractTradingTransactions(block.Transactions())
+	tradingService, lendingService := engine.GetTomoXService(), engine.GetLendingService()
+	if tradingService == nil || lendingService == nil {
+		return fmt.Errorf("tomox services not found")
+	}
+	if !tradingService.IsSDKNode() {
+		return fmt.Errorf("tomox is not running as a SDK node")
+	}
+	if !bc.Config().IsTIPTomoX(block.Number()) || block.NumberU64() <= bc.chainConfig.Posv.Epoch || block.NumberU64()%bc.chainConfig.Posv.Epoch == 0 {
+		return nil
+	}
+	parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return fmt.Errorf("parent block #%d not found", block.NumberU64()-1)
+	}
+	statedb, err := state.New(parent.Root(), bc.stateCache)
 	if err != nil {
-		log.Crit("failed to extract matching transaction", "err", err)
-		return
+		return fmt.Errorf("state of block #%d not available: %v", parent.NumberU64(), err)
 	}
-	if len(txMatchBatchData) == 0 {
-		return
+	author, err := bc.Engine().Author(block.Header())
+	if err != nil {
+		return err
 	}
-	currentState, err := bc.State()
+	parentAuthor, err := bc.Engine().Author(parent.Header())
 	if err != nil {
-		log.Crit("logExchangeData: failed to get current state", "err", err)
-		return
+		return err
+	}
+	tradingState, err := tradingService.GetTradingState(parent, parentAuthor)
+	if err != nil {
+		return fmt.Errorf("trading state of block #%d not available: %v", parent.NumberU64(), err)
+	}
+	lendingState, err := lendingService.GetLendingState(parent, parentAuthor)
+	if err != nil {
+		return fmt.Errorf("lending state of block #%d not available: %v", parent.NumberU64(), err)
+	}
+	txMatchBatchData, err := ExtractTradingTransactions(block.Transactions())
+	if err != nil {
+		return err
 	}
-	start := time.Now()
-	defer func() {
-		//The deferred call's arguments are evaluated immediately, but the function call is not executed until the surrounding function returns
-		// That's why we should put this log statement in an anonymous function
-		log.Debug("logExchangeData takes", "time", common.PrettyDuration(time.Since(start)), "blockNumber", block.NumberU64())
-	}()
-
 	for _, txMatchBatch := range txMatchBatchData {
-		dirtyOrderCount := uint64(0)
-		for _, txMatch := range txMatchBatch.Data {
-			var (
-				takerOrderInTx *tradingstate.OrderItem
-				trades         []map[string]string
-				rejectedOrders []*tradingstate.OrderItem
-			)
-
-			if takerOrderInTx, err = txMatch.DecodeOrder(); err != nil {
-				log.Crit("SDK node decode takerOrderInTx failed", "txDataMatch", txMatch)
-				return
-			}
-			cacheKey := crypto.Keccak256Hash(txMatchBatch.TxHash.Bytes(), tradingstate.GetMatchingResultCacheKey(takerOrderInTx).Bytes())
-			// getTrades from cache
-			resultTrades, ok := bc.resultTrade.Get(cacheKey)
-			if ok && resultTrades != nil {
-				trades = resultTrades.([]map[string]string)
-			}
-
-			// getRejectedOrder from cache
-			rejected, ok := bc.rejectedOrders.Get(cacheKey)
-			if ok && rejected != nil {
-				rejectedOrders = rejected.([]*tradingstate.OrderItem)
-			}
-
-			txMatchTime := time.Unix(block.Header().Time.Int64(), 0).UTC()
-			if err := tomoXService.SyncDataToSDKNode(takerOrderInTx, txMatchBatch.TxHash, txMatchTime, currentState, trades, rejectedOrders, &dirtyOrderCount); err != nil {
-				log.Crit("failed to SyncDataToSDKNode ", "blockNumber", block.Number(), "err", err)
-				return
-			}
+		if err := bc.Validator().ValidateTradingOrder(statedb, tradingState, txMatchBatch, author, block.Header()); err != nil {
+			return err
 		}
 	}
-}
-
-func (bc *BlockChain) reorgTxMatches(deletedTxs types.Transactions, newChain types.Blocks) {
-	engine, ok := bc.Engine().(*posv.Posv)
-	if !ok || engine == nil {
-		return
+	batches, err := ExtractLendingTransactions(block.Transactions())
+	if err != nil {
+		return err
 	}
-	tomoXService := engine.GetTomoXService()
-	lendingService := engine.GetLendingService()
-	if tomoXService == nil || !tomoXService.IsSDKNode() {
-		return
+	for _, batch := range batches {
+		if err := bc.Validator().ValidateLendingOrder(statedb, lendingState, tradingState, batch, author, block.Header()); err != nil {
+			return err
+		}
 	}
-	start := time.Now()
-	defer func() {
-		//The deferred call's arguments are evaluated immediately, but the function call is not executed until the surrounding function returns
-		// That's why we should put this log statement in an anonymous function
-		log.Debug("reorgTxMatches takes", "time", common.PrettyDuration(time.Since(start)))
-	}()
-	for _, deletedTx := range deletedTxs {
-		if deletedTx.IsTradingTransaction() {
-			log.Debug("Rollback reorg txMatch", "txhash", deletedTx.Hash())
-			if err := tomoXService.RollbackReorgTxMatch(deletedTx.Hash()); err != nil {
-				log.Crit("Reorg trading failed", "err", err, "hash", deletedTx.Hash())
-			}
+	if _, err := lendingService.SweepExpiredLendingItems(block.Header(), bc, statedb, lendingState); err != nil {
+		return fmt.Errorf("failed to sweep expired lending items. Err: %v ", err)
+	}
+	if block.NumberU64()%bc.chainConfig.Posv.Epoch == common.LiquidateLendingTradeBlock {
+		finalizedTrades, _, _, _, _, err := lendingService.ProcessLiquidationData(block.Header(), bc, statedb, tradingState, lendingState)
+		if err != nil {
+			return fmt.Errorf("failed to ProcessLiquidationData. Err: %v ", err)
 		}
-		if lendingService != nil && (deletedTx.IsLendingTransaction() || deletedTx.IsLendingFinalizedTradeTransaction()) {
-			log.Debug("Rollback reorg lendingItem", "txhash", deletedTx.Hash())
-			if err := lendingService.RollbackLendingData(deletedTx.Hash()); err != nil {
-				log.Crit("Reorg lending failed", "err", err, "hash", deletedTx.Hash())
-			}
+		finalizedTx, err := ExtractLendingFinalizedTradeTransactions(block.Transactions())
+		if err != nil {
+			return err
 		}
+		bc.AddFinalizedTrades(finalizedTx.TxHash, finalizedTrades)
 	}
-
-	// apply new chain
-	for i := len(newChain) - 1; i >= 0; i-- {
-		bc.logExchangeData(newChain[i])
-		bc.logLendingData(newChain[i])
+	if expectRoot, _ := tradingService.GetTradingStateRoot(block, author); tradingState.IntermediateRoot() != expectRoot {
+		return fmt.Errorf("invalid tomox trading state merke trie got : %s , expect : %s", tradingState.IntermediateRoot().Hex(), expectRoot.Hex())
 	}
+	if expectRoot, _ := lendingService.GetLendingStateRoot(block, author); lendingState.IntermediateRoot() != expectRoot {
+		return fmt.Errorf("invalid lending state merke trie got : %s , expect : %s", lendingState.IntermediateRoot().Hex(), expectRoot.Hex())
+	}
+	bc.postTomoXEvents(block, true)
+	return nil
 }
 
-func (bc *BlockChain) logLendingData(block *types.Block) {
-	engine, ok := bc.Engine().(*posv.Posv)
-	if !ok || engine == nil {
-		return
-	}
-	tomoXService := engine.GetTomoXService()
-	if tomoXService == nil || !tomoXService.IsSDKNode() {
-		return
-	}
-	lendingService := engine.GetLendingService()
-	if lendingService == nil {
-		return
-	}
-	batches, err := ExtractLendingTransactions(block.Transactions())
-	if err != nil {
-		log.Crit("failed to extract lending transaction", "err", err)
-	}
+// reorgTxMatches queues the trading and lending transactions removed from the canonical chain by a
+// reorg, then the TomoX events of the blocks of the new chain.
+func (bc *BlockChain) reorgTxMatches(deletedTxs types.Transactions, newChain types.Blocks) {
 	start := time.Now()
 	defer func() {
 		//The deferred call's arguments are evaluated immediately, but the function call is not executed until the surrounding function returns
 		// That's why we should put this log statement in an anonymous function
-		log.Debug("logLendingData takes", "time", common.PrettyDuration(time.Since(start)), "blockNumber", block.NumberU64())
+		log.Debug("reorgTxMatches takes", "time", common.PrettyDuration(time.Since(start)))
 	}()
+	bc.queueTxReverted(deletedTxs)
 
-	for _, batch := range batches {
-
-		dirtyOrderCount := uint64(0)
-		for _, item := range batch.Data {
-			var (
-				trades         []*lendingstate.LendingTrade
-				rejectedOrders []*lendingstate.LendingItem
-			)
-			// getTrades from cache
-			resultLendingTrades, ok := bc.resultLendingTrade.Get(crypto.Keccak256Hash(batch.TxHash.Bytes(), lendingstate.GetLendingCacheKey(item).Bytes()))
-
-			if ok && resultLendingTrades != nil {
-				trades = resultLendingTrades.([]*lendingstate.LendingTrade)
-			}
-
-			// getRejectedOrder from cache
-			rejected, ok := bc.rejectedLendingItem.Get(crypto.Keccak256Hash(batch.TxHash.Bytes(), lendingstate.GetLendingCacheKey(item).Bytes()))
-			if ok && rejected != nil {
-				rejectedOrders = rejected.([]*lendingstate.LendingItem)
-			}
-
-			txMatchTime := time.Unix(block.Header().Time.Int64(), 0).UTC()
-			statedb, _ := bc.State()
-
-			if err := lendingService.SyncDataToSDKNode(bc, statedb.Copy(), block, item, batch.TxHash, txMatchTime, trades, rejectedOrders, &dirtyOrderCount); err != nil {
-				log.Crit("lending: failed to SyncDataToSDKNode ", "blockNumber", block.Number(), "err", err)
-			}
-		}
-	}
-
-	// update finalizedTrades
-	if block.Number().Uint64()%bc.chainConfig.Posv.Epoch == common.LiquidateLendingTradeBlock {
-		finalizedTx, err := ExtractLendingFinalizedTradeTransactions(block.Transactions())
-		if err != nil {
-			log.Crit("failed to extract finalizedTrades transaction", "err", err)
-		}
-		finalizedTrades := map[common.Hash]*lendingstate.LendingTrade{}
-		finalizedData, ok := bc.finalizedTrade.Get(finalizedTx.TxHash)
-		if ok && finalizedData != nil {
-			finalizedTrades = finalizedData.(map[common.Hash]*lendingstate.LendingTrade)
-		}
-		if len(finalizedTrades) > 0 {
-			if err := lendingService.UpdateLiquidatedTrade(block.Time().Uint64(), finalizedTx, finalizedTrades); err != nil {
-				log.Crit("lending: failed to UpdateLiquidatedTrade ", "blockNumber", block.Number(), "err", err)
-			}
-		}
+	// apply new chain
+	for i := len(newChain) - 1; i >= 0; i-- {
+		bc.queueTomoXEvents(newChain[i])
 	}
 }
 
@@ -27,6 +27,7 @@ import (
 
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/consensus/posv"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/core/vm"
@@ -1411,3 +1412,30 @@ func TestAreTwoBlocksSamePath(t *testing.T) {
 	})
 
 }
+
+// retentionLendingService keeps the lending state of a number of recent epochs.
+type retentionLendingService struct {
+	posv.LendingService
+	epochs uint64
+}
+
+func (s retentionLendingService) LendingStateRetention() (bool, uint64) {
+	return false, s.epochs
+}
+
+func TestLendingGCLimit(t *testing.T) {
+	bc := &BlockChain{chainConfig: &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 900}}}
+	tests := []struct {
+		epochs, current, want uint64
+	}{
+		{0, 1000, 1000 - triesInMemory}, // default policy
+		{2, 1000, 0},                    // less than 2 epochs imported
+		{2, 5000, 5000 - 1800},          // last 2 epochs kept
+		{1, 100000, 100000 - 900},       // last epoch kept
+	}
+	for i, tt := range tests {
+		if got := bc.lendingGCLimit(retentionLendingService{epochs: tt.epochs}, tt.current, tt.current-triesInMemory); got != tt.want {
+			t.Errorf("test %d: lending GC limit mismatch: have %d, want %d", i, got, tt.want)
+		}
+	}
+}
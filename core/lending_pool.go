@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"math/big"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -41,6 +43,14 @@ import (
 var (
 	ErrInvalidLendingSide        = errors.New("invalid lending side")
 	ErrInvalidLendingType        = errors.New("invalid lending type")
+	ErrInvalidLendingTrigger     = errors.New("invalid lending trigger interest")
+	ErrInvalidLendingDisplay     = errors.New("invalid lending displayed quantity")
+	ErrInvalidLendingRateModel   = errors.New("invalid lending rate model")
+	ErrInvalidLendingReferrer    = errors.New("invalid lending referrer")
+	ErrInvalidLendingMargin      = errors.New("invalid lending margin order")
+	ErrInvalidLendingPriceOracle = errors.New("invalid lending price oracle")
+	ErrInvalidLendingPriceReport = errors.New("invalid lending price report")
+	ErrInvalidLendingTimeInForce = errors.New("invalid lending time in force")
 	ErrInvalidLendingStatus      = errors.New("invalid lending status")
 	ErrInvalidLendingUserAddress = errors.New("invalid lending user address")
 	ErrInvalidLendingQuantity    = errors.New("invalid lending quantity")
@@ -48,8 +58,13 @@ var (
 	ErrInvalidLendingRelayer     = errors.New("invalid lending relayer address")
 	ErrInvalidLendingHash        = errors.New("invalid lending hash")
 	ErrInvalidCancelledLending   = errors.New("invalid cancel lending id")
+	ErrInvalidAmendedLending     = errors.New("invalid amend lending id")
 	ErrInvalidLendingTradeID     = errors.New("invalid lending trade ID")
 	ErrInvalidLendingCollateral  = errors.New("invalid collateral")
+
+	// ErrRelayerPoolOverflow is returned if the relayer of a lending transaction already has
+	// as many transactions in the pool as its slots allow.
+	ErrRelayerPoolOverflow = errors.New("relayer lending pool slots full")
 )
 
 var (
@@ -67,6 +82,7 @@ type LendingPoolConfig struct {
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
+	RelayerSlots uint64 // Maximum number of transaction slots of the users of a relayer, 0 for no limit
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
 }
@@ -142,6 +158,7 @@ type LendingPool struct {
 	queue     map[common.Address]*lendingtxList         // Queued but non-processable transactions
 	beats     map[common.Address]time.Time              // Last heartbeat from each known account
 	all       map[common.Hash]*types.LendingTransaction // All transactions to allow lookups
+	relayers  map[common.Address]uint64                 // Number of transactions of each relayer in all
 	wg        sync.WaitGroup                            // for shutdown sync
 	homestead bool
 	IsSigner  func(address common.Address) bool
@@ -158,11 +175,12 @@ func NewLendingPool(chainconfig *params.ChainConfig, chain blockChainLending) *L
 		config:      config,
 		chainconfig: chainconfig,
 		chain:       chain,
-		signer:      types.LendingTxSigner{},
+		signer:      types.MakeLendingSigner(chainconfig, new(big.Int).Add(chain.CurrentBlock().Number(), common.Big1)),
 		pending:     make(map[common.Address]*lendingtxList),
 		queue:       make(map[common.Address]*lendingtxList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.LendingTransaction),
+		relayers:    make(map[common.Address]uint64),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 	}
 	pool.locals = newLendingAccountSet(pool.signer)
@@ -220,6 +238,9 @@ func (pool *LendingPool) loop() {
 				if pool.chainconfig.IsHomestead(ev.Block.Number()) {
 					pool.homestead = true
 				}
+				// the transactions of the pool are mined in the next block, accept its signatures
+				pool.signer = types.MakeLendingSigner(pool.chainconfig, new(big.Int).Add(ev.Block.Number(), common.Big1))
+				pool.locals.signer = pool.signer
 				log.Debug("LendingPool new chain header reset pool", "old", head.Header().Number, "new", ev.Block.Header().Number)
 				pool.reset(head, ev.Block)
 				head = ev.Block
@@ -334,6 +355,60 @@ func (pool *LendingPool) Stop() {
 	log.Info("Transaction pool stopped")
 }
 
+// SetRelayerSlots limits the number of pending and queued transactions placed through a single
+// relayer, so that the users of a relayer can't fill the pool shared with the other relayers.
+// Zero removes the limit.
+func (pool *LendingPool) SetRelayerSlots(slots uint64) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.config.RelayerSlots = slots
+}
+
+// addToAll records a transaction in the lookup of all the transactions of the pool, and counts it
+// in the transactions of its relayer.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LendingPool) addToAll(tx *types.LendingTransaction) {
+	hash := tx.Hash()
+	if pool.all[hash] != nil {
+		return
+	}
+	pool.all[hash] = tx
+	pool.relayers[tx.RelayerAddress()]++
+}
+
+// removeFromAll removes a transaction from the lookup of all the transactions of the pool, and
+// from the count of the transactions of its relayer.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LendingPool) removeFromAll(hash common.Hash) {
+	tx, ok := pool.all[hash]
+	if !ok {
+		return
+	}
+	delete(pool.all, hash)
+	relayer := tx.RelayerAddress()
+	if pool.relayers[relayer]--; pool.relayers[relayer] == 0 {
+		delete(pool.relayers, relayer)
+	}
+}
+
+// relayerSlotsFull returns whether a new transaction is rejected because its relayer has no slot
+// left. A transaction replacing one of the same sender and nonce doesn't take a new slot.
+func (pool *LendingPool) relayerSlotsFull(from common.Address, tx *types.LendingTransaction) bool {
+	if pool.config.RelayerSlots == 0 {
+		return false
+	}
+	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
+		return false
+	}
+	if list := pool.queue[from]; list != nil && list.Overlaps(tx) {
+		return false
+	}
+	return pool.relayers[tx.RelayerAddress()] >= pool.config.RelayerSlots
+}
+
 // SubscribeTxPreEvent registers a subscription of TxPreEvent and
 // starts sending event to the given channel.
 func (pool *LendingPool) SubscribeTxPreEvent(ch chan<- LendingTxPreEvent) event.Subscription {
@@ -371,6 +446,38 @@ func (pool *LendingPool) stats() (int, int) {
 	return pending, queued
 }
 
+// Content retrieves the data content of the transaction pool, returning all the
+// pending as well as queued transactions, grouped by account and sorted by nonce.
+func (pool *LendingPool) Content() (map[common.Address]types.LendingTransactions, map[common.Address]types.LendingTransactions) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	pending := make(map[common.Address]types.LendingTransactions)
+	for addr, list := range pool.pending {
+		pending[addr] = list.Flatten()
+	}
+	queued := make(map[common.Address]types.LendingTransactions)
+	for addr, list := range pool.queue {
+		queued[addr] = list.Flatten()
+	}
+	return pending, queued
+}
+
+// ContentFrom retrieves the pending and queued transactions of an account, sorted by nonce.
+func (pool *LendingPool) ContentFrom(addr common.Address) (types.LendingTransactions, types.LendingTransactions) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var pending, queued types.LendingTransactions
+	if list, ok := pool.pending[addr]; ok {
+		pending = list.Flatten()
+	}
+	if list, ok := pool.queue[addr]; ok {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
 // Pending retrieves all currently processable transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -418,7 +525,7 @@ func (pool *LendingPool) validateNewLending(cloneStateDb *state.StateDB, cloneLe
 	if quantity == nil || quantity.Sign() <= 0 {
 		return ErrInvalidLendingQuantity
 	}
-	if lendingType != LendingTypeMarket {
+	if lendingType != LendingTypeMarket && lendingType != lendingstate.Margin {
 		if interest <= 0 {
 			return ErrInvalidLendingInterest
 		}
@@ -427,9 +534,57 @@ func (pool *LendingPool) validateNewLending(cloneStateDb *state.StateDB, cloneLe
 	if lendingSide != lendingstate.Investing && lendingSide != lendingstate.Borrowing {
 		return ErrInvalidLendingSide
 	}
-	if lendingType != LendingTypeLimit && lendingType != LendingTypeMarket {
+	if lendingType == lendingstate.StopLimit {
+		if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+			return ErrInvalidLendingType
+		}
+		if trigger, ok := new(big.Int).SetString(tx.ExtraData(), 10); !ok || trigger.Sign() <= 0 {
+			return ErrInvalidLendingTrigger
+		}
+	} else if lendingType == lendingstate.Iceberg {
+		if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+			return ErrInvalidLendingType
+		}
+		if display, ok := new(big.Int).SetString(tx.ExtraData(), 10); !ok || display.Sign() <= 0 || display.Cmp(quantity) > 0 {
+			return ErrInvalidLendingDisplay
+		}
+	} else if lendingType == lendingstate.Margin {
+		if !pool.chainconfig.IsTIPTomoXMargin(pool.chain.CurrentBlock().Number()) {
+			return ErrInvalidLendingType
+		}
+		// the leveraged part of the spot order is borrowed
+		if lendingSide != lendingstate.Borrowing {
+			return ErrInvalidLendingSide
+		}
+		if _, err := lendingstate.ParseMarginOrder(tx.LendingToken(), tx.ExtraData()); err != nil {
+			return ErrInvalidLendingMargin
+		}
+	} else if lendingType != LendingTypeLimit && lendingType != LendingTypeMarket {
 		return ErrInvalidLendingType
 	}
+	if lendingType == LendingTypeLimit && tx.HasTimeInForce() {
+		if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+			return ErrInvalidLendingTimeInForce
+		}
+		extraData, _ := types.SplitLendingReferrer(tx.ExtraData())
+		if extraData == types.LendingTimeInForcePO && !pool.chainconfig.IsTIPTomoXPostOnly(pool.chain.CurrentBlock().Number()) {
+			return ErrInvalidLendingTimeInForce
+		}
+		if tif := strings.SplitN(extraData, ":", 2); tif[0] == types.LendingTimeInForceGTT {
+			if expiry, err := strconv.ParseUint(tif[1], 10, 64); err != nil || expiry <= pool.chain.CurrentBlock().Time().Uint64() {
+				return ErrInvalidLendingTimeInForce
+			}
+		}
+	}
+	if referrer := tx.Referrer(); referrer != (common.Address{}) {
+		if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+			return ErrInvalidLendingReferrer
+		}
+		// the referrer must be registered with the relayer
+		if cloneLendingStateDb.GetReferralShare(tx.RelayerAddress(), referrer).Sign() == 0 {
+			return ErrInvalidLendingReferrer
+		}
+	}
 	if tx.Side() == lendingstate.Borrowing {
 		if tx.CollateralToken().String() == lendingstate.EmptyAddress || tx.CollateralToken().String() == tx.LendingToken().String() {
 			return ErrInvalidLendingCollateral
@@ -446,7 +601,7 @@ func (pool *LendingPool) validateNewLending(cloneStateDb *state.StateDB, cloneLe
 			return ErrInvalidLendingCollateral
 		}
 	}
-	if lendingType == LendingTypeLimit {
+	if lendingType == LendingTypeLimit || lendingType == lendingstate.StopLimit || lendingType == lendingstate.Iceberg {
 		if err := pool.validateBalance(cloneStateDb, cloneLendingStateDb, tx, tx.CollateralToken()); err != nil {
 			return err
 		}
@@ -469,6 +624,49 @@ func (pool *LendingPool) validateCancelledLending(cloneLendingStateDb *lendingst
 	}
 	return nil
 }
+
+// validateAmendedLending checks an amendment of an open limit item, whose amended quantity must be
+// covered by the balance of the user like the quantity of a new item.
+func (pool *LendingPool) validateAmendedLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingStatus
+	}
+	if tx.LendingId() == 0 {
+		return ErrInvalidAmendedLending
+	}
+	item := cloneLendingStateDb.GetLendingOrder(lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term()), common.Uint64ToHash(tx.LendingId()))
+	if item == lendingstate.EmptyLendingOrder || item.Quantity == nil || item.Quantity.Sign() == 0 {
+		log.Debug("LendingOrder not found ", "LendingId", tx.LendingId(), "LendToken", tx.LendingToken().Hex(), "Term", tx.Term())
+		return ErrInvalidAmendedLending
+	}
+	if item.Hash != tx.LendingHash() {
+		log.Debug("Invalid lending hash", "expected", item.Hash.Hex(), "got", tx.LendingHash().Hex())
+		return ErrInvalidLendingHash
+	}
+	if item.UserAddress != tx.UserAddress() {
+		return ErrInvalidLendingUserAddress
+	}
+	if item.Relayer != tx.RelayerAddress() {
+		return ErrInvalidLendingRelayer
+	}
+	if item.Type != lendingstate.Limit || tx.Type() != lendingstate.Limit {
+		return ErrInvalidLendingType
+	}
+	if item.Side != tx.Side() {
+		return ErrInvalidLendingSide
+	}
+	if item.CollateralToken != tx.CollateralToken() {
+		return ErrInvalidLendingCollateral
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	if tx.Interest() == 0 {
+		return ErrInvalidLendingInterest
+	}
+	return pool.validateBalance(cloneStateDb, cloneLendingStateDb, tx, tx.CollateralToken())
+}
+
 func (pool *LendingPool) validateRepayLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
 	if tx.LendingTradeId() == 0 {
 		return ErrInvalidLendingTradeID
@@ -513,6 +711,303 @@ func (pool *LendingPool) validateTopupLending(cloneStateDb *state.StateDB, clone
 	return nil
 }
 
+func (pool *LendingPool) validateTopupReserveLending(cloneStateDb *state.StateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	// a zero reserve disables the automatic top-up
+	if tx.Quantity() == nil || tx.Quantity().Sign() < 0 {
+		return ErrInvalidLendingQuantity
+	}
+	collateralList, _ := lendingstate.GetCollaterals(cloneStateDb, tx.RelayerAddress(), tx.LendingToken(), tx.Term())
+	for _, collateral := range collateralList {
+		if tx.CollateralToken().String() == collateral.String() {
+			return nil
+		}
+	}
+	return ErrInvalidLendingCollateral
+}
+
+func (pool *LendingPool) validateAuctionBidLending(cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	auctionBook := lendingstate.GetLendingAuctionBookHash(lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term()))
+	auction := cloneLendingStateDb.GetLendingTrade(auctionBook, common.Uint64ToHash(tx.LendingTradeId()))
+	if auction == lendingstate.EmptyLendingTrade {
+		return ErrInvalidLendingTradeID
+	}
+	return nil
+}
+
+func (pool *LendingPool) validateAddCollateralLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.LendingTradeId() == 0 {
+		return ErrInvalidLendingTradeID
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term())
+	lendingTrade := cloneLendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(tx.LendingTradeId()))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return ErrInvalidLendingTradeID
+	}
+	if tx.UserAddress().String() != lendingTrade.Borrower.String() {
+		return ErrInvalidLendingUserAddress
+	}
+	if tx.CollateralToken().String() == lendingTrade.CollateralToken.String() {
+		return ErrInvalidLendingCollateral
+	}
+	valid := false
+	collateralList, _ := lendingstate.GetCollaterals(cloneStateDb, tx.RelayerAddress(), tx.LendingToken(), tx.Term())
+	for _, collateral := range collateralList {
+		if tx.CollateralToken().String() == collateral.String() {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidLendingCollateral
+	}
+	if balance := lendingstate.GetTokenBalance(tx.UserAddress(), tx.CollateralToken(), cloneStateDb); balance.Cmp(tx.Quantity()) < 0 {
+		return fmt.Errorf("not enough balance to add collateral. Token: %s. Expected: %v. Have: %v", tx.CollateralToken().Hex(), tx.Quantity(), balance)
+	}
+	return nil
+}
+
+func (pool *LendingPool) validateRolloverLending(cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.LendingTradeId() == 0 {
+		return ErrInvalidLendingTradeID
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term())
+	lendingTrade := cloneLendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(tx.LendingTradeId()))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return ErrInvalidLendingTradeID
+	}
+	if tx.UserAddress().String() != lendingTrade.Borrower.String() {
+		return ErrInvalidLendingUserAddress
+	}
+	if cloneLendingStateDb.GetTradeLiquidationThreshold(lendingBook, tx.LendingTradeId()).Sign() > 0 {
+		return fmt.Errorf("multi-collateral lending trade %d can't be rolled over", tx.LendingTradeId())
+	}
+	if lendingTrade.LiquidationTime <= pool.chain.CurrentBlock().Time().Uint64() {
+		return fmt.Errorf("lending trade %d has reached maturity", tx.LendingTradeId())
+	}
+	return nil
+}
+
+func (pool *LendingPool) validateRecallLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.LendingTradeId() == 0 {
+		return ErrInvalidLendingTradeID
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term())
+	lendingTrade := cloneLendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(tx.LendingTradeId()))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return ErrInvalidLendingTradeID
+	}
+	if tx.UserAddress().String() != lendingTrade.Borrower.String() {
+		return ErrInvalidLendingUserAddress
+	}
+	if tx.RelayerAddress().String() != lendingTrade.BorrowingRelayer.String() {
+		return ErrInvalidLendingRelayer
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 || tx.Quantity().Cmp(lendingTrade.CollateralLockedAmount) >= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	if cloneLendingStateDb.GetTradeLiquidationThreshold(lendingBook, tx.LendingTradeId()).Sign() > 0 {
+		return fmt.Errorf("collateral of multi-collateral lending trade %d can't be recalled", tx.LendingTradeId())
+	}
+
+	// check the health factor of the trade against the latest medium collateral price
+	posvEngine, ok := pool.chain.Engine().(*posv.Posv)
+	if !ok {
+		return ErrNotPoSV
+	}
+	tomoXServ := posvEngine.GetTomoXService()
+	lendingServ := posvEngine.GetLendingService()
+	if tomoXServ == nil {
+		return fmt.Errorf("tomox not found in order validation")
+	}
+	author, err := pool.chain.Engine().Author(pool.chain.CurrentHeader())
+	if err != nil {
+		return err
+	}
+	tradingStateDb, err := tomoXServ.GetTradingState(pool.chain.CurrentBlock(), author)
+	if err != nil {
+		return fmt.Errorf("validateLending: failed to get tradingStateDb. Error: %v", err)
+	}
+	_, collateralPrice, err := lendingServ.GetCollateralPrices(pool.chain.CurrentHeader(), pool.chain, cloneStateDb, tradingStateDb.Copy(), lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	if err != nil {
+		return err
+	}
+	if collateralPrice == nil || collateralPrice.Sign() <= 0 {
+		return lendingstate.ErrInvalidCollateralPrice
+	}
+	depositRate, liquidationRate, recallRate := lendingTrade.DepositRate, lendingTrade.LiquidationRate, lendingTrade.RecallRate
+	if depositRate == nil || liquidationRate == nil || recallRate == nil || liquidationRate.Sign() <= 0 {
+		depositRate, liquidationRate, recallRate = lendingstate.GetCollateralDetail(cloneStateDb, lendingTrade.CollateralToken)
+	}
+	if lendingstate.CalculateHealthFactor(collateralPrice, lendingTrade.LiquidationPrice).Cmp(recallRate) <= 0 {
+		return lendingstate.ErrLowHealthFactor
+	}
+	if maxRecallAmount := lendingstate.CalculateMaxRecallAmount(lendingTrade.CollateralLockedAmount, lendingTrade.LiquidationPrice, collateralPrice, depositRate, liquidationRate); tx.Quantity().Cmp(maxRecallAmount) > 0 {
+		return fmt.Errorf("recall quantity %v exceeds the recallable collateral %v", tx.Quantity(), maxRecallAmount)
+	}
+	return nil
+}
+
+func (pool *LendingPool) validateCircuitBreakerLending(cloneStateDb *state.StateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	// the circuit breaker of a lending book is governed by the owners of the relayers listing it
+	if tx.UserAddress() != lendingstate.GetRelayerOwner(tx.RelayerAddress(), cloneStateDb) {
+		return ErrInvalidLendingUserAddress
+	}
+	// a zero threshold disables the circuit breaker
+	if tx.Quantity() == nil || tx.Quantity().Sign() < 0 {
+		return ErrInvalidLendingQuantity
+	}
+	if tx.Quantity().Sign() > 0 && tx.Interest() == 0 {
+		return ErrInvalidLendingInterest
+	}
+	return nil
+}
+
+func (pool *LendingPool) validateRateModelLending(cloneStateDb *state.StateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	// like the circuit breaker, the rate model of a lending book is governed by the owners of the relayers listing it
+	if tx.UserAddress() != lendingstate.GetRelayerOwner(tx.RelayerAddress(), cloneStateDb) {
+		return ErrInvalidLendingUserAddress
+	}
+	if _, err := lendingstate.ParseLendingRateModel(tx.ExtraData()); err != nil {
+		return ErrInvalidLendingRateModel
+	}
+	return nil
+}
+
+func (pool *LendingPool) validateInterestModeLending(cloneStateDb *state.StateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXPeriodicInterest(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	// like the rate model, the interest mode of a lending book is governed by the owners of the relayers listing it
+	if tx.UserAddress() != lendingstate.GetRelayerOwner(tx.RelayerAddress(), cloneStateDb) {
+		return ErrInvalidLendingUserAddress
+	}
+	// a zero quantity settles the interest at close
+	if tx.Quantity() == nil || tx.Quantity().Sign() < 0 {
+		return ErrInvalidLendingQuantity
+	}
+	return nil
+}
+
+func (pool *LendingPool) validatePriceOracleLending(cloneStateDb *state.StateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXPriceOracle(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	// the price oracles are governed by the moderator listing the collateral tokens
+	if tx.UserAddress() != lendingstate.GetModerator(cloneStateDb) {
+		return ErrInvalidLendingUserAddress
+	}
+	if tx.CollateralToken() == (common.Address{}) {
+		return ErrInvalidLendingCollateral
+	}
+	if _, err := lendingstate.ParsePriceOracle(tx.ExtraData()); err != nil {
+		return ErrInvalidLendingPriceOracle
+	}
+	return nil
+}
+
+func (pool *LendingPool) validatePriceReportLending(cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXPriceOracle(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.CollateralToken() == (common.Address{}) || tx.CollateralToken() == tx.LendingToken() {
+		return ErrInvalidLendingCollateral
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	// a signed report is relayed by anyone, the others are sent by the reporters
+	reporter := tx.UserAddress()
+	if tx.ExtraData() != "" {
+		signer, _, err := lendingstate.RecoverPriceReporter(tx.CollateralToken(), tx.LendingToken(), tx.Quantity(), tx.ExtraData())
+		if err != nil {
+			return ErrInvalidLendingPriceReport
+		}
+		reporter = signer
+	}
+	if !cloneLendingStateDb.IsPriceReporter(tx.CollateralToken(), reporter) {
+		return ErrInvalidLendingPriceReport
+	}
+	return nil
+}
+
+func (pool *LendingPool) validateReferralLending(cloneStateDb *state.StateDB, tx *types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoXLendingV2(pool.chain.CurrentBlock().Number()) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != lendingstate.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	// referrers are registered by the owner of the relayer sharing its fees
+	if tx.UserAddress() != lendingstate.GetRelayerOwner(tx.RelayerAddress(), cloneStateDb) {
+		return ErrInvalidLendingUserAddress
+	}
+	// a zero share unregisters the referrer
+	if tx.Quantity() == nil || tx.Quantity().Sign() < 0 || tx.Quantity().Cmp(lendingstate.ReferralShareBasis) > 0 {
+		return ErrInvalidLendingQuantity
+	}
+	if _, err := lendingstate.ParseLendingReferral(tx.ExtraData()); err != nil {
+		return ErrInvalidLendingReferrer
+	}
+	return nil
+}
+
 func (pool *LendingPool) validateBalance(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction, collateralToken common.Address) error {
 	posvEngine, ok := pool.chain.Engine().(*posv.Posv)
 	if !ok {
@@ -609,12 +1104,48 @@ func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 	if tx.IsCancelledLending() {
 		return pool.validateCancelledLending(cloneLendingStateDb, tx)
 	}
+	if tx.IsAmendedLending() {
+		return pool.validateAmendedLending(cloneStateDb, cloneLendingStateDb, tx)
+	}
 	if tx.IsTopupLending() {
 		return pool.validateTopupLending(cloneStateDb, cloneLendingStateDb, tx)
 	}
 	if tx.IsRepayLending() {
 		return pool.validateRepayLending(cloneStateDb, cloneLendingStateDb, tx)
 	}
+	if tx.IsTopupReserveLending() {
+		return pool.validateTopupReserveLending(cloneStateDb, tx)
+	}
+	if tx.IsAuctionBidLending() {
+		return pool.validateAuctionBidLending(cloneLendingStateDb, tx)
+	}
+	if tx.IsAddCollateralLending() {
+		return pool.validateAddCollateralLending(cloneStateDb, cloneLendingStateDb, tx)
+	}
+	if tx.IsRolloverLending() {
+		return pool.validateRolloverLending(cloneLendingStateDb, tx)
+	}
+	if tx.IsRecallLending() {
+		return pool.validateRecallLending(cloneStateDb, cloneLendingStateDb, tx)
+	}
+	if tx.IsCircuitBreakerLending() {
+		return pool.validateCircuitBreakerLending(cloneStateDb, tx)
+	}
+	if tx.IsRateModelLending() {
+		return pool.validateRateModelLending(cloneStateDb, tx)
+	}
+	if tx.IsReferralLending() {
+		return pool.validateReferralLending(cloneStateDb, tx)
+	}
+	if tx.IsInterestModeLending() {
+		return pool.validateInterestModeLending(cloneStateDb, tx)
+	}
+	if tx.IsPriceOracleLending() {
+		return pool.validatePriceOracleLending(cloneStateDb, tx)
+	}
+	if tx.IsPriceReportLending() {
+		return pool.validatePriceReportLending(cloneLendingStateDb, tx)
+	}
 
 	return ErrInvalidLendingStatus
 }
@@ -624,8 +1155,8 @@ func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 func (pool *LendingPool) validateTx(tx *types.LendingTransaction, local bool) error {
 
 	// check if sender is in black list
-	if tx.From() != nil && common.Blacklist[*tx.From()] {
-		return fmt.Errorf("Reject transaction with sender in black-list: %v", tx.From().Hex())
+	if from := tx.From(pool.signer); from != nil && common.Blacklist[*from] {
+		return fmt.Errorf("Reject transaction with sender in black-list: %v", from.Hex())
 	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > 32*1024 {
@@ -681,6 +1212,11 @@ func (pool *LendingPool) add(tx *types.LendingTransaction, local bool) (bool, er
 		log.Debug("Add lending transaction to pool full", "hash", hash, "nonce", tx.Nonce())
 		return false, ErrPoolOverflow
 	}
+	// Only a replacement is accepted once the relayer slots are full
+	if pool.relayerSlotsFull(from, tx) {
+		log.Debug("Add lending transaction to relayer slots full", "hash", hash, "relayer", tx.RelayerAddress())
+		return false, ErrRelayerPoolOverflow
+	}
 	// If the transaction is replacing an already pending one, do directly
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
 		inserted, old := list.Add(tx)
@@ -689,10 +1225,10 @@ func (pool *LendingPool) add(tx *types.LendingTransaction, local bool) (bool, er
 			return false, ErrPendingNonceTooLow
 		}
 		if old != nil {
-			delete(pool.all, old.Hash())
+			pool.removeFromAll(old.Hash())
 			pendingReplaceCounter.Inc(1)
 		}
-		pool.all[tx.Hash()] = tx
+		pool.addToAll(tx)
 		pool.journalTx(from, tx)
 
 		log.Debug("Lending Pooled new executable transaction", "hash", hash, "useraddress", tx.UserAddress(), "nonce", tx.Nonce(), "status", tx.Status(), "lendingid", tx.LendingId())
@@ -731,10 +1267,10 @@ func (pool *LendingPool) enqueueTx(hash common.Hash, tx *types.LendingTransactio
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.removeFromAll(old.Hash())
 		queuedReplaceCounter.Inc(1)
 	}
-	pool.all[hash] = tx
+	pool.addToAll(tx)
 	return old != nil, nil
 }
 
@@ -763,19 +1299,17 @@ func (pool *LendingPool) promoteTx(addr common.Address, hash common.Hash, tx *ty
 	inserted, old := list.Add(tx)
 	if !inserted {
 		// An older transaction was better, discard this
-		delete(pool.all, hash)
+		pool.removeFromAll(hash)
 		pendingDiscardCounter.Inc(1)
 		return
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.removeFromAll(old.Hash())
 		pendingReplaceCounter.Inc(1)
 	}
 	// Failsafe to work around direct pending inserts (tests)
-	if pool.all[hash] == nil {
-		pool.all[hash] = tx
-	}
+	pool.addToAll(tx)
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr.Hash(), tx.Nonce()+1)
@@ -812,6 +1346,50 @@ func (pool *LendingPool) AddRemotes(txs []*types.LendingTransaction) []error {
 	return pool.addTxs(txs, false)
 }
 
+// AddLocalsAtomic validates a batch of transactions and adds them to the pool as
+// local ones only if all of them are valid. The index of the first invalid
+// transaction is reported in the returned error.
+func (pool *LendingPool) AddLocalsAtomic(txs []*types.LendingTransaction) error {
+	if !pool.chainconfig.IsTIPTomoX(pool.chain.CurrentBlock().Number()) {
+		return nil
+	}
+	for _, tx := range txs {
+		tx.CacheHash()
+		types.CacheLendingSigner(pool.signer, tx)
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if uint64(len(pool.all)+len(txs)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
+		return ErrPoolOverflow
+	}
+	known := make(map[common.Hash]struct{}, len(txs))
+	relayerTxs := make(map[common.Address]uint64) // transactions of the batch by relayer
+	for i, tx := range txs {
+		hash := tx.Hash()
+		if _, ok := known[hash]; ok || pool.all[hash] != nil {
+			return fmt.Errorf("lending transaction %d: known transaction: %x", i, hash)
+		}
+		known[hash] = struct{}{}
+		if err := pool.validateTx(tx, true); err != nil {
+			invalidTxCounter.Inc(1)
+			return fmt.Errorf("lending transaction %d: %v", i, err)
+		}
+		if pool.config.RelayerSlots > 0 {
+			relayer := tx.RelayerAddress()
+			if relayerTxs[relayer]++; pool.relayers[relayer]+relayerTxs[relayer] > pool.config.RelayerSlots {
+				return fmt.Errorf("lending transaction %d: %v", i, ErrRelayerPoolOverflow)
+			}
+		}
+	}
+	for i, err := range pool.addTxsLocked(txs, !pool.config.NoLocals) {
+		if err != nil {
+			return fmt.Errorf("lending transaction %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
 // addTx enqueues a single transaction into the pool if it is valid.
 func (pool *LendingPool) addTx(tx *types.LendingTransaction, local bool) error {
 	if !pool.chainconfig.IsTIPTomoX(pool.chain.CurrentBlock().Number()) {
@@ -910,7 +1488,7 @@ func (pool *LendingPool) removeTx(hash common.Hash) {
 	addr, _ := types.LendingSender(pool.signer, tx) // already validated during insertion
 
 	// Remove it from the list of known transactions
-	delete(pool.all, hash)
+	pool.removeFromAll(hash)
 
 	// Remove the transaction from the pending lists and reset the account nonce
 	if pending := pool.pending[addr]; pending != nil {
@@ -964,7 +1542,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 		for _, tx := range list.Forward(pool.currentLendingState.GetNonce(addr.Hash())) {
 			hash := tx.Hash()
 			log.Trace("Removed old queued transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.removeFromAll(hash)
 
 		}
 
@@ -978,7 +1556,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 		if !pool.locals.contains(addr) {
 			for _, tx := range list.Cap(int(pool.config.AccountQueue)) {
 				hash := tx.Hash()
-				delete(pool.all, hash)
+				pool.removeFromAll(hash)
 
 				queuedRateLimitCounter.Inc(1)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
@@ -1023,7 +1601,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 						for _, tx := range list.Cap(list.Len() - 1) {
 							// Drop the transaction from the global pools too
 							hash := tx.Hash()
-							delete(pool.all, hash)
+							pool.removeFromAll(hash)
 
 							// Update the account nonce to the dropped transaction
 							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i].Hash()) > nonce {
@@ -1044,7 +1622,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 					for _, tx := range list.Cap(list.Len() - 1) {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
-						delete(pool.all, hash)
+						pool.removeFromAll(hash)
 
 						// Update the account nonce to the dropped transaction
 						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr.Hash()) > nonce {
@@ -1112,7 +1690,7 @@ func (pool *LendingPool) demoteUnexecutables() {
 		for _, tx := range list.Forward(nonce) {
 			hash := tx.Hash()
 			log.Debug("Removed old pending transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.removeFromAll(hash)
 		}
 
 		// If there's a gap in front, warn (should never happen) and postpone all transactions
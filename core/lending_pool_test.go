@@ -285,3 +285,48 @@ func TestRecallLending(t *testing.T) {
 	testSendLending(key, nonce, USDAddress, common.HexToAddress(common.TomoNativeAddress), new(big.Int).Mul(_1E8, big.NewInt(1000)), interestRate, lendingstate.Borrowing, lendingstate.LendingStatusNew, true, 0, 0, common.Hash{}, "")
 	time.Sleep(2 * time.Second)
 }
+
+func TestLendingPoolRelayerSlots(t *testing.T) {
+	var (
+		relayer = common.HexToAddress("0x0D3ab14BBaD3D99F4203bd7a11aCB94882050E7e")
+		other   = common.HexToAddress("0x1")
+		user    = common.HexToAddress("0x2")
+	)
+	newTx := func(nonce uint64, relayer common.Address) *types.LendingTransaction {
+		return types.NewLendingTransaction(nonce, big.NewInt(1), 1, 86400, relayer, user, USDAddress, BTCAddress, false, lendingstate.LendingStatusNew, lendingstate.Borrowing, lendingstate.Limit, common.Hash{}, 0, 0, "")
+	}
+	pool := &LendingPool{
+		pending:  make(map[common.Address]*lendingtxList),
+		queue:    make(map[common.Address]*lendingtxList),
+		all:      make(map[common.Hash]*types.LendingTransaction),
+		relayers: make(map[common.Address]uint64),
+	}
+	pool.pending[user], pool.queue[user] = newLendingTxList(true), newLendingTxList(false)
+	for nonce, list := range []*lendingtxList{pool.pending[user], pool.queue[user]} {
+		tx := newTx(uint64(nonce), relayer)
+		list.Add(tx)
+		pool.addToAll(tx)
+	}
+	if pool.relayerSlotsFull(user, newTx(2, relayer)) {
+		t.Fatal("relayer slots full without a limit")
+	}
+	pool.SetRelayerSlots(2)
+	if !pool.relayerSlotsFull(user, newTx(2, relayer)) {
+		t.Fatal("relayer slots not full")
+	}
+	if pool.relayerSlotsFull(user, newTx(1, relayer)) {
+		t.Fatal("replacement of a queued transaction rejected")
+	}
+	if pool.relayerSlotsFull(user, newTx(2, other)) {
+		t.Fatal("transaction of another relayer rejected")
+	}
+	// the count of the relayer follows the transactions leaving the pool
+	pool.removeFromAll(pool.queue[user].txs.Flatten()[0].Hash())
+	if pool.relayerSlotsFull(user, newTx(2, relayer)) {
+		t.Fatal("relayer slots full after a transaction left the pool")
+	}
+	pool.removeFromAll(pool.pending[user].txs.Flatten()[0].Hash())
+	if _, ok := pool.relayers[relayer]; ok {
+		t.Fatalf("relayer still counted without transactions: %d", pool.relayers[relayer])
+	}
+}
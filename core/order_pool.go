@@ -52,12 +52,19 @@ var (
 )
 
 var (
-	OrderTypeLimit    = "LO"
-	OrderTypeMarket   = "MO"
-	OrderStatusNew    = "NEW"
-	OrderStatusCancle = "CANCELLED"
-	OrderSideBid      = "BUY"
-	OrderSideAsk      = "SELL"
+	OrderTypeLimit        = "LO"
+	OrderTypeMarket       = "MO"
+	OrderTypeStopLoss     = "SL"
+	OrderTypeTakeProfit   = "TP"
+	OrderTypeTrailingStop = "TS"
+	OrderTypePostOnly     = "PO"
+	OrderTypeFeeTier      = "FT"
+	OrderTypeMinNotional  = "MN"
+	OrderTypePriceBand    = "PB"
+	OrderStatusNew        = "NEW"
+	OrderStatusCancle     = "CANCELLED"
+	OrderSideBid          = "BUY"
+	OrderSideAsk          = "SELL"
 )
 
 var (
@@ -75,6 +82,7 @@ type OrderPoolConfig struct {
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
+	CancelSlots  uint64 // Transaction slots reserved to the cancellations once the pool is full
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
 }
@@ -105,6 +113,7 @@ var DefaultOrderPoolConfig = OrderPoolConfig{
 	GlobalSlots:  4096,
 	AccountQueue: 64,
 	GlobalQueue:  1024,
+	CancelSlots:  256,
 
 	Lifetime: 3 * time.Hour,
 }
@@ -166,7 +175,7 @@ func NewOrderPool(chainconfig *params.ChainConfig, chain blockChainTomox) *Order
 		config:      config,
 		chainconfig: chainconfig,
 		chain:       chain,
-		signer:      types.OrderTxSigner{},
+		signer:      types.MakeOrderSigner(chainconfig, new(big.Int).Add(chain.CurrentBlock().Number(), common.Big1)),
 		pending:     make(map[common.Address]*ordertxList),
 		queue:       make(map[common.Address]*ordertxList),
 		beats:       make(map[common.Address]time.Time),
@@ -227,6 +236,9 @@ func (pool *OrderPool) loop() {
 				if pool.chainconfig.IsHomestead(ev.Block.Number()) {
 					pool.homestead = true
 				}
+				// the transactions of the pool are mined in the next block, accept its signatures
+				pool.signer = types.MakeOrderSigner(pool.chainconfig, new(big.Int).Add(ev.Block.Number(), common.Big1))
+				pool.locals.signer = pool.signer
 				log.Debug("OrderPool new chain header reset pool", "old", head.Header().Number, "new", ev.Block.Header().Number)
 				pool.reset(head, ev.Block)
 				head = ev.Block
@@ -446,7 +458,17 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 		if quantity == nil || quantity.Cmp(big.NewInt(0)) <= 0 {
 			return ErrInvalidOrderQuantity
 		}
-		if orderType != OrderTypeMarket {
+		if orderType == OrderTypeFeeTier {
+			// the price of a fee tier order is a fee rate, which may be zero
+			if price == nil || price.Sign() < 0 || price.Cmp(common.TomoXBaseFee) > 0 {
+				return ErrInvalidOrderPrice
+			}
+		} else if orderType == OrderTypePriceBand {
+			// the price of a price band order is a window of epochs, zero for the last epoch
+			if price == nil || price.Sign() < 0 || price.Cmp(new(big.Int).SetUint64(tradingstate.MaxTwapWindow)) > 0 {
+				return ErrInvalidOrderPrice
+			}
+		} else if orderType != OrderTypeMarket && orderType != OrderTypeMinNotional {
 			if price == nil || price.Cmp(big.NewInt(0)) <= 0 {
 				return ErrInvalidOrderPrice
 			}
@@ -455,14 +477,50 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 		if orderSide != OrderSideAsk && orderSide != OrderSideBid {
 			return ErrInvalidOrderSide
 		}
-		if orderType != OrderTypeLimit && orderType != OrderTypeMarket {
+		if orderType == OrderTypeStopLoss || orderType == OrderTypeTakeProfit {
+			if !pool.chainconfig.IsTIPTomoXStopOrders(pool.chain.CurrentBlock().Number()) {
+				return ErrInvalidOrderType
+			}
+		} else if orderType == OrderTypePostOnly {
+			if !pool.chainconfig.IsTIPTomoXPostOnly(pool.chain.CurrentBlock().Number()) {
+				return ErrInvalidOrderType
+			}
+		} else if orderType == OrderTypeTrailingStop {
+			if !pool.chainconfig.IsTIPTomoXTrailingStopOrders(pool.chain.CurrentBlock().Number()) {
+				return ErrInvalidOrderType
+			}
+		} else if orderType == OrderTypeFeeTier {
+			if !pool.chainconfig.IsTIPTomoXFeeTiers(pool.chain.CurrentBlock().Number()) {
+				return ErrInvalidOrderType
+			}
+			// the fee tiers of a relayer are governed by its owner
+			if tx.UserAddress() != tradingstate.GetRelayerOwner(tx.ExchangeAddress(), cloneStateDb) {
+				return ErrInvalidOrderUserAddress
+			}
+		} else if orderType == OrderTypeMinNotional {
+			if !pool.chainconfig.IsTIPTomoXMinNotional(pool.chain.CurrentBlock().Number()) {
+				return ErrInvalidOrderType
+			}
+			// the minimum notional of a pair is governed by the owners of the relayers listing it
+			if tx.UserAddress() != tradingstate.GetRelayerOwner(tx.ExchangeAddress(), cloneStateDb) {
+				return ErrInvalidOrderUserAddress
+			}
+		} else if orderType == OrderTypePriceBand {
+			if !pool.chainconfig.IsTIPTomoXPriceBand(pool.chain.CurrentBlock().Number()) {
+				return ErrInvalidOrderType
+			}
+			// so is its price band
+			if tx.UserAddress() != tradingstate.GetRelayerOwner(tx.ExchangeAddress(), cloneStateDb) {
+				return ErrInvalidOrderUserAddress
+			}
+		} else if orderType != OrderTypeLimit && orderType != OrderTypeMarket {
 			return ErrInvalidOrderType
 		}
 		if err := tradingstate.VerifyPair(cloneStateDb, tx.ExchangeAddress(), tx.BaseToken(), tx.QuoteToken()); err != nil {
 			return err
 		}
 
-		if orderType == OrderTypeLimit {
+		if orderType == OrderTypeLimit || orderType == OrderTypePostOnly {
 			posvEngine, ok := pool.chain.Engine().(*posv.Posv)
 			if !ok {
 				return ErrNotPoSV
@@ -504,7 +562,24 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 		if tx.OrderID() == 0 {
 			return ErrInvalidCancelledOrder
 		}
-		originOrder := cloneTomoXStateDb.GetOrder(tradingstate.GetTradingOrderBookHash(tx.BaseToken(), tx.QuoteToken()), common.BigToHash(new(big.Int).SetUint64(tx.OrderID())))
+		orderBook := tradingstate.GetTradingOrderBookHash(tx.BaseToken(), tx.QuoteToken())
+		originOrder := cloneTomoXStateDb.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(tx.OrderID())))
+		if originOrder.Hash != tx.OrderHash() && pool.chainconfig.IsTIPTomoXStopOrders(pool.chain.CurrentBlock().Number()) {
+			// the order may be a stop order which has not been triggered yet
+			if triggerBook := tradingstate.GetTradingTriggerBookHash(orderBook); cloneTomoXStateDb.Exist(triggerBook) {
+				if stopOrder := cloneTomoXStateDb.GetOrder(triggerBook, common.BigToHash(new(big.Int).SetUint64(tx.OrderID()))); stopOrder.Hash == tx.OrderHash() {
+					originOrder = stopOrder
+				}
+			}
+		}
+		if originOrder.Hash != tx.OrderHash() && pool.chainconfig.IsTIPTomoXTrailingStopOrders(pool.chain.CurrentBlock().Number()) {
+			// the order may be a trailing stop order which has not been triggered yet
+			if trailingBook := tradingstate.GetTradingTrailingBookHash(orderBook); cloneTomoXStateDb.Exist(trailingBook) {
+				if trailingOrder := cloneTomoXStateDb.GetOrder(trailingBook, common.BigToHash(new(big.Int).SetUint64(tx.OrderID()))); trailingOrder.Hash == tx.OrderHash() {
+					originOrder = trailingOrder
+				}
+			}
+		}
 		if originOrder == tradingstate.EmptyOrder {
 			log.Debug("Order not found ", "OrderId", tx.OrderID(), "BaseToken", tx.BaseToken().Hex(), "QuoteToken", tx.QuoteToken().Hex())
 			return ErrInvalidCancelledOrder
@@ -584,8 +659,13 @@ func (pool *OrderPool) add(tx *types.OrderTransaction, local bool) (bool, error)
 	}
 	from, _ := types.OrderSender(pool.signer, tx) // already validated
 
-	// If the transaction pool is full, discard underpriced transactions
-	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
+	// If the transaction pool is full, discard underpriced transactions. Cancellations have a
+	// priority lane of reserved slots, so that stale quotes can still be pulled.
+	capacity := pool.config.GlobalSlots + pool.config.GlobalQueue
+	if tx.IsCancelledOrder() {
+		capacity += pool.config.CancelSlots
+	}
+	if uint64(len(pool.all)) >= capacity {
 		log.Debug("Add order transaction to pool full", "hash", hash, "nonce", tx.Nonce())
 		return false, ErrPoolOverflow
 	}
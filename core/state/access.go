@@ -0,0 +1,122 @@
+package state
+
+import (
+	"github.com/tomochain/tomochain/common"
+)
+
+// accessKey identifies an account, or a storage slot of an account.
+type accessKey struct {
+	addr    common.Address
+	slot    common.Hash
+	storage bool
+}
+
+// AccessSet is the set of the accounts and storage slots read and written through a state. It
+// tells whether the changes made to several copies of a state can be merged: the copies must not
+// write what the others read or write.
+type AccessSet struct {
+	reads  map[accessKey]struct{}
+	writes map[accessKey]struct{}
+}
+
+// NewAccessSet creates an empty access set.
+func NewAccessSet() *AccessSet {
+	return &AccessSet{
+		reads:  make(map[accessKey]struct{}),
+		writes: make(map[accessKey]struct{}),
+	}
+}
+
+// Conflicts returns whether one of the sets writes an account or storage slot accessed by the
+// other.
+func (s *AccessSet) Conflicts(other *AccessSet) bool {
+	for key := range s.writes {
+		if _, ok := other.reads[key]; ok {
+			return true
+		}
+		if _, ok := other.writes[key]; ok {
+			return true
+		}
+	}
+	for key := range other.writes {
+		if _, ok := s.reads[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds the accesses of another set to the set.
+func (s *AccessSet) Add(other *AccessSet) {
+	for key := range other.reads {
+		s.reads[key] = struct{}{}
+	}
+	for key := range other.writes {
+		s.writes[key] = struct{}{}
+	}
+}
+
+// RecordAccesses starts recording the accounts and storage slots accessed through the state,
+// dropping the accesses recorded so far.
+func (self *StateDB) RecordAccesses() {
+	self.accesses = NewAccessSet()
+}
+
+// Accesses returns the accesses recorded since RecordAccesses, nil if they aren't recorded.
+func (self *StateDB) Accesses() *AccessSet {
+	return self.accesses
+}
+
+func (self *StateDB) recordRead(addr common.Address, slot *common.Hash) {
+	if self.accesses == nil {
+		return
+	}
+	if slot != nil {
+		self.accesses.reads[accessKey{addr: addr, slot: *slot, storage: true}] = struct{}{}
+	} else {
+		self.accesses.reads[accessKey{addr: addr}] = struct{}{}
+	}
+}
+
+func (self *StateDB) recordWrite(addr common.Address, slot *common.Hash) {
+	if self.accesses == nil {
+		return
+	}
+	if slot != nil {
+		self.accesses.writes[accessKey{addr: addr, slot: *slot, storage: true}] = struct{}{}
+	} else {
+		self.accesses.writes[accessKey{addr: addr}] = struct{}{}
+	}
+}
+
+// ApplyWrites copies into the state the accounts and storage slots written through a copy of it
+// since the copy started recording its accesses. The accounts deleted or suicided in the copy are
+// deleted or suicided in the state as well.
+func (self *StateDB) ApplyWrites(src *StateDB) {
+	if src.accesses == nil {
+		return
+	}
+	for key := range src.accesses.writes {
+		srcObject := src.stateObjects[key.addr]
+		if srcObject == nil {
+			continue
+		}
+		if srcObject.deleted {
+			self.DeleteAddress(key.addr)
+			continue
+		}
+		if srcObject.suicided {
+			self.Suicide(key.addr)
+			continue
+		}
+		if key.storage {
+			self.SetState(key.addr, key.slot, srcObject.GetState(src.db, key.slot))
+			continue
+		}
+		self.SetBalance(key.addr, srcObject.Balance())
+		self.SetNonce(key.addr, srcObject.Nonce())
+		if srcObject.dirtyCode {
+			self.SetCode(key.addr, srcObject.Code(src.db))
+		}
+	}
+}
@@ -80,6 +80,9 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// Accounts and storage slots accessed, only recorded after RecordAccesses.
+	accesses *AccessSet
+
 	lock sync.Mutex
 }
 
@@ -93,6 +96,7 @@ func (self *StateDB) SubRefund(gas uint64) {
 }
 
 func (self *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
+	self.recordRead(addr, &hash)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetCommittedState(self.db, hash)
@@ -253,6 +257,7 @@ func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 }
 
 func (self *StateDB) GetState(addr common.Address, bhash common.Hash) common.Hash {
+	self.recordRead(addr, &bhash)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetState(self.db, bhash)
@@ -290,6 +295,7 @@ func (self *StateDB) HasSuicided(addr common.Address) bool {
 
 // AddBalance adds amount to the account associated with addr.
 func (self *StateDB) AddBalance(addr common.Address, amount *big.Int) {
+	self.recordWrite(addr, nil)
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.AddBalance(amount)
@@ -298,6 +304,7 @@ func (self *StateDB) AddBalance(addr common.Address, amount *big.Int) {
 
 // SubBalance subtracts amount from the account associated with addr.
 func (self *StateDB) SubBalance(addr common.Address, amount *big.Int) {
+	self.recordWrite(addr, nil)
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SubBalance(amount)
@@ -305,6 +312,7 @@ func (self *StateDB) SubBalance(addr common.Address, amount *big.Int) {
 }
 
 func (self *StateDB) SetBalance(addr common.Address, amount *big.Int) {
+	self.recordWrite(addr, nil)
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetBalance(amount)
@@ -312,6 +320,7 @@ func (self *StateDB) SetBalance(addr common.Address, amount *big.Int) {
 }
 
 func (self *StateDB) SetNonce(addr common.Address, nonce uint64) {
+	self.recordWrite(addr, nil)
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetNonce(nonce)
@@ -319,6 +328,7 @@ func (self *StateDB) SetNonce(addr common.Address, nonce uint64) {
 }
 
 func (self *StateDB) SetCode(addr common.Address, code []byte) {
+	self.recordWrite(addr, nil)
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetCode(crypto.Keccak256Hash(code), code)
@@ -326,6 +336,7 @@ func (self *StateDB) SetCode(addr common.Address, code []byte) {
 }
 
 func (self *StateDB) SetState(addr common.Address, key, value common.Hash) {
+	self.recordWrite(addr, &key)
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetState(self.db, key, value)
@@ -338,6 +349,7 @@ func (self *StateDB) SetState(addr common.Address, key, value common.Hash) {
 // The account's state object is still available until the state is committed,
 // getStateObject will return a non-nil account after Suicide.
 func (self *StateDB) Suicide(addr common.Address) bool {
+	self.recordWrite(addr, nil)
 	stateObject := self.getStateObject(addr)
 	if stateObject == nil {
 		return false
@@ -376,6 +388,7 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 
 // DeleteAddress removes the address from the state trie.
 func (self *StateDB) DeleteAddress(addr common.Address) {
+	self.recordWrite(addr, nil)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil && !stateObject.deleted {
 		self.deleteStateObject(stateObject)
@@ -384,6 +397,7 @@ func (self *StateDB) DeleteAddress(addr common.Address) {
 
 // Retrieve a state object given my the address. Returns nil if not found.
 func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObject) {
+	self.recordRead(addr, nil)
 	// Prefer 'live' objects.
 	if obj := self.stateObjects[addr]; obj != nil {
 		if obj.deleted {
@@ -431,6 +445,7 @@ func (self *StateDB) MarkStateObjectDirty(addr common.Address) {
 // createObject creates a new state object. If there is an existing account with
 // the given address, it is overwritten and returned as the second return value.
 func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
+	self.recordWrite(addr, nil)
 	prev = self.getStateObject(addr)
 	newobj = newObject(self, addr, Account{}, self.MarkStateObjectDirty)
 	newobj.setNonce(0) // sets the object to dirty
@@ -427,3 +427,66 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+func TestApplyWrites(t *testing.T) {
+	var (
+		token      = common.HexToAddress("0x10")
+		alice, bob = common.HexToAddress("0x1"), common.HexToAddress("0x2")
+		slotA      = common.HexToHash("0xa")
+		slotB      = common.HexToHash("0xb")
+	)
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	state.SetState(token, slotA, common.HexToHash("0x1"))
+	state.AddBalance(alice, big.NewInt(1))
+
+	serial := state.Copy()
+	serial.SetState(token, slotA, common.HexToHash("0x2"))
+	serial.AddBalance(alice, big.NewInt(1))
+	serial.SetState(token, slotB, common.HexToHash("0x3"))
+	serial.AddBalance(bob, big.NewInt(5))
+	want := serial.IntermediateRoot(false)
+
+	copyA, copyB := state.Copy(), state.Copy()
+	copyA.RecordAccesses()
+	copyB.RecordAccesses()
+	copyA.SetState(token, slotA, common.HexToHash("0x2"))
+	copyA.AddBalance(alice, big.NewInt(1))
+	copyB.SetState(token, slotB, common.HexToHash("0x3"))
+	copyB.AddBalance(bob, big.NewInt(5))
+	if copyA.Accesses().Conflicts(copyB.Accesses()) {
+		t.Fatal("writes of distinct slots reported as conflicting")
+	}
+	state.ApplyWrites(copyA)
+	state.ApplyWrites(copyB)
+	if root := state.IntermediateRoot(false); root != want {
+		t.Fatalf("wrong merged root: have %x, want %x", root, want)
+	}
+
+	// reading a slot written by another copy conflicts
+	copyC := state.Copy()
+	copyC.RecordAccesses()
+	copyC.GetState(token, slotB)
+	if !copyC.Accesses().Conflicts(copyB.Accesses()) {
+		t.Fatal("read of a written slot not reported as conflicting")
+	}
+
+	// accounts suicided or deleted in a copy are removed from the merged state
+	serial = state.Copy()
+	serial.Suicide(alice)
+	serial.DeleteAddress(bob)
+	want = serial.IntermediateRoot(false)
+
+	copyD, copyE := state.Copy(), state.Copy()
+	copyD.RecordAccesses()
+	copyE.RecordAccesses()
+	copyD.Suicide(alice)
+	copyE.DeleteAddress(bob)
+	state.ApplyWrites(copyD)
+	state.ApplyWrites(copyE)
+	if root := state.IntermediateRoot(false); root != want {
+		t.Fatalf("wrong merged root after deletions: have %x, want %x", root, want)
+	}
+	if state.Exist(alice) || state.Exist(bob) {
+		t.Fatal("deleted accounts kept in the merged state")
+	}
+}
@@ -0,0 +1,332 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The block processing posts the orders and the lending items processed by each canonical block,
+// and the lending trades finalized by the liquidation blocks, on the TomoX event bus of the chain.
+// They are consumed by the SDK database and the indexes of the node (see tomoxSyncLoop), the RPC
+// subscriptions and the metrics. The events of a block follow the order of its transactions and
+// end with a TomoXBlockEvent. On a reorg, a TomoXTxRevertedEvent is posted for each transaction
+// removed from the canonical chain before the events of the new blocks.
+//
+// The events of the blocks imported while holding the chain lock are queued, and posted by
+// PostChainEvents once it is released (see postTomoXQueue). The subscribers receive the events in
+// the order they are posted, and a slow subscriber delays the caller importing the blocks, but not
+// the import of the other blocks: those which can't keep up must buffer or drop them. The caller
+// waits for the events of each block to be recorded by tomoxSyncLoop, as the SDK database and the
+// indexes follow the chain.
+
+// OrderMatchedEvent is posted for each order processed by the matching engine in a block, with the
+// trades it made and the orders rejected while matching it.
+type OrderMatchedEvent struct {
+	Block    *types.Block
+	TxHash   common.Hash
+	Order    *tradingstate.OrderItem
+	Trades   []map[string]string
+	Rejected []*tradingstate.OrderItem
+}
+
+// OrderRejectedEvent is posted for each order rejected by the matching engine in a block.
+type OrderRejectedEvent struct {
+	Block  *types.Block
+	TxHash common.Hash
+	Order  *tradingstate.OrderItem
+}
+
+// TradeSettledEvent is posted for each lending item processed in a block, with the lending trades
+// it opened or updated and the items rejected while processing it.
+type TradeSettledEvent struct {
+	Block    *types.Block
+	TxHash   common.Hash
+	Item     *lendingstate.LendingItem
+	Trades   []*lendingstate.LendingTrade
+	Rejected []*lendingstate.LendingItem
+}
+
+// TradesFinalizedEvent is posted for the lending trades finalized by the liquidation pass of a
+// liquidation block: liquidated, put in auction, repaid, topped up or recalled.
+type TradesFinalizedEvent struct {
+	Block  *types.Block
+	Result lendingstate.FinalizedResult
+	Trades map[common.Hash]*lendingstate.LendingTrade
+}
+
+// LiquidationStartedEvent is posted for each lending trade whose collateral is put in a
+// liquidation auction.
+type LiquidationStartedEvent struct {
+	Block  *types.Block
+	TxHash common.Hash
+	Trade  *lendingstate.LendingTrade
+}
+
+// LiquidationCompletedEvent is posted for each lending trade liquidated, by the liquidation pass
+// or by the last bid of its auction.
+type LiquidationCompletedEvent struct {
+	Block  *types.Block
+	TxHash common.Hash
+	Trade  *lendingstate.LendingTrade
+}
+
+// TomoXTxRevertedEvent is posted for each trading or lending transaction removed from the canonical
+// chain by a reorg.
+type TomoXTxRevertedEvent struct {
+	TxHash  common.Hash
+	Lending bool // lending or finalized trades transaction, trading transaction otherwise
+}
+
+// TomoXBlockEvent is posted once all the events of a block have been posted.
+type TomoXBlockEvent struct {
+	Block *types.Block
+	done  chan struct{} // closed once the block is recorded by tomoxSyncLoop
+}
+
+// TomoXEventBus holds the feeds of the TomoX events of a chain.
+type TomoXEventBus struct {
+	orderMatchedFeed         event.Feed
+	orderRejectedFeed        event.Feed
+	tradeSettledFeed         event.Feed
+	tradesFinalizedFeed      event.Feed
+	liquidationStartedFeed   event.Feed
+	liquidationCompletedFeed event.Feed
+	txRevertedFeed           event.Feed
+	blockFeed                event.Feed
+	scope                    event.SubscriptionScope
+
+	queueMu sync.Mutex  // protects queue
+	queue   []tomoxPost // posts queued while holding the chain lock
+	postMu  sync.Mutex  // serializes the posts of the queue
+}
+
+// tomoxPost is the TomoX events of a canonical block, or of the transactions removed from the
+// canonical chain by a reorg, queued by the chain import.
+type tomoxPost struct {
+	block    *types.Block       // canonical block whose events are posted, nil for a reorg
+	lending  bool               // whether the lending events of the block are posted
+	reverted types.Transactions // trading and lending transactions removed by a reorg
+}
+
+// SubscribeOrderMatched registers a subscription of OrderMatchedEvent.
+func (bus *TomoXEventBus) SubscribeOrderMatched(ch chan<- OrderMatchedEvent) event.Subscription {
+	return bus.scope.Track(bus.orderMatchedFeed.Subscribe(ch))
+}
+
+// SubscribeOrderRejected registers a subscription of OrderRejectedEvent.
+func (bus *TomoXEventBus) SubscribeOrderRejected(ch chan<- OrderRejectedEvent) event.Subscription {
+	return bus.scope.Track(bus.orderRejectedFeed.Subscribe(ch))
+}
+
+// SubscribeTradeSettled registers a subscription of TradeSettledEvent.
+func (bus *TomoXEventBus) SubscribeTradeSettled(ch chan<- TradeSettledEvent) event.Subscription {
+	return bus.scope.Track(bus.tradeSettledFeed.Subscribe(ch))
+}
+
+// SubscribeTradesFinalized registers a subscription of TradesFinalizedEvent.
+func (bus *TomoXEventBus) SubscribeTradesFinalized(ch chan<- TradesFinalizedEvent) event.Subscription {
+	return bus.scope.Track(bus.tradesFinalizedFeed.Subscribe(ch))
+}
+
+// SubscribeLiquidationStarted registers a subscription of LiquidationStartedEvent.
+func (bus *TomoXEventBus) SubscribeLiquidationStarted(ch chan<- LiquidationStartedEvent) event.Subscription {
+	return bus.scope.Track(bus.liquidationStartedFeed.Subscribe(ch))
+}
+
+// SubscribeLiquidationCompleted registers a subscription of LiquidationCompletedEvent.
+func (bus *TomoXEventBus) SubscribeLiquidationCompleted(ch chan<- LiquidationCompletedEvent) event.Subscription {
+	return bus.scope.Track(bus.liquidationCompletedFeed.Subscribe(ch))
+}
+
+// SubscribeTxReverted registers a subscription of TomoXTxRevertedEvent.
+func (bus *TomoXEventBus) SubscribeTxReverted(ch chan<- TomoXTxRevertedEvent) event.Subscription {
+	return bus.scope.Track(bus.txRevertedFeed.Subscribe(ch))
+}
+
+// SubscribeBlock registers a subscription of TomoXBlockEvent.
+func (bus *TomoXEventBus) SubscribeBlock(ch chan<- TomoXBlockEvent) event.Subscription {
+	return bus.scope.Track(bus.blockFeed.Subscribe(ch))
+}
+
+// TomoXEvents returns the TomoX event bus of the chain.
+func (bc *BlockChain) TomoXEvents() *TomoXEventBus {
+	return &bc.tomoxEvents
+}
+
+// queueTomoXEvents queues the TomoX events of a canonical block, to be posted once the chain lock
+// is released.
+func (bc *BlockChain) queueTomoXEvents(block *types.Block) {
+	tomoXService, lendingService := bc.tomoxServices()
+	if tomoXService == nil {
+		return
+	}
+	bc.tomoxEvents.push(tomoxPost{block: block, lending: lendingService != nil})
+}
+
+// queueTxReverted queues the trading and lending transactions removed from the canonical chain by
+// a reorg, to be posted once the chain lock is released.
+func (bc *BlockChain) queueTxReverted(deletedTxs types.Transactions) {
+	if tomoXService, _ := bc.tomoxServices(); tomoXService == nil || len(deletedTxs) == 0 {
+		return
+	}
+	bc.tomoxEvents.push(tomoxPost{reverted: deletedTxs})
+}
+
+func (bus *TomoXEventBus) push(post tomoxPost) {
+	bus.queueMu.Lock()
+	defer bus.queueMu.Unlock()
+
+	bus.queue = append(bus.queue, post)
+}
+
+// postTomoXQueue posts the TomoX events queued by the chain import, in the order they were queued.
+// It must not be called while holding the chain lock.
+func (bc *BlockChain) postTomoXQueue() {
+	bus := &bc.tomoxEvents
+	bus.queueMu.Lock()
+	empty := len(bus.queue) == 0
+	bus.queueMu.Unlock()
+	if empty {
+		return
+	}
+	bus.postMu.Lock()
+	defer bus.postMu.Unlock()
+
+	bus.queueMu.Lock()
+	posts := bus.queue
+	bus.queue = nil
+	bus.queueMu.Unlock()
+
+	for _, post := range posts {
+		if post.block == nil {
+			bc.postTxReverted(post.reverted)
+			continue
+		}
+		bc.postTomoXEvents(post.block, post.lending)
+	}
+}
+
+// postTomoXEvents posts the TomoX events of a canonical block and waits for tomoxSyncLoop to record
+// the block.
+func (bc *BlockChain) postTomoXEvents(block *types.Block, lending bool) {
+	bc.postTradingEvents(block)
+	if lending {
+		bc.postLendingEvents(block)
+	}
+	done := make(chan struct{})
+	if bc.tomoxEvents.blockFeed.Send(TomoXBlockEvent{Block: block, done: done}) == 0 {
+		return
+	}
+	select {
+	case <-done:
+	case <-bc.quit:
+	}
+}
+
+// postTradingEvents posts the orders processed by a block, from the matching results cached when
+// the block was processed.
+func (bc *BlockChain) postTradingEvents(block *types.Block) {
+	bus := &bc.tomoxEvents
+	txMatchBatchData, err := ExtractTradingTransactions(block.Transactions())
+	if err != nil {
+		log.Crit("failed to extract matching transaction", "err", err)
+		return
+	}
+	for _, txMatchBatch := range txMatchBatchData {
+		for _, txMatch := range txMatchBatch.Data {
+			takerOrderInTx, err := txMatch.DecodeOrder()
+			if err != nil {
+				log.Crit("SDK node decode takerOrderInTx failed", "txDataMatch", txMatch)
+				return
+			}
+			ev := OrderMatchedEvent{Block: block, TxHash: txMatchBatch.TxHash, Order: takerOrderInTx}
+			cacheKey := crypto.Keccak256Hash(txMatchBatch.TxHash.Bytes(), tradingstate.GetMatchingResultCacheKey(takerOrderInTx).Bytes())
+			if resultTrades, ok := bc.resultTrade.Get(cacheKey); ok && resultTrades != nil {
+				ev.Trades = resultTrades.([]map[string]string)
+			}
+			if rejected, ok := bc.rejectedOrders.Get(cacheKey); ok && rejected != nil {
+				ev.Rejected = rejected.([]*tradingstate.OrderItem)
+			}
+			bus.orderMatchedFeed.Send(ev)
+			for _, order := range ev.Rejected {
+				bus.orderRejectedFeed.Send(OrderRejectedEvent{Block: block, TxHash: txMatchBatch.TxHash, Order: order})
+			}
+		}
+	}
+}
+
+// postLendingEvents posts the lending items processed by a block and the lending trades finalized
+// by a liquidation block, from the results cached when the block was processed.
+func (bc *BlockChain) postLendingEvents(block *types.Block) {
+	bus := &bc.tomoxEvents
+	batches, err := ExtractLendingTransactions(block.Transactions())
+	if err != nil {
+		log.Crit("failed to extract lending transaction", "err", err)
+	}
+	for _, batch := range batches {
+		for _, item := range batch.Data {
+			ev := TradeSettledEvent{Block: block, TxHash: batch.TxHash, Item: item}
+			cacheKey := crypto.Keccak256Hash(batch.TxHash.Bytes(), lendingstate.GetLendingCacheKey(item).Bytes())
+			if resultLendingTrades, ok := bc.resultLendingTrade.Get(cacheKey); ok && resultLendingTrades != nil {
+				ev.Trades = resultLendingTrades.([]*lendingstate.LendingTrade)
+			}
+			if rejected, ok := bc.rejectedLendingItem.Get(cacheKey); ok && rejected != nil {
+				ev.Rejected = rejected.([]*lendingstate.LendingItem)
+			}
+			bus.tradeSettledFeed.Send(ev)
+			for _, trade := range ev.Trades {
+				if trade.Status == lendingstate.TradeStatusLiquidated {
+					bus.liquidationCompletedFeed.Send(LiquidationCompletedEvent{Block: block, TxHash: batch.TxHash, Trade: trade})
+				}
+			}
+		}
+	}
+	if block.Number().Uint64()%bc.chainConfig.Posv.Epoch != common.LiquidateLendingTradeBlock {
+		return
+	}
+	finalizedTx, err := ExtractLendingFinalizedTradeTransactions(block.Transactions())
+	if err != nil {
+		log.Crit("failed to extract finalizedTrades transaction", "err", err)
+	}
+	finalizedTrades := map[common.Hash]*lendingstate.LendingTrade{}
+	if finalizedData, ok := bc.finalizedTrade.Get(finalizedTx.TxHash); ok && finalizedData != nil {
+		finalizedTrades = finalizedData.(map[common.Hash]*lendingstate.LendingTrade)
+	}
+	if len(finalizedTrades) == 0 {
+		return
+	}
+	bus.tradesFinalizedFeed.Send(TradesFinalizedEvent{Block: block, Result: finalizedTx, Trades: finalizedTrades})
+	// the trades are posted in the order of the finalized result
+	for _, hash := range finalizedTx.Liquidated {
+		trade, ok := finalizedTrades[hash]
+		if !ok {
+			continue
+		}
+		switch trade.Status {
+		case lendingstate.TradeStatusAuction:
+			bus.liquidationStartedFeed.Send(LiquidationStartedEvent{Block: block, TxHash: finalizedTx.TxHash, Trade: trade})
+		case lendingstate.TradeStatusLiquidated:
+			bus.liquidationCompletedFeed.Send(LiquidationCompletedEvent{Block: block, TxHash: finalizedTx.TxHash, Trade: trade})
+		}
+	}
+}
+
+// postTxReverted posts the trading and lending transactions removed from the canonical chain by
+// a reorg.
+func (bc *BlockChain) postTxReverted(deletedTxs types.Transactions) {
+	for _, deletedTx := range deletedTxs {
+		if deletedTx.IsTradingTransaction() {
+			bc.tomoxEvents.txRevertedFeed.Send(TomoXTxRevertedEvent{TxHash: deletedTx.Hash()})
+		}
+		if deletedTx.IsLendingTransaction() || deletedTx.IsLendingFinalizedTradeTransaction() {
+			bc.tomoxEvents.txRevertedFeed.Send(TomoXTxRevertedEvent{TxHash: deletedTx.Hash(), Lending: true})
+		}
+	}
+}
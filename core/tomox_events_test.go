@@ -0,0 +1,215 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func newTomoXEventsChain(t *testing.T) *BlockChain {
+	config := *params.TestChainConfig
+	config.Posv = &params.PosvConfig{Epoch: 900}
+	db := rawdb.NewMemoryDatabase()
+	(&Genesis{Config: &config}).MustCommit(db)
+	chain, err := NewBlockChain(db, nil, &config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return chain
+}
+
+func tomoxEventsTx(t *testing.T, nonce uint64, to string, data []byte, err error) *types.Transaction {
+	if err != nil {
+		t.Fatalf("failed to encode transaction data: %v", err)
+	}
+	return types.NewTransaction(nonce, common.HexToAddress(to), common.Big0, 0, common.Big0, data)
+}
+
+func TestTomoXEvents(t *testing.T) {
+	chain := newTomoXEventsChain(t)
+	defer chain.Stop()
+
+	var (
+		user    = common.HexToAddress("0x01")
+		order   = &tradingstate.OrderItem{UserAddress: user, Nonce: big.NewInt(1), Status: tradingstate.OrderStatusFilled, Signature: &tradingstate.Signature{}}
+		maker   = &tradingstate.OrderItem{UserAddress: user, Nonce: big.NewInt(2), Status: tradingstate.OrderStatusRejected}
+		item    = &lendingstate.LendingItem{UserAddress: user, Nonce: big.NewInt(3)}
+		bid     = &lendingstate.LendingTrade{Hash: common.HexToHash("0x0a"), Status: lendingstate.TradeStatusLiquidated}
+		auction = &lendingstate.LendingTrade{Hash: common.HexToHash("0x0b"), Status: lendingstate.TradeStatusAuction}
+		closed  = &lendingstate.LendingTrade{Hash: common.HexToHash("0x0c"), Status: lendingstate.TradeStatusLiquidated}
+		repaid  = &lendingstate.LendingTrade{Hash: common.HexToHash("0x0d"), Status: lendingstate.TradeStatusClosed}
+		trade   = map[string]string{tradingstate.TradeQuantity: "1"}
+		txs     []*types.Transaction
+	)
+	encoded, err := tradingstate.EncodeBytesItem(order)
+	if err != nil {
+		t.Fatalf("failed to encode order: %v", err)
+	}
+	data, err := tradingstate.EncodeTxMatchesBatch(tradingstate.TxMatchBatch{Data: []tradingstate.TxDataMatch{{Order: encoded}}})
+	txs = append(txs, tomoxEventsTx(t, 0, common.TomoXAddr, data, err))
+	data, err = lendingstate.EncodeTxLendingBatch(lendingstate.TxLendingBatch{Data: []*lendingstate.LendingItem{item}})
+	txs = append(txs, tomoxEventsTx(t, 1, common.TomoXLendingAddress, data, err))
+	data, err = lendingstate.EncodeFinalizedResult([]*lendingstate.LendingTrade{auction, closed}, []*lendingstate.LendingTrade{repaid}, nil, nil)
+	txs = append(txs, tomoxEventsTx(t, 2, common.TomoXLendingFinalizedTradeAddress, data, err))
+
+	block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(common.LiquidateLendingTradeBlock)}, txs, nil, nil)
+	chain.AddMatchingResult(txs[0].Hash(), map[common.Hash]tradingstate.MatchingResult{
+		tradingstate.GetMatchingResultCacheKey(order): {Trades: []map[string]string{trade}, Rejects: []*tradingstate.OrderItem{maker}},
+	})
+	chain.AddLendingResult(txs[1].Hash(), map[common.Hash]lendingstate.MatchingResult{
+		lendingstate.GetLendingCacheKey(item): {Trades: []*lendingstate.LendingTrade{bid}},
+	})
+	chain.AddFinalizedTrades(txs[2].Hash(), map[common.Hash]*lendingstate.LendingTrade{auction.Hash: auction, closed.Hash: closed, repaid.Hash: repaid})
+
+	var (
+		bus         = chain.TomoXEvents()
+		matchedCh   = make(chan OrderMatchedEvent, 4)
+		rejectedCh  = make(chan OrderRejectedEvent, 4)
+		settledCh   = make(chan TradeSettledEvent, 4)
+		finalizedCh = make(chan TradesFinalizedEvent, 4)
+		startedCh   = make(chan LiquidationStartedEvent, 4)
+		completedCh = make(chan LiquidationCompletedEvent, 4)
+	)
+	defer bus.SubscribeOrderMatched(matchedCh).Unsubscribe()
+	defer bus.SubscribeOrderRejected(rejectedCh).Unsubscribe()
+	defer bus.SubscribeTradeSettled(settledCh).Unsubscribe()
+	defer bus.SubscribeTradesFinalized(finalizedCh).Unsubscribe()
+	defer bus.SubscribeLiquidationStarted(startedCh).Unsubscribe()
+	defer bus.SubscribeLiquidationCompleted(completedCh).Unsubscribe()
+
+	chain.postTradingEvents(block)
+	chain.postLendingEvents(block)
+
+	if len(matchedCh) != 1 {
+		t.Fatalf("matched events mismatch: have %d, want 1", len(matchedCh))
+	}
+	if matched := <-matchedCh; matched.TxHash != txs[0].Hash() || matched.Order.Hash != order.Hash || len(matched.Trades) != 1 || len(matched.Rejected) != 1 {
+		t.Errorf("matched event mismatch: %+v", matched)
+	}
+	if len(rejectedCh) != 1 {
+		t.Fatalf("rejected events mismatch: have %d, want 1", len(rejectedCh))
+	}
+	if rejected := <-rejectedCh; rejected.Order != maker {
+		t.Errorf("rejected order mismatch: have %+v, want %+v", rejected.Order, maker)
+	}
+	if len(settledCh) != 1 {
+		t.Fatalf("settled events mismatch: have %d, want 1", len(settledCh))
+	}
+	if settled := <-settledCh; settled.TxHash != txs[1].Hash() || len(settled.Trades) != 1 || settled.Trades[0] != bid {
+		t.Errorf("settled event mismatch: %+v", settled)
+	}
+	if len(finalizedCh) != 1 {
+		t.Fatalf("finalized events mismatch: have %d, want 1", len(finalizedCh))
+	}
+	if finalized := <-finalizedCh; finalized.Result.TxHash != txs[2].Hash() || len(finalized.Trades) != 3 {
+		t.Errorf("finalized event mismatch: %+v", finalized)
+	}
+	if len(startedCh) != 1 {
+		t.Fatalf("started liquidations mismatch: have %d, want 1", len(startedCh))
+	}
+	if started := <-startedCh; started.Trade != auction {
+		t.Errorf("started liquidation mismatch: have %x, want %x", started.Trade.Hash, auction.Hash)
+	}
+	// the liquidation by the last auction bid comes before the liquidation pass
+	for i, want := range []*lendingstate.LendingTrade{bid, closed} {
+		if len(completedCh) == 0 {
+			t.Fatalf("completed liquidation %d missing", i)
+		}
+		if completed := <-completedCh; completed.Trade != want {
+			t.Errorf("completed liquidation %d mismatch: have %x, want %x", i, completed.Trade.Hash, want.Hash)
+		}
+	}
+	if len(completedCh) != 0 {
+		t.Errorf("unexpected completed liquidations: %d", len(completedCh))
+	}
+}
+
+// Tests that a TomoX event subscriber which never reads its events doesn't block the chain import.
+func TestTomoXEventsSlowSubscriber(t *testing.T) {
+	db, chain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	order := &tradingstate.OrderItem{UserAddress: common.HexToAddress("0x01"), Nonce: big.NewInt(1), Signature: &tradingstate.Signature{}}
+	encoded, err := tradingstate.EncodeBytesItem(order)
+	if err != nil {
+		t.Fatalf("failed to encode order: %v", err)
+	}
+	data, err := tradingstate.EncodeTxMatchesBatch(tradingstate.TxMatchBatch{Data: []tradingstate.TxDataMatch{{Order: encoded}}})
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, []*types.Transaction{tomoxEventsTx(t, 0, common.TomoXAddr, data, err)}, nil, nil)
+
+	stuck := make(chan OrderMatchedEvent)
+	sub := chain.TomoXEvents().SubscribeOrderMatched(stuck)
+	defer sub.Unsubscribe()
+
+	// queue the events of a block as insertChain does, and post them to the stuck subscriber
+	chain.chainmu.Lock()
+	chain.tomoxEvents.push(tomoxPost{block: block})
+	chain.chainmu.Unlock()
+	posted := make(chan struct{})
+	go func() {
+		chain.PostChainEvents(nil, nil)
+		close(posted)
+	}()
+	for {
+		chain.tomoxEvents.queueMu.Lock()
+		taken := len(chain.tomoxEvents.queue) == 0
+		chain.tomoxEvents.queueMu.Unlock()
+		if taken {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// the chain is imported while the events are blocked
+	inserted := make(chan error, 1)
+	go func() {
+		_, err := chain.InsertChain(makeBlockChain(chain.CurrentBlock(), 3, ethash.NewFaker(), db, 0))
+		inserted <- err
+	}()
+	select {
+	case err := <-inserted:
+		if err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("chain import blocked by a TomoX event subscriber")
+	}
+	select {
+	case <-posted:
+		t.Fatalf("events posted to the stuck subscriber")
+	default:
+	}
+	// the events are posted once the subscriber leaves
+	sub.Unsubscribe()
+	select {
+	case <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("events not posted after unsubscribing")
+	}
+}
+
+func TestTomoXSyncDirtyOrders(t *testing.T) {
+	var (
+		pending = &tomoxSync{}
+		first   = common.HexToHash("0x01")
+		second  = common.HexToHash("0x02")
+	)
+	*pending.dirtyOrders(first) += 2
+	if count := *pending.dirtyOrders(first); count != 2 {
+		t.Errorf("dirty orders of the same transaction mismatch: have %d, want 2", count)
+	}
+	if count := *pending.dirtyOrders(second); count != 0 {
+		t.Errorf("dirty orders of a new transaction mismatch: have %d, want 0", count)
+	}
+}
@@ -0,0 +1,283 @@
+package core
+
+import (
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+var (
+	tomoxOrderMatchedMeter         = metrics.NewRegisteredMeter("tomox/orders/matched", nil)
+	tomoxOrderRejectedMeter        = metrics.NewRegisteredMeter("tomox/orders/rejected", nil)
+	tomoxTradeMeter                = metrics.NewRegisteredMeter("tomox/trades", nil)
+	tomoxLendingSettledMeter       = metrics.NewRegisteredMeter("tomox/lending/settled", nil)
+	tomoxLendingTradeMeter         = metrics.NewRegisteredMeter("tomox/lending/trades", nil)
+	tomoxLiquidationStartedMeter   = metrics.NewRegisteredMeter("tomox/lending/liquidations/started", nil)
+	tomoxLiquidationCompletedMeter = metrics.NewRegisteredMeter("tomox/lending/liquidations/completed", nil)
+	tomoxTxRevertedMeter           = metrics.NewRegisteredMeter("tomox/txs/reverted", nil)
+)
+
+// tomoxSync holds the data of the block being recorded by tomoxSyncLoop.
+type tomoxSync struct {
+	txHash          common.Hash // transaction of the last event
+	dirtyOrderCount uint64      // orders of the transaction written to the SDK database
+
+	blockTrades     []map[string]string
+	lendingLogs     []*types.Log
+	settledItems    []*lendingstate.LendingItem
+	settledTrades   [][]*lendingstate.LendingTrade
+	settledRejected [][]*lendingstate.LendingItem
+	finalizedTrades map[common.Hash]*lendingstate.LendingTrade
+	journaledTxs    []common.Hash
+}
+
+// dirtyOrders returns the count of orders of a transaction written to the SDK database, starting a
+// new count with each transaction.
+func (s *tomoxSync) dirtyOrders(txHash common.Hash) *uint64 {
+	if s.txHash != txHash {
+		s.txHash, s.dirtyOrderCount = txHash, 0
+	}
+	return &s.dirtyOrderCount
+}
+
+// tomoxSyncer holds the subscriptions of tomoxSyncLoop to the TomoX events of the chain.
+type tomoxSyncer struct {
+	matchedCh   chan OrderMatchedEvent
+	settledCh   chan TradeSettledEvent
+	finalizedCh chan TradesFinalizedEvent
+	startedCh   chan LiquidationStartedEvent
+	completedCh chan LiquidationCompletedEvent
+	revertedCh  chan TomoXTxRevertedEvent
+	blockCh     chan TomoXBlockEvent
+	subs        []event.Subscription
+}
+
+// newTomoXSyncer subscribes to the TomoX events of the chain, before tomoxSyncLoop is started so
+// that no event is missed.
+func (bc *BlockChain) newTomoXSyncer() *tomoxSyncer {
+	bus := &bc.tomoxEvents
+	s := &tomoxSyncer{
+		matchedCh:   make(chan OrderMatchedEvent),
+		settledCh:   make(chan TradeSettledEvent),
+		finalizedCh: make(chan TradesFinalizedEvent),
+		startedCh:   make(chan LiquidationStartedEvent),
+		completedCh: make(chan LiquidationCompletedEvent),
+		revertedCh:  make(chan TomoXTxRevertedEvent),
+		blockCh:     make(chan TomoXBlockEvent),
+	}
+	s.subs = []event.Subscription{
+		bus.SubscribeOrderMatched(s.matchedCh),
+		bus.SubscribeTradeSettled(s.settledCh),
+		bus.SubscribeTradesFinalized(s.finalizedCh),
+		bus.SubscribeLiquidationStarted(s.startedCh),
+		bus.SubscribeLiquidationCompleted(s.completedCh),
+		bus.SubscribeTxReverted(s.revertedCh),
+		bus.SubscribeBlock(s.blockCh),
+	}
+	return s
+}
+
+// tomoxSyncLoop records the TomoX events of the chain in the SDK database of a SDK node, or in the
+// candle and lending indexes of the other nodes, and counts them in the metrics.
+func (bc *BlockChain) tomoxSyncLoop(s *tomoxSyncer) {
+	defer bc.wg.Done()
+	defer func() {
+		for _, sub := range s.subs {
+			sub.Unsubscribe()
+		}
+	}()
+
+	pending := &tomoxSync{}
+	for {
+		select {
+		case ev := <-s.matchedCh:
+			tomoxOrderMatchedMeter.Mark(1)
+			tomoxOrderRejectedMeter.Mark(int64(len(ev.Rejected)))
+			tomoxTradeMeter.Mark(int64(len(ev.Trades)))
+			bc.syncOrderMatched(pending, ev)
+		case ev := <-s.settledCh:
+			tomoxLendingSettledMeter.Mark(1)
+			tomoxLendingTradeMeter.Mark(int64(len(ev.Trades)))
+			bc.syncTradeSettled(pending, ev)
+		case ev := <-s.finalizedCh:
+			bc.syncTradesFinalized(pending, ev)
+		case <-s.startedCh:
+			tomoxLiquidationStartedMeter.Mark(1)
+		case <-s.completedCh:
+			tomoxLiquidationCompletedMeter.Mark(1)
+		case ev := <-s.revertedCh:
+			tomoxTxRevertedMeter.Mark(1)
+			bc.syncTxReverted(ev)
+		case ev := <-s.blockCh:
+			bc.syncBlock(pending, ev.Block)
+			pending = &tomoxSync{}
+			close(ev.done)
+
+		case <-s.subs[0].Err():
+			return
+		}
+	}
+}
+
+// tomoxServices returns the trading and lending services of the chain, nil if it has none.
+func (bc *BlockChain) tomoxServices() (posv.TradingService, posv.LendingService) {
+	engine, ok := bc.Engine().(*posv.Posv)
+	if !ok || engine == nil || engine.GetTomoXService == nil {
+		return nil, nil
+	}
+	tomoXService := engine.GetTomoXService()
+	if tomoXService == nil || engine.GetLendingService == nil {
+		return tomoXService, nil
+	}
+	return tomoXService, engine.GetLendingService()
+}
+
+// syncOrderMatched writes an order processed by the matching engine and its trades to the SDK
+// database, and keeps its trades for the candles of the block.
+func (bc *BlockChain) syncOrderMatched(pending *tomoxSync, ev OrderMatchedEvent) {
+	tomoXService, _ := bc.tomoxServices()
+	if tomoXService == nil || (!tomoXService.IsSDKNode() && !tomoXService.HasCandleIndex()) {
+		return
+	}
+	pending.blockTrades = append(pending.blockTrades, ev.Trades...)
+	if !tomoXService.IsSDKNode() {
+		return
+	}
+	// the head may have moved past the block of the event, record the state of the block itself
+	blockState, err := bc.StateAt(ev.Block.Root())
+	if err != nil {
+		log.Crit("tomox: failed to get block state", "blockNumber", ev.Block.Number(), "root", ev.Block.Root(), "err", err)
+		return
+	}
+	txMatchTime := time.Unix(ev.Block.Header().Time.Int64(), 0).UTC()
+	if err := tomoXService.SyncDataToSDKNode(ev.Order, ev.TxHash, txMatchTime, blockState, ev.Trades, ev.Rejected, pending.dirtyOrders(ev.TxHash)); err != nil {
+		log.Crit("failed to SyncDataToSDKNode ", "blockNumber", ev.Block.Number(), "err", err)
+	}
+}
+
+// syncTradeSettled writes a lending item processed in a block and its trades to the SDK database,
+// or indexes them on the other nodes.
+func (bc *BlockChain) syncTradeSettled(pending *tomoxSync, ev TradeSettledEvent) {
+	tomoXService, lendingService := bc.tomoxServices()
+	if tomoXService == nil || lendingService == nil {
+		return
+	}
+	sdkNode := tomoXService.IsSDKNode()
+	if !sdkNode && !lendingService.HasLendingIndex() {
+		return
+	}
+	txMatchTime := time.Unix(ev.Block.Header().Time.Int64(), 0).UTC()
+	if !sdkNode {
+		pending.lendingLogs = append(pending.lendingLogs, lendingstate.LendingItemLogs(ev.TxHash, ev.Item, ev.Trades)...)
+		pending.settledItems = append(pending.settledItems, ev.Item)
+		pending.settledTrades = append(pending.settledTrades, ev.Trades)
+		pending.settledRejected = append(pending.settledRejected, ev.Rejected)
+		if err := lendingService.IndexLendingData(ev.Item, ev.TxHash, txMatchTime, ev.Trades, ev.Rejected); err != nil {
+			log.Error("lending: failed to index lending data", "blockNumber", ev.Block.Number(), "err", err)
+		}
+		return
+	}
+	if len(pending.journaledTxs) == 0 || pending.journaledTxs[len(pending.journaledTxs)-1] != ev.TxHash {
+		pending.journaledTxs = append(pending.journaledTxs, ev.TxHash)
+	}
+	statedb, err := bc.StateAt(ev.Block.Root())
+	if err != nil {
+		log.Crit("lending: failed to get block state", "blockNumber", ev.Block.Number(), "root", ev.Block.Root(), "err", err)
+		return
+	}
+	if err := lendingService.SyncDataToSDKNode(bc, statedb, ev.Block, ev.Item, ev.TxHash, txMatchTime, ev.Trades, ev.Rejected, pending.dirtyOrders(ev.TxHash)); err != nil {
+		if lendingstate.IsQueuedSDKSyncError(err) {
+			log.Error("lending: SyncDataToSDKNode failed, data queued for replay", "blockNumber", ev.Block.Number(), "err", err)
+			return
+		}
+		log.Crit("lending: failed to SyncDataToSDKNode ", "blockNumber", ev.Block.Number(), "err", err)
+	}
+}
+
+// syncTradesFinalized writes the lending trades finalized by a liquidation block to the SDK
+// database, or indexes them on the other nodes.
+func (bc *BlockChain) syncTradesFinalized(pending *tomoxSync, ev TradesFinalizedEvent) {
+	tomoXService, lendingService := bc.tomoxServices()
+	if tomoXService == nil || lendingService == nil {
+		return
+	}
+	if tomoXService.IsSDKNode() {
+		pending.journaledTxs = append(pending.journaledTxs, ev.Result.TxHash)
+		if err := lendingService.UpdateLiquidatedTrade(ev.Block.Time().Uint64(), ev.Result, ev.Trades); err != nil {
+			log.Crit("lending: failed to UpdateLiquidatedTrade ", "blockNumber", ev.Block.Number(), "err", err)
+		}
+		return
+	}
+	if !lendingService.HasLendingIndex() {
+		return
+	}
+	pending.finalizedTrades = ev.Trades
+	if err := lendingService.IndexLiquidatedTrades(ev.Block.Time().Uint64(), ev.Result, ev.Trades); err != nil {
+		log.Error("lending: failed to index liquidated trades", "blockNumber", ev.Block.Number(), "err", err)
+	}
+	pending.lendingLogs = append(pending.lendingLogs, lendingstate.FinalizedTradeLogs(ev.Result, ev.Trades)...)
+}
+
+// syncTxReverted rolls back the data of a transaction removed from the canonical chain from the
+// SDK database.
+func (bc *BlockChain) syncTxReverted(ev TomoXTxRevertedEvent) {
+	tomoXService, lendingService := bc.tomoxServices()
+	if tomoXService == nil || !tomoXService.IsSDKNode() {
+		return
+	}
+	if !ev.Lending {
+		log.Debug("Rollback reorg txMatch", "txhash", ev.TxHash)
+		if err := tomoXService.RollbackReorgTxMatch(ev.TxHash); err != nil {
+			log.Crit("Reorg trading failed", "err", err, "hash", ev.TxHash)
+		}
+		return
+	}
+	if lendingService != nil {
+		log.Debug("Rollback reorg lendingItem", "txhash", ev.TxHash)
+		if err := lendingService.RollbackLendingData(ev.TxHash); err != nil {
+			log.Crit("Reorg lending failed", "err", err, "hash", ev.TxHash)
+		}
+	}
+}
+
+// syncBlock records the candles of a block, and journals its lending data in the SDK database or
+// indexes its lending logs, epoch report and state diff on the other nodes.
+func (bc *BlockChain) syncBlock(pending *tomoxSync, block *types.Block) {
+	tomoXService, lendingService := bc.tomoxServices()
+	if tomoXService == nil {
+		return
+	}
+	sdkNode := tomoXService.IsSDKNode()
+	if sdkNode || tomoXService.HasCandleIndex() {
+		if err := tomoXService.IndexCandles(block, pending.blockTrades); err != nil {
+			log.Error("failed to index candles", "blockNumber", block.Number(), "err", err)
+		}
+	}
+	if lendingService == nil {
+		return
+	}
+	if sdkNode {
+		if err := lendingService.JournalLendingData(block, pending.journaledTxs); err != nil {
+			log.Error("lending: failed to journal SDK lending data", "blockNumber", block.Number(), "err", err)
+		}
+		return
+	}
+	if !lendingService.HasLendingIndex() {
+		return
+	}
+	if err := lendingService.IndexLendingLogs(block, pending.lendingLogs); err != nil {
+		log.Error("lending: failed to index lending logs", "blockNumber", block.Number(), "err", err)
+	}
+	if err := lendingService.IndexEpochReport(block, pending.settledItems, pending.settledTrades, pending.finalizedTrades); err != nil {
+		log.Error("lending: failed to index epoch report", "blockNumber", block.Number(), "err", err)
+	}
+	if err := lendingService.IndexStateDiff(block, pending.settledItems, pending.settledTrades, pending.settledRejected, pending.finalizedTrades); err != nil {
+		log.Error("lending: failed to index state diff", "blockNumber", block.Number(), "err", err)
+	}
+}
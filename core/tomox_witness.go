@@ -0,0 +1,38 @@
+package core
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+var tomoxStatelessBlockMeter = metrics.NewRegisteredMeter("tomox/blocks/stateless", nil)
+
+// parentTomoXStates returns the trading and lending states of the parent of a block, which the
+// orders of the block are processed against. A node not holding them verifies the block
+// statelessly, on the states opened on the witness of the block supplied by its proposer, and
+// fails as before if there is none.
+func parentTomoXStates(block, parent *types.Block, parentAuthor common.Address, tradingService posv.TradingService, lendingService posv.LendingService) (*tradingstate.TradingStateDB, *lendingstate.LendingStateDB, error) {
+	tradingState, err := tradingService.GetTradingState(parent, parentAuthor)
+	if err == nil {
+		var lendingState *lendingstate.LendingStateDB
+		if lendingState, err = lendingService.GetLendingState(parent, parentAuthor); err == nil {
+			return tradingState, lendingState, nil
+		}
+	}
+	tradingState, werr := tradingService.GetTradingStateFromWitness(parent, parentAuthor, block.Hash())
+	if werr != nil {
+		return nil, nil, err
+	}
+	lendingState, werr := lendingService.GetLendingStateFromWitness(parent, parentAuthor, block.Hash())
+	if werr != nil {
+		return nil, nil, err
+	}
+	log.Debug("Verifying block statelessly", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+	tomoxStatelessBlockMeter.Mark(1)
+	return tradingState, lendingState, nil
+}
@@ -0,0 +1,226 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/math"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Since TIPTomoXLendingEIP712 a lending transaction can also be signed as EIP-712 typed data, so
+// that hardware wallets and browser wallets show the fields of the lending item being signed
+// instead of an opaque hash. The signed digest is
+//
+//	keccak256("\x19\x01" || domainSeparator || hashStruct(LendingItem))
+//
+// with the domain EIP712Domain(string name,string version,uint256 chainId). All the statuses and
+// types of lending items share the LendingItem struct below; the legacy signatures over the personal
+// message of LendingTxSigner.Hash remain valid.
+const (
+	LendingEIP712Name    = "TomoX Lending"
+	LendingEIP712Version = "1"
+)
+
+// EIP712Field is a member of an EIP-712 struct type.
+type EIP712Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+var (
+	lendingEIP712Domain = []EIP712Field{
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+	}
+	lendingEIP712Item = []EIP712Field{
+		{Name: "nonce", Type: "uint256"},
+		{Name: "quantity", Type: "uint256"},
+		{Name: "interest", Type: "uint256"},
+		{Name: "term", Type: "uint256"},
+		{Name: "relayer", Type: "address"},
+		{Name: "user", Type: "address"},
+		{Name: "lendingToken", Type: "address"},
+		{Name: "collateralToken", Type: "address"},
+		{Name: "autoTopUp", Type: "bool"},
+		{Name: "status", Type: "string"},
+		{Name: "side", Type: "string"},
+		{Name: "type", Type: "string"},
+		{Name: "hash", Type: "bytes32"},
+		{Name: "lendingId", Type: "uint256"},
+		{Name: "tradeId", Type: "uint256"},
+		{Name: "extraData", Type: "string"},
+	}
+	lendingEIP712DomainTypeHash = crypto.Keccak256Hash([]byte(eip712TypeString("EIP712Domain", lendingEIP712Domain)))
+	lendingEIP712ItemTypeHash   = crypto.Keccak256Hash([]byte(eip712TypeString("LendingItem", lendingEIP712Item)))
+)
+
+func eip712TypeString(name string, fields []EIP712Field) string {
+	s := name + "("
+	for i, field := range fields {
+		if i > 0 {
+			s += ","
+		}
+		s += field.Type + " " + field.Name
+	}
+	return s + ")"
+}
+
+// MakeLendingTxSigner returns the LendingTxSigner of the given block number.
+func MakeLendingTxSigner(config *params.ChainConfig, blockNumber *big.Int) LendingTxSigner {
+	if config != nil && config.IsTIPTomoXLendingV2(blockNumber) {
+		return NewLendingTxSignerV2()
+	}
+	return LendingTxSigner{}
+}
+
+// MakeLendingSigner returns the lending signer accepted at the given block number.
+func MakeLendingSigner(config *params.ChainConfig, blockNumber *big.Int) LendingSigner {
+	var signer LendingSigner = MakeLendingTxSigner(config, blockNumber)
+	if config == nil {
+		return signer
+	}
+	if config.IsTIPTomoXLendingEIP712(blockNumber) {
+		signer = NewLendingEIP712Signer(config.ChainId)
+	}
+	if config.IsTIPTomoXTextSigning(blockNumber) {
+		signer = NewLendingTextSigner(config.ChainId, signer)
+	}
+	return signer
+}
+
+// LendingEIP712Signer signs lending transactions as EIP-712 typed data of a chain. It recovers
+// the legacy signatures of LendingTxSigner as well, with the hash of the blocks since
+// TIPTomoXLendingV2 as TIPTomoXLendingEIP712 doesn't precede it.
+type LendingEIP712Signer struct {
+	chainId         *big.Int
+	domainSeparator common.Hash
+}
+
+// NewLendingEIP712Signer returns the EIP-712 lending signer of a chain.
+func NewLendingEIP712Signer(chainId *big.Int) LendingEIP712Signer {
+	if chainId == nil {
+		chainId = new(big.Int)
+	}
+	return LendingEIP712Signer{
+		chainId: chainId,
+		domainSeparator: crypto.Keccak256Hash(
+			lendingEIP712DomainTypeHash.Bytes(),
+			crypto.Keccak256([]byte(LendingEIP712Name)),
+			crypto.Keccak256([]byte(LendingEIP712Version)),
+			math.PaddedBigBytes(math.U256(new(big.Int).Set(chainId)), 32),
+		),
+	}
+}
+
+// Equal returns whether s2 is the EIP-712 lending signer of the same chain.
+func (s LendingEIP712Signer) Equal(s2 LendingSigner) bool {
+	eip712, ok := s2.(LendingEIP712Signer)
+	return ok && eip712.chainId.Cmp(s.chainId) == 0
+}
+
+// SignatureValues returns signature values. This signature needs to be in the [R || S || V] format where V is 0 or 1.
+func (s LendingEIP712Signer) SignatureValues(tx *LendingTransaction, sig []byte) (r, ss, v *big.Int, err error) {
+	return LendingTxSigner{}.SignatureValues(tx, sig)
+}
+
+// Hash returns the EIP-712 digest signed by the sender.
+func (s LendingEIP712Signer) Hash(tx *LendingTransaction) common.Hash {
+	return crypto.Keccak256Hash([]byte("\x19\x01"), s.domainSeparator.Bytes(), lendingEIP712StructHash(tx).Bytes())
+}
+
+// Sender returns the signer of the typed data of the transaction, or of its legacy signature if the
+// typed data isn't signed by its user.
+func (s LendingEIP712Signer) Sender(tx *LendingTransaction) (common.Address, error) {
+	V, R, S := tx.Signature()
+	sigBytes, err := MarshalSignature(R, S, V)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if pubKey, err := crypto.SigToPub(s.Hash(tx).Bytes(), sigBytes); err == nil {
+		if address := crypto.PubkeyToAddress(*pubKey); address == tx.UserAddress() {
+			return address, nil
+		}
+	}
+	return NewLendingTxSignerV2().Sender(tx)
+}
+
+// lendingEIP712StructHash returns hashStruct of the LendingItem typed data of a transaction.
+func lendingEIP712StructHash(tx *LendingTransaction) common.Hash {
+	word := func(v *big.Int) []byte {
+		if v == nil {
+			v = new(big.Int)
+		}
+		return math.PaddedBigBytes(math.U256(new(big.Int).Set(v)), 32)
+	}
+	uint64Word := func(v uint64) []byte { return word(new(big.Int).SetUint64(v)) }
+	addressWord := func(a common.Address) []byte { return common.BytesToHash(a.Bytes()).Bytes() }
+	stringWord := func(s string) []byte { return crypto.Keccak256([]byte(s)) }
+	autoTopUp := uint64(0)
+	if tx.AutoTopUp() {
+		autoTopUp = 1
+	}
+	return crypto.Keccak256Hash(
+		lendingEIP712ItemTypeHash.Bytes(),
+		uint64Word(tx.Nonce()),
+		word(tx.Quantity()),
+		uint64Word(tx.Interest()),
+		uint64Word(tx.Term()),
+		addressWord(tx.RelayerAddress()),
+		addressWord(tx.UserAddress()),
+		addressWord(tx.LendingToken()),
+		addressWord(tx.CollateralToken()),
+		uint64Word(autoTopUp),
+		stringWord(tx.Status()),
+		stringWord(tx.Side()),
+		stringWord(tx.Type()),
+		tx.LendingHash().Bytes(),
+		uint64Word(tx.LendingId()),
+		uint64Word(tx.LendingTradeId()),
+		stringWord(tx.ExtraData()),
+	)
+}
+
+// LendingTypedData returns the EIP-712 typed data of a lending transaction, as signed with
+// eth_signTypedData_v4. Integers are encoded as decimal strings.
+func LendingTypedData(tx *LendingTransaction, chainId *big.Int) map[string]interface{} {
+	quantity := tx.Quantity()
+	if quantity == nil {
+		quantity = new(big.Int)
+	}
+	if chainId == nil {
+		chainId = new(big.Int)
+	}
+	return map[string]interface{}{
+		"types": map[string][]EIP712Field{
+			"EIP712Domain": lendingEIP712Domain,
+			"LendingItem":  lendingEIP712Item,
+		},
+		"primaryType": "LendingItem",
+		"domain": map[string]interface{}{
+			"name":    LendingEIP712Name,
+			"version": LendingEIP712Version,
+			"chainId": chainId.String(),
+		},
+		"message": map[string]interface{}{
+			"nonce":           new(big.Int).SetUint64(tx.Nonce()).String(),
+			"quantity":        quantity.String(),
+			"interest":        new(big.Int).SetUint64(tx.Interest()).String(),
+			"term":            new(big.Int).SetUint64(tx.Term()).String(),
+			"relayer":         tx.RelayerAddress().Hex(),
+			"user":            tx.UserAddress().Hex(),
+			"lendingToken":    tx.LendingToken().Hex(),
+			"collateralToken": tx.CollateralToken().Hex(),
+			"autoTopUp":       tx.AutoTopUp(),
+			"status":          tx.Status(),
+			"side":            tx.Side(),
+			"type":            tx.Type(),
+			"hash":            tx.LendingHash().Hex(),
+			"lendingId":       new(big.Int).SetUint64(tx.LendingId()).String(),
+			"tradeId":         new(big.Int).SetUint64(tx.LendingTradeId()).String(),
+			"extraData":       tx.ExtraData(),
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestLendingEIP712Signer(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	newTx := func() *LendingTransaction {
+		return NewLendingTransaction(3, big.NewInt(1000), 10, 86400, common.HexToAddress("0x1"), user, common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+			true, "NEW", "INVEST", "LO", common.Hash{}, 0, 0, "")
+	}
+	if have, want := eip712TypeString("LendingItem", lendingEIP712Item), "LendingItem(uint256 nonce,uint256 quantity,uint256 interest,uint256 term,address relayer,address user,address lendingToken,address collateralToken,bool autoTopUp,string status,string side,string type,bytes32 hash,uint256 lendingId,uint256 tradeId,string extraData)"; have != want {
+		t.Errorf("wrong LendingItem type: have %s, want %s", have, want)
+	}
+	signer := NewLendingEIP712Signer(big.NewInt(88))
+	typed, err := LendingSignTx(newTx(), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign typed data: %v", err)
+	}
+	if from, err := signer.Sender(typed); err != nil || from != user {
+		t.Errorf("wrong signer of typed data: have %x, %v, want %x", from, err, user)
+	}
+	// the typed data is bound to its chain, and isn't a legacy signature
+	if from, _ := NewLendingEIP712Signer(big.NewInt(89)).Sender(typed); from == user {
+		t.Error("typed data of another chain accepted")
+	}
+	if from, _ := (LendingTxSigner{}).Sender(typed); from == user {
+		t.Error("typed data accepted by the legacy signer")
+	}
+	// the legacy signatures remain valid
+	legacy, err := LendingSignTx(newTx(), LendingTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign legacy transaction: %v", err)
+	}
+	if from, err := signer.Sender(legacy); err != nil || from != user {
+		t.Errorf("wrong signer of a legacy signature: have %x, %v, want %x", from, err, user)
+	}
+	// every field is signed
+	tampered := newTx()
+	tampered.data.ExtraData = "x"
+	V, R, S := typed.Signature()
+	tampered.ImportSignature(V, R, S)
+	if from, _ := signer.Sender(tampered); from == user {
+		t.Error("tampered typed data accepted")
+	}
+
+	forked := MakeLendingSigner(params.TestChainConfig, common.TIPTomoXLendingEIP712)
+	if text, ok := forked.(LendingTextSigner); ok {
+		forked = text.next
+	}
+	if _, ok := forked.(LendingEIP712Signer); !ok {
+		t.Error("legacy signer after TIPTomoXLendingEIP712")
+	}
+	before := new(big.Int).Sub(common.TIPTomoXLendingEIP712, common.Big1)
+	if _, ok := MakeLendingSigner(params.TestChainConfig, before).(LendingTxSigner); !ok {
+		t.Error("typed data signer before TIPTomoXLendingEIP712")
+	}
+	data := LendingTypedData(typed, big.NewInt(88))
+	if data["primaryType"] != "LendingItem" || data["message"].(map[string]interface{})["user"] != user.Hex() {
+		t.Errorf("wrong typed data: %v", data)
+	}
+}
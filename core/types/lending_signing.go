@@ -74,10 +74,13 @@ func LendingSender(signer LendingSigner, tx *LendingTransaction) (common.Address
 // LendingSignTx signs the lending transaction using the given lending signer and private key
 func LendingSignTx(tx *LendingTransaction, s LendingSigner, prv *ecdsa.PrivateKey) (*LendingTransaction, error) {
 	h := s.Hash(tx)
-	message := crypto.Keccak256(
-		[]byte("\x19Ethereum Signed Message:\n32"),
-		h.Bytes(),
-	)
+	message := h.Bytes()
+	if _, legacy := s.(LendingTxSigner); legacy {
+		message = crypto.Keccak256(
+			[]byte("\x19Ethereum Signed Message:\n32"),
+			h.Bytes(),
+		)
+	}
 
 	sig, err := crypto.Sign(message[:], prv)
 	if err != nil {
@@ -86,13 +89,22 @@ func LendingSignTx(tx *LendingTransaction, s LendingSigner, prv *ecdsa.PrivateKe
 	return tx.WithSignature(s, sig)
 }
 
-//LendingTxSigner signer
-type LendingTxSigner struct{}
+// LendingTxSigner signer. Since TIPTomoXLendingV2 the hash of a LO lending also covers its time in
+// force, and the hash of a LO or MO lending the referrer it names, in ExtraData, and the hash of a
+// repayment its quantity.
+type LendingTxSigner struct {
+	lendingV2 bool
+}
+
+// NewLendingTxSignerV2 returns the LendingTxSigner of the blocks since TIPTomoXLendingV2
+func NewLendingTxSignerV2() LendingTxSigner {
+	return LendingTxSigner{lendingV2: true}
+}
 
 // Equal compare two signer
 func (lendingsign LendingTxSigner) Equal(s2 LendingSigner) bool {
-	_, ok := s2.(LendingSigner)
-	return ok
+	legacy, ok := s2.(LendingTxSigner)
+	return ok && legacy.lendingV2 == lendingsign.lendingV2
 }
 
 //SignatureValues returns signature values. This signature needs to be in the [R || S || V] format where V is 0 or 1.
@@ -121,9 +133,12 @@ func (lendingsign LendingTxSigner) LendingCreateHash(tx *LendingTransaction) com
 	sha.Write(tx.LendingToken().Bytes())
 	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
-	if tx.IsLoTypeLending() {
+	if tx.IsLoTypeLending() || tx.IsIceTypeLending() {
 		sha.Write(common.BigToHash(big.NewInt(int64(tx.Interest()))).Bytes())
 	}
+	if tx.IsIceTypeLending() || tx.IsMarginLending() || (lendingsign.lendingV2 && ((tx.IsLoTypeLending() && tx.HasTimeInForce()) || tx.Referrer() != (common.Address{}))) {
+		sha.Write([]byte(tx.ExtraData()))
+	}
 	sha.Write([]byte(tx.Side()))
 	sha.Write([]byte(tx.Status()))
 	sha.Write([]byte(tx.Type()))
@@ -151,6 +166,22 @@ func (lendingsign LendingTxSigner) LendingCancelHash(tx *LendingTransaction) com
 	return common.BytesToHash(sha.Sum(nil))
 }
 
+// LendingAmendHash hash of amended lending transaction
+func (lendingsign LendingTxSigner) LendingAmendHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingId()))).Bytes())
+	sha.Write(tx.LendingHash().Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write(common.BigToHash(new(big.Int).SetUint64(tx.Interest())).Bytes())
+	return common.BytesToHash(sha.Sum(nil))
+}
+
 // LendingRepayHash hash of cancelled lending transaction
 func (lendingsign LendingTxSigner) LendingRepayHash(tx *LendingTransaction) common.Hash {
 	sha := sha3.NewKeccak256()
@@ -161,6 +192,14 @@ func (lendingsign LendingTxSigner) LendingRepayHash(tx *LendingTransaction) comm
 	sha.Write(tx.LendingToken().Bytes())
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	if lendingsign.lendingV2 {
+		// the quantity of a partial repayment, zero for a full repayment
+		quantity := tx.Quantity()
+		if quantity == nil {
+			quantity = new(big.Int)
+		}
+		sha.Write(common.BigToHash(quantity).Bytes())
+	}
 	sha.Write([]byte(tx.Type()))
 	return common.BytesToHash(sha.Sum(nil))
 }
@@ -180,12 +219,165 @@ func (lendingsign LendingTxSigner) LendingTopUpHash(tx *LendingTransaction) comm
 	return common.BytesToHash(sha.Sum(nil))
 }
 
+// LendingTopUpReserveHash hash of top-up reserve transaction
+func (lendingsign LendingTxSigner) LendingTopUpReserveHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(tx.CollateralToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingAuctionBidHash hash of liquidation auction bid transaction
+func (lendingsign LendingTxSigner) LendingAuctionBidHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingAddCollateralHash hash of add collateral transaction
+func (lendingsign LendingTxSigner) LendingAddCollateralHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(tx.CollateralToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingRolloverHash hash of rollover transaction
+func (lendingsign LendingTxSigner) LendingRolloverHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	sha.Write(common.BigToHash(new(big.Int).SetUint64(tx.Interest())).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingRecallHash hash of recall transaction
+func (lendingsign LendingTxSigner) LendingRecallHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingCircuitBreakerHash hash of circuit breaker transaction
+func (lendingsign LendingTxSigner) LendingCircuitBreakerHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write(common.BigToHash(new(big.Int).SetUint64(tx.Interest())).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingInterestModeHash hash of interest mode transaction
+func (lendingsign LendingTxSigner) LendingInterestModeHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingPriceOracleHash hash of price oracle and price report transactions
+func (lendingsign LendingTxSigner) LendingPriceOracleHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(tx.CollateralToken().Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.ExtraData()))
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingRateModelHash hash of rate model transaction
+func (lendingsign LendingTxSigner) LendingRateModelHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write([]byte(tx.ExtraData()))
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingReferralHash hash of referral transaction
+func (lendingsign LendingTxSigner) LendingReferralHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.ExtraData()))
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (lendingsign LendingTxSigner) Hash(tx *LendingTransaction) common.Hash {
 	if tx.IsCancelledLending() {
 		return lendingsign.LendingCancelHash(tx)
 	}
+	if tx.IsAmendedLending() {
+		return lendingsign.LendingAmendHash(tx)
+	}
 	if tx.IsCreatedLending() {
 		return lendingsign.LendingCreateHash(tx)
 	}
@@ -195,6 +387,36 @@ func (lendingsign LendingTxSigner) Hash(tx *LendingTransaction) common.Hash {
 	if tx.IsRepayLending() {
 		return lendingsign.LendingRepayHash(tx)
 	}
+	if tx.IsTopupReserveLending() {
+		return lendingsign.LendingTopUpReserveHash(tx)
+	}
+	if tx.IsAuctionBidLending() {
+		return lendingsign.LendingAuctionBidHash(tx)
+	}
+	if tx.IsAddCollateralLending() {
+		return lendingsign.LendingAddCollateralHash(tx)
+	}
+	if tx.IsRolloverLending() {
+		return lendingsign.LendingRolloverHash(tx)
+	}
+	if tx.IsRecallLending() {
+		return lendingsign.LendingRecallHash(tx)
+	}
+	if tx.IsCircuitBreakerLending() {
+		return lendingsign.LendingCircuitBreakerHash(tx)
+	}
+	if tx.IsRateModelLending() {
+		return lendingsign.LendingRateModelHash(tx)
+	}
+	if tx.IsReferralLending() {
+		return lendingsign.LendingReferralHash(tx)
+	}
+	if tx.IsInterestModeLending() {
+		return lendingsign.LendingInterestModeHash(tx)
+	}
+	if tx.IsPriceOracleLending() || tx.IsPriceReportLending() {
+		return lendingsign.LendingPriceOracleHash(tx)
+	}
 	return common.Hash{}
 }
 
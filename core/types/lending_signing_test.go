@@ -0,0 +1,134 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestLendingTxSignerV2(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	newTx := func(extraData string) *LendingTransaction {
+		return NewLendingTransaction(3, big.NewInt(1000), 10, 86400, common.HexToAddress("0x1"), user, common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+			true, "NEW", "BORROW", "LO", common.Hash{}, 0, 0, extraData)
+	}
+	preFork := new(big.Int).Sub(common.TIPTomoXLendingV2, common.Big1)
+	if signer := MakeLendingTxSigner(params.TestChainConfig, preFork); !signer.Equal(LendingTxSigner{}) || signer.Equal(NewLendingTxSignerV2()) {
+		t.Error("LendingTxSignerV2 before TIPTomoXLendingV2")
+	}
+	if signer := MakeLendingTxSigner(params.TestChainConfig, common.TIPTomoXLendingV2); !signer.Equal(NewLendingTxSignerV2()) {
+		t.Error("legacy LendingTxSigner after TIPTomoXLendingV2")
+	}
+
+	// before the fork neither the time in force nor the referrer of a LO lending is hashed, as by the
+	// original LendingTxSigner, and the signatures over that hash remain valid
+	legacy := MakeLendingSigner(params.TestChainConfig, preFork)
+	if have, want := legacy.Hash(newTx("GTT:1600000000")), legacy.Hash(newTx("")); have != want {
+		t.Errorf("time in force hashed before TIPTomoXLendingV2: have %x, want %x", have, want)
+	}
+	referred := "GTC;REF:" + common.HexToAddress("0x9").Hex()
+	if have, want := legacy.Hash(newTx(referred)), legacy.Hash(newTx("")); have != want {
+		t.Errorf("referrer hashed before TIPTomoXLendingV2: have %x, want %x", have, want)
+	}
+	baseline := NewLendingTransaction(3, big.NewInt(1000), 10, 86400, common.HexToAddress("0x1"), common.HexToAddress("0x5"), common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+		true, "NEW", "BORROW", "LO", common.Hash{}, 0, 0, "GTT:1600000000")
+	if have, want := legacy.Hash(baseline), common.HexToHash("0x1d7e9e19c37bc6faf40a51914ff7d1d9a6286e14b9c85a4beb3395b01d333722"); have != want {
+		t.Errorf("hash of a LO lending changed before TIPTomoXLendingV2: have %x, want %x", have, want)
+	}
+	signed, err := LendingSignTx(newTx("GTT:1600000000"), LendingTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign lending transaction: %v", err)
+	}
+	if from, err := legacy.Sender(signed); err != nil || from != user {
+		t.Errorf("wrong signer before TIPTomoXLendingV2: have %x, %v, want %x", from, err, user)
+	}
+
+	// since the fork they are hashed, so that the relayer can't change them
+	signer := NewLendingTxSignerV2()
+	if signer.Hash(newTx("GTT:1600000000")) == signer.Hash(newTx("")) {
+		t.Error("time in force not hashed since TIPTomoXLendingV2")
+	}
+	if signer.Hash(newTx(referred)) == signer.Hash(newTx("GTC")) {
+		t.Error("referrer not hashed since TIPTomoXLendingV2")
+	}
+	signed, err = LendingSignTx(newTx("GTT:1600000000"), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign lending transaction: %v", err)
+	}
+	if from, err := signer.Sender(signed); err != nil || from != user {
+		t.Errorf("wrong signer since TIPTomoXLendingV2: have %x, %v, want %x", from, err, user)
+	}
+	tampered := newTx("IOC")
+	V, R, S := signed.Signature()
+	tampered.ImportSignature(V, R, S)
+	if from, _ := signer.Sender(tampered); from == user {
+		t.Error("tampered time in force accepted since TIPTomoXLendingV2")
+	}
+}
+
+func TestLendingTransactionFrom(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	preFork := new(big.Int).Sub(common.TIPTomoXLendingV2, common.Big1)
+
+	// a LO lending signed before the fork whose ExtraData reads as a time in force and a referrer
+	// is recovered with the signer of its block, which doesn't hash them
+	tx := NewLendingTransaction(3, big.NewInt(1000), 10, 86400, common.HexToAddress("0x1"), user, common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+		true, "NEW", "BORROW", "LO", common.Hash{}, 0, 0, "GTT:1600000000;REF:"+common.HexToAddress("0x9").Hex())
+	signed, err := LendingSignTx(tx, MakeLendingSigner(params.TestChainConfig, preFork), key)
+	if err != nil {
+		t.Fatalf("failed to sign lending transaction: %v", err)
+	}
+	if from := signed.From(MakeLendingSigner(params.TestChainConfig, preFork)); from == nil || *from != user {
+		t.Errorf("sender mismatch before TIPTomoXLendingV2: have %v, want %x", from, user)
+	}
+	if from := signed.From(NewLendingTxSignerV2()); from != nil && *from == user {
+		t.Error("pre-fork lending transaction recovered with the hash of TIPTomoXLendingV2")
+	}
+	if from := tx.From(LendingTxSigner{}); from != nil {
+		t.Errorf("sender of an unsigned transaction: have %x, want nil", *from)
+	}
+}
+
+func TestLendingRepayHash(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	newTx := func(quantity *big.Int) *LendingTransaction {
+		return NewLendingTransaction(4, quantity, 0, 86400, common.HexToAddress("0x1"), user, common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+			false, "REPAY", "", "REPAY", common.Hash{}, 0, 7, "")
+	}
+	// before TIPTomoXLendingV2 the quantity of a repayment isn't hashed, as by the original
+	// LendingTxSigner
+	baseline := NewLendingTransaction(4, big.NewInt(500), 0, 86400, common.HexToAddress("0x1"), common.HexToAddress("0x5"), common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+		false, "REPAY", "", "REPAY", common.Hash{}, 0, 7, "")
+	if have, want := (LendingTxSigner{}).Hash(baseline), common.HexToHash("0xe8993ad2093b2b29593ca30610662bc2e0fe306f63d69839537a1601dad7b786"); have != want {
+		t.Errorf("hash of a repayment changed before TIPTomoXLendingV2: have %x, want %x", have, want)
+	}
+
+	// since the fork it is, so that the relayer can't turn a partial repayment into another one
+	signer := MakeLendingSigner(params.TestChainConfig, common.TIPTomoXLendingV2)
+	if NewLendingTxSignerV2().Hash(newTx(nil)) != NewLendingTxSignerV2().Hash(newTx(new(big.Int))) {
+		t.Error("full repayments without quantity hashed differently")
+	}
+	for _, s := range []LendingSigner{NewLendingTxSignerV2(), signer} {
+		signed, err := LendingSignTx(newTx(big.NewInt(500)), s, key)
+		if err != nil {
+			t.Fatalf("failed to sign repayment: %v", err)
+		}
+		if from, err := signer.Sender(signed); err != nil || from != user {
+			t.Errorf("wrong signer of a repayment: have %x, %v, want %x", from, err, user)
+		}
+		for _, quantity := range []*big.Int{big.NewInt(5000), new(big.Int)} {
+			tampered := newTx(quantity)
+			V, R, S := signed.Signature()
+			tampered.ImportSignature(V, R, S)
+			if from, _ := signer.Sender(tampered); from == user {
+				t.Errorf("repayment of %v accepted for the signed repayment of 500", quantity)
+			}
+		}
+	}
+}
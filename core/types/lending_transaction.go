@@ -17,10 +17,12 @@
 package types
 
 import (
+	"bytes"
 	"container/heap"
 	"errors"
 	"io"
 	"math/big"
+	"strings"
 	"sync/atomic"
 
 	"github.com/tomochain/tomochain/common"
@@ -41,12 +43,33 @@ const (
 	LendingStatusPartialFilled = "PARTIAL_FILLED"
 	LendingStatusFilled        = "FILLED"
 	LendingStatusCancelled     = "CANCELLED"
+	LendingStatusAmended       = "AMENDED"
 	LendingTypeMo              = "MO"
 	LendingTypeLo              = "LO"
+	LendingTypeSlo             = "SLO"
+	LendingTypeIce             = "ICE"
 	LendingSideBorrow          = "BORROW"
 	LendingSideInvest          = "INVEST"
 	LendingRePay               = "REPAY"
 	LendingTopup               = "TOPUP"
+	LendingTopupReserve        = "TOPUP_RESERVE"
+	LendingAuctionBid          = "AUCTION_BID"
+	LendingAddCollateral       = "ADD_COLLATERAL"
+	LendingRollover            = "ROLLOVER"
+	LendingRecall              = "RECALL"
+	LendingCircuitBreaker      = "CIRCUIT_BREAKER"
+	LendingRateModel           = "RATE_MODEL"
+	LendingReferral            = "REFERRAL"
+	LendingMargin              = "MARGIN"
+	LendingInterestMode        = "INTEREST_MODE"
+	LendingPriceOracle         = "PRICE_ORACLE"
+	LendingPriceReport         = "PRICE_REPORT"
+	LendingReferrerPrefix      = "REF:"
+	LendingTimeInForceGTC      = "GTC"
+	LendingTimeInForceGTT      = "GTT"
+	LendingTimeInForceIOC      = "IOC"
+	LendingTimeInForceFOK      = "FOK"
+	LendingTimeInForcePO       = "PO"
 )
 
 // LendingTransaction lending transaction
@@ -86,7 +109,7 @@ type lendingtxdata struct {
 
 // IsCreatedLending check if tx is cancelled transaction
 func (tx *LendingTransaction) IsCreatedLending() bool {
-	if (tx.IsLoTypeLending() || tx.IsMoTypeLending()) && tx.Status() == LendingStatusNew {
+	if (tx.IsLoTypeLending() || tx.IsMoTypeLending() || tx.IsSloTypeLending() || tx.IsIceTypeLending() || tx.IsMarginLending()) && tx.Status() == LendingStatusNew {
 		return true
 	}
 	return false
@@ -100,6 +123,14 @@ func (tx *LendingTransaction) IsCancelledLending() bool {
 	return false
 }
 
+// IsAmendedLending check if tx amends the quantity and the interest of an open lending item
+func (tx *LendingTransaction) IsAmendedLending() bool {
+	if tx.Status() == LendingStatusAmended {
+		return true
+	}
+	return false
+}
+
 // IsRepayLending check if tx is repay lending transaction
 func (tx *LendingTransaction) IsRepayLending() bool {
 	if tx.Type() == LendingRePay {
@@ -116,6 +147,102 @@ func (tx *LendingTransaction) IsTopupLending() bool {
 	return false
 }
 
+// IsTopupReserveLending check if tx is a top-up reserve transaction
+func (tx *LendingTransaction) IsTopupReserveLending() bool {
+	if tx.Type() == LendingTopupReserve {
+		return true
+	}
+	return false
+}
+
+// IsAuctionBidLending check if tx is a liquidation auction bid transaction
+func (tx *LendingTransaction) IsAuctionBidLending() bool {
+	if tx.Type() == LendingAuctionBid {
+		return true
+	}
+	return false
+}
+
+// IsAddCollateralLending check if tx adds a collateral token to a lending trade
+func (tx *LendingTransaction) IsAddCollateralLending() bool {
+	if tx.Type() == LendingAddCollateral {
+		return true
+	}
+	return false
+}
+
+// IsRolloverLending check if tx requests the rollover of a lending trade at maturity
+func (tx *LendingTransaction) IsRolloverLending() bool {
+	if tx.Type() == LendingRollover {
+		return true
+	}
+	return false
+}
+
+// IsRecallLending check if tx recalls the excess collateral of a lending trade
+func (tx *LendingTransaction) IsRecallLending() bool {
+	if tx.Type() == LendingRecall {
+		return true
+	}
+	return false
+}
+
+// IsCircuitBreakerLending check if tx sets the circuit breaker of a lending book
+func (tx *LendingTransaction) IsCircuitBreakerLending() bool {
+	if tx.Type() == LendingCircuitBreaker {
+		return true
+	}
+	return false
+}
+
+// IsRateModelLending check if tx sets the interest rate model of a lending book
+func (tx *LendingTransaction) IsRateModelLending() bool {
+	if tx.Type() == LendingRateModel {
+		return true
+	}
+	return false
+}
+
+// IsReferralLending check if tx registers a referrer with a relayer
+func (tx *LendingTransaction) IsReferralLending() bool {
+	if tx.Type() == LendingReferral {
+		return true
+	}
+	return false
+}
+
+// IsInterestModeLending check if tx sets how the interest of the trades of a lending book is settled
+func (tx *LendingTransaction) IsInterestModeLending() bool {
+	if tx.Type() == LendingInterestMode {
+		return true
+	}
+	return false
+}
+
+// IsPriceOracleLending check if tx sets the price oracle of a collateral token
+func (tx *LendingTransaction) IsPriceOracleLending() bool {
+	if tx.Type() == LendingPriceOracle {
+		return true
+	}
+	return false
+}
+
+// IsPriceReportLending check if tx reports a collateral price to a price oracle
+func (tx *LendingTransaction) IsPriceReportLending() bool {
+	if tx.Type() == LendingPriceReport {
+		return true
+	}
+	return false
+}
+
+// IsMarginLending check if tx borrows part of the spot order in its extra data
+func (tx *LendingTransaction) IsMarginLending() bool {
+	if tx.Type() == LendingMargin {
+		return true
+	}
+	return false
+}
+
 // IsMoTypeLending check if tx type is MO lending
 func (tx *LendingTransaction) IsMoTypeLending() bool {
 	if tx.Type() == LendingTypeMo {
@@ -132,6 +259,48 @@ func (tx *LendingTransaction) IsLoTypeLending() bool {
 	return false
 }
 
+// IsSloTypeLending check if tx type is SLO lending
+func (tx *LendingTransaction) IsSloTypeLending() bool {
+	if tx.Type() == LendingTypeSlo {
+		return true
+	}
+	return false
+}
+
+// IsIceTypeLending check if tx type is iceberg lending
+func (tx *LendingTransaction) IsIceTypeLending() bool {
+	if tx.Type() == LendingTypeIce {
+		return true
+	}
+	return false
+}
+
+// HasTimeInForce check if the extra data of the tx is the time in force of a LO lending
+func (tx *LendingTransaction) HasTimeInForce() bool {
+	extraData, _ := SplitLendingReferrer(tx.ExtraData())
+	return extraData == LendingTimeInForceGTC || extraData == LendingTimeInForceIOC || extraData == LendingTimeInForceFOK || extraData == LendingTimeInForcePO ||
+		strings.HasPrefix(extraData, LendingTimeInForceGTT+":")
+}
+
+// Referrer returns the referrer named by a LO or MO lending, empty if it names none
+func (tx *LendingTransaction) Referrer() common.Address {
+	if !tx.IsLoTypeLending() && !tx.IsMoTypeLending() {
+		return common.Address{}
+	}
+	_, referrer := SplitLendingReferrer(tx.ExtraData())
+	return referrer
+}
+
+// SplitLendingReferrer splits the extra data of a LO or MO lending into the rest of the extra data
+// and the referrer named by its last part, e.g. GTT:1600000000;REF:<address> or REF:<address>.
+func SplitLendingReferrer(extraData string) (string, common.Address) {
+	i := strings.LastIndex(extraData, LendingReferrerPrefix)
+	if i < 0 || (i > 0 && extraData[i-1] != ';') || !common.IsHexAddress(extraData[i+len(LendingReferrerPrefix):]) {
+		return extraData, common.Address{}
+	}
+	return strings.TrimSuffix(extraData[:i], ";"), common.HexToAddress(extraData[i+len(LendingReferrerPrefix):])
+}
+
 // EncodeRLP implements rlp.Encoder
 func (tx *LendingTransaction) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, &tx.data)
@@ -205,10 +374,10 @@ func (tx *LendingTransaction) LendingTradeId() uint64 { return tx.data.LendingTr
 // SetLendingHash set hash of lending transaction hash
 func (tx *LendingTransaction) SetLendingHash(h common.Hash) { tx.data.Hash = h }
 
-// From get transaction from
-func (tx *LendingTransaction) From() *common.Address {
+// From returns the sender of the transaction recovered with the signer of the block it belongs to,
+// see MakeLendingSigner, or nil if the transaction isn't signed.
+func (tx *LendingTransaction) From(signer LendingSigner) *common.Address {
 	if tx.data.V != nil {
-		signer := LendingTxSigner{}
 		if f, err := LendingSender(signer, tx); err != nil {
 			return nil
 		} else {
@@ -346,8 +515,17 @@ func LendingTxDifference(a, b LendingTransactions) (keep LendingTransactions) {
 // LendingTxByNonce sorted lending by nonce defined
 type LendingTxByNonce LendingTransactions
 
-func (s LendingTxByNonce) Len() int           { return len(s) }
-func (s LendingTxByNonce) Less(i, j int) bool { return s[i].data.AccountNonce < s[j].data.AccountNonce }
+func (s LendingTxByNonce) Len() int { return len(s) }
+
+// Less orders the transactions by nonce, then by user address, so that the transactions left
+// over by a block are the same whatever the order the accounts were listed in. It only orders the
+// transactions of the miner, the items of a block are verified in the order of the block.
+func (s LendingTxByNonce) Less(i, j int) bool {
+	if s[i].data.AccountNonce != s[j].data.AccountNonce {
+		return s[i].data.AccountNonce < s[j].data.AccountNonce
+	}
+	return bytes.Compare(s[i].data.UserAddress.Bytes(), s[j].data.UserAddress.Bytes()) < 0
+}
 
 func (s LendingTxByNonce) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 
@@ -74,10 +74,13 @@ func OrderSender(signer OrderSigner, tx *OrderTransaction) (common.Address, erro
 // OrderSignTx signs the order transaction using the given order signer and private key
 func OrderSignTx(tx *OrderTransaction, s OrderSigner, prv *ecdsa.PrivateKey) (*OrderTransaction, error) {
 	h := s.Hash(tx)
-	message := crypto.Keccak256(
-		[]byte("\x19Ethereum Signed Message:\n32"),
-		h.Bytes(),
-	)
+	message := h.Bytes()
+	if _, legacy := s.(OrderTxSigner); legacy {
+		message = crypto.Keccak256(
+			[]byte("\x19Ethereum Signed Message:\n32"),
+			h.Bytes(),
+		)
+	}
 
 	sig, err := crypto.Sign(message[:], prv)
 	if err != nil {
@@ -91,7 +94,7 @@ type OrderTxSigner struct{}
 
 // Equal compare two signer
 func (ordersign OrderTxSigner) Equal(s2 OrderSigner) bool {
-	_, ok := s2.(OrderSigner)
+	_, ok := s2.(OrderTxSigner)
 	return ok
 }
 
@@ -114,7 +117,7 @@ func (ordersign OrderTxSigner) OrderCreateHash(tx *OrderTransaction) common.Hash
 	sha.Write(tx.BaseToken().Bytes())
 	sha.Write(tx.QuoteToken().Bytes())
 	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
-	if tx.IsLoTypeOrder() {
+	if tx.IsLoTypeOrder() || tx.IsPoTypeOrder() || tx.IsStopTypeOrder() || tx.IsFtTypeOrder() {
 		if tx.Price() != nil {
 			sha.Write(common.BigToHash(tx.Price()).Bytes())
 		}
@@ -43,6 +43,13 @@ const (
 	OrderStatusCancelled     = "CANCELLED"
 	OrderTypeMo              = "MO"
 	OrderTypeLo              = "LO"
+	OrderTypeSl              = "SL"
+	OrderTypeTp              = "TP"
+	OrderTypeTs              = "TS"
+	OrderTypePo              = "PO"
+	OrderTypeFt              = "FT"
+	OrderTypeMn              = "MN"
+	OrderTypePb              = "PB"
 )
 
 // OrderTransaction order transaction
@@ -99,6 +106,30 @@ func (tx *OrderTransaction) IsLoTypeOrder() bool {
 	return false
 }
 
+// IsPoTypeOrder check if tx type is PO (post-only limit) Order
+func (tx *OrderTransaction) IsPoTypeOrder() bool {
+	if tx.Type() == OrderTypePo {
+		return true
+	}
+	return false
+}
+
+// IsFtTypeOrder check if tx type is FT (fee tier) Order
+func (tx *OrderTransaction) IsFtTypeOrder() bool {
+	if tx.Type() == OrderTypeFt {
+		return true
+	}
+	return false
+}
+
+// IsStopTypeOrder check if tx type is SL (stop-loss), TP (take-profit) or TS (trailing stop) Order
+func (tx *OrderTransaction) IsStopTypeOrder() bool {
+	if tx.Type() == OrderTypeSl || tx.Type() == OrderTypeTp || tx.Type() == OrderTypeTs {
+		return true
+	}
+	return false
+}
+
 // EncodeRLP implements rlp.Encoder
 func (tx *OrderTransaction) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, &tx.data)
@@ -0,0 +1,196 @@
+package types
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Since TIPTomoXTextSigning an order or lending transaction can also be signed as an Ethereum signed
+// message of its human-readable terms (see OrderText and LendingText), the message hardware wallets
+// display before signing. The signed digest is
+//
+//	keccak256("\x19Ethereum Signed Message:\n" + len(text) + text)
+//
+// The text names the chain, so that it can't be replayed on another network. The signatures of the
+// previous schemes remain valid.
+
+// textHash returns the digest of a text signed as an Ethereum signed message.
+func textHash(text string) common.Hash {
+	return crypto.Keccak256Hash([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(text), text)))
+}
+
+// recoverText returns the signer of a digest, if it is the expected user.
+func recoverText(digest common.Hash, user common.Address, V, R, S *big.Int) (common.Address, bool) {
+	sigBytes, err := MarshalSignature(R, S, V)
+	if err != nil {
+		return common.Address{}, false
+	}
+	pubKey, err := crypto.SigToPub(digest.Bytes(), sigBytes)
+	if err != nil {
+		return common.Address{}, false
+	}
+	address := crypto.PubkeyToAddress(*pubKey)
+	return address, address == user
+}
+
+func textAmount(amount *big.Int) string {
+	if amount == nil {
+		return "0"
+	}
+	return amount.String()
+}
+
+// OrderText returns the human-readable terms of an order transaction of a chain, signed by
+// OrderTextSigner.
+func OrderText(tx *OrderTransaction, chainId *big.Int) string {
+	lines := []string{
+		"TomoX order",
+		"Chain: " + textAmount(chainId),
+		"Status: " + tx.Status(),
+		"Side: " + tx.Side(),
+		"Type: " + tx.Type(),
+		"Quantity: " + textAmount(tx.Quantity()),
+		"Price: " + textAmount(tx.Price()),
+		"Base token: " + tx.BaseToken().Hex(),
+		"Quote token: " + tx.QuoteToken().Hex(),
+		"Exchange: " + tx.ExchangeAddress().Hex(),
+		"User: " + tx.UserAddress().Hex(),
+		fmt.Sprintf("Nonce: %d", tx.Nonce()),
+		fmt.Sprintf("Order id: %d", tx.OrderID()),
+		"Hash: " + tx.OrderHash().Hex(),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LendingText returns the human-readable terms of a lending transaction of a chain, signed by
+// LendingTextSigner.
+func LendingText(tx *LendingTransaction, chainId *big.Int) string {
+	lines := []string{
+		"TomoX lending order",
+		"Chain: " + textAmount(chainId),
+		"Status: " + tx.Status(),
+		"Side: " + tx.Side(),
+		"Type: " + tx.Type(),
+		"Quantity: " + textAmount(tx.Quantity()),
+		fmt.Sprintf("Interest: %d", tx.Interest()),
+		fmt.Sprintf("Term: %d seconds", tx.Term()),
+		"Lending token: " + tx.LendingToken().Hex(),
+		"Collateral token: " + tx.CollateralToken().Hex(),
+		fmt.Sprintf("Auto top-up: %t", tx.AutoTopUp()),
+		"Relayer: " + tx.RelayerAddress().Hex(),
+		"User: " + tx.UserAddress().Hex(),
+		fmt.Sprintf("Nonce: %d", tx.Nonce()),
+		fmt.Sprintf("Lending id: %d", tx.LendingId()),
+		fmt.Sprintf("Trade id: %d", tx.LendingTradeId()),
+		"Hash: " + tx.LendingHash().Hex(),
+		"Extra data: " + tx.ExtraData(),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MakeOrderSigner returns the order signer accepted at the given block number.
+func MakeOrderSigner(config *params.ChainConfig, blockNumber *big.Int) OrderSigner {
+	if config != nil && config.IsTIPTomoXTextSigning(blockNumber) {
+		return NewOrderTextSigner(config.ChainId)
+	}
+	return OrderTxSigner{}
+}
+
+// OrderTextSigner signs order transactions of a chain as Ethereum signed messages of their terms.
+// It recovers the signatures of OrderTxSigner as well.
+type OrderTextSigner struct {
+	chainId *big.Int
+}
+
+// NewOrderTextSigner returns the text order signer of a chain.
+func NewOrderTextSigner(chainId *big.Int) OrderTextSigner {
+	if chainId == nil {
+		chainId = new(big.Int)
+	}
+	return OrderTextSigner{chainId: chainId}
+}
+
+// Text returns the terms of the transaction signed by its user.
+func (s OrderTextSigner) Text(tx *OrderTransaction) string {
+	return OrderText(tx, s.chainId)
+}
+
+// Equal returns whether s2 is the text order signer of the same chain.
+func (s OrderTextSigner) Equal(s2 OrderSigner) bool {
+	text, ok := s2.(OrderTextSigner)
+	return ok && text.chainId.Cmp(s.chainId) == 0
+}
+
+// SignatureValues returns signature values. This signature needs to be in the [R || S || V] format where V is 0 or 1.
+func (s OrderTextSigner) SignatureValues(tx *OrderTransaction, sig []byte) (r, ss, v *big.Int, err error) {
+	return OrderTxSigner{}.SignatureValues(tx, sig)
+}
+
+// Hash returns the digest of the signed message of the terms of the transaction.
+func (s OrderTextSigner) Hash(tx *OrderTransaction) common.Hash {
+	return textHash(s.Text(tx))
+}
+
+// Sender returns the signer of the terms of the transaction, or of its legacy signature if the
+// terms aren't signed by its user.
+func (s OrderTextSigner) Sender(tx *OrderTransaction) (common.Address, error) {
+	V, R, S := tx.Signature()
+	if address, ok := recoverText(s.Hash(tx), tx.UserAddress(), V, R, S); ok {
+		return address, nil
+	}
+	return OrderTxSigner{}.Sender(tx)
+}
+
+// LendingTextSigner signs lending transactions of a chain as Ethereum signed messages of their
+// terms. It recovers the signatures of the lending signer it extends as well.
+type LendingTextSigner struct {
+	chainId *big.Int
+	next    LendingSigner
+}
+
+// NewLendingTextSigner returns the text lending signer of a chain, falling back to next.
+func NewLendingTextSigner(chainId *big.Int, next LendingSigner) LendingTextSigner {
+	if chainId == nil {
+		chainId = new(big.Int)
+	}
+	if next == nil {
+		next = LendingTxSigner{}
+	}
+	return LendingTextSigner{chainId: chainId, next: next}
+}
+
+// Text returns the terms of the transaction signed by its user.
+func (s LendingTextSigner) Text(tx *LendingTransaction) string {
+	return LendingText(tx, s.chainId)
+}
+
+// Equal returns whether s2 is the text lending signer of the same chain and fallback.
+func (s LendingTextSigner) Equal(s2 LendingSigner) bool {
+	text, ok := s2.(LendingTextSigner)
+	return ok && text.chainId.Cmp(s.chainId) == 0 && text.next.Equal(s.next)
+}
+
+// SignatureValues returns signature values. This signature needs to be in the [R || S || V] format where V is 0 or 1.
+func (s LendingTextSigner) SignatureValues(tx *LendingTransaction, sig []byte) (r, ss, v *big.Int, err error) {
+	return LendingTxSigner{}.SignatureValues(tx, sig)
+}
+
+// Hash returns the digest of the signed message of the terms of the transaction.
+func (s LendingTextSigner) Hash(tx *LendingTransaction) common.Hash {
+	return textHash(s.Text(tx))
+}
+
+// Sender returns the signer of the terms of the transaction, or the sender recovered by the
+// extended signer if the terms aren't signed by its user.
+func (s LendingTextSigner) Sender(tx *LendingTransaction) (common.Address, error) {
+	V, R, S := tx.Signature()
+	if address, ok := recoverText(s.Hash(tx), tx.UserAddress(), V, R, S); ok {
+		return address, nil
+	}
+	return s.next.Sender(tx)
+}
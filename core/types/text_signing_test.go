@@ -0,0 +1,74 @@
+package types
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+func TestOrderTextSigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	newTx := func() *OrderTransaction {
+		return NewOrderTransaction(1, big.NewInt(5), big.NewInt(7), common.HexToAddress("0x1"), user, common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+			"NEW", "BUY", "LO", common.Hash{}, 0)
+	}
+	signer := NewOrderTextSigner(big.NewInt(88))
+	if text := signer.Text(newTx()); !strings.Contains(text, "Side: BUY\n") || !strings.Contains(text, "Price: 7\n") || !strings.Contains(text, "Chain: 88\n") {
+		t.Errorf("terms missing from the order text:\n%s", text)
+	}
+	signed, err := OrderSignTx(newTx(), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign order text: %v", err)
+	}
+	if from, err := signer.Sender(signed); err != nil || from != user {
+		t.Errorf("wrong signer of the order text: have %x, %v, want %x", from, err, user)
+	}
+	if from, _ := NewOrderTextSigner(big.NewInt(89)).Sender(signed); from == user {
+		t.Error("order text of another chain accepted")
+	}
+	if from, _ := (OrderTxSigner{}).Sender(signed); from == user {
+		t.Error("order text accepted by the legacy signer")
+	}
+	legacy, err := OrderSignTx(newTx(), OrderTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign legacy order: %v", err)
+	}
+	if from, err := signer.Sender(legacy); err != nil || from != user {
+		t.Errorf("wrong signer of a legacy order: have %x, %v, want %x", from, err, user)
+	}
+	// the senders recovered by the legacy signer aren't reused by the text signer
+	if (OrderTxSigner{}).Equal(signer) || signer.Equal(OrderTxSigner{}) {
+		t.Error("legacy and text order signers are equal")
+	}
+}
+
+func TestLendingTextSigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	newTx := func() *LendingTransaction {
+		return NewLendingTransaction(3, big.NewInt(1000), 10, 86400, common.HexToAddress("0x1"), user, common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+			true, "NEW", "INVEST", "LO", common.Hash{}, 0, 0, "")
+	}
+	eip712 := NewLendingEIP712Signer(big.NewInt(88))
+	signer := NewLendingTextSigner(big.NewInt(88), eip712)
+	if text := signer.Text(newTx()); !strings.Contains(text, "Term: 86400 seconds\n") || !strings.Contains(text, "Interest: 10\n") {
+		t.Errorf("terms missing from the lending text:\n%s", text)
+	}
+	// the terms, the typed data and the legacy hash are all accepted
+	for _, s := range []LendingSigner{signer, eip712, LendingTxSigner{}} {
+		signed, err := LendingSignTx(newTx(), s, key)
+		if err != nil {
+			t.Fatalf("failed to sign lending transaction: %v", err)
+		}
+		if from, err := signer.Sender(signed); err != nil || from != user {
+			t.Errorf("wrong signer with %T: have %x, %v, want %x", s, from, err, user)
+		}
+	}
+	if signer.Equal(NewLendingTextSigner(big.NewInt(88), LendingTxSigner{})) || (LendingTxSigner{}).Equal(signer) {
+		t.Error("different lending signers are equal")
+	}
+}
@@ -197,6 +197,15 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 	eth.txPool = core.NewTxPool(config.TxPool, eth.chainConfig, eth.blockchain)
 	eth.orderPool = core.NewOrderPool(eth.chainConfig, eth.blockchain)
 	eth.lendingPool = core.NewLendingPool(eth.chainConfig, eth.blockchain)
+	if tomoXServ != nil {
+		tomoXServ.SetChain(eth.blockchain)
+		eth.lendingPool.SetRelayerSlots(tomoXServ.LendingRelayerSlots())
+	}
+	if lendingServ != nil {
+		lendingServ.SetChain(eth.blockchain)
+		lendingServ.SetLendingPool(eth.lendingPool)
+		lendingServ.SetAccountManager(ctx.AccountManager)
+	}
 	if common.RollbackHash != common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000") {
 		curBlock := eth.blockchain.CurrentBlock()
 		prevBlock := eth.blockchain.GetBlockByHash(common.RollbackHash)
@@ -0,0 +1,286 @@
+// Package graphql provides a GraphQL interface to the orders, trades and lending records of an
+// SDK node.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Long is a 64 bit unsigned integer.
+type Long uint64
+
+// ImplementsGraphQLType returns true if Long implements the provided GraphQL type.
+func (b Long) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (b *Long) UnmarshalGraphQL(input interface{}) error {
+	var err error
+	switch input := input.(type) {
+	case string:
+		var value uint64
+		value, err = hexutil.DecodeUint64(input)
+		*b = Long(value)
+	case int32:
+		if input < 0 {
+			return fmt.Errorf("negative value %d for Long", input)
+		}
+		*b = Long(input)
+	default:
+		err = fmt.Errorf("unexpected type %T for Long", input)
+	}
+	return err
+}
+
+func bigInt(x *big.Int) *hexutil.Big {
+	if x == nil {
+		return nil
+	}
+	return (*hexutil.Big)(x)
+}
+
+// unixTime returns the unix timestamp of t in seconds, 0 for the zero time.
+func unixTime(t time.Time) Long {
+	if t.IsZero() {
+		return 0
+	}
+	return Long(t.Unix())
+}
+
+// Order represents a spot order.
+type Order struct {
+	o *tradingstate.OrderItem
+}
+
+func (o *Order) Hash() common.Hash          { return o.o.Hash }
+func (o *Order) TxHash() common.Hash        { return o.o.TxHash }
+func (o *Order) User() common.Address       { return o.o.UserAddress }
+func (o *Order) Exchange() common.Address   { return o.o.ExchangeAddress }
+func (o *Order) BaseToken() common.Address  { return o.o.BaseToken }
+func (o *Order) QuoteToken() common.Address { return o.o.QuoteToken }
+func (o *Order) Side() string               { return o.o.Side }
+func (o *Order) Type() string               { return o.o.Type }
+func (o *Order) Status() string             { return o.o.Status }
+func (o *Order) Price() *hexutil.Big        { return bigInt(o.o.Price) }
+func (o *Order) Quantity() *hexutil.Big     { return bigInt(o.o.Quantity) }
+func (o *Order) FilledAmount() *hexutil.Big { return bigInt(o.o.FilledAmount) }
+func (o *Order) Nonce() *hexutil.Big        { return bigInt(o.o.Nonce) }
+func (o *Order) OrderId() Long              { return Long(o.o.OrderID) }
+func (o *Order) CreatedAt() Long            { return unixTime(o.o.CreatedAt) }
+func (o *Order) UpdatedAt() Long            { return unixTime(o.o.UpdatedAt) }
+
+// Trade represents a spot trade.
+type Trade struct {
+	t *tradingstate.Trade
+}
+
+func (t *Trade) Hash() common.Hash             { return t.t.Hash }
+func (t *Trade) TxHash() common.Hash           { return t.t.TxHash }
+func (t *Trade) Taker() common.Address         { return t.t.Taker }
+func (t *Trade) Maker() common.Address         { return t.t.Maker }
+func (t *Trade) BaseToken() common.Address     { return t.t.BaseToken }
+func (t *Trade) QuoteToken() common.Address    { return t.t.QuoteToken }
+func (t *Trade) TakerOrderHash() common.Hash   { return t.t.TakerOrderHash }
+func (t *Trade) MakerOrderHash() common.Hash   { return t.t.MakerOrderHash }
+func (t *Trade) TakerExchange() common.Address { return t.t.TakerExchange }
+func (t *Trade) MakerExchange() common.Address { return t.t.MakerExchange }
+func (t *Trade) Price() *hexutil.Big           { return bigInt(t.t.PricePoint) }
+func (t *Trade) Amount() *hexutil.Big          { return bigInt(t.t.Amount) }
+func (t *Trade) TakeFee() *hexutil.Big         { return bigInt(t.t.TakeFee) }
+func (t *Trade) MakeFee() *hexutil.Big         { return bigInt(t.t.MakeFee) }
+func (t *Trade) Status() string                { return t.t.Status }
+func (t *Trade) TakerOrderSide() string        { return t.t.TakerOrderSide }
+func (t *Trade) TakerOrderType() string        { return t.t.TakerOrderType }
+func (t *Trade) MakerOrderType() string        { return t.t.MakerOrderType }
+func (t *Trade) CreatedAt() Long               { return unixTime(t.t.CreatedAt) }
+func (t *Trade) UpdatedAt() Long               { return unixTime(t.t.UpdatedAt) }
+
+// LendingItem represents a lending item.
+type LendingItem struct {
+	i *lendingstate.LendingItem
+}
+
+func (i *LendingItem) Hash() common.Hash               { return i.i.Hash }
+func (i *LendingItem) TxHash() common.Hash             { return i.i.TxHash }
+func (i *LendingItem) User() common.Address            { return i.i.UserAddress }
+func (i *LendingItem) Relayer() common.Address         { return i.i.Relayer }
+func (i *LendingItem) LendingToken() common.Address    { return i.i.LendingToken }
+func (i *LendingItem) CollateralToken() common.Address { return i.i.CollateralToken }
+func (i *LendingItem) Term() Long                      { return Long(i.i.Term) }
+func (i *LendingItem) Interest() *hexutil.Big          { return bigInt(i.i.Interest) }
+func (i *LendingItem) Side() string                    { return i.i.Side }
+func (i *LendingItem) Type() string                    { return i.i.Type }
+func (i *LendingItem) Status() string                  { return i.i.Status }
+func (i *LendingItem) Quantity() *hexutil.Big          { return bigInt(i.i.Quantity) }
+func (i *LendingItem) FilledAmount() *hexutil.Big      { return bigInt(i.i.FilledAmount) }
+func (i *LendingItem) AutoTopUp() bool                 { return i.i.AutoTopUp }
+func (i *LendingItem) Nonce() *hexutil.Big             { return bigInt(i.i.Nonce) }
+func (i *LendingItem) LendingId() Long                 { return Long(i.i.LendingId) }
+func (i *LendingItem) LendingTradeId() Long            { return Long(i.i.LendingTradeId) }
+func (i *LendingItem) ExtraData() string               { return i.i.ExtraData }
+func (i *LendingItem) RejectReason() string            { return i.i.RejectReason }
+func (i *LendingItem) CreatedAt() Long                 { return unixTime(i.i.CreatedAt) }
+func (i *LendingItem) UpdatedAt() Long                 { return unixTime(i.i.UpdatedAt) }
+
+// LendingTrade represents a lending trade.
+type LendingTrade struct {
+	t *lendingstate.LendingTrade
+}
+
+func (t *LendingTrade) Hash() common.Hash                { return t.t.Hash }
+func (t *LendingTrade) TxHash() common.Hash              { return t.t.TxHash }
+func (t *LendingTrade) TradeId() Long                    { return Long(t.t.TradeId) }
+func (t *LendingTrade) Borrower() common.Address         { return t.t.Borrower }
+func (t *LendingTrade) Investor() common.Address         { return t.t.Investor }
+func (t *LendingTrade) LendingToken() common.Address     { return t.t.LendingToken }
+func (t *LendingTrade) CollateralToken() common.Address  { return t.t.CollateralToken }
+func (t *LendingTrade) BorrowingOrderHash() common.Hash  { return t.t.BorrowingOrderHash }
+func (t *LendingTrade) InvestingOrderHash() common.Hash  { return t.t.InvestingOrderHash }
+func (t *LendingTrade) BorrowingRelayer() common.Address { return t.t.BorrowingRelayer }
+func (t *LendingTrade) InvestingRelayer() common.Address { return t.t.InvestingRelayer }
+func (t *LendingTrade) Term() Long                       { return Long(t.t.Term) }
+func (t *LendingTrade) Interest() Long                   { return Long(t.t.Interest) }
+func (t *LendingTrade) Amount() *hexutil.Big             { return bigInt(t.t.Amount) }
+func (t *LendingTrade) CollateralPrice() *hexutil.Big    { return bigInt(t.t.CollateralPrice) }
+func (t *LendingTrade) LiquidationPrice() *hexutil.Big   { return bigInt(t.t.LiquidationPrice) }
+func (t *LendingTrade) CollateralLockedAmount() *hexutil.Big {
+	return bigInt(t.t.CollateralLockedAmount)
+}
+func (t *LendingTrade) AutoTopUp() bool            { return t.t.AutoTopUp }
+func (t *LendingTrade) LiquidationTime() Long      { return Long(t.t.LiquidationTime) }
+func (t *LendingTrade) BorrowingFee() *hexutil.Big { return bigInt(t.t.BorrowingFee) }
+func (t *LendingTrade) InvestingFee() *hexutil.Big { return bigInt(t.t.InvestingFee) }
+func (t *LendingTrade) Status() string             { return t.t.Status }
+func (t *LendingTrade) TakerOrderSide() string     { return t.t.TakerOrderSide }
+func (t *LendingTrade) TakerOrderType() string     { return t.t.TakerOrderType }
+func (t *LendingTrade) MakerOrderType() string     { return t.t.MakerOrderType }
+func (t *LendingTrade) ExtraData() string          { return t.t.ExtraData }
+func (t *LendingTrade) CreatedAt() Long            { return unixTime(t.t.CreatedAt) }
+func (t *LendingTrade) UpdatedAt() Long            { return unixTime(t.t.UpdatedAt) }
+
+// Resolver is the root resolver of the schema, serving the records through the RPC APIs of the
+// TomoX and lending services.
+type Resolver struct {
+	tomox   *tomox.PublicTomoXAPI
+	lending *tomoxlending.PublicTomoXLendingAPI
+}
+
+// The optional arguments of the queries are nil when left out.
+func addressArg(arg *common.Address) common.Address {
+	if arg == nil {
+		return common.Address{}
+	}
+	return *arg
+}
+
+func stringArg(arg *string) string {
+	if arg == nil {
+		return ""
+	}
+	return *arg
+}
+
+func intArg(arg *int32) int {
+	if arg == nil {
+		return 0
+	}
+	return int(*arg)
+}
+
+func longArg(arg *Long) uint64 {
+	if arg == nil {
+		return 0
+	}
+	return uint64(*arg)
+}
+
+type tradingArgs struct {
+	User       common.Address
+	BaseToken  *common.Address
+	QuoteToken *common.Address
+	Status     *string
+	Page       *int32
+	Limit      *int32
+}
+
+func (r *Resolver) Orders(ctx context.Context, args tradingArgs) ([]*Order, error) {
+	orders, err := r.tomox.GetOrdersByUser(ctx, args.User, addressArg(args.BaseToken), addressArg(args.QuoteToken), stringArg(args.Status), intArg(args.Page), intArg(args.Limit))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*Order, len(orders))
+	for i, order := range orders {
+		ret[i] = &Order{order}
+	}
+	return ret, nil
+}
+
+func (r *Resolver) Trades(ctx context.Context, args tradingArgs) ([]*Trade, error) {
+	trades, err := r.tomox.GetTradesByUser(ctx, args.User, addressArg(args.BaseToken), addressArg(args.QuoteToken), stringArg(args.Status), intArg(args.Page), intArg(args.Limit))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*Trade, len(trades))
+	for i, trade := range trades {
+		ret[i] = &Trade{trade}
+	}
+	return ret, nil
+}
+
+type lendingArgs struct {
+	User         common.Address
+	LendingToken *common.Address
+	Term         *Long
+	Status       *string
+	Relayer      *common.Address
+	Page         *int32
+	Limit        *int32
+}
+
+func (r *Resolver) LendingItems(ctx context.Context, args lendingArgs) ([]*LendingItem, error) {
+	items, err := r.lending.GetLendingItemsByUser(ctx, args.User, addressArg(args.LendingToken), longArg(args.Term), stringArg(args.Status), intArg(args.Page), intArg(args.Limit), args.Relayer)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*LendingItem, len(items))
+	for i, item := range items {
+		ret[i] = &LendingItem{item}
+	}
+	return ret, nil
+}
+
+func (r *Resolver) LendingTrades(ctx context.Context, args lendingArgs) ([]*LendingTrade, error) {
+	return r.lendingTrades(ctx, args.User, args.LendingToken, args.Term, stringArg(args.Status), args.Relayer, args.Page, args.Limit)
+}
+
+func (r *Resolver) Liquidations(ctx context.Context, args struct {
+	User         common.Address
+	LendingToken *common.Address
+	Term         *Long
+	Relayer      *common.Address
+	Page         *int32
+	Limit        *int32
+}) ([]*LendingTrade, error) {
+	return r.lendingTrades(ctx, args.User, args.LendingToken, args.Term, lendingstate.TradeStatusLiquidated, args.Relayer, args.Page, args.Limit)
+}
+
+func (r *Resolver) lendingTrades(ctx context.Context, user common.Address, lendingToken *common.Address, term *Long, status string, relayer *common.Address, page, limit *int32) ([]*LendingTrade, error) {
+	trades, err := r.lending.GetLendingTradesByUser(ctx, user, addressArg(lendingToken), longArg(term), status, intArg(page), intArg(limit), relayer)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*LendingTrade, len(trades))
+	for i, trade := range trades {
+		ret[i] = &LendingTrade{trade}
+	}
+	return ret, nil
+}
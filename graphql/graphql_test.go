@@ -0,0 +1,127 @@
+package graphql
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func newTestService(t *testing.T) (*Service, *tomox.TomoX) {
+	tomoX := tomox.New(&tomox.Config{DataDir: t.TempDir(), DBEngine: "badger"})
+	t.Cleanup(func() { tomoX.GetMongoDB().Close() })
+	service, err := New(tomoX, tomoxlending.New(tomoX))
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	return service, tomoX
+}
+
+func query(t *testing.T, service *Service, query string) map[string]interface{} {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+	service.HTTPHandlers()["/graphql"].ServeHTTP(rec, req)
+
+	var result struct {
+		Data   map[string]interface{}
+		Errors []interface{}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response %s: %v", rec.Body.String(), err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("query failed: %v", result.Errors)
+	}
+	return result.Data
+}
+
+func TestQueryHistory(t *testing.T) {
+	service, tomoX := newTestService(t)
+	db := tomoX.GetMongoDB()
+
+	var (
+		user  = common.HexToAddress("0x1")
+		other = common.HexToAddress("0x2")
+		base  = common.HexToAddress("0x10")
+		quote = common.HexToAddress("0x11")
+		now   = time.Unix(1600000000, 0)
+	)
+	db.InitBulk()
+	for i, pair := range [][2]common.Address{{base, quote}, {quote, base}} {
+		order := &tradingstate.OrderItem{
+			UserAddress: user,
+			BaseToken:   pair[0],
+			QuoteToken:  pair[1],
+			Status:      tradingstate.OrderStatusOpen,
+			Price:       big.NewInt(100),
+			Hash:        common.BigToHash(big.NewInt(int64(i + 1))),
+			CreatedAt:   now.Add(time.Duration(i) * time.Second),
+		}
+		db.PutObject(order.Hash, order)
+	}
+	trade := &tradingstate.Trade{Taker: other, Maker: user, BaseToken: base, QuoteToken: quote, Amount: big.NewInt(5), Hash: common.HexToHash("0x20"), CreatedAt: now}
+	db.PutObject(trade.Hash, trade)
+	if err := db.CommitBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+	db.InitLendingBulk()
+	for i, status := range []string{lendingstate.TradeStatusOpen, lendingstate.TradeStatusLiquidated} {
+		lendingTrade := &lendingstate.LendingTrade{Borrower: user, Investor: other, Term: 86400, Status: status, Hash: common.BigToHash(big.NewInt(int64(0x30 + i))), CreatedAt: now}
+		db.PutObject(lendingTrade.Hash, lendingTrade)
+	}
+	if err := db.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+
+	data := query(t, service, `{
+		orders(user: "0x0000000000000000000000000000000000000001", baseToken: "0x0000000000000000000000000000000000000010") { hash price createdAt }
+		all: orders(user: "0x0000000000000000000000000000000000000001", limit: 1) { hash }
+		trades(user: "0x0000000000000000000000000000000000000001") { taker amount }
+		lendingTrades(user: "0x0000000000000000000000000000000000000001", term: 86400) { status }
+		liquidations(user: "0x0000000000000000000000000000000000000002") { hash }
+	}`)
+	orders := data["orders"].([]interface{})
+	if len(orders) != 1 {
+		t.Fatalf("wrong number of orders of the pair: have %d, want 1", len(orders))
+	}
+	order := orders[0].(map[string]interface{})
+	if order["hash"] != common.BigToHash(big.NewInt(1)).Hex() || order["price"] != "0x64" || order["createdAt"] != float64(now.Unix()) {
+		t.Fatalf("wrong order: %v", order)
+	}
+	if all := data["all"].([]interface{}); len(all) != 1 || all[0].(map[string]interface{})["hash"] != common.BigToHash(big.NewInt(2)).Hex() {
+		t.Fatalf("wrong page of orders: %v", all)
+	}
+	if trades := data["trades"].([]interface{}); len(trades) != 1 || trades[0].(map[string]interface{})["taker"] != strings.ToLower(other.Hex()) {
+		t.Fatalf("wrong trades: %v", trades)
+	}
+	if trades := data["lendingTrades"].([]interface{}); len(trades) != 2 {
+		t.Fatalf("wrong number of lending trades: have %d, want 2", len(trades))
+	}
+	if liquidations := data["liquidations"].([]interface{}); len(liquidations) != 1 || liquidations[0].(map[string]interface{})["hash"] != common.BigToHash(big.NewInt(0x31)).Hex() {
+		t.Fatalf("wrong liquidations: %v", liquidations)
+	}
+}
+
+func TestQueryRequiresSDKNode(t *testing.T) {
+	tomoX := tomox.New(&tomox.Config{DataDir: t.TempDir()})
+	service, err := New(tomoX, tomoxlending.New(tomoX))
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	body := `{"query": "{ orders(user: \"0x0000000000000000000000000000000000000001\") { hash } }"}`
+	rec := httptest.NewRecorder()
+	service.HTTPHandlers()["/graphql"].ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+	if !strings.Contains(rec.Body.String(), "SDK node") {
+		t.Fatalf("expected an SDK node error, got %s", rec.Body.String())
+	}
+}
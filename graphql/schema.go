@@ -0,0 +1,140 @@
+package graphql
+
+const schema string = `
+    # Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
+    scalar Bytes32
+    # Address is a 20 byte Ethereum address, represented as 0x-prefixed hexadecimal.
+    scalar Address
+    # BigInt is a large integer, represented as 0x-prefixed hexadecimal.
+    scalar BigInt
+    # Long is a 64 bit unsigned integer.
+    scalar Long
+
+    schema {
+        query: Query
+    }
+
+    # Order is a spot order placed on a relayer.
+    type Order {
+        hash: Bytes32!
+        # TxHash is the hash of the transaction matching the order last.
+        txHash: Bytes32!
+        user: Address!
+        exchange: Address!
+        baseToken: Address!
+        quoteToken: Address!
+        side: String!
+        type: String!
+        status: String!
+        price: BigInt
+        quantity: BigInt
+        filledAmount: BigInt
+        nonce: BigInt
+        orderId: Long!
+        # CreatedAt and UpdatedAt are unix timestamps, in seconds.
+        createdAt: Long!
+        updatedAt: Long!
+    }
+
+    # Trade is the match of a taker spot order with a maker spot order.
+    type Trade {
+        hash: Bytes32!
+        txHash: Bytes32!
+        taker: Address!
+        maker: Address!
+        baseToken: Address!
+        quoteToken: Address!
+        takerOrderHash: Bytes32!
+        makerOrderHash: Bytes32!
+        takerExchange: Address!
+        makerExchange: Address!
+        price: BigInt
+        amount: BigInt
+        takeFee: BigInt
+        makeFee: BigInt
+        status: String!
+        takerOrderSide: String!
+        takerOrderType: String!
+        makerOrderType: String!
+        createdAt: Long!
+        updatedAt: Long!
+    }
+
+    # LendingItem is a lending order, or a top up, a repayment or a recall of a lending trade.
+    type LendingItem {
+        hash: Bytes32!
+        txHash: Bytes32!
+        user: Address!
+        relayer: Address!
+        lendingToken: Address!
+        collateralToken: Address!
+        term: Long!
+        # Interest is the yearly interest rate, over 10^8.
+        interest: BigInt
+        side: String!
+        type: String!
+        status: String!
+        quantity: BigInt
+        filledAmount: BigInt
+        autoTopUp: Boolean!
+        nonce: BigInt
+        lendingId: Long!
+        lendingTradeId: Long!
+        extraData: String!
+        rejectReason: String!
+        createdAt: Long!
+        updatedAt: Long!
+    }
+
+    # LendingTrade is a lending position, opened by the match of a borrowing item with an
+    # investing item.
+    type LendingTrade {
+        hash: Bytes32!
+        txHash: Bytes32!
+        tradeId: Long!
+        borrower: Address!
+        investor: Address!
+        lendingToken: Address!
+        collateralToken: Address!
+        borrowingOrderHash: Bytes32!
+        investingOrderHash: Bytes32!
+        borrowingRelayer: Address!
+        investingRelayer: Address!
+        term: Long!
+        interest: Long!
+        amount: BigInt
+        collateralPrice: BigInt
+        liquidationPrice: BigInt
+        collateralLockedAmount: BigInt
+        autoTopUp: Boolean!
+        # LiquidationTime is the unix timestamp, in seconds, the trade is liquidated at if it
+        # isn't repaid.
+        liquidationTime: Long!
+        borrowingFee: BigInt
+        investingFee: BigInt
+        status: String!
+        takerOrderSide: String!
+        takerOrderType: String!
+        makerOrderType: String!
+        extraData: String!
+        createdAt: Long!
+        updatedAt: Long!
+    }
+
+    # The lists are sorted by descending creation time. Pages are numbered from zero and hold at
+    # most 100 records, the default limit. Filters left out match every record.
+    type Query {
+        # Orders returns the spot orders placed by a user.
+        orders(user: Address!, baseToken: Address, quoteToken: Address, status: String, page: Int, limit: Int): [Order!]!
+        # Trades returns the spot trades in which a user is the taker or the maker.
+        trades(user: Address!, baseToken: Address, quoteToken: Address, status: String, page: Int, limit: Int): [Trade!]!
+        # LendingItems returns the lending items placed by a user.
+        lendingItems(user: Address!, lendingToken: Address, term: Long, status: String, relayer: Address, page: Int, limit: Int): [LendingItem!]!
+        # LendingTrades returns the lending positions in which a user is the borrower or the
+        # investor.
+        lendingTrades(user: Address!, lendingToken: Address, term: Long, status: String, relayer: Address, page: Int, limit: Int): [LendingTrade!]!
+        # Liquidations returns the liquidated lending positions in which a user is the borrower or
+        # the investor.
+        liquidations(user: Address!, lendingToken: Address, term: Long, relayer: Address, page: Int, limit: Int): [LendingTrade!]!
+    }
+`
@@ -0,0 +1,51 @@
+package graphql
+
+import (
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending"
+)
+
+// Service serves the GraphQL queries at /graphql on the HTTP endpoint of the node.
+type Service struct {
+	handler http.Handler
+}
+
+// New creates a GraphQL service over the records of the TomoX and lending services.
+func New(tomoX *tomox.TomoX, lending *tomoxlending.Lending) (*Service, error) {
+	resolver := &Resolver{
+		tomox:   tomox.NewPublicTomoXAPI(tomoX),
+		lending: tomoxlending.NewPublicTomoXLendingAPI(lending),
+	}
+	s, err := graphql.ParseSchema(schema, resolver)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{handler: &relay.Handler{Schema: s}}, nil
+}
+
+// Protocols returns the list of protocols exported by this service.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs returns the list of APIs exported by this service.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start is called after all services have been constructed and the networking
+// layer was also initialized to spawn any goroutines required by the service.
+func (s *Service) Start(server *p2p.Server) error { return nil }
+
+func (s *Service) SaveData() {}
+
+// Stop terminates all goroutines belonging to the service, blocking until they
+// are all terminated.
+func (s *Service) Stop() error { return nil }
+
+// HTTPHandlers returns the GraphQL handler, served on the HTTP endpoint of the node.
+func (s *Service) HTTPHandlers() map[string]http.Handler {
+	return map[string]http.Handler{"/graphql": s.handler}
+}
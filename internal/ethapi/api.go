@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/tomochain/tomochain/tomoxlending"
 	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
 	"math/big"
 	"sort"
@@ -1952,6 +1953,77 @@ func (s *PublicTomoXTransactionPoolAPI) GetOrderPoolContent(ctx context.Context)
 	}
 }
 
+// RPCOrderTransaction represents an order transaction of the pool that will serialize to the RPC
+// representation.
+type RPCOrderTransaction struct {
+	Hash            common.Hash    `json:"hash"`
+	Nonce           hexutil.Uint64 `json:"nonce"`
+	UserAddress     common.Address `json:"userAddress"`
+	ExchangeAddress common.Address `json:"exchangeAddress"`
+	BaseToken       common.Address `json:"baseToken"`
+	QuoteToken      common.Address `json:"quoteToken"`
+	Side            string         `json:"side"`
+	Type            string         `json:"type"`
+	Status          string         `json:"status"`
+	Price           *hexutil.Big   `json:"price"`
+	Quantity        *hexutil.Big   `json:"quantity"`
+	OrderHash       common.Hash    `json:"orderHash"`
+	OrderID         hexutil.Uint64 `json:"orderID"`
+	V               *hexutil.Big   `json:"v"`
+	R               *hexutil.Big   `json:"r"`
+	S               *hexutil.Big   `json:"s"`
+}
+
+func newRPCOrderTransaction(tx *types.OrderTransaction) *RPCOrderTransaction {
+	v, r, s := tx.Signature()
+	return &RPCOrderTransaction{
+		Hash:            tx.Hash(),
+		Nonce:           hexutil.Uint64(tx.Nonce()),
+		UserAddress:     tx.UserAddress(),
+		ExchangeAddress: tx.ExchangeAddress(),
+		BaseToken:       tx.BaseToken(),
+		QuoteToken:      tx.QuoteToken(),
+		Side:            tx.Side(),
+		Type:            tx.Type(),
+		Status:          tx.Status(),
+		Price:           (*hexutil.Big)(tx.Price()),
+		Quantity:        (*hexutil.Big)(tx.Quantity()),
+		OrderHash:       tx.OrderHash(),
+		OrderID:         hexutil.Uint64(tx.OrderID()),
+		V:               (*hexutil.Big)(v),
+		R:               (*hexutil.Big)(r),
+		S:               (*hexutil.Big)(s),
+	}
+}
+
+// PendingOrderTransactions returns the content of the order transaction pool like txpool_content:
+// the pending and queued transactions by account and nonce, optionally restricted to the orders
+// of a base token and of a quote token.
+func (s *PublicTomoXTransactionPoolAPI) PendingOrderTransactions(ctx context.Context, baseToken, quoteToken *common.Address) map[string]map[string]map[string]*RPCOrderTransaction {
+	content := map[string]map[string]map[string]*RPCOrderTransaction{
+		"pending": make(map[string]map[string]*RPCOrderTransaction),
+		"queued":  make(map[string]map[string]*RPCOrderTransaction),
+	}
+	pending, queued := s.b.OrderTxPoolContent()
+	flatten := func(txsByAccount map[common.Address]types.OrderTransactions, dump map[string]map[string]*RPCOrderTransaction) {
+		for account, txs := range txsByAccount {
+			txDump := make(map[string]*RPCOrderTransaction)
+			for _, tx := range txs {
+				if baseToken != nil && tx.BaseToken() != *baseToken || quoteToken != nil && tx.QuoteToken() != *quoteToken {
+					continue
+				}
+				txDump[fmt.Sprintf("%d", tx.Nonce())] = newRPCOrderTransaction(tx)
+			}
+			if len(txDump) > 0 {
+				dump[account.Hex()] = txDump
+			}
+		}
+	}
+	flatten(pending, content["pending"])
+	flatten(queued, content["queued"])
+	return content
+}
+
 // GetOrderStats return pending, queued length
 func (s *PublicTomoXTransactionPoolAPI) GetOrderStats(ctx context.Context) interface{} {
 	pending, queued := s.b.OrderStats()
@@ -2028,6 +2100,50 @@ func (s *PublicTomoXTransactionPoolAPI) SendOrder(ctx context.Context, msg Order
 	return submitOrderTransaction(ctx, s.b, tx)
 }
 
+// PrivateTomoXAPI provides the tomoX RPC methods signing with the accounts of the node,
+// which are not exposed publicly.
+type PrivateTomoXAPI struct {
+	b Backend
+}
+
+// NewPrivateTomoXAPI creates a new RPC service signing orders with the accounts of the node.
+func NewPrivateTomoXAPI(b Backend) *PrivateTomoXAPI {
+	return &PrivateTomoXAPI{b: b}
+}
+
+// SignAndSendOrder signs an order with the account of its user managed by the node and submits
+// it to the order pool. The account is an unlocked keystore account, or a hardware wallet which
+// displays the terms of the order before the user confirms it since TIPTomoXTextSigning. The
+// signature fields of the message are ignored, and a new order placed without a hash gets its
+// order hash.
+func (s *PrivateTomoXAPI) SignAndSendOrder(ctx context.Context, msg OrderMsg) (common.Hash, error) {
+	account := accounts.Account{Address: msg.UserAddress}
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	tx := types.NewOrderTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), msg.Price.ToInt(), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.OrderID))
+	if !tx.IsCancelledOrder() && tx.OrderHash() == (common.Hash{}) {
+		tx = types.NewOrderTransaction(tx.Nonce(), tx.Quantity(), tx.Price(), tx.ExchangeAddress(), tx.UserAddress(), tx.BaseToken(), tx.QuoteToken(), tx.Status(), tx.Side(), tx.Type(), types.OrderTxSigner{}.Hash(tx), tx.OrderID())
+	}
+	// the legacy signature is the signed message of the hash of the order, or since
+	// TIPTomoXTextSigning of its terms
+	signer := types.MakeOrderSigner(s.b.ChainConfig(), new(big.Int).Add(s.b.CurrentBlock().Number(), common.Big1))
+	text := types.OrderTxSigner{}.Hash(tx).Bytes()
+	if textSigner, ok := signer.(types.OrderTextSigner); ok {
+		text = []byte(textSigner.Text(tx))
+	}
+	sig, err := accounts.SignText(wallet, account, text)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	signed, err := tx.WithSignature(signer, sig)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return submitOrderTransaction(ctx, s.b, signed)
+}
+
 // SendLending will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTomoXTransactionPoolAPI) SendLending(ctx context.Context, msg LendingMsg) (common.Hash, error) {
@@ -2455,6 +2571,17 @@ func (s *PublicTomoXTransactionPoolAPI) GetLendingOrderCount(ctx context.Context
 	return (*hexutil.Uint64)(&nonce), err
 }
 
+// GetAccountSnapshot returns in a single response the open spot orders, open lending items and
+// open lending trades of an account at the current block, the token amounts they hold in escrow
+// and the health of the trades it borrowed.
+func (s *PublicTomoXTransactionPoolAPI) GetAccountSnapshot(ctx context.Context, addr common.Address) (*tomoxlending.AccountSnapshot, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	return lendingService.AccountSnapshot(addr)
+}
+
 func (s *PublicTomoXTransactionPoolAPI) GetBestInvesting(ctx context.Context, lendingToken common.Address, term uint64) (InterestVolume, error) {
 	result := InterestVolume{}
 	block := s.b.CurrentBlock()
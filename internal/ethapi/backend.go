@@ -119,6 +119,11 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicTomoXTransactionPoolAPI(apiBackend, nonceLock),
 			Public:    true,
+		}, {
+			Namespace: "tomox",
+			Version:   "1.0",
+			Service:   NewPrivateTomoXAPI(apiBackend),
+			Public:    false,
 		}, {
 			Namespace: "txpool",
 			Version:   "1.0",
@@ -689,6 +689,12 @@ web3._extend({
             params: 0
 		}),
 		new web3._extend.Method({
+            name: 'pendingOrderTransactions',
+            call: 'tomox_pendingOrderTransactions',
+            params: 2,
+            inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
             name: 'getOrderStats',
             call: 'tomox_getOrderStats',
             params: 0
@@ -697,6 +703,11 @@ web3._extend({
             name: 'getOrderCount',
             call: 'tomox_getOrderCount',
             params: 1
+        }),
+		new web3._extend.Method({
+            name: 'signAndSendOrder',
+            call: 'tomox_signAndSendOrder',
+            params: 1
         }),
 		new web3._extend.Method({
             name: 'getBestBid',
@@ -782,6 +793,11 @@ web3._extend({
             name: 'getLendingOrderCount',
             call: 'tomox_getLendingOrderCount',
             params: 1
+        }),
+		new web3._extend.Method({
+            name: 'getAccountSnapshot',
+            call: 'tomox_getAccountSnapshot',
+            params: 1
         }),
 		new web3._extend.Method({
             name: 'getBestInvesting',
@@ -885,6 +901,28 @@ web3._extend({
             name: 'getPendingOrders',
             call: 'tomoxlending_getPendingOrders',
             params: 1
+        }),
+		new web3._extend.Method({
+            name: 'reserveLendingNonce',
+            call: 'tomoxlending_reserveLendingNonce',
+            params: 1,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+        }),
+		new web3._extend.Method({
+            name: 'getLendingTypedData',
+            call: 'tomoxlending_getLendingTypedData',
+            params: 1
+        }),
+		new web3._extend.Method({
+            name: 'isOrderHashUsed',
+            call: 'tomoxlending_isOrderHashUsed',
+            params: 1
+        }),
+		new web3._extend.Method({
+            name: 'pendingLendingOrders',
+            call: 'tomoxlending_pendingLendingOrders',
+            params: 2,
+            inputFormatter: [null, null]
         }),
 		new web3._extend.Method({
             name: 'getAllPendingHashes',
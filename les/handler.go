@@ -106,6 +106,7 @@ type ProtocolManager struct {
 	lesTopic    discv5.Topic
 	reqDist     *requestDistributor
 	retriever   *retrieveManager
+	tomoXStates *tomoXStates // nil unless the node serves the TomoX states
 
 	downloader *downloader.Downloader
 	fetcher    *lightFetcher
@@ -331,7 +332,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 }
 
-var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg}
+var reqList = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg, GetTomoXProofsMsg}
 
 // handleMsg is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
@@ -796,6 +797,46 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
 		return p.SendProofsV2(req.ReqID, bv, nodes.NodeList())
 
+	case GetTomoXProofsMsg:
+		p.Log().Trace("Received TomoX proofs request")
+		if pm.tomoXStates == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+		// Decode the retrieval message
+		var req struct {
+			ReqID uint64
+			Reqs  []TomoXProofReq
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		reqCnt := len(req.Reqs)
+		if reject(uint64(reqCnt), MaxProofsFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		nodes := light.NewNodeSet()
+
+		for _, req := range req.Reqs {
+			block := core.GetBlock(pm.chainDb, req.BHash, core.GetBlockNumber(pm.chainDb, req.BHash))
+			if block == nil {
+				continue
+			}
+			triedb, root, err := pm.tomoXStates.stateRoot(block, req.Trie)
+			if err != nil {
+				continue
+			}
+			if err := proveTomoXTrie(triedb, root, req, nodes); err != nil {
+				p.Log().Debug("Failed to prove TomoX trie", "block", req.BHash, "book", req.Book, "err", err)
+				continue
+			}
+			if nodes.DataSize() >= softResponseLimit {
+				break
+			}
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendTomoXProofs(req.ReqID, bv, nodes.NodeList())
+
 	case ProofsV1Msg:
 		if pm.odr == nil {
 			return errResp(ErrUnexpectedResponse, "")
@@ -1040,6 +1081,27 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		return p.SendTxStatus(req.ReqID, bv, stats)
 
+	case TomoXProofsMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received TomoX proofs response")
+		// A batch of merkle proofs arrived to one of our previous requests
+		var resp struct {
+			ReqID, BV uint64
+			Data      light.NodeList
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgTomoXProofs,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Data,
+		}
+
 	case GetTxStatusMsg:
 		if pm.txpool == nil {
 			return errResp(ErrUnexpectedResponse, "")
@@ -68,6 +68,8 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*ChtRequest)(r)
 	case *light.BloomRequest:
 		return (*BloomRequest)(r)
+	case *light.TomoXTrieRequest:
+		return (*TomoXTrieRequest)(r)
 	default:
 		return nil
 	}
@@ -193,7 +195,7 @@ func (r *TrieRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetProofsV1Msg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetProofsV2Msg, 1)
 	default:
 		panic(nil)
@@ -256,6 +258,71 @@ func (r *TrieRequest) Validate(db ethdb.Database, msg *Msg) error {
 	}
 }
 
+// TomoXProofReq requests the proof of a key of a trie of an orderbook or of a lending book, see
+// light.TomoXTrieID. The proof covers the path to the book in the state trie, and the path to
+// the key in the book trie from FromLevel.
+type TomoXProofReq struct {
+	BHash     common.Hash
+	Trie      uint
+	Book      common.Hash
+	BookTrie  uint
+	Key       []byte
+	FromLevel uint
+}
+
+// ODR request type for the trading and lending trie entries, see LesOdrRequest interface
+type TomoXTrieRequest light.TomoXTrieRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *TomoXTrieRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetTomoXProofsMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *TomoXTrieRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv3 && peer.HasBlock(r.Id.BlockHash, r.Id.BlockNumber)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *TomoXTrieRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting TomoX trie proof", "root", r.Id.Root, "book", r.Id.Book, "key", r.Key)
+	req := TomoXProofReq{
+		BHash:    r.Id.BlockHash,
+		Trie:     r.Id.Trie,
+		Book:     r.Id.Book,
+		BookTrie: r.Id.BookTrie,
+		Key:      r.Key,
+	}
+	return peer.RequestTomoXProofs(reqID, r.GetCost(peer), []TomoXProofReq{req})
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *TomoXTrieRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating TomoX trie proof", "root", r.Id.Root, "book", r.Id.Book, "key", r.Key)
+
+	if msg.MsgType != MsgTomoXProofs {
+		return errInvalidMessageType
+	}
+	proofs := msg.Obj.(light.NodeList)
+	// Verify the proof and store if checks out
+	nodeSet := proofs.NodeSet()
+	reads := &readTraceDB{db: nodeSet}
+	value, err := light.VerifyTomoXProof(r.Id, r.Key, reads)
+	if err != nil {
+		return fmt.Errorf("merkle proof verification failed: %v", err)
+	}
+	// check if all nodes have been read by VerifyTomoXProof
+	if len(reads.reads) != nodeSet.KeyCount() {
+		return errUselessNodes
+	}
+	r.Proof = nodeSet
+	r.Value = value
+	return nil
+}
+
 type CodeReq struct {
 	BHash  common.Hash
 	AccKey []byte
@@ -353,7 +420,7 @@ func (r *ChtRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetHeaderProofsMsg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetHelperTrieProofsMsg, 1)
 	default:
 		panic(nil)
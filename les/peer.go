@@ -221,6 +221,11 @@ func (p *peer) SendProofsV2(reqID, bv uint64, proofs light.NodeList) error {
 	return sendResponse(p.rw, ProofsV2Msg, reqID, bv, proofs)
 }
 
+// SendTomoXProofs sends a batch of trading and lending trie proofs, corresponding to the ones requested.
+func (p *peer) SendTomoXProofs(reqID, bv uint64, proofs light.NodeList) error {
+	return sendResponse(p.rw, TomoXProofsMsg, reqID, bv, proofs)
+}
+
 // SendHeaderProofs sends a batch of legacy LES/1 header proofs, corresponding to the ones requested.
 func (p *peer) SendHeaderProofs(reqID, bv uint64, proofs []ChtResp) error {
 	return sendResponse(p.rw, HeaderProofsMsg, reqID, bv, proofs)
@@ -276,13 +281,19 @@ func (p *peer) RequestProofs(reqID, cost uint64, reqs []ProofReq) error {
 	switch p.version {
 	case lpv1:
 		return sendRequest(p.rw, GetProofsV1Msg, reqID, cost, reqs)
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, GetProofsV2Msg, reqID, cost, reqs)
 	default:
 		panic(nil)
 	}
 }
 
+// RequestTomoXProofs fetches a batch of trading and lending trie proofs from a remote node.
+func (p *peer) RequestTomoXProofs(reqID, cost uint64, reqs []TomoXProofReq) error {
+	p.Log().Debug("Fetching batch of TomoX proofs", "count", len(reqs))
+	return sendRequest(p.rw, GetTomoXProofsMsg, reqID, cost, reqs)
+}
+
 // RequestHelperTrieProofs fetches a batch of HelperTrie merkle proofs from a remote node.
 func (p *peer) RequestHelperTrieProofs(reqID, cost uint64, reqs []HelperTrieReq) error {
 	p.Log().Debug("Fetching batch of HelperTrie proofs", "count", len(reqs))
@@ -298,7 +309,7 @@ func (p *peer) RequestHelperTrieProofs(reqID, cost uint64, reqs []HelperTrieReq)
 			reqsV1[i] = ChtReq{ChtNum: (req.TrieIdx + 1) * (light.CHTFrequencyClient / light.CHTFrequencyServer), BlockNum: blockNum, FromLevel: req.FromLevel}
 		}
 		return sendRequest(p.rw, GetHeaderProofsMsg, reqID, cost, reqsV1)
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, GetHelperTrieProofsMsg, reqID, cost, reqs)
 	default:
 		panic(nil)
@@ -317,7 +328,7 @@ func (p *peer) SendTxs(reqID, cost uint64, txs types.Transactions) error {
 	switch p.version {
 	case lpv1:
 		return p2p.Send(p.rw, SendTxMsg, txs) // old message format does not include reqID
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, SendTxV2Msg, reqID, cost, txs)
 	default:
 		panic(nil)
@@ -37,17 +37,18 @@ import (
 const (
 	lpv1 = 1
 	lpv2 = 2
+	lpv3 = 3
 )
 
 // Supported versions of the les protocol (first is primary)
 var (
-	ClientProtocolVersions    = []uint{lpv2, lpv1}
-	ServerProtocolVersions    = []uint{lpv2, lpv1}
+	ClientProtocolVersions    = []uint{lpv3, lpv2, lpv1}
+	ServerProtocolVersions    = []uint{lpv3, lpv2, lpv1}
 	AdvertiseProtocolVersions = []uint{lpv2} // clients are searching for the first advertised protocol in the list
 )
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = map[uint]uint64{lpv1: 15, lpv2: 22}
+var ProtocolLengths = map[uint]uint64{lpv1: 15, lpv2: 22, lpv3: 24}
 
 const (
 	NetworkId          = 1
@@ -80,6 +81,9 @@ const (
 	SendTxV2Msg            = 0x13
 	GetTxStatusMsg         = 0x14
 	TxStatusMsg            = 0x15
+	// Protocol messages belonging to LPV3
+	GetTomoXProofsMsg = 0x16
+	TomoXProofsMsg    = 0x17
 )
 
 type errCode int
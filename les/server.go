@@ -56,6 +56,8 @@ func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 		return nil, err
 	}
 
+	pm.tomoXStates = &tomoXStates{engine: eth.Engine(), tomoX: eth.GetTomoX(), lending: eth.GetTomoXLending()}
+
 	lesTopics := make([]discv5.Topic, len(AdvertiseProtocolVersions))
 	for i, pv := range AdvertiseProtocolVersions {
 		lesTopics[i] = lesTopic(eth.BlockChain().Genesis().Hash(), pv)
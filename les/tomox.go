@@ -0,0 +1,75 @@
+package les
+
+import (
+	"errors"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/light"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/trie"
+)
+
+var errNoTomoXState = errors.New("TomoX state not served")
+
+// tomoXStates resolves the trading and lending states committed by the blocks, which a server
+// proves to the light clients.
+type tomoXStates struct {
+	engine  consensus.Engine
+	tomoX   *tomox.TomoX
+	lending *tomoxlending.Lending
+}
+
+// stateRoot returns the trie database and the root of the trading or lending state committed by
+// a block.
+func (s *tomoXStates) stateRoot(block *types.Block, stateTrie uint) (*trie.Database, common.Hash, error) {
+	author, err := s.engine.Author(block.Header())
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	switch {
+	case stateTrie == light.TradingTrie && s.tomoX != nil:
+		root, err := s.tomoX.GetTradingStateRoot(block, author)
+		return s.tomoX.GetStateCache().TrieDB(), root, err
+	case stateTrie == light.LendingTrie && s.lending != nil:
+		root, err := s.lending.GetLendingStateRoot(block, author)
+		return s.lending.GetStateCache().TrieDB(), root, err
+	default:
+		return nil, common.Hash{}, errNoTomoXState
+	}
+}
+
+// proveTomoXTrie writes to nodes the proof of a key of a TomoX trie: the path to the book in the
+// state trie of the given root, then the path to the key in the book trie. A key of the state
+// trie itself is proven if the book is empty.
+func proveTomoXTrie(triedb *trie.Database, root common.Hash, req TomoXProofReq, nodes *light.NodeSet) error {
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	if req.Book == (common.Hash{}) {
+		return tr.Prove(req.Key, req.FromLevel, nodes)
+	}
+	if err := tr.Prove(req.Book[:], 0, nodes); err != nil {
+		return err
+	}
+	enc, err := tr.TryGet(req.Book[:])
+	if err != nil || enc == nil {
+		return err
+	}
+	bookRoot, err := light.TomoXBookTrieRoot(req.Trie, enc, req.BookTrie)
+	if err != nil {
+		return err
+	}
+	if bookRoot == (common.Hash{}) || bookRoot == types.EmptyRootHash {
+		// the absence of the key follows from the empty book trie
+		return nil
+	}
+	bookTrie, err := trie.New(bookRoot, triedb)
+	if err != nil {
+		return err
+	}
+	return bookTrie.Prove(req.Key, req.FromLevel, nodes)
+}
@@ -0,0 +1,66 @@
+package les
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/light"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestProveTomoXTrie(t *testing.T) {
+	lendingBook := lendingstate.GetLendingOrderBookHash(common.HexToAddress("0x1"), 60)
+	stateCache := lendingstate.NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := lendingstate.New(common.Hash{}, stateCache)
+	item := lendingstate.LendingItem{LendingId: 1, Quantity: big.NewInt(10), Interest: big.NewInt(5), Side: lendingstate.Investing,
+		UserAddress: common.HexToAddress("0x2"), Signature: &lendingstate.Signature{V: 27}}
+	statedb.InsertLendingItem(lendingBook, common.Uint64ToHash(1), item)
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+
+	prove := func(book common.Hash, bookTrie uint, key []byte) (*light.NodeSet, *light.TomoXTrieID) {
+		nodes := light.NewNodeSet()
+		req := TomoXProofReq{Trie: light.LendingTrie, Book: book, BookTrie: bookTrie, Key: key}
+		if err := proveTomoXTrie(stateCache.TrieDB(), root, req, nodes); err != nil {
+			t.Fatalf("failed to prove key %x: %v", key, err)
+		}
+		return nodes, &light.TomoXTrieID{Trie: light.LendingTrie, Root: root, Book: book, BookTrie: bookTrie}
+	}
+	// open lending item
+	nodes, id := prove(lendingBook, lendingstate.LendingItemTrie, common.Uint64ToHash(1).Bytes())
+	value, err := light.VerifyTomoXProof(id, common.Uint64ToHash(1).Bytes(), nodes)
+	if err != nil {
+		t.Fatalf("failed to verify lending item proof: %v", err)
+	}
+	var proven lendingstate.LendingItem
+	if err := rlp.DecodeBytes(value, &proven); err != nil {
+		t.Fatalf("failed to decode proven lending item: %v", err)
+	}
+	if proven.UserAddress != item.UserAddress || proven.Quantity.Cmp(item.Quantity) != 0 {
+		t.Fatalf("wrong proven lending item: %v", lendingstate.ToJSON(&proven))
+	}
+	// the proof doesn't hold for another root
+	if _, err := light.VerifyTomoXProof(&light.TomoXTrieID{Trie: light.LendingTrie, Root: common.HexToHash("0x3"), Book: lendingBook}, common.Uint64ToHash(1).Bytes(), nodes); err == nil {
+		t.Error("proof verified against another root")
+	}
+	// missing item, empty book trie and missing book are proven absent
+	for _, test := range []struct {
+		book     common.Hash
+		bookTrie uint
+		key      []byte
+	}{
+		{lendingBook, lendingstate.LendingItemTrie, common.Uint64ToHash(2).Bytes()},
+		{lendingBook, lendingstate.LendingTradeTrie, common.Uint64ToHash(1).Bytes()},
+		{lendingstate.GetLendingOrderBookHash(common.HexToAddress("0x1"), 30), lendingstate.LendingItemTrie, common.Uint64ToHash(1).Bytes()},
+	} {
+		nodes, id := prove(test.book, test.bookTrie, test.key)
+		if value, err := light.VerifyTomoXProof(id, test.key, nodes); err != nil || value != nil {
+			t.Errorf("book %x, trie %d, key %x: have value %x, err %v, want absent", test.book, test.bookTrie, test.key, value, err)
+		}
+	}
+}
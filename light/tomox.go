@@ -0,0 +1,110 @@
+package light
+
+import (
+	"context"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// TomoX state tries of TomoXTrieID
+const (
+	TradingTrie = iota // trading state of TomoX
+	LendingTrie        // lending state of TomoX lending
+)
+
+// TomoXTrieID identifies a trie of an orderbook or of a lending book in the trading or lending
+// state committed by a block, or the state trie itself if Book is empty. BookTrie selects the
+// trie of the book, see tradingstate.OrderBookTrieRoot and lendingstate.LendingBookTrieRoot.
+type TomoXTrieID struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Trie        uint        // TradingTrie or LendingTrie
+	Root        common.Hash // trading or lending state root committed by the block
+	Book        common.Hash // orderbook or lending book
+	BookTrie    uint
+}
+
+// TomoXTrieRequest is the ODR request type for the entries of the trading and lending tries.
+// The proof covers the path from the state root to the book and from the root of the book trie
+// to the key, Value is the proven value, nil if the key is absent.
+type TomoXTrieRequest struct {
+	OdrRequest
+	Id    *TomoXTrieID
+	Key   []byte
+	Proof *NodeSet
+	Value []byte
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *TomoXTrieRequest) StoreResult(db ethdb.Database) {
+	req.Proof.Store(db)
+}
+
+// VerifyTomoXProof checks a proof of a key of a TomoX trie against the state root of the trie
+// id, and returns the value of the key, nil if the key or the book is absent.
+func VerifyTomoXProof(id *TomoXTrieID, key []byte, proofDb ethdb.KeyValueReader) ([]byte, error) {
+	if id.Book == (common.Hash{}) {
+		return trie.VerifyProof(id.Root, key, proofDb)
+	}
+	enc, err := trie.VerifyProof(id.Root, id.Book[:], proofDb)
+	if err != nil || enc == nil {
+		return nil, err
+	}
+	root, err := TomoXBookTrieRoot(id.Trie, enc, id.BookTrie)
+	if err != nil {
+		return nil, err
+	}
+	if root == (common.Hash{}) || root == types.EmptyRootHash {
+		return nil, nil
+	}
+	return trie.VerifyProof(root, key, proofDb)
+}
+
+// TomoXBookTrieRoot returns the root of a trie of a book encoded in a trading or lending state trie.
+func TomoXBookTrieRoot(stateTrie uint, enc []byte, bookTrie uint) (common.Hash, error) {
+	if stateTrie == LendingTrie {
+		return lendingstate.LendingBookTrieRoot(enc, bookTrie)
+	}
+	return tradingstate.OrderBookTrieRoot(enc, bookTrie)
+}
+
+// GetTomoXStateRoots retrieves the trading and lending state roots committed by a block, which
+// are the data of the transaction sent by the block author to the trading state address. The
+// author is recovered from the header by the consensus engine.
+func GetTomoXStateRoots(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64, author common.Address) (common.Hash, common.Hash, error) {
+	block, err := GetBlock(ctx, odr, hash, number)
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	for _, tx := range block.Transactions() {
+		if tx.To() == nil || tx.To().Hex() != common.TradingStateAddr || len(tx.Data()) < 32 {
+			continue
+		}
+		if from := tx.From(); from == nil || *from != author {
+			continue
+		}
+		// the blocks before the lending fork only commit the trading state
+		lendingRoot := lendingstate.EmptyRoot
+		if len(tx.Data()) >= 64 {
+			lendingRoot = common.BytesToHash(tx.Data()[32:64])
+		}
+		return common.BytesToHash(tx.Data()[:32]), lendingRoot, nil
+	}
+	return tradingstate.EmptyRoot, lendingstate.EmptyRoot, nil
+}
+
+// GetTomoXTrieValue retrieves the value of a key of a TomoX trie, verified against the state
+// root of the trie id. It returns nil if the key is absent. The values are the RLP encodings of
+// the state objects, e.g. a lendingstate.LendingTrade in the LendingTradeTrie of a lending book.
+func GetTomoXTrieValue(ctx context.Context, odr OdrBackend, id *TomoXTrieID, key []byte) ([]byte, error) {
+	r := &TomoXTrieRequest{Id: id, Key: key}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	return r.Value, nil
+}
@@ -91,6 +91,9 @@ type Work struct {
 	uncles       mapset.Set // uncle set
 	tcount       int        // tx count in cycle
 
+	tradingWitness *tradingstate.WitnessDatabase // records the witness of the block, if the node does
+	lendingWitness *lendingstate.WitnessDatabase
+
 	Block *types.Block // the new block
 
 	header   *types.Header
@@ -364,6 +367,11 @@ func (self *worker) wait() {
 				log.Error("Failed writing block to chain", "err", err)
 				continue
 			}
+			if work.tradingWitness != nil && work.lendingWitness != nil {
+				if err := self.eth.GetTomoX().WriteBlockWitness(block.Hash(), work.tradingWitness.Witness(), work.lendingWitness.Witness()); err != nil {
+					log.Warn("Failed writing block witness", "number", block.Number(), "hash", block.Hash(), "err", err)
+				}
+			}
 			// check if canon block and write transactions
 			if stat == core.CanonStatTy {
 				// implicit by posting ChainHeadEvent
@@ -457,15 +465,25 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 	author, _ := self.chain.Engine().Author(parent.Header())
 	var tomoxState *tradingstate.TradingStateDB
 	var lendingState *lendingstate.LendingStateDB
+	var tradingWitness *tradingstate.WitnessDatabase
+	var lendingWitness *lendingstate.WitnessDatabase
 	if self.config.Posv != nil {
 		tomoX := self.eth.GetTomoX()
-		tomoxState, err = tomoX.GetTradingState(parent, author)
+		lending := self.eth.GetTomoXLending()
+		if tomoX.RecordsWitness() {
+			tomoxState, tradingWitness, err = tomoX.GetWitnessedTradingState(parent, author)
+		} else {
+			tomoxState, err = tomoX.GetTradingState(parent, author)
+		}
 		if err != nil {
 			log.Error("Failed to get tomox state ", "number", parent.Number(), "err", err)
 			return err
 		}
-		lending := self.eth.GetTomoXLending()
-		lendingState, err = lending.GetLendingState(parent, author)
+		if tomoX.RecordsWitness() {
+			lendingState, lendingWitness, err = lending.GetWitnessedLendingState(parent, author)
+		} else {
+			lendingState, err = lending.GetLendingState(parent, author)
+		}
 		if err != nil {
 			log.Error("Failed to get lending state ", "number", parent.Number(), "err", err)
 			return err
@@ -485,6 +503,7 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 		header:       header,
 		createdAt:    time.Now(),
 	}
+	work.tradingWitness, work.lendingWitness = tradingWitness, lendingWitness
 
 	if self.config.Posv == nil {
 		// when 08 is processed ancestors contain 07 (quick block)
@@ -674,6 +693,10 @@ func (self *worker) commitNewWork() {
 					lendingOrderPending, _ := self.eth.LendingPool().Pending()
 					lendingInput, lendingMatchingResults = tomoXLending.ProcessOrderPending(header, self.coinbase, self.chain, lendingOrderPending, work.state, work.lendingState, work.tradingState)
 					log.Debug("lending transaction matches found", "lendingInput", len(lendingInput), "lendingMatchingResults", len(lendingMatchingResults))
+					if _, err := tomoXLending.SweepExpiredLendingItems(header, self.chain, work.state, work.lendingState); err != nil {
+						log.Error("Fail when sweep expired lending items", "error", err)
+						return
+					}
 					if header.Number.Uint64()%self.config.Posv.Epoch == common.LiquidateLendingTradeBlock {
 						updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err = tomoXLending.ProcessLiquidationData(header, self.chain, work.state, work.tradingState, work.lendingState)
 						if err != nil {
@@ -701,7 +724,7 @@ func (self *worker) commitNewWork() {
 						return
 					} else {
 						tradingTransaction = txM
-						if tomoX.IsSDKNode() {
+						if tomoX.IsSDKNode() || tomoX.HasCandleIndex() {
 							self.chain.AddMatchingResult(tradingTransaction.Hash(), tradingMatchingResults)
 						}
 					}
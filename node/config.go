@@ -145,6 +145,18 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// RPCAuthNamespaces is a list of API modules whose HTTP and websocket calls must
+	// carry one of the RPCAuthTokens as bearer token in their Authorization header.
+	RPCAuthNamespaces []string `toml:",omitempty"`
+	RPCAuthTokens     []string `toml:",omitempty"`
+
+	// RPCRateLimitNamespaces is a list of API modules whose HTTP and websocket calls
+	// are limited to RPCRateLimit calls per second for each client IP, in bursts of at
+	// most RPCRateBurst calls. The limit is shared by the modules and the endpoints.
+	RPCRateLimitNamespaces []string `toml:",omitempty"`
+	RPCRateLimit           float64  `toml:",omitempty"`
+	RPCRateBurst           int      `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
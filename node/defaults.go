@@ -41,6 +41,8 @@ var DefaultConfig = Config{
 	HTTPVirtualHosts: []string{"localhost"},
 	WSPort:           DefaultWSPort,
 	WSModules:        []string{"net", "web3"},
+	RPCRateLimit:     10,
+	RPCRateBurst:     20,
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
 		MaxPeers:   25,
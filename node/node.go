@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"github.com/tomochain/tomochain/core/rawdb"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -59,15 +60,18 @@ type Node struct {
 	ipcListener net.Listener // IPC RPC listener socket to serve API requests
 	ipcHandler  *rpc.Server  // IPC RPC request handler to process the API requests
 
-	httpEndpoint  string       // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
-	httpWhitelist []string     // HTTP RPC modules to allow through this endpoint
-	httpListener  net.Listener // HTTP RPC listener socket to server API requests
-	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
+	httpEndpoint  string                  // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
+	httpWhitelist []string                // HTTP RPC modules to allow through this endpoint
+	httpListener  net.Listener            // HTTP RPC listener socket to server API requests
+	httpHandler   *rpc.Server             // HTTP RPC request handler to process the API requests
+	httpServices  map[string]http.Handler // HTTP handlers of the services, served next to the API requests
 
 	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	rpcRateLimit *rpc.RateLimitGuard // Call budgets of the clients of the rate limited API modules
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 
@@ -253,9 +257,16 @@ func (n *Node) openDataDir() error {
 func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	// Gather all the possible APIs to surface
 	apis := n.apis()
+	handlers := make(map[string]http.Handler)
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
+		if service, ok := service.(HTTPService); ok {
+			for path, handler := range service.HTTPHandlers() {
+				handlers[path] = handler
+			}
+		}
 	}
+	n.httpServices = handlers
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -386,6 +397,9 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 			n.log.Debug("HTTP registered", "service", api.Service, "namespace", api.Namespace)
 		}
 	}
+	if err := n.guardRPC(handler); err != nil {
+		return err
+	}
 	// All APIs registered, start the HTTP listener
 	var (
 		listener net.Listener
@@ -394,7 +408,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, vhosts, handler).Serve(listener)
+	go rpc.NewHTTPServerWithHandlers(cors, vhosts, handler, n.httpServices).Serve(listener)
 	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
@@ -404,6 +418,33 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	return nil
 }
 
+// guardRPC restricts the calls of a public RPC endpoint to the authenticated API modules
+// to the clients presenting a token, and the calls to the rate limited ones to the budget
+// of their client.
+func (n *Node) guardRPC(handler *rpc.Server) error {
+	if len(n.config.RPCAuthNamespaces) > 0 {
+		if len(n.config.RPCAuthTokens) == 0 {
+			return errors.New("no RPC authentication token")
+		}
+		guard := rpc.NewTokenGuard(n.config.RPCAuthTokens)
+		for _, namespace := range n.config.RPCAuthNamespaces {
+			handler.AddGuard(namespace, guard)
+		}
+	}
+	if len(n.config.RPCRateLimitNamespaces) > 0 {
+		if n.config.RPCRateLimit <= 0 {
+			return errors.New("no RPC rate limit")
+		}
+		if n.rpcRateLimit == nil {
+			n.rpcRateLimit = rpc.NewRateLimitGuard(n.config.RPCRateLimit, n.config.RPCRateBurst)
+		}
+		for _, namespace := range n.config.RPCRateLimitNamespaces {
+			handler.AddGuard(namespace, n.rpcRateLimit)
+		}
+	}
+	return nil
+}
+
 // stopHTTP terminates the HTTP RPC endpoint.
 func (n *Node) stopHTTP() {
 	if n.httpListener != nil {
@@ -439,6 +480,9 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 			n.log.Debug("WebSocket registered", "service", api.Service, "namespace", api.Namespace)
 		}
 	}
+	if err := n.guardRPC(handler); err != nil {
+		return err
+	}
 	// All APIs registered, start the HTTP listener
 	var (
 		listener net.Listener
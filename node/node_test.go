@@ -19,8 +19,10 @@ package node
 import (
 	"errors"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -573,3 +575,48 @@ func TestAPIGather(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the authenticated API modules of the HTTP endpoint refuse the calls without token.
+func TestRPCAuthGuard(t *testing.T) {
+	config := testNodeConfig()
+	config.HTTPHost = "127.0.0.1"
+	config.RPCAuthNamespaces = []string{"single"}
+	config.RPCAuthTokens = []string{"secret"}
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	calls := make(chan string, 2)
+	apis := []rpc.API{{Namespace: "single", Version: "1", Service: &OneMethodApi{fun: func() { calls <- "single" }}, Public: true}}
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return &InstrumentedService{apis: apis}, nil }); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	call := func(token string) string {
+		req, _ := http.NewRequest(http.MethodPost, "http://"+stack.httpListener.Addr().String(), strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"single_theOneMethod","params":[]}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	if resp := call(""); !strings.Contains(resp, rpc.ErrUnauthorized.Error()) {
+		t.Fatalf("call without token served: %s", resp)
+	}
+	if resp := call("secret"); strings.Contains(resp, "error") {
+		t.Fatalf("call with the token refused: %s", resp)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("wrong number of served calls: have %d, want 1", len(calls))
+	}
+}
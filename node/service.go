@@ -18,6 +18,7 @@ package node
 
 import (
 	"github.com/tomochain/tomochain/core/rawdb"
+	"net/http"
 	"reflect"
 
 	"github.com/tomochain/tomochain/accounts"
@@ -103,3 +104,10 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// HTTPService is implemented by the services serving plain HTTP handlers, next to the RPC APIs,
+// on the HTTP endpoint of the node.
+type HTTPService interface {
+	// HTTPHandlers retrieves the HTTP handlers the service provides, by path.
+	HTTPHandlers() map[string]http.Handler
+}
@@ -273,6 +273,87 @@ func (c *ChainConfig) IsTIPTomoXCancellationFee(num *big.Int) bool {
 	return isForked(common.TIPTomoXCancellationFee, num)
 }
 
+func (c *ChainConfig) IsTIPTomoXLendingV2(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingV2, num)
+}
+
+// IsTIPTomoXLendingEIP712 returns whether lending transactions can be signed as EIP-712 typed data.
+func (c *ChainConfig) IsTIPTomoXLendingEIP712(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingEIP712, num)
+}
+
+// IsTIPTomoXTextSigning returns whether order and lending transactions can be signed as Ethereum
+// signed messages of their human-readable terms.
+func (c *ChainConfig) IsTIPTomoXTextSigning(num *big.Int) bool {
+	return isForked(common.TIPTomoXTextSigning, num)
+}
+
+// IsTIPTomoXLendingReplayProtection returns whether the hashes of the created lending items are
+// recorded in the lending state, and the items reusing a recorded hash rejected.
+func (c *ChainConfig) IsTIPTomoXLendingReplayProtection(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingReplayProtection, num)
+}
+
+// IsTIPTomoXStopOrders returns whether stop-loss and take-profit orders can be placed on the
+// TomoX order books.
+func (c *ChainConfig) IsTIPTomoXStopOrders(num *big.Int) bool {
+	return isForked(common.TIPTomoXStopOrders, num)
+}
+
+// IsTIPTomoXTrailingStopOrders returns whether trailing stop orders can be placed on the TomoX
+// order books.
+func (c *ChainConfig) IsTIPTomoXTrailingStopOrders(num *big.Int) bool {
+	return isForked(common.TIPTomoXTrailingStopOrders, num)
+}
+
+// IsTIPTomoXPostOnly returns whether post-only orders and lending items, rejected instead of
+// matching a resting order, can be placed.
+func (c *ChainConfig) IsTIPTomoXPostOnly(num *big.Int) bool {
+	return isForked(common.TIPTomoXPostOnly, num)
+}
+
+// IsTIPTomoXFeeTiers returns whether the trading fees follow the fee tiers set by the relayers,
+// based on the volume traded by each user, instead of the flat relayer fee.
+func (c *ChainConfig) IsTIPTomoXFeeTiers(num *big.Int) bool {
+	return isForked(common.TIPTomoXFeeTiers, num)
+}
+
+// IsTIPTomoXMinNotional returns whether the orders of a pair below its minimum notional are
+// rejected and the dust left by partial fills is cancelled.
+func (c *ChainConfig) IsTIPTomoXMinNotional(num *big.Int) bool {
+	return isForked(common.TIPTomoXMinNotional, num)
+}
+
+// IsTIPTomoXMargin returns whether margin items, borrowing part of the spot order they place, can
+// be processed.
+func (c *ChainConfig) IsTIPTomoXMargin(num *big.Int) bool {
+	return isForked(common.TIPTomoXMargin, num)
+}
+
+// IsTIPTomoXPeriodicInterest returns whether lending books can settle the interest of their trades
+// every epoch instead of at close.
+func (c *ChainConfig) IsTIPTomoXPeriodicInterest(num *big.Int) bool {
+	return isForked(common.TIPTomoXPeriodicInterest, num)
+}
+
+// IsTIPTomoXPriceOracle returns whether collateral tokens can be priced for liquidations by the
+// price oracles set by the lending moderator.
+func (c *ChainConfig) IsTIPTomoXPriceOracle(num *big.Int) bool {
+	return isForked(common.TIPTomoXPriceOracle, num)
+}
+
+// IsTIPTomoXTwap returns whether the trades matched by tomox are recorded in the time and volume
+// weighted average prices of their pair by epoch.
+func (c *ChainConfig) IsTIPTomoXTwap(num *big.Int) bool {
+	return isForked(common.TIPTomoXTwap, num)
+}
+
+// IsTIPTomoXPriceBand returns whether the limit orders priced outside the price band of their pair
+// are rejected.
+func (c *ChainConfig) IsTIPTomoXPriceBand(num *big.Int) bool {
+	return isForked(common.TIPTomoXPriceBand, num)
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
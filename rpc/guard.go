@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Guards restrict the calls to the methods and subscriptions of a namespace, so that a node can
+// expose APIs such as the order submission of a relayer to the public. Only the HTTP and
+// websocket requests carry the information of their client: the in-process and IPC calls are
+// never refused.
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limit exceeded")
+)
+
+// rateLimitSweepInterval is the interval between the removals of the idle clients of a
+// RateLimitGuard.
+const rateLimitSweepInterval = time.Minute
+
+// PeerInfo describes the client of an HTTP or websocket request.
+type PeerInfo struct {
+	RemoteAddr string // address of the client connection
+	Token      string // bearer token of the Authorization header, empty if none
+}
+
+type peerInfoKey struct{}
+
+// PeerInfoFromContext returns the client of a request, if it was received over HTTP or a
+// websocket.
+func PeerInfoFromContext(ctx context.Context) (PeerInfo, bool) {
+	info, ok := ctx.Value(peerInfoKey{}).(PeerInfo)
+	return info, ok
+}
+
+// peerContext returns a context holding the client of an HTTP request.
+func peerContext(r *http.Request) context.Context {
+	info := PeerInfo{RemoteAddr: r.RemoteAddr}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		info.Token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return context.WithValue(context.Background(), peerInfoKey{}, info)
+}
+
+// Guard decides whether the calls of a client are served.
+type Guard interface {
+	// Allow returns the error the call of a client is refused with, nil to serve it.
+	Allow(peer PeerInfo) error
+}
+
+// AddGuard restricts the calls to the methods and subscriptions of a namespace to the ones
+// allowed by the guard. A call is served if all the guards of its namespace allow it.
+func (s *Server) AddGuard(namespace string, guard Guard) {
+	s.guardsMu.Lock()
+	defer s.guardsMu.Unlock()
+
+	if s.guards == nil {
+		s.guards = make(map[string][]Guard)
+	}
+	s.guards[namespace] = append(s.guards[namespace], guard)
+}
+
+// checkGuards returns the error a call to a namespace is refused with, if any.
+func (s *Server) checkGuards(ctx context.Context, namespace string) error {
+	peer, ok := PeerInfoFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	s.guardsMu.RLock()
+	guards := s.guards[namespace]
+	s.guardsMu.RUnlock()
+
+	for _, guard := range guards {
+		if err := guard.Allow(peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TokenGuard allows the clients presenting one of its tokens as bearer token.
+type TokenGuard struct {
+	tokens [][]byte
+}
+
+// NewTokenGuard creates a guard accepting the given bearer tokens.
+func NewTokenGuard(tokens []string) *TokenGuard {
+	g := &TokenGuard{}
+	for _, token := range tokens {
+		g.tokens = append(g.tokens, []byte(token))
+	}
+	return g
+}
+
+// Allow implements Guard.
+func (g *TokenGuard) Allow(peer PeerInfo) error {
+	if peer.Token == "" {
+		return ErrUnauthorized
+	}
+	token := []byte(peer.Token)
+	for _, accepted := range g.tokens {
+		if subtle.ConstantTimeCompare(token, accepted) == 1 {
+			return nil
+		}
+	}
+	return ErrUnauthorized
+}
+
+// rateBucket is the call budget of a client.
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitGuard limits the calls of each client IP to a number per second, allowing bursts. The
+// IP is the one of the connection: clients behind the same proxy share their budget.
+type RateLimitGuard struct {
+	rate  float64 // calls per second
+	burst float64 // maximum number of calls in a burst
+
+	lock      sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimitGuard creates a guard allowing each client IP rate calls per second, in bursts
+// of at most burst calls. The burst is at least one call.
+func NewRateLimitGuard(rate float64, burst int) *RateLimitGuard {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimitGuard{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*rateBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow implements Guard.
+func (g *RateLimitGuard) Allow(peer PeerInfo) error {
+	host, _, err := net.SplitHostPort(peer.RemoteAddr)
+	if err != nil {
+		host = peer.RemoteAddr
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	now := g.now()
+	if now.Sub(g.lastSweep) > rateLimitSweepInterval {
+		g.sweep(now)
+	}
+	bucket := g.buckets[host]
+	if bucket == nil {
+		bucket = &rateBucket{tokens: g.burst, last: now}
+		g.buckets[host] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * g.rate
+	if bucket.tokens > g.burst {
+		bucket.tokens = g.burst
+	}
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return ErrRateLimited
+	}
+	bucket.tokens--
+	return nil
+}
+
+// sweep removes the clients whose budget is refilled, they are the same as new clients.
+func (g *RateLimitGuard) sweep(now time.Time) {
+	for host, bucket := range g.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*g.rate >= g.burst {
+			delete(g.buckets, host)
+		}
+	}
+	g.lastSweep = now
+}
@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenGuard(t *testing.T) {
+	guard := NewTokenGuard([]string{"secret"})
+	tests := []struct {
+		token string
+		want  error
+	}{
+		{"secret", nil},
+		{"", ErrUnauthorized},
+		{"secre", ErrUnauthorized},
+		{"secrets", ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		if err := guard.Allow(PeerInfo{Token: tt.token}); err != tt.want {
+			t.Errorf("token %q: have %v, want %v", tt.token, err, tt.want)
+		}
+	}
+}
+
+func TestRateLimitGuard(t *testing.T) {
+	now := time.Unix(1000, 0)
+	guard := NewRateLimitGuard(2, 3)
+	guard.now = func() time.Time { return now }
+
+	client := PeerInfo{RemoteAddr: "10.0.0.1:1000"}
+	for i := 0; i < 3; i++ {
+		if err := guard.Allow(client); err != nil {
+			t.Fatalf("call %d of the burst refused: %v", i, err)
+		}
+	}
+	if err := guard.Allow(PeerInfo{RemoteAddr: "10.0.0.1:2000"}); err != ErrRateLimited {
+		t.Fatalf("call above the burst from another port: have %v, want %v", err, ErrRateLimited)
+	}
+	if err := guard.Allow(PeerInfo{RemoteAddr: "10.0.0.2:1000"}); err != nil {
+		t.Fatalf("call of another client refused: %v", err)
+	}
+	// half a second refills one call
+	now = now.Add(500 * time.Millisecond)
+	if err := guard.Allow(client); err != nil {
+		t.Fatalf("call after the refill refused: %v", err)
+	}
+	if err := guard.Allow(client); err != ErrRateLimited {
+		t.Fatalf("second call after the refill: have %v, want %v", err, ErrRateLimited)
+	}
+	// idle clients are removed once their budget is refilled
+	now = now.Add(2 * rateLimitSweepInterval)
+	guard.Allow(client)
+	if len(guard.buckets) != 1 {
+		t.Fatalf("wrong number of clients after the sweep: have %d, want 1", len(guard.buckets))
+	}
+}
+
+func TestServerGuard(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	server.AddGuard("test", NewTokenGuard([]string{"secret"}))
+
+	call := func(method, token string) string {
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":[]}`
+		req := httptest.NewRequest(http.MethodPost, "http://url.com", strings.NewReader(body))
+		req.Header.Set("content-type", contentType)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	if resp := call("test_rets", ""); !strings.Contains(resp, ErrUnauthorized.Error()) {
+		t.Fatalf("call without token served: %s", resp)
+	}
+	if resp := call("test_rets", "wrong"); !strings.Contains(resp, ErrUnauthorized.Error()) {
+		t.Fatalf("call with a wrong token served: %s", resp)
+	}
+	if resp := call("test_rets", "secret"); strings.Contains(resp, "error") {
+		t.Fatalf("call with the token refused: %s", resp)
+	}
+	// other namespaces and in-process calls aren't guarded
+	if resp := call("rpc_modules", ""); strings.Contains(resp, "error") {
+		t.Fatalf("call to an unguarded namespace refused: %s", resp)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+	var result string
+	if err := client.Call(&result, "test_rets"); err != nil {
+		t.Fatalf("in-process call refused: %v", err)
+	}
+}
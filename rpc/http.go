@@ -150,8 +150,23 @@ func (t *httpReadWriteNopCloser) Close() error {
 //
 // Deprecated: Server implements http.Handler
 func NewHTTPServer(cors []string, vhosts []string, srv *Server) *http.Server {
+	return NewHTTPServerWithHandlers(cors, vhosts, srv, nil)
+}
+
+// NewHTTPServerWithHandlers creates a new HTTP RPC server around an API provider, also serving
+// the given handlers at their paths. The JSON-RPC requests are served at any other path.
+func NewHTTPServerWithHandlers(cors []string, vhosts []string, srv *Server, handlers map[string]http.Handler) *http.Server {
+	var next http.Handler = srv
+	if len(handlers) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/", srv)
+		for path, handler := range handlers {
+			mux.Handle(path, handler)
+		}
+		next = mux
+	}
 	// Wrap the CORS-handler within a host-handler
-	handler := newCorsHandler(srv, cors)
+	handler := newCorsHandler(next, cors)
 	handler = newVHostHandler(vhosts, handler)
 	return &http.Server{
 		Handler:      handler,
@@ -179,7 +194,7 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer codec.Close()
 
 	w.Header().Set("content-type", contentType)
-	srv.ServeSingleRequest(codec, OptionMethodInvocation)
+	srv.serveRequest(peerContext(r), codec, true, OptionMethodInvocation)
 }
 
 // validateRequest returns a non-zero response code and error message if the
@@ -200,7 +215,7 @@ func validateRequest(r *http.Request) (int, error) {
 	return 0, nil
 }
 
-func newCorsHandler(srv *Server, allowedOrigins []string) http.Handler {
+func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
 		return srv
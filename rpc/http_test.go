@@ -52,3 +52,21 @@ func testHTTPErrorResponse(t *testing.T, method, contentType, body string, expec
 		t.Fatalf("response code should be %d not %d", expected, code)
 	}
 }
+
+func TestHTTPServerWithHandlers(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	server := NewHTTPServerWithHandlers(nil, []string{"*"}, NewServer(), map[string]http.Handler{"/custom": handler})
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://url.com/custom", strings.NewReader("")))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("custom handler response code should be %d not %d", http.StatusTeapot, rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://url.com/", strings.NewReader("")))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("rpc response code should be %d not %d", http.StatusUnsupportedMediaType, rec.Code)
+	}
+}
@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"reflect"
@@ -24,6 +25,7 @@ import (
 	"sync"
 
 	mapset "github.com/deckarep/golang-set"
+	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/hexutil"
 )
 
@@ -71,6 +73,9 @@ type subscriptions map[string]*callback  // collection of subscription callbacks
 type Server struct {
 	services serviceRegistry
 
+	guardsMu sync.RWMutex
+	guards   map[string][]Guard // guards of the namespaces, see AddGuard
+
 	run      int32
 	codecsMu sync.Mutex
 	codecs   mapset.Set
@@ -162,6 +167,71 @@ func (bn BlockNumber) Int64() int64 {
 	return (int64)(bn)
 }
 
+// BlockNumberOrHash selects a block either by number or by hash.
+type BlockNumberOrHash struct {
+	BlockNumber *BlockNumber `json:"blockNumber,omitempty"`
+	BlockHash   *common.Hash `json:"blockHash,omitempty"`
+}
+
+// UnmarshalJSON parses the given JSON fragment into a BlockNumberOrHash. It supports:
+// - a block hash or a block number as accepted by BlockNumber
+// - an object with either a "blockNumber" or a "blockHash" field
+func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
+	type object BlockNumberOrHash
+	var obj object
+	if err := json.Unmarshal(data, &obj); err == nil {
+		if obj.BlockNumber != nil && obj.BlockHash != nil {
+			return fmt.Errorf("cannot specify both blockHash and blockNumber, choose one or the other")
+		}
+		if obj.BlockNumber == nil && obj.BlockHash == nil {
+			return fmt.Errorf("either blockHash or blockNumber must be specified")
+		}
+		*bnh = BlockNumberOrHash(obj)
+		return nil
+	}
+	input := trimData(data)
+	if len(input) == 66 {
+		hash := common.Hash{}
+		if err := hash.UnmarshalText([]byte(input)); err != nil {
+			return err
+		}
+		bnh.BlockHash = &hash
+		return nil
+	}
+	var number BlockNumber
+	if err := number.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	bnh.BlockNumber = &number
+	return nil
+}
+
+// Number returns the block number, if the block is selected by number.
+func (bnh *BlockNumberOrHash) Number() (BlockNumber, bool) {
+	if bnh.BlockNumber != nil {
+		return *bnh.BlockNumber, true
+	}
+	return BlockNumber(0), false
+}
+
+// Hash returns the block hash, if the block is selected by hash.
+func (bnh *BlockNumberOrHash) Hash() (common.Hash, bool) {
+	if bnh.BlockHash != nil {
+		return *bnh.BlockHash, true
+	}
+	return common.Hash{}, false
+}
+
+// BlockNumberOrHashWithNumber selects a block by number.
+func BlockNumberOrHashWithNumber(blockNr BlockNumber) BlockNumberOrHash {
+	return BlockNumberOrHash{BlockNumber: &blockNr}
+}
+
+// BlockNumberOrHashWithHash selects a block by hash.
+func BlockNumberOrHashWithHash(hash common.Hash) BlockNumberOrHash {
+	return BlockNumberOrHash{BlockHash: &hash}
+}
+
 func (e *EpochNumber) UnmarshalJSON(data []byte) error {
 	input := trimData(data)
 	if input == "latest" {
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/math"
 )
 
@@ -64,3 +65,48 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockNumberOrHashJSONUnmarshal(t *testing.T) {
+	hash := common.HexToHash("0x1e4e4e8fb2c3f5c6e2ce4d3fd4fc84ab0b0d15e0c0b0c0b0c0b0c0b0c0b0c0b0")
+	tests := []struct {
+		input    string
+		mustFail bool
+		expected BlockNumberOrHash
+	}{
+		0:  {`"0x"`, true, BlockNumberOrHash{}},
+		1:  {`"0x0"`, false, BlockNumberOrHashWithNumber(0)},
+		2:  {`"0x12"`, false, BlockNumberOrHashWithNumber(18)},
+		3:  {`"latest"`, false, BlockNumberOrHashWithNumber(LatestBlockNumber)},
+		4:  {`"pending"`, false, BlockNumberOrHashWithNumber(PendingBlockNumber)},
+		5:  {`"` + hash.Hex() + `"`, false, BlockNumberOrHashWithHash(hash)},
+		6:  {`{"blockNumber":"0x12"}`, false, BlockNumberOrHashWithNumber(18)},
+		7:  {`{"blockHash":"` + hash.Hex() + `"}`, false, BlockNumberOrHashWithHash(hash)},
+		8:  {`{"blockNumber":"0x12","blockHash":"` + hash.Hex() + `"}`, true, BlockNumberOrHash{}},
+		9:  {`{}`, true, BlockNumberOrHash{}},
+		10: {`"0x1e4e"`, false, BlockNumberOrHashWithNumber(0x1e4e)},
+		11: {`someString`, true, BlockNumberOrHash{}},
+	}
+
+	for i, test := range tests {
+		var bnh BlockNumberOrHash
+		err := json.Unmarshal([]byte(test.input), &bnh)
+		if test.mustFail && err == nil {
+			t.Errorf("Test %d should fail", i)
+			continue
+		}
+		if !test.mustFail && err != nil {
+			t.Errorf("Test %d should pass but got err: %v", i, err)
+			continue
+		}
+		if test.mustFail {
+			continue
+		}
+		haveNumber, haveIsNumber := bnh.Number()
+		wantNumber, wantIsNumber := test.expected.Number()
+		haveHash, _ := bnh.Hash()
+		wantHash, _ := test.expected.Hash()
+		if haveNumber != wantNumber || haveIsNumber != wantIsNumber || haveHash != wantHash {
+			t.Errorf("Test %d got unexpected value, want %+v, got %+v", i, test.expected, bnh)
+		}
+	}
+}
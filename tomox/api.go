@@ -3,8 +3,15 @@ package tomox
 import (
 	"context"
 	"errors"
+	"math/big"
 	"sync"
 	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
 )
 
 const (
@@ -40,3 +47,219 @@ func NewPublicTomoXAPI(t *TomoX) *PublicTomoXAPI {
 func (api *PublicTomoXAPI) Version(ctx context.Context) string {
 	return ProtocolVersionStr
 }
+
+// GetOrdersByUser returns a page of the orders placed by a user, the most recent first. An empty
+// base or quote token matches every pair, an empty status every status. Pages are numbered from
+// zero and hold at most 100 orders.
+func (api *PublicTomoXAPI) GetOrdersByUser(ctx context.Context, user common.Address, baseToken common.Address, quoteToken common.Address, status string, page int, limit int) ([]*tradingstate.OrderItem, error) {
+	return api.t.getOrdersByUser(user, baseToken, quoteToken, status, page, limit)
+}
+
+// GetTradesByUser returns a page of the trades of a user as a taker or a maker, the most recent
+// first, with the same filters as GetOrdersByUser.
+func (api *PublicTomoXAPI) GetTradesByUser(ctx context.Context, user common.Address, baseToken common.Address, quoteToken common.Address, status string, page int, limit int) ([]*tradingstate.Trade, error) {
+	return api.t.getTradesByUser(user, baseToken, quoteToken, status, page, limit)
+}
+
+// GetTrades returns a page of at most limit trades of a pair, 100 at most, in the given direction:
+// "desc" (the default) for the most recent first, "asc" for the oldest first. The page starts
+// after the trade whose hash is the cursor, from the first trade if it is empty, and the cursor
+// of the next page is returned along with the trades.
+func (api *PublicTomoXAPI) GetTrades(ctx context.Context, baseToken common.Address, quoteToken common.Address, cursor common.Hash, limit int, direction string) (*TradesPage, error) {
+	return api.t.getTrades(baseToken, quoteToken, cursor, limit, direction)
+}
+
+// GetCandles returns the price candles of an interval (1m, 5m, 1h or 1d) of a pair opened between
+// the from and to unix times, the oldest first. Candles are only recorded by nodes started with
+// --tomox.candleindex, and a query returns at most 1000 of them.
+func (api *PublicTomoXAPI) GetCandles(ctx context.Context, baseToken common.Address, quoteToken common.Address, interval string, from, to uint64) ([]*tradingstate.Candle, error) {
+	return api.t.getCandles(baseToken, quoteToken, interval, time.Unix(int64(from), 0).UTC(), time.Unix(int64(to), 0).UTC())
+}
+
+// GetWashTradeReport returns the self trades and the rings of users trading in circles among the
+// trades of a relayer created between the from and to unix times, 31 days at most, pair by pair.
+// Reports are only available on SDK nodes.
+func (api *PublicTomoXAPI) GetWashTradeReport(ctx context.Context, relayer common.Address, from, to uint64) (*WashTradeReport, error) {
+	return api.t.getWashTradeReport(relayer, time.Unix(int64(from), 0).UTC(), time.Unix(int64(to), 0).UTC())
+}
+
+// GetFeeTiers returns the fee tiers set by a relayer for a quote token by ascending minimum volume.
+func (api *PublicTomoXAPI) GetFeeTiers(ctx context.Context, relayer common.Address, quoteToken common.Address) ([]tradingstate.FeeTier, error) {
+	return api.t.feeTiers(relayer, quoteToken)
+}
+
+// GetFeeVolume returns the volume in quote token traded by a user through a relayer during the
+// last 30 days, which decides the fee tier of the user.
+func (api *PublicTomoXAPI) GetFeeVolume(ctx context.Context, relayer common.Address, user common.Address, quoteToken common.Address) (*big.Int, error) {
+	return api.t.feeVolume(relayer, user, quoteToken)
+}
+
+// GetMinNotional returns the minimum notional in quote token of the orders of a pair, zero if
+// there is none. Smaller orders are rejected and smaller remainders of partial fills cancelled.
+func (api *PublicTomoXAPI) GetMinNotional(ctx context.Context, baseToken common.Address, quoteToken common.Address) (*big.Int, error) {
+	return api.t.minNotional(baseToken, quoteToken)
+}
+
+// GetPriceBand returns the price band of a pair: its limit orders priced further than the band from
+// the average price of the window are rejected.
+func (api *PublicTomoXAPI) GetPriceBand(ctx context.Context, baseToken common.Address, quoteToken common.Address) (*tradingstate.PriceBand, error) {
+	return api.t.priceBand(baseToken, quoteToken)
+}
+
+// GetTwap returns the time and volume weighted average prices of a pair during the last window
+// epochs, up to the current one. The prices are recorded from TIPTomoXTwap.
+func (api *PublicTomoXAPI) GetTwap(ctx context.Context, baseToken common.Address, quoteToken common.Address, window uint64) (*tradingstate.Twap, error) {
+	return api.t.twap(baseToken, quoteToken, window)
+}
+
+// GetEngineStats returns the orders matched, rejected and cancelled by the matching engine, the
+// average match depth and the matching latency per pair over the last blocks, all the blocks kept
+// in memory if blocks is omitted.
+func (api *PublicTomoXAPI) GetEngineStats(ctx context.Context, blocks *int) *EngineStats {
+	if blocks == nil {
+		return api.t.EngineStats(0)
+	}
+	return api.t.EngineStats(*blocks)
+}
+
+// GetOrderBook returns the aggregated bid and ask volume at each price of the order book of a pair,
+// built from the trading state of the current block. At most depth levels are returned per side,
+// all of them if depth is not positive.
+func (api *PublicTomoXAPI) GetOrderBook(ctx context.Context, baseToken common.Address, quoteToken common.Address, depth int) (*OrderBook, error) {
+	book, err := api.t.currentOrderBook(baseToken, quoteToken)
+	if err != nil {
+		return nil, err
+	}
+	if depth > 0 {
+		if len(book.Bids) > depth {
+			book.Bids = book.Bids[:depth]
+		}
+		if len(book.Asks) > depth {
+			book.Asks = book.Asks[:depth]
+		}
+	}
+	return book, nil
+}
+
+// OrderBookDiffs creates a subscription that is triggered by each new head block changing the
+// order book of a pair, with the levels changed since the previous notification, or since the
+// subscription for the first one. Clients apply the diffs above the block of a GetOrderBook
+// snapshot taken after subscribing.
+func (api *PublicTomoXAPI) OrderBookDiffs(ctx context.Context, baseToken common.Address, quoteToken common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	chain, ok := api.t.chain.(chainHeadSubscriber)
+	if !ok {
+		return &rpc.Subscription{}, errChainHeadsUnavailable
+	}
+	last, err := api.t.currentOrderBook(baseToken, quoteToken)
+	if err != nil {
+		return &rpc.Subscription{}, err
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		heads := make(chan core.ChainHeadEvent, orderBookEventChanSize)
+		headsSub := chain.SubscribeChainHeadEvent(heads)
+
+		for {
+			select {
+			case head := <-heads:
+				book, err := api.t.orderBookAt(head.Block, baseToken, quoteToken)
+				if err != nil {
+					log.Debug("Failed to build order book diff", "block", head.Block.Number(), "err", err)
+					continue
+				}
+				if diff := diffOrderBook(last, book); diff != nil {
+					notifier.Notify(rpcSub.ID, diff)
+				}
+				last = book
+			case <-rpcSub.Err():
+				headsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				headsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// OrderEvents creates a subscription that is triggered by each order of a pair matched or rejected
+// by the matching engine in a new canonical block. Orders of blocks reverted by a reorg are sent
+// again as the blocks of the new chain are processed.
+func (api *PublicTomoXAPI) OrderEvents(ctx context.Context, baseToken common.Address, quoteToken common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	chain, ok := api.t.chain.(tomoxEventSubscriber)
+	if !ok {
+		return &rpc.Subscription{}, errTomoXEventsUnavailable
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		// the events are notified by the queue, not to block the chain import on a slow client
+		queue := NewEventQueue(orderEventQueueSize, func(ev interface{}) error {
+			return notifier.Notify(rpcSub.ID, ev)
+		})
+		defer queue.Close()
+
+		matched := make(chan core.OrderMatchedEvent, orderBookEventChanSize)
+		rejected := make(chan core.OrderRejectedEvent, orderBookEventChanSize)
+		matchedSub := chain.TomoXEvents().SubscribeOrderMatched(matched)
+		rejectedSub := chain.TomoXEvents().SubscribeOrderRejected(rejected)
+		defer matchedSub.Unsubscribe()
+		defer rejectedSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-matched:
+				if event := newMatchedOrderEvent(ev, baseToken, quoteToken); event != nil {
+					pushOrderEvent(queue, event)
+				}
+			case ev := <-rejected:
+				if event := newRejectedOrderEvent(ev, baseToken, quoteToken); event != nil {
+					pushOrderEvent(queue, event)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// GetBlockWitness returns the witness of a block sealed by the node, or supplied to it: the trie
+// nodes of the trading and lending states of the parent block read by the matching of the block.
+// Witnesses are recorded by the nodes started with --tomox.witness.
+func (api *PublicTomoXAPI) GetBlockWitness(ctx context.Context, blockHash common.Hash) (*BlockWitness, error) {
+	return api.t.ReadBlockWitness(blockHash)
+}
+
+// PrivateTomoXAPI provides the administrative tomoX RPC service, which is not exposed publicly.
+type PrivateTomoXAPI struct {
+	t *TomoX
+}
+
+// NewPrivateTomoXAPI creates a new administrative RPC tomoX service.
+func NewPrivateTomoXAPI(t *TomoX) *PrivateTomoXAPI {
+	return &PrivateTomoXAPI{t: t}
+}
+
+// AddBlockWitness supplies the witness of a block, as returned by tomox_getBlockWitness on its
+// proposer, so that the node verifies the block statelessly if it doesn't hold the trading and
+// lending states of its parent. The witness must be supplied before the block is imported.
+func (api *PrivateTomoXAPI) AddBlockWitness(ctx context.Context, blockHash common.Hash, witness BlockWitness) error {
+	return api.t.writeBlockWitness(blockHash, &witness)
+}
@@ -0,0 +1,231 @@
+package tomox
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Nodes started with --tomox.candleindex keep the price candles of each pair in the tomox leveldb,
+// so that charts can be served without mongodb. The trades of a block are recorded by pair and
+// minute as a piece of the 1m candle, keyed by block number, then the candles covering them are
+// rebuilt rather than updated incrementally: the 1m candle from its pieces, then each longer
+// candle from the shorter candles it covers. A block imported again after a reorg replaces the
+// pieces of the block it reorgs out, so the candles only depend on the canonical blocks.
+var (
+	candlePiecePrefix = []byte("tomoxCandlePiece-") // candlePiecePrefix + order book + minute + number -> Candle of the trades of the block
+	candleBlockPrefix = []byte("tomoxCandleBlock-") // candleBlockPrefix + number + order book + minute -> nil
+	candlePrefix      = []byte("tomoxCandle-")      // candlePrefix + order book + interval + open time -> Candle
+)
+
+// maxCandles is the maximum number of candles returned by a candle query.
+const maxCandles = 1000
+
+var (
+	errCandleIndexUnavailable = errors.New("candles require --tomox.candleindex")
+	errUnknownCandleInterval  = errors.New("unknown candle interval")
+	errCandleRange            = fmt.Errorf("candle range exceeds %d candles", maxCandles)
+)
+
+// candleMinute identifies the 1m candle of a pair.
+type candleMinute struct {
+	orderBook common.Hash
+	openTime  time.Time
+}
+
+func encodeCandleTime(t time.Time) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, uint64(t.Unix()))
+	return enc
+}
+
+func candlePieceKey(orderBook common.Hash, minute time.Time, number uint64) []byte {
+	key := append(append(append([]byte{}, candlePiecePrefix...), orderBook.Bytes()...), encodeCandleTime(minute)...)
+	return append(key, encodeBlockNumber(number)...)
+}
+
+func candleBlockKey(number uint64, orderBook common.Hash, minute time.Time) []byte {
+	key := append(append(append([]byte{}, candleBlockPrefix...), encodeBlockNumber(number)...), orderBook.Bytes()...)
+	return append(key, encodeCandleTime(minute)...)
+}
+
+func candleBookPrefix(orderBook common.Hash, interval int) []byte {
+	return append(append(append([]byte{}, candlePrefix...), orderBook.Bytes()...), byte(interval))
+}
+
+func encodeBlockNumber(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return enc
+}
+
+// HasCandleIndex returns whether the candles of each pair are indexed in leveldb.
+func (tomox *TomoX) HasCandleIndex() bool {
+	return tomox.candleIndex
+}
+
+// IndexCandles records the trades of a block into the candles of their pairs, replacing the trades
+// of any block with the same number indexed before. It does nothing unless the index is enabled.
+func (tomox *TomoX) IndexCandles(block *types.Block, trades []map[string]string) error {
+	if !tomox.HasCandleIndex() {
+		return nil
+	}
+	db := tomox.GetLevelDB()
+	batch := db.NewBatch()
+	number := block.NumberU64()
+	dirty := make(map[candleMinute]bool)
+
+	// remove the trades of a block reorged out
+	prefix := append(append([]byte{}, candleBlockPrefix...), encodeBlockNumber(number)...)
+	it := db.NewIterator(prefix, nil)
+	for it.Next() {
+		key := it.Key()[len(prefix):]
+		minute := candleMinute{common.BytesToHash(key[:common.HashLength]), time.Unix(int64(binary.BigEndian.Uint64(key[common.HashLength:])), 0).UTC()}
+		dirty[minute] = true
+		if err := batch.Delete(candlePieceKey(minute.orderBook, minute.openTime, number)); err != nil {
+			it.Release()
+			return err
+		}
+		if err := batch.Delete(candleBlockKey(number, minute.orderBook, minute.openTime)); err != nil {
+			it.Release()
+			return err
+		}
+	}
+	it.Release()
+
+	blockTime := time.Unix(block.Time().Int64(), 0).UTC()
+	minute := blockTime.Truncate(time.Minute)
+	pieces := make(map[common.Hash]*tradingstate.Candle)
+	var books []common.Hash
+	for _, trade := range trades {
+		baseToken, quoteToken := common.HexToAddress(trade[tradingstate.TradeBaseToken]), common.HexToAddress(trade[tradingstate.TradeQuoteToken])
+		price, quantity := tradingstate.ToBigInt(trade[tradingstate.TradePrice]), tradingstate.ToBigInt(trade[tradingstate.TradeQuantity])
+		if price.Sign() <= 0 || quantity.Sign() <= 0 {
+			continue
+		}
+		orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+		piece, ok := pieces[orderBook]
+		if !ok {
+			piece = &tradingstate.Candle{BaseToken: baseToken, QuoteToken: quoteToken, Interval: tradingstate.CandleIntervals[0].Name, OpenTime: minute}
+			pieces[orderBook] = piece
+			books = append(books, orderBook)
+		}
+		piece.AddTrade(price, quantity)
+	}
+	for _, orderBook := range books {
+		data, err := json.Marshal(pieces[orderBook])
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(candlePieceKey(orderBook, minute, number), data); err != nil {
+			return err
+		}
+		if err := batch.Put(candleBlockKey(number, orderBook, minute), nil); err != nil {
+			return err
+		}
+		dirty[candleMinute{orderBook, minute}] = true
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	// the candles of an interval must be written before the longer ones are built from them
+	for i, interval := range tradingstate.CandleIntervals {
+		batch = db.NewBatch()
+		done := make(map[candleMinute]bool)
+		for m := range dirty {
+			candle := candleMinute{m.orderBook, m.openTime.Truncate(interval.Duration)}
+			if done[candle] {
+				continue
+			}
+			done[candle] = true
+			if err := tomox.updateCandle(batch, candle.orderBook, i, candle.openTime); err != nil {
+				return fmt.Errorf("failed to update %s candle. Err: %v", interval.Name, err)
+			}
+		}
+		if err := batch.Write(); err != nil {
+			return fmt.Errorf("failed to commit %s candles. Err: %v", interval.Name, err)
+		}
+	}
+	log.Debug("Indexed candles", "number", number, "pairs", len(books), "candles", len(dirty))
+	return nil
+}
+
+// updateCandle rebuilds a candle from the pieces of its minute for the shortest interval, from the
+// candles of the previous interval otherwise. An empty candle is removed.
+func (tomox *TomoX) updateCandle(batch ethdb.Batch, orderBook common.Hash, interval int, openTime time.Time) error {
+	candle := &tradingstate.Candle{Interval: tradingstate.CandleIntervals[interval].Name, OpenTime: openTime}
+	var parts []*tradingstate.Candle
+	if interval == 0 {
+		prefix := append(append(append([]byte{}, candlePiecePrefix...), orderBook.Bytes()...), encodeCandleTime(openTime)...)
+		parts = tomox.readCandles(prefix, nil, time.Time{})
+	} else {
+		parts = tomox.readCandles(candleBookPrefix(orderBook, interval-1), encodeCandleTime(openTime), openTime.Add(tradingstate.CandleIntervals[interval].Duration))
+	}
+	for _, part := range parts {
+		candle.BaseToken, candle.QuoteToken = part.BaseToken, part.QuoteToken
+		candle.AddCandle(part)
+	}
+	key := append(candleBookPrefix(orderBook, interval), encodeCandleTime(openTime)...)
+	if candle.Count == 0 {
+		return batch.Delete(key)
+	}
+	data, err := json.Marshal(candle)
+	if err != nil {
+		return err
+	}
+	return batch.Put(key, data)
+}
+
+// readCandles returns the candles stored under a prefix from start on, in key order, stopping at
+// the first one opened at or after closeTime unless it is zero.
+func (tomox *TomoX) readCandles(prefix []byte, start []byte, closeTime time.Time) []*tradingstate.Candle {
+	it := tomox.GetLevelDB().NewIterator(prefix, start)
+	defer it.Release()
+
+	candles := []*tradingstate.Candle{}
+	for it.Next() {
+		candle := &tradingstate.Candle{}
+		if err := json.Unmarshal(it.Value(), candle); err != nil {
+			log.Error("Failed to decode indexed candle", "key", common.Bytes2Hex(it.Key()), "err", err)
+			continue
+		}
+		if !closeTime.IsZero() && !candle.OpenTime.Before(closeTime) {
+			break
+		}
+		candles = append(candles, candle)
+	}
+	return candles
+}
+
+// getCandles returns the candles of an interval of a pair opened in [from, to).
+func (tomox *TomoX) getCandles(baseToken, quoteToken common.Address, interval string, from, to time.Time) ([]*tradingstate.Candle, error) {
+	if !tomox.HasCandleIndex() {
+		return nil, errCandleIndexUnavailable
+	}
+	index := -1
+	for i, candleInterval := range tradingstate.CandleIntervals {
+		if candleInterval.Name == interval {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, errUnknownCandleInterval
+	}
+	if to.Sub(from) > maxCandles*tradingstate.CandleIntervals[index].Duration {
+		return nil, errCandleRange
+	}
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+	return tomox.readCandles(candleBookPrefix(orderBook, index), encodeCandleTime(from), to), nil
+}
@@ -0,0 +1,87 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestIndexCandles(t *testing.T) {
+	tomox := New(&Config{DataDir: t.TempDir(), CandleIndex: true})
+	baseToken, quoteToken := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	block := func(number, time int64) *types.Block {
+		return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Time: big.NewInt(time)})
+	}
+	trade := func(price, quantity int64) map[string]string {
+		return map[string]string{
+			tradingstate.TradeBaseToken:  baseToken.Hex(),
+			tradingstate.TradeQuoteToken: quoteToken.Hex(),
+			tradingstate.TradePrice:      big.NewInt(price).String(),
+			tradingstate.TradeQuantity:   big.NewInt(quantity).String(),
+		}
+	}
+	candles := func(interval string) []*tradingstate.Candle {
+		candles, err := tomox.getCandles(baseToken, quoteToken, interval, time.Unix(0, 0), time.Unix(3600, 0))
+		if err != nil {
+			t.Fatalf("failed to get %s candles: %v", interval, err)
+		}
+		return candles
+	}
+	check := func(candle *tradingstate.Candle, openTime int64, open, high, low, close, volume int64, count uint64) {
+		t.Helper()
+		if !candle.OpenTime.Equal(time.Unix(openTime, 0)) || candle.Open.Int64() != open || candle.High.Int64() != high || candle.Low.Int64() != low ||
+			candle.Close.Int64() != close || candle.Volume.Int64() != volume || candle.Count != count {
+			t.Errorf("unexpected %s candle: have open time %d, ohlc %v %v %v %v, volume %v, count %d", candle.Interval, candle.OpenTime.Unix(), candle.Open, candle.High, candle.Low, candle.Close, candle.Volume, candle.Count)
+		}
+	}
+
+	for _, b := range []struct {
+		block  *types.Block
+		trades []map[string]string
+	}{
+		{block(1, 60), []map[string]string{trade(10, 1), trade(12, 2)}},
+		{block(2, 62), []map[string]string{trade(9, 3)}},
+		{block(3, 121), []map[string]string{trade(11, 4)}},
+	} {
+		if err := tomox.IndexCandles(b.block, b.trades); err != nil {
+			t.Fatalf("failed to index candles: %v", err)
+		}
+	}
+	if minutes := candles("1m"); len(minutes) != 2 {
+		t.Fatalf("unexpected number of 1m candles: have %d, want 2", len(minutes))
+	} else {
+		check(minutes[0], 60, 10, 12, 9, 9, 6, 3)
+		check(minutes[1], 120, 11, 11, 11, 11, 4, 1)
+	}
+	if hours := candles("1h"); len(hours) != 1 {
+		t.Fatalf("unexpected number of 1h candles: have %d, want 1", len(hours))
+	} else {
+		check(hours[0], 0, 10, 12, 9, 11, 10, 4)
+	}
+
+	// block 2 is reorged out by a block without trades, block 3 by a block with other trades
+	tomox.IndexCandles(block(2, 63), nil)
+	tomox.IndexCandles(block(3, 180), []map[string]string{trade(13, 5)})
+	if minutes := candles("1m"); len(minutes) != 2 {
+		t.Fatalf("unexpected number of 1m candles after reorg: have %d, want 2", len(minutes))
+	} else {
+		check(minutes[0], 60, 10, 12, 10, 12, 3, 2)
+		check(minutes[1], 180, 13, 13, 13, 13, 5, 1)
+	}
+	if days := candles("1d"); len(days) != 1 {
+		t.Fatalf("unexpected number of 1d candles after reorg: have %d, want 1", len(days))
+	} else {
+		check(days[0], 0, 10, 13, 10, 13, 8, 3)
+	}
+
+	if _, err := tomox.getCandles(baseToken, quoteToken, "2m", time.Unix(0, 0), time.Unix(60, 0)); err != errUnknownCandleInterval {
+		t.Errorf("unexpected error for an unknown interval: %v", err)
+	}
+	if _, err := New(&Config{DataDir: t.TempDir()}).getCandles(baseToken, quoteToken, "1m", time.Unix(0, 0), time.Unix(60, 0)); err != errCandleIndexUnavailable {
+		t.Errorf("unexpected error without candle index: %v", err)
+	}
+}
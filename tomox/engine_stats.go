@@ -0,0 +1,185 @@
+package tomox
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// engineStatsBlocks is the number of recent blocks whose matching statistics are kept in memory.
+const engineStatsBlocks = 128
+
+// PairEngineStats are the matching statistics of a pair. Latencies are in nanoseconds.
+type PairEngineStats struct {
+	BaseToken         common.Address `json:"baseToken"`
+	QuoteToken        common.Address `json:"quoteToken"`
+	Orders            uint64         `json:"orders"`            // orders and cancellations applied
+	Matched           uint64         `json:"matched"`           // orders matched with at least one trade
+	Trades            uint64         `json:"trades"`            // trades of the matched orders
+	Rejected          uint64         `json:"rejected"`          // orders rejected, including the resting orders rejected while matching
+	Cancelled         uint64         `json:"cancelled"`         // orders cancelled
+	AverageMatchDepth float64        `json:"averageMatchDepth"` // trades per matched order
+	AverageLatency    time.Duration  `json:"averageLatency"`
+	MaxLatency        time.Duration  `json:"maxLatency"`
+
+	totalLatency time.Duration
+}
+
+func (stats *PairEngineStats) add(other *PairEngineStats) {
+	stats.Orders += other.Orders
+	stats.Matched += other.Matched
+	stats.Trades += other.Trades
+	stats.Rejected += other.Rejected
+	stats.Cancelled += other.Cancelled
+	stats.totalLatency += other.totalLatency
+	if other.MaxLatency > stats.MaxLatency {
+		stats.MaxLatency = other.MaxLatency
+	}
+}
+
+func (stats *PairEngineStats) finalize() {
+	if stats.Matched > 0 {
+		stats.AverageMatchDepth = float64(stats.Trades) / float64(stats.Matched)
+	}
+	if stats.Orders > 0 {
+		stats.AverageLatency = stats.totalLatency / time.Duration(stats.Orders)
+	}
+}
+
+// EngineStats are the matching statistics of the recent blocks, in total and by pair.
+type EngineStats struct {
+	FromBlock uint64 `json:"fromBlock"`
+	ToBlock   uint64 `json:"toBlock"`
+	Blocks    int    `json:"blocks"` // blocks with orders between FromBlock and ToBlock
+	PairEngineStats
+	Pairs []*PairEngineStats `json:"pairs"`
+}
+
+// enginePair identifies the pair of an order.
+type enginePair struct {
+	baseToken, quoteToken common.Address
+}
+
+// blockEngineStats are the matching statistics of the last run of a block number.
+type blockEngineStats struct {
+	run   common.Hash
+	pairs map[enginePair]*PairEngineStats
+}
+
+// engineStats keeps the matching statistics of the recent blocks. The matching engine records every
+// order it applies, whether it matches the orders of a new block or verifies the orders of an
+// imported block, by block number and pair. A block number matched again by another run, for
+// another parent block, coinbase or time, replaces the statistics of the previous run, so that the
+// statistics of a number are the ones of the last block matched at that height.
+type engineStats struct {
+	lock   sync.Mutex
+	blocks map[uint64]*blockEngineStats
+	latest uint64
+}
+
+// engineRun identifies a run of the matching engine over the orders of a block.
+func engineRun(header *types.Header) common.Hash {
+	return crypto.Keccak256Hash(header.ParentHash.Bytes(), header.Coinbase.Bytes(), header.Time.Bytes())
+}
+
+// record adds an order applied to a block to the statistics of its pair.
+func (s *engineStats) record(header *types.Header, order *tradingstate.OrderItem, trades []map[string]string, rejects []*tradingstate.OrderItem, elapsed time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	number := header.Number.Uint64()
+	if number+engineStatsBlocks <= s.latest {
+		return
+	}
+	if s.blocks == nil {
+		s.blocks = make(map[uint64]*blockEngineStats)
+	}
+	if number > s.latest {
+		s.latest = number
+		for n := range s.blocks {
+			if n+engineStatsBlocks <= number {
+				delete(s.blocks, n)
+			}
+		}
+	}
+	run := engineRun(header)
+	block := s.blocks[number]
+	if block == nil || block.run != run {
+		block = &blockEngineStats{run: run, pairs: make(map[enginePair]*PairEngineStats)}
+		s.blocks[number] = block
+	}
+	key := enginePair{order.BaseToken, order.QuoteToken}
+	pair := block.pairs[key]
+	if pair == nil {
+		pair = &PairEngineStats{BaseToken: order.BaseToken, QuoteToken: order.QuoteToken}
+		block.pairs[key] = pair
+	}
+	pair.Orders++
+	if len(trades) > 0 {
+		pair.Matched++
+		pair.Trades += uint64(len(trades))
+	}
+	pair.Rejected += uint64(len(rejects))
+	if order.Status == tradingstate.OrderStatusCancelled && len(rejects) == 0 {
+		pair.Cancelled++
+	}
+	pair.totalLatency += elapsed
+	if elapsed > pair.MaxLatency {
+		pair.MaxLatency = elapsed
+	}
+}
+
+// stats sums the statistics of the last blocks up to the latest block recorded, of all the
+// recorded blocks if blocks is not positive.
+func (s *engineStats) stats(blocks int) *EngineStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if blocks <= 0 || blocks > engineStatsBlocks {
+		blocks = engineStatsBlocks
+	}
+	result := &EngineStats{ToBlock: s.latest, Pairs: []*PairEngineStats{}}
+	if s.latest+1 > uint64(blocks) {
+		result.FromBlock = s.latest + 1 - uint64(blocks)
+	}
+	pairs := make(map[enginePair]*PairEngineStats)
+	for number, block := range s.blocks {
+		if number < result.FromBlock {
+			continue
+		}
+		result.Blocks++
+		for key, stats := range block.pairs {
+			pair := pairs[key]
+			if pair == nil {
+				pair = &PairEngineStats{BaseToken: stats.BaseToken, QuoteToken: stats.QuoteToken}
+				pairs[key] = pair
+				result.Pairs = append(result.Pairs, pair)
+			}
+			pair.add(stats)
+			result.add(stats)
+		}
+	}
+	for _, pair := range result.Pairs {
+		pair.finalize()
+	}
+	result.finalize()
+	sort.Slice(result.Pairs, func(i, j int) bool {
+		if cmp := bytes.Compare(result.Pairs[i].BaseToken.Bytes(), result.Pairs[j].BaseToken.Bytes()); cmp != 0 {
+			return cmp < 0
+		}
+		return bytes.Compare(result.Pairs[i].QuoteToken.Bytes(), result.Pairs[j].QuoteToken.Bytes()) < 0
+	})
+	return result
+}
+
+// EngineStats returns the matching statistics of the last blocks, up to the latest block matched,
+// of all the blocks kept if blocks is not positive.
+func (tomox *TomoX) EngineStats(blocks int) *EngineStats {
+	return tomox.engineStats.stats(blocks)
+}
@@ -0,0 +1,60 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestEngineStats(t *testing.T) {
+	var stats engineStats
+	btc, eth, usdt := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	header := func(number, time int64) *types.Header {
+		return &types.Header{Number: big.NewInt(number), Time: big.NewInt(time)}
+	}
+	order := func(baseToken common.Address, status string) *tradingstate.OrderItem {
+		return &tradingstate.OrderItem{BaseToken: baseToken, QuoteToken: usdt, Status: status}
+	}
+	trades := func(n int) []map[string]string {
+		return make([]map[string]string, n)
+	}
+
+	stats.record(header(1, 10), order(btc, tradingstate.OrderStatusNew), trades(3), nil, 4*time.Millisecond)
+	stats.record(header(1, 10), order(btc, tradingstate.OrderStatusNew), nil, []*tradingstate.OrderItem{{}}, 2*time.Millisecond)
+	stats.record(header(2, 12), order(btc, tradingstate.OrderStatusNew), trades(1), nil, 6*time.Millisecond)
+	stats.record(header(2, 12), order(eth, tradingstate.OrderStatusCancelled), nil, nil, time.Millisecond)
+
+	result := stats.stats(0)
+	if result.FromBlock != 0 || result.ToBlock != 2 || result.Blocks != 2 || len(result.Pairs) != 2 {
+		t.Fatalf("unexpected stats: from %d, to %d, blocks %d, pairs %d", result.FromBlock, result.ToBlock, result.Blocks, len(result.Pairs))
+	}
+	if result.Orders != 4 || result.Matched != 2 || result.Trades != 4 || result.Rejected != 1 || result.Cancelled != 1 || result.AverageMatchDepth != 2 {
+		t.Errorf("unexpected totals: %+v", result.PairEngineStats)
+	}
+	if pair := result.Pairs[0]; pair.BaseToken != btc || pair.Orders != 3 || pair.AverageLatency != 4*time.Millisecond || pair.MaxLatency != 6*time.Millisecond {
+		t.Errorf("unexpected stats of the first pair: %+v", pair)
+	}
+	if pair := result.Pairs[1]; pair.BaseToken != eth || pair.Cancelled != 1 || pair.Matched != 0 || pair.AverageMatchDepth != 0 {
+		t.Errorf("unexpected stats of the second pair: %+v", pair)
+	}
+	if result := stats.stats(1); result.FromBlock != 2 || result.Orders != 2 {
+		t.Errorf("unexpected stats of the last block: from %d, orders %d", result.FromBlock, result.Orders)
+	}
+
+	// block 2 is matched again for another block, replacing the first run
+	stats.record(header(2, 13), order(eth, tradingstate.OrderStatusNew), nil, nil, time.Millisecond)
+	if result := stats.stats(1); result.Orders != 1 || len(result.Pairs) != 1 || result.Pairs[0].BaseToken != eth {
+		t.Errorf("unexpected stats after reorg: %+v", result)
+	}
+
+	// old blocks are pruned and not recorded any more
+	stats.record(header(engineStatsBlocks+1, 20), order(btc, tradingstate.OrderStatusNew), nil, nil, time.Millisecond)
+	stats.record(header(1, 10), order(btc, tradingstate.OrderStatusNew), nil, nil, time.Millisecond)
+	if result := stats.stats(0); result.Blocks != 2 || result.Orders != 2 {
+		t.Errorf("unexpected stats after pruning: blocks %d, orders %d", result.Blocks, result.Orders)
+	}
+}
@@ -0,0 +1,91 @@
+package tomox
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// feeDaySeconds is the length of the days over which the trading volume of the fee tiers is kept.
+const feeDaySeconds = 24 * 60 * 60
+
+// feeDay returns the day of a block.
+func feeDay(header *types.Header) uint64 {
+	return header.Time.Uint64() / feeDaySeconds
+}
+
+// processFeeTierOrder sets the fee rate of the fee tier of the relayer given by a fee tier order.
+//
+// From TIPTomoXFeeTiers, the owner of a relayer may replace its flat fee for the pairs of a quote
+// token by a schedule of fee tiers, each one setting the maker and taker fee rates of the users
+// whose volume traded through the relayer in the last 30 days reaches its minimum volume (see
+// tradingstate.FeeTier). A fee tier order sets one rate of a tier: its quantity is the minimum
+// volume of the tier in quote token, its price is the rate in 1/TomoXBaseFee, its side picks the
+// maker rate when SELL and the taker rate when BUY.
+func processFeeTierOrder(tradingStateDB *tradingstate.TradingStateDB, order *tradingstate.OrderItem) error {
+	maker := order.Side == tradingstate.Ask
+	if err := tradingStateDB.SetFeeTierRate(order.ExchangeAddress, order.QuoteToken, order.Quantity, maker, order.Price); err != nil {
+		return err
+	}
+	log.Debug("Set fee tier rate", "relayer", order.ExchangeAddress.Hex(), "quoteToken", order.QuoteToken.Hex(), "minVolume", order.Quantity, "maker", maker, "rate", order.Price)
+	return nil
+}
+
+// tradeFeeRates returns the fee rates of the taker and the maker of a trade: the flat fees of their
+// relayers, or the rates of the fee tiers reached by their volumes from TIPTomoXFeeTiers.
+func tradeFeeRates(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem) (*big.Int, *big.Int) {
+	takerFeeRate := tradingstate.GetExRelayerFee(takerOrder.ExchangeAddress, statedb)
+	makerFeeRate := tradingstate.GetExRelayerFee(makerOrder.ExchangeAddress, statedb)
+	if !chain.Config().IsTIPTomoXFeeTiers(header.Number) {
+		return takerFeeRate, makerFeeRate
+	}
+	day := feeDay(header)
+	_, takerFeeRate = userFeeRates(tradingStateDB, takerOrder.ExchangeAddress, takerOrder.UserAddress, makerOrder.QuoteToken, day, takerFeeRate)
+	makerFeeRate, _ = userFeeRates(tradingStateDB, makerOrder.ExchangeAddress, makerOrder.UserAddress, makerOrder.QuoteToken, day, makerFeeRate)
+	return takerFeeRate, makerFeeRate
+}
+
+// userFeeRates returns the maker and taker fee rates of a user trading through a relayer in a
+// quote token at a day.
+func userFeeRates(tradingStateDB *tradingstate.TradingStateDB, relayer common.Address, user common.Address, quoteToken common.Address, day uint64, relayerFee *big.Int) (*big.Int, *big.Int) {
+	tiers := tradingStateDB.GetFeeTiers(relayer, quoteToken)
+	if len(tiers) == 0 {
+		return relayerFee, relayerFee
+	}
+	return tradingstate.FeeTierRates(tiers, tradingStateDB.GetFeeVolume(relayer, user, quoteToken, day), relayerFee)
+}
+
+// recordFeeVolume adds the quote token quantity of a trade to the volumes of its taker and maker
+// from TIPTomoXFeeTiers.
+func recordFeeVolume(header *types.Header, chain consensus.ChainContext, tradingStateDB *tradingstate.TradingStateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, quoteQuantity *big.Int) {
+	if !chain.Config().IsTIPTomoXFeeTiers(header.Number) {
+		return
+	}
+	day := feeDay(header)
+	tradingStateDB.AddFeeVolume(takerOrder.ExchangeAddress, takerOrder.UserAddress, makerOrder.QuoteToken, day, quoteQuantity)
+	tradingStateDB.AddFeeVolume(makerOrder.ExchangeAddress, makerOrder.UserAddress, makerOrder.QuoteToken, day, quoteQuantity)
+}
+
+// feeTiers returns the fee tiers of a relayer for a quote token at the current block.
+func (tomox *TomoX) feeTiers(relayer common.Address, quoteToken common.Address) ([]tradingstate.FeeTier, error) {
+	_, tradingState, err := tomox.currentTradingState()
+	if err != nil {
+		return nil, err
+	}
+	return tradingState.GetFeeTiers(relayer, quoteToken), nil
+}
+
+// feeVolume returns the volume traded by a user through a relayer in a quote token during the last
+// 30 days at the current block, deciding its fee tier.
+func (tomox *TomoX) feeVolume(relayer common.Address, user common.Address, quoteToken common.Address) (*big.Int, error) {
+	block, tradingState, err := tomox.currentTradingState()
+	if err != nil {
+		return nil, err
+	}
+	return tradingState.GetFeeVolume(relayer, user, quoteToken, feeDay(block.Header())), nil
+}
@@ -0,0 +1,73 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// configChain is a chain context only serving its chain config.
+type configChain struct {
+	consensus.ChainContext
+}
+
+func (configChain) Config() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+func TestTradeFeeRates(t *testing.T) {
+	relayer, quoteToken := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	taker, maker := common.HexToAddress("0x3"), common.HexToAddress("0x4")
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	for _, tier := range []struct {
+		side      string
+		minVolume int64
+		rate      int64
+	}{
+		{tradingstate.Ask, 100, 2}, {tradingstate.Bid, 100, 4}, {tradingstate.Bid, 1000, 3},
+	} {
+		order := &tradingstate.OrderItem{ExchangeAddress: relayer, QuoteToken: quoteToken, Side: tier.side, Quantity: big.NewInt(tier.minVolume), Price: big.NewInt(tier.rate)}
+		if err := processFeeTierOrder(tradingStateDB, order); err != nil {
+			t.Fatalf("failed to process fee tier order: %v", err)
+		}
+	}
+	takerOrder := &tradingstate.OrderItem{ExchangeAddress: relayer, UserAddress: taker, QuoteToken: quoteToken}
+	makerOrder := &tradingstate.OrderItem{ExchangeAddress: relayer, UserAddress: maker, QuoteToken: quoteToken}
+	header := &types.Header{Number: new(big.Int).Set(common.TIPTomoXFeeTiers), Time: big.NewInt(100 * feeDaySeconds)}
+	rates := func() (int64, int64) {
+		takerFeeRate, makerFeeRate := tradeFeeRates(header, configChain{}, statedb, tradingStateDB, takerOrder, makerOrder)
+		return takerFeeRate.Int64(), makerFeeRate.Int64()
+	}
+
+	// below the first tier, the flat relayer fee (zero here) applies
+	if takerFeeRate, makerFeeRate := rates(); takerFeeRate != 0 || makerFeeRate != 0 {
+		t.Fatalf("unexpected rates without volume: have %d %d, want 0 0", takerFeeRate, makerFeeRate)
+	}
+	recordFeeVolume(header, configChain{}, tradingStateDB, takerOrder, makerOrder, big.NewInt(500))
+	if takerFeeRate, makerFeeRate := rates(); takerFeeRate != 4 || makerFeeRate != 2 {
+		t.Fatalf("unexpected rates of the first tier: have %d %d, want 4 2", takerFeeRate, makerFeeRate)
+	}
+	recordFeeVolume(header, configChain{}, tradingStateDB, takerOrder, makerOrder, big.NewInt(500))
+	if takerFeeRate, makerFeeRate := rates(); takerFeeRate != 3 || makerFeeRate != 0 {
+		t.Fatalf("unexpected rates of the second tier: have %d %d, want 3 0", takerFeeRate, makerFeeRate)
+	}
+	// the volume expires after 30 days
+	header.Time = big.NewInt(130 * feeDaySeconds)
+	if takerFeeRate, makerFeeRate := rates(); takerFeeRate != 0 || makerFeeRate != 0 {
+		t.Fatalf("unexpected rates after 30 days: have %d %d, want 0 0", takerFeeRate, makerFeeRate)
+	}
+	// before TIPTomoXFeeTiers, the tiers are ignored
+	header.Time = big.NewInt(100 * feeDaySeconds)
+	header.Number = new(big.Int).Sub(common.TIPTomoXFeeTiers, common.Big1)
+	if takerFeeRate, makerFeeRate := rates(); takerFeeRate != 0 || makerFeeRate != 0 {
+		t.Fatalf("unexpected rates before the fork: have %d %d, want 0 0", takerFeeRate, makerFeeRate)
+	}
+}
@@ -0,0 +1,101 @@
+package tomox
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+const maxTradingHistoryLimit = 100 // Maximum number of records returned by a page of trading history
+
+// Directions of a page of trades
+const (
+	TradesAscending  = "asc"  // the oldest first
+	TradesDescending = "desc" // the most recent first
+)
+
+var (
+	errTradingHistoryUnavailable = errors.New("trading history requires an SDK node")
+	errUnknownTradesDirection    = fmt.Errorf("unknown direction, expected %s or %s", TradesAscending, TradesDescending)
+	errTradesCursorNotFound      = errors.New("cursor trade not found")
+)
+
+// TradesPage is a page of the trades of a pair. Next is the cursor of the following page, the
+// hash of the last trade of the page, or empty at the end of the trades.
+type TradesPage struct {
+	Trades []*tradingstate.Trade `json:"trades"`
+	Next   common.Hash           `json:"next"`
+}
+
+func tradingHistoryPage(page, limit int) (offset int, size int) {
+	if limit <= 0 || limit > maxTradingHistoryLimit {
+		limit = maxTradingHistoryLimit
+	}
+	if page < 0 {
+		page = 0
+	}
+	return page * limit, limit
+}
+
+// getOrdersByUser returns a page of the orders placed by a user, the most recent first. An empty
+// base or quote token matches every pair, an empty status every status. Pages are numbered from
+// zero.
+func (tomox *TomoX) getOrdersByUser(user, baseToken, quoteToken common.Address, status string, page, limit int) ([]*tradingstate.OrderItem, error) {
+	if !tomox.IsSDKNode() {
+		return nil, errTradingHistoryUnavailable
+	}
+	offset, limit := tradingHistoryPage(page, limit)
+	orders, _ := tomox.GetMongoReadDB().GetTradingListByUser(user, baseToken, quoteToken, status, offset, limit, &tradingstate.OrderItem{}).([]*tradingstate.OrderItem)
+	return orders, nil
+}
+
+// getTradesByUser returns a page of the trades of a user as a taker or a maker, the most recent
+// first, with the same filters as getOrdersByUser.
+func (tomox *TomoX) getTradesByUser(user, baseToken, quoteToken common.Address, status string, page, limit int) ([]*tradingstate.Trade, error) {
+	if !tomox.IsSDKNode() {
+		return nil, errTradingHistoryUnavailable
+	}
+	offset, limit := tradingHistoryPage(page, limit)
+	trades, _ := tomox.GetMongoReadDB().GetTradingListByUser(user, baseToken, quoteToken, status, offset, limit, &tradingstate.Trade{}).([]*tradingstate.Trade)
+	return trades, nil
+}
+
+// getTrades returns a page of the trades of a pair in the given direction, descending if empty,
+// starting after the trade whose hash is the cursor, or from the first trade if the cursor is
+// empty. Trades are ordered by creation time then hash, so pages are stable while new trades are
+// recorded.
+func (tomox *TomoX) getTrades(baseToken, quoteToken common.Address, cursor common.Hash, limit int, direction string) (*TradesPage, error) {
+	if !tomox.IsSDKNode() {
+		return nil, errTradingHistoryUnavailable
+	}
+	if direction == "" {
+		direction = TradesDescending
+	}
+	if direction != TradesAscending && direction != TradesDescending {
+		return nil, errUnknownTradesDirection
+	}
+	_, limit = tradingHistoryPage(0, limit)
+	db := tomox.GetMongoReadDB()
+	var cursorTrade *tradingstate.Trade
+	if cursor != (common.Hash{}) {
+		val, err := db.GetObject(cursor, &tradingstate.Trade{})
+		if err != nil || val == nil {
+			return nil, errTradesCursorNotFound
+		}
+		cursorTrade = val.(*tradingstate.Trade)
+		if cursorTrade.BaseToken != baseToken || cursorTrade.QuoteToken != quoteToken {
+			return nil, errTradesCursorNotFound
+		}
+	}
+	trades, err := db.GetTradesByPair(baseToken, quoteToken, cursorTrade, limit, direction == TradesAscending)
+	if err != nil {
+		return nil, err
+	}
+	page := &TradesPage{Trades: trades}
+	if len(trades) == limit {
+		page.Next = trades[len(trades)-1].Hash
+	}
+	return page, nil
+}
@@ -0,0 +1,56 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestGetTrades(t *testing.T) {
+	tomox := New(&Config{DevDB: true})
+	defer tomox.Stop()
+	btc, usdt := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	now := time.Unix(1600000000, 0).UTC()
+
+	db := tomox.GetMongoDB()
+	db.InitBulk()
+	for i := int64(1); i <= 3; i++ {
+		trade := &tradingstate.Trade{
+			BaseToken:  btc,
+			QuoteToken: usdt,
+			Hash:       common.BigToHash(big.NewInt(i)),
+			Amount:     big.NewInt(1),
+			PricePoint: big.NewInt(1),
+			MakeFee:    big.NewInt(0),
+			TakeFee:    big.NewInt(0),
+			CreatedAt:  now.Add(time.Duration(i) * time.Second),
+		}
+		db.PutObject(trade.Hash, trade)
+	}
+	if err := db.CommitBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+
+	page, err := tomox.getTrades(btc, usdt, common.Hash{}, 2, "")
+	if err != nil || len(page.Trades) != 2 || page.Trades[0].Hash != common.BigToHash(big.NewInt(3)) || page.Next != common.BigToHash(big.NewInt(2)) {
+		t.Fatalf("wrong first page: %v (err %v)", page, err)
+	}
+	page, err = tomox.getTrades(btc, usdt, page.Next, 2, TradesDescending)
+	if err != nil || len(page.Trades) != 1 || page.Trades[0].Hash != common.BigToHash(big.NewInt(1)) || page.Next != (common.Hash{}) {
+		t.Fatalf("wrong last page: %v (err %v)", page, err)
+	}
+	page, err = tomox.getTrades(btc, usdt, common.BigToHash(big.NewInt(1)), 0, TradesAscending)
+	if err != nil || len(page.Trades) != 2 || page.Trades[0].Hash != common.BigToHash(big.NewInt(2)) {
+		t.Fatalf("wrong ascending page: %v (err %v)", page, err)
+	}
+
+	if _, err := tomox.getTrades(btc, usdt, common.Hash{}, 2, "up"); err != errUnknownTradesDirection {
+		t.Errorf("unexpected error for an unknown direction: %v", err)
+	}
+	if _, err := tomox.getTrades(btc, common.HexToAddress("0x3"), common.BigToHash(big.NewInt(1)), 2, ""); err != errTradesCursorNotFound {
+		t.Errorf("unexpected error for a cursor of another pair: %v", err)
+	}
+}
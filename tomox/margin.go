@@ -0,0 +1,33 @@
+package tomox
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// ApplyMarginOrder processes the spot order of a margin item as a limit order, once the lending
+// engine has borrowed its leveraged part. Unlike ApplyOrder, the order isn't signed by the user nor
+// counted by its trading nonce: the margin item carrying it is. The order is rejected, without error,
+// if its relayer doesn't list the pair or its notional is below the minimum notional of the pair.
+// The caller reverts the state on rejection.
+func (tomox *TomoX) ApplyMarginOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	if !tradingstate.IsValidRelayer(statedb, order.ExchangeAddress) {
+		log.Debug("Reject margin order of an invalid relayer", "relayer", order.ExchangeAddress.Hex())
+		return nil, []*tradingstate.OrderItem{order}, nil
+	}
+	if err := tradingstate.VerifyPair(statedb, order.ExchangeAddress, order.BaseToken, order.QuoteToken); err != nil {
+		log.Debug("Reject margin order of an unlisted pair", "err", err)
+		return nil, []*tradingstate.OrderItem{order}, nil
+	}
+	if tomox.belowMinNotional(header, chain, statedb, tradingStateDB, orderBook, order.BaseToken, order.Quantity, order.Price) {
+		log.Debug("Reject margin order below the minimum notional", "quantity", order.Quantity, "price", order.Price)
+		order.RejectReason = tradingstate.RejectReasonMinNotional
+		return nil, []*tradingstate.OrderItem{order}, nil
+	}
+	log.Debug("Process margin order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
+	return tomox.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+}
@@ -0,0 +1,58 @@
+package tomox
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// orderNotional returns the value in quote token of a quantity of base token at a price.
+func orderNotional(quantity *big.Int, price *big.Int, baseTokenDecimal *big.Int) *big.Int {
+	return new(big.Int).Div(new(big.Int).Mul(quantity, price), baseTokenDecimal)
+}
+
+// belowMinNotional returns whether a quantity of base token at a price is below the minimum
+// notional of the order book.
+//
+// From TIPTomoXMinNotional, the owner of a relayer listing a pair may set the minimum notional of
+// its orders, their quantity times their price in quote token. The orders with a notional below it
+// are rejected with MIN_NOTIONAL, and the quantity left by a partial fill below it is dust: a
+// resting order is cancelled and the rest of a new order isn't added to the order book, both being
+// rejected with DUST, so that the order books don't keep crumbs nobody will match.
+func (tomox *TomoX) belowMinNotional(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, baseToken common.Address, quantity *big.Int, price *big.Int) bool {
+	if !chain.Config().IsTIPTomoXMinNotional(header.Number) {
+		return false
+	}
+	minNotional := tradingStateDB.GetMinNotional(orderBook)
+	if minNotional.Sign() == 0 {
+		return false
+	}
+	baseTokenDecimal, err := tomox.GetTokenDecimal(chain, statedb, baseToken)
+	if err != nil || baseTokenDecimal.Sign() == 0 {
+		return false
+	}
+	return orderNotional(quantity, price, baseTokenDecimal).Cmp(minNotional) < 0
+}
+
+// hasMinNotional returns whether an order type has a price from which the notional of the orders
+// is checked: market and trailing stop orders don't.
+func hasMinNotional(orderType string) bool {
+	switch orderType {
+	case tradingstate.Limit, tradingstate.PostOnly, tradingstate.StopLoss, tradingstate.TakeProfit:
+		return true
+	}
+	return false
+}
+
+// minNotional returns the minimum notional of the orders of a pair at the current block.
+func (tomox *TomoX) minNotional(baseToken common.Address, quoteToken common.Address) (*big.Int, error) {
+	_, tradingState, err := tomox.currentTradingState()
+	if err != nil {
+		return nil, err
+	}
+	return tradingState.GetMinNotional(tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)), nil
+}
@@ -0,0 +1,49 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestMinNotional(t *testing.T) {
+	tomox := New(&Config{DataDir: t.TempDir()})
+	baseToken, quoteToken := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	tomox.tokenDecimalCache.Add(baseToken, big.NewInt(100))
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	header := &types.Header{Number: new(big.Int).Set(common.TIPTomoXMinNotional)}
+
+	// 250 base units at 2 quote tokens per 100 base units are 5 quote tokens
+	if tomox.belowMinNotional(header, configChain{}, nil, tradingStateDB, orderBook, baseToken, big.NewInt(250), big.NewInt(2)) {
+		t.Fatalf("order below the minimum notional of a pair without one")
+	}
+	tradingStateDB.SetMinNotional(orderBook, big.NewInt(6))
+	if !tomox.belowMinNotional(header, configChain{}, nil, tradingStateDB, orderBook, baseToken, big.NewInt(250), big.NewInt(2)) {
+		t.Errorf("order of notional 5 not below the minimum notional 6")
+	}
+	if tomox.belowMinNotional(header, configChain{}, nil, tradingStateDB, orderBook, baseToken, big.NewInt(300), big.NewInt(2)) {
+		t.Errorf("order of notional 6 below the minimum notional 6")
+	}
+	before := &types.Header{Number: new(big.Int).Sub(common.TIPTomoXMinNotional, common.Big1)}
+	if tomox.belowMinNotional(before, configChain{}, nil, tradingStateDB, orderBook, baseToken, big.NewInt(250), big.NewInt(2)) {
+		t.Errorf("minimum notional enforced before TIPTomoXMinNotional")
+	}
+
+	// the dust left by a limit order isn't added to the order book
+	order := &tradingstate.OrderItem{BaseToken: baseToken, QuoteToken: quoteToken, Side: tradingstate.Bid, Type: tradingstate.Limit, Quantity: big.NewInt(250), Price: big.NewInt(2)}
+	_, rejects, err := tomox.processLimitOrder(header, common.Address{}, configChain{}, nil, tradingStateDB, orderBook, order)
+	if err != nil {
+		t.Fatalf("failed to process limit order: %v", err)
+	}
+	if len(rejects) != 1 || rejects[0].RejectReason != tradingstate.RejectReasonDust {
+		t.Fatalf("unexpected rejects: %v", rejects)
+	}
+	if bid, _ := tradingStateDB.GetBestBidPrice(orderBook); bid.Sign() != 0 {
+		t.Errorf("dust added to the order book at %v", bid)
+	}
+}
@@ -0,0 +1,213 @@
+package tomox
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// orderBookEventChanSize is the size of the channel receiving the chain head events of an order
+// book subscription.
+const orderBookEventChanSize = 16
+
+var (
+	errTradingStateUnavailable = errors.New("trading state is unavailable")
+	errChainHeadsUnavailable   = errors.New("chain head events are unavailable")
+)
+
+// blockChain is the subset of the chain used to serve the order books from the trading state.
+type blockChain interface {
+	consensus.ChainContext
+	CurrentBlock() *types.Block
+}
+
+// chainHeadSubscriber is implemented by the chains announcing their new head blocks.
+type chainHeadSubscriber interface {
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// SetChain injects the blockchain used to serve the order books from the trading state.
+func (tomox *TomoX) SetChain(chain blockChain) {
+	tomox.chain = chain
+}
+
+// OrderBookLevel is the aggregated volume of an order book at one price.
+type OrderBookLevel struct {
+	Price  *big.Int `json:"price"`
+	Volume *big.Int `json:"volume"`
+}
+
+// OrderBook is the depth of the order book of a pair in the trading state of a block.
+// Bids are sorted by descending price, asks by ascending price.
+type OrderBook struct {
+	BaseToken   common.Address   `json:"baseToken"`
+	QuoteToken  common.Address   `json:"quoteToken"`
+	BlockHash   common.Hash      `json:"blockHash"`
+	BlockNumber uint64           `json:"blockNumber"`
+	Bids        []OrderBookLevel `json:"bids"`
+	Asks        []OrderBookLevel `json:"asks"`
+}
+
+// OrderBookDiff holds the levels of the order book of a pair changed by a block since the
+// previous diff, sorted like the levels of OrderBook. A level removed from the book has a zero
+// volume.
+type OrderBookDiff struct {
+	BaseToken   common.Address   `json:"baseToken"`
+	QuoteToken  common.Address   `json:"quoteToken"`
+	BlockHash   common.Hash      `json:"blockHash"`
+	BlockNumber uint64           `json:"blockNumber"`
+	Bids        []OrderBookLevel `json:"bids"`
+	Asks        []OrderBookLevel `json:"asks"`
+}
+
+// orderBookAt builds the order book of a pair from the trading state of a block.
+func (tomox *TomoX) orderBookAt(block *types.Block, baseToken common.Address, quoteToken common.Address) (*OrderBook, error) {
+	tradingState, err := tomox.tradingStateAt(block)
+	if err != nil {
+		return nil, err
+	}
+	bids, asks, err := orderBookDepth(tradingState, tradingstate.GetTradingOrderBookHash(baseToken, quoteToken))
+	if err != nil {
+		return nil, err
+	}
+	return &OrderBook{
+		BaseToken:   baseToken,
+		QuoteToken:  quoteToken,
+		BlockHash:   block.Hash(),
+		BlockNumber: block.NumberU64(),
+		Bids:        bids,
+		Asks:        asks,
+	}, nil
+}
+
+// currentOrderBook builds the order book of a pair from the trading state of the current block.
+func (tomox *TomoX) currentOrderBook(baseToken common.Address, quoteToken common.Address) (*OrderBook, error) {
+	block, err := tomox.currentBlock()
+	if err != nil {
+		return nil, err
+	}
+	return tomox.orderBookAt(block, baseToken, quoteToken)
+}
+
+// currentBlock returns the current block of the chain serving the trading state.
+func (tomox *TomoX) currentBlock() (*types.Block, error) {
+	if tomox.chain == nil {
+		return nil, errTradingStateUnavailable
+	}
+	block := tomox.chain.CurrentBlock()
+	if block == nil {
+		return nil, errors.New("Current block not found")
+	}
+	return block, nil
+}
+
+// tradingStateAt returns the trading state of a block.
+func (tomox *TomoX) tradingStateAt(block *types.Block) (*tradingstate.TradingStateDB, error) {
+	if tomox.chain == nil {
+		return nil, errTradingStateUnavailable
+	}
+	author, err := tomox.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	return tomox.GetTradingState(block, author)
+}
+
+// currentTradingState returns the current block and its trading state.
+func (tomox *TomoX) currentTradingState() (*types.Block, *tradingstate.TradingStateDB, error) {
+	block, err := tomox.currentBlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	tradingState, err := tomox.tradingStateAt(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, tradingState, nil
+}
+
+// orderBookDepth returns the bid and ask levels of an order book, empty if the order book has
+// never been traded.
+func orderBookDepth(tradingState *tradingstate.TradingStateDB, orderBook common.Hash) ([]OrderBookLevel, []OrderBookLevel, error) {
+	if !tradingState.Exist(orderBook) {
+		return []OrderBookLevel{}, []OrderBookLevel{}, nil
+	}
+	bids, err := tradingState.GetBids(orderBook)
+	if err != nil {
+		return nil, nil, err
+	}
+	asks, err := tradingState.GetAsks(orderBook)
+	if err != nil {
+		return nil, nil, err
+	}
+	return orderBookLevels(bids, true), orderBookLevels(asks, false), nil
+}
+
+// orderBookLevels returns the non-empty levels of an order book side sorted by price.
+func orderBookLevels(volumes map[*big.Int]*big.Int, descending bool) []OrderBookLevel {
+	levels := make([]OrderBookLevel, 0, len(volumes))
+	for price, volume := range volumes {
+		if volume != nil && volume.Sign() > 0 {
+			levels = append(levels, OrderBookLevel{Price: price, Volume: volume})
+		}
+	}
+	sortOrderBookLevels(levels, descending)
+	return levels
+}
+
+func sortOrderBookLevels(levels []OrderBookLevel, descending bool) {
+	sort.Slice(levels, func(i, j int) bool {
+		if descending {
+			return levels[i].Price.Cmp(levels[j].Price) > 0
+		}
+		return levels[i].Price.Cmp(levels[j].Price) < 0
+	})
+}
+
+// diffOrderBookLevels returns the levels of an order book side changed from prev to next: the
+// levels of next with a new volume, and the levels of prev missing from next with a zero volume.
+func diffOrderBookLevels(prev []OrderBookLevel, next []OrderBookLevel, descending bool) []OrderBookLevel {
+	volumes := make(map[string]*big.Int, len(prev))
+	for _, level := range prev {
+		volumes[level.Price.String()] = level.Volume
+	}
+	diff := []OrderBookLevel{}
+	for _, level := range next {
+		price := level.Price.String()
+		if volume, ok := volumes[price]; !ok || volume.Cmp(level.Volume) != 0 {
+			diff = append(diff, level)
+		}
+		delete(volumes, price)
+	}
+	for _, level := range prev {
+		if _, ok := volumes[level.Price.String()]; ok {
+			diff = append(diff, OrderBookLevel{Price: level.Price, Volume: new(big.Int)})
+		}
+	}
+	sortOrderBookLevels(diff, descending)
+	return diff
+}
+
+// diffOrderBook returns the levels changed from the prev order book to the next one, nil if none.
+func diffOrderBook(prev *OrderBook, next *OrderBook) *OrderBookDiff {
+	bids := diffOrderBookLevels(prev.Bids, next.Bids, true)
+	asks := diffOrderBookLevels(prev.Asks, next.Asks, false)
+	if len(bids) == 0 && len(asks) == 0 {
+		return nil
+	}
+	return &OrderBookDiff{
+		BaseToken:   next.BaseToken,
+		QuoteToken:  next.QuoteToken,
+		BlockHash:   next.BlockHash,
+		BlockNumber: next.BlockNumber,
+		Bids:        bids,
+		Asks:        asks,
+	}
+}
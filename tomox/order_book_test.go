@@ -0,0 +1,72 @@
+package tomox
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func levels(priceVolumes ...int64) []OrderBookLevel {
+	result := []OrderBookLevel{}
+	for i := 0; i < len(priceVolumes); i += 2 {
+		result = append(result, OrderBookLevel{Price: big.NewInt(priceVolumes[i]), Volume: big.NewInt(priceVolumes[i+1])})
+	}
+	return result
+}
+
+func TestOrderBookDepth(t *testing.T) {
+	orderBook := common.StringToHash("BTC/USDT")
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	bids, asks, err := orderBookDepth(tradingStateDB, orderBook)
+	if err != nil || len(bids) != 0 || len(asks) != 0 {
+		t.Fatalf("unexpected depth of an empty order book: bids %v, asks %v, err %v", bids, asks, err)
+	}
+	for i, order := range []struct {
+		side     string
+		price    int64
+		quantity int64
+	}{
+		{tradingstate.Bid, 90, 1}, {tradingstate.Bid, 95, 2}, {tradingstate.Bid, 90, 3},
+		{tradingstate.Ask, 110, 4}, {tradingstate.Ask, 105, 5},
+	} {
+		orderId := uint64(i + 1)
+		tradingStateDB.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(orderId)), tradingstate.OrderItem{
+			OrderID:  orderId,
+			Side:     order.side,
+			Price:    big.NewInt(order.price),
+			Quantity: big.NewInt(order.quantity),
+		})
+	}
+	bids, asks, err = orderBookDepth(tradingStateDB, orderBook)
+	if err != nil {
+		t.Fatalf("failed to get order book depth: %v", err)
+	}
+	if want := levels(95, 2, 90, 4); !reflect.DeepEqual(bids, want) {
+		t.Errorf("unexpected bids: have %v, want %v", bids, want)
+	}
+	if want := levels(105, 5, 110, 4); !reflect.DeepEqual(asks, want) {
+		t.Errorf("unexpected asks: have %v, want %v", asks, want)
+	}
+}
+
+func TestDiffOrderBook(t *testing.T) {
+	prev := &OrderBook{Bids: levels(95, 2, 90, 4), Asks: levels(105, 5, 110, 4)}
+	if diff := diffOrderBook(prev, &OrderBook{Bids: levels(95, 2, 90, 4), Asks: levels(105, 5, 110, 4)}); diff != nil {
+		t.Fatalf("unexpected diff of an unchanged order book: %v", diff)
+	}
+	next := &OrderBook{BlockNumber: 7, Bids: levels(100, 1, 95, 2), Asks: levels(105, 3, 110, 4)}
+	diff := diffOrderBook(prev, next)
+	if diff == nil || diff.BlockNumber != 7 {
+		t.Fatalf("unexpected diff: %v", diff)
+	}
+	if want := levels(100, 1, 90, 0); !reflect.DeepEqual(diff.Bids, want) {
+		t.Errorf("unexpected bids diff: have %v, want %v", diff.Bids, want)
+	}
+	if want := levels(105, 3); !reflect.DeepEqual(diff.Asks, want) {
+		t.Errorf("unexpected asks diff: have %v, want %v", diff.Asks, want)
+	}
+}
@@ -0,0 +1,138 @@
+package tomox
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Kinds of the order events.
+const (
+	OrderEventMatched  = "MATCHED"
+	OrderEventRejected = "REJECTED"
+)
+
+// orderEventQueueSize is the number of order events queued for a slow subscriber before they are
+// dropped.
+const orderEventQueueSize = 256
+
+var errTomoXEventsUnavailable = errors.New("tomox events are unavailable")
+
+// tomoxEventSubscriber is implemented by the chains posting the TomoX events of their blocks.
+type tomoxEventSubscriber interface {
+	TomoXEvents() *core.TomoXEventBus
+}
+
+// OrderEvent is the RPC representation of an order processed by the matching engine in a block.
+type OrderEvent struct {
+	Kind        string                  `json:"kind"`
+	BlockNumber uint64                  `json:"blockNumber"`
+	BlockHash   common.Hash             `json:"blockHash"`
+	TxHash      common.Hash             `json:"txHash"`
+	Order       *tradingstate.OrderItem `json:"order"`
+	Trades      []map[string]string     `json:"trades,omitempty"` // trades of a matched order
+}
+
+// newMatchedOrderEvent returns the order event of an order matched in a block, nil if the order
+// isn't an order of the pair.
+func newMatchedOrderEvent(ev core.OrderMatchedEvent, baseToken, quoteToken common.Address) *OrderEvent {
+	if ev.Order == nil || ev.Order.BaseToken != baseToken || ev.Order.QuoteToken != quoteToken {
+		return nil
+	}
+	return &OrderEvent{
+		Kind:        OrderEventMatched,
+		BlockNumber: ev.Block.NumberU64(),
+		BlockHash:   ev.Block.Hash(),
+		TxHash:      ev.TxHash,
+		Order:       ev.Order,
+		Trades:      ev.Trades,
+	}
+}
+
+// newRejectedOrderEvent returns the order event of an order rejected in a block, nil if the order
+// isn't an order of the pair.
+func newRejectedOrderEvent(ev core.OrderRejectedEvent, baseToken, quoteToken common.Address) *OrderEvent {
+	if ev.Order == nil || ev.Order.BaseToken != baseToken || ev.Order.QuoteToken != quoteToken {
+		return nil
+	}
+	return &OrderEvent{
+		Kind:        OrderEventRejected,
+		BlockNumber: ev.Block.NumberU64(),
+		BlockHash:   ev.Block.Hash(),
+		TxHash:      ev.TxHash,
+		Order:       ev.Order,
+	}
+}
+
+// EventQueue forwards the events of a subscription to a consumer which may be slow, such as a RPC
+// client, without blocking the feed delivering them, as the TomoX events are posted by the chain
+// import. The events are queued, and dropped while the queue is full.
+type EventQueue struct {
+	dropped uint64 // accessed atomically, first for alignment
+	events  chan interface{}
+
+	quit     chan struct{}
+	done     chan struct{}
+	quitOnce sync.Once
+}
+
+// NewEventQueue creates an event queue of the given size, which forwards the events to send until
+// it is closed or send fails.
+func NewEventQueue(size int, send func(interface{}) error) *EventQueue {
+	q := &EventQueue{
+		events: make(chan interface{}, size),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go q.loop(send)
+	return q
+}
+
+// Push queues an event. It returns false if the event is dropped because the queue is full.
+func (q *EventQueue) Push(ev interface{}) bool {
+	select {
+	case q.events <- ev:
+		return true
+	default:
+		atomic.AddUint64(&q.dropped, 1)
+		return false
+	}
+}
+
+// Dropped returns the number of events dropped so far.
+func (q *EventQueue) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// Close stops forwarding the events and waits for the event being sent.
+func (q *EventQueue) Close() {
+	q.quitOnce.Do(func() { close(q.quit) })
+	<-q.done
+}
+
+func (q *EventQueue) loop(send func(interface{}) error) {
+	defer close(q.done)
+	for {
+		select {
+		case ev := <-q.events:
+			if err := send(ev); err != nil {
+				return
+			}
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// pushOrderEvent queues an order event of a RPC subscription, logging the first event dropped by
+// a subscriber which can't keep up.
+func pushOrderEvent(queue *EventQueue, event *OrderEvent) {
+	if !queue.Push(event) && queue.Dropped() == 1 {
+		log.Warn("Dropping order events of a slow subscriber", "block", event.BlockNumber)
+	}
+}
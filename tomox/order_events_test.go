@@ -0,0 +1,77 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestOrderEvents(t *testing.T) {
+	var (
+		base   = common.HexToAddress("0x01")
+		quote  = common.HexToAddress("0x02")
+		other  = common.HexToAddress("0x03")
+		block  = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7)})
+		txHash = common.HexToHash("0x0a")
+		order  = &tradingstate.OrderItem{BaseToken: base, QuoteToken: quote}
+		trades = []map[string]string{{tradingstate.TradeQuantity: "1"}}
+	)
+	matched := newMatchedOrderEvent(core.OrderMatchedEvent{Block: block, TxHash: txHash, Order: order, Trades: trades}, base, quote)
+	if matched == nil {
+		t.Fatal("matched order of the pair filtered out")
+	}
+	if matched.Kind != OrderEventMatched || matched.BlockNumber != 7 || matched.BlockHash != block.Hash() || matched.TxHash != txHash || len(matched.Trades) != 1 {
+		t.Errorf("matched order event mismatch: %+v", matched)
+	}
+	rejected := newRejectedOrderEvent(core.OrderRejectedEvent{Block: block, TxHash: txHash, Order: order}, base, quote)
+	if rejected == nil || rejected.Kind != OrderEventRejected || rejected.Order != order {
+		t.Errorf("rejected order event mismatch: %+v", rejected)
+	}
+	if event := newMatchedOrderEvent(core.OrderMatchedEvent{Block: block, Order: order}, base, other); event != nil {
+		t.Errorf("matched order of another pair not filtered out: %+v", event)
+	}
+	if event := newRejectedOrderEvent(core.OrderRejectedEvent{Block: block, Order: order}, other, quote); event != nil {
+		t.Errorf("rejected order of another pair not filtered out: %+v", event)
+	}
+}
+
+func TestEventQueueDropsEvents(t *testing.T) {
+	var (
+		blocked = make(chan struct{})
+		sent    = make(chan interface{}, 4)
+	)
+	queue := NewEventQueue(2, func(ev interface{}) error {
+		<-blocked
+		sent <- ev
+		return nil
+	})
+	// the first event is taken by the stuck consumer, the next two are queued, the last dropped
+	for i := 0; i < 4; i++ {
+		queue.Push(i)
+		if i == 0 {
+			for len(queue.events) != 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	if dropped := queue.Dropped(); dropped != 1 {
+		t.Errorf("dropped events mismatch: have %d, want 1", dropped)
+	}
+	close(blocked)
+	for i := 0; i < 3; i++ {
+		select {
+		case ev := <-sent:
+			if ev != i {
+				t.Errorf("event %d mismatch: have %v", i, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not sent", i)
+		}
+	}
+	queue.Close()
+}
@@ -29,7 +29,17 @@ func (tomox *TomoX) CommitOrder(header *types.Header, coinbase common.Address, c
 	return trades, rejects, err
 }
 
+// ApplyOrder matches an order against its order book, recording the outcome in the engine statistics.
 func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	start := time.Now()
+	trades, rejects, err := tomox.applyOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+	if err == nil {
+		tomox.engineStats.record(header, order, trades, rejects, time.Since(start))
+	}
+	return trades, rejects, err
+}
+
+func (tomox *TomoX) applyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		rejects []*tradingstate.OrderItem
 		trades  []map[string]string
@@ -56,7 +66,61 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 		}
 	}()
 
-	if err := order.VerifyOrder(statedb); err != nil {
+	if err := order.VerifyOrder(statedb, tomox.orderSigner(chain.Config(), header.Number)); err != nil {
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	isStopOrder := order.Type == tradingstate.StopLoss || order.Type == tradingstate.TakeProfit
+	if chain.Config().IsTIPTomoXStopOrders(header.Number) {
+		// the stop orders triggered by the medium price of the last epoch are processed before the order
+		trades, rejects = tomox.processTriggeredStopOrders(header, coinbase, chain, statedb, tradingStateDB, orderBook)
+	} else if isStopOrder {
+		log.Debug("Reject stop order before TIPTomoXStopOrders", "type", order.Type)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.Type == tradingstate.PostOnly && !chain.Config().IsTIPTomoXPostOnly(header.Number) {
+		log.Debug("Reject post-only order before TIPTomoXPostOnly", "type", order.Type)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.Type == tradingstate.FeeTierOrder {
+		if !chain.Config().IsTIPTomoXFeeTiers(header.Number) || order.Status != tradingstate.OrderNew {
+			log.Debug("Reject fee tier order", "status", order.Status)
+			rejects = append(rejects, order)
+		} else if err := processFeeTierOrder(tradingStateDB, order); err != nil {
+			log.Debug("Reject fee tier order", "err", err, "order", tradingstate.ToJSON(order))
+			rejects = append(rejects, order)
+		}
+		return trades, rejects, nil
+	}
+	if order.Type == tradingstate.MinNotional {
+		if !chain.Config().IsTIPTomoXMinNotional(header.Number) || order.Status != tradingstate.OrderNew {
+			log.Debug("Reject minimum notional order", "status", order.Status)
+			rejects = append(rejects, order)
+		} else {
+			tradingStateDB.SetMinNotional(orderBook, order.Quantity)
+			log.Debug("Set minimum notional", "orderBook", orderBook.Hex(), "minNotional", order.Quantity)
+		}
+		return trades, rejects, nil
+	}
+	if order.Type == tradingstate.PriceBandOrder {
+		if !chain.Config().IsTIPTomoXPriceBand(header.Number) || order.Status != tradingstate.OrderNew {
+			log.Debug("Reject price band order", "status", order.Status)
+			rejects = append(rejects, order)
+		} else {
+			tradingStateDB.SetPriceBand(orderBook, tradingstate.PriceBand{Band: order.Quantity, Window: order.Price.Uint64()})
+			log.Debug("Set price band", "orderBook", orderBook.Hex(), "band", order.Quantity, "window", order.Price)
+		}
+		return trades, rejects, nil
+	}
+	isTrailingStopOrder := order.Type == tradingstate.TrailingStop
+	if chain.Config().IsTIPTomoXTrailingStopOrders(header.Number) {
+		newTrades, newRejects := tomox.processTriggeredTrailingStopOrders(header, coinbase, chain, statedb, tradingStateDB, orderBook)
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+	} else if isTrailingStopOrder {
+		log.Debug("Reject trailing stop order before TIPTomoXTrailingStopOrders", "type", order.Type)
 		rejects = append(rejects, order)
 		return trades, rejects, nil
 	}
@@ -80,31 +144,76 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 		rejects = append(rejects, order)
 		return trades, rejects, nil
 	}
+	if hasMinNotional(order.Type) && tomox.belowMinNotional(header, chain, statedb, tradingStateDB, orderBook, order.BaseToken, order.Quantity, order.Price) {
+		log.Debug("Reject order below the minimum notional", "quantity", order.Quantity, "price", order.Price)
+		order.RejectReason = tradingstate.RejectReasonMinNotional
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if hasPriceBand(order.Type) && outsidePriceBand(header, chain, tradingStateDB, orderBook, order.Price) {
+		log.Debug("Reject order outside the price band", "price", order.Price)
+		order.RejectReason = tradingstate.RejectReasonPriceBand
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	var (
+		newTrades  []map[string]string
+		newRejects []*tradingstate.OrderItem
+	)
 	orderType := order.Type
 	// if we do not use auto-increment orderid, we must set price slot to avoid conflict
 	if orderType == tradingstate.Market {
 		log.Debug("Process maket order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
-		trades, rejects, err = tomox.processMarketOrder(coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		newTrades, newRejects, err = tomox.processMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
 		if err != nil {
 			log.Debug("Reject market order", "err", err, "order", tradingstate.ToJSON(order))
-			trades = []map[string]string{}
-			rejects = append(rejects, order)
+		}
+	} else if isStopOrder {
+		log.Debug("Process stop order", "type", orderType, "side", order.Side, "quantity", order.Quantity, "trigger", order.Price)
+		newTrades, newRejects, err = tomox.processStopOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		if err != nil {
+			log.Debug("Reject stop order", "err", err, "order", tradingstate.ToJSON(order))
+		}
+	} else if orderType == tradingstate.PostOnly && postOnlyOrderCrosses(tradingStateDB, orderBook, order) {
+		log.Debug("Reject post-only order crossing the spread", "side", order.Side, "price", order.Price)
+		order.RejectReason = tradingstate.RejectReasonPostOnly
+		newRejects = append(newRejects, order)
+	} else if isTrailingStopOrder {
+		log.Debug("Process trailing stop order", "side", order.Side, "quantity", order.Quantity, "delta", order.Price)
+		err = tomox.processTrailingStopOrder(tradingStateDB, orderBook, order)
+		if err != nil {
+			log.Debug("Reject trailing stop order", "err", err, "order", tradingstate.ToJSON(order))
 		}
 	} else {
 		log.Debug("Process limit order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
-		trades, rejects, err = tomox.processLimitOrder(coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		newTrades, newRejects, err = tomox.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
 		if err != nil {
 			log.Debug("Reject limit order", "err", err, "order", tradingstate.ToJSON(order))
-			trades = []map[string]string{}
-			rejects = append(rejects, order)
 		}
 	}
+	if err != nil {
+		// the state is reverted, the triggered stop orders included
+		return []map[string]string{}, []*tradingstate.OrderItem{order}, nil
+	}
+	trades = append(trades, newTrades...)
+	rejects = append(rejects, newRejects...)
 
 	return trades, rejects, nil
 }
 
+// postOnlyOrderCrosses returns whether a post-only order would match a resting order of the order book
+// instead of resting in the book as a maker.
+func postOnlyOrderCrosses(tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) bool {
+	if order.Side == tradingstate.Bid {
+		bestAsk, _ := tradingStateDB.GetBestAskPrice(orderBook)
+		return bestAsk.Sign() > 0 && order.Price.Cmp(bestAsk) >= 0
+	}
+	bestBid, _ := tradingStateDB.GetBestBidPrice(orderBook)
+	return bestBid.Sign() > 0 && order.Price.Cmp(bestBid) <= 0
+}
+
 // processMarketOrder : process the market order
-func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processMarketOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		trades     []map[string]string
 		newTrades  []map[string]string
@@ -120,7 +229,7 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 		bestPrice, volume := tradingStateDB.GetBestAskPrice(orderBook)
 		log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && bestPrice.Cmp(zero) > 0 {
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, bestPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, bestPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -133,7 +242,7 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 		bestPrice, volume := tradingStateDB.GetBestBidPrice(orderBook)
 		log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && bestPrice.Cmp(zero) > 0 {
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, bestPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, bestPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -148,7 +257,7 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 
 // processLimitOrder : process the limit order, can change the quote
 // If not care for performance, we should make a copy of quote to prevent further reference problem
-func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processLimitOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		trades     []map[string]string
 		newTrades  []map[string]string
@@ -168,7 +277,7 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 		log.Debug("processLimitOrder ", "side", side, "minPrice", minPrice, "orderPrice", price, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && price.Cmp(minPrice) >= 0 && minPrice.Cmp(zero) > 0 {
 			log.Debug("Min price in asks tree", "price", minPrice.String())
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, minPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, minPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -183,7 +292,7 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 		log.Debug("processLimitOrder ", "side", side, "maxPrice", maxPrice, "orderPrice", price, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && price.Cmp(maxPrice) <= 0 && maxPrice.Cmp(zero) > 0 {
 			log.Debug("Max price in bids tree", "price", maxPrice.String())
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, maxPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, maxPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -194,7 +303,11 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 			log.Debug("processLimitOrder ", "side", side, "maxPrice", maxPrice, "orderPrice", price, "volume", volume)
 		}
 	}
-	if quantityToTrade.Cmp(zero) > 0 {
+	if quantityToTrade.Cmp(zero) > 0 && tomox.belowMinNotional(header, chain, statedb, tradingStateDB, orderBook, order.BaseToken, quantityToTrade, price) {
+		log.Debug("Drop dust left by the order after matching", "side", order.Side, "quantity", quantityToTrade, "price", price)
+		order.RejectReason = tradingstate.RejectReasonDust
+		rejects = append(rejects, order)
+	} else if quantityToTrade.Cmp(zero) > 0 {
 		orderId := tradingStateDB.GetNonce(orderBook)
 		order.OrderID = orderId + 1
 		order.Quantity = quantityToTrade
@@ -207,7 +320,7 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 }
 
 // processOrderList : process the order list
-func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, quantityStillToTrade *big.Int, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processOrderList(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, quantityStillToTrade *big.Int, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
 	quantityToTrade := tradingstate.CloneBigInt(quantityStillToTrade)
 	log.Debug("Process matching between order and orderlist", "quantityToTrade", quantityToTrade)
 	var (
@@ -254,7 +367,7 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 		} else {
 			quotePrice = common.BasePrice
 		}
-		tradedQuantity, rejectMaker, settleBalanceResult, err := tomox.getTradeQuantity(quotePrice, coinbase, chain, statedb, order, &oldestOrder, maxTradedQuantity)
+		tradedQuantity, rejectMaker, settleBalanceResult, err := tomox.getTradeQuantity(header, quotePrice, coinbase, chain, statedb, tradingStateDB, order, &oldestOrder, maxTradedQuantity)
 		if err != nil && err == tradingstate.ErrQuantityTradeTooSmall {
 			if tradedQuantity.Cmp(maxTradedQuantity) == 0 {
 				if quantityToTrade.Cmp(amount) == 0 { // reject Taker & maker
@@ -349,6 +462,16 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 			}
 
 			tradingStateDB.SetMediumPrice(orderBook, newAveragePrice, newTotalQuantity)
+			recordTwapTrade(header, chain, tradingStateDB, orderBook, price, tradedQuantity)
+
+			if remaining := new(big.Int).Sub(amount, tradedQuantity); !rejectMaker && remaining.Sign() > 0 && tomox.belowMinNotional(header, chain, statedb, tradingStateDB, orderBook, oldestOrder.BaseToken, remaining, oldestOrder.Price) {
+				log.Debug("Cancel dust left by the maker order after matching", "orderId", orderId.Hex(), "quantity", remaining, "price", oldestOrder.Price)
+				oldestOrder.RejectReason = tradingstate.RejectReasonDust
+				rejects = append(rejects, &oldestOrder)
+				if err := tradingStateDB.CancelOrder(orderBook, &oldestOrder); err != nil {
+					return nil, nil, nil, err
+				}
+			}
 		}
 		if rejectMaker {
 			rejects = append(rejects, &oldestOrder)
@@ -361,7 +484,7 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 	return quantityToTrade, trades, rejects, nil
 }
 
-func (tomox *TomoX) getTradeQuantity(quotePrice *big.Int, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, quantityToTrade *big.Int) (*big.Int, bool, *tradingstate.SettleBalance, error) {
+func (tomox *TomoX) getTradeQuantity(header *types.Header, quotePrice *big.Int, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, quantityToTrade *big.Int) (*big.Int, bool, *tradingstate.SettleBalance, error) {
 	baseTokenDecimal, err := tomox.GetTokenDecimal(chain, statedb, makerOrder.BaseToken)
 	if err != nil || baseTokenDecimal.Sign() == 0 {
 		return tradingstate.Zero, false, nil, fmt.Errorf("Fail to get tokenDecimal. Token: %v . Err: %v", makerOrder.BaseToken.String(), err)
@@ -388,8 +511,7 @@ func (tomox *TomoX) getTradeQuantity(quotePrice *big.Int, coinbase common.Addres
 			return tradingstate.Zero, true, nil, nil
 		}
 	}
-	takerFeeRate := tradingstate.GetExRelayerFee(takerOrder.ExchangeAddress, statedb)
-	makerFeeRate := tradingstate.GetExRelayerFee(makerOrder.ExchangeAddress, statedb)
+	takerFeeRate, makerFeeRate := tradeFeeRates(header, chain, statedb, tradingStateDB, takerOrder, makerOrder)
 	var takerBalance, makerBalance *big.Int
 	switch takerOrder.Side {
 	case tradingstate.Bid:
@@ -412,6 +534,9 @@ func (tomox *TomoX) getTradeQuantity(quotePrice *big.Int, coinbase common.Addres
 		if err == nil {
 			err = DoSettleBalance(coinbase, takerOrder, makerOrder, settleBalanceResult, statedb)
 		}
+		if err == nil {
+			recordFeeVolume(header, chain, tradingStateDB, takerOrder, makerOrder, new(big.Int).Div(new(big.Int).Mul(quantity, makerOrder.Price), baseTokenDecimal))
+		}
 		return quantity, rejectMaker, settleBalanceResult, err
 	}
 	return quantity, rejectMaker, settleBalanceResult, nil
@@ -620,6 +745,24 @@ func (tomox *TomoX) ProcessCancelOrder(header *types.Header, tradingStateDB *tra
 	// order: basic order information (includes orderId, orderHash, baseToken, quoteToken) which user send to tomox to cancel order
 	// originOrder: full order information getting from order trie
 	originOrder := tradingStateDB.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)))
+	if originOrder.Hash != order.Hash && chain.Config().IsTIPTomoXStopOrders(header.Number) {
+		// the order may be a stop order which has not been triggered yet
+		if triggerBook := tradingstate.GetTradingTriggerBookHash(orderBook); tradingStateDB.Exist(triggerBook) {
+			if stopOrder := tradingStateDB.GetOrder(triggerBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID))); stopOrder.Hash == order.Hash {
+				originOrder = fromTriggerOrder(stopOrder)
+				orderBook = triggerBook
+			}
+		}
+	}
+	if originOrder.Hash != order.Hash && chain.Config().IsTIPTomoXTrailingStopOrders(header.Number) {
+		// the order may be a trailing stop order which has not been triggered yet
+		if trailingBook := tradingstate.GetTradingTrailingBookHash(orderBook); tradingStateDB.Exist(trailingBook) {
+			if trailingOrder := tradingStateDB.GetOrder(trailingBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID))); trailingOrder.Hash == order.Hash {
+				originOrder = fromTrailingOrder(trailingOrder)
+				orderBook = trailingBook
+			}
+		}
+	}
 	if originOrder == tradingstate.EmptyOrder {
 		return fmt.Errorf("order not found. OrderId: %v. Base: %s. Quote: %s", order.OrderID, order.BaseToken.Hex(), order.QuoteToken.Hex()), false
 	}
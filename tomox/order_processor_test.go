@@ -572,3 +572,32 @@ func TestGetTradeQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestPostOnlyOrderCrosses(t *testing.T) {
+	orderBook := common.StringToHash("BTC/USDT")
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	if postOnlyOrderCrosses(tradingStateDB, orderBook, &tradingstate.OrderItem{Side: tradingstate.Bid, Price: big.NewInt(100)}) {
+		t.Fatalf("post-only order crosses an empty order book")
+	}
+	tradingStateDB.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(1)), tradingstate.OrderItem{OrderID: 1, Side: tradingstate.Ask, Price: big.NewInt(101), Quantity: big.NewInt(1)})
+	tradingStateDB.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(2)), tradingstate.OrderItem{OrderID: 2, Side: tradingstate.Bid, Price: big.NewInt(99), Quantity: big.NewInt(1)})
+
+	tests := []struct {
+		side  string
+		price int64
+		want  bool
+	}{
+		{tradingstate.Bid, 100, false},
+		{tradingstate.Bid, 101, true},
+		{tradingstate.Bid, 102, true},
+		{tradingstate.Ask, 100, false},
+		{tradingstate.Ask, 99, true},
+		{tradingstate.Ask, 98, true},
+	}
+	for _, tt := range tests {
+		order := &tradingstate.OrderItem{Side: tt.side, Price: big.NewInt(tt.price)}
+		if got := postOnlyOrderCrosses(tradingStateDB, orderBook, order); got != tt.want {
+			t.Errorf("side %s price %d: have %v, want %v", tt.side, tt.price, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package tomox
+
+import (
+	"math/big"
+	"runtime"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+)
+
+// orderSenderCacheLimit is the number of order senders kept in memory, a few blocks of orders.
+const orderSenderCacheLimit = 16384
+
+// orderSender is a sender recovered by an order signer.
+type orderSender struct {
+	signer types.OrderSigner
+	from   common.Address
+}
+
+// cachedOrderSigner is an order signer looking the senders up in the sender cache of TomoX,
+// keyed by the hash of the order transaction, which covers its terms and its signature.
+type cachedOrderSigner struct {
+	types.OrderSigner
+	senders *lru.Cache
+}
+
+// Equal returns whether s2 is the same order signer, cached or not.
+func (s cachedOrderSigner) Equal(s2 types.OrderSigner) bool {
+	if cached, ok := s2.(cachedOrderSigner); ok {
+		s2 = cached.OrderSigner
+	}
+	return s.OrderSigner.Equal(s2)
+}
+
+// Sender returns the cached sender of the transaction, recovering and caching it if it's missing.
+func (s cachedOrderSigner) Sender(tx *types.OrderTransaction) (common.Address, error) {
+	hash := tx.Hash()
+	if cached, ok := s.senders.Get(hash); ok {
+		if sender := cached.(orderSender); sender.signer.Equal(s.OrderSigner) {
+			return sender.from, nil
+		}
+	}
+	from, err := s.OrderSigner.Sender(tx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	s.senders.Add(hash, orderSender{signer: s.OrderSigner, from: from})
+	return from, nil
+}
+
+// orderSigner returns the order signer of the given block, caching the senders it recovers.
+func (tomox *TomoX) orderSigner(config *params.ChainConfig, blockNumber *big.Int) types.OrderSigner {
+	return cachedOrderSigner{OrderSigner: types.MakeOrderSigner(config, blockNumber), senders: tomox.orderSenders}
+}
+
+// OrderSenderWorkers returns the number of order signatures recovered concurrently before the
+// pending orders are matched.
+func (tomox *TomoX) OrderSenderWorkers() int {
+	if tomox.orderSenderWorkers > 0 {
+		return tomox.orderSenderWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// recoverOrderSenders recovers the senders of the pending order transactions on a pool of
+// workers, so that matching them finds their senders cached.
+func (tomox *TomoX) recoverOrderSenders(signer types.OrderSigner, pending map[common.Address]types.OrderTransactions) {
+	txs := make(chan *types.OrderTransaction)
+	var wg sync.WaitGroup
+	for i := 0; i < tomox.OrderSenderWorkers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tx := range txs {
+				types.OrderSender(signer, tx)
+			}
+		}()
+	}
+	for _, accTxs := range pending {
+		for _, tx := range accTxs {
+			txs <- tx
+		}
+	}
+	close(txs)
+	wg.Wait()
+}
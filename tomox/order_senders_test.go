@@ -0,0 +1,56 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestRecoverOrderSenders(t *testing.T) {
+	tomox := New(&Config{DataDir: t.TempDir(), OrderSenderWorkers: 4})
+	signer := tomox.orderSigner(params.TestChainConfig, big.NewInt(1))
+
+	pending := make(map[common.Address]types.OrderTransactions)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		user := crypto.PubkeyToAddress(key.PublicKey)
+		for nonce := uint64(0); nonce < 10; nonce++ {
+			tx := types.NewOrderTransaction(nonce, big.NewInt(1), big.NewInt(1), common.Address{}, user, common.Address{}, common.Address{},
+				tradingstate.OrderNew, tradingstate.Bid, tradingstate.Limit, common.Hash{}, 0)
+			signed, err := types.OrderSignTx(tx, types.OrderTxSigner{}, key)
+			if err != nil {
+				t.Fatalf("failed to sign order transaction: %v", err)
+			}
+			pending[user] = append(pending[user], signed)
+		}
+	}
+	// the order processor rebuilds the order transactions from the order items
+	rebuild := func(tx *types.OrderTransaction, R *big.Int) *types.OrderTransaction {
+		V, _, S := tx.Signature()
+		rebuilt := types.NewOrderTransaction(tx.Nonce(), tx.Quantity(), tx.Price(), tx.ExchangeAddress(), tx.UserAddress(), tx.BaseToken(), tx.QuoteToken(),
+			tx.Status(), tx.Side(), tx.Type(), tx.OrderHash(), tx.OrderID())
+		return rebuilt.ImportSignature(V, R, S)
+	}
+	tomox.recoverOrderSenders(signer, pending)
+	if n := tomox.orderSenders.Len(); n != 30 {
+		t.Fatalf("wrong number of cached senders: have %d, want %d", n, 30)
+	}
+	for user, txs := range pending {
+		for _, tx := range txs {
+			_, R, _ := tx.Signature()
+			cached, ok := tomox.orderSenders.Get(rebuild(tx, R).Hash())
+			if !ok || cached.(orderSender).from != user {
+				t.Fatalf("sender of order %d of %x not cached", tx.Nonce(), user)
+			}
+			// a tampered signature misses the cache
+			if from, _ := types.OrderSender(signer, rebuild(tx, new(big.Int).Add(R, common.Big1))); from == user {
+				t.Fatalf("tampered order %d of %x recovered to its user", tx.Nonce(), user)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package tomox
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// outsidePriceBand returns whether a price is outside the price band of the order book.
+//
+// From TIPTomoXPriceBand, the owner of a relayer listing a pair may bound the prices of its limit
+// and post-only orders around the medium price of the last epoch, or the time weighted average
+// price of the last epochs (see tradingstate.Twap). The orders priced further from it than the band
+// are rejected with PRICE_BAND, so that a fat-finger order doesn't wipe out the order book nor
+// move the prices liquidating the lending trades. Pairs without average price yet aren't bounded.
+func outsidePriceBand(header *types.Header, chain consensus.ChainContext, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, price *big.Int) bool {
+	if !chain.Config().IsTIPTomoXPriceBand(header.Number) {
+		return false
+	}
+	band := tradingStateDB.GetPriceBand(orderBook)
+	if band.Band == nil {
+		return false
+	}
+	reference := priceBandReference(header, chain, tradingStateDB, orderBook, band.Window)
+	if reference == nil || reference.Sign() <= 0 {
+		return false
+	}
+	deviation := new(big.Int).Sub(price, reference)
+	deviation.Abs(deviation).Mul(deviation, common.TomoXBaseFee)
+	return deviation.Cmp(new(big.Int).Mul(reference, band.Band)) > 0
+}
+
+// priceBandReference returns the average price of an order book a price band is centered on.
+func priceBandReference(header *types.Header, chain consensus.ChainContext, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, window uint64) *big.Int {
+	if window == 0 {
+		return tradingStateDB.GetMediumPriceBeforeEpoch(orderBook)
+	}
+	config := chain.Config()
+	if config.Posv == nil {
+		return nil
+	}
+	epoch := header.Number.Uint64() / config.Posv.Epoch
+	if epoch == 0 {
+		return nil
+	}
+	return tradingStateDB.GetTwap(orderBook, epoch-1, window).Twap
+}
+
+// hasPriceBand returns whether an order type is bounded by the price band of its pair.
+func hasPriceBand(orderType string) bool {
+	return orderType == tradingstate.Limit || orderType == tradingstate.PostOnly
+}
+
+// priceBand returns the price band of a pair at the current block.
+func (tomox *TomoX) priceBand(baseToken common.Address, quoteToken common.Address) (*tradingstate.PriceBand, error) {
+	_, tradingState, err := tomox.currentTradingState()
+	if err != nil {
+		return nil, err
+	}
+	band := tradingState.GetPriceBand(tradingstate.GetTradingOrderBookHash(baseToken, quoteToken))
+	return &band, nil
+}
@@ -0,0 +1,48 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestOutsidePriceBand(t *testing.T) {
+	orderBook := tradingstate.GetTradingOrderBookHash(common.HexToAddress("0x1"), common.HexToAddress("0x2"))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	header := &types.Header{Number: new(big.Int).Set(common.TIPTomoXPriceBand)}
+
+	tradingStateDB.SetMediumPriceBeforeEpoch(orderBook, big.NewInt(1000))
+	if outsidePriceBand(header, posvChain{}, tradingStateDB, orderBook, big.NewInt(5000)) {
+		t.Fatalf("order outside the price band of a pair without one")
+	}
+	// 10% around the medium price of the last epoch
+	tradingStateDB.SetPriceBand(orderBook, tradingstate.PriceBand{Band: big.NewInt(1000)})
+	for price, outside := range map[int64]bool{899: true, 900: false, 1100: false, 1101: true} {
+		if outsidePriceBand(header, posvChain{}, tradingStateDB, orderBook, big.NewInt(price)) != outside {
+			t.Errorf("price %d: want outside %v", price, outside)
+		}
+	}
+	before := &types.Header{Number: new(big.Int).Sub(common.TIPTomoXPriceBand, common.Big1)}
+	if outsidePriceBand(before, posvChain{}, tradingStateDB, orderBook, big.NewInt(5000)) {
+		t.Errorf("price band enforced before TIPTomoXPriceBand")
+	}
+
+	// 10% around the TWAP of the last 2 epochs, the trades of the current epoch left out
+	epoch := header.Number.Uint64() / 900
+	tradingStateDB.SetPriceBand(orderBook, tradingstate.PriceBand{Band: big.NewInt(1000), Window: 2})
+	if outsidePriceBand(header, posvChain{}, tradingStateDB, orderBook, big.NewInt(5000)) {
+		t.Errorf("order outside the price band of a pair without TWAP")
+	}
+	tradingStateDB.AddTwapTrade(orderBook, epoch-2, big.NewInt(2000), big.NewInt(1))
+	tradingStateDB.AddTwapTrade(orderBook, epoch-1, big.NewInt(4000), big.NewInt(1))
+	tradingStateDB.AddTwapTrade(orderBook, epoch, big.NewInt(100000), big.NewInt(1))
+	for price, outside := range map[int64]bool{2699: true, 2700: false, 3300: false, 3301: true} {
+		if outsidePriceBand(header, posvChain{}, tradingStateDB, orderBook, big.NewInt(price)) != outside {
+			t.Errorf("price %d: want outside %v of the TWAP", price, outside)
+		}
+	}
+}
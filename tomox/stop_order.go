@@ -0,0 +1,132 @@
+package tomox
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// maxTriggeredStopOrders is the maximum number of stop-loss and take-profit orders triggered by a single order.
+const maxTriggeredStopOrders = 20
+
+// Stop-loss and take-profit orders waiting for their trigger are kept in the trigger book of their
+// order book (see tradingstate.GetTradingTriggerBookHash), indexed by trigger price: the price of
+// the order. The orders triggered once the medium price of the last epoch falls to their trigger
+// are kept on the Bid side of the trigger book, the ones triggered once it rises to their trigger
+// on the Ask side, while the stored order carries its own side in ExtraData.
+func toTriggerOrder(order *tradingstate.OrderItem) tradingstate.OrderItem {
+	item := *order
+	item.Side = triggerSide(order)
+	item.ExtraData = order.Side
+	return item
+}
+
+func fromTriggerOrder(item tradingstate.OrderItem) tradingstate.OrderItem {
+	order := item
+	order.Side = item.ExtraData
+	order.ExtraData = ""
+	return order
+}
+
+// triggerSide returns the side of the trigger book holding a stop order: a stop-loss sell order
+// and a take-profit buy order are triggered by a falling price, a stop-loss buy order and a
+// take-profit sell order by a rising price.
+func triggerSide(order *tradingstate.OrderItem) string {
+	if (order.Type == tradingstate.StopLoss) == (order.Side == tradingstate.Ask) {
+		return tradingstate.Bid
+	}
+	return tradingstate.Ask
+}
+
+// stopOrderTriggered returns whether the medium price of the last epoch crossed the trigger price
+// of a stop order kept on the given side of the trigger book.
+func stopOrderTriggered(tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, side string, trigger *big.Int) bool {
+	price := tradingStateDB.GetMediumPriceBeforeEpoch(orderBook)
+	if price.Sign() <= 0 {
+		return false
+	}
+	switch side {
+	case tradingstate.Bid:
+		return price.Cmp(trigger) <= 0
+	case tradingstate.Ask:
+		return price.Cmp(trigger) >= 0
+	}
+	return false
+}
+
+// processStopOrder puts a stop-loss or take-profit order into the trigger book of the order book,
+// or processes it as a market order right away if its trigger has already been crossed.
+func (tomox *TomoX) processStopOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	if stopOrderTriggered(tradingStateDB, orderBook, triggerSide(order), order.Price) {
+		return tomox.processMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+	}
+	triggerBook := tradingstate.GetTradingTriggerBookHash(orderBook)
+	order.OrderID = tradingStateDB.GetNonce(triggerBook) + 1
+	tradingStateDB.SetNonce(triggerBook, order.OrderID)
+	tradingStateDB.InsertOrderItem(triggerBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)), toTriggerOrder(order))
+	log.Debug("Stop order added to trigger book", "type", order.Type, "side", order.Side, "OrderID", order.OrderID, "trigger", order.Price)
+	return nil, nil, nil
+}
+
+// processTriggeredStopOrders removes the stop orders whose trigger has been crossed by the medium
+// price of the last epoch from the trigger book, processing them as market orders.
+func (tomox *TomoX) processTriggeredStopOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash) ([]map[string]string, []*tradingstate.OrderItem) {
+	triggerBook := tradingstate.GetTradingTriggerBookHash(orderBook)
+	if !tradingStateDB.Exist(triggerBook) {
+		return nil, nil
+	}
+	return tomox.processTriggeredOrders(header, coinbase, chain, statedb, tradingStateDB, orderBook, triggerBook, fromTriggerOrder)
+}
+
+// processTriggeredOrders removes the orders of a book of triggers (Bid side triggered by a falling
+// price, Ask side by a rising price) crossed by the medium price of the last epoch, restoring them
+// with fromTrigger and processing them as market orders.
+func (tomox *TomoX) processTriggeredOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, triggerBook common.Hash, fromTrigger func(tradingstate.OrderItem) tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem) {
+	var (
+		trades  []map[string]string
+		rejects []*tradingstate.OrderItem
+	)
+	for i := 0; i < maxTriggeredStopOrders; i++ {
+		var (
+			side    string
+			trigger *big.Int
+		)
+		if highest, _ := tradingStateDB.GetBestBidPrice(triggerBook); highest.Sign() > 0 && stopOrderTriggered(tradingStateDB, orderBook, tradingstate.Bid, highest) {
+			side, trigger = tradingstate.Bid, highest
+		} else if lowest, _ := tradingStateDB.GetBestAskPrice(triggerBook); lowest.Sign() > 0 && stopOrderTriggered(tradingStateDB, orderBook, tradingstate.Ask, lowest) {
+			side, trigger = tradingstate.Ask, lowest
+		} else {
+			break
+		}
+		orderId, amount, err := tradingStateDB.GetBestOrderIdAndAmount(triggerBook, trigger, side)
+		if err != nil {
+			log.Error("Failed to get triggered stop order", "orderBook", orderBook.Hex(), "trigger", trigger, "side", side, "err", err)
+			break
+		}
+		item := tradingStateDB.GetOrder(triggerBook, orderId)
+		if err := tradingStateDB.SubAmountOrderItem(triggerBook, orderId, trigger, amount, side); err != nil {
+			log.Error("Failed to remove triggered stop order", "orderBook", orderBook.Hex(), "orderId", orderId.Hex(), "err", err)
+			break
+		}
+		order := fromTrigger(item)
+		order.Quantity = amount
+		log.Debug("Process triggered stop order", "type", order.Type, "side", order.Side, "quantity", order.Quantity, "trigger", trigger)
+		tomoxSnap, dbSnap := tradingStateDB.Snapshot(), statedb.Snapshot()
+		newTrades, newRejects, err := tomox.processMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, &order)
+		if err != nil {
+			tradingStateDB.RevertToSnapshot(tomoxSnap)
+			statedb.RevertToSnapshot(dbSnap)
+			log.Debug("Reject triggered stop order", "err", err, "order", tradingstate.ToJSON(&order))
+			rejects = append(rejects, &order)
+			continue
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+	}
+	return trades, rejects
+}
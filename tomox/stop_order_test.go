@@ -0,0 +1,88 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestStopOrderTriggered(t *testing.T) {
+	orderBook := common.StringToHash("BTC/USDT")
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	if stopOrderTriggered(tradingStateDB, orderBook, tradingstate.Bid, big.NewInt(100)) {
+		t.Fatalf("stop order triggered without medium price")
+	}
+	tradingStateDB.SetMediumPriceBeforeEpoch(orderBook, big.NewInt(100))
+
+	tests := []struct {
+		side    string
+		trigger int64
+		want    bool
+	}{
+		{tradingstate.Bid, 101, true},
+		{tradingstate.Bid, 100, true},
+		{tradingstate.Bid, 99, false},
+		{tradingstate.Ask, 99, true},
+		{tradingstate.Ask, 100, true},
+		{tradingstate.Ask, 101, false},
+	}
+	for _, tt := range tests {
+		if got := stopOrderTriggered(tradingStateDB, orderBook, tt.side, big.NewInt(tt.trigger)); got != tt.want {
+			t.Errorf("side %s trigger %d: have %v, want %v", tt.side, tt.trigger, got, tt.want)
+		}
+	}
+}
+
+func TestTriggerOrderRoundTrip(t *testing.T) {
+	tests := []struct {
+		orderType, side, triggerSide string
+	}{
+		{tradingstate.StopLoss, tradingstate.Ask, tradingstate.Bid},
+		{tradingstate.StopLoss, tradingstate.Bid, tradingstate.Ask},
+		{tradingstate.TakeProfit, tradingstate.Ask, tradingstate.Ask},
+		{tradingstate.TakeProfit, tradingstate.Bid, tradingstate.Bid},
+	}
+	for _, tt := range tests {
+		order := &tradingstate.OrderItem{Type: tt.orderType, Side: tt.side, Price: big.NewInt(7)}
+		item := toTriggerOrder(order)
+		if item.Side != tt.triggerSide || item.ExtraData != tt.side {
+			t.Errorf("%s %s: unexpected trigger order: side %s, extraData %s", tt.orderType, tt.side, item.Side, item.ExtraData)
+		}
+		if restored := fromTriggerOrder(item); restored.Side != tt.side || restored.ExtraData != "" {
+			t.Errorf("%s %s: unexpected restored order: side %s, extraData %s", tt.orderType, tt.side, restored.Side, restored.ExtraData)
+		}
+	}
+}
+
+func TestProcessStopOrder(t *testing.T) {
+	tomox := New(&Config{DataDir: t.TempDir()})
+	orderBook := common.StringToHash("BTC/USDT")
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	stopLoss := &tradingstate.OrderItem{Type: tradingstate.StopLoss, Side: tradingstate.Ask, Price: big.NewInt(90), Quantity: big.NewInt(1), Hash: common.StringToHash("sl")}
+	takeProfit := &tradingstate.OrderItem{Type: tradingstate.TakeProfit, Side: tradingstate.Ask, Price: big.NewInt(110), Quantity: big.NewInt(2), Hash: common.StringToHash("tp")}
+	for _, order := range []*tradingstate.OrderItem{stopLoss, takeProfit} {
+		if _, _, err := tomox.processStopOrder(nil, common.Address{}, nil, nil, tradingStateDB, orderBook, order); err != nil {
+			t.Fatalf("failed to process stop order: %v", err)
+		}
+	}
+	if stopLoss.OrderID != 1 || takeProfit.OrderID != 2 {
+		t.Fatalf("unexpected order ids: have %d and %d, want 1 and 2", stopLoss.OrderID, takeProfit.OrderID)
+	}
+	triggerBook := tradingstate.GetTradingTriggerBookHash(orderBook)
+	if price, _ := tradingStateDB.GetBestBidPrice(triggerBook); price.Cmp(stopLoss.Price) != 0 {
+		t.Errorf("unexpected falling trigger: have %v, want %v", price, stopLoss.Price)
+	}
+	if price, _ := tradingStateDB.GetBestAskPrice(triggerBook); price.Cmp(takeProfit.Price) != 0 {
+		t.Errorf("unexpected rising trigger: have %v, want %v", price, takeProfit.Price)
+	}
+	if best, _ := tradingStateDB.GetBestAskPrice(orderBook); best.Sign() != 0 {
+		t.Errorf("stop order added to the order book at %v", best)
+	}
+	if stored := fromTriggerOrder(tradingStateDB.GetOrder(triggerBook, common.BigToHash(big.NewInt(1)))); stored.Hash != stopLoss.Hash || stored.Side != tradingstate.Ask {
+		t.Errorf("unexpected stored stop order: hash %x, side %s", stored.Hash, stored.Side)
+	}
+}
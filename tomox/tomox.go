@@ -1,10 +1,13 @@
 package tomox
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tomochain/tomochain/consensus"
@@ -15,6 +18,7 @@ import (
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 
 	lru "github.com/hashicorp/golang-lru"
+	_ "github.com/lib/pq" // PostgreSQL driver of the postgres DBEngine
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/log"
@@ -31,17 +35,44 @@ const (
 	MaximumTxMatchSize = 1000
 )
 
+const defaultEventSinkTopic = "tomox" // Default prefix of the topics published to the event sink
+
 var (
 	ErrNonceTooHigh = errors.New("nonce too high")
 	ErrNonceTooLow  = errors.New("nonce too low")
 )
 
 type Config struct {
-	DataDir        string `toml:",omitempty"`
-	DBEngine       string `toml:",omitempty"`
-	DBName         string `toml:",omitempty"`
-	ConnectionUrl  string `toml:",omitempty"`
-	ReplicaSetName string `toml:",omitempty"`
+	DataDir             string        `toml:",omitempty"`
+	DBEngine            string        `toml:",omitempty"`
+	DBName              string        `toml:",omitempty"`
+	ConnectionUrl       string        `toml:",omitempty"`
+	ReplicaSetName      string        `toml:",omitempty"`
+	ReadPreference      string        `toml:",omitempty"` // mongodb members serving the read-only SDK queries, see tomoxDAO.MongoOptions
+	PoolLimit           int           `toml:",omitempty"` // maximum number of mongodb sockets per server, 0 for the driver default
+	WriteConcern        string        `toml:",omitempty"` // acknowledgement of the mongodb writes: majority or a number of members
+	LendingIndex        bool          `toml:",omitempty"` // index the lending history of each user in leveldb on non-SDK nodes
+	CandleIndex         bool          `toml:",omitempty"` // index the price candles of each pair in leveldb
+	Witness             bool          `toml:",omitempty"` // record the witness of the blocks sealed by the node for stateless validators
+	EventSink           string        `toml:",omitempty"` // url of the broker the SDK node publishes its records to (nats://, kafka+http://)
+	EventSinkTopic      string        `toml:",omitempty"` // prefix of the topics the SDK node publishes to
+	Retention           string        `toml:",omitempty"` // retention rules of the SDK records, see tomoxDAO.ParseRetentionRules
+	RetentionDryRun     bool          `toml:",omitempty"` // only report the SDK records the retention rules would remove
+	LendingArchive      bool          `toml:",omitempty"` // never garbage collect the lending state tries
+	LendingStateEpochs  uint64        `toml:",omitempty"` // number of recent epochs whose lending state is kept by a full node
+	LendingRelayerSlots uint64        `toml:",omitempty"` // maximum number of lending transactions of a relayer in the lending pool, 0 for no limit
+	LendingMatchWorkers int           `toml:",omitempty"` // number of lending books matched concurrently when sealing a block, 0 or 1 to match serially
+	LendingMatchBudget  uint64        `toml:",omitempty"` // matching work allowed to the lending items of a sealed block, 0 for no limit
+	SDKTimeout          time.Duration `toml:",omitempty"` // deadline of the SDK database round-trips recording a lending item, 0 for none
+	SDKJournalDepth     uint64        `toml:",omitempty"` // number of recent blocks whose SDK lending records can be rolled back, 0 for the default
+	LendingGRPC         string        `toml:",omitempty"` // listening address of the lending gRPC server, empty to disable it
+	LendingREST         string        `toml:",omitempty"` // listening address of the signed lending order REST gateway, empty to disable it
+	GraphQL             bool          `toml:",omitempty"` // serve GraphQL queries over the SDK records at /graphql on the HTTP-RPC server
+	DevDB               bool          `toml:",omitempty"` // run an SDK node on in-memory databases, ignoring DataDir and DBEngine
+	LendingCacheSize    int           `toml:",omitempty"` // entries of the lending history and backfill caches, 0 for the default
+	LendingRootsCache   int           `toml:",omitempty"` // lending state roots of recent blocks kept in memory, 0 for the default
+	LendingNonces       bool          `toml:",omitempty"` // assign the nonces of the lending items submitted to the node
+	OrderSenderWorkers  int           `toml:",omitempty"` // number of order signatures recovered concurrently before matching, 0 for the number of CPUs
 }
 
 // DefaultConfig represents (shocker!) the default configuration.
@@ -53,15 +84,38 @@ type TomoX struct {
 	// Order related
 	db         tomoxDAO.TomoXDAO
 	mongodb    tomoxDAO.TomoXDAO
+	mongoRead  tomoxDAO.TomoXDAO     // serves the read-only SDK queries
 	Triegc     *prque.Prque          // Priority queue mapping block numbers to tries to gc
 	StateCache tradingstate.Database // State database to reuse between imports (contains state cache)    *tomox_state.TradingStateDB
 
 	orderNonce map[common.Address]*big.Int
 
-	sdkNode           bool
-	settings          syncmap.Map // holds configuration settings that can be dynamically changed
-	tokenDecimalCache *lru.Cache
-	orderCache        *lru.Cache
+	sdkNode             bool
+	lendingIndex        bool
+	candleIndex         bool
+	witness             bool
+	lendingArchive      bool
+	lendingStateEpochs  uint64
+	lendingRelayerSlots uint64
+	lendingMatchWorkers int
+	lendingMatchBudget  uint64
+	sdkTimeout          time.Duration
+	sdkJournalDepth     uint64
+	lendingCacheSize    int
+	lendingRootsCache   int
+	lendingNonces       bool
+	orderSenderWorkers  int
+	lendingGRPC         string
+	lendingREST         string
+	eventSink           tomoxDAO.EventSink
+	eventSinkTopic      string
+	pruner              *tomoxDAO.Pruner
+	settings            syncmap.Map // holds configuration settings that can be dynamically changed
+	tokenDecimalCache   *lru.Cache
+	orderCache          *lru.Cache
+	orderSenders        *lru.Cache // senders of the order transactions, see cachedOrderSigner
+	chain               blockChain // chain serving the order books, see SetChain
+	engineStats         engineStats // matching statistics of the recent blocks, see EngineStats
 }
 
 func (tomox *TomoX) Protocols() []p2p.Protocol {
@@ -75,6 +129,12 @@ func (tomox *TomoX) Start(server *p2p.Server) error {
 func (tomox *TomoX) SaveData() {
 }
 func (tomox *TomoX) Stop() error {
+	if tomox.pruner != nil {
+		tomox.pruner.Stop()
+	}
+	if tomox.eventSink != nil {
+		return tomox.eventSink.Close()
+	}
 	return nil
 }
 
@@ -85,7 +145,13 @@ func NewLDBEngine(cfg *Config) *tomoxDAO.BatchDatabase {
 }
 
 func NewMongoDBEngine(cfg *Config) *tomoxDAO.MongoDatabase {
-	mongoDB, err := tomoxDAO.NewMongoDatabase(nil, cfg.DBName, cfg.ConnectionUrl, cfg.ReplicaSetName, 0)
+	opts := tomoxDAO.MongoOptions{
+		ReplicaSetName: cfg.ReplicaSetName,
+		ReadPreference: cfg.ReadPreference,
+		PoolLimit:      cfg.PoolLimit,
+		WriteConcern:   cfg.WriteConcern,
+	}
+	mongoDB, err := tomoxDAO.NewMongoDatabase(nil, cfg.DBName, cfg.ConnectionUrl, opts, 0)
 
 	if err != nil {
 		log.Crit("Failed to init mongodb engine", "err", err)
@@ -94,23 +160,124 @@ func NewMongoDBEngine(cfg *Config) *tomoxDAO.MongoDatabase {
 	return mongoDB
 }
 
+// NewSQLDBEngine opens the SQL database of an SDK node: PostgreSQL for the "postgres" engine, or
+// the database of a database/sql driver registered in the binary for the "sql:<driver>" engine.
+// The connection url is the data source name of the driver.
+func NewSQLDBEngine(cfg *Config) *tomoxDAO.SQLDatabase {
+	driver := strings.TrimPrefix(cfg.DBEngine, "sql:")
+	sqlDB, err := tomoxDAO.NewSQLDatabase(driver, cfg.ConnectionUrl, 0)
+	if err != nil {
+		log.Crit("Failed to init sql engine", "driver", driver, "err", err)
+	}
+	return sqlDB
+}
+
+// NewBadgerDBEngine opens the embedded database of an SDK node in the sdk directory of the TomoX
+// data directory.
+func NewBadgerDBEngine(cfg *Config) *tomoxDAO.BadgerDatabase {
+	badgerDB, err := tomoxDAO.NewBadgerDatabase(filepath.Join(cfg.DataDir, "sdk"), 0)
+	if err != nil {
+		log.Crit("Failed to init badger engine", "err", err)
+	}
+	return badgerDB
+}
+
+// NewMemoryDBEngine opens the in-memory database of a development SDK node.
+func NewMemoryDBEngine(cfg *Config) *tomoxDAO.BadgerDatabase {
+	memDB, err := tomoxDAO.NewMemoryBadgerDatabase(0)
+	if err != nil {
+		log.Crit("Failed to init in-memory engine", "err", err)
+	}
+	return memDB
+}
+
 func New(cfg *Config) *TomoX {
 	tokenDecimalCache, _ := lru.New(defaultCacheLimit)
 	orderCache, _ := lru.New(tradingstate.OrderCacheLimit)
+	orderSenders, _ := lru.New(orderSenderCacheLimit)
 	tomoX := &TomoX{
 		orderNonce:        make(map[common.Address]*big.Int),
 		Triegc:            prque.New(),
 		tokenDecimalCache: tokenDecimalCache,
 		orderCache:        orderCache,
+		orderSenders:      orderSenders,
 	}
 
 	// default DBEngine: levelDB
-	tomoX.db = NewLDBEngine(cfg)
+	if cfg.DevDB {
+		tomoX.db = tomoxDAO.NewMemoryDatabase(0)
+	} else {
+		tomoX.db = NewLDBEngine(cfg)
+	}
 	tomoX.sdkNode = false
 
-	if cfg.DBEngine == "mongodb" { // this is an add-on DBEngine for SDK nodes
-		tomoX.mongodb = NewMongoDBEngine(cfg)
+	if cfg.DevDB { // in-memory DBEngine for development SDK nodes
+		tomoX.mongodb = NewMemoryDBEngine(cfg)
+		tomoX.sdkNode = true
+		log.Warn("TomoX runs on in-memory databases, its data is lost on exit")
+	} else if cfg.DBEngine == "mongodb" { // this is an add-on DBEngine for SDK nodes
+		mongoDB := NewMongoDBEngine(cfg)
+		tomoX.mongodb, tomoX.mongoRead = mongoDB, mongoDB.ReadReplica()
+		tomoX.sdkNode = true
+	} else if cfg.DBEngine == "postgres" || strings.HasPrefix(cfg.DBEngine, "sql:") { // SQL add-on DBEngine for SDK nodes
+		tomoX.mongodb = NewSQLDBEngine(cfg)
 		tomoX.sdkNode = true
+	} else if cfg.DBEngine == "badger" { // embedded add-on DBEngine for SDK nodes
+		tomoX.mongodb = NewBadgerDBEngine(cfg)
+		tomoX.sdkNode = true
+	}
+
+	if tomoX.sdkNode {
+		// replay the lending bulks interrupted by a crash before any new record is written
+		wal := tomoxDAO.NewLendingWAL(tomoX.mongodb, tomoX.db)
+		if n, err := wal.ReplayLendingWAL(); err != nil {
+			log.Error("Failed to replay journaled lending bulks", "replayed", n, "err", err)
+		} else if n > 0 {
+			log.Info("Replayed journaled lending bulks", "count", n)
+		}
+		if tomoX.mongoRead == nil {
+			tomoX.mongoRead = tomoX.mongodb
+		}
+		tomoX.mongodb = wal
+	}
+	if tomoX.mongoRead == nil {
+		tomoX.mongoRead = tomoX.mongodb
+	}
+	tomoX.lendingIndex = cfg.LendingIndex && !tomoX.sdkNode
+	tomoX.candleIndex = cfg.CandleIndex
+	tomoX.witness = cfg.Witness
+	tomoX.lendingArchive, tomoX.lendingStateEpochs = cfg.LendingArchive, cfg.LendingStateEpochs
+	tomoX.lendingRelayerSlots = cfg.LendingRelayerSlots
+	tomoX.lendingMatchWorkers = cfg.LendingMatchWorkers
+	tomoX.lendingMatchBudget = cfg.LendingMatchBudget
+	tomoX.sdkTimeout = cfg.SDKTimeout
+	tomoX.sdkJournalDepth = cfg.SDKJournalDepth
+	tomoX.lendingCacheSize, tomoX.lendingRootsCache = cfg.LendingCacheSize, cfg.LendingRootsCache
+	tomoX.lendingGRPC = cfg.LendingGRPC
+	tomoX.lendingREST = cfg.LendingREST
+	tomoX.lendingNonces = cfg.LendingNonces
+	tomoX.orderSenderWorkers = cfg.OrderSenderWorkers
+
+	if cfg.EventSink != "" && tomoX.sdkNode {
+		sink, err := tomoxDAO.NewEventSink(cfg.EventSink)
+		if err != nil {
+			log.Crit("Failed to init event sink", "url", cfg.EventSink, "err", err)
+		}
+		tomoX.eventSink = sink
+		tomoX.eventSinkTopic = cfg.EventSinkTopic
+		if tomoX.eventSinkTopic == "" {
+			tomoX.eventSinkTopic = defaultEventSinkTopic
+		}
+	}
+
+	if cfg.Retention != "" && tomoX.sdkNode {
+		rules, err := tomoxDAO.ParseRetentionRules(cfg.Retention)
+		if err != nil {
+			log.Crit("Invalid retention rules", "rules", cfg.Retention, "err", err)
+		}
+		tomoX.pruner = tomoxDAO.NewPruner(tomoX.mongodb, rules, cfg.RetentionDryRun)
+		tomoX.pruner.Start()
+		log.Info("Pruning SDK records", "rules", rules, "dryRun", cfg.RetentionDryRun)
 	}
 
 	tomoX.StateCache = tradingstate.NewDatabase(tomoX.db)
@@ -129,6 +296,84 @@ func (tomox *TomoX) IsSDKNode() bool {
 	return tomox.sdkNode
 }
 
+// PublishEvent publishes a record written by the SDK node to the topic prefix.name of the
+// event sink, if any. The record is encoded in JSON.
+func (tomox *TomoX) PublishEvent(name string, key common.Hash, record interface{}) {
+	if tomox.eventSink == nil {
+		return
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		log.Error("Failed to encode event", "name", name, "key", key.Hex(), "err", err)
+		return
+	}
+	if err := tomox.eventSink.Publish(tomox.eventSinkTopic+"."+name, key.Bytes(), value); err != nil {
+		log.Warn("Failed to publish event", "name", name, "key", key.Hex(), "err", err)
+	}
+}
+
+// HasLendingIndex returns whether the lending history of each user is indexed in leveldb.
+func (tomox *TomoX) HasLendingIndex() bool {
+	return tomox.lendingIndex
+}
+
+// LendingStateRetention returns whether the lending state tries are never garbage collected,
+// and otherwise the number of recent epochs whose lending state is kept.
+func (tomox *TomoX) LendingStateRetention() (bool, uint64) {
+	return tomox.lendingArchive, tomox.lendingStateEpochs
+}
+
+// LendingRelayerSlots returns the maximum number of lending transactions of a relayer in the
+// lending pool, 0 for no limit.
+func (tomox *TomoX) LendingRelayerSlots() uint64 {
+	return tomox.lendingRelayerSlots
+}
+
+// LendingMatchWorkers returns the number of lending books matched concurrently when sealing a
+// block, 0 or 1 if they are matched serially.
+func (tomox *TomoX) LendingMatchWorkers() int {
+	return tomox.lendingMatchWorkers
+}
+
+// LendingMatchBudget returns the matching work allowed to the lending items of a sealed block, 0
+// if it isn't limited.
+func (tomox *TomoX) LendingMatchBudget() uint64 {
+	return tomox.lendingMatchBudget
+}
+
+// SDKTimeout returns the deadline of the SDK database round-trips recording a lending item, 0 if
+// they have none.
+func (tomox *TomoX) SDKTimeout() time.Duration {
+	return tomox.sdkTimeout
+}
+
+// SDKJournalDepth returns the number of recent blocks whose SDK lending records can be rolled
+// back, 0 for the default.
+func (tomox *TomoX) SDKJournalDepth() uint64 {
+	return tomox.sdkJournalDepth
+}
+
+// LendingCacheSizes returns the number of entries of the lending history and backfill caches, and
+// the number of lending state roots kept in memory, 0 for the defaults.
+func (tomox *TomoX) LendingCacheSizes() (int, int) {
+	return tomox.lendingCacheSize, tomox.lendingRootsCache
+}
+
+// LendingNonces returns whether the node assigns the nonces of the lending items submitted to it.
+func (tomox *TomoX) LendingNonces() bool {
+	return tomox.lendingNonces
+}
+
+// LendingGRPC returns the listening address of the lending gRPC server, empty if it is disabled.
+func (tomox *TomoX) LendingGRPC() string {
+	return tomox.lendingGRPC
+}
+
+// LendingREST returns the listening address of the lending REST gateway, empty if it is disabled.
+func (tomox *TomoX) LendingREST() string {
+	return tomox.lendingREST
+}
+
 func (tomox *TomoX) GetLevelDB() tomoxDAO.TomoXDAO {
 	return tomox.db
 }
@@ -137,6 +382,13 @@ func (tomox *TomoX) GetMongoDB() tomoxDAO.TomoXDAO {
 	return tomox.mongodb
 }
 
+// GetMongoReadDB returns the SDK database serving the read-only queries of the APIs. With a
+// mongodb read preference other than primary, it reads from members lagging behind the writes of
+// GetMongoDB.
+func (tomox *TomoX) GetMongoReadDB() tomoxDAO.TomoXDAO {
+	return tomox.mongoRead
+}
+
 // APIs returns the RPC descriptors the TomoX implementation offers
 func (tomox *TomoX) APIs() []rpc.API {
 	return []rpc.API{
@@ -146,6 +398,12 @@ func (tomox *TomoX) APIs() []rpc.API {
 			Service:   NewPublicTomoXAPI(tomox),
 			Public:    true,
 		},
+		{
+			Namespace: ProtocolName,
+			Version:   ProtocolVersionStr,
+			Service:   NewPrivateTomoXAPI(tomox),
+			Public:    false,
+		},
 	}
 }
 
@@ -154,22 +412,38 @@ func (tomox *TomoX) Version() uint64 {
 	return ProtocolVersion
 }
 
+// ProcessOrderPending matches the pending order transactions. The cancellations heading the
+// transactions of an account are applied first, in a priority lane (see splitCancelLane), so that
+// they don't wait behind the new orders of the other accounts. The senders of the transactions
+// are recovered concurrently beforehand.
 func (tomox *TomoX) ProcessOrderPending(header *types.Header, coinbase common.Address, chain consensus.ChainContext, pending map[common.Address]types.OrderTransactions, statedb *state.StateDB, tomoXstatedb *tradingstate.TradingStateDB) ([]tradingstate.TxDataMatch, map[common.Hash]tradingstate.MatchingResult) {
 	txMatches := []tradingstate.TxDataMatch{}
 	matchingResults := map[common.Hash]tradingstate.MatchingResult{}
 
-	txs := types.NewOrderTransactionByNonce(types.OrderTxSigner{}, pending)
+	signer := tomox.orderSigner(chain.Config(), header.Number)
+	tomox.recoverOrderSenders(signer, pending)
+	cancels, orders := splitCancelLane(pending)
 	numberTx := 0
+	for _, lane := range []map[common.Address]types.OrderTransactions{cancels, orders} {
+		laneMatches := tomox.processOrderTxs(header, coinbase, chain, types.NewOrderTransactionByNonce(signer, lane), &numberTx, statedb, tomoXstatedb, matchingResults)
+		txMatches = append(txMatches, laneMatches...)
+	}
+	return txMatches, matchingResults
+}
+
+// processOrderTxs matches the order transactions of a lane, until numberTx exceeds MaximumTxMatchSize.
+func (tomox *TomoX) processOrderTxs(header *types.Header, coinbase common.Address, chain consensus.ChainContext, txs *types.OrderTransactionByNonce, numberTx *int, statedb *state.StateDB, tomoXstatedb *tradingstate.TradingStateDB, matchingResults map[common.Hash]tradingstate.MatchingResult) []tradingstate.TxDataMatch {
+	txMatches := []tradingstate.TxDataMatch{}
 	for {
 		tx := txs.Peek()
 		if tx == nil {
 			break
 		}
-		if numberTx > MaximumTxMatchSize {
+		if *numberTx > MaximumTxMatchSize {
 			break
 		}
-		numberTx++
-		log.Debug("ProcessOrderPending start", "len", len(pending))
+		*numberTx++
+		log.Debug("ProcessOrderPending start", "number", *numberTx)
 		log.Debug("Get pending orders to process", "address", tx.UserAddress(), "nonce", tx.Nonce())
 		V, R, S := tx.Signature()
 
@@ -260,7 +534,28 @@ func (tomox *TomoX) ProcessOrderPending(header *types.Header, coinbase common.Ad
 			Rejects: newRejectedOrders,
 		}
 	}
-	return txMatches, matchingResults
+	return txMatches
+}
+
+// splitCancelLane splits the pending order transactions into the cancellations heading the
+// transactions of each account, sorted by nonce, and the transactions following them. The
+// cancellations behind a new order of the same account stay in nonce order after it.
+func splitCancelLane(pending map[common.Address]types.OrderTransactions) (map[common.Address]types.OrderTransactions, map[common.Address]types.OrderTransactions) {
+	cancels := make(map[common.Address]types.OrderTransactions)
+	orders := make(map[common.Address]types.OrderTransactions)
+	for addr, txs := range pending {
+		n := 0
+		for n < len(txs) && txs[n].IsCancelledOrder() {
+			n++
+		}
+		if n > 0 {
+			cancels[addr] = txs[:n]
+		}
+		if n < len(txs) {
+			orders[addr] = txs[n:]
+		}
+	}
+	return cancels, orders
 }
 
 // return average price of the given pair in the last epoch
@@ -383,18 +678,28 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 		if price.Cmp(big.NewInt(0)) <= 0 || quantity.Cmp(big.NewInt(0)) <= 0 {
 			return fmt.Errorf("trade misses important information. tradedPrice %v, tradedQuantity %v", price, quantity)
 		}
+		takerOrder := updatedTakerOrder
+		triggered := trade[tradingstate.TradeTakerOrderHash] != updatedTakerOrder.Hash.Hex()
+		if triggered {
+			// trade of a stop order triggered by the taker: the stop order has already been recorded
+			val, err := db.GetObject(common.HexToHash(trade[tradingstate.TradeTakerOrderHash]), &tradingstate.OrderItem{})
+			if err != nil || val == nil {
+				return fmt.Errorf("SDKNode: failed to get triggered stop order. Hash: %s", trade[tradingstate.TradeTakerOrderHash])
+			}
+			takerOrder = val.(*tradingstate.OrderItem)
+		}
 		tradeRecord.Amount = quantity
 		tradeRecord.PricePoint = price
-		tradeRecord.BaseToken = updatedTakerOrder.BaseToken
-		tradeRecord.QuoteToken = updatedTakerOrder.QuoteToken
+		tradeRecord.BaseToken = takerOrder.BaseToken
+		tradeRecord.QuoteToken = takerOrder.QuoteToken
 		tradeRecord.Status = tradingstate.TradeStatusSuccess
-		tradeRecord.Taker = updatedTakerOrder.UserAddress
+		tradeRecord.Taker = takerOrder.UserAddress
 		tradeRecord.Maker = common.HexToAddress(trade[tradingstate.TradeMaker])
-		tradeRecord.TakerOrderHash = updatedTakerOrder.Hash
+		tradeRecord.TakerOrderHash = takerOrder.Hash
 		tradeRecord.MakerOrderHash = common.HexToHash(trade[tradingstate.TradeMakerOrderHash])
 		tradeRecord.TxHash = txHash
-		tradeRecord.TakerOrderSide = updatedTakerOrder.Side
-		tradeRecord.TakerExchange = updatedTakerOrder.ExchangeAddress
+		tradeRecord.TakerOrderSide = takerOrder.Side
+		tradeRecord.TakerExchange = takerOrder.ExchangeAddress
 		tradeRecord.MakerExchange = common.HexToAddress(trade[tradingstate.TradeMakerExchange])
 
 		tradeRecord.MakeFee, _ = new(big.Int).SetString(trade[tradingstate.MakerFee], 10)
@@ -402,7 +707,7 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 
 		// set makerOrderType, takerOrderType
 		tradeRecord.MakerOrderType = trade[tradingstate.MakerOrderType]
-		tradeRecord.TakerOrderType = updatedTakerOrder.Type
+		tradeRecord.TakerOrderType = takerOrder.Type
 
 		if tradeRecord.CreatedAt.IsZero() {
 			tradeRecord.CreatedAt = txMatchTime
@@ -420,13 +725,22 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 		// 2.b. update status and filledAmount
 		filledAmount := quantity
 		// maker dirty order
-		makerFilledAmount := big.NewInt(0)
-		if amount, ok := makerDirtyFilledAmount[trade[tradingstate.TradeMakerOrderHash]]; ok {
-			makerFilledAmount = tradingstate.CloneBigInt(amount)
+		makerOrderHashes := []string{trade[tradingstate.TradeMakerOrderHash]}
+		if triggered {
+			makerOrderHashes = append(makerOrderHashes, trade[tradingstate.TradeTakerOrderHash])
+		}
+		for _, makerOrderHash := range makerOrderHashes {
+			makerFilledAmount := big.NewInt(0)
+			if amount, ok := makerDirtyFilledAmount[makerOrderHash]; ok {
+				makerFilledAmount = tradingstate.CloneBigInt(amount)
+			}
+			makerFilledAmount = new(big.Int).Add(makerFilledAmount, filledAmount)
+			makerDirtyFilledAmount[makerOrderHash] = makerFilledAmount
+			makerDirtyHashes = append(makerDirtyHashes, makerOrderHash)
+		}
+		if triggered {
+			continue
 		}
-		makerFilledAmount = new(big.Int).Add(makerFilledAmount, filledAmount)
-		makerDirtyFilledAmount[trade[tradingstate.TradeMakerOrderHash]] = makerFilledAmount
-		makerDirtyHashes = append(makerDirtyHashes, trade[tradingstate.TradeMakerOrderHash])
 
 		//updatedTakerOrder = tomox.updateMatchedOrder(updatedTakerOrder, filledAmount, txMatchTime, txHash)
 		//  update filledAmount, status of takerOrder
@@ -494,9 +808,13 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 
 	if len(rejectedOrders) > 0 {
 		var rejectedHashes []string
+		rejectReasons := make(map[common.Hash]string)
 		// updateRejectedOrders
 		for _, rejectedOrder := range rejectedOrders {
 			rejectedHashes = append(rejectedHashes, rejectedOrder.Hash.Hex())
+			if rejectedOrder.RejectReason != "" {
+				rejectReasons[rejectedOrder.Hash] = rejectedOrder.RejectReason
+			}
 			if updatedTakerOrder.Hash == rejectedOrder.Hash && !txMatchTime.Before(updatedTakerOrder.UpdatedAt) {
 				// cache order history for handling reorg
 				orderHistoryRecord := tradingstate.OrderHistoryItem{
@@ -513,6 +831,7 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 				} else {
 					updatedTakerOrder.Status = tradingstate.OrderStatusRejected
 				}
+				updatedTakerOrder.RejectReason = rejectedOrder.RejectReason
 				updatedTakerOrder.TxHash = txHash
 				updatedTakerOrder.UpdatedAt = txMatchTime
 				if err := db.PutObject(updatedTakerOrder.Hash, updatedTakerOrder); err != nil {
@@ -547,6 +866,9 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 				} else {
 					order.Status = tradingstate.OrderStatusRejected
 				}
+				if reason, ok := rejectReasons[order.Hash]; ok {
+					order.RejectReason = reason
+				}
 				order.TxHash = txHash
 				order.UpdatedAt = txMatchTime
 				if err = db.PutObject(order.Hash, order); err != nil {
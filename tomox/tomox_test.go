@@ -0,0 +1,37 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestSplitCancelLane(t *testing.T) {
+	newTx := func(user common.Address, nonce uint64, status string) *types.OrderTransaction {
+		return types.NewOrderTransaction(nonce, big.NewInt(1), big.NewInt(1), common.Address{}, user, common.Address{}, common.Address{},
+			status, tradingstate.Bid, tradingstate.Limit, common.Hash{}, 0)
+	}
+	var (
+		maker, taker, other = common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+		cancelled, placed   = tradingstate.OrderStatusCancelled, tradingstate.OrderNew
+	)
+	pending := map[common.Address]types.OrderTransactions{
+		maker: {newTx(maker, 0, cancelled), newTx(maker, 1, cancelled), newTx(maker, 2, placed), newTx(maker, 3, cancelled)},
+		taker: {newTx(taker, 5, placed), newTx(taker, 6, cancelled)},
+		other: {newTx(other, 9, cancelled)},
+	}
+	cancels, orders := splitCancelLane(pending)
+
+	if len(cancels) != 2 || len(cancels[maker]) != 2 || cancels[maker][1].Nonce() != 1 || len(cancels[other]) != 1 {
+		t.Errorf("wrong cancel lane: %v", cancels)
+	}
+	if len(orders) != 2 || len(orders[maker]) != 2 || orders[maker][0].Nonce() != 2 || len(orders[taker]) != 2 {
+		t.Errorf("wrong order lane: %v", orders)
+	}
+	if _, ok := orders[other]; ok {
+		t.Error("empty order lane of an account")
+	}
+}
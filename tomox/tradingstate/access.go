@@ -0,0 +1,38 @@
+package tradingstate
+
+import (
+	"github.com/tomochain/tomochain/common"
+)
+
+// RecordAccesses starts recording the orderbooks and other state objects accessed through the
+// state, dropping the accesses recorded so far. Objects are recorded whether they are read or
+// written.
+func (self *TradingStateDB) RecordAccesses() {
+	self.accessed = make(map[common.Hash]struct{})
+}
+
+// Accessed returns the state objects accessed since RecordAccesses, nil if they aren't recorded.
+func (self *TradingStateDB) Accessed() map[common.Hash]struct{} {
+	return self.accessed
+}
+
+func (self *TradingStateDB) recordAccess(addr common.Hash) {
+	if self.accessed != nil {
+		self.accessed[addr] = struct{}{}
+	}
+}
+
+// ApplyAccessed copies into the state the objects accessed through a copy of it since the copy
+// started recording its accesses.
+func (self *TradingStateDB) ApplyAccessed(src *TradingStateDB) {
+	for addr := range src.accessed {
+		obj := src.stateExhangeObjects[addr]
+		if obj == nil {
+			continue
+		}
+		self.stateExhangeObjects[addr] = obj.deepCopy(self, self.MarkStateExchangeObjectDirty)
+		if _, dirty := src.stateExhangeObjectsDirty[addr]; dirty {
+			self.stateExhangeObjectsDirty[addr] = struct{}{}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package tradingstate
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// CandleIntervals are the periods of the price candles indexed by TomoX, from the shortest to the
+// longest: each one is a multiple of the previous one.
+var CandleIntervals = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+// CandleInterval returns the duration of a candle interval, 0 if it is unknown.
+func CandleInterval(name string) time.Duration {
+	for _, interval := range CandleIntervals {
+		if interval.Name == name {
+			return interval.Duration
+		}
+	}
+	return 0
+}
+
+// Candle summarizes the prices and quantities of the trades of a pair matched during an interval
+// starting at OpenTime.
+type Candle struct {
+	BaseToken  common.Address `json:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken"`
+	Interval   string         `json:"interval"`
+	OpenTime   time.Time      `json:"openTime"`
+	Open       *big.Int       `json:"open"`   // price of the first trade
+	High       *big.Int       `json:"high"`   // highest price
+	Low        *big.Int       `json:"low"`    // lowest price
+	Close      *big.Int       `json:"close"`  // price of the last trade
+	Volume     *big.Int       `json:"volume"` // traded quantity of base token
+	Count      uint64         `json:"count"`
+}
+
+// AddTrade adds a trade to the candle. The trades must be added in matching order.
+func (c *Candle) AddTrade(price *big.Int, quantity *big.Int) {
+	c.add(price, price, price, price, quantity, 1)
+}
+
+// AddCandle merges a candle of a shorter interval into the candle. The candles must be added in
+// time order.
+func (c *Candle) AddCandle(candle *Candle) {
+	c.add(candle.Open, candle.High, candle.Low, candle.Close, candle.Volume, candle.Count)
+}
+
+func (c *Candle) add(open, high, low, close, volume *big.Int, count uint64) {
+	if c.Count == 0 {
+		c.Open, c.High, c.Low, c.Volume = CloneBigInt(open), CloneBigInt(high), CloneBigInt(low), new(big.Int)
+	}
+	if high.Cmp(c.High) > 0 {
+		c.High = CloneBigInt(high)
+	}
+	if low.Cmp(c.Low) < 0 {
+		c.Low = CloneBigInt(low)
+	}
+	c.Close = CloneBigInt(close)
+	if volume != nil {
+		c.Volume = new(big.Int).Add(c.Volume, volume)
+	}
+	c.Count += count
+}
@@ -15,13 +15,20 @@ const (
 )
 
 var (
-	EmptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
-	Ask       = "SELL"
-	Bid       = "BUY"
-	Market    = "MO"
-	Limit     = "LO"
-	Cancel    = "CANCELLED"
-	OrderNew  = "NEW"
+	EmptyRoot      = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+	Ask            = "SELL"
+	Bid            = "BUY"
+	Market         = "MO"
+	Limit          = "LO"
+	StopLoss       = "SL"
+	TakeProfit     = "TP"
+	TrailingStop   = "TS"
+	PostOnly       = "PO"
+	FeeTierOrder   = "FT" // sets a fee rate of the fee tier of the relayer from Quantity: maker rate on the SELL side, taker rate on the BUY side
+	MinNotional    = "MN" // sets the minimum notional of the pair in quote token to Quantity
+	PriceBandOrder = "PB" // sets the price band of the pair to Quantity in 1/TomoXBaseFee around the average price of the last Price epochs
+	Cancel         = "CANCELLED"
+	OrderNew       = "NEW"
 )
 
 var EmptyHash = common.Hash{}
@@ -49,11 +56,21 @@ var (
 	ErrInvalidOrderType = errors.New("verify order: unsupported order type")
 	ErrInvalidOrderSide = errors.New("verify order: invalid order side")
 	ErrInvalidStatus    = errors.New("verify order: invalid status")
+	ErrInvalidFeeRate   = errors.New("verify order: invalid fee rate")
+	ErrNotRelayerOwner  = errors.New("verify order: not sent by the relayer owner")
+	ErrInvalidWindow    = errors.New("verify order: invalid price band window")
 
 	// supported order types
 	MatchingOrderType = map[string]bool{
-		Market: true,
-		Limit:  true,
+		Market:         true,
+		Limit:          true,
+		StopLoss:       true,
+		TakeProfit:     true,
+		TrailingStop:   true,
+		PostOnly:       true,
+		FeeTierOrder:   true,
+		MinNotional:    true,
+		PriceBandOrder: true,
 	}
 )
 
@@ -214,6 +231,36 @@ func GetTradingOrderBookHash(baseToken common.Address, quoteToken common.Address
 	return common.BytesToHash(append(baseToken[:16], quoteToken[4:]...))
 }
 
+// GetTradingTriggerBookHash returns the hash of the book holding the stop-loss and take-profit
+// orders of an order book until they are triggered.
+func GetTradingTriggerBookHash(orderBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(orderBook.Bytes(), []byte("trigger"))
+}
+
+// GetTradingMinNotionalHash returns the hash of the object holding the minimum notional of an
+// order book as its last price.
+func GetTradingMinNotionalHash(orderBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(orderBook.Bytes(), []byte("minNotional"))
+}
+
+// GetTradingTwapHash returns the hash of the object holding the trades of an order book during the
+// epochs kept in a slot of its average prices.
+func GetTradingTwapHash(orderBook common.Hash, slot uint64) common.Hash {
+	return crypto.Keccak256Hash(orderBook.Bytes(), common.BigToHash(new(big.Int).SetUint64(slot)).Bytes(), []byte("twap"))
+}
+
+// GetTradingPriceBandHash returns the hash of the object holding the price band of an order book
+// as its last price and its window as its nonce.
+func GetTradingPriceBandHash(orderBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(orderBook.Bytes(), []byte("priceBand"))
+}
+
+// GetTradingTrailingBookHash returns the hash of the book holding the trailing stop orders of an
+// order book until they are triggered.
+func GetTradingTrailingBookHash(orderBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(orderBook.Bytes(), []byte("trailing"))
+}
+
 func GetMatchingResultCacheKey(order *OrderItem) common.Hash {
 	return crypto.Keccak256Hash(order.UserAddress.Bytes(), order.Nonce.Bytes())
 }
@@ -0,0 +1,173 @@
+package tradingstate
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+const (
+	MaxFeeTiers   = 8  // maximum number of fee tiers of a relayer for a quote token
+	FeeVolumeDays = 30 // days of trading volume deciding the fee tier of a user
+)
+
+// flags of the fee rates set in a fee tier
+const (
+	feeTierMakerSet = uint64(1)
+	feeTierTakerSet = uint64(2)
+)
+
+var ErrFeeTiersFull = errors.New("fee tier: too many fee tiers")
+
+// FeeTier holds the fee rates of the users who traded at least MinVolume, in 1/TomoXBaseFee.
+// A nil rate is the flat fee of the relayer.
+//
+// The fee schedule of a relayer for a quote token replaces the flat relayer fee by the fee rates of
+// the tier reached by the volume traded by a user through the relayer during the last
+// FeeVolumeDays days, in quote token. Each tier is held by the object returned by
+// GetTradingFeeTierHash for its slot: its last price is the minimum volume of the tier, its medium
+// price and total quantity are the maker and taker fee rates, and its nonce flags the rates set,
+// a zero nonce marking a free slot. A rate left unset is the flat relayer fee.
+//
+// The volume of a user is kept by day in FeeVolumeDays objects returned by GetTradingFeeVolumeHash,
+// reused in turn: the nonce of an object is the day whose volume it holds as its last price.
+type FeeTier struct {
+	MinVolume *big.Int `json:"minVolume"`
+	MakerFee  *big.Int `json:"makerFee"`
+	TakerFee  *big.Int `json:"takerFee"`
+}
+
+// GetTradingFeeTierHash returns the hash of the object holding a slot of the fee schedule of a
+// relayer for a quote token.
+func GetTradingFeeTierHash(relayer common.Address, quoteToken common.Address, slot uint64) common.Hash {
+	return crypto.Keccak256Hash(relayer.Bytes(), quoteToken.Bytes(), common.BigToHash(new(big.Int).SetUint64(slot)).Bytes(), []byte("feeTier"))
+}
+
+// GetTradingFeeVolumeHash returns the hash of the object holding the volume of a user traded
+// through a relayer in a quote token during the days kept in a slot.
+func GetTradingFeeVolumeHash(relayer common.Address, user common.Address, quoteToken common.Address, slot uint64) common.Hash {
+	return crypto.Keccak256Hash(relayer.Bytes(), user.Bytes(), quoteToken.Bytes(), common.BigToHash(new(big.Int).SetUint64(slot)).Bytes(), []byte("feeVolume"))
+}
+
+func (self *TradingStateDB) getFeeTier(relayer common.Address, quoteToken common.Address, slot uint64) (uint64, FeeTier) {
+	hash := GetTradingFeeTierHash(relayer, quoteToken, slot)
+	flags := self.GetNonce(hash)
+	if flags == 0 {
+		return 0, FeeTier{}
+	}
+	tier := FeeTier{MinVolume: new(big.Int)}
+	if minVolume := self.GetLastPrice(hash); minVolume != nil {
+		tier.MinVolume.Set(minVolume)
+	}
+	makerFee, takerFee := self.GetMediumPriceAndTotalAmount(hash)
+	if flags&feeTierMakerSet != 0 {
+		tier.MakerFee = new(big.Int).Set(makerFee)
+	}
+	if flags&feeTierTakerSet != 0 {
+		tier.TakerFee = new(big.Int).Set(takerFee)
+	}
+	return flags, tier
+}
+
+// GetFeeTiers returns the fee tiers of a relayer for a quote token by ascending minimum volume.
+func (self *TradingStateDB) GetFeeTiers(relayer common.Address, quoteToken common.Address) []FeeTier {
+	tiers := []FeeTier{}
+	for slot := uint64(1); slot <= MaxFeeTiers; slot++ {
+		if flags, tier := self.getFeeTier(relayer, quoteToken, slot); flags != 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinVolume.Cmp(tiers[j].MinVolume) < 0
+	})
+	return tiers
+}
+
+// SetFeeTierRate sets the maker or the taker fee rate of the fee tier of a relayer for a quote
+// token starting at minVolume, adding the tier if needed.
+func (self *TradingStateDB) SetFeeTierRate(relayer common.Address, quoteToken common.Address, minVolume *big.Int, maker bool, rate *big.Int) error {
+	slot := uint64(0)
+	for s := uint64(MaxFeeTiers); s >= 1; s-- {
+		flags, tier := self.getFeeTier(relayer, quoteToken, s)
+		if flags == 0 {
+			slot = s
+		} else if tier.MinVolume.Cmp(minVolume) == 0 {
+			slot = s
+			break
+		}
+	}
+	if slot == 0 {
+		return ErrFeeTiersFull
+	}
+	flags, tier := self.getFeeTier(relayer, quoteToken, slot)
+	makerFee, takerFee := tier.MakerFee, tier.TakerFee
+	if maker {
+		flags, makerFee = flags|feeTierMakerSet, rate
+	} else {
+		flags, takerFee = flags|feeTierTakerSet, rate
+	}
+	if makerFee == nil {
+		makerFee = new(big.Int)
+	}
+	if takerFee == nil {
+		takerFee = new(big.Int)
+	}
+	hash := GetTradingFeeTierHash(relayer, quoteToken, slot)
+	self.SetNonce(hash, flags)
+	self.SetLastPrice(hash, new(big.Int).Set(minVolume))
+	self.SetMediumPrice(hash, new(big.Int).Set(makerFee), new(big.Int).Set(takerFee))
+	return nil
+}
+
+// GetFeeVolume returns the volume of a user traded through a relayer in a quote token during the
+// FeeVolumeDays days up to day.
+func (self *TradingStateDB) GetFeeVolume(relayer common.Address, user common.Address, quoteToken common.Address, day uint64) *big.Int {
+	volume := new(big.Int)
+	for slot := uint64(0); slot < FeeVolumeDays; slot++ {
+		hash := GetTradingFeeVolumeHash(relayer, user, quoteToken, slot)
+		if d := self.GetNonce(hash); d > day || d+FeeVolumeDays <= day {
+			continue
+		}
+		if dayVolume := self.GetLastPrice(hash); dayVolume != nil {
+			volume.Add(volume, dayVolume)
+		}
+	}
+	return volume
+}
+
+// AddFeeVolume adds the quote token quantity of a trade to the volume of a user traded through a
+// relayer during day.
+func (self *TradingStateDB) AddFeeVolume(relayer common.Address, user common.Address, quoteToken common.Address, day uint64, quantity *big.Int) {
+	hash := GetTradingFeeVolumeHash(relayer, user, quoteToken, day%FeeVolumeDays)
+	volume := new(big.Int)
+	if self.GetNonce(hash) == day {
+		if dayVolume := self.GetLastPrice(hash); dayVolume != nil {
+			volume.Set(dayVolume)
+		}
+	} else {
+		self.SetNonce(hash, day)
+	}
+	self.SetLastPrice(hash, volume.Add(volume, quantity))
+}
+
+// FeeTierRates returns the maker and taker fee rates of the tier reached by a volume, falling back
+// to the flat relayer fee for the rates unset or below the first tier.
+func FeeTierRates(tiers []FeeTier, volume *big.Int, relayerFee *big.Int) (*big.Int, *big.Int) {
+	makerFee, takerFee := relayerFee, relayerFee
+	for _, tier := range tiers {
+		if tier.MinVolume.Cmp(volume) > 0 {
+			break
+		}
+		makerFee, takerFee = relayerFee, relayerFee
+		if tier.MakerFee != nil {
+			makerFee = tier.MakerFee
+		}
+		if tier.TakerFee != nil {
+			takerFee = tier.TakerFee
+		}
+	}
+	return makerFee, takerFee
+}
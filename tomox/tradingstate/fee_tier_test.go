@@ -0,0 +1,80 @@
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestFeeTiers(t *testing.T) {
+	relayer, quoteToken := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	tradingStateDB, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	if tiers := tradingStateDB.GetFeeTiers(relayer, quoteToken); len(tiers) != 0 {
+		t.Fatalf("unexpected tiers of an empty schedule: %v", tiers)
+	}
+	tradingStateDB.SetFeeTierRate(relayer, quoteToken, big.NewInt(1000), true, big.NewInt(5))
+	tradingStateDB.SetFeeTierRate(relayer, quoteToken, big.NewInt(100), false, big.NewInt(8))
+	tradingStateDB.SetFeeTierRate(relayer, quoteToken, big.NewInt(1000), false, big.NewInt(6))
+	tradingStateDB.SetFeeTierRate(relayer, quoteToken, big.NewInt(100), true, big.NewInt(0))
+
+	tiers := tradingStateDB.GetFeeTiers(relayer, quoteToken)
+	if len(tiers) != 2 {
+		t.Fatalf("unexpected number of tiers: have %d, want 2", len(tiers))
+	}
+	for i, want := range [][3]int64{{100, 0, 8}, {1000, 5, 6}} {
+		if tiers[i].MinVolume.Int64() != want[0] || tiers[i].MakerFee.Int64() != want[1] || tiers[i].TakerFee.Int64() != want[2] {
+			t.Errorf("unexpected tier %d: have %v %v %v, want %v", i, tiers[i].MinVolume, tiers[i].MakerFee, tiers[i].TakerFee, want)
+		}
+	}
+	relayerFee := big.NewInt(10)
+	for _, test := range []struct {
+		volume, maker, taker int64
+	}{
+		{99, 10, 10}, {100, 0, 8}, {999, 0, 8}, {5000, 5, 6},
+	} {
+		if maker, taker := FeeTierRates(tiers, big.NewInt(test.volume), relayerFee); maker.Int64() != test.maker || taker.Int64() != test.taker {
+			t.Errorf("unexpected rates at volume %d: have %v %v, want %d %d", test.volume, maker, taker, test.maker, test.taker)
+		}
+	}
+
+	for i := int64(3); i <= MaxFeeTiers; i++ {
+		if err := tradingStateDB.SetFeeTierRate(relayer, quoteToken, big.NewInt(i*10000), true, big.NewInt(1)); err != nil {
+			t.Fatalf("failed to add tier %d: %v", i, err)
+		}
+	}
+	if err := tradingStateDB.SetFeeTierRate(relayer, quoteToken, big.NewInt(1), true, big.NewInt(1)); err != ErrFeeTiersFull {
+		t.Errorf("unexpected error adding a tier to a full schedule: %v", err)
+	}
+	if err := tradingStateDB.SetFeeTierRate(relayer, quoteToken, big.NewInt(100), true, big.NewInt(1)); err != nil {
+		t.Errorf("failed to update a tier of a full schedule: %v", err)
+	}
+}
+
+func TestFeeVolume(t *testing.T) {
+	relayer, user, quoteToken := common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+	tradingStateDB, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	day := uint64(18000)
+	tradingStateDB.AddFeeVolume(relayer, user, quoteToken, day, big.NewInt(10))
+	tradingStateDB.AddFeeVolume(relayer, user, quoteToken, day, big.NewInt(5))
+	tradingStateDB.AddFeeVolume(relayer, user, quoteToken, day+10, big.NewInt(20))
+	for _, test := range []struct {
+		day    uint64
+		volume int64
+	}{
+		{day - 1, 0}, {day, 15}, {day + 10, 35}, {day + FeeVolumeDays - 1, 35}, {day + FeeVolumeDays, 20},
+	} {
+		if volume := tradingStateDB.GetFeeVolume(relayer, user, quoteToken, test.day); volume.Int64() != test.volume {
+			t.Errorf("unexpected volume at day %d: have %v, want %d", test.day, volume, test.volume)
+		}
+	}
+	// the slot of the first day is reused FeeVolumeDays later
+	tradingStateDB.AddFeeVolume(relayer, user, quoteToken, day+FeeVolumeDays, big.NewInt(1))
+	if volume := tradingStateDB.GetFeeVolume(relayer, user, quoteToken, day+FeeVolumeDays); volume.Int64() != 21 {
+		t.Errorf("unexpected volume after reusing a slot: have %v, want 21", volume)
+	}
+	if volume := tradingStateDB.GetFeeVolume(relayer, common.HexToAddress("0x4"), quoteToken, day); volume.Sign() != 0 {
+		t.Errorf("unexpected volume of another user: %v", volume)
+	}
+}
@@ -0,0 +1,21 @@
+package tradingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// GetMinNotional returns the minimum notional in quote token of the orders of an order book, zero
+// if there is none.
+func (self *TradingStateDB) GetMinNotional(orderBook common.Hash) *big.Int {
+	if minNotional := self.GetLastPrice(GetTradingMinNotionalHash(orderBook)); minNotional != nil {
+		return new(big.Int).Set(minNotional)
+	}
+	return new(big.Int)
+}
+
+// SetMinNotional sets the minimum notional in quote token of the orders of an order book.
+func (self *TradingStateDB) SetMinNotional(orderBook common.Hash, minNotional *big.Int) {
+	self.SetLastPrice(GetTradingMinNotionalHash(orderBook), new(big.Int).Set(minNotional))
+}
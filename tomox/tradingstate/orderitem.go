@@ -23,6 +23,14 @@ const (
 	OrderStatusRejected      = "REJECTED"
 )
 
+// Reasons recorded in the RejectReason of rejected orders
+const (
+	RejectReasonPostOnly    = "POST_ONLY"    // post-only order crossing the spread
+	RejectReasonMinNotional = "MIN_NOTIONAL" // order below the minimum notional of the pair
+	RejectReasonDust        = "DUST"         // remaining quantity below the minimum notional of the pair after a partial fill
+	RejectReasonPriceBand   = "PRICE_BAND"   // limit order priced outside the price band of the pair
+)
+
 // OrderItem : info that will be store in database
 type OrderItem struct {
 	Quantity        *big.Int       `json:"quantity,omitempty"`
@@ -43,6 +51,7 @@ type OrderItem struct {
 	UpdatedAt       time.Time      `json:"updatedAt,omitempty"`
 	OrderID         uint64         `json:"orderID,omitempty"`
 	ExtraData       string         `json:"extraData,omitempty"`
+	RejectReason    string         `json:"rejectReason,omitempty" rlp:"-"` // why the order was rejected, not part of the trading state
 }
 
 // Signature struct
@@ -77,6 +86,7 @@ type OrderItemBSON struct {
 	UpdatedAt       time.Time        `json:"updatedAt,omitempty" bson:"updatedAt"`
 	OrderID         string           `json:"orderID,omitempty" bson:"orderID"`
 	ExtraData       string           `json:"extraData,omitempty" bson:"extraData"`
+	RejectReason    string           `json:"rejectReason,omitempty" bson:"rejectReason,omitempty"`
 }
 
 func (o *OrderItem) GetBSON() (interface{}, error) {
@@ -97,6 +107,7 @@ func (o *OrderItem) GetBSON() (interface{}, error) {
 		UpdatedAt:       o.UpdatedAt,
 		OrderID:         strconv.FormatUint(o.OrderID, 10),
 		ExtraData:       o.ExtraData,
+		RejectReason:    o.RejectReason,
 	}
 
 	if o.FilledAmount != nil {
@@ -137,6 +148,7 @@ func (o *OrderItem) SetBSON(raw bson.Raw) error {
 		UpdatedAt       time.Time        `json:"updatedAt" bson:"updatedAt"`
 		OrderID         string           `json:"orderID" bson:"orderID"`
 		ExtraData       string           `json:"extraData,omitempty" bson:"extraData"`
+		RejectReason    string           `json:"rejectReason,omitempty" bson:"rejectReason"`
 	})
 
 	err := raw.Unmarshal(decoded)
@@ -184,12 +196,13 @@ func (o *OrderItem) SetBSON(raw bson.Raw) error {
 	}
 	o.OrderID = uint64(orderID)
 	o.ExtraData = decoded.ExtraData
+	o.RejectReason = decoded.RejectReason
 	return nil
 }
 
 // VerifyOrder verify orderItem
-func (o *OrderItem) VerifyOrder(state *state.StateDB) error {
-	if err := o.VerifyBasicOrderInfo(); err != nil {
+func (o *OrderItem) VerifyOrder(state *state.StateDB, signer types.OrderSigner) error {
+	if err := o.VerifyBasicOrderInfo(signer); err != nil {
 		return err
 	}
 	if err := o.verifyRelayer(state); err != nil {
@@ -199,19 +212,33 @@ func (o *OrderItem) VerifyOrder(state *state.StateDB) error {
 		if err := VerifyPair(state, o.ExchangeAddress, o.BaseToken, o.QuoteToken); err != nil {
 			return err
 		}
+		// the fee tiers of a relayer and the minimum notional and price band of the pairs it lists are governed by its owner
+		if (o.Type == FeeTierOrder || o.Type == MinNotional || o.Type == PriceBandOrder) && o.UserAddress != GetRelayerOwner(o.ExchangeAddress, state) {
+			return ErrNotRelayerOwner
+		}
 	}
 	return nil
 }
 
 // VerifyBasicOrderInfo verify basic info
-func (o *OrderItem) VerifyBasicOrderInfo() error {
+func (o *OrderItem) VerifyBasicOrderInfo(signer types.OrderSigner) error {
 
 	if o.Status == OrderNew {
-		if o.Type == Limit {
+		if o.Type == Limit || o.Type == PostOnly || o.Type == StopLoss || o.Type == TakeProfit || o.Type == TrailingStop {
 			if err := o.verifyPrice(); err != nil {
 				return err
 			}
 		}
+		if o.Type == FeeTierOrder {
+			if err := o.verifyFeeRate(); err != nil {
+				return err
+			}
+		}
+		if o.Type == PriceBandOrder {
+			if err := o.verifyPriceBandWindow(); err != nil {
+				return err
+			}
+		}
 		if err := o.verifyQuantity(); err != nil {
 			return err
 		}
@@ -225,7 +252,7 @@ func (o *OrderItem) VerifyBasicOrderInfo() error {
 	if err := o.verifyStatus(); err != nil {
 		return err
 	}
-	if err := o.verifySignature(); err != nil {
+	if err := o.verifySignature(signer); err != nil {
 		return err
 	}
 	return nil
@@ -239,8 +266,8 @@ func (o *OrderItem) verifyRelayer(state *state.StateDB) error {
 	return nil
 }
 
-//verify signatures
-func (o *OrderItem) verifySignature() error {
+// verifySignature checks that the order is signed by its user with the given order signer.
+func (o *OrderItem) verifySignature(signer types.OrderSigner) error {
 	bigstr := o.Nonce.String()
 	n, err := strconv.ParseInt(bigstr, 10, 64)
 	if err != nil {
@@ -253,7 +280,7 @@ func (o *OrderItem) verifySignature() error {
 	tx := types.NewOrderTransaction(uint64(n), o.Quantity, o.Price, o.ExchangeAddress, o.UserAddress,
 		o.BaseToken, o.QuoteToken, o.Status, o.Side, o.Type, o.Hash, o.OrderID)
 	tx.ImportSignature(V, R, S)
-	from, _ := types.OrderSender(types.OrderTxSigner{}, tx)
+	from, _ := types.OrderSender(signer, tx)
 	if from != tx.UserAddress() {
 		return ErrInvalidSignature
 	}
@@ -295,6 +322,24 @@ func (o *OrderItem) verifyPrice() error {
 	return nil
 }
 
+// verifyFeeRate make sure the price of a fee tier order is a fee rate between 0 and 100%
+func (o *OrderItem) verifyFeeRate() error {
+	if o.Price == nil || o.Price.Sign() < 0 || o.Price.Cmp(common.TomoXBaseFee) > 0 {
+		log.Debug("Invalid fee rate", "rate", o.Price)
+		return ErrInvalidFeeRate
+	}
+	return nil
+}
+
+// verifyPriceBandWindow makes sure the window of a price band order is at most MaxTwapWindow epochs
+func (o *OrderItem) verifyPriceBandWindow() error {
+	if o.Price == nil || o.Price.Sign() < 0 || o.Price.Cmp(new(big.Int).SetUint64(MaxTwapWindow)) > 0 {
+		log.Debug("Invalid price band window", "window", o.Price)
+		return ErrInvalidWindow
+	}
+	return nil
+}
+
 // verifyQuantity make sure quantity is a positive number
 func (o *OrderItem) verifyQuantity() error {
 	if o.Quantity == nil || o.Quantity.Cmp(big.NewInt(0)) <= 0 {
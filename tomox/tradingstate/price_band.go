@@ -0,0 +1,30 @@
+package tradingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// PriceBand bounds the prices of the limit orders of an order book around its average price.
+type PriceBand struct {
+	Band   *big.Int `json:"band"`   // largest deviation from the average price in 1/TomoXBaseFee, nil if the order book has no band
+	Window uint64   `json:"window"` // epochs of the time weighted average price, zero for the medium price of the last epoch
+}
+
+// GetPriceBand returns the price band of an order book.
+func (self *TradingStateDB) GetPriceBand(orderBook common.Hash) PriceBand {
+	hash := GetTradingPriceBandHash(orderBook)
+	band := PriceBand{Window: self.GetNonce(hash)}
+	if price := self.GetLastPrice(hash); price != nil && price.Sign() > 0 {
+		band.Band = new(big.Int).Set(price)
+	}
+	return band
+}
+
+// SetPriceBand sets the price band of an order book.
+func (self *TradingStateDB) SetPriceBand(orderBook common.Hash, band PriceBand) {
+	hash := GetTradingPriceBandHash(orderBook)
+	self.SetNonce(hash, band.Window)
+	self.SetLastPrice(hash, new(big.Int).Set(band.Band))
+}
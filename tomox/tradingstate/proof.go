@@ -0,0 +1,37 @@
+package tradingstate
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// Tries of an orderbook, as selected by the light client proofs.
+const (
+	OrderTrie            = iota // open orders, keyed by order id
+	AskTrie                     // ask price levels, keyed by price
+	BidTrie                     // bid price levels, keyed by price
+	LiquidationPriceTrie        // liquidation prices of the lending trades, keyed by price
+)
+
+// OrderBookTrieRoot returns the root of a trie of an orderbook, from the orderbook as encoded in
+// the trading state trie.
+func OrderBookTrieRoot(enc []byte, bookTrie uint) (common.Hash, error) {
+	var data tradingExchangeObject
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		return common.Hash{}, fmt.Errorf("can't decode orderbook: %v", err)
+	}
+	switch bookTrie {
+	case OrderTrie:
+		return data.OrderRoot, nil
+	case AskTrie:
+		return data.AskRoot, nil
+	case BidTrie:
+		return data.BidRoot, nil
+	case LiquidationPriceTrie:
+		return data.LiquidationPriceRoot, nil
+	default:
+		return common.Hash{}, fmt.Errorf("unknown orderbook trie %d", bookTrie)
+	}
+}
@@ -152,7 +152,7 @@ func (self *liquidationPriceState) deepCopy(db *TradingStateDB, onDirty func(liq
 		stateOrderList.trie = db.db.CopyTrie(self.trie)
 	}
 	for key, value := range self.stateLendingBooks {
-		stateOrderList.stateLendingBooks[key] = value.deepCopy(db, self.MarkStateLendingBookDirty)
+		stateOrderList.stateLendingBooks[key] = value.deepCopy(db, stateOrderList.MarkStateLendingBookDirty)
 	}
 	for key, value := range self.stateLendingBooksDirty {
 		stateOrderList.stateLendingBooksDirty[key] = value
@@ -21,6 +21,7 @@ import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
 	"io"
 	"math/big"
 )
@@ -327,25 +328,25 @@ func (self *tradingExchanges) deepCopy(db *TradingStateDB, onDirty func(hash com
 		stateExchanges.ordersTrie = db.db.CopyTrie(self.ordersTrie)
 	}
 	for price, bidObject := range self.stateBidObjects {
-		stateExchanges.stateBidObjects[price] = bidObject.deepCopy(db, self.MarkStateBidObjectDirty)
+		stateExchanges.stateBidObjects[price] = bidObject.deepCopy(db, stateExchanges.MarkStateBidObjectDirty)
 	}
 	for price := range self.stateBidObjectsDirty {
 		stateExchanges.stateBidObjectsDirty[price] = struct{}{}
 	}
 	for price, askObject := range self.stateAskObjects {
-		stateExchanges.stateAskObjects[price] = askObject.deepCopy(db, self.MarkStateAskObjectDirty)
+		stateExchanges.stateAskObjects[price] = askObject.deepCopy(db, stateExchanges.MarkStateAskObjectDirty)
 	}
 	for price := range self.stateAskObjectsDirty {
 		stateExchanges.stateAskObjectsDirty[price] = struct{}{}
 	}
 	for orderId, orderItem := range self.stateOrderObjects {
-		stateExchanges.stateOrderObjects[orderId] = orderItem.deepCopy(self.MarkStateOrderObjectDirty)
+		stateExchanges.stateOrderObjects[orderId] = orderItem.deepCopy(stateExchanges.MarkStateOrderObjectDirty)
 	}
 	for orderId := range self.stateOrderObjectsDirty {
 		stateExchanges.stateOrderObjectsDirty[orderId] = struct{}{}
 	}
 	for price, liquidationPrice := range self.liquidationPriceStates {
-		stateExchanges.liquidationPriceStates[price] = liquidationPrice.deepCopy(db, self.MarkStateLiquidationPriceDirty)
+		stateExchanges.liquidationPriceStates[price] = liquidationPrice.deepCopy(db, stateExchanges.MarkStateLiquidationPriceDirty)
 	}
 	for price := range self.liquidationPriceStatesDirty {
 		stateExchanges.liquidationPriceStatesDirty[price] = struct{}{}
@@ -716,6 +717,37 @@ func (self *tradingExchanges) getAllLowerLiquidationPrice(db Database, limit com
 	return result
 }
 
+// getAllHigherLiquidationPrice returns the liquidation prices above the limit. It reads the
+// committed liquidation price trie.
+func (self *tradingExchanges) getAllHigherLiquidationPrice(db Database, limit common.Hash) map[common.Hash]*liquidationPriceState {
+	result := map[common.Hash]*liquidationPriceState{}
+	it := trie.NewIterator(self.getLiquidationPriceTrie(db).NodeIterator(limit.Bytes()))
+	for it.Next() {
+		price := common.BytesToHash(it.Key)
+		if price == limit {
+			continue
+		}
+		obj := self.liquidationPriceStates[price]
+		if obj == nil {
+			var data orderList
+			if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+				log.Error("Failed to decode state get all higher liquidation price trie", "price", price, "err", err)
+				return result
+			}
+			obj = newLiquidationPriceState(self.db, self.orderBookHash, price, data, self.MarkStateLiquidationPriceDirty)
+			self.liquidationPriceStates[price] = obj
+		}
+		if obj.empty() {
+			continue
+		}
+		result[price] = obj
+	}
+	if it.Err != nil {
+		log.Error("Failed get higher liquidation price trie", "orderbook", self.orderBookHash.Hex(), "err", it.Err)
+	}
+	return result
+}
+
 func (self *tradingExchanges) getHighestLiquidationPrice(db Database) (common.Hash, *liquidationPriceState) {
 	trie := self.getLiquidationPriceTrie(db)
 	encKey, encValue, err := trie.TryGetBestRightKeyAndValue()
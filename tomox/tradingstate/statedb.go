@@ -59,6 +59,9 @@ type TradingStateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// State objects accessed, only recorded after RecordAccesses.
+	accessed map[common.Hash]struct{}
+
 	lock sync.Mutex
 }
 
@@ -424,6 +427,7 @@ func (self *TradingStateDB) updateStateExchangeObject(stateObject *tradingExchan
 
 // Retrieve a state object given my the address. Returns nil if not found.
 func (self *TradingStateDB) getStateExchangeObject(addr common.Hash) (stateObject *tradingExchanges) {
+	self.recordAccess(addr)
 	// Prefer 'live' objects.
 	if obj := self.stateExhangeObjects[addr]; obj != nil {
 		return obj
@@ -468,6 +472,7 @@ func (self *TradingStateDB) MarkStateExchangeObjectDirty(addr common.Hash) {
 // createStateOrderListObject creates a new state object. If there is an existing orderId with
 // the given address, it is overwritten and returned as the second return value.
 func (self *TradingStateDB) createExchangeObject(hash common.Hash) (newobj *tradingExchanges) {
+	self.recordAccess(hash)
 	newobj = newStateExchanges(self, hash, tradingExchangeObject{LendingCount: Zero, MediumPrice: Zero, MediumPriceBeforeEpoch: Zero, TotalQuantity: Zero}, self.MarkStateExchangeObjectDirty)
 	newobj.setNonce(0) // sets the object to dirty
 	self.setStateExchangeObject(newobj)
@@ -638,6 +643,27 @@ func (self *TradingStateDB) GetAllLowerLiquidationPriceData(orderBook common.Has
 	return result
 }
 
+// GetAllHigherLiquidationPriceData returns the ids of the lending trades by lending book and by
+// liquidation price, for the liquidation prices of an orderbook above the given price: the trades
+// liquidated at this price. The state must be left unchanged since it was opened.
+func (self *TradingStateDB) GetAllHigherLiquidationPriceData(orderBook common.Hash, price *big.Int) map[*big.Int]map[common.Hash][]common.Hash {
+	result := map[*big.Int]map[common.Hash][]common.Hash{}
+	orderbookState := self.getStateExchangeObject(orderBook)
+	if orderbookState == nil {
+		return result
+	}
+	for priceHash, liquidationState := range orderbookState.getAllHigherLiquidationPrice(self.db, common.BigToHash(price)) {
+		liquidationData := map[common.Hash][]common.Hash{}
+		for lendingBook, data := range liquidationState.getAllLiquidationData(self.db) {
+			if len(data) > 0 {
+				liquidationData[lendingBook] = data
+			}
+		}
+		result[new(big.Int).SetBytes(priceHash[:])] = liquidationData
+	}
+	return result
+}
+
 func (self *TradingStateDB) GetHighestLiquidationPriceData(orderBook common.Hash, price *big.Int) (*big.Int, map[common.Hash][]common.Hash) {
 	liquidationData := map[common.Hash][]common.Hash{}
 	orderbookState := self.getStateExchangeObject(orderBook)
@@ -0,0 +1,46 @@
+package tradingstate
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// NewTradingStateSync creates a download scheduler of the trading state trie, with the ask, bid,
+// order and liquidation price tries of every orderbook.
+func NewTradingStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	var syncer *trie.Sync
+	// addSubTrie skips the roots of the tries which have never been created
+	addSubTrie := func(root common.Hash, parent common.Hash, callback trie.LeafCallback) {
+		if root != EmptyHash {
+			syncer.AddSubTrie(root, 64, parent, callback)
+		}
+	}
+	// orderListCallback schedules the trie of an order list, whose leaves are orders when callback
+	// is nil, or order lists again.
+	orderListCallback := func(callback trie.LeafCallback) trie.LeafCallback {
+		return func(leaf []byte, parent common.Hash) error {
+			var list orderList
+			if err := rlp.DecodeBytes(leaf, &list); err != nil {
+				return nil
+			}
+			addSubTrie(list.Root, parent, callback)
+			return nil
+		}
+	}
+	callback := func(leaf []byte, parent common.Hash) error {
+		var exchange tradingExchangeObject
+		if err := rlp.DecodeBytes(leaf, &exchange); err != nil {
+			return nil
+		}
+		addSubTrie(exchange.AskRoot, parent, orderListCallback(nil))
+		addSubTrie(exchange.BidRoot, parent, orderListCallback(nil))
+		addSubTrie(exchange.OrderRoot, parent, nil)
+		// liquidation price => lending book => lending trade ids
+		addSubTrie(exchange.LiquidationPriceRoot, parent, orderListCallback(orderListCallback(nil)))
+		return nil
+	}
+	syncer = trie.NewSync(root, database, callback, bloom)
+	return syncer
+}
@@ -0,0 +1,100 @@
+package tradingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// MaxTwapWindow is the longest window of the average prices of an order book, in epochs.
+const MaxTwapWindow = uint64(48)
+
+// Twap holds the average prices of the trades of an order book during a window of epochs.
+//
+// From TIPTomoXTwap, each trade is added to the epoch of its block, kept in MaxTwapWindow objects
+// returned by GetTradingTwapHash reused in turn: the nonce of an object is the epoch it holds plus
+// one, a zero nonce marking a free slot, its last price the sum of the price times the quantity of
+// the trades, and its medium price and total quantity the volume weighted price and the traded
+// quantity of the epoch. The time weighted price gives each epoch of the window the same weight,
+// an epoch without trade weighing the price of the last epoch with trades.
+type Twap struct {
+	Window    uint64   `json:"window"`    // epochs of the window
+	FromEpoch uint64   `json:"fromEpoch"` // first epoch of the window
+	ToEpoch   uint64   `json:"toEpoch"`   // last epoch of the window
+	Twap      *big.Int `json:"twap"`      // time weighted average price, nil without trade
+	Vwap      *big.Int `json:"vwap"`      // volume weighted average price, nil without trade
+	Volume    *big.Int `json:"volume"`    // traded quantity of base token
+	Epochs    uint64   `json:"epochs"`    // epochs of the window with trades
+}
+
+// getTwapEpoch returns the notional and the quantity traded by an order book during an epoch,
+// nil if the epoch has no trade or left the slots.
+func (self *TradingStateDB) getTwapEpoch(orderBook common.Hash, epoch uint64) (*big.Int, *big.Int) {
+	hash := GetTradingTwapHash(orderBook, epoch%MaxTwapWindow)
+	if self.GetNonce(hash) != epoch+1 {
+		return nil, nil
+	}
+	notional := self.GetLastPrice(hash)
+	_, quantity := self.GetMediumPriceAndTotalAmount(hash)
+	if notional == nil || quantity == nil || quantity.Sign() <= 0 {
+		return nil, nil
+	}
+	return notional, quantity
+}
+
+// AddTwapTrade adds a trade of an order book to the average prices of an epoch.
+func (self *TradingStateDB) AddTwapTrade(orderBook common.Hash, epoch uint64, price *big.Int, quantity *big.Int) {
+	if price == nil || price.Sign() <= 0 || quantity == nil || quantity.Sign() <= 0 {
+		return
+	}
+	notional, total := new(big.Int), new(big.Int)
+	if epochNotional, epochQuantity := self.getTwapEpoch(orderBook, epoch); epochQuantity != nil {
+		notional.Set(epochNotional)
+		total.Set(epochQuantity)
+	}
+	notional.Add(notional, new(big.Int).Mul(price, quantity))
+	total.Add(total, quantity)
+
+	hash := GetTradingTwapHash(orderBook, epoch%MaxTwapWindow)
+	self.SetNonce(hash, epoch+1)
+	self.SetLastPrice(hash, notional)
+	self.SetMediumPrice(hash, new(big.Int).Div(notional, total), total)
+}
+
+// GetTwap returns the average prices of an order book during the window of epochs ending at epoch,
+// the window being capped at MaxTwapWindow.
+func (self *TradingStateDB) GetTwap(orderBook common.Hash, epoch uint64, window uint64) *Twap {
+	if window == 0 {
+		window = 1
+	}
+	if window > MaxTwapWindow {
+		window = MaxTwapWindow
+	}
+	twap := &Twap{Window: window, ToEpoch: epoch, Volume: new(big.Int)}
+	if epoch+1 > window {
+		twap.FromEpoch = epoch + 1 - window
+	}
+	var (
+		last         *big.Int
+		sum          = new(big.Int)
+		weighted     = uint64(0)
+		vwapNotional = new(big.Int)
+	)
+	for e := twap.FromEpoch; e <= epoch; e++ {
+		if notional, quantity := self.getTwapEpoch(orderBook, e); quantity != nil {
+			last = new(big.Int).Div(notional, quantity)
+			vwapNotional.Add(vwapNotional, notional)
+			twap.Volume.Add(twap.Volume, quantity)
+			twap.Epochs++
+		}
+		if last != nil {
+			sum.Add(sum, last)
+			weighted++
+		}
+	}
+	if twap.Epochs > 0 {
+		twap.Twap = sum.Div(sum, new(big.Int).SetUint64(weighted))
+		twap.Vwap = vwapNotional.Div(vwapNotional, twap.Volume)
+	}
+	return twap
+}
@@ -0,0 +1,55 @@
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestTwap(t *testing.T) {
+	tradingStateDB, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	orderBook := GetTradingOrderBookHash(common.HexToAddress("0x1"), common.HexToAddress("0x2"))
+	if twap := tradingStateDB.GetTwap(orderBook, 10, 4); twap.Twap != nil || twap.Vwap != nil || twap.Volume.Sign() != 0 {
+		t.Fatalf("average prices without trades: %v", twap)
+	}
+
+	// epoch 7: 1 at 100 and 3 at 200, epoch 9: 4 at 300, no trade in epoch 8
+	tradingStateDB.AddTwapTrade(orderBook, 7, big.NewInt(100), big.NewInt(1))
+	tradingStateDB.AddTwapTrade(orderBook, 7, big.NewInt(200), big.NewInt(3))
+	tradingStateDB.AddTwapTrade(orderBook, 9, big.NewInt(300), big.NewInt(4))
+	root, err := tradingStateDB.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	tradingStateDB, _ = New(root, tradingStateDB.Database())
+
+	twap := tradingStateDB.GetTwap(orderBook, 10, 4)
+	if twap.FromEpoch != 7 || twap.ToEpoch != 10 || twap.Epochs != 2 {
+		t.Errorf("wrong window: %v", twap)
+	}
+	// epochs 7 and 8 weigh 175, epochs 9 and 10 weigh 300
+	if twap.Twap.Int64() != 237 {
+		t.Errorf("wrong time weighted price: have %v, want 237", twap.Twap)
+	}
+	if twap.Vwap.Int64() != 237 || twap.Volume.Int64() != 8 {
+		t.Errorf("wrong volume weighted price or volume: have %v %v, want 237 8", twap.Vwap, twap.Volume)
+	}
+	// a single epoch window
+	if twap := tradingStateDB.GetTwap(orderBook, 8, 1); twap.Twap != nil {
+		t.Errorf("average price of an epoch without trade: %v", twap.Twap)
+	}
+
+	// the slot of epoch 7 is reused by epoch 7+MaxTwapWindow
+	tradingStateDB.AddTwapTrade(orderBook, 7+MaxTwapWindow, big.NewInt(400), big.NewInt(1))
+	if twap := tradingStateDB.GetTwap(orderBook, 7+MaxTwapWindow, 1); twap.Vwap.Int64() != 400 {
+		t.Errorf("wrong price of a reused slot: have %v, want 400", twap.Vwap)
+	}
+	if twap := tradingStateDB.GetTwap(orderBook, 7, 1); twap.Vwap != nil {
+		t.Errorf("price of an epoch left the slots: %v", twap.Vwap)
+	}
+	if twap := tradingStateDB.GetTwap(orderBook, 100, 1000); twap.Window != MaxTwapWindow {
+		t.Errorf("window not capped: %d", twap.Window)
+	}
+}
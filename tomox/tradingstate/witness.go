@@ -0,0 +1,195 @@
+package tradingstate
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/ethdb/memorydb"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// A witness holds the trie nodes of the trading state read by the matching of a block: a validator
+// not holding the trading state of the parent block opens it on the witness supplied by the
+// proposer, and processes the orders of the block against it to check its trading state root.
+//
+// The proposer records the witness through a WitnessDatabase: each trie it opens is mirrored by a
+// shadow trie, opened on a trie database that starts empty and resolves its nodes from the state
+// database. The shadow tries apply the same operations as the tries of the state, so they resolve
+// the nodes the matching reads, which the node store records, while the state keeps committing to
+// the state database.
+
+// witnessStore is the node store of the shadow tries. It resolves the nodes from the trie database
+// of the state, recording them.
+type witnessStore struct {
+	ethdb.KeyValueStore // serves the other keys, the shadow tries commit to their trie database only
+	src                 *trie.Database
+
+	lock  sync.Mutex
+	nodes map[common.Hash][]byte
+}
+
+func (s *witnessStore) Get(key []byte) ([]byte, error) {
+	if len(key) != common.HashLength {
+		return s.KeyValueStore.Get(key)
+	}
+	hash := common.BytesToHash(key)
+	enc, err := s.src.Node(hash)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	s.nodes[hash] = enc
+	s.lock.Unlock()
+	return enc, nil
+}
+
+func (s *witnessStore) Has(key []byte) (bool, error) {
+	enc, err := s.Get(key)
+	return err == nil && enc != nil, nil
+}
+
+// WitnessDatabase wraps a state database, recording the trie nodes read through the tries it
+// opens as the witness of the state changes applied to them.
+type WitnessDatabase struct {
+	Database
+	store  *witnessStore
+	shadow *trie.Database
+}
+
+// NewWitnessDatabase returns a database opening the tries of db, recording the trie nodes they read.
+func NewWitnessDatabase(db Database) *WitnessDatabase {
+	store := &witnessStore{
+		KeyValueStore: memorydb.New(),
+		src:           db.TrieDB(),
+		nodes:         make(map[common.Hash][]byte),
+	}
+	return &WitnessDatabase{
+		Database: db,
+		store:    store,
+		shadow:   trie.NewDatabase(store),
+	}
+}
+
+func (db *WitnessDatabase) openWitnessTrie(tr Trie, root common.Hash) (Trie, error) {
+	shadow, err := NewTomoXTrie(root, db.shadow)
+	if err != nil {
+		return nil, err
+	}
+	return &witnessTrie{Trie: tr, shadow: shadow}, nil
+}
+
+// OpenTrie opens the main account trie.
+func (db *WitnessDatabase) OpenTrie(root common.Hash) (Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return db.openWitnessTrie(tr, root)
+}
+
+// OpenStorageTrie opens the storage trie of an account.
+func (db *WitnessDatabase) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	tr, err := db.Database.OpenStorageTrie(addrHash, root)
+	if err != nil {
+		return nil, err
+	}
+	return db.openWitnessTrie(tr, root)
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *WitnessDatabase) CopyTrie(t Trie) Trie {
+	if t, ok := t.(*witnessTrie); ok {
+		return &witnessTrie{Trie: db.Database.CopyTrie(t.Trie), shadow: t.shadow.Copy()}
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// Witness returns the trie nodes read so far, ordered by hash.
+func (db *WitnessDatabase) Witness() [][]byte {
+	db.store.lock.Lock()
+	defer db.store.lock.Unlock()
+
+	hashes := make([]common.Hash, 0, len(db.store.nodes))
+	for hash := range db.store.nodes {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i].Bytes(), hashes[j].Bytes()) < 0
+	})
+	nodes := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		nodes[i] = common.CopyBytes(db.store.nodes[hash])
+	}
+	return nodes
+}
+
+// NewWitnessStateDatabase returns a state database holding only the trie nodes of a witness. Opening
+// or reading a trie through nodes missing from the witness fails.
+func NewWitnessStateDatabase(nodes [][]byte) Database {
+	db := rawdb.NewMemoryDatabase()
+	for _, node := range nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return NewDatabase(db)
+}
+
+// witnessTrie is a trie of the state mirrored by its shadow trie.
+type witnessTrie struct {
+	Trie
+	shadow *TomoXTrie
+}
+
+func (t *witnessTrie) TryGet(key []byte) ([]byte, error) {
+	t.shadow.TryGet(key)
+	return t.Trie.TryGet(key)
+}
+
+func (t *witnessTrie) TryGetBestLeftKeyAndValue() ([]byte, []byte, error) {
+	t.shadow.TryGetBestLeftKeyAndValue()
+	return t.Trie.TryGetBestLeftKeyAndValue()
+}
+
+func (t *witnessTrie) TryGetAllLeftKeyAndValue(limit []byte) ([][]byte, [][]byte, error) {
+	t.shadow.TryGetAllLeftKeyAndValue(limit)
+	return t.Trie.TryGetAllLeftKeyAndValue(limit)
+}
+
+func (t *witnessTrie) TryGetBestRightKeyAndValue() ([]byte, []byte, error) {
+	t.shadow.TryGetBestRightKeyAndValue()
+	return t.Trie.TryGetBestRightKeyAndValue()
+}
+
+func (t *witnessTrie) TryUpdate(key, value []byte) error {
+	t.shadow.TryUpdate(key, value)
+	return t.Trie.TryUpdate(key, value)
+}
+
+func (t *witnessTrie) TryDelete(key []byte) error {
+	t.shadow.TryDelete(key)
+	return t.Trie.TryDelete(key)
+}
+
+func (t *witnessTrie) Commit(onleaf trie.LeafCallback) (common.Hash, error) {
+	t.shadow.Commit(nil)
+	return t.Trie.Commit(onleaf)
+}
+
+func (t *witnessTrie) NodeIterator(startKey []byte) trie.NodeIterator {
+	return &witnessIterator{NodeIterator: t.Trie.NodeIterator(startKey), shadow: t.shadow.NodeIterator(startKey)}
+}
+
+// witnessIterator is an iterator of a trie of the state moving its shadow trie iterator along.
+type witnessIterator struct {
+	trie.NodeIterator
+	shadow trie.NodeIterator
+}
+
+func (it *witnessIterator) Next(descend bool) bool {
+	it.shadow.Next(descend)
+	return it.NodeIterator.Next(descend)
+}
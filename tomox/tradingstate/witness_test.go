@@ -0,0 +1,94 @@
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestWitnessDatabase(t *testing.T) {
+	var (
+		user     = common.HexToAddress("0x01")
+		book     = common.StringToHash("BTC/TOMO")
+		other    = common.StringToHash("ETH/TOMO")
+		diskdb   = rawdb.NewMemoryDatabase()
+		db       = NewDatabase(diskdb)
+		state, _ = New(common.Hash{}, db)
+	)
+	newOrder := func(id uint64, side string) OrderItem {
+		return OrderItem{
+			OrderID:     id,
+			Quantity:    big.NewInt(int64(id)),
+			Price:       big.NewInt(int64(100 + id)),
+			Side:        side,
+			UserAddress: user,
+			Hash:        common.BigToHash(new(big.Int).SetUint64(id)),
+			Signature:   &Signature{V: 1, R: common.HexToHash("0x11"), S: common.HexToHash("0x22")},
+		}
+	}
+	for id := uint64(1); id <= 40; id++ {
+		side := Ask
+		if id%2 == 0 {
+			side = Bid
+		}
+		state.InsertOrderItem(book, common.Uint64ToHash(id), newOrder(id, side))
+		state.InsertOrderItem(other, common.Uint64ToHash(id), newOrder(id, side))
+	}
+	state.InsertLiquidationPrice(book, big.NewInt(50), other, 1)
+	root, err := state.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit the state: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit the trie database: %v", err)
+	}
+
+	// the orders of a block: a cancellation, a new order and the reads of the matching
+	cancelled := newOrder(7, Ask)
+	process := func(state *TradingStateDB) common.Hash {
+		state.GetBestAskPrice(book)
+		state.GetBestBidPrice(book)
+		state.GetAllLowerLiquidationPriceData(book, big.NewInt(60))
+		if err := state.CancelOrder(book, &cancelled); err != nil {
+			t.Fatalf("failed to cancel order: %v", err)
+		}
+		state.InsertOrderItem(book, common.Uint64ToHash(41), newOrder(41, Bid))
+		state.SetNonce(user.Hash(), 1)
+		return state.IntermediateRoot()
+	}
+
+	witnessDB := NewWitnessDatabase(db)
+	proposed, err := New(root, witnessDB)
+	if err != nil {
+		t.Fatalf("failed to open the state on the witness database: %v", err)
+	}
+	want := process(proposed)
+	if _, err := proposed.Commit(); err != nil {
+		t.Fatalf("failed to commit the proposed state: %v", err)
+	}
+	witness := witnessDB.Witness()
+	nodes := 0
+	for it := diskdb.NewIterator(nil, nil); it.Next(); {
+		nodes++
+	}
+	if len(witness) == 0 || len(witness) >= nodes {
+		t.Fatalf("witness size mismatch: have %d nodes, state has %d", len(witness), nodes)
+	}
+
+	stateless, err := New(root, NewWitnessStateDatabase(witness))
+	if err != nil {
+		t.Fatalf("failed to open the state on the witness: %v", err)
+	}
+	if got := process(stateless); got != want {
+		t.Fatalf("stateless root mismatch: have %x, want %x", got, want)
+	}
+	// the orders of the other book aren't in the witness
+	if order := stateless.GetOrder(other, common.Uint64ToHash(3)); order.Quantity != nil && order.Quantity.Sign() > 0 {
+		t.Errorf("order outside the witness read: %+v", order)
+	}
+	if _, err := New(root, NewWitnessStateDatabase(nil)); err == nil {
+		t.Error("state opened without witness")
+	}
+}
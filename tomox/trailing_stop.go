@@ -0,0 +1,151 @@
+package tomox
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// maxTrailingStopOrders is the maximum number of trailing stop orders waiting in an order book,
+// all of them trailing the medium price whenever it moves.
+const maxTrailingStopOrders = 100
+
+var (
+	errNoTrailedPrice       = errors.New("trailing stop order: no medium price to trail")
+	errTrailingDeltaTooHigh = errors.New("trailing stop order: delta above the medium price")
+	errTrailingBookFull     = errors.New("trailing stop order: too many trailing stop orders")
+)
+
+// Trailing stop orders waiting for their trigger are kept in the trailing book of their order book
+// (see tradingstate.GetTradingTrailingBookHash). The price of a trailing stop order is its delta:
+// a sell order is triggered once the medium price of the last epoch falls by delta below the
+// highest medium price since the order was placed, a buy order once it rises by delta above the
+// lowest one. The stored order is indexed by its current trigger price, on the Bid side of the
+// trailing book for a sell order and on the Ask side for a buy order, while its own side and its
+// delta are kept in ExtraData. Its OrderID is a slot of the trailing book, reused once the order
+// is triggered or cancelled, and the trailing book records the last medium price trailed as its
+// last price.
+type trailingStop struct {
+	Side  string
+	Delta string
+}
+
+func toTrailingOrder(order *tradingstate.OrderItem, trigger *big.Int) tradingstate.OrderItem {
+	item := *order
+	item.Price = trigger
+	item.Side = trailingSide(order.Side)
+	extraData, _ := json.Marshal(trailingStop{Side: order.Side, Delta: order.Price.Text(10)})
+	item.ExtraData = string(extraData)
+	return item
+}
+
+func fromTrailingOrder(item tradingstate.OrderItem) tradingstate.OrderItem {
+	var trailing trailingStop
+	json.Unmarshal([]byte(item.ExtraData), &trailing)
+	order := item
+	order.Side = trailing.Side
+	order.Price, _ = new(big.Int).SetString(trailing.Delta, 10)
+	order.ExtraData = ""
+	return order
+}
+
+// trailingSide returns the side of the trailing book holding a trailing stop order: a sell order is
+// triggered by a falling price, a buy order by a rising price.
+func trailingSide(side string) string {
+	if side == tradingstate.Ask {
+		return tradingstate.Bid
+	}
+	return tradingstate.Ask
+}
+
+// trailingTrigger returns the trigger price of a trailing stop order trailing the given price.
+func trailingTrigger(side string, price *big.Int, delta *big.Int) *big.Int {
+	if side == tradingstate.Ask {
+		return new(big.Int).Sub(price, delta)
+	}
+	return new(big.Int).Add(price, delta)
+}
+
+// processTrailingStopOrder puts a trailing stop order into a free slot of the trailing book of the
+// order book, trailing the medium price of the last epoch.
+func (tomox *TomoX) processTrailingStopOrder(tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) error {
+	price := tradingStateDB.GetMediumPriceBeforeEpoch(orderBook)
+	if price.Sign() <= 0 {
+		return errNoTrailedPrice
+	}
+	trigger := trailingTrigger(order.Side, price, order.Price)
+	if trigger.Sign() <= 0 {
+		return errTrailingDeltaTooHigh
+	}
+	trailingBook := tradingstate.GetTradingTrailingBookHash(orderBook)
+	slots := tradingStateDB.GetNonce(trailingBook)
+	order.OrderID = slots + 1
+	for slot := uint64(1); slot <= slots; slot++ {
+		if item := tradingStateDB.GetOrder(trailingBook, common.BigToHash(new(big.Int).SetUint64(slot))); item.Quantity == nil || item.Quantity.Sign() == 0 {
+			order.OrderID = slot
+			break
+		}
+	}
+	if order.OrderID > maxTrailingStopOrders {
+		return errTrailingBookFull
+	}
+	if order.OrderID > slots {
+		tradingStateDB.SetNonce(trailingBook, order.OrderID)
+	}
+	tradingStateDB.SetLastPrice(trailingBook, price)
+	tradingStateDB.InsertOrderItem(trailingBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)), toTrailingOrder(order, trigger))
+	log.Debug("Trailing stop order added to trailing book", "side", order.Side, "OrderID", order.OrderID, "delta", order.Price, "trigger", trigger)
+	return nil
+}
+
+// trailStopOrders moves the trigger of the trailing stop orders of a trailing book after a change
+// of the medium price: up for the sell orders when the price rises, down for the buy orders when
+// the price falls.
+func trailStopOrders(tradingStateDB *tradingstate.TradingStateDB, trailingBook common.Hash, price *big.Int) {
+	slots := tradingStateDB.GetNonce(trailingBook)
+	for slot := uint64(1); slot <= slots; slot++ {
+		orderId := common.BigToHash(new(big.Int).SetUint64(slot))
+		item := tradingStateDB.GetOrder(trailingBook, orderId)
+		if item.Quantity == nil || item.Quantity.Sign() == 0 {
+			continue
+		}
+		order := fromTrailingOrder(item)
+		trigger := trailingTrigger(order.Side, price, order.Price)
+		if trigger.Sign() <= 0 || (item.Side == tradingstate.Bid && trigger.Cmp(item.Price) <= 0) || (item.Side == tradingstate.Ask && trigger.Cmp(item.Price) >= 0) {
+			continue
+		}
+		if err := tradingStateDB.SubAmountOrderItem(trailingBook, orderId, item.Price, item.Quantity, item.Side); err != nil {
+			log.Error("Failed to trail stop order", "trailingBook", trailingBook.Hex(), "orderId", orderId.Hex(), "err", err)
+			continue
+		}
+		log.Debug("Trail stop order", "side", order.Side, "OrderID", item.OrderID, "from", item.Price, "to", trigger)
+		item.Price = trigger
+		tradingStateDB.InsertOrderItem(trailingBook, orderId, item)
+	}
+}
+
+// processTriggeredTrailingStopOrders trails the trailing stop orders of the order book after a
+// change of the medium price of the last epoch, then removes the ones whose trigger has been
+// crossed from the trailing book, processing them as market orders.
+func (tomox *TomoX) processTriggeredTrailingStopOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash) ([]map[string]string, []*tradingstate.OrderItem) {
+	trailingBook := tradingstate.GetTradingTrailingBookHash(orderBook)
+	if !tradingStateDB.Exist(trailingBook) {
+		return nil, nil
+	}
+	price := tradingStateDB.GetMediumPriceBeforeEpoch(orderBook)
+	if price.Sign() <= 0 {
+		return nil, nil
+	}
+	if trailed := tradingStateDB.GetLastPrice(trailingBook); trailed == nil || trailed.Cmp(price) != 0 {
+		trailStopOrders(tradingStateDB, trailingBook, price)
+		tradingStateDB.SetLastPrice(trailingBook, price)
+	}
+	return tomox.processTriggeredOrders(header, coinbase, chain, statedb, tradingStateDB, orderBook, trailingBook, fromTrailingOrder)
+}
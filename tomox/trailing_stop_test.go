@@ -0,0 +1,81 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestTrailingOrderRoundTrip(t *testing.T) {
+	order := &tradingstate.OrderItem{Type: tradingstate.TrailingStop, Side: tradingstate.Ask, Price: big.NewInt(7), OrderID: 3}
+	item := toTrailingOrder(order, big.NewInt(93))
+	if item.Side != tradingstate.Bid || item.Price.Cmp(big.NewInt(93)) != 0 || item.OrderID != 3 {
+		t.Fatalf("unexpected trailing order: side %s, price %v, OrderID %d", item.Side, item.Price, item.OrderID)
+	}
+	restored := fromTrailingOrder(item)
+	if restored.Side != order.Side || restored.Price.Cmp(order.Price) != 0 || restored.ExtraData != "" {
+		t.Fatalf("unexpected restored order: side %s, price %v, extraData %s", restored.Side, restored.Price, restored.ExtraData)
+	}
+}
+
+func TestTrailingStopOrders(t *testing.T) {
+	tomox := New(&Config{DataDir: t.TempDir()})
+	orderBook := common.StringToHash("BTC/USDT")
+	trailingBook := tradingstate.GetTradingTrailingBookHash(orderBook)
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	newOrder := func(side string, delta int64, hash string) *tradingstate.OrderItem {
+		return &tradingstate.OrderItem{Type: tradingstate.TrailingStop, Side: side, Price: big.NewInt(delta), Quantity: big.NewInt(1), Hash: common.StringToHash(hash)}
+	}
+	bestTriggers := func() (*big.Int, *big.Int) {
+		falling, _ := tradingStateDB.GetBestBidPrice(trailingBook)
+		rising, _ := tradingStateDB.GetBestAskPrice(trailingBook)
+		return falling, rising
+	}
+
+	if err := tomox.processTrailingStopOrder(tradingStateDB, orderBook, newOrder(tradingstate.Ask, 10, "sell")); err != errNoTrailedPrice {
+		t.Fatalf("trailing stop order placed without medium price: %v", err)
+	}
+	tradingStateDB.SetMediumPriceBeforeEpoch(orderBook, big.NewInt(100))
+	if err := tomox.processTrailingStopOrder(tradingStateDB, orderBook, newOrder(tradingstate.Ask, 100, "sell")); err != errTrailingDeltaTooHigh {
+		t.Fatalf("trailing stop order placed with a delta above the price: %v", err)
+	}
+	sell, buy := newOrder(tradingstate.Ask, 10, "sell"), newOrder(tradingstate.Bid, 5, "buy")
+	for _, order := range []*tradingstate.OrderItem{sell, buy} {
+		if err := tomox.processTrailingStopOrder(tradingStateDB, orderBook, order); err != nil {
+			t.Fatalf("failed to place trailing stop order: %v", err)
+		}
+	}
+	if sell.OrderID != 1 || buy.OrderID != 2 {
+		t.Fatalf("unexpected slots: have %d and %d, want 1 and 2", sell.OrderID, buy.OrderID)
+	}
+	if falling, rising := bestTriggers(); falling.Cmp(big.NewInt(90)) != 0 || rising.Cmp(big.NewInt(105)) != 0 {
+		t.Fatalf("unexpected triggers: have %v and %v, want 90 and 105", falling, rising)
+	}
+
+	// the price rises: the sell order trails it, the buy order is triggered
+	tradingStateDB.SetMediumPriceBeforeEpoch(orderBook, big.NewInt(120))
+	tomox.processTriggeredTrailingStopOrders(nil, common.Address{}, nil, statedb, tradingStateDB, orderBook)
+	if falling, rising := bestTriggers(); falling.Cmp(big.NewInt(110)) != 0 || rising.Sign() != 0 {
+		t.Fatalf("unexpected triggers: have %v and %v, want 110 and none", falling, rising)
+	}
+	if trailed := tradingStateDB.GetLastPrice(trailingBook); trailed.Cmp(big.NewInt(120)) != 0 {
+		t.Fatalf("unexpected trailed price: have %v, want 120", trailed)
+	}
+	// the slot of the triggered order is reused
+	reused := newOrder(tradingstate.Bid, 5, "reused")
+	if err := tomox.processTrailingStopOrder(tradingStateDB, orderBook, reused); err != nil || reused.OrderID != 2 {
+		t.Fatalf("unexpected slot: have %d, want 2 (err %v)", reused.OrderID, err)
+	}
+
+	// the price falls back: the sell order is triggered, the buy order trails it
+	tradingStateDB.SetMediumPriceBeforeEpoch(orderBook, big.NewInt(110))
+	tomox.processTriggeredTrailingStopOrders(nil, common.Address{}, nil, statedb, tradingStateDB, orderBook)
+	if falling, rising := bestTriggers(); falling.Sign() != 0 || rising.Cmp(big.NewInt(115)) != 0 {
+		t.Fatalf("unexpected triggers: have %v and %v, want none and 115", falling, rising)
+	}
+}
@@ -0,0 +1,41 @@
+package tomox
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+var errInvalidTwapWindow = fmt.Errorf("twap: window must be between 1 and %d epochs", tradingstate.MaxTwapWindow)
+
+// recordTwapTrade adds a trade to the average prices of its order book for the epoch of the block
+// (see tradingstate.Twap), from TIPTomoXTwap.
+func recordTwapTrade(header *types.Header, chain consensus.ChainContext, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, price *big.Int, quantity *big.Int) {
+	config := chain.Config()
+	if config.Posv == nil || !config.IsTIPTomoXTwap(header.Number) {
+		return
+	}
+	tradingStateDB.AddTwapTrade(orderBook, header.Number.Uint64()/config.Posv.Epoch, price, quantity)
+}
+
+// twap returns the average prices of a pair during the window of epochs ending at the epoch of the
+// current block.
+func (tomox *TomoX) twap(baseToken common.Address, quoteToken common.Address, window uint64) (*tradingstate.Twap, error) {
+	if window == 0 || window > tradingstate.MaxTwapWindow {
+		return nil, errInvalidTwapWindow
+	}
+	block, tradingState, err := tomox.currentTradingState()
+	if err != nil {
+		return nil, err
+	}
+	config := tomox.chain.Config()
+	if config.Posv == nil {
+		return nil, errTradingStateUnavailable
+	}
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+	return tradingState.GetTwap(orderBook, block.NumberU64()/config.Posv.Epoch, window), nil
+}
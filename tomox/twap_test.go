@@ -0,0 +1,41 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+type posvChain struct {
+	consensus.ChainContext
+}
+
+func (posvChain) Config() *params.ChainConfig {
+	return &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 900}}
+}
+
+func TestRecordTwapTrade(t *testing.T) {
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	orderBook := tradingstate.GetTradingOrderBookHash(common.HexToAddress("0x1"), common.HexToAddress("0x2"))
+	header := &types.Header{Number: new(big.Int).Set(common.TIPTomoXTwap)}
+	epoch := header.Number.Uint64() / 900
+
+	before := &types.Header{Number: new(big.Int).Sub(common.TIPTomoXTwap, common.Big1)}
+	recordTwapTrade(before, posvChain{}, tradingStateDB, orderBook, big.NewInt(100), big.NewInt(1))
+	if twap := tradingStateDB.GetTwap(orderBook, epoch, 2); twap.Volume.Sign() != 0 {
+		t.Fatalf("trade recorded before TIPTomoXTwap: %v", twap)
+	}
+	recordTwapTrade(header, posvChain{}, tradingStateDB, orderBook, big.NewInt(100), big.NewInt(1))
+	recordTwapTrade(header, posvChain{}, tradingStateDB, orderBook, big.NewInt(400), big.NewInt(2))
+	if twap := tradingStateDB.GetTwap(orderBook, epoch, 2); twap.Vwap.Int64() != 300 || twap.Volume.Int64() != 3 {
+		t.Errorf("wrong average price or volume: have %v %v, want 300 3", twap.Vwap, twap.Volume)
+	}
+	// chains without epochs don't record trades
+	recordTwapTrade(header, configChain{}, tradingStateDB, orderBook, big.NewInt(100), big.NewInt(1))
+}
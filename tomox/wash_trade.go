@@ -0,0 +1,217 @@
+package tomox
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+const maxWashTradeReportRange = 31 * 24 * time.Hour // Longest period covered by a wash trade report
+
+var errInvalidReportRange = errors.New("invalid report range, expected from < to within 31 days")
+
+// WashTradeReport lists the trading patterns of the users of a relayer hinting at wash trading
+// during a period, pair by pair.
+type WashTradeReport struct {
+	Relayer common.Address   `json:"relayer"`
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Pairs   []*PairWashTrade `json:"pairs"`
+}
+
+// PairWashTrade is the part of a wash trade report about a pair. Volumes are quantities of base
+// token. Self trades are trades whose taker is also the maker, rings are groups of users trading
+// the base token in circles, each of them having both sold to and bought from the others.
+type PairWashTrade struct {
+	BaseToken  common.Address   `json:"baseToken"`
+	QuoteToken common.Address   `json:"quoteToken"`
+	Trades     int              `json:"trades"`
+	Volume     *big.Int         `json:"volume"`
+	SelfTrades int              `json:"selfTrades"`
+	SelfVolume *big.Int         `json:"selfVolume"`
+	Rings      []*WashTradeRing `json:"rings"`
+}
+
+// WashTradeRing is a group of users trading in circles. Retained is the base token quantity the
+// buyers of the ring kept, its net flow: far below the volume, the users mostly traded the same
+// tokens back and forth.
+type WashTradeRing struct {
+	Users    []common.Address `json:"users"`
+	Trades   int              `json:"trades"`
+	Volume   *big.Int         `json:"volume"`
+	Retained *big.Int         `json:"retained"`
+}
+
+// getWashTradeReport analyses the trades of a relayer created between from and to.
+func (tomox *TomoX) getWashTradeReport(relayer common.Address, from, to time.Time) (*WashTradeReport, error) {
+	if !tomox.IsSDKNode() {
+		return nil, errTradingHistoryUnavailable
+	}
+	if !from.Before(to) || to.Sub(from) > maxWashTradeReportRange {
+		return nil, errInvalidReportRange
+	}
+	trades, err := tomox.GetMongoReadDB().GetTradesByExchange(relayer, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return washTradeReport(relayer, from, to, trades), nil
+}
+
+// washTradeReport builds the wash trade report of a relayer from its trades.
+func washTradeReport(relayer common.Address, from, to time.Time, trades []*tradingstate.Trade) *WashTradeReport {
+	type pair struct{ baseToken, quoteToken common.Address }
+	var (
+		pairs  []pair
+		byPair = make(map[pair][]*tradingstate.Trade)
+	)
+	for _, trade := range trades {
+		p := pair{trade.BaseToken, trade.QuoteToken}
+		if _, ok := byPair[p]; !ok {
+			pairs = append(pairs, p)
+		}
+		byPair[p] = append(byPair[p], trade)
+	}
+	report := &WashTradeReport{Relayer: relayer, From: from, To: to, Pairs: []*PairWashTrade{}}
+	for _, p := range pairs {
+		report.Pairs = append(report.Pairs, pairWashTrade(p.baseToken, p.quoteToken, byPair[p]))
+	}
+	return report
+}
+
+// tradeSeller returns the seller and the buyer of the base token of a trade.
+func tradeSeller(trade *tradingstate.Trade) (common.Address, common.Address) {
+	if trade.TakerOrderSide == tradingstate.Bid {
+		return trade.Maker, trade.Taker
+	}
+	return trade.Taker, trade.Maker
+}
+
+// pairWashTrade finds the self trades and the rings among the trades of a pair. The rings are the
+// strongly connected components of more than one user of the graph of the base token transfers.
+func pairWashTrade(baseToken, quoteToken common.Address, trades []*tradingstate.Trade) *PairWashTrade {
+	result := &PairWashTrade{
+		BaseToken:  baseToken,
+		QuoteToken: quoteToken,
+		Volume:     new(big.Int),
+		SelfVolume: new(big.Int),
+		Rings:      []*WashTradeRing{},
+	}
+	transfers := make(map[common.Address]map[common.Address]bool)
+	for _, trade := range trades {
+		result.Trades++
+		result.Volume.Add(result.Volume, trade.Amount)
+		if trade.Taker == trade.Maker {
+			result.SelfTrades++
+			result.SelfVolume.Add(result.SelfVolume, trade.Amount)
+			continue
+		}
+		seller, buyer := tradeSeller(trade)
+		if transfers[seller] == nil {
+			transfers[seller] = make(map[common.Address]bool)
+		}
+		transfers[seller][buyer] = true
+	}
+	for _, users := range stronglyConnected(transfers) {
+		if len(users) < 2 {
+			continue
+		}
+		members := make(map[common.Address]bool, len(users))
+		for _, user := range users {
+			members[user] = true
+		}
+		ring := &WashTradeRing{Users: users, Volume: new(big.Int), Retained: new(big.Int)}
+		flows := make(map[common.Address]*big.Int, len(users))
+		for _, user := range users {
+			flows[user] = new(big.Int)
+		}
+		for _, trade := range trades {
+			seller, buyer := tradeSeller(trade)
+			if seller == buyer || !members[seller] || !members[buyer] {
+				continue
+			}
+			ring.Trades++
+			ring.Volume.Add(ring.Volume, trade.Amount)
+			flows[seller].Sub(flows[seller], trade.Amount)
+			flows[buyer].Add(flows[buyer], trade.Amount)
+		}
+		for _, flow := range flows {
+			if flow.Sign() > 0 {
+				ring.Retained.Add(ring.Retained, flow)
+			}
+		}
+		result.Rings = append(result.Rings, ring)
+	}
+	return result
+}
+
+// stronglyConnected returns the strongly connected components of a directed graph with Tarjan's
+// algorithm, each one and their users sorted by address so that reports are deterministic.
+func stronglyConnected(edges map[common.Address]map[common.Address]bool) [][]common.Address {
+	sortAddresses := func(addresses []common.Address) {
+		sort.Slice(addresses, func(i, j int) bool { return bytes.Compare(addresses[i][:], addresses[j][:]) < 0 })
+	}
+	var nodes []common.Address
+	for node := range edges {
+		nodes = append(nodes, node)
+	}
+	sortAddresses(nodes)
+
+	var (
+		index      = make(map[common.Address]int)
+		lowLink    = make(map[common.Address]int)
+		onStack    = make(map[common.Address]bool)
+		stack      []common.Address
+		components [][]common.Address
+		visit      func(node common.Address)
+	)
+	visit = func(node common.Address) {
+		index[node], lowLink[node] = len(index), len(index)
+		stack = append(stack, node)
+		onStack[node] = true
+
+		var next []common.Address
+		for to := range edges[node] {
+			next = append(next, to)
+		}
+		sortAddresses(next)
+		for _, to := range next {
+			if _, ok := index[to]; !ok {
+				visit(to)
+				if lowLink[to] < lowLink[node] {
+					lowLink[node] = lowLink[to]
+				}
+			} else if onStack[to] && index[to] < lowLink[node] {
+				lowLink[node] = index[to]
+			}
+		}
+		if lowLink[node] != index[node] {
+			return
+		}
+		var component []common.Address
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == node {
+				break
+			}
+		}
+		sortAddresses(component)
+		components = append(components, component)
+	}
+	for _, node := range nodes {
+		if _, ok := index[node]; !ok {
+			visit(node)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		return bytes.Compare(components[i][0][:], components[j][0][:]) < 0
+	})
+	return components
+}
@@ -0,0 +1,88 @@
+package tomox
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestWashTradeReport(t *testing.T) {
+	tomox := New(&Config{DevDB: true})
+	defer tomox.Stop()
+	var (
+		relayer, other = common.HexToAddress("0x10"), common.HexToAddress("0x11")
+		btc, eth, usdt = common.HexToAddress("0x1"), common.HexToAddress("0x2"), common.HexToAddress("0x3")
+		a, b, c, d     = common.HexToAddress("0xa"), common.HexToAddress("0xb"), common.HexToAddress("0xc"), common.HexToAddress("0xd")
+		now            = time.Unix(1600000000, 0).UTC()
+	)
+	db := tomox.GetMongoDB()
+	db.InitBulk()
+	for i, test := range []struct {
+		baseToken    common.Address
+		taker, maker common.Address
+		side         string
+		amount       int64
+		exchange     common.Address
+		createdAfter time.Duration
+	}{
+		{btc, a, b, tradingstate.Ask, 5, relayer, time.Second},   // a sells to b
+		{btc, b, a, tradingstate.Ask, 4, relayer, time.Second},   // b sells to a
+		{btc, c, c, tradingstate.Bid, 3, relayer, time.Second},   // c trades with itself
+		{btc, d, a, tradingstate.Bid, 2, relayer, time.Second},   // d buys from a
+		{eth, a, b, tradingstate.Bid, 1, relayer, time.Second},   // a buys from b
+		{btc, d, a, tradingstate.Ask, 7, other, time.Second},     // another relayer
+		{btc, b, a, tradingstate.Ask, 7, relayer, time.Hour * 2}, // after the period
+	} {
+		trade := &tradingstate.Trade{
+			BaseToken:      test.baseToken,
+			QuoteToken:     usdt,
+			Taker:          test.taker,
+			Maker:          test.maker,
+			TakerExchange:  test.exchange,
+			MakerExchange:  test.exchange,
+			TakerOrderSide: test.side,
+			Hash:           common.BigToHash(big.NewInt(int64(i + 1))),
+			Amount:         big.NewInt(test.amount),
+			PricePoint:     big.NewInt(1),
+			MakeFee:        big.NewInt(0),
+			TakeFee:        big.NewInt(0),
+			CreatedAt:      now.Add(test.createdAfter),
+		}
+		db.PutObject(trade.Hash, trade)
+	}
+	if err := db.CommitBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+
+	report, err := tomox.getWashTradeReport(relayer, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get report: %v", err)
+	}
+	if len(report.Pairs) != 2 {
+		t.Fatalf("wrong number of pairs: have %d, want 2", len(report.Pairs))
+	}
+	pair := report.Pairs[0]
+	if pair.BaseToken != btc || pair.Trades != 4 || pair.Volume.Int64() != 14 || pair.SelfTrades != 1 || pair.SelfVolume.Int64() != 3 {
+		t.Errorf("wrong btc pair: have %d trades of %v, %d self trades of %v", pair.Trades, pair.Volume, pair.SelfTrades, pair.SelfVolume)
+	}
+	if len(pair.Rings) != 1 {
+		t.Fatalf("wrong number of btc rings: have %d, want 1", len(pair.Rings))
+	}
+	ring := pair.Rings[0]
+	if !reflect.DeepEqual(ring.Users, []common.Address{a, b}) || ring.Trades != 2 || ring.Volume.Int64() != 9 || ring.Retained.Int64() != 1 {
+		t.Errorf("wrong btc ring: have users %v, %d trades of %v retaining %v", ring.Users, ring.Trades, ring.Volume, ring.Retained)
+	}
+	if pair := report.Pairs[1]; pair.BaseToken != eth || pair.Trades != 1 || len(pair.Rings) != 0 {
+		t.Errorf("wrong eth pair: have %d trades, %d rings", pair.Trades, len(pair.Rings))
+	}
+
+	for _, period := range [][2]time.Time{{now, now}, {now, now.Add(maxWashTradeReportRange + time.Second)}} {
+		if _, err := tomox.getWashTradeReport(relayer, period[0], period[1]); err != errInvalidReportRange {
+			t.Errorf("unexpected error for the period %v: %v", period, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package tomox
+
+import (
+	"errors"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Nodes started with --tomox.witness record the trie nodes of the trading and lending states read
+// by the matching of each block they seal, and keep them in the tomox leveldb as the witness of the
+// block. A validator not holding the trading and lending states of the parent of a block verifies
+// the block statelessly: it processes the orders of the block against the states opened on the
+// witness, fetched from the proposer with tomox_getBlockWitness and supplied to the validator with
+// tomox_addBlockWitness, and checks the state roots of the block as usual.
+var blockWitnessPrefix = []byte("tomoxWitness-") // blockWitnessPrefix + hash -> BlockWitness
+
+var errNoBlockWitness = errors.New("no witness of the block")
+
+// BlockWitness holds the trie nodes of the trading and lending states of the parent of a block read
+// by the matching of the block.
+type BlockWitness struct {
+	TradingNodes []hexutil.Bytes `json:"tradingNodes"`
+	LendingNodes []hexutil.Bytes `json:"lendingNodes"`
+}
+
+func toWitnessNodes(nodes [][]byte) []hexutil.Bytes {
+	enc := make([]hexutil.Bytes, len(nodes))
+	for i, node := range nodes {
+		enc[i] = node
+	}
+	return enc
+}
+
+func fromWitnessNodes(enc []hexutil.Bytes) [][]byte {
+	nodes := make([][]byte, len(enc))
+	for i, node := range enc {
+		nodes[i] = node
+	}
+	return nodes
+}
+
+func blockWitnessKey(hash common.Hash) []byte {
+	return append(append([]byte{}, blockWitnessPrefix...), hash.Bytes()...)
+}
+
+// RecordsWitness returns whether the node records the witness of the blocks it seals.
+func (tomox *TomoX) RecordsWitness() bool {
+	return tomox.witness
+}
+
+// GetWitnessedTradingState returns the trading state of a block opened on a database recording
+// the trie nodes read through it, for the witness of the child block.
+func (tomox *TomoX) GetWitnessedTradingState(block *types.Block, author common.Address) (*tradingstate.TradingStateDB, *tradingstate.WitnessDatabase, error) {
+	root, err := tomox.GetTradingStateRoot(block, author)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tomox.StateCache == nil {
+		return nil, nil, errors.New("Not initialized tomox")
+	}
+	db := tradingstate.NewWitnessDatabase(tomox.StateCache)
+	state, err := tradingstate.New(root, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return state, db, nil
+}
+
+// WriteBlockWitness records the witness of a block from the trie nodes of the trading and lending
+// states read by its matching.
+func (tomox *TomoX) WriteBlockWitness(hash common.Hash, tradingNodes, lendingNodes [][]byte) error {
+	return tomox.writeBlockWitness(hash, &BlockWitness{TradingNodes: toWitnessNodes(tradingNodes), LendingNodes: toWitnessNodes(lendingNodes)})
+}
+
+func (tomox *TomoX) writeBlockWitness(hash common.Hash, witness *BlockWitness) error {
+	enc, err := rlp.EncodeToBytes(witness)
+	if err != nil {
+		return err
+	}
+	return tomox.GetLevelDB().Put(blockWitnessKey(hash), enc)
+}
+
+// ReadBlockWitness returns the witness of a block, recorded by its proposer or supplied to the node.
+func (tomox *TomoX) ReadBlockWitness(hash common.Hash) (*BlockWitness, error) {
+	enc, err := tomox.GetLevelDB().Get(blockWitnessKey(hash))
+	if err != nil || len(enc) == 0 {
+		return nil, errNoBlockWitness
+	}
+	witness := new(BlockWitness)
+	if err := rlp.DecodeBytes(enc, witness); err != nil {
+		return nil, err
+	}
+	return witness, nil
+}
+
+// ReadLendingWitness returns the trie nodes of the lending state in the witness of a block.
+func (tomox *TomoX) ReadLendingWitness(hash common.Hash) ([][]byte, error) {
+	witness, err := tomox.ReadBlockWitness(hash)
+	if err != nil {
+		return nil, err
+	}
+	return fromWitnessNodes(witness.LendingNodes), nil
+}
+
+// GetTradingStateFromWitness returns the trading state of a block opened on the witness of its
+// child block, holding only the trie nodes read by the matching of the child block.
+func (tomox *TomoX) GetTradingStateFromWitness(block *types.Block, author common.Address, child common.Hash) (*tradingstate.TradingStateDB, error) {
+	root, err := tomox.GetTradingStateRoot(block, author)
+	if err != nil {
+		return nil, err
+	}
+	witness, err := tomox.ReadBlockWitness(child)
+	if err != nil {
+		return nil, err
+	}
+	return tradingstate.New(root, tradingstate.NewWitnessStateDatabase(fromWitnessNodes(witness.TradingNodes)))
+}
@@ -0,0 +1,36 @@
+package tomox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+func TestBlockWitness(t *testing.T) {
+	var (
+		tomox = New(&Config{DataDir: t.TempDir()})
+		hash  = common.HexToHash("0x0a")
+	)
+	if _, err := tomox.ReadBlockWitness(hash); err != errNoBlockWitness {
+		t.Fatalf("missing witness: have %v, want %v", err, errNoBlockWitness)
+	}
+	trading, lending := [][]byte{{0x01, 0x02}, {0x03}}, [][]byte{{0x04}}
+	if err := tomox.WriteBlockWitness(hash, trading, lending); err != nil {
+		t.Fatalf("failed to write witness: %v", err)
+	}
+	witness, err := tomox.ReadBlockWitness(hash)
+	if err != nil {
+		t.Fatalf("failed to read witness: %v", err)
+	}
+	if len(witness.TradingNodes) != 2 || !bytes.Equal(witness.TradingNodes[0], trading[0]) || !bytes.Equal(witness.TradingNodes[1], trading[1]) {
+		t.Errorf("trading nodes mismatch: have %x, want %x", witness.TradingNodes, trading)
+	}
+	nodes, err := tomox.ReadLendingWitness(hash)
+	if err != nil || len(nodes) != 1 || !bytes.Equal(nodes[0], lending[0]) {
+		t.Errorf("lending nodes mismatch: have %x, want %x (%v)", nodes, lending, err)
+	}
+	if _, err := tomox.ReadLendingWitness(common.HexToHash("0x0b")); err != errNoBlockWitness {
+		t.Errorf("lending nodes of a missing witness: have %v, want %v", err, errNoBlockWitness)
+	}
+}
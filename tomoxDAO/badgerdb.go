@@ -0,0 +1,944 @@
+package tomoxDAO
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// BadgerDatabase stores the records of SDK nodes in an embedded BadgerDB, so that a node serves
+// the SDK history queries without an external database. The records are JSON documents keyed by
+// table (the mongodb collection) and hash, with secondary indexes by transaction, user and pair:
+//
+//	r/<table>/<hash>                                  -> record
+//	t/<table>/<txHash>/<hash>                         -> nil
+//	u/<table>/<user>/<^createdAt>/<hash>              -> nil (the most recent first)
+//	p/<table>/<pair>/<createdAt>/<hash>               -> nil
+//
+// The pair of orders and trades is the base token and the quote token, the pair of lending items
+// and trades is the lending token and the term. The user of a lending trade is both the borrower
+// and the investor.
+type BadgerDatabase struct {
+	db          *badger.DB
+	emptyKey    []byte
+	cacheItems  *lru.Cache // Cache for reading
+	lock        sync.Mutex
+	bulk        []badgerOp // pending writes of InitBulk
+	lendingBulk []badgerOp // pending writes of InitLendingBulk
+}
+
+const badgerPruneBatch = 1000 // Number of records removed per transaction by PruneCollection
+
+// badgerOp is a pending write of a bulk. Inserts don't overwrite an existing record, as duplicates
+// are ignored by the mongodb bulks.
+type badgerOp struct {
+	table  string
+	hash   common.Hash
+	val    interface{}
+	upsert bool
+}
+
+// badgerLogger forwards the logs of BadgerDB to the node logger.
+type badgerLogger struct{}
+
+func (badgerLogger) Errorf(format string, args ...interface{}) {
+	log.Error("BadgerDB: " + fmt.Sprintf(format, args...))
+}
+func (badgerLogger) Warningf(format string, args ...interface{}) {
+	log.Warn("BadgerDB: " + fmt.Sprintf(format, args...))
+}
+func (badgerLogger) Infof(format string, args ...interface{}) {
+	log.Debug("BadgerDB: " + fmt.Sprintf(format, args...))
+}
+func (badgerLogger) Debugf(format string, args ...interface{}) {
+	log.Trace("BadgerDB: " + fmt.Sprintf(format, args...))
+}
+
+// NewBadgerDatabase opens the BadgerDB in the given directory.
+func NewBadgerDatabase(dir string, cacheLimit int) (*BadgerDatabase, error) {
+	return openBadgerDatabase(badger.DefaultOptions(dir), cacheLimit)
+}
+
+func openBadgerDatabase(opts badger.Options, cacheLimit int) (*BadgerDatabase, error) {
+	db, err := badger.Open(opts.WithLogger(badgerLogger{}))
+	if err != nil {
+		return nil, err
+	}
+	itemCacheLimit := defaultCacheLimit
+	if cacheLimit > 0 {
+		itemCacheLimit = cacheLimit
+	}
+	cacheItems, _ := lru.New(itemCacheLimit)
+
+	return &BadgerDatabase{
+		db:         db,
+		emptyKey:   EmptyKey(),
+		cacheItems: cacheItems,
+	}, nil
+}
+
+func badgerKey(kind byte, table string, parts ...[]byte) []byte {
+	key := append([]byte{kind, '/'}, table...)
+	key = append(key, '/')
+	for _, part := range parts {
+		key = append(key, part...)
+	}
+	return key
+}
+
+func badgerTime(t time.Time) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, uint64(t.UnixNano()))
+	return enc
+}
+
+func badgerReverseTime(t time.Time) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, math.MaxUint64-uint64(t.UnixNano()))
+	return enc
+}
+
+func lendingPair(lendingToken common.Address, term uint64) []byte {
+	pair := make([]byte, common.AddressLength+8)
+	copy(pair, lendingToken.Bytes())
+	binary.BigEndian.PutUint64(pair[common.AddressLength:], term)
+	return pair
+}
+
+// candlePair is the key of the candles of an interval of a lending book in the indexes.
+func candlePair(lendingToken common.Address, term uint64, interval string) []byte {
+	return append(lendingPair(lendingToken, term), []byte(interval+"/")...)
+}
+
+// insurancePair is the key of the insurance fund records of a kind of a lending book in the indexes.
+func insurancePair(lendingToken common.Address, term uint64, kind string) []byte {
+	return append(lendingPair(lendingToken, term), []byte(kind+"/")...)
+}
+
+// badgerIndexKeys returns the secondary index keys of a record.
+func badgerIndexKeys(table string, val interface{}) [][]byte {
+	var (
+		hash, txHash common.Hash
+		users        []common.Address
+		exchanges    []common.Address
+		pair         []byte
+		createdAt    time.Time
+	)
+	switch val := val.(type) {
+	case *tradingstate.OrderItem:
+		hash, txHash, createdAt = val.Hash, val.TxHash, val.CreatedAt
+		users = []common.Address{val.UserAddress}
+		pair = append(val.BaseToken.Bytes(), val.QuoteToken.Bytes()...)
+	case *tradingstate.Trade:
+		hash, txHash, createdAt = val.Hash, val.TxHash, val.CreatedAt
+		users = []common.Address{val.Taker, val.Maker}
+		exchanges = []common.Address{val.TakerExchange, val.MakerExchange}
+		pair = append(val.BaseToken.Bytes(), val.QuoteToken.Bytes()...)
+	case *tradingstate.EpochPriceItem:
+		return nil
+	case *lendingstate.LendingItem:
+		hash, txHash, createdAt = val.Hash, val.TxHash, val.CreatedAt
+		users = []common.Address{val.UserAddress}
+		pair = lendingPair(val.LendingToken, val.Term)
+	case *lendingstate.LendingTrade:
+		hash, txHash, createdAt = val.Hash, val.TxHash, val.CreatedAt
+		users = []common.Address{val.Borrower, val.Investor}
+		pair = lendingPair(val.LendingToken, val.Term)
+	case *lendingstate.LendingCandle:
+		// candles are only listed by lending book and interval
+		return [][]byte{badgerKey('p', table, candlePair(val.LendingToken, val.Term, val.Interval), badgerTime(val.OpenTime), val.Hash.Bytes())}
+	case *lendingstate.LendingInsuranceRecord:
+		// insurance fund records are listed by lending book and kind, and removed by transaction
+		return [][]byte{
+			badgerKey('t', table, val.TxHash.Bytes(), val.Hash.Bytes()),
+			badgerKey('p', table, insurancePair(val.LendingToken, val.Term, val.Kind), badgerTime(val.CreatedAt), val.Hash.Bytes()),
+		}
+	default:
+		return nil
+	}
+	keys := [][]byte{
+		badgerKey('t', table, txHash.Bytes(), hash.Bytes()),
+		badgerKey('p', table, pair, badgerTime(createdAt), hash.Bytes()),
+	}
+	for i, user := range users {
+		if i > 0 && user == users[0] {
+			continue
+		}
+		keys = append(keys, badgerKey('u', table, user.Bytes(), badgerReverseTime(createdAt), hash.Bytes()))
+	}
+	for i, exchange := range exchanges {
+		if i > 0 && exchange == exchanges[0] {
+			continue
+		}
+		keys = append(keys, badgerKey('x', table, exchange.Bytes(), badgerTime(createdAt), hash.Bytes()))
+	}
+	return keys
+}
+
+// newRecord returns an empty record of the type of val.
+func newRecord(val interface{}) interface{} {
+	switch val.(type) {
+	case *tradingstate.OrderItem:
+		return &tradingstate.OrderItem{}
+	case *tradingstate.Trade:
+		return &tradingstate.Trade{}
+	case *tradingstate.EpochPriceItem:
+		return &tradingstate.EpochPriceItem{}
+	case *lendingstate.LendingItem:
+		return &lendingstate.LendingItem{}
+	case *lendingstate.LendingTrade:
+		return &lendingstate.LendingTrade{}
+	case *lendingstate.LendingCandle:
+		return &lendingstate.LendingCandle{}
+	case *lendingstate.LendingInsuranceRecord:
+		return &lendingstate.LendingInsuranceRecord{}
+	}
+	return nil
+}
+
+// getRecord reads a record of the type of val in a transaction, nil if it doesn't exist.
+func getRecord(txn *badger.Txn, table string, hash common.Hash, val interface{}) (interface{}, error) {
+	item, err := txn.Get(badgerKey('r', table, hash.Bytes()))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := newRecord(val)
+	if err := item.Value(func(data []byte) error { return json.Unmarshal(data, record) }); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// putRecord writes a record and its indexes in a transaction, replacing the indexes of the
+// previous version of the record.
+func putRecord(txn *badger.Txn, table string, hash common.Hash, val interface{}) error {
+	if err := deleteRecord(txn, table, hash, val); err != nil {
+		return err
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(badgerKey('r', table, hash.Bytes()), data); err != nil {
+		return err
+	}
+	for _, key := range badgerIndexKeys(table, val) {
+		if err := txn.Set(key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRecord removes a record and its indexes in a transaction.
+func deleteRecord(txn *badger.Txn, table string, hash common.Hash, val interface{}) error {
+	old, err := getRecord(txn, table, hash, val)
+	if err != nil || old == nil {
+		return err
+	}
+	for _, key := range badgerIndexKeys(table, old) {
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+	return txn.Delete(badgerKey('r', table, hash.Bytes()))
+}
+
+// indexedHashes returns the hashes of the records of an index prefix, in key order from start,
+// until the callback returns false.
+func indexedHashes(txn *badger.Txn, prefix []byte, start []byte, fn func(key []byte, hash common.Hash) bool) {
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+	defer it.Close()
+	for it.Seek(append(append([]byte{}, prefix...), start...)); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().Key()
+		if len(key) < len(prefix)+common.HashLength {
+			continue
+		}
+		if !fn(key, common.BytesToHash(key[len(key)-common.HashLength:])) {
+			return
+		}
+	}
+}
+
+func (db *BadgerDatabase) IsEmptyKey(key []byte) bool {
+	return key == nil || len(key) == 0 || bytes.Equal(key, db.emptyKey)
+}
+
+func (db *BadgerDatabase) getCacheKey(key []byte) string {
+	return hex.EncodeToString(key)
+}
+
+func (db *BadgerDatabase) HasObject(hash common.Hash, val interface{}) (bool, error) {
+	if db.IsEmptyKey(hash.Bytes()) {
+		return false, nil
+	}
+	if db.cacheItems.Contains(db.getCacheKey(hash.Bytes())) {
+		return true, nil
+	}
+	table, ok := sqlTable(val)
+	if !ok {
+		return false, nil
+	}
+	found := false
+	err := db.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(badgerKey('r', table, hash.Bytes()))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		found = err == nil
+		return err
+	})
+	return found, err
+}
+
+func (db *BadgerDatabase) GetObject(hash common.Hash, val interface{}) (interface{}, error) {
+	if db.IsEmptyKey(hash.Bytes()) {
+		return nil, nil
+	}
+	cacheKey := db.getCacheKey(hash.Bytes())
+	if cached, ok := db.cacheItems.Get(cacheKey); ok {
+		return cached, nil
+	}
+	table, ok := sqlTable(val)
+	if !ok {
+		return nil, nil
+	}
+	var record interface{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		var err error
+		record, err = getRecord(txn, table, hash, val)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, badger.ErrKeyNotFound
+	}
+	db.cacheItems.Add(cacheKey, record)
+	return record, nil
+}
+
+// PutObject adds the record to the pending writes of the trading or lending bulk, like the
+// mongodb database.
+func (db *BadgerDatabase) PutObject(hash common.Hash, val interface{}) error {
+	db.cacheItems.Add(db.getCacheKey(hash.Bytes()), val)
+
+	table, ok := sqlTable(val)
+	if !ok {
+		log.Error("PutObject: unknown type of object", "val", val)
+		return nil
+	}
+	upsert := true
+	lending := false
+	switch val := val.(type) {
+	case *tradingstate.Trade:
+		upsert = false
+	case *tradingstate.OrderItem:
+		upsert = val.Status != tradingstate.OrderStatusOpen
+	case *lendingstate.LendingTrade, *lendingstate.LendingCandle, *lendingstate.LendingInsuranceRecord:
+		lending = true
+	case *lendingstate.LendingItem:
+		lending = true
+		switch val.Type {
+		case lendingstate.Repay, lendingstate.TopUp, lendingstate.Recall:
+			if val.Status != lendingstate.LendingStatusReject {
+				val.Status = val.Type
+			}
+			upsert = false
+		default:
+			upsert = val.Status != lendingstate.LendingStatusOpen
+		}
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if lending {
+		db.lendingBulk = append(db.lendingBulk, badgerOp{table: table, hash: hash, val: val, upsert: upsert})
+	} else {
+		db.bulk = append(db.bulk, badgerOp{table: table, hash: hash, val: val, upsert: upsert})
+	}
+	return nil
+}
+
+func (db *BadgerDatabase) DeleteObject(hash common.Hash, val interface{}) error {
+	db.cacheItems.Remove(db.getCacheKey(hash.Bytes()))
+	table, ok := sqlTable(val)
+	if !ok {
+		return nil
+	}
+	if err := db.db.Update(func(txn *badger.Txn) error { return deleteRecord(txn, table, hash, val) }); err != nil {
+		return fmt.Errorf("failed to delete %s. Err: %v", table, err)
+	}
+	return nil
+}
+
+func (db *BadgerDatabase) InitBulk() {
+	db.lock.Lock()
+	db.bulk = nil
+	db.lock.Unlock()
+}
+
+func (db *BadgerDatabase) InitLendingBulk() {
+	db.lock.Lock()
+	db.lendingBulk = nil
+	db.lock.Unlock()
+}
+
+// commit writes the pending writes of a bulk, in as few transactions as their size allows.
+func (db *BadgerDatabase) commit(ops []badgerOp) error {
+	for len(ops) > 0 {
+		n := len(ops)
+		for {
+			err := db.db.Update(func(txn *badger.Txn) error {
+				for _, op := range ops[:n] {
+					if !op.upsert {
+						old, err := getRecord(txn, op.table, op.hash, op.val)
+						if err != nil {
+							return err
+						}
+						if old != nil {
+							continue
+						}
+					}
+					if err := putRecord(txn, op.table, op.hash, op.val); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err == badger.ErrTxnTooBig && n > 1 {
+				n /= 2
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			break
+		}
+		ops = ops[n:]
+	}
+	return nil
+}
+
+func (db *BadgerDatabase) CommitBulk() error {
+	db.lock.Lock()
+	ops := db.bulk
+	db.bulk = nil
+	db.lock.Unlock()
+	return db.commit(ops)
+}
+
+func (db *BadgerDatabase) CommitLendingBulk() error {
+	db.lock.Lock()
+	ops := db.lendingBulk
+	db.lendingBulk = nil
+	db.lock.Unlock()
+	return db.commit(ops)
+}
+
+// The embedded database can't stall for long, the context variants of its methods only check the
+// context before running.
+
+func (db *BadgerDatabase) GetObjectContext(ctx context.Context, hash common.Hash, val interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return db.GetObject(hash, val)
+}
+
+func (db *BadgerDatabase) GetListItemByTxHashContext(ctx context.Context, txhash common.Hash, val interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return db.GetListItemByTxHash(txhash, val), nil
+}
+
+func (db *BadgerDatabase) GetListItemByHashesContext(ctx context.Context, hashes []string, val interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return db.GetListItemByHashes(hashes, val), nil
+}
+
+func (db *BadgerDatabase) CommitBulkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.CommitBulk()
+}
+
+func (db *BadgerDatabase) CommitLendingBulkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.CommitLendingBulk()
+}
+
+func (db *BadgerDatabase) Put(key []byte, val []byte) error {
+	// for levelDB only
+	return nil
+}
+
+func (db *BadgerDatabase) Delete(key []byte) error {
+	// for levelDB only
+	return nil
+}
+
+func (db *BadgerDatabase) Has(key []byte) (bool, error) {
+	// for levelDB only
+	return false, nil
+}
+
+func (db *BadgerDatabase) Get(key []byte) ([]byte, error) {
+	// for levelDB only
+	return nil, nil
+}
+
+func (db *BadgerDatabase) DeleteItemByTxHash(txhash common.Hash, val interface{}) {
+	table, ok := sqlTable(val)
+	if !ok {
+		log.Error("DeleteItemByTxHash: Unknown object type", "txhash", txhash, "object", val)
+		return
+	}
+	err := db.db.Update(func(txn *badger.Txn) error {
+		var hashes []common.Hash
+		indexedHashes(txn, badgerKey('t', table, txhash.Bytes()), nil, func(key []byte, hash common.Hash) bool {
+			hashes = append(hashes, hash)
+			return true
+		})
+		for _, hash := range hashes {
+			db.cacheItems.Remove(db.getCacheKey(hash.Bytes()))
+			if err := deleteRecord(txn, table, hash, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("DeleteItemByTxHash: failed to delete records", "table", table, "txhash", txhash, "err", err)
+	}
+}
+
+// getRecords returns the records of the given hashes as a slice of the type of val.
+func (db *BadgerDatabase) getRecords(txn *badger.Txn, table string, hashes []common.Hash, val interface{}) (interface{}, error) {
+	var documents [][]byte
+	for _, hash := range hashes {
+		item, err := txn.Get(badgerKey('r', table, hash.Bytes()))
+		if err == badger.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, data)
+	}
+	return decodeRecords(documents, val)
+}
+
+func (db *BadgerDatabase) GetListItemByTxHash(txhash common.Hash, val interface{}) interface{} {
+	table, ok := sqlTable(val)
+	if !ok {
+		log.Error("GetListItemByTxHash: Unknown object type", "txhash", txhash, "object", val)
+		return nil
+	}
+	var result interface{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		var hashes []common.Hash
+		indexedHashes(txn, badgerKey('t', table, txhash.Bytes()), nil, func(key []byte, hash common.Hash) bool {
+			hashes = append(hashes, hash)
+			return true
+		})
+		var err error
+		result, err = db.getRecords(txn, table, hashes, val)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to GetListItemByTxHash", "table", table, "err", err, "txhash", txhash)
+	}
+	return result
+}
+
+func (db *BadgerDatabase) GetListItemByHashes(hashes []string, val interface{}) interface{} {
+	table, ok := sqlTable(val)
+	if !ok {
+		log.Error("GetListItemByHashes: Unknown object type", "hashes", hashes, "object", val)
+		return nil
+	}
+	keys := make([]common.Hash, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = common.HexToHash(hash)
+	}
+	var result interface{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		var err error
+		result, err = db.getRecords(txn, table, keys, val)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to GetListItemByHashes", "table", table, "err", err, "hashes", hashes)
+	}
+	return result
+}
+
+// GetLendingListByTime returns the lending items or lending trades of a lending book created in [from, to),
+// its candles of the interval of val opened in [from, to), or its insurance fund records of the kind of val.
+func (db *BadgerDatabase) GetLendingListByTime(lendingToken common.Address, term uint64, from, to time.Time, val interface{}) interface{} {
+	var pair []byte
+	switch val := val.(type) {
+	case *lendingstate.LendingItem, *lendingstate.LendingTrade:
+		pair = lendingPair(lendingToken, term)
+	case *lendingstate.LendingCandle:
+		pair = candlePair(lendingToken, term, val.Interval)
+	case *lendingstate.LendingInsuranceRecord:
+		pair = insurancePair(lendingToken, term, val.Kind)
+	default:
+		log.Error("GetLendingListByTime: Unknown object type", "lendingToken", lendingToken.Hex(), "term", term, "object", val)
+		return nil
+	}
+	table, _ := sqlTable(val)
+	prefix := badgerKey('p', table, pair)
+	end := append(append([]byte{}, prefix...), badgerTime(to)...)
+	var result interface{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		var hashes []common.Hash
+		indexedHashes(txn, prefix, badgerTime(from), func(key []byte, hash common.Hash) bool {
+			if bytes.Compare(key, end) >= 0 {
+				return false
+			}
+			hashes = append(hashes, hash)
+			return true
+		})
+		var err error
+		result, err = db.getRecords(txn, table, hashes, val)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to GetLendingListByTime", "table", table, "err", err, "lendingToken", lendingToken.Hex(), "term", term)
+	}
+	return result
+}
+
+// GetLendingListByUser returns a page of the lending items placed by a user, or of the lending trades
+// in which the user is the borrower or the investor, the most recent first.
+// An empty lending token or a zero term matches every lending book, an empty status every status.
+// The relayer of val (see relayerSelector) restricts the records to the ones of a relayer.
+func (db *BadgerDatabase) GetLendingListByUser(user common.Address, lendingToken common.Address, term uint64, status string, offset, limit int, val interface{}) interface{} {
+	switch val.(type) {
+	case *lendingstate.LendingItem, *lendingstate.LendingTrade:
+	default:
+		log.Error("GetLendingListByUser: Unknown object type", "user", user.Hex(), "object", val)
+		return nil
+	}
+	table, _ := sqlTable(val)
+	relayer := relayerSelector(val)
+	match := func(record interface{}) bool {
+		var (
+			recordToken                        common.Address
+			recordTerm                         uint64
+			recordStatus                       string
+			borrowingRelayer, investingRelayer common.Address
+		)
+		switch record := record.(type) {
+		case *lendingstate.LendingItem:
+			recordToken, recordTerm, recordStatus = record.LendingToken, record.Term, record.Status
+			borrowingRelayer, investingRelayer = record.Relayer, record.Relayer
+		case *lendingstate.LendingTrade:
+			recordToken, recordTerm, recordStatus = record.LendingToken, record.Term, record.Status
+			borrowingRelayer, investingRelayer = record.BorrowingRelayer, record.InvestingRelayer
+		}
+		if relayer != (common.Address{}) && relayer != borrowingRelayer && relayer != investingRelayer {
+			return false
+		}
+		if lendingToken != (common.Address{}) && lendingToken != recordToken {
+			return false
+		}
+		if term > 0 && term != recordTerm {
+			return false
+		}
+		return status == "" || status == recordStatus
+	}
+	var result interface{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		var (
+			hashes  []common.Hash
+			skipped int
+			err     error
+		)
+		indexedHashes(txn, badgerKey('u', table, user.Bytes()), nil, func(key []byte, hash common.Hash) bool {
+			var record interface{}
+			if record, err = getRecord(txn, table, hash, val); err != nil {
+				return false
+			}
+			if record == nil || !match(record) {
+				return true
+			}
+			if skipped < offset {
+				skipped++
+				return true
+			}
+			hashes = append(hashes, hash)
+			return limit <= 0 || len(hashes) < limit
+		})
+		if err != nil {
+			return err
+		}
+		result, err = db.getRecords(txn, table, hashes, val)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to GetLendingListByUser", "table", table, "err", err, "user", user.Hex())
+	}
+	return result
+}
+
+// GetTradingListByUser returns a page of the orders placed by a user, or of the trades in which the
+// user is the taker or the maker, the most recent first.
+// An empty base or quote token matches every pair, an empty status every status.
+func (db *BadgerDatabase) GetTradingListByUser(user common.Address, baseToken, quoteToken common.Address, status string, offset, limit int, val interface{}) interface{} {
+	switch val.(type) {
+	case *tradingstate.OrderItem, *tradingstate.Trade:
+	default:
+		log.Error("GetTradingListByUser: Unknown object type", "user", user.Hex(), "object", val)
+		return nil
+	}
+	table, _ := sqlTable(val)
+	match := func(record interface{}) bool {
+		var (
+			recordBase, recordQuote common.Address
+			recordStatus            string
+		)
+		switch record := record.(type) {
+		case *tradingstate.OrderItem:
+			recordBase, recordQuote, recordStatus = record.BaseToken, record.QuoteToken, record.Status
+		case *tradingstate.Trade:
+			recordBase, recordQuote, recordStatus = record.BaseToken, record.QuoteToken, record.Status
+		}
+		if baseToken != (common.Address{}) && baseToken != recordBase {
+			return false
+		}
+		if quoteToken != (common.Address{}) && quoteToken != recordQuote {
+			return false
+		}
+		return status == "" || status == recordStatus
+	}
+	var result interface{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		var (
+			hashes  []common.Hash
+			skipped int
+			err     error
+		)
+		indexedHashes(txn, badgerKey('u', table, user.Bytes()), nil, func(key []byte, hash common.Hash) bool {
+			var record interface{}
+			if record, err = getRecord(txn, table, hash, val); err != nil {
+				return false
+			}
+			if record == nil || !match(record) {
+				return true
+			}
+			if skipped < offset {
+				skipped++
+				return true
+			}
+			hashes = append(hashes, hash)
+			return limit <= 0 || len(hashes) < limit
+		})
+		if err != nil {
+			return err
+		}
+		result, err = db.getRecords(txn, table, hashes, val)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to GetTradingListByUser", "table", table, "err", err, "user", user.Hex())
+	}
+	return result
+}
+
+// GetTradesByPair returns a page of the trades of a pair ordered by creation time and hash, the
+// oldest first if ascending, the most recent first otherwise. The page starts after the cursor
+// trade, from the first trade in that order if the cursor is nil.
+func (db *BadgerDatabase) GetTradesByPair(baseToken, quoteToken common.Address, cursor *tradingstate.Trade, limit int, ascending bool) ([]*tradingstate.Trade, error) {
+	prefix := badgerKey('p', tradesCollection, baseToken.Bytes(), quoteToken.Bytes())
+	var result interface{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix, Reverse: !ascending})
+		defer it.Close()
+
+		seek := append([]byte{}, prefix...)
+		if cursor != nil {
+			seek = append(append(seek, badgerTime(cursor.CreatedAt)...), cursor.Hash.Bytes()...)
+		} else if !ascending {
+			seek = append(seek, 0xff)
+		}
+		var hashes []common.Hash
+		for it.Seek(seek); it.ValidForPrefix(prefix) && (limit <= 0 || len(hashes) < limit); it.Next() {
+			key := it.Item().Key()
+			if len(key) < len(prefix)+common.HashLength || (cursor != nil && bytes.Equal(key, seek)) {
+				continue
+			}
+			hashes = append(hashes, common.BytesToHash(key[len(key)-common.HashLength:]))
+		}
+		var err error
+		result, err = db.getRecords(txn, tradesCollection, hashes, &tradingstate.Trade{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*tradingstate.Trade), nil
+}
+
+// GetTradesByExchange returns the trades created between from (included) and to (excluded) whose
+// taker or maker order was placed through an exchange, the oldest first.
+func (db *BadgerDatabase) GetTradesByExchange(exchange common.Address, from, to time.Time) ([]*tradingstate.Trade, error) {
+	prefix := badgerKey('x', tradesCollection, exchange.Bytes())
+	end := append(append([]byte{}, prefix...), badgerTime(to)...)
+	var result interface{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		var hashes []common.Hash
+		indexedHashes(txn, prefix, badgerTime(from), func(key []byte, hash common.Hash) bool {
+			if bytes.Compare(key, end) >= 0 {
+				return false
+			}
+			hashes = append(hashes, hash)
+			return true
+		})
+		var err error
+		result, err = db.getRecords(txn, tradesCollection, hashes, &tradingstate.Trade{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*tradingstate.Trade), nil
+}
+
+// PruneCollection removes the records of a table with the given status (any status if empty)
+// last updated before the given time, and returns their number. Nothing is removed in dry-run mode.
+// The records of the table are scanned, as they aren't indexed by update time.
+func (db *BadgerDatabase) PruneCollection(collection string, status string, before time.Time, dryRun bool) (int, error) {
+	val, ok := retentionCollections[collection]
+	if !ok {
+		return 0, fmt.Errorf("unknown collection %s", collection)
+	}
+	var hashes []common.Hash
+	err := db.db.View(func(txn *badger.Txn) error {
+		prefix := badgerKey('r', collection)
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix, PrefetchValues: true, PrefetchSize: 100})
+		defer it.Close()
+		for it.Rewind(); it.ValidForPrefix(prefix); it.Next() {
+			var record struct {
+				Status    string    `json:"status"`
+				UpdatedAt time.Time `json:"updatedAt"`
+			}
+			if err := it.Item().Value(func(data []byte) error { return json.Unmarshal(data, &record) }); err != nil {
+				return err
+			}
+			if record.UpdatedAt.Before(before) && (status == "" || record.Status == status) {
+				hashes = append(hashes, common.BytesToHash(it.Item().Key()[len(prefix):]))
+			}
+		}
+		return nil
+	})
+	if err != nil || dryRun || len(hashes) == 0 {
+		return len(hashes), err
+	}
+	for start := 0; start < len(hashes); start += badgerPruneBatch {
+		end := start + badgerPruneBatch
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		err := db.db.Update(func(txn *badger.Txn) error {
+			for _, hash := range hashes[start:end] {
+				if err := deleteRecord(txn, collection, hash, val); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return start, err
+		}
+	}
+	db.cacheItems.Purge()
+	return len(hashes), nil
+}
+
+func (db *BadgerDatabase) Close() error {
+	return db.db.Close()
+}
+
+// HasAncient returns an error as we don't have a backing chain freezer.
+func (db *BadgerDatabase) HasAncient(kind string, number uint64) (bool, error) {
+	return false, errNotSupported
+}
+
+// Ancient returns an error as we don't have a backing chain freezer.
+func (db *BadgerDatabase) Ancient(kind string, number uint64) ([]byte, error) {
+	return nil, errNotSupported
+}
+
+// Ancients returns an error as we don't have a backing chain freezer.
+func (db *BadgerDatabase) Ancients() (uint64, error) {
+	return 0, errNotSupported
+}
+
+// AncientSize returns an error as we don't have a backing chain freezer.
+func (db *BadgerDatabase) AncientSize(kind string) (uint64, error) {
+	return 0, errNotSupported
+}
+
+// AppendAncient returns an error as we don't have a backing chain freezer.
+func (db *BadgerDatabase) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	return errNotSupported
+}
+
+// TruncateAncients returns an error as we don't have a backing chain freezer.
+func (db *BadgerDatabase) TruncateAncients(items uint64) error {
+	return errNotSupported
+}
+
+// Sync returns an error as we don't have a backing chain freezer.
+func (db *BadgerDatabase) Sync() error {
+	return errNotSupported
+}
+
+func (db *BadgerDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	// for levelDB only
+	return nil
+}
+
+func (db *BadgerDatabase) Stat(property string) (string, error) {
+	return "", errNotSupported
+}
+
+func (db *BadgerDatabase) Compact(start []byte, limit []byte) error {
+	return errNotSupported
+}
+
+func (db *BadgerDatabase) NewBatch() ethdb.Batch {
+	// for levelDB only
+	return nil
+}
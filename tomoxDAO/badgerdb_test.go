@@ -0,0 +1,178 @@
+package tomoxDAO
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestBadgerDatabase(t *testing.T) {
+	db, err := NewBadgerDatabase(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		borrower = common.HexToAddress("0x1")
+		investor = common.HexToAddress("0x2")
+		usdt     = common.HexToAddress("0x10")
+		txHash   = common.HexToHash("0x100")
+		now      = time.Unix(1600000000, 0).UTC()
+	)
+	newItem := func(n int64, term uint64) *lendingstate.LendingItem {
+		return &lendingstate.LendingItem{
+			Quantity:     big.NewInt(100),
+			Interest:     big.NewInt(10),
+			Side:         lendingstate.Borrowing,
+			Type:         lendingstate.Limit,
+			LendingToken: usdt,
+			Term:         term,
+			Status:       lendingstate.LendingStatusOpen,
+			UserAddress:  borrower,
+			Hash:         common.BigToHash(big.NewInt(n)),
+			TxHash:       txHash,
+			CreatedAt:    now.Add(time.Duration(n) * time.Second),
+		}
+	}
+	db.InitLendingBulk()
+	for i := int64(1); i <= 3; i++ {
+		term := uint64(86400)
+		if i == 3 {
+			term = 2 * 86400
+		}
+		db.PutObject(common.BigToHash(big.NewInt(i)), newItem(i, term))
+	}
+	trade := &lendingstate.LendingTrade{
+		Borrower:         borrower,
+		Investor:         investor,
+		LendingToken:     usdt,
+		Term:             86400,
+		Amount:           big.NewInt(40),
+		Status:           lendingstate.TradeStatusOpen,
+		Hash:             common.HexToHash("0x10"),
+		TxHash:           common.HexToHash("0x101"),
+		CreatedAt:        now.Add(10 * time.Second),
+		InvestingRelayer: common.HexToAddress("0x20"),
+	}
+	db.PutObject(trade.Hash, trade)
+	if err := db.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+
+	items := db.GetLendingListByUser(borrower, common.Address{}, 0, "", 0, 10, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem)
+	if len(items) != 3 || items[0].Hash != common.BigToHash(big.NewInt(3)) || items[2].Hash != common.BigToHash(big.NewInt(1)) {
+		t.Fatalf("wrong items by user: %v", items)
+	}
+	if items := db.GetLendingListByUser(borrower, usdt, 86400, "", 1, 10, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem); len(items) != 1 || items[0].Hash != common.BigToHash(big.NewInt(1)) {
+		t.Fatalf("wrong page of items by book: %v", items)
+	}
+	for _, user := range []common.Address{borrower, investor} {
+		if trades := db.GetLendingListByUser(user, common.Address{}, 0, "", 0, 10, &lendingstate.LendingTrade{}).([]*lendingstate.LendingTrade); len(trades) != 1 || trades[0].Hash != trade.Hash {
+			t.Fatalf("wrong trades of %x: %v", user, trades)
+		}
+	}
+	if trades := db.GetLendingListByUser(borrower, common.Address{}, 0, "", 0, 10, &lendingstate.LendingTrade{BorrowingRelayer: common.HexToAddress("0x20")}).([]*lendingstate.LendingTrade); len(trades) != 1 {
+		t.Fatalf("wrong trades of the relayer: %v", trades)
+	}
+	if items := db.GetLendingListByUser(borrower, common.Address{}, 0, "", 0, 10, &lendingstate.LendingItem{Relayer: common.HexToAddress("0x20")}).([]*lendingstate.LendingItem); len(items) != 0 {
+		t.Fatalf("wrong items of the relayer: %v", items)
+	}
+	if items := db.GetLendingListByTime(usdt, 86400, now.Add(2*time.Second), now.Add(time.Hour), &lendingstate.LendingItem{}).([]*lendingstate.LendingItem); len(items) != 1 || items[0].Hash != common.BigToHash(big.NewInt(2)) {
+		t.Fatalf("wrong items by time: %v", items)
+	}
+
+	// updating the status of an item replaces its indexes, inserting it again is ignored
+	db.InitLendingBulk()
+	cancelled := newItem(1, 86400)
+	cancelled.Status = lendingstate.LendingStatusCancelled
+	db.PutObject(cancelled.Hash, cancelled)
+	db.PutObject(trade.Hash, trade)
+	if err := db.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+	if items := db.GetLendingListByUser(borrower, common.Address{}, 0, lendingstate.LendingStatusCancelled, 0, 10, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem); len(items) != 1 || items[0].Hash != cancelled.Hash {
+		t.Fatalf("wrong cancelled items: %v", items)
+	}
+	if items := db.GetListItemByTxHash(txHash, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem); len(items) != 3 {
+		t.Fatalf("wrong items by tx hash: %v", items)
+	}
+
+	db.DeleteItemByTxHash(txHash, &lendingstate.LendingItem{})
+	if items := db.GetLendingListByUser(borrower, common.Address{}, 0, "", 0, 10, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem); len(items) != 0 {
+		t.Fatalf("items not deleted: %v", items)
+	}
+	if found, _ := db.HasObject(trade.Hash, trade); !found {
+		t.Fatalf("trade deleted with the items")
+	}
+}
+
+func TestBadgerTradesByPair(t *testing.T) {
+	db, err := NewBadgerDatabase(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		btc  = common.HexToAddress("0x1")
+		usdt = common.HexToAddress("0x2")
+		now  = time.Unix(1600000000, 0).UTC()
+	)
+	// trades 1 and 2 are created at the same time, trade 4 is of another pair
+	db.InitBulk()
+	for i, createdAt := range []time.Time{now, now, now.Add(time.Second), now.Add(2 * time.Second)} {
+		trade := &tradingstate.Trade{
+			BaseToken:  btc,
+			QuoteToken: usdt,
+			Hash:       common.BigToHash(big.NewInt(int64(i + 1))),
+			TxHash:     common.HexToHash("0x100"),
+			Amount:     big.NewInt(1),
+			PricePoint: big.NewInt(1),
+			MakeFee:    big.NewInt(0),
+			TakeFee:    big.NewInt(0),
+			CreatedAt:  createdAt,
+		}
+		if i == 3 {
+			trade.QuoteToken = common.HexToAddress("0x3")
+		}
+		db.PutObject(trade.Hash, trade)
+	}
+	if err := db.CommitBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+
+	hashes := func(trades []*tradingstate.Trade) []int64 {
+		var result []int64
+		for _, trade := range trades {
+			result = append(result, trade.Hash.Big().Int64())
+		}
+		return result
+	}
+	for _, test := range []struct {
+		cursor    *tradingstate.Trade
+		limit     int
+		ascending bool
+		want      []int64
+	}{
+		{nil, 0, true, []int64{1, 2, 3}},
+		{nil, 2, true, []int64{1, 2}},
+		{&tradingstate.Trade{Hash: common.BigToHash(big.NewInt(1)), CreatedAt: now}, 2, true, []int64{2, 3}},
+		{nil, 0, false, []int64{3, 2, 1}},
+		{&tradingstate.Trade{Hash: common.BigToHash(big.NewInt(2)), CreatedAt: now}, 2, false, []int64{1}},
+		{&tradingstate.Trade{Hash: common.BigToHash(big.NewInt(3)), CreatedAt: now.Add(time.Second)}, 2, true, nil},
+	} {
+		trades, err := db.GetTradesByPair(btc, usdt, test.cursor, test.limit, test.ascending)
+		if err != nil {
+			t.Fatalf("failed to get trades by pair: %v", err)
+		}
+		if have := hashes(trades); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("wrong trades after %v (ascending %v): have %v, want %v", test.cursor, test.ascending, have, test.want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package tomoxDAO
+
+import (
+	"context"
+)
+
+// runContext runs a database operation which can't be cancelled, giving up on it once the context
+// is done. The operation keeps running in the background then, so it must not share state with
+// the caller.
+func runContext(ctx context.Context, op func() error) error {
+	if ctx.Done() == nil {
+		return op()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- op()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
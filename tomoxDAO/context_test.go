@@ -0,0 +1,32 @@
+package tomoxDAO
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunContext(t *testing.T) {
+	opErr := errors.New("op failed")
+	if err := runContext(context.Background(), func() error { return opErr }); err != opErr {
+		t.Fatalf("error mismatch: have %v, want %v", err, opErr)
+	}
+
+	// a done context doesn't run the operation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	if err := runContext(ctx, func() error { ran = true; return nil }); err != context.Canceled || ran {
+		t.Fatalf("cancelled context: have err %v, ran %v", err, ran)
+	}
+
+	// a stalled operation is given up once the deadline expires
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := runContext(ctx, func() error { <-release; return nil }); err != context.DeadlineExceeded {
+		t.Fatalf("error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+}
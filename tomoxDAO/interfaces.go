@@ -5,8 +5,12 @@
 package tomoxDAO
 
 import (
+	"context"
+	"time"
+
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
 )
 
 const defaultCacheLimit = 1024
@@ -24,14 +28,27 @@ type TomoXDAO interface {
 	GetListItemByTxHash(txhash common.Hash, val interface{}) interface{}
 	GetListItemByHashes(hashes []string, val interface{}) interface{}
 	DeleteItemByTxHash(txhash common.Hash, val interface{})
+	GetLendingListByTime(lendingToken common.Address, term uint64, from, to time.Time, val interface{}) interface{}
+	GetLendingListByUser(user common.Address, lendingToken common.Address, term uint64, status string, offset, limit int, val interface{}) interface{}
+	GetTradingListByUser(user common.Address, baseToken, quoteToken common.Address, status string, offset, limit int, val interface{}) interface{}
+	GetTradesByPair(baseToken, quoteToken common.Address, cursor *tradingstate.Trade, limit int, ascending bool) ([]*tradingstate.Trade, error)
+	GetTradesByExchange(exchange common.Address, from, to time.Time) ([]*tradingstate.Trade, error)
+	PruneCollection(collection string, status string, before time.Time, dryRun bool) (int, error)
+
+	// mongodb methods giving up once the context is done, returning its error
+	GetObjectContext(ctx context.Context, hash common.Hash, val interface{}) (interface{}, error)
+	GetListItemByTxHashContext(ctx context.Context, txhash common.Hash, val interface{}) (interface{}, error)
+	GetListItemByHashesContext(ctx context.Context, hashes []string, val interface{}) (interface{}, error)
 
 	// basic tomox
 	InitBulk()
 	CommitBulk() error
+	CommitBulkContext(ctx context.Context) error
 
 	// tomox lending
 	InitLendingBulk()
 	CommitLendingBulk() error
+	CommitLendingBulkContext(ctx context.Context) error
 
 	// leveldb methods
 	Put(key []byte, value []byte) error
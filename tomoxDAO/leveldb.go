@@ -2,15 +2,18 @@ package tomoxDAO
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"errors"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/rawdb"
 	"sync"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/tomochain/tomochain/ethdb"
 	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
 )
 
 type BatchItem struct {
@@ -38,6 +41,10 @@ func NewBatchDatabaseWithEncode(datadir string, cacheLimit int) *BatchDatabase {
 		log.Error("Can't create new DB", "error", err)
 		return nil
 	}
+	return newBatchDatabase(db, cacheLimit)
+}
+
+func newBatchDatabase(db ethdb.Database, cacheLimit int) *BatchDatabase {
 	itemCacheLimit := defaultCacheLimit
 	if cacheLimit > 0 {
 		itemCacheLimit = cacheLimit
@@ -74,6 +81,11 @@ func (db *BatchDatabase) GetObject(hash common.Hash, val interface{}) (interface
 	return nil, nil
 }
 
+func (db *BatchDatabase) GetObjectContext(ctx context.Context, hash common.Hash, val interface{}) (interface{}, error) {
+	// for mongodb only
+	return nil, nil
+}
+
 func (db *BatchDatabase) PutObject(hash common.Hash, val interface{}) error {
 	// for mongodb only
 	return nil
@@ -119,6 +131,38 @@ func (db *BatchDatabase) GetListItemByHashes(hashes []string, val interface{}) i
 	return []interface{}{}
 }
 
+func (db *BatchDatabase) GetListItemByTxHashContext(ctx context.Context, txhash common.Hash, val interface{}) (interface{}, error) {
+	return []interface{}{}, nil
+}
+
+func (db *BatchDatabase) GetListItemByHashesContext(ctx context.Context, hashes []string, val interface{}) (interface{}, error) {
+	return []interface{}{}, nil
+}
+
+func (db *BatchDatabase) GetLendingListByTime(lendingToken common.Address, term uint64, from, to time.Time, val interface{}) interface{} {
+	return []interface{}{}
+}
+
+func (db *BatchDatabase) GetLendingListByUser(user common.Address, lendingToken common.Address, term uint64, status string, offset, limit int, val interface{}) interface{} {
+	return []interface{}{}
+}
+
+func (db *BatchDatabase) GetTradingListByUser(user common.Address, baseToken, quoteToken common.Address, status string, offset, limit int, val interface{}) interface{} {
+	return []interface{}{}
+}
+
+func (db *BatchDatabase) GetTradesByPair(baseToken, quoteToken common.Address, cursor *tradingstate.Trade, limit int, ascending bool) ([]*tradingstate.Trade, error) {
+	return []*tradingstate.Trade{}, nil
+}
+
+func (db *BatchDatabase) GetTradesByExchange(exchange common.Address, from, to time.Time) ([]*tradingstate.Trade, error) {
+	return []*tradingstate.Trade{}, nil
+}
+
+func (db *BatchDatabase) PruneCollection(collection string, status string, before time.Time, dryRun bool) (int, error) {
+	return 0, errNotSupported
+}
+
 func (db *BatchDatabase) InitBulk() {
 }
 
@@ -126,6 +170,10 @@ func (db *BatchDatabase) CommitBulk() error {
 	return nil
 }
 
+func (db *BatchDatabase) CommitBulkContext(ctx context.Context) error {
+	return nil
+}
+
 func (db *BatchDatabase) InitLendingBulk() {
 }
 
@@ -133,6 +181,10 @@ func (db *BatchDatabase) CommitLendingBulk() error {
 	return nil
 }
 
+func (db *BatchDatabase) CommitLendingBulkContext(ctx context.Context) error {
+	return nil
+}
+
 var errNotSupported = errors.New("this operation is not supported")
 
 // HasAncient returns an error as we don't have a backing chain freezer.
@@ -171,13 +223,13 @@ func (db *BatchDatabase) Sync() error {
 }
 
 func (db *BatchDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
-	return db.NewIterator(prefix, start)
+	return db.db.NewIterator(prefix, start)
 }
 
 func (db *BatchDatabase) Stat(property string) (string, error) {
-	return db.Stat(property)
+	return db.db.Stat(property)
 }
 
 func (db *BatchDatabase) Compact(start []byte, limit []byte) error {
-	return db.Compact(start, limit)
+	return db.db.Compact(start, limit)
 }
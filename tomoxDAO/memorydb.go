@@ -0,0 +1,21 @@
+package tomoxDAO
+
+import (
+	"github.com/dgraph-io/badger/v3"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+// The in-memory databases run TomoX without any database on disk, for unit tests and development
+// nodes (--tomox.devdb): NewMemoryDatabase replaces the leveldb of the trading and lending states,
+// NewMemoryBadgerDatabase the SDK database. They serve the same queries as their on-disk
+// counterparts, and lose their data once closed.
+
+// NewMemoryDatabase returns a leveldb database kept in memory.
+func NewMemoryDatabase(cacheLimit int) *BatchDatabase {
+	return newBatchDatabase(rawdb.NewMemoryDatabase(), cacheLimit)
+}
+
+// NewMemoryBadgerDatabase returns an SDK database kept in memory.
+func NewMemoryBadgerDatabase(cacheLimit int) (*BadgerDatabase, error) {
+	return openBadgerDatabase(badger.DefaultOptions("").WithInMemory(true), cacheLimit)
+}
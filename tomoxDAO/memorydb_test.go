@@ -0,0 +1,48 @@
+package tomoxDAO
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestMemoryDatabases(t *testing.T) {
+	ldb := NewMemoryDatabase(0)
+	defer ldb.Close()
+	if err := ldb.Put([]byte("key-1"), []byte("value")); err != nil {
+		t.Fatalf("failed to put key: %v", err)
+	}
+	if value, err := ldb.Get([]byte("key-1")); err != nil || !bytes.Equal(value, []byte("value")) {
+		t.Errorf("wrong value: %q, %v", value, err)
+	}
+	it := ldb.NewIterator([]byte("key-"), nil)
+	if !it.Next() || !bytes.Equal(it.Key(), []byte("key-1")) {
+		t.Error("key not iterated")
+	}
+	it.Release()
+
+	sdb, err := NewMemoryBadgerDatabase(0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer sdb.Close()
+	var (
+		user = common.HexToAddress("0x1")
+		item = &lendingstate.LendingItem{Hash: common.HexToHash("0x1"), TxHash: common.HexToHash("0x100"), UserAddress: user,
+			Quantity: big.NewInt(100), Status: lendingstate.LendingStatusOpen}
+	)
+	sdb.InitLendingBulk()
+	if err := sdb.PutObject(item.Hash, item); err != nil {
+		t.Fatalf("failed to put item: %v", err)
+	}
+	if err := sdb.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+	items := sdb.GetLendingListByUser(user, common.Address{}, 0, "", 0, 0, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem)
+	if len(items) != 1 || items[0].Hash != item.Hash {
+		t.Errorf("wrong items of the user: %v", items)
+	}
+}
@@ -2,6 +2,7 @@ package tomoxDAO
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"github.com/globalsign/mgo"
@@ -12,19 +13,22 @@ import (
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	ordersCollection        = "orders"
-	tradesCollection        = "trades"
-	lendingItemsCollection  = "lending_items"
-	lendingTradesCollection = "lending_trades"
-	lendingTopUpCollection  = "lending_topups"
-	lendingRepayCollection  = "lending_repays"
-	lendingRecallCollection = "lending_recalls"
-	epochPriceCollection    = "epoch_prices"
+	ordersCollection           = "orders"
+	tradesCollection           = "trades"
+	lendingItemsCollection     = "lending_items"
+	lendingTradesCollection    = "lending_trades"
+	lendingTopUpCollection     = "lending_topups"
+	lendingRepayCollection     = "lending_repays"
+	lendingRecallCollection    = "lending_recalls"
+	epochPriceCollection       = "epoch_prices"
+	lendingCandleCollection    = "lending_candles"
+	lendingInsuranceCollection = "lending_insurance"
 )
 
 type MongoDatabase struct {
@@ -40,18 +44,63 @@ type MongoDatabase struct {
 	recallBulk       *mgo.Bulk
 	repayBulk        *mgo.Bulk
 	lendingTradeBulk *mgo.Bulk
+	candleBulk       *mgo.Bulk
+	insuranceBulk    *mgo.Bulk
+	replica          *MongoDatabase // serves the read-only queries, see ReadReplica
+}
+
+// MongoOptions are the connection options of a MongoDB database.
+type MongoOptions struct {
+	ReplicaSetName string // replica set of the hosts, empty for a standalone server
+	ReadPreference string // members serving the read-only queries: primary (default), primaryPreferred, secondary, secondaryPreferred or nearest
+	PoolLimit      int    // maximum number of sockets per server, 0 for the driver default
+	WriteConcern   string // acknowledgement of the writes: majority or a number of members, empty for the driver default
+}
+
+// mongoReadModes maps the read preferences to the consistency modes of the driver.
+var mongoReadModes = map[string]mgo.Mode{
+	"":                   mgo.Primary,
+	"primary":            mgo.Primary,
+	"primaryPreferred":   mgo.PrimaryPreferred,
+	"secondary":          mgo.Secondary,
+	"secondaryPreferred": mgo.SecondaryPreferred,
+	"nearest":            mgo.Nearest,
+}
+
+// parseWriteConcern returns the safety mode of a write concern, nil for the driver default.
+func parseWriteConcern(concern string) (*mgo.Safe, error) {
+	switch concern {
+	case "":
+		return nil, nil
+	case "majority":
+		return &mgo.Safe{WMode: "majority"}, nil
+	}
+	w, err := strconv.Atoi(concern)
+	if err != nil || w < 1 {
+		return nil, fmt.Errorf("invalid write concern %q", concern)
+	}
+	return &mgo.Safe{W: w}, nil
 }
 
 // InitSession initializes a new session with mongodb
-func NewMongoDatabase(session *mgo.Session, dbName string, mongoURL string, replicaSetName string, cacheLimit int) (*MongoDatabase, error) {
+func NewMongoDatabase(session *mgo.Session, dbName string, mongoURL string, opts MongoOptions, cacheLimit int) (*MongoDatabase, error) {
+	readMode, ok := mongoReadModes[opts.ReadPreference]
+	if !ok {
+		return nil, fmt.Errorf("invalid read preference %q", opts.ReadPreference)
+	}
+	safe, err := parseWriteConcern(opts.WriteConcern)
+	if err != nil {
+		return nil, err
+	}
 	if session == nil {
 		// in case of multiple database instances
 		hosts := strings.Split(mongoURL, ",")
 		dbInfo := &mgo.DialInfo{
 			Addrs:          hosts,
 			Database:       dbName,
-			ReplicaSetName: replicaSetName,
+			ReplicaSetName: opts.ReplicaSetName,
 			Timeout:        30 * time.Second,
+			PoolLimit:      opts.PoolLimit,
 		}
 		ns, err := mgo.DialWithInfo(dbInfo)
 		if err != nil {
@@ -59,6 +108,11 @@ func NewMongoDatabase(session *mgo.Session, dbName string, mongoURL string, repl
 		}
 		session = ns
 	}
+	// the bulks and the reads of the SDK synchronisation must see the last writes
+	session.SetMode(mgo.Primary, true)
+	if safe != nil {
+		session.SetSafe(safe)
+	}
 	itemCacheLimit := defaultCacheLimit
 	if cacheLimit > 0 {
 		itemCacheLimit = cacheLimit
@@ -70,12 +124,31 @@ func NewMongoDatabase(session *mgo.Session, dbName string, mongoURL string, repl
 		dbName:     dbName,
 		cacheItems: cacheItems,
 	}
+	db.replica = db
+	if readMode != mgo.Primary {
+		// the replica has its own cache, the records it reads may lag behind the primary
+		replicaSession := session.Copy()
+		replicaSession.SetMode(readMode, true)
+		replicaCache, _ := lru.New(itemCacheLimit)
+		db.replica = &MongoDatabase{
+			Session:    replicaSession,
+			dbName:     dbName,
+			cacheItems: replicaCache,
+		}
+	}
 	if err := db.EnsureIndexes(); err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
+// ReadReplica returns the database serving the read-only queries of the SDK APIs, reading from
+// the members chosen by the read preference. It must not be written to, and may lag behind the
+// primary.
+func (db *MongoDatabase) ReadReplica() *MongoDatabase {
+	return db.replica
+}
+
 func (db *MongoDatabase) IsEmptyKey(key []byte) bool {
 	return key == nil || len(key) == 0 || bytes.Equal(key, db.emptyKey)
 }
@@ -152,6 +225,28 @@ func (db *MongoDatabase) HasObject(hash common.Hash, val interface{}) (bool, err
 			return false, err
 		}
 
+		if count == 1 {
+			return true, nil
+		}
+	case *lendingstate.LendingCandle:
+		// Find key in lendingCandleCollection collection
+		count, err = sc.DB(db.dbName).C(lendingCandleCollection).Find(query).Limit(1).Count()
+
+		if err != nil {
+			return false, err
+		}
+
+		if count == 1 {
+			return true, nil
+		}
+	case *lendingstate.LendingInsuranceRecord:
+		// Find key in lendingInsuranceCollection collection
+		count, err = sc.DB(db.dbName).C(lendingInsuranceCollection).Find(query).Limit(1).Count()
+
+		if err != nil {
+			return false, err
+		}
+
 		if count == 1 {
 			return true, nil
 		}
@@ -219,6 +314,22 @@ func (db *MongoDatabase) GetObject(hash common.Hash, val interface{}) (interface
 			}
 			db.cacheItems.Add(cacheKey, t)
 			return t, nil
+		case *lendingstate.LendingCandle:
+			var c *lendingstate.LendingCandle
+			err := sc.DB(db.dbName).C(lendingCandleCollection).Find(query).One(&c)
+			if err != nil {
+				return nil, err
+			}
+			db.cacheItems.Add(cacheKey, c)
+			return c, nil
+		case *lendingstate.LendingInsuranceRecord:
+			var r *lendingstate.LendingInsuranceRecord
+			err := sc.DB(db.dbName).C(lendingInsuranceCollection).Find(query).One(&r)
+			if err != nil {
+				return nil, err
+			}
+			db.cacheItems.Add(cacheKey, r)
+			return r, nil
 		default:
 			return nil, nil
 		}
@@ -257,6 +368,16 @@ func (db *MongoDatabase) PutObject(hash common.Hash, val interface{}) error {
 		} else {
 			db.lendingTradeBulk.Insert(lt)
 		}
+	case *lendingstate.LendingCandle:
+		c := val.(*lendingstate.LendingCandle)
+		query := bson.M{"hash": c.Hash.Hex()}
+		db.candleBulk.Upsert(query, c)
+		return nil
+	case *lendingstate.LendingInsuranceRecord:
+		r := val.(*lendingstate.LendingInsuranceRecord)
+		query := bson.M{"hash": r.Hash.Hex()}
+		db.insuranceBulk.Upsert(query, r)
+		return nil
 	case *lendingstate.LendingItem:
 		// PutObject order into ordersCollection collection
 		li := val.(*lendingstate.LendingItem)
@@ -343,6 +464,16 @@ func (db *MongoDatabase) DeleteObject(hash common.Hash, val interface{}) error {
 			if err != nil && err != mgo.ErrNotFound {
 				return fmt.Errorf("failed to delete lendingTrade. Err: %v", err)
 			}
+		case *lendingstate.LendingCandle:
+			err = sc.DB(db.dbName).C(lendingCandleCollection).Remove(query)
+			if err != nil && err != mgo.ErrNotFound {
+				return fmt.Errorf("failed to delete lendingCandle. Err: %v", err)
+			}
+		case *lendingstate.LendingInsuranceRecord:
+			err = sc.DB(db.dbName).C(lendingInsuranceCollection).Remove(query)
+			if err != nil && err != mgo.ErrNotFound {
+				return fmt.Errorf("failed to delete lendingInsuranceRecord. Err: %v", err)
+			}
 
 		}
 	}
@@ -364,38 +495,75 @@ func (db *MongoDatabase) InitLendingBulk() {
 	db.topUpBulk = sc.DB(db.dbName).C(lendingTopUpCollection).Bulk()
 	db.repayBulk = sc.DB(db.dbName).C(lendingRepayCollection).Bulk()
 	db.recallBulk = sc.DB(db.dbName).C(lendingRecallCollection).Bulk()
+	db.candleBulk = sc.DB(db.dbName).C(lendingCandleCollection).Bulk()
+	db.insuranceBulk = sc.DB(db.dbName).C(lendingInsuranceCollection).Bulk()
 }
 
 func (db *MongoDatabase) CommitBulk() error {
-	if _, err := db.orderBulk.Run(); err != nil && !mgo.IsDup(err) {
-		return err
-	}
-	if _, err := db.tradeBulk.Run(); err != nil && !mgo.IsDup(err) {
-		return err
-	}
-	if _, err := db.epochPriceBulk.Run(); err != nil && !mgo.IsDup(err) {
-		return err
-	}
-	return nil
+	return db.CommitBulkContext(context.Background())
+}
+
+// CommitBulkContext runs the tomox bulks, giving up once the context is done.
+func (db *MongoDatabase) CommitBulkContext(ctx context.Context) error {
+	// the bulks are taken now, the next InitBulk replaces them while an abandoned run goes on
+	bulks := []*mgo.Bulk{db.orderBulk, db.tradeBulk, db.epochPriceBulk}
+	return runContext(ctx, func() error { return runBulks(bulks) })
 }
 
 func (db *MongoDatabase) CommitLendingBulk() error {
-	if _, err := db.lendingItemBulk.Run(); err != nil && !mgo.IsDup(err) {
-		return err
-	}
-	if _, err := db.lendingTradeBulk.Run(); err != nil && !mgo.IsDup(err) {
-		return err
+	return db.CommitLendingBulkContext(context.Background())
+}
+
+// CommitLendingBulkContext runs the tomox lending bulks, giving up once the context is done.
+func (db *MongoDatabase) CommitLendingBulkContext(ctx context.Context) error {
+	bulks := []*mgo.Bulk{db.lendingItemBulk, db.lendingTradeBulk, db.topUpBulk, db.repayBulk, db.recallBulk, db.candleBulk, db.insuranceBulk}
+	return runContext(ctx, func() error { return runBulks(bulks) })
+}
+
+// runBulks runs bulks in order, ignoring duplicated inserts.
+func runBulks(bulks []*mgo.Bulk) error {
+	for _, b := range bulks {
+		if _, err := b.Run(); err != nil && !mgo.IsDup(err) {
+			return err
+		}
 	}
-	if _, err := db.topUpBulk.Run(); err != nil && !mgo.IsDup(err) {
+	return nil
+}
+
+// GetObjectContext is GetObject giving up once the context is done.
+func (db *MongoDatabase) GetObjectContext(ctx context.Context, hash common.Hash, val interface{}) (interface{}, error) {
+	var result interface{}
+	if err := runContext(ctx, func() (err error) {
+		result, err = db.GetObject(hash, val)
 		return err
+	}); err != nil {
+		return nil, err
 	}
-	if _, err := db.repayBulk.Run(); err != nil && !mgo.IsDup(err) {
-		return err
+	return result, nil
+}
+
+// GetListItemByTxHashContext is GetListItemByTxHash giving up once the context is done.
+func (db *MongoDatabase) GetListItemByTxHashContext(ctx context.Context, txhash common.Hash, val interface{}) (interface{}, error) {
+	var result interface{}
+	if err := runContext(ctx, func() error {
+		result = db.GetListItemByTxHash(txhash, val)
+		return nil
+	}); err != nil {
+		return nil, err
 	}
-	if _, err := db.recallBulk.Run(); err != nil && !mgo.IsDup(err) {
-		return err
+	return result, nil
+}
+
+// GetListItemByHashesContext is GetListItemByHashes giving up once the context is done.
+func (db *MongoDatabase) GetListItemByHashesContext(ctx context.Context, hashes []string, val interface{}) (interface{}, error) {
+	var result interface{}
+	if err := runContext(ctx, func() error {
+		result = db.GetListItemByHashes(hashes, val)
+		return nil
+	}); err != nil {
+		return nil, err
 	}
-	return nil
+	return result, nil
 }
 
 func (db *MongoDatabase) Put(key []byte, val []byte) error {
@@ -461,6 +629,10 @@ func (db *MongoDatabase) DeleteItemByTxHash(txhash common.Hash, val interface{})
 		if err := sc.DB(db.dbName).C(lendingTradesCollection).Remove(query); err != nil && err != mgo.ErrNotFound {
 			log.Error("DeleteItemByTxHash: failed to delete lendingTrade", "txhash", txhash, "err", err)
 		}
+	case *lendingstate.LendingInsuranceRecord:
+		if _, err := sc.DB(db.dbName).C(lendingInsuranceCollection).RemoveAll(query); err != nil && err != mgo.ErrNotFound {
+			log.Error("DeleteItemByTxHash: failed to delete lendingInsuranceRecords", "txhash", txhash, "err", err)
+		}
 	default:
 		log.Error("DeleteItemByTxHash: Unknown object type", "txhash", txhash, "object", val)
 	}
@@ -578,6 +750,207 @@ func (db *MongoDatabase) GetListItemByHashes(hashes []string, val interface{}) i
 	return nil
 }
 
+// GetLendingListByTime returns the lending items or lending trades of a lending book created in [from, to),
+// its candles of the interval of val opened in [from, to), or its insurance fund records of the kind of val.
+func (db *MongoDatabase) GetLendingListByTime(lendingToken common.Address, term uint64, from, to time.Time, val interface{}) interface{} {
+	sc := db.Session.Copy()
+	defer sc.Close()
+
+	query := bson.M{
+		"lendingToken": lendingToken.Hex(),
+		"term":         strconv.FormatUint(term, 10),
+		"createdAt":    bson.M{"$gte": from, "$lt": to},
+	}
+
+	switch val.(type) {
+	case *lendingstate.LendingItem:
+		result := []*lendingstate.LendingItem{}
+		if err := sc.DB(db.dbName).C(lendingItemsCollection).Find(query).All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetLendingListByTime (lendingItems)", "err", err, "lendingToken", lendingToken.Hex(), "term", term)
+		}
+		return result
+	case *lendingstate.LendingTrade:
+		result := []*lendingstate.LendingTrade{}
+		if err := sc.DB(db.dbName).C(lendingTradesCollection).Find(query).All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetLendingListByTime (lendingTrades)", "err", err, "lendingToken", lendingToken.Hex(), "term", term)
+		}
+		return result
+	case *lendingstate.LendingCandle:
+		// candles of the interval of val, by open time
+		delete(query, "createdAt")
+		query["interval"] = val.(*lendingstate.LendingCandle).Interval
+		query["openTime"] = bson.M{"$gte": from, "$lt": to}
+		result := []*lendingstate.LendingCandle{}
+		if err := sc.DB(db.dbName).C(lendingCandleCollection).Find(query).Sort("openTime").All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetLendingListByTime (lendingCandles)", "err", err, "lendingToken", lendingToken.Hex(), "term", term)
+		}
+		return result
+	case *lendingstate.LendingInsuranceRecord:
+		// records of the kind of val
+		query["kind"] = val.(*lendingstate.LendingInsuranceRecord).Kind
+		result := []*lendingstate.LendingInsuranceRecord{}
+		if err := sc.DB(db.dbName).C(lendingInsuranceCollection).Find(query).Sort("createdAt").All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetLendingListByTime (lendingInsuranceRecords)", "err", err, "lendingToken", lendingToken.Hex(), "term", term)
+		}
+		return result
+	default:
+		log.Error("GetLendingListByTime: Unknown object type", "lendingToken", lendingToken.Hex(), "term", term, "object", val)
+	}
+	return nil
+}
+
+// GetLendingListByUser returns a page of the lending items placed by a user, or of the lending trades
+// in which the user is the borrower or the investor, the most recent first.
+// An empty lending token or a zero term matches every lending book, an empty status every status.
+// The relayer of val (see relayerSelector) restricts the records to the ones of a relayer.
+func (db *MongoDatabase) GetLendingListByUser(user common.Address, lendingToken common.Address, term uint64, status string, offset, limit int, val interface{}) interface{} {
+	sc := db.Session.Copy()
+	defer sc.Close()
+
+	query := bson.M{}
+	if lendingToken != (common.Address{}) {
+		query["lendingToken"] = lendingToken.Hex()
+	}
+	if term > 0 {
+		query["term"] = strconv.FormatUint(term, 10)
+	}
+	if status != "" {
+		query["status"] = status
+	}
+
+	relayer := relayerSelector(val)
+	switch val.(type) {
+	case *lendingstate.LendingItem:
+		query["userAddress"] = user.Hex()
+		if relayer != (common.Address{}) {
+			query["relayer"] = relayer.Hex()
+		}
+		result := []*lendingstate.LendingItem{}
+		if err := sc.DB(db.dbName).C(lendingItemsCollection).Find(query).Sort("-createdAt").Skip(offset).Limit(limit).All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetLendingListByUser (lendingItems)", "err", err, "user", user.Hex())
+		}
+		return result
+	case *lendingstate.LendingTrade:
+		query["$or"] = []bson.M{{"borrower": user.Hex()}, {"investor": user.Hex()}}
+		if relayer != (common.Address{}) {
+			query["$and"] = []bson.M{{"$or": []bson.M{{"borrowingRelayer": relayer.Hex()}, {"investingRelayer": relayer.Hex()}}}}
+		}
+		result := []*lendingstate.LendingTrade{}
+		if err := sc.DB(db.dbName).C(lendingTradesCollection).Find(query).Sort("-createdAt").Skip(offset).Limit(limit).All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetLendingListByUser (lendingTrades)", "err", err, "user", user.Hex())
+		}
+		return result
+	default:
+		log.Error("GetLendingListByUser: Unknown object type", "user", user.Hex(), "object", val)
+	}
+	return nil
+}
+
+// GetTradingListByUser returns a page of the orders placed by a user, or of the trades in which the
+// user is the taker or the maker, the most recent first.
+// An empty base or quote token matches every pair, an empty status every status.
+func (db *MongoDatabase) GetTradingListByUser(user common.Address, baseToken, quoteToken common.Address, status string, offset, limit int, val interface{}) interface{} {
+	sc := db.Session.Copy()
+	defer sc.Close()
+
+	query := bson.M{}
+	if baseToken != (common.Address{}) {
+		query["baseToken"] = baseToken.Hex()
+	}
+	if quoteToken != (common.Address{}) {
+		query["quoteToken"] = quoteToken.Hex()
+	}
+	if status != "" {
+		query["status"] = status
+	}
+
+	switch val.(type) {
+	case *tradingstate.OrderItem:
+		query["userAddress"] = user.Hex()
+		result := []*tradingstate.OrderItem{}
+		if err := sc.DB(db.dbName).C(ordersCollection).Find(query).Sort("-createdAt").Skip(offset).Limit(limit).All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetTradingListByUser (orders)", "err", err, "user", user.Hex())
+		}
+		return result
+	case *tradingstate.Trade:
+		query["$or"] = []bson.M{{"taker": user.Hex()}, {"maker": user.Hex()}}
+		result := []*tradingstate.Trade{}
+		if err := sc.DB(db.dbName).C(tradesCollection).Find(query).Sort("-createdAt").Skip(offset).Limit(limit).All(&result); err != nil && err != mgo.ErrNotFound {
+			log.Error("failed to GetTradingListByUser (trades)", "err", err, "user", user.Hex())
+		}
+		return result
+	default:
+		log.Error("GetTradingListByUser: Unknown object type", "user", user.Hex(), "object", val)
+	}
+	return nil
+}
+
+// GetTradesByPair returns a page of the trades of a pair ordered by creation time and hash, the
+// oldest first if ascending, the most recent first otherwise. The page starts after the cursor
+// trade, from the first trade in that order if the cursor is nil.
+func (db *MongoDatabase) GetTradesByPair(baseToken, quoteToken common.Address, cursor *tradingstate.Trade, limit int, ascending bool) ([]*tradingstate.Trade, error) {
+	sc := db.Session.Copy()
+	defer sc.Close()
+
+	query := bson.M{"baseToken": baseToken.Hex(), "quoteToken": quoteToken.Hex()}
+	sort := []string{"-createdAt", "-hash"}
+	op := "$lt"
+	if ascending {
+		sort, op = []string{"createdAt", "hash"}, "$gt"
+	}
+	if cursor != nil {
+		query["$or"] = []bson.M{
+			{"createdAt": bson.M{op: cursor.CreatedAt}},
+			{"createdAt": cursor.CreatedAt, "hash": bson.M{op: cursor.Hash.Hex()}},
+		}
+	}
+	result := []*tradingstate.Trade{}
+	if err := sc.DB(db.dbName).C(tradesCollection).Find(query).Sort(sort...).Limit(limit).All(&result); err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetTradesByExchange returns the trades created between from (included) and to (excluded) whose
+// taker or maker order was placed through an exchange, the oldest first.
+func (db *MongoDatabase) GetTradesByExchange(exchange common.Address, from, to time.Time) ([]*tradingstate.Trade, error) {
+	sc := db.Session.Copy()
+	defer sc.Close()
+
+	query := bson.M{
+		"$or":       []bson.M{{"takerExchange": exchange.Hex()}, {"makerExchange": exchange.Hex()}},
+		"createdAt": bson.M{"$gte": from, "$lt": to},
+	}
+	result := []*tradingstate.Trade{}
+	if err := sc.DB(db.dbName).C(tradesCollection).Find(query).Sort("createdAt", "hash").All(&result); err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PruneCollection removes the records of a collection with the given status (any status if empty)
+// last updated before the given time, and returns their number. Nothing is removed in dry-run mode.
+func (db *MongoDatabase) PruneCollection(collection string, status string, before time.Time, dryRun bool) (int, error) {
+	sc := db.Session.Copy()
+	defer sc.Close()
+
+	query := bson.M{"updatedAt": bson.M{"$lt": before}}
+	if status != "" {
+		query["status"] = status
+	}
+	if dryRun {
+		return sc.DB(db.dbName).C(collection).Find(query).Count()
+	}
+	info, err := sc.DB(db.dbName).C(collection).RemoveAll(query)
+	if err != nil {
+		return 0, err
+	}
+	if info.Removed > 0 {
+		db.cacheItems.Purge()
+	}
+	return info.Removed, nil
+}
+
 func (db *MongoDatabase) EnsureIndexes() error {
 	orderHashIndex := mgo.Index{
 		Key:        []string{"hash"},
@@ -609,6 +982,11 @@ func (db *MongoDatabase) EnsureIndexes() error {
 		Sparse:     true,
 		Name:       "index_trade_tx_hash",
 	}
+	tradePairIndex := mgo.Index{
+		Key:        []string{"baseToken", "quoteToken", "createdAt", "hash"},
+		Background: true,
+		Name:       "index_trade_pair",
+	}
 	lendingItemHashIndex := mgo.Index{
 		Key:        []string{"hash"},
 		Unique:     true,
@@ -639,6 +1017,22 @@ func (db *MongoDatabase) EnsureIndexes() error {
 		Sparse:     true,
 		Name:       "index_lending_trade_tx_hash",
 	}
+	lendingItemUserIndex := mgo.Index{
+		Key:        []string{"userAddress", "-createdAt"},
+		Background: true,
+		Name:       "index_lending_item_user",
+	}
+	lendingTradeBorrowerIndex := mgo.Index{
+		Key:        []string{"borrower", "-createdAt"},
+		Background: true,
+		Name:       "index_lending_trade_borrower",
+	}
+	lendingTradeInvestorIndex := mgo.Index{
+		Key:        []string{"investor", "-createdAt"},
+		Background: true,
+		Name:       "index_lending_trade_investor",
+	}
+
 	repayHashIndex := mgo.Index{
 		Key:        []string{"hash"},
 		DropDups:   true,
@@ -720,6 +1114,42 @@ func (db *MongoDatabase) EnsureIndexes() error {
 		Name:       "index_epoch_price",
 	}
 
+	lendingCandleHashIndex := mgo.Index{
+		Key:        []string{"hash"},
+		Unique:     true,
+		DropDups:   true,
+		Background: true,
+		Sparse:     true,
+		Name:       "index_lending_candle_hash",
+	}
+	lendingCandleBookIndex := mgo.Index{
+		Key:        []string{"lendingToken", "term", "interval", "openTime"},
+		Background: true,
+		Sparse:     true,
+		Name:       "index_lending_candle_book",
+	}
+
+	lendingInsuranceHashIndex := mgo.Index{
+		Key:        []string{"hash"},
+		Unique:     true,
+		DropDups:   true,
+		Background: true,
+		Sparse:     true,
+		Name:       "index_lending_insurance_hash",
+	}
+	lendingInsuranceTxHashIndex := mgo.Index{
+		Key:        []string{"txHash"},
+		Background: true,
+		Sparse:     true,
+		Name:       "index_lending_insurance_tx_hash",
+	}
+	lendingInsuranceBookIndex := mgo.Index{
+		Key:        []string{"lendingToken", "term", "kind", "createdAt"},
+		Background: true,
+		Sparse:     true,
+		Name:       "index_lending_insurance_book",
+	}
+
 	sc := db.Session.Copy()
 	defer sc.Close()
 
@@ -746,6 +1176,11 @@ func (db *MongoDatabase) EnsureIndexes() error {
 			return fmt.Errorf("failed to create index %s . Err: %v", tradeTxHashIndex.Name, err)
 		}
 	}
+	if !existingIndex(tradePairIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(tradesCollection).EnsureIndex(tradePairIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", tradePairIndex.Name, err)
+		}
+	}
 
 	indexes, _ = sc.DB(db.dbName).C(lendingItemsCollection).Indexes()
 	if !existingIndex(lendingItemHashIndex.Name, indexes) {
@@ -758,6 +1193,11 @@ func (db *MongoDatabase) EnsureIndexes() error {
 			return fmt.Errorf("failed to create index %s . Err: %v", lendingItemTxHashIndex.Name, err)
 		}
 	}
+	if !existingIndex(lendingItemUserIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(lendingItemsCollection).EnsureIndex(lendingItemUserIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", lendingItemUserIndex.Name, err)
+		}
+	}
 
 	indexes, _ = sc.DB(db.dbName).C(lendingTradesCollection).Indexes()
 	if !existingIndex(lendingTradeHashIndex.Name, indexes) {
@@ -770,6 +1210,16 @@ func (db *MongoDatabase) EnsureIndexes() error {
 			return fmt.Errorf("failed to create index %s . Err: %v", lendingTradeTxHashIndex.Name, err)
 		}
 	}
+	if !existingIndex(lendingTradeBorrowerIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(lendingTradesCollection).EnsureIndex(lendingTradeBorrowerIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", lendingTradeBorrowerIndex.Name, err)
+		}
+	}
+	if !existingIndex(lendingTradeInvestorIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(lendingTradesCollection).EnsureIndex(lendingTradeInvestorIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", lendingTradeInvestorIndex.Name, err)
+		}
+	}
 
 	indexes, _ = sc.DB(db.dbName).C(lendingRepayCollection).Indexes()
 	if !existingIndex(repayHashIndex.Name, indexes) {
@@ -830,11 +1280,36 @@ func (db *MongoDatabase) EnsureIndexes() error {
 			return fmt.Errorf("failed to create index %s . Err: %v", epochPriceIndex.Name, err)
 		}
 	}
+
+	indexes, _ = sc.DB(db.dbName).C(lendingCandleCollection).Indexes()
+	if !existingIndex(lendingCandleHashIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(lendingCandleCollection).EnsureIndex(lendingCandleHashIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", lendingCandleHashIndex.Name, err)
+		}
+	}
+	if !existingIndex(lendingCandleBookIndex.Name, indexes) {
+		if err := sc.DB(db.dbName).C(lendingCandleCollection).EnsureIndex(lendingCandleBookIndex); err != nil {
+			return fmt.Errorf("failed to create index %s . Err: %v", lendingCandleBookIndex.Name, err)
+		}
+	}
+
+	indexes, _ = sc.DB(db.dbName).C(lendingInsuranceCollection).Indexes()
+	for _, index := range []mgo.Index{lendingInsuranceHashIndex, lendingInsuranceTxHashIndex, lendingInsuranceBookIndex} {
+		if !existingIndex(index.Name, indexes) {
+			if err := sc.DB(db.dbName).C(lendingInsuranceCollection).EnsureIndex(index); err != nil {
+				return fmt.Errorf("failed to create index %s . Err: %v", index.Name, err)
+			}
+		}
+	}
 	return nil
 }
 
 func (db *MongoDatabase) Close() error {
-	return db.Close()
+	if db.replica != nil && db.replica != db {
+		db.replica.Session.Close()
+	}
+	db.Session.Close()
+	return nil
 }
 
 // HasAncient returns an error as we don't have a backing chain freezer.
@@ -0,0 +1,43 @@
+package tomoxDAO
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo"
+)
+
+func TestParseWriteConcern(t *testing.T) {
+	tests := []struct {
+		concern string
+		safe    *mgo.Safe
+		fail    bool
+	}{
+		{concern: "", safe: nil},
+		{concern: "majority", safe: &mgo.Safe{WMode: "majority"}},
+		{concern: "2", safe: &mgo.Safe{W: 2}},
+		{concern: "0", fail: true},
+		{concern: "all", fail: true},
+	}
+	for _, tt := range tests {
+		safe, err := parseWriteConcern(tt.concern)
+		if (err != nil) != tt.fail {
+			t.Fatalf("%q: error mismatch: have %v, want failure %v", tt.concern, err, tt.fail)
+		}
+		if tt.fail {
+			continue
+		}
+		if (safe == nil) != (tt.safe == nil) || (safe != nil && *safe != *tt.safe) {
+			t.Fatalf("%q: safety mode mismatch: have %+v, want %+v", tt.concern, safe, tt.safe)
+		}
+	}
+}
+
+func TestMongoOptionsRejected(t *testing.T) {
+	// invalid options are rejected before dialing
+	if _, err := NewMongoDatabase(nil, "tomodex", "localhost:1", MongoOptions{ReadPreference: "slave"}, 0); err == nil {
+		t.Fatal("invalid read preference accepted")
+	}
+	if _, err := NewMongoDatabase(nil, "tomodex", "localhost:1", MongoOptions{WriteConcern: "-1"}, 0); err == nil {
+		t.Fatal("invalid write concern accepted")
+	}
+}
@@ -0,0 +1,148 @@
+package tomoxDAO
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+const defaultRetentionInterval = time.Hour // Time between two pruning rounds of the SDK records
+
+var (
+	retentionPrunedCounter   = metrics.NewRegisteredCounter("tomox/retention/pruned", nil)
+	retentionPrunableCounter = metrics.NewRegisteredCounter("tomox/retention/prunable", nil)
+	retentionTimer           = metrics.NewRegisteredTimer("tomox/retention/duration", nil)
+)
+
+// retentionCollections are the collections of SDK records which can be pruned, with an empty
+// record of each.
+var retentionCollections = map[string]interface{}{
+	ordersCollection:        &tradingstate.OrderItem{},
+	tradesCollection:        &tradingstate.Trade{},
+	lendingItemsCollection:  &lendingstate.LendingItem{},
+	lendingTradesCollection: &lendingstate.LendingTrade{},
+	lendingTopUpCollection:  &lendingstate.LendingItem{Type: lendingstate.TopUp},
+	lendingRepayCollection:  &lendingstate.LendingItem{Type: lendingstate.Repay},
+	lendingRecallCollection: &lendingstate.LendingItem{Type: lendingstate.Recall},
+}
+
+// RetentionRule removes the records of a collection with the given status (any status if empty)
+// which haven't been updated for MaxAge.
+type RetentionRule struct {
+	Collection string
+	Status     string
+	MaxAge     time.Duration
+}
+
+func (r RetentionRule) String() string {
+	if r.Status == "" {
+		return fmt.Sprintf("%s=%v", r.Collection, r.MaxAge)
+	}
+	return fmt.Sprintf("%s:%s=%v", r.Collection, r.Status, r.MaxAge)
+}
+
+// ParseRetentionRules parses a comma separated list of rules collection[:status]=maxAge, e.g.
+// "orders:CANCELLED=720h,lending_items:CANCELLED=720h". The records without a rule are kept forever.
+func ParseRetentionRules(rules string) ([]RetentionRule, error) {
+	var result []RetentionRule
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid retention rule %q, expected collection[:status]=maxAge", rule)
+		}
+		maxAge, err := time.ParseDuration(parts[1])
+		if err != nil || maxAge <= 0 {
+			return nil, fmt.Errorf("invalid retention period in rule %q", rule)
+		}
+		collection, status := parts[0], ""
+		if i := strings.Index(collection, ":"); i >= 0 {
+			collection, status = collection[:i], collection[i+1:]
+		}
+		if _, ok := retentionCollections[collection]; !ok {
+			return nil, fmt.Errorf("unknown collection in retention rule %q", rule)
+		}
+		result = append(result, RetentionRule{Collection: collection, Status: status, MaxAge: maxAge})
+	}
+	return result, nil
+}
+
+// Pruner enforces retention rules over the SDK records of a database in the background.
+// In dry-run mode, the records which would be removed are only counted.
+type Pruner struct {
+	db       TomoXDAO
+	rules    []RetentionRule
+	dryRun   bool
+	interval time.Duration
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewPruner returns a pruner of the database, which runs once started.
+func NewPruner(db TomoXDAO, rules []RetentionRule, dryRun bool) *Pruner {
+	return &Pruner{
+		db:       db,
+		rules:    rules,
+		dryRun:   dryRun,
+		interval: defaultRetentionInterval,
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start prunes the database at every interval until the pruner is stopped.
+func (p *Pruner) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			p.Prune(time.Now())
+			select {
+			case <-ticker.C:
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the pruning rounds, waiting for the current one to end.
+func (p *Pruner) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// Prune applies the retention rules at the given time, and returns the number of records removed,
+// or which would be removed in dry-run mode.
+func (p *Pruner) Prune(now time.Time) int {
+	start := time.Now()
+	defer retentionTimer.UpdateSince(start)
+
+	total := 0
+	for _, rule := range p.rules {
+		count, err := p.db.PruneCollection(rule.Collection, rule.Status, now.Add(-rule.MaxAge), p.dryRun)
+		if err != nil {
+			log.Error("Failed to prune SDK records", "rule", rule, "err", err)
+			continue
+		}
+		total += count
+		if p.dryRun {
+			retentionPrunableCounter.Inc(int64(count))
+			log.Info("SDK records to prune (dry run)", "rule", rule, "count", count)
+		} else {
+			retentionPrunedCounter.Inc(int64(count))
+			log.Debug("Pruned SDK records", "rule", rule, "count", count)
+		}
+	}
+	return total
+}
@@ -0,0 +1,85 @@
+package tomoxDAO
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestParseRetentionRules(t *testing.T) {
+	rules, err := ParseRetentionRules("orders:CANCELLED=720h, lending_trades=8760h,")
+	if err != nil {
+		t.Fatalf("failed to parse rules: %v", err)
+	}
+	want := []RetentionRule{
+		{Collection: ordersCollection, Status: "CANCELLED", MaxAge: 720 * time.Hour},
+		{Collection: lendingTradesCollection, MaxAge: 8760 * time.Hour},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("wrong rules: have %v, want %v", rules, want)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("wrong rule %d: have %v, want %v", i, rules[i], want[i])
+		}
+	}
+	for _, invalid := range []string{"orders", "orders=forever", "orders=-1h", "balances=1h"} {
+		if _, err := ParseRetentionRules(invalid); err == nil {
+			t.Errorf("no error for invalid rules %q", invalid)
+		}
+	}
+}
+
+func TestPruner(t *testing.T) {
+	db, err := NewBadgerDatabase(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Unix(1600000000, 0).UTC()
+	db.InitLendingBulk()
+	for i, status := range []string{lendingstate.LendingStatusCancelled, lendingstate.LendingStatusCancelled, lendingstate.LendingStatusOpen} {
+		item := &lendingstate.LendingItem{
+			Quantity:     big.NewInt(100),
+			Interest:     big.NewInt(10),
+			Type:         lendingstate.Limit,
+			LendingToken: common.HexToAddress("0x10"),
+			Term:         86400,
+			Status:       status,
+			UserAddress:  common.HexToAddress("0x1"),
+			Hash:         common.BigToHash(big.NewInt(int64(i + 1))),
+			CreatedAt:    now.Add(-48 * time.Hour),
+			UpdatedAt:    now.Add(-48 * time.Hour),
+		}
+		if i == 1 {
+			item.UpdatedAt = now.Add(-time.Hour)
+		}
+		db.PutObject(item.Hash, item)
+	}
+	if err := db.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+
+	rules := []RetentionRule{{Collection: lendingItemsCollection, Status: lendingstate.LendingStatusCancelled, MaxAge: 24 * time.Hour}}
+	if count := NewPruner(db, rules, true).Prune(now); count != 1 {
+		t.Fatalf("wrong dry run count: have %d, want 1", count)
+	}
+	if found, _ := db.HasObject(common.BigToHash(big.NewInt(1)), &lendingstate.LendingItem{}); !found {
+		t.Fatalf("record removed in dry run")
+	}
+	if count := NewPruner(db, rules, false).Prune(now); count != 1 {
+		t.Fatalf("wrong pruned count: have %d, want 1", count)
+	}
+	for i, kept := range []bool{false, true, true} {
+		if found, _ := db.HasObject(common.BigToHash(big.NewInt(int64(i+1))), &lendingstate.LendingItem{}); found != kept {
+			t.Errorf("record %d: found %v, want %v", i+1, found, kept)
+		}
+	}
+	if items := db.GetLendingListByUser(common.HexToAddress("0x1"), common.Address{}, 0, "", 0, 10, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem); len(items) != 2 {
+		t.Fatalf("indexes of the pruned record not removed: %v", items)
+	}
+}
@@ -0,0 +1,245 @@
+package tomoxDAO
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/log"
+)
+
+const (
+	sinkQueueSize    = 4096             // Maximum number of events waiting to be published
+	sinkDialTimeout  = 5 * time.Second  // Timeout to connect to the event sink
+	sinkWriteTimeout = 10 * time.Second // Timeout to publish an event
+)
+
+var errUnknownSink = errors.New("unknown event sink scheme, expected nats:// or kafka+http(s)://")
+
+// EventSink publishes the records written by SDK nodes to a message broker, so that consumers
+// can follow the order and lending updates without polling the database.
+type EventSink interface {
+	Publish(topic string, key []byte, value []byte) error
+	Close() error
+}
+
+// NewEventSink returns the event sink of the given url:
+//   - nats://host:port publishes to the subjects of a NATS server
+//   - kafka+http://host:port publishes to the topics of a Kafka REST proxy
+//
+// The events are published in the background, see NewAsyncEventSink.
+func NewEventSink(rawurl string) (EventSink, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "nats":
+		sink, err := newNatsSink(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return NewAsyncEventSink(sink), nil
+	case "kafka+http", "kafka+https":
+		u.Scheme = strings.TrimPrefix(u.Scheme, "kafka+")
+		return NewAsyncEventSink(newKafkaRestSink(u.String())), nil
+	}
+	return nil, errUnknownSink
+}
+
+type sinkEvent struct {
+	topic string
+	key   []byte
+	value []byte
+}
+
+// asyncEventSink publishes events from a queue, so that a slow broker doesn't hold the block
+// import. Events are dropped when the queue is full.
+type asyncEventSink struct {
+	sink  EventSink
+	queue chan sinkEvent
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewAsyncEventSink wraps a sink to publish its events in the background.
+func NewAsyncEventSink(sink EventSink) EventSink {
+	s := &asyncEventSink{
+		sink:  sink,
+		queue: make(chan sinkEvent, sinkQueueSize),
+		quit:  make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+func (s *asyncEventSink) loop() {
+	defer s.wg.Done()
+	for {
+		select {
+		case ev := <-s.queue:
+			if err := s.sink.Publish(ev.topic, ev.key, ev.value); err != nil {
+				log.Warn("Failed to publish event", "topic", ev.topic, "err", err)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *asyncEventSink) Publish(topic string, key []byte, value []byte) error {
+	select {
+	case s.queue <- sinkEvent{topic: topic, key: key, value: value}:
+		return nil
+	default:
+		return fmt.Errorf("event sink queue full, event dropped: %s", topic)
+	}
+}
+
+func (s *asyncEventSink) Close() error {
+	close(s.quit)
+	s.wg.Wait()
+	return s.sink.Close()
+}
+
+// natsSink publishes events with the text protocol of NATS. The key of an event is not sent.
+type natsSink struct {
+	addr string
+	lock sync.Mutex
+	conn net.Conn
+}
+
+func newNatsSink(addr string) (*natsSink, error) {
+	s := &natsSink{addr: addr}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *natsSink) connect() error {
+	conn, err := net.DialTimeout("tcp", s.addr, sinkDialTimeout)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(sinkDialTimeout))
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO") {
+		conn.Close()
+		return fmt.Errorf("invalid NATS server greeting: %q, %v", info, err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	if _, err := conn.Write([]byte("CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"tomox\"}\r\n")); err != nil {
+		conn.Close()
+		return err
+	}
+	s.conn = conn
+	go s.readLoop(conn, reader)
+	return nil
+}
+
+// readLoop answers the keep-alive pings of the server.
+func (s *natsSink) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			s.lock.Lock()
+			conn.SetWriteDeadline(time.Now().Add(sinkWriteTimeout))
+			conn.Write([]byte("PONG\r\n"))
+			s.lock.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			log.Warn("NATS server error", "err", strings.TrimSpace(line))
+		}
+	}
+}
+
+func (s *natsSink) Publish(topic string, key []byte, value []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	msg := append([]byte(fmt.Sprintf("PUB %s %d\r\n", topic, len(value))), value...)
+	msg = append(msg, '\r', '\n')
+	if s.conn != nil {
+		s.conn.SetWriteDeadline(time.Now().Add(sinkWriteTimeout))
+		if _, err := s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	// reconnect once after a broken connection
+	if err := s.connect(); err != nil {
+		return err
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(sinkWriteTimeout))
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+func (s *natsSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// kafkaRestSink publishes events to the topics of a Kafka REST proxy (v2 API), keyed by the
+// event key so that the updates of a record stay ordered in a partition.
+type kafkaRestSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newKafkaRestSink(endpoint string) *kafkaRestSink {
+	return &kafkaRestSink{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: sinkWriteTimeout},
+	}
+}
+
+type kafkaRestRecords struct {
+	Records []kafkaRestRecord `json:"records"`
+}
+
+type kafkaRestRecord struct {
+	Key   []byte `json:"key"`   // base64 encoded
+	Value []byte `json:"value"` // base64 encoded
+}
+
+func (s *kafkaRestSink) Publish(topic string, key []byte, value []byte) error {
+	body, err := json.Marshal(kafkaRestRecords{Records: []kafkaRestRecord{{Key: key, Value: value}}})
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.endpoint+"/topics/"+url.PathEscape(topic), "application/vnd.kafka.binary.v2+json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kafka rest proxy returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *kafkaRestSink) Close() error {
+	return nil
+}
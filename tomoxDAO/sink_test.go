@@ -0,0 +1,86 @@
+package tomoxDAO
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNatsSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {}\r\n"))
+		reader := bufio.NewReader(conn)
+		if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, "CONNECT") {
+			received <- "unexpected " + line
+			return
+		}
+		pub, _ := reader.ReadString('\n')
+		payload, _ := reader.ReadString('\n')
+		received <- pub + payload
+	}()
+
+	sink, err := NewEventSink("nats://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer sink.Close()
+	if err := sink.Publish("tomox.lendingTrades", []byte{1}, []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	select {
+	case msg := <-received:
+		if want := "PUB tomox.lendingTrades 7\r\n{\"a\":1}\r\n"; msg != want {
+			t.Fatalf("wrong message: have %q, want %q", msg, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestKafkaRestSink(t *testing.T) {
+	received := make(chan kafkaRestRecords, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/tomox.lendingItems" || r.Header.Get("Content-Type") != "application/vnd.kafka.binary.v2+json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var records kafkaRestRecords
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &records)
+		received <- records
+	}))
+	defer server.Close()
+
+	sink := newKafkaRestSink(server.URL)
+	if err := sink.Publish("tomox.lendingItems", []byte{1}, []byte("value")); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	records := <-received
+	if len(records.Records) != 1 || string(records.Records[0].Value) != "value" || string(records.Records[0].Key) != "\x01" {
+		t.Fatalf("wrong records: %v", records)
+	}
+	if err := sink.Publish("unknown", nil, nil); err == nil {
+		t.Fatal("no error on a failed request")
+	}
+	if _, err := NewEventSink("amqp://localhost"); err != errUnknownSink {
+		t.Fatalf("wrong error: have %v, want %v", err, errUnknownSink)
+	}
+}
@@ -0,0 +1,855 @@
+package tomoxDAO
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// SQLDatabase stores the records of SDK nodes in a SQL database (PostgreSQL by default) with
+// a table per mongodb collection. The records are kept as JSON documents, along with the columns
+// they are queried by.
+type SQLDatabase struct {
+	db          *sql.DB
+	dollar      bool // whether the driver uses $n placeholders instead of ?
+	emptyKey    []byte
+	cacheItems  *lru.Cache // Cache for reading
+	lock        sync.Mutex
+	bulk        []sqlOp // pending writes of InitBulk
+	lendingBulk []sqlOp // pending writes of InitLendingBulk
+}
+
+// sqlRecord holds the columns of a record.
+type sqlRecord struct {
+	hash         string
+	txHash       string
+	userAddress  string
+	borrower     string
+	investor     string
+	lendingToken string
+	term         int64
+	status       string
+	createdAt    time.Time
+	updatedAt    time.Time
+	data         []byte
+}
+
+// sqlOp is a pending write of a bulk. Inserts don't overwrite an existing record, as duplicates
+// are ignored by the mongodb bulks.
+type sqlOp struct {
+	table  string
+	record sqlRecord
+	upsert bool
+}
+
+const sqlColumns = "hash, tx_hash, user_address, borrower, investor, lending_token, term, status, created_at, updated_at, data"
+
+// NewSQLDatabase opens the SQL database of the given driver and data source, and creates its tables.
+func NewSQLDatabase(driver string, dataSource string, cacheLimit int) (*SQLDatabase, error) {
+	sqlDB, err := sql.Open(driver, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	itemCacheLimit := defaultCacheLimit
+	if cacheLimit > 0 {
+		itemCacheLimit = cacheLimit
+	}
+	cacheItems, _ := lru.New(itemCacheLimit)
+
+	db := &SQLDatabase{
+		db:         sqlDB,
+		dollar:     driver == "postgres" || driver == "pgx",
+		emptyKey:   EmptyKey(),
+		cacheItems: cacheItems,
+	}
+	if err := db.EnsureTables(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// EnsureTables creates the tables and indexes of the records.
+func (db *SQLDatabase) EnsureTables() error {
+	tables := []string{ordersCollection, tradesCollection, epochPriceCollection, lendingItemsCollection, lendingTradesCollection, lendingTopUpCollection, lendingRepayCollection, lendingRecallCollection, lendingCandleCollection, lendingInsuranceCollection}
+	for _, table := range tables {
+		statements := []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	hash VARCHAR(66) PRIMARY KEY,
+	tx_hash VARCHAR(66) NOT NULL,
+	user_address VARCHAR(42) NOT NULL,
+	borrower VARCHAR(42) NOT NULL,
+	investor VARCHAR(42) NOT NULL,
+	lending_token VARCHAR(42) NOT NULL,
+	term BIGINT NOT NULL,
+	status VARCHAR(32) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	data TEXT NOT NULL)`, table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS index_%s_tx_hash ON %s (tx_hash)", table, table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS index_%s_user ON %s (user_address, created_at)", table, table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS index_%s_borrower ON %s (borrower, created_at)", table, table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS index_%s_investor ON %s (investor, created_at)", table, table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS index_%s_book ON %s (lending_token, term, created_at)", table, table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS index_%s_updated ON %s (status, updated_at)", table, table),
+		}
+		for _, statement := range statements {
+			if _, err := db.db.Exec(statement); err != nil {
+				return fmt.Errorf("failed to create table %s . Err: %v", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rebind replaces the ? placeholders of a query by the ones of the driver.
+func (db *SQLDatabase) rebind(query string) string {
+	if !db.dollar {
+		return query
+	}
+	var (
+		buf bytes.Buffer
+		n   int
+	)
+	for _, c := range query {
+		if c == '?' {
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// lendingItemTable returns the table of a lending item, by type.
+func lendingItemTable(itemType string) string {
+	switch itemType {
+	case lendingstate.Repay:
+		return lendingRepayCollection
+	case lendingstate.TopUp:
+		return lendingTopUpCollection
+	case lendingstate.Recall:
+		return lendingRecallCollection
+	default:
+		return lendingItemsCollection
+	}
+}
+
+// relayerSelector returns the relayer selected by val in a user history query: the relayer of a
+// lending item, or either relayer of a lending trade. An empty relayer selects every relayer.
+func relayerSelector(val interface{}) common.Address {
+	switch val := val.(type) {
+	case *lendingstate.LendingItem:
+		return val.Relayer
+	case *lendingstate.LendingTrade:
+		if val.BorrowingRelayer != (common.Address{}) {
+			return val.BorrowingRelayer
+		}
+		return val.InvestingRelayer
+	}
+	return common.Address{}
+}
+
+// jsonField returns the LIKE pattern matching an address field of a JSON document.
+func jsonField(name string, address common.Address) string {
+	value, _ := json.Marshal(address)
+	return "%\"" + name + "\":" + string(value) + "%"
+}
+
+// sqlTable returns the table of a type of record.
+func sqlTable(val interface{}) (string, bool) {
+	switch val := val.(type) {
+	case *tradingstate.OrderItem:
+		return ordersCollection, true
+	case *tradingstate.Trade:
+		return tradesCollection, true
+	case *tradingstate.EpochPriceItem:
+		return epochPriceCollection, true
+	case *lendingstate.LendingItem:
+		return lendingItemTable(val.Type), true
+	case *lendingstate.LendingTrade:
+		return lendingTradesCollection, true
+	case *lendingstate.LendingCandle:
+		return lendingCandleCollection, true
+	case *lendingstate.LendingInsuranceRecord:
+		return lendingInsuranceCollection, true
+	}
+	return "", false
+}
+
+// newSQLRecord returns the columns of a record.
+func newSQLRecord(val interface{}) (sqlRecord, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return sqlRecord{}, err
+	}
+	record := sqlRecord{data: data}
+	switch val := val.(type) {
+	case *tradingstate.OrderItem:
+		record.hash, record.txHash, record.userAddress = val.Hash.Hex(), val.TxHash.Hex(), val.UserAddress.Hex()
+		record.status, record.createdAt, record.updatedAt = val.Status, val.CreatedAt, val.UpdatedAt
+	case *tradingstate.Trade:
+		record.hash, record.txHash, record.createdAt, record.updatedAt = val.Hash.Hex(), val.TxHash.Hex(), val.CreatedAt, val.UpdatedAt
+		record.status = val.Status
+	case *tradingstate.EpochPriceItem:
+		record.hash = val.Hash.Hex()
+	case *lendingstate.LendingItem:
+		record.hash, record.txHash, record.userAddress = val.Hash.Hex(), val.TxHash.Hex(), val.UserAddress.Hex()
+		record.lendingToken, record.term = val.LendingToken.Hex(), int64(val.Term)
+		record.status, record.createdAt, record.updatedAt = val.Status, val.CreatedAt, val.UpdatedAt
+	case *lendingstate.LendingTrade:
+		record.hash, record.txHash, record.borrower, record.investor = val.Hash.Hex(), val.TxHash.Hex(), val.Borrower.Hex(), val.Investor.Hex()
+		record.lendingToken, record.term = val.LendingToken.Hex(), int64(val.Term)
+		record.status, record.createdAt, record.updatedAt = val.Status, val.CreatedAt, val.UpdatedAt
+	case *lendingstate.LendingCandle:
+		// the status column holds the interval of a candle, the created_at column its open time
+		record.hash, record.lendingToken, record.term = val.Hash.Hex(), val.LendingToken.Hex(), int64(val.Term)
+		record.status, record.createdAt, record.updatedAt = val.Interval, val.OpenTime, val.UpdatedAt
+	case *lendingstate.LendingInsuranceRecord:
+		// the status column holds the kind of a record
+		record.hash, record.txHash, record.investor = val.Hash.Hex(), val.TxHash.Hex(), val.Investor.Hex()
+		record.lendingToken, record.term = val.LendingToken.Hex(), int64(val.Term)
+		record.status, record.createdAt, record.updatedAt = val.Kind, val.CreatedAt, val.UpdatedAt
+	}
+	record.createdAt, record.updatedAt = record.createdAt.UTC(), record.updatedAt.UTC()
+	return record, nil
+}
+
+func (db *SQLDatabase) IsEmptyKey(key []byte) bool {
+	return key == nil || len(key) == 0 || bytes.Equal(key, db.emptyKey)
+}
+
+func (db *SQLDatabase) getCacheKey(key []byte) string {
+	return hex.EncodeToString(key)
+}
+
+func (db *SQLDatabase) HasObject(hash common.Hash, val interface{}) (bool, error) {
+	if db.IsEmptyKey(hash.Bytes()) {
+		return false, nil
+	}
+	if db.cacheItems.Contains(db.getCacheKey(hash.Bytes())) {
+		return true, nil
+	}
+	table, ok := sqlTable(val)
+	if !ok {
+		return false, nil
+	}
+	var found int
+	err := db.db.QueryRow(db.rebind("SELECT 1 FROM "+table+" WHERE hash = ?"), hash.Hex()).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// decodeRecords decodes JSON documents into a slice of the type of val.
+func decodeRecords(documents [][]byte, val interface{}) (interface{}, error) {
+	switch val.(type) {
+	case *tradingstate.OrderItem:
+		result := []*tradingstate.OrderItem{}
+		for _, data := range documents {
+			item := &tradingstate.OrderItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+		}
+		return result, nil
+	case *tradingstate.Trade:
+		result := []*tradingstate.Trade{}
+		for _, data := range documents {
+			trade := &tradingstate.Trade{}
+			if err := json.Unmarshal(data, trade); err != nil {
+				return nil, err
+			}
+			result = append(result, trade)
+		}
+		return result, nil
+	case *tradingstate.EpochPriceItem:
+		result := []*tradingstate.EpochPriceItem{}
+		for _, data := range documents {
+			item := &tradingstate.EpochPriceItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+		}
+		return result, nil
+	case *lendingstate.LendingItem:
+		result := []*lendingstate.LendingItem{}
+		for _, data := range documents {
+			item := &lendingstate.LendingItem{}
+			if err := json.Unmarshal(data, item); err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+		}
+		return result, nil
+	case *lendingstate.LendingTrade:
+		result := []*lendingstate.LendingTrade{}
+		for _, data := range documents {
+			trade := &lendingstate.LendingTrade{}
+			if err := json.Unmarshal(data, trade); err != nil {
+				return nil, err
+			}
+			result = append(result, trade)
+		}
+		return result, nil
+	case *lendingstate.LendingCandle:
+		result := []*lendingstate.LendingCandle{}
+		for _, data := range documents {
+			candle := &lendingstate.LendingCandle{}
+			if err := json.Unmarshal(data, candle); err != nil {
+				return nil, err
+			}
+			result = append(result, candle)
+		}
+		return result, nil
+	case *lendingstate.LendingInsuranceRecord:
+		result := []*lendingstate.LendingInsuranceRecord{}
+		for _, data := range documents {
+			record := &lendingstate.LendingInsuranceRecord{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return nil, err
+			}
+			result = append(result, record)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unknown type of object %T", val)
+}
+
+// queryRecords returns the records of the type of val selected by the query, as a slice of this type.
+func (db *SQLDatabase) queryRecords(ctx context.Context, val interface{}, query string, args ...interface{}) (interface{}, error) {
+	rows, err := db.db.QueryContext(ctx, db.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents [][]byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		documents = append(documents, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return decodeRecords(documents, val)
+}
+
+func (db *SQLDatabase) GetObject(hash common.Hash, val interface{}) (interface{}, error) {
+	return db.GetObjectContext(context.Background(), hash, val)
+}
+
+func (db *SQLDatabase) GetObjectContext(ctx context.Context, hash common.Hash, val interface{}) (interface{}, error) {
+	if db.IsEmptyKey(hash.Bytes()) {
+		return nil, nil
+	}
+	cacheKey := db.getCacheKey(hash.Bytes())
+	if cached, ok := db.cacheItems.Get(cacheKey); ok {
+		return cached, nil
+	}
+	table, ok := sqlTable(val)
+	if !ok {
+		return nil, nil
+	}
+	result, err := db.queryRecords(ctx, val, "SELECT data FROM "+table+" WHERE hash = ?", hash.Hex())
+	if err != nil {
+		return nil, err
+	}
+	var object interface{}
+	switch result := result.(type) {
+	case []*tradingstate.OrderItem:
+		if len(result) > 0 {
+			object = result[0]
+		}
+	case []*tradingstate.Trade:
+		if len(result) > 0 {
+			object = result[0]
+		}
+	case []*tradingstate.EpochPriceItem:
+		if len(result) > 0 {
+			object = result[0]
+		}
+	case []*lendingstate.LendingItem:
+		if len(result) > 0 {
+			object = result[0]
+		}
+	case []*lendingstate.LendingTrade:
+		if len(result) > 0 {
+			object = result[0]
+		}
+	case []*lendingstate.LendingCandle:
+		if len(result) > 0 {
+			object = result[0]
+		}
+	case []*lendingstate.LendingInsuranceRecord:
+		if len(result) > 0 {
+			object = result[0]
+		}
+	}
+	if object == nil {
+		return nil, sql.ErrNoRows
+	}
+	db.cacheItems.Add(cacheKey, object)
+	return object, nil
+}
+
+// PutObject adds the record to the pending writes of the trading or lending bulk, like the
+// mongodb database.
+func (db *SQLDatabase) PutObject(hash common.Hash, val interface{}) error {
+	db.cacheItems.Add(db.getCacheKey(hash.Bytes()), val)
+
+	table, ok := sqlTable(val)
+	if !ok {
+		log.Error("PutObject: unknown type of object", "val", val)
+		return nil
+	}
+	upsert := true
+	lending := false
+	switch val := val.(type) {
+	case *tradingstate.Trade:
+		upsert = false
+	case *tradingstate.OrderItem:
+		upsert = val.Status != tradingstate.OrderStatusOpen
+	case *lendingstate.LendingTrade, *lendingstate.LendingCandle, *lendingstate.LendingInsuranceRecord:
+		lending = true
+	case *lendingstate.LendingItem:
+		lending = true
+		switch val.Type {
+		case lendingstate.Repay, lendingstate.TopUp, lendingstate.Recall:
+			if val.Status != lendingstate.LendingStatusReject {
+				val.Status = val.Type
+			}
+			upsert = false
+		default:
+			upsert = val.Status != lendingstate.LendingStatusOpen
+		}
+	}
+	record, err := newSQLRecord(val)
+	if err != nil {
+		return err
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if lending {
+		db.lendingBulk = append(db.lendingBulk, sqlOp{table: table, record: record, upsert: upsert})
+	} else {
+		db.bulk = append(db.bulk, sqlOp{table: table, record: record, upsert: upsert})
+	}
+	return nil
+}
+
+func (db *SQLDatabase) DeleteObject(hash common.Hash, val interface{}) error {
+	db.cacheItems.Remove(db.getCacheKey(hash.Bytes()))
+	table, ok := sqlTable(val)
+	if !ok {
+		return nil
+	}
+	if _, err := db.db.Exec(db.rebind("DELETE FROM "+table+" WHERE hash = ?"), hash.Hex()); err != nil {
+		return fmt.Errorf("failed to delete %s. Err: %v", table, err)
+	}
+	return nil
+}
+
+func (db *SQLDatabase) InitBulk() {
+	db.lock.Lock()
+	db.bulk = nil
+	db.lock.Unlock()
+}
+
+func (db *SQLDatabase) InitLendingBulk() {
+	db.lock.Lock()
+	db.lendingBulk = nil
+	db.lock.Unlock()
+}
+
+// commit writes the pending writes of a bulk in a transaction.
+func (db *SQLDatabase) commit(ctx context.Context, ops []sqlOp) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		r := op.record
+		if op.upsert {
+			if _, err := tx.ExecContext(ctx, db.rebind("DELETE FROM "+op.table+" WHERE hash = ?"), r.hash); err != nil {
+				tx.Rollback()
+				return err
+			}
+		} else {
+			var found int
+			err := tx.QueryRowContext(ctx, db.rebind("SELECT 1 FROM "+op.table+" WHERE hash = ?"), r.hash).Scan(&found)
+			if err == nil {
+				continue
+			}
+			if err != sql.ErrNoRows {
+				tx.Rollback()
+				return err
+			}
+		}
+		query := "INSERT INTO " + op.table + " (" + sqlColumns + ") VALUES (" + placeholders(11) + ")"
+		if _, err := tx.ExecContext(ctx, db.rebind(query), r.hash, r.txHash, r.userAddress, r.borrower, r.investor, r.lendingToken, r.term, r.status, r.createdAt, r.updatedAt, string(r.data)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *SQLDatabase) CommitBulk() error {
+	return db.CommitBulkContext(context.Background())
+}
+
+func (db *SQLDatabase) CommitBulkContext(ctx context.Context) error {
+	db.lock.Lock()
+	ops := db.bulk
+	db.bulk = nil
+	db.lock.Unlock()
+	return db.commit(ctx, ops)
+}
+
+func (db *SQLDatabase) CommitLendingBulk() error {
+	return db.CommitLendingBulkContext(context.Background())
+}
+
+func (db *SQLDatabase) CommitLendingBulkContext(ctx context.Context) error {
+	db.lock.Lock()
+	ops := db.lendingBulk
+	db.lendingBulk = nil
+	db.lock.Unlock()
+	return db.commit(ctx, ops)
+}
+
+func (db *SQLDatabase) Put(key []byte, val []byte) error {
+	// for levelDB only
+	return nil
+}
+
+func (db *SQLDatabase) Delete(key []byte) error {
+	// for levelDB only
+	return nil
+}
+
+func (db *SQLDatabase) Has(key []byte) (bool, error) {
+	// for levelDB only
+	return false, nil
+}
+
+func (db *SQLDatabase) Get(key []byte) ([]byte, error) {
+	// for levelDB only
+	return nil, nil
+}
+
+func (db *SQLDatabase) DeleteItemByTxHash(txhash common.Hash, val interface{}) {
+	table, ok := sqlTable(val)
+	if !ok {
+		log.Error("DeleteItemByTxHash: Unknown object type", "txhash", txhash, "object", val)
+		return
+	}
+	if _, err := db.db.Exec(db.rebind("DELETE FROM "+table+" WHERE tx_hash = ?"), txhash.Hex()); err != nil {
+		log.Error("DeleteItemByTxHash: failed to delete records", "table", table, "txhash", txhash, "err", err)
+	}
+}
+
+func (db *SQLDatabase) GetListItemByTxHash(txhash common.Hash, val interface{}) interface{} {
+	result, err := db.GetListItemByTxHashContext(context.Background(), txhash, val)
+	if err != nil {
+		log.Error("failed to GetListItemByTxHash", "err", err, "txhash", txhash)
+	}
+	return result
+}
+
+func (db *SQLDatabase) GetListItemByTxHashContext(ctx context.Context, txhash common.Hash, val interface{}) (interface{}, error) {
+	table, ok := sqlTable(val)
+	if !ok {
+		log.Error("GetListItemByTxHash: Unknown object type", "txhash", txhash, "object", val)
+		return nil, nil
+	}
+	return db.queryRecords(ctx, val, "SELECT data FROM "+table+" WHERE tx_hash = ?", txhash.Hex())
+}
+
+func (db *SQLDatabase) GetListItemByHashes(hashes []string, val interface{}) interface{} {
+	result, err := db.GetListItemByHashesContext(context.Background(), hashes, val)
+	if err != nil {
+		log.Error("failed to GetListItemByHashes", "err", err, "hashes", hashes)
+	}
+	return result
+}
+
+func (db *SQLDatabase) GetListItemByHashesContext(ctx context.Context, hashes []string, val interface{}) (interface{}, error) {
+	table, ok := sqlTable(val)
+	if !ok {
+		log.Error("GetListItemByHashes: Unknown object type", "hashes", hashes, "object", val)
+		return nil, nil
+	}
+	if len(hashes) == 0 {
+		return decodeRecords(nil, val)
+	}
+	args := make([]interface{}, len(hashes))
+	for i, hash := range hashes {
+		args[i] = hash
+	}
+	return db.queryRecords(ctx, val, "SELECT data FROM "+table+" WHERE hash IN ("+placeholders(len(hashes))+")", args...)
+}
+
+// GetLendingListByTime returns the lending items or lending trades of a lending book created in [from, to),
+// its candles of the interval of val opened in [from, to), or its insurance fund records of the kind of val.
+func (db *SQLDatabase) GetLendingListByTime(lendingToken common.Address, term uint64, from, to time.Time, val interface{}) interface{} {
+	var result interface{}
+	var err error
+	table, _ := sqlTable(val)
+	switch val := val.(type) {
+	case *lendingstate.LendingItem, *lendingstate.LendingTrade:
+		result, err = db.queryRecords(context.Background(), val, "SELECT data FROM "+table+" WHERE lending_token = ? AND term = ? AND created_at >= ? AND created_at < ?",
+			lendingToken.Hex(), int64(term), from.UTC(), to.UTC())
+	case *lendingstate.LendingCandle:
+		result, err = db.queryRecords(context.Background(), val, "SELECT data FROM "+table+" WHERE lending_token = ? AND term = ? AND status = ? AND created_at >= ? AND created_at < ? ORDER BY created_at",
+			lendingToken.Hex(), int64(term), val.Interval, from.UTC(), to.UTC())
+	case *lendingstate.LendingInsuranceRecord:
+		result, err = db.queryRecords(context.Background(), val, "SELECT data FROM "+table+" WHERE lending_token = ? AND term = ? AND status = ? AND created_at >= ? AND created_at < ? ORDER BY created_at",
+			lendingToken.Hex(), int64(term), val.Kind, from.UTC(), to.UTC())
+	default:
+		log.Error("GetLendingListByTime: Unknown object type", "lendingToken", lendingToken.Hex(), "term", term, "object", val)
+		return nil
+	}
+	if err != nil {
+		log.Error("failed to GetLendingListByTime", "table", table, "err", err, "lendingToken", lendingToken.Hex(), "term", term)
+	}
+	return result
+}
+
+// GetLendingListByUser returns a page of the lending items placed by a user, or of the lending trades
+// in which the user is the borrower or the investor, the most recent first.
+// An empty lending token or a zero term matches every lending book, an empty status every status.
+// The relayer of val (see relayerSelector) restricts the records to the ones of a relayer.
+func (db *SQLDatabase) GetLendingListByUser(user common.Address, lendingToken common.Address, term uint64, status string, offset, limit int, val interface{}) interface{} {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	switch val.(type) {
+	case *lendingstate.LendingItem:
+		conditions, args = append(conditions, "user_address = ?"), append(args, user.Hex())
+	case *lendingstate.LendingTrade:
+		conditions, args = append(conditions, "(borrower = ? OR investor = ?)"), append(args, user.Hex(), user.Hex())
+	default:
+		log.Error("GetLendingListByUser: Unknown object type", "user", user.Hex(), "object", val)
+		return nil
+	}
+	if lendingToken != (common.Address{}) {
+		conditions, args = append(conditions, "lending_token = ?"), append(args, lendingToken.Hex())
+	}
+	if term > 0 {
+		conditions, args = append(conditions, "term = ?"), append(args, int64(term))
+	}
+	if status != "" {
+		conditions, args = append(conditions, "status = ?"), append(args, status)
+	}
+	// the relayers have no column, they are matched in the JSON document
+	if relayer := relayerSelector(val); relayer != (common.Address{}) {
+		switch val.(type) {
+		case *lendingstate.LendingItem:
+			conditions, args = append(conditions, "data LIKE ?"), append(args, jsonField("relayer", relayer))
+		case *lendingstate.LendingTrade:
+			conditions, args = append(conditions, "(data LIKE ? OR data LIKE ?)"), append(args, jsonField("borrowingRelayer", relayer), jsonField("investingRelayer", relayer))
+		}
+	}
+	table, _ := sqlTable(val)
+	query := "SELECT data FROM " + table + " WHERE " + strings.Join(conditions, " AND ") + " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	result, err := db.queryRecords(context.Background(), val, query, append(args, limit, offset)...)
+	if err != nil {
+		log.Error("failed to GetLendingListByUser", "table", table, "err", err, "user", user.Hex())
+	}
+	return result
+}
+
+// GetTradingListByUser returns a page of the orders placed by a user, or of the trades in which the
+// user is the taker or the maker, the most recent first.
+// An empty base or quote token matches every pair, an empty status every status.
+func (db *SQLDatabase) GetTradingListByUser(user common.Address, baseToken, quoteToken common.Address, status string, offset, limit int, val interface{}) interface{} {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	// the takers, makers and tokens have no column, they are matched in the JSON document
+	switch val.(type) {
+	case *tradingstate.OrderItem:
+		conditions, args = append(conditions, "user_address = ?"), append(args, user.Hex())
+	case *tradingstate.Trade:
+		conditions, args = append(conditions, "(data LIKE ? OR data LIKE ?)"), append(args, jsonField("taker", user), jsonField("maker", user))
+	default:
+		log.Error("GetTradingListByUser: Unknown object type", "user", user.Hex(), "object", val)
+		return nil
+	}
+	if baseToken != (common.Address{}) {
+		conditions, args = append(conditions, "data LIKE ?"), append(args, jsonField("baseToken", baseToken))
+	}
+	if quoteToken != (common.Address{}) {
+		conditions, args = append(conditions, "data LIKE ?"), append(args, jsonField("quoteToken", quoteToken))
+	}
+	if status != "" {
+		conditions, args = append(conditions, "status = ?"), append(args, status)
+	}
+	table, _ := sqlTable(val)
+	query := "SELECT data FROM " + table + " WHERE " + strings.Join(conditions, " AND ") + " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	result, err := db.queryRecords(context.Background(), val, query, append(args, limit, offset)...)
+	if err != nil {
+		log.Error("failed to GetTradingListByUser", "table", table, "err", err, "user", user.Hex())
+	}
+	return result
+}
+
+// GetTradesByPair returns a page of the trades of a pair ordered by creation time and hash, the
+// oldest first if ascending, the most recent first otherwise. The page starts after the cursor
+// trade, from the first trade in that order if the cursor is nil.
+func (db *SQLDatabase) GetTradesByPair(baseToken, quoteToken common.Address, cursor *tradingstate.Trade, limit int, ascending bool) ([]*tradingstate.Trade, error) {
+	// the tokens have no column, they are matched in the JSON document
+	conditions := []string{"data LIKE ?", "data LIKE ?"}
+	args := []interface{}{jsonField("baseToken", baseToken), jsonField("quoteToken", quoteToken)}
+	op, order := "<", "DESC"
+	if ascending {
+		op, order = ">", "ASC"
+	}
+	if cursor != nil {
+		createdAt := cursor.CreatedAt.UTC()
+		conditions = append(conditions, "(created_at "+op+" ? OR (created_at = ? AND hash "+op+" ?))")
+		args = append(args, createdAt, createdAt, cursor.Hash.Hex())
+	}
+	query := "SELECT data FROM " + tradesCollection + " WHERE " + strings.Join(conditions, " AND ") + " ORDER BY created_at " + order + ", hash " + order + " LIMIT ?"
+	result, err := db.queryRecords(context.Background(), &tradingstate.Trade{}, query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	trades, _ := result.([]*tradingstate.Trade)
+	if trades == nil {
+		trades = []*tradingstate.Trade{}
+	}
+	return trades, nil
+}
+
+// GetTradesByExchange returns the trades created between from (included) and to (excluded) whose
+// taker or maker order was placed through an exchange, the oldest first.
+func (db *SQLDatabase) GetTradesByExchange(exchange common.Address, from, to time.Time) ([]*tradingstate.Trade, error) {
+	// the exchanges have no column, they are matched in the JSON document
+	query := "SELECT data FROM " + tradesCollection + " WHERE (data LIKE ? OR data LIKE ?) AND created_at >= ? AND created_at < ? ORDER BY created_at, hash"
+	result, err := db.queryRecords(context.Background(), &tradingstate.Trade{}, query,
+		jsonField("takerExchange", exchange), jsonField("makerExchange", exchange), from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	trades, _ := result.([]*tradingstate.Trade)
+	if trades == nil {
+		trades = []*tradingstate.Trade{}
+	}
+	return trades, nil
+}
+
+// PruneCollection removes the records of a table with the given status (any status if empty)
+// last updated before the given time, and returns their number. Nothing is removed in dry-run mode.
+func (db *SQLDatabase) PruneCollection(collection string, status string, before time.Time, dryRun bool) (int, error) {
+	if _, ok := retentionCollections[collection]; !ok {
+		return 0, fmt.Errorf("unknown collection %s", collection)
+	}
+	condition, args := "updated_at < ?", []interface{}{before.UTC()}
+	if status != "" {
+		condition, args = condition+" AND status = ?", append(args, status)
+	}
+	if dryRun {
+		var count int
+		err := db.db.QueryRow(db.rebind("SELECT COUNT(*) FROM "+collection+" WHERE "+condition), args...).Scan(&count)
+		return count, err
+	}
+	result, err := db.db.Exec(db.rebind("DELETE FROM "+collection+" WHERE "+condition), args...)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	if removed > 0 {
+		db.cacheItems.Purge()
+	}
+	return int(removed), err
+}
+
+func (db *SQLDatabase) Close() error {
+	return db.db.Close()
+}
+
+// HasAncient returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) HasAncient(kind string, number uint64) (bool, error) {
+	return false, errNotSupported
+}
+
+// Ancient returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) Ancient(kind string, number uint64) ([]byte, error) {
+	return nil, errNotSupported
+}
+
+// Ancients returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) Ancients() (uint64, error) {
+	return 0, errNotSupported
+}
+
+// AncientSize returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) AncientSize(kind string) (uint64, error) {
+	return 0, errNotSupported
+}
+
+// AppendAncient returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	return errNotSupported
+}
+
+// TruncateAncients returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) TruncateAncients(items uint64) error {
+	return errNotSupported
+}
+
+// Sync returns an error as we don't have a backing chain freezer.
+func (db *SQLDatabase) Sync() error {
+	return errNotSupported
+}
+
+func (db *SQLDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	// for levelDB only
+	return nil
+}
+
+func (db *SQLDatabase) Stat(property string) (string, error) {
+	return "", errNotSupported
+}
+
+func (db *SQLDatabase) Compact(start []byte, limit []byte) error {
+	return errNotSupported
+}
+
+func (db *SQLDatabase) NewBatch() ethdb.Batch {
+	// for levelDB only
+	return nil
+}
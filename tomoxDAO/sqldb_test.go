@@ -0,0 +1,73 @@
+package tomoxDAO
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestSQLRebind(t *testing.T) {
+	query := "SELECT data FROM lending_items WHERE hash IN (" + placeholders(2) + ") AND term = ?"
+	if have, want := (&SQLDatabase{}).rebind(query), query; have != want {
+		t.Errorf("wrong query: have %q, want %q", have, want)
+	}
+	if have, want := (&SQLDatabase{dollar: true}).rebind(query), "SELECT data FROM lending_items WHERE hash IN ($1, $2) AND term = $3"; have != want {
+		t.Errorf("wrong postgres query: have %q, want %q", have, want)
+	}
+}
+
+func TestSQLRecord(t *testing.T) {
+	item := &lendingstate.LendingItem{
+		Quantity:     big.NewInt(100),
+		Interest:     big.NewInt(10),
+		Type:         lendingstate.TopUp,
+		Status:       lendingstate.TopUp,
+		LendingToken: common.HexToAddress("0x10"),
+		Term:         86400,
+		UserAddress:  common.HexToAddress("0x1"),
+		Hash:         common.HexToHash("0x2"),
+		TxHash:       common.HexToHash("0x3"),
+		CreatedAt:    time.Unix(1600000000, 0),
+	}
+	if table, _ := sqlTable(item); table != lendingTopUpCollection {
+		t.Fatalf("wrong table: %s", table)
+	}
+	record, err := newSQLRecord(item)
+	if err != nil {
+		t.Fatalf("failed to encode record: %v", err)
+	}
+	if record.hash != item.Hash.Hex() || record.txHash != item.TxHash.Hex() || record.userAddress != item.UserAddress.Hex() ||
+		record.lendingToken != item.LendingToken.Hex() || record.term != 86400 || record.createdAt.Location() != time.UTC {
+		t.Fatalf("wrong record columns: %+v", record)
+	}
+	decoded, err := decodeRecords([][]byte{record.data}, &lendingstate.LendingItem{})
+	if err != nil {
+		t.Fatalf("failed to decode record: %v", err)
+	}
+	items := decoded.([]*lendingstate.LendingItem)
+	if len(items) != 1 || items[0].Hash != item.Hash || items[0].Quantity.Cmp(item.Quantity) != 0 || !items[0].CreatedAt.Equal(item.CreatedAt) {
+		t.Fatalf("wrong decoded record: %v", items)
+	}
+	if empty, _ := decodeRecords(nil, &lendingstate.LendingTrade{}); len(empty.([]*lendingstate.LendingTrade)) != 0 {
+		t.Fatalf("wrong empty result: %v", empty)
+	}
+}
+
+func TestSQLRelayerPattern(t *testing.T) {
+	relayer := common.HexToAddress("0x0D3ab14BBaD3D99F4203bd7a11aCB94882050E7e")
+	record, err := newSQLRecord(&lendingstate.LendingTrade{InvestingRelayer: relayer})
+	if err != nil {
+		t.Fatalf("failed to encode record: %v", err)
+	}
+	pattern := jsonField("investingRelayer", relayer)
+	if !strings.Contains(string(record.data), strings.Trim(pattern, "%")) {
+		t.Fatalf("pattern %s doesn't match %s", pattern, record.data)
+	}
+	if relayerSelector(&lendingstate.LendingTrade{InvestingRelayer: relayer}) != relayer {
+		t.Fatal("wrong relayer selector")
+	}
+}
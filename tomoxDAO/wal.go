@@ -0,0 +1,194 @@
+package tomoxDAO
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The lending records of a bulk are written to the SDK database by several bulks run one after
+// the other, so a node crashing in the middle of a commit leaves some of them written, e.g. the
+// lending items of a transaction without its trades. LendingWAL journals the records of each lending
+// bulk into the tomox leveldb before committing it, and removes them once the commit returned.
+// The bulks left in the journal by a crash are committed again on restart, by ReplayLendingWAL:
+// records are written by hash, so a replay overwrites the records already written with the same
+// values.
+var lendingWALPrefix = []byte("lendingWAL-") // lendingWALPrefix + sequence (uint64 big endian) -> []walRecord
+
+// Kinds of the records of a lending bulk.
+const (
+	walLendingItem      = "item"
+	walLendingTrade     = "trade"
+	walLendingCandle    = "candle"
+	walLendingInsurance = "insurance"
+)
+
+// walRecord is a record put into a lending bulk.
+type walRecord struct {
+	Kind string          `json:"kind"`
+	Hash common.Hash     `json:"hash"`
+	Data json.RawMessage `json:"data"`
+}
+
+// LendingWAL is an SDK database whose lending bulks are journaled into a leveldb database.
+type LendingWAL struct {
+	TomoXDAO
+	wal TomoXDAO
+
+	lock    sync.Mutex
+	seq     uint64
+	pending []walRecord // records of the lending bulk being built
+}
+
+// NewLendingWAL journals the lending bulks of an SDK database into the given leveldb database.
+func NewLendingWAL(db TomoXDAO, wal TomoXDAO) *LendingWAL {
+	w := &LendingWAL{TomoXDAO: db, wal: wal}
+	it := wal.NewIterator(lendingWALPrefix, nil)
+	for it.Next() {
+		if key := it.Key(); len(key) == len(lendingWALPrefix)+8 {
+			w.seq = binary.BigEndian.Uint64(key[len(lendingWALPrefix):]) + 1
+		}
+	}
+	it.Release()
+	return w
+}
+
+func lendingWALKey(seq uint64) []byte {
+	key := make([]byte, len(lendingWALPrefix)+8)
+	copy(key, lendingWALPrefix)
+	binary.BigEndian.PutUint64(key[len(lendingWALPrefix):], seq)
+	return key
+}
+
+// walKind returns the kind of a lending record, empty for the records of the other bulks.
+func walKind(val interface{}) string {
+	switch val.(type) {
+	case *lendingstate.LendingItem:
+		return walLendingItem
+	case *lendingstate.LendingTrade:
+		return walLendingTrade
+	case *lendingstate.LendingCandle:
+		return walLendingCandle
+	case *lendingstate.LendingInsuranceRecord:
+		return walLendingInsurance
+	}
+	return ""
+}
+
+// walValue returns an empty record of the given kind.
+func walValue(kind string) (interface{}, error) {
+	switch kind {
+	case walLendingItem:
+		return &lendingstate.LendingItem{}, nil
+	case walLendingTrade:
+		return &lendingstate.LendingTrade{}, nil
+	case walLendingCandle:
+		return &lendingstate.LendingCandle{}, nil
+	case walLendingInsurance:
+		return &lendingstate.LendingInsuranceRecord{}, nil
+	}
+	return nil, fmt.Errorf("unknown lending record kind %q", kind)
+}
+
+func (w *LendingWAL) InitLendingBulk() {
+	w.lock.Lock()
+	w.pending = nil
+	w.lock.Unlock()
+	w.TomoXDAO.InitLendingBulk()
+}
+
+func (w *LendingWAL) PutObject(hash common.Hash, val interface{}) error {
+	if kind := walKind(val); kind != "" {
+		// the record is journaled as put, the SDK database may update it while putting it
+		data, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		w.lock.Lock()
+		w.pending = append(w.pending, walRecord{Kind: kind, Hash: hash, Data: data})
+		w.lock.Unlock()
+	}
+	return w.TomoXDAO.PutObject(hash, val)
+}
+
+func (w *LendingWAL) CommitLendingBulk() error {
+	return w.CommitLendingBulkContext(context.Background())
+}
+
+// CommitLendingBulkContext journals the records of the lending bulk, then commits it. The journal is
+// removed whatever the result of the commit: the callers retry the failed commits themselves.
+func (w *LendingWAL) CommitLendingBulkContext(ctx context.Context) error {
+	w.lock.Lock()
+	records, seq := w.pending, w.seq
+	w.pending = nil
+	if len(records) > 0 {
+		w.seq++
+	}
+	w.lock.Unlock()
+
+	if len(records) == 0 {
+		return w.TomoXDAO.CommitLendingBulkContext(ctx)
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if err := w.wal.Put(lendingWALKey(seq), data); err != nil {
+		return fmt.Errorf("failed to journal lending bulk: %v", err)
+	}
+	commitErr := w.TomoXDAO.CommitLendingBulkContext(ctx)
+	if err := w.wal.Delete(lendingWALKey(seq)); err != nil {
+		log.Error("Failed to remove journaled lending bulk", "seq", seq, "err", err)
+	}
+	return commitErr
+}
+
+// ReplayLendingWAL commits again the lending bulks left in the journal by a crash, in order, and
+// returns the number of bulks replayed. It must run before the node writes other lending records.
+func (w *LendingWAL) ReplayLendingWAL() (int, error) {
+	type bulk struct {
+		key     []byte
+		records []walRecord
+	}
+	var bulks []bulk
+	it := w.wal.NewIterator(lendingWALPrefix, nil)
+	for it.Next() {
+		var records []walRecord
+		if err := json.Unmarshal(it.Value(), &records); err != nil {
+			it.Release()
+			return 0, fmt.Errorf("failed to decode journaled lending bulk: %v", err)
+		}
+		bulks = append(bulks, bulk{key: common.CopyBytes(it.Key()), records: records})
+	}
+	it.Release()
+
+	for i, b := range bulks {
+		w.TomoXDAO.InitLendingBulk()
+		for _, record := range b.records {
+			val, err := walValue(record.Kind)
+			if err != nil {
+				return i, err
+			}
+			if err := json.Unmarshal(record.Data, val); err != nil {
+				return i, fmt.Errorf("failed to decode journaled lending record %s: %v", record.Hash.Hex(), err)
+			}
+			if err := w.TomoXDAO.PutObject(record.Hash, val); err != nil {
+				return i, err
+			}
+		}
+		if err := w.TomoXDAO.CommitLendingBulk(); err != nil {
+			return i, fmt.Errorf("failed to replay journaled lending bulk: %v", err)
+		}
+		if err := w.wal.Delete(b.key); err != nil {
+			return i, err
+		}
+		log.Info("Replayed journaled lending bulk", "records", len(b.records))
+	}
+	return len(bulks), nil
+}
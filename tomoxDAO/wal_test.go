@@ -0,0 +1,78 @@
+package tomoxDAO
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestLendingWAL(t *testing.T) {
+	ldb := NewBatchDatabaseWithEncode(t.TempDir(), 0)
+	defer ldb.Close()
+	crashed, err := NewBadgerDatabase(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to open badger: %v", err)
+	}
+	defer crashed.Close()
+
+	var (
+		item  = &lendingstate.LendingItem{Hash: common.HexToHash("0x1"), Quantity: big.NewInt(100), FilledAmount: big.NewInt(40), Status: lendingstate.LendingStatusPartialFilled}
+		trade = &lendingstate.LendingTrade{Hash: common.HexToHash("0x2"), TradeId: 1, Amount: big.NewInt(40), Status: lendingstate.TradeStatusOpen}
+	)
+	// the node crashes once the bulk is journaled, before it is committed
+	w := NewLendingWAL(crashed, ldb)
+	w.InitLendingBulk()
+	for hash, val := range map[common.Hash]interface{}{item.Hash: item, trade.Hash: trade} {
+		if err := w.PutObject(hash, val); err != nil {
+			t.Fatalf("failed to put %x: %v", hash, err)
+		}
+	}
+	data, err := json.Marshal(w.pending)
+	if err != nil {
+		t.Fatalf("failed to encode bulk: %v", err)
+	}
+	if err := ldb.Put(lendingWALKey(w.seq), data); err != nil {
+		t.Fatalf("failed to journal bulk: %v", err)
+	}
+
+	// the bulk is committed on restart
+	db, err := NewBadgerDatabase(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to open badger: %v", err)
+	}
+	defer db.Close()
+	w = NewLendingWAL(db, ldb)
+	if w.seq != 1 {
+		t.Errorf("wrong sequence after restart: have %d, want 1", w.seq)
+	}
+	if n, err := w.ReplayLendingWAL(); err != nil || n != 1 {
+		t.Fatalf("failed to replay: %d bulks, %v", n, err)
+	}
+	val, err := db.GetObject(item.Hash, &lendingstate.LendingItem{})
+	if err != nil || val == nil || val.(*lendingstate.LendingItem).FilledAmount.Int64() != 40 {
+		t.Errorf("item not replayed: %v, %v", val, err)
+	}
+	if has, _ := db.HasObject(trade.Hash, &lendingstate.LendingTrade{}); !has {
+		t.Error("trade not replayed")
+	}
+	if n, err := w.ReplayLendingWAL(); err != nil || n != 0 {
+		t.Errorf("bulk replayed twice: %d bulks, %v", n, err)
+	}
+
+	// a committed bulk leaves nothing in the journal
+	w.InitLendingBulk()
+	if err := w.PutObject(item.Hash, item); err != nil {
+		t.Fatalf("failed to put item: %v", err)
+	}
+	if err := w.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+	it := ldb.NewIterator(lendingWALPrefix, nil)
+	defer it.Release()
+	if it.Next() {
+		t.Errorf("committed bulk left in the journal: %x", it.Key())
+	}
+}
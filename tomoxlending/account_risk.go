@@ -0,0 +1,129 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// AccountRisk is the RPC representation of the risk of an account across the spot and lending
+// books, see lendingstate.AccountRisk. The matching engines still check the free balance of an
+// account only, the margin tells the relayers and clients how much of it, and of the collateral in
+// excess of the lending trades, is left to back new orders.
+type AccountRisk struct {
+	User        common.Address            `json:"user"`
+	BlockNumber uint64                    `json:"blockNumber"`
+	Tokens      []*lendingstate.TokenRisk `json:"tokens"`
+}
+
+// addSpotOrders adds the open orders of an account in the spot books, with their remaining
+// quantity, to its risk or escrow.
+func (l *Lending) addSpotOrders(user common.Address, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, add func(order *tradingstate.OrderItem, remaining *big.Int, baseTokenDecimal *big.Int)) error {
+	orderBooks, err := tradingstate.GetAllTradingPairs(statedb)
+	if err != nil {
+		return err
+	}
+	for orderBook := range orderBooks {
+		for _, dump := range []func(common.Hash) (map[*big.Int]tradingstate.DumpOrderList, error){tradingState.DumpAskTrie, tradingState.DumpBidTrie} {
+			orderLists, err := dump(orderBook)
+			if err != nil {
+				// no order placed in the book yet
+				break
+			}
+			for _, orderList := range orderLists {
+				for orderId, remaining := range orderList.Orders {
+					order := tradingState.GetOrder(orderBook, common.BigToHash(orderId))
+					if order.UserAddress != user {
+						continue
+					}
+					baseTokenDecimal, err := l.tomox.GetTokenDecimal(l.chain, statedb, order.BaseToken)
+					if err != nil {
+						return err
+					}
+					add(&order, remaining, baseTokenDecimal)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addLendingTrades adds the open lending trades of the account, as borrower or investor, to its
+// risk. The excess collateral of a trade is valued at the current collateral price of its pair.
+func (l *Lending) addLendingTrades(risk *lendingstate.AccountRisk, header *types.Header, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) error {
+	lendingBooks, err := lendingstate.GetAllLendingBooks(statedb)
+	if err != nil {
+		// no lending book registered yet
+		return nil
+	}
+	collateralPrices := make(map[lendingstate.LendingPair]*big.Int)
+	for lendingBook := range lendingBooks {
+		if !lendingState.Exist(lendingBook) {
+			continue
+		}
+		trades, err := lendingState.DumpLendingTradeTrie(lendingBook)
+		if err != nil {
+			return err
+		}
+		for _, trade := range trades {
+			if trade.Borrower != risk.User && trade.Investor != risk.User {
+				continue
+			}
+			pair := lendingstate.LendingPair{LendingToken: trade.LendingToken, CollateralToken: trade.CollateralToken}
+			collateralPrice, ok := collateralPrices[pair]
+			if !ok {
+				_, collateralPrice, err = l.GetCollateralPrices(header, l.chain, statedb, tradingState, trade.CollateralToken, trade.LendingToken)
+				if err != nil {
+					collateralPrice = nil
+				}
+				collateralPrices[pair] = collateralPrice
+			}
+			depositRate, liquidationRate := trade.DepositRate, trade.LiquidationRate
+			if depositRate == nil || liquidationRate == nil || liquidationRate.Sign() <= 0 {
+				depositRate, liquidationRate, _ = lendingstate.GetCollateralDetail(statedb, trade.CollateralToken)
+			}
+			// the collateral of a multi-collateral trade can't be recalled, see ProcessRecall
+			recallable := !isMultiCollateralTrade(lendingState, lendingBook, trade.TradeId)
+			trade := trade
+			risk.AddLendingTrade(&trade, collateralPrice, depositRate, liquidationRate, recallable)
+		}
+	}
+	return nil
+}
+
+// accountRisk returns the risk of an account across the spot and lending books at the current
+// block.
+func (l *Lending) accountRisk(user common.Address) (*AccountRisk, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := l.tomox.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	risk := lendingstate.NewAccountRisk(user)
+	if err := l.addSpotOrders(user, statedb, tradingState, risk.AddSpotOrder); err != nil {
+		return nil, err
+	}
+	if err := l.addLendingTrades(risk, block.Header(), statedb, tradingState, lendingState); err != nil {
+		return nil, err
+	}
+	risk.Token(common.HexToAddress(common.TomoNativeAddress))
+	for _, tokenRisk := range risk.Tokens() {
+		tokenRisk.Balance = lendingstate.GetTokenBalance(user, tokenRisk.Token, statedb)
+	}
+	return &AccountRisk{User: user, BlockNumber: block.NumberU64(), Tokens: risk.Tokens()}, nil
+}
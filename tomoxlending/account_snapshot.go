@@ -0,0 +1,238 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// AccountSnapshot is the spot and lending position of an account at the current block, as returned
+// by tomox_getAccountSnapshot.
+//
+// Escrow sums, per token, the amounts committed by the account: the remaining quantity of its open
+// asks in base token and of its open bids in quote token, the remaining quantity of its open
+// investing items in lending token, and the collateral locked by the trades it borrowed. Borrowing
+// items lock their collateral only once matched, so they are not part of it.
+type AccountSnapshot struct {
+	Address       common.Address               `json:"address"`
+	BlockNumber   uint64                       `json:"blockNumber"`
+	BlockHash     common.Hash                  `json:"blockHash"`
+	Escrow        map[common.Address]*big.Int  `json:"escrow"`
+	SpotOrders    []*tradingstate.OrderItem    `json:"spotOrders"`
+	LendingItems  []*lendingstate.LendingItem  `json:"lendingItems"`
+	LendingTrades []*lendingstate.LendingTrade `json:"lendingTrades"`
+	Health        []PositionHealth             `json:"health"`
+}
+
+// AccountSnapshot returns the open spot orders, open lending items and open lending trades of an
+// account, the tokens they commit and the health of the trades it borrowed, all read from the
+// trading and lending states of the current block.
+func (l *Lending) AccountSnapshot(user common.Address) (*AccountSnapshot, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := l.tomox.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &AccountSnapshot{
+		Address:       user,
+		BlockNumber:   block.NumberU64(),
+		BlockHash:     block.Hash(),
+		Escrow:        make(map[common.Address]*big.Int),
+		LendingTrades: []*lendingstate.LendingTrade{},
+		Health:        []PositionHealth{},
+	}
+	if snapshot.SpotOrders, err = l.openSpotOrders(statedb, tradingState, user); err != nil {
+		return nil, err
+	}
+	for _, order := range snapshot.SpotOrders {
+		if order.Side == tradingstate.Ask {
+			addEscrow(snapshot.Escrow, order.BaseToken, order.Quantity)
+			continue
+		}
+		baseTokenDecimal, err := l.tomox.GetTokenDecimal(l.chain, statedb, order.BaseToken)
+		if err != nil {
+			return nil, err
+		}
+		addEscrow(snapshot.Escrow, order.QuoteToken, bidEscrow(order.Quantity, order.Price, baseTokenDecimal))
+	}
+	if snapshot.LendingItems, err = l.openLendingItems(user, nil, 0); err != nil {
+		return nil, err
+	}
+	for _, item := range snapshot.LendingItems {
+		if item.Side == lendingstate.Investing {
+			addEscrow(snapshot.Escrow, item.LendingToken, item.Quantity)
+		}
+	}
+	for _, lendingBook := range lendingBooks(statedb) {
+		if !lendingState.Exist(lendingBook) {
+			continue
+		}
+		trades, err := lendingState.DumpLendingTradeTrie(lendingBook)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.LendingTrades = append(snapshot.LendingTrades, userLendingTrades(trades, user)...)
+	}
+	for _, trade := range snapshot.LendingTrades {
+		if trade.Borrower != user {
+			continue
+		}
+		addEscrow(snapshot.Escrow, trade.CollateralToken, trade.CollateralLockedAmount)
+		health, err := l.positionHealth(block, statedb, tradingState, trade)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Health = append(snapshot.Health, health)
+	}
+	return snapshot, nil
+}
+
+// openSpotOrders returns the orders of a user resting in the order books of the trading state,
+// sorted by order book and order id. Their quantity is the remaining one.
+func (l *Lending) openSpotOrders(statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, user common.Address) ([]*tradingstate.OrderItem, error) {
+	pairs, err := tradingstate.GetAllTradingPairs(statedb)
+	if err != nil {
+		return nil, err
+	}
+	orderBooks := make([]common.Hash, 0, len(pairs))
+	for orderBook := range pairs {
+		orderBooks = append(orderBooks, orderBook)
+	}
+	sort.Slice(orderBooks, func(i, j int) bool {
+		return orderBooks[i].Big().Cmp(orderBooks[j].Big()) < 0
+	})
+	orders := []*tradingstate.OrderItem{}
+	for _, orderBook := range orderBooks {
+		if !tradingState.Exist(orderBook) {
+			continue
+		}
+		asks, err := tradingState.DumpAskTrie(orderBook)
+		if err != nil {
+			return nil, err
+		}
+		bids, err := tradingState.DumpBidTrie(orderBook)
+		if err != nil {
+			return nil, err
+		}
+		bookOrders := []*tradingstate.OrderItem{}
+		for _, side := range []map[*big.Int]tradingstate.DumpOrderList{asks, bids} {
+			for _, orderList := range side {
+				for orderId, quantity := range orderList.Orders {
+					order := tradingState.GetOrder(orderBook, common.BigToHash(orderId))
+					if order.UserAddress != user || quantity.Sign() <= 0 {
+						continue
+					}
+					order.Quantity = quantity
+					bookOrders = append(bookOrders, &order)
+				}
+			}
+		}
+		sort.Slice(bookOrders, func(i, j int) bool {
+			return bookOrders[i].OrderID < bookOrders[j].OrderID
+		})
+		orders = append(orders, bookOrders...)
+	}
+	return orders, nil
+}
+
+// lendingBooks returns the hashes of the lending books of the supported lending tokens and terms.
+func lendingBooks(statedb *state.StateDB) []common.Hash {
+	books := []common.Hash{}
+	for _, token := range lendingstate.GetSupportedBaseToken(statedb) {
+		for _, term := range lendingstate.GetSupportedTerms(statedb) {
+			books = append(books, lendingstate.GetLendingOrderBookHash(token, term))
+		}
+	}
+	return books
+}
+
+// userLendingTrades returns the open trades of a lending book borrowed or invested by a user,
+// sorted by trade id.
+func userLendingTrades(bookTrades map[*big.Int]lendingstate.LendingTrade, user common.Address) []*lendingstate.LendingTrade {
+	trades := []*lendingstate.LendingTrade{}
+	for _, trade := range bookTrades {
+		if (trade.Borrower != user && trade.Investor != user) || trade.Amount == nil || trade.Amount.Sign() <= 0 {
+			continue
+		}
+		trade := trade
+		trades = append(trades, &trade)
+	}
+	sort.Slice(trades, func(i, j int) bool {
+		return trades[i].TradeId < trades[j].TradeId
+	})
+	return trades
+}
+
+// bidEscrow returns the quote token amount committed by a bid: its quantity valued at its price.
+func bidEscrow(quantity, price, baseTokenDecimal *big.Int) *big.Int {
+	if price == nil || baseTokenDecimal == nil || baseTokenDecimal.Sign() == 0 {
+		return new(big.Int)
+	}
+	amount := new(big.Int).Mul(quantity, price)
+	return amount.Div(amount, baseTokenDecimal)
+}
+
+// addEscrow adds an amount of token to the escrowed balances.
+func addEscrow(escrow map[common.Address]*big.Int, token common.Address, amount *big.Int) {
+	if amount == nil || amount.Sign() <= 0 {
+		return
+	}
+	if escrow[token] == nil {
+		escrow[token] = new(big.Int)
+	}
+	escrow[token].Add(escrow[token], amount)
+}
+
+// positionHealth values an open lending trade with the collateral price of the current epoch.
+func (l *Lending) positionHealth(block *types.Block, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, trade *lendingstate.LendingTrade) (PositionHealth, error) {
+	_, collateralPrice, err := l.GetCollateralPrices(block.Header(), l.chain, statedb, tradingState, trade.CollateralToken, trade.LendingToken)
+	if err != nil {
+		return PositionHealth{}, err
+	}
+	collateralTokenDecimal, err := l.tomox.GetTokenDecimal(l.chain, statedb, trade.CollateralToken)
+	if err != nil {
+		return PositionHealth{}, err
+	}
+	if collateralPrice == nil || collateralPrice.Sign() == 0 || collateralTokenDecimal == nil || collateralTokenDecimal.Sign() == 0 {
+		return PositionHealth{}, lendingstate.ErrInvalidCollateralPrice
+	}
+	collateralValue := new(big.Int).Mul(trade.CollateralLockedAmount, collateralPrice)
+	collateralValue = new(big.Int).Div(collateralValue, collateralTokenDecimal)
+	debtValue := lendingstate.CalculateTotalRepayValue(block.Time().Uint64(), trade.LiquidationTime, trade.Term, trade.Interest, trade.Amount)
+
+	health := PositionHealth{
+		TradeId:                trade.TradeId,
+		Hash:                   trade.Hash,
+		CollateralToken:        trade.CollateralToken,
+		CollateralLockedAmount: trade.CollateralLockedAmount,
+		CollateralPrice:        collateralPrice,
+		LiquidationPrice:       trade.LiquidationPrice,
+		LiquidationTime:        trade.LiquidationTime,
+		CollateralValue:        collateralValue,
+		DebtValue:              debtValue,
+	}
+	if debtValue.Sign() > 0 {
+		health.HealthFactor, _ = new(big.Float).Quo(new(big.Float).SetInt(collateralValue), new(big.Float).SetInt(debtValue)).Float64()
+	}
+	if trade.LiquidationPrice != nil {
+		distance := new(big.Float).SetInt(new(big.Int).Sub(collateralPrice, trade.LiquidationPrice))
+		health.DistanceToLiquidation, _ = new(big.Float).Quo(distance, new(big.Float).SetInt(collateralPrice)).Float64()
+	}
+	return health, nil
+}
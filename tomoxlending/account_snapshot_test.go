@@ -0,0 +1,49 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestAccountSnapshotEscrow(t *testing.T) {
+	var (
+		user, other  = common.HexToAddress("0x1"), common.HexToAddress("0x2")
+		lendingToken = common.HexToAddress("0x10")
+		quoteToken   = common.HexToAddress("0x20")
+	)
+	trades := map[*big.Int]lendingstate.LendingTrade{
+		big.NewInt(3): {TradeId: 3, Investor: user, Borrower: other, Amount: big.NewInt(10)},
+		big.NewInt(1): {TradeId: 1, Investor: other, Borrower: user, Amount: big.NewInt(20)},
+		big.NewInt(2): {TradeId: 2, Investor: other, Borrower: other, Amount: big.NewInt(30)},
+		big.NewInt(4): {TradeId: 4, Investor: user, Borrower: other, Amount: new(big.Int)},
+	}
+	userTrades := userLendingTrades(trades, user)
+	if len(userTrades) != 2 || userTrades[0].TradeId != 1 || userTrades[1].TradeId != 3 {
+		t.Errorf("wrong open trades of the user: %v", userTrades)
+	}
+
+	escrow := make(map[common.Address]*big.Int)
+	addEscrow(escrow, lendingToken, big.NewInt(5))
+	addEscrow(escrow, lendingToken, big.NewInt(7))
+	addEscrow(escrow, quoteToken, bidEscrow(big.NewInt(3e18), big.NewInt(2e17), big.NewInt(1e18)))
+	addEscrow(escrow, other, new(big.Int))
+	if escrow[lendingToken].Cmp(big.NewInt(12)) != 0 {
+		t.Errorf("wrong escrow of the lending token: have %v, want 12", escrow[lendingToken])
+	}
+	if escrow[quoteToken].Cmp(big.NewInt(6e17)) != 0 {
+		t.Errorf("wrong escrow of a bid: have %v, want 6e17", escrow[quoteToken])
+	}
+	if _, ok := escrow[other]; ok {
+		t.Error("escrow of an empty amount")
+	}
+
+	tomoX := tomox.New(&tomox.Config{DevDB: true})
+	defer tomoX.GetMongoDB().Close()
+	if _, err := New(tomoX).AccountSnapshot(user); err != errLendingStateUnavailable {
+		t.Errorf("wrong error without a chain: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// An amendment is a lending item with the status AMENDED which refers to an open limit item of the
+// same user by its LendingId and hash, and sets the quantity still open and the interest of the item
+// with a single nonce, instead of a cancellation followed by a new item.
+//
+// The priority of the amended item follows these rules:
+//   - if the interest is unchanged and the quantity isn't increased, the item keeps its LendingId,
+//     and so its place in the queue of its interest level
+//   - otherwise the item is removed from the lending book and processed again as a new limit item
+//     with the amended quantity and interest: it may be matched, and its unmatched part is added at
+//     the end of the queue of its interest level with a new LendingId
+//
+// In both cases the item keeps its hash, its collateral and its time in force. The relayer pays the
+// cancellation fee to the masternode, the user doesn't pay any fee.
+
+// amendKeepsPriority returns whether amending an open item to the given quantity and interest keeps
+// its place in the queue of its interest level.
+func amendKeepsPriority(item *lendingstate.LendingItem, quantity, interest *big.Int) bool {
+	return item.Interest.Cmp(interest) == 0 && quantity.Cmp(item.Quantity) <= 0
+}
+
+// ProcessAmendOrder applies an amendment to the open limit item it refers to. The amendment is
+// rejected without any change if the item isn't an open limit item of the user placed through the
+// same relayer, or if the relayer can't pay the fee.
+func (l *Lending) ProcessAmendOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
+	lendingId := common.BigToHash(new(big.Int).SetUint64(order.LendingId))
+	originOrder := lendingStateDB.GetLendingOrder(lendingOrderBook, lendingId)
+	if originOrder.Quantity == nil || originOrder.Quantity.Sign() == 0 {
+		log.Debug("Amended lending item not found", "LendingId", order.LendingId, "lendingBook", lendingOrderBook.Hex())
+		return nil, []*lendingstate.LendingItem{rejectLendingItem(order, lendingstate.RejectReasonAmendFailed)}, nil
+	}
+	if originOrder.Hash != order.Hash || originOrder.UserAddress != order.UserAddress || originOrder.Relayer != order.Relayer ||
+		originOrder.Type != lendingstate.Limit || originOrder.Side != order.Side || originOrder.CollateralToken != order.CollateralToken {
+		log.Debug("Amendment doesn't match the lending item", "LendingId", order.LendingId, "hash", originOrder.Hash.Hex(), "got", order.Hash.Hex())
+		return nil, []*lendingstate.LendingItem{rejectLendingItem(order, lendingstate.RejectReasonAmendFailed)}, nil
+	}
+	if err := lendingstate.CheckRelayerFee(originOrder.Relayer, common.RelayerLendingCancelFee, statedb); err != nil {
+		log.Debug("Relayer not enough fee when amend order", "err", err)
+		return nil, []*lendingstate.LendingItem{rejectLendingItem(order, lendingstate.RejectReasonInsufficientRelayerFee)}, nil
+	}
+	lendingstate.SubRelayerFee(originOrder.Relayer, common.RelayerLendingCancelFee, statedb)
+	statedb.AddBalance(statedb.GetOwner(coinbase), common.RelayerLendingCancelFee)
+
+	if amendKeepsPriority(&originOrder, order.Quantity, order.Interest) {
+		if reduced := new(big.Int).Sub(originOrder.Quantity, order.Quantity); reduced.Sign() > 0 {
+			if err := lendingStateDB.SubAmountLendingItem(lendingOrderBook, lendingId, originOrder.Interest, reduced, originOrder.Side); err != nil {
+				return nil, nil, err
+			}
+		}
+		log.Debug("Amended lending item in place", "LendingId", order.LendingId, "quantity", order.Quantity)
+		return nil, nil, nil
+	}
+	if err := cancelLendingItem(lendingStateDB, lendingOrderBook, &originOrder); err != nil {
+		return nil, nil, err
+	}
+	amended := originOrder
+	amended.Quantity = lendingstate.CloneBigInt(order.Quantity)
+	amended.Interest = lendingstate.CloneBigInt(order.Interest)
+	log.Debug("Process amended limit order", "side", amended.Side, "quantity", amended.Quantity, "Interest", amended.Interest)
+	trades, rejects, err := l.processLimitOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, &amended)
+	if err != nil {
+		return nil, nil, err
+	}
+	order.LendingId = amended.LendingId
+	return trades, rejects, nil
+}
+
+// isRejectedAmendment returns whether an amendment is among the rejected items of its transaction.
+// The rejected items may also hold the amended item itself, when it is processed again and rejected.
+func isRejectedAmendment(amendment *lendingstate.LendingItem, rejectedItems []*lendingstate.LendingItem) bool {
+	for _, item := range rejectedItems {
+		if item.Hash == amendment.Hash && item.Status == lendingstate.LendingStatusAmended {
+			return true
+		}
+	}
+	return false
+}
+
+// isForeignLendingHash returns whether a lending item was rejected because it doesn't own its hash,
+// the records of the item with that hash are left alone.
+func isForeignLendingHash(item *lendingstate.LendingItem, rejectedItems []*lendingstate.LendingItem) bool {
+	for _, rejected := range rejectedItems {
+		if rejected.Hash == item.Hash && (rejected.RejectReason == lendingstate.RejectReasonReplayed || rejected.RejectReason == lendingstate.RejectReasonInvalidHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// amendLendingItemRecord applies an amendment to the SDK record of the amended item. The record
+// keeps its filled amount, its quantity becomes the filled amount plus the amended open quantity.
+func amendLendingItemRecord(record *lendingstate.LendingItem, amendment *lendingstate.LendingItem) {
+	if record.FilledAmount == nil {
+		record.FilledAmount = new(big.Int)
+	}
+	record.Quantity = new(big.Int).Add(record.FilledAmount, amendment.Quantity)
+	record.Interest = lendingstate.CloneBigInt(amendment.Interest)
+	record.LendingId = amendment.LendingId
+	record.Status = lendingstate.LendingStatusOpen
+	if record.FilledAmount.Sign() > 0 {
+		record.Status = lendingstate.LendingStatusPartialFilled
+	}
+}
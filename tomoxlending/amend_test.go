@@ -0,0 +1,68 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestAmendKeepsPriority(t *testing.T) {
+	item := &lendingstate.LendingItem{Quantity: big.NewInt(10), Interest: big.NewInt(5)}
+	tests := []struct {
+		quantity, interest int64
+		want               bool
+	}{
+		{10, 5, true},
+		{4, 5, true},
+		{11, 5, false},
+		{10, 6, false},
+		{4, 4, false},
+	}
+	for i, test := range tests {
+		if got := amendKeepsPriority(item, big.NewInt(test.quantity), big.NewInt(test.interest)); got != test.want {
+			t.Errorf("test %d: have %v, want %v", i, got, test.want)
+		}
+	}
+}
+
+func TestAmendInPlaceKeepsQueue(t *testing.T) {
+	lendingBook := lendingstate.GetLendingOrderBookHash(common.HexToAddress("0x3"), 60)
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	interest := big.NewInt(5)
+	for _, id := range []uint64{1, 2} {
+		item := lendingstate.LendingItem{LendingId: id, Quantity: big.NewInt(10), Interest: interest, Term: 60,
+			Side: lendingstate.Investing, Hash: common.Uint64ToHash(id)}
+		lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(id), item)
+	}
+	// reduce the first item of the queue from 10 to 4
+	if err := lendingStateDB.SubAmountLendingItem(lendingBook, common.Uint64ToHash(1), interest, big.NewInt(6), lendingstate.Investing); err != nil {
+		t.Fatalf("failed to amend lending item: %v", err)
+	}
+	lendingId, amount, err := lendingStateDB.GetBestLendingIdAndAmount(lendingBook, interest, lendingstate.Investing)
+	if err != nil {
+		t.Fatalf("failed to get best lending item: %v", err)
+	}
+	if lendingId != common.Uint64ToHash(1) || amount.Cmp(big.NewInt(4)) != 0 {
+		t.Fatalf("wrong head of queue: have id %x amount %v, want id 1 amount 4", lendingId, amount)
+	}
+}
+
+func TestAmendLendingItemRecord(t *testing.T) {
+	record := &lendingstate.LendingItem{LendingId: 1, Quantity: big.NewInt(10), Interest: big.NewInt(5), FilledAmount: big.NewInt(3),
+		Status: lendingstate.LendingStatusPartialFilled}
+	amendment := &lendingstate.LendingItem{LendingId: 9, Quantity: big.NewInt(2), Interest: big.NewInt(6), Status: lendingstate.LendingStatusAmended}
+	amendLendingItemRecord(record, amendment)
+	if record.Quantity.Cmp(big.NewInt(5)) != 0 || record.Interest.Cmp(big.NewInt(6)) != 0 || record.LendingId != 9 ||
+		record.Status != lendingstate.LendingStatusPartialFilled {
+		t.Fatalf("wrong amended record: %v", lendingstate.ToJSON(record))
+	}
+	if isRejectedAmendment(amendment, []*lendingstate.LendingItem{{Hash: amendment.Hash, Status: lendingstate.LendingStatusOpen}}) {
+		t.Fatal("rejected re-inserted item taken as rejected amendment")
+	}
+	if !isRejectedAmendment(amendment, []*lendingstate.LendingItem{amendment}) {
+		t.Fatal("rejected amendment not detected")
+	}
+}
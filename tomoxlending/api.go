@@ -3,16 +3,158 @@ package tomoxlending
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/big"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
 )
 
 // List of errors
 var (
-	ErrOrderNonceTooLow  = errors.New("OrderNonce too low")
-	ErrOrderNonceTooHigh = errors.New("OrderNonce too high")
+	ErrOrderNonceTooLow    = errors.New("OrderNonce too low")
+	ErrOrderNonceTooHigh   = errors.New("OrderNonce too high")
+	ErrTooManyLendingItems = fmt.Errorf("too many lending items, max %d per call", maxLendingItemsPerCall)
+	ErrNoLendingItems      = errors.New("no lending items")
 )
 
+// maxLendingItemsPerCall is the maximum number of lending items accepted by SendLendingItems.
+const maxLendingItemsPerCall = 100
+
+// LendingItemArgs represents a signed lending item sent over RPC. It uses the same
+// json fields as the lending message accepted by tomox_sendLending.
+type LendingItemArgs struct {
+	AccountNonce    hexutil.Uint64 `json:"nonce"`
+	Quantity        hexutil.Big    `json:"quantity,omitempty"`
+	RelayerAddress  common.Address `json:"relayerAddress,omitempty"`
+	UserAddress     common.Address `json:"userAddress,omitempty"`
+	CollateralToken common.Address `json:"collateralToken,omitempty"`
+	AutoTopUp       bool           `json:"autoTopUp,omitempty"`
+	LendingToken    common.Address `json:"lendingToken,omitempty"`
+	Term            hexutil.Uint64 `json:"term,omitempty"`
+	Interest        hexutil.Uint64 `json:"interest,omitempty"`
+	Status          string         `json:"status,omitempty"`
+	Side            string         `json:"side,omitempty"`
+	Type            string         `json:"type,omitempty"`
+	LendingId       hexutil.Uint64 `json:"lendingId,omitempty"`
+	LendingTradeId  hexutil.Uint64 `json:"tradeId,omitempty"`
+	ExtraData       string         `json:"extraData,omitempty"`
+
+	// Signature values
+	V hexutil.Big `json:"v"`
+	R hexutil.Big `json:"r"`
+	S hexutil.Big `json:"s"`
+
+	Hash common.Hash `json:"hash"`
+}
+
+func (args *LendingItemArgs) toTransaction() *types.LendingTransaction {
+	tx := types.NewLendingTransaction(uint64(args.AccountNonce), args.Quantity.ToInt(), uint64(args.Interest), uint64(args.Term), args.RelayerAddress, args.UserAddress, args.LendingToken, args.CollateralToken, args.AutoTopUp, args.Status, args.Side, args.Type, args.Hash, uint64(args.LendingId), uint64(args.LendingTradeId), args.ExtraData)
+	return tx.ImportSignature(args.V.ToInt(), args.R.ToInt(), args.S.ToInt())
+}
+
+// PositionHealth describes how far an open lending trade is from liquidation.
+// Values are expressed in lending token, prices are collateral prices in lending token.
+type PositionHealth struct {
+	TradeId                uint64         `json:"tradeId"`
+	Hash                   common.Hash    `json:"hash"`
+	CollateralToken        common.Address `json:"collateralToken"`
+	CollateralLockedAmount *big.Int       `json:"collateralLockedAmount"`
+	CollateralPrice        *big.Int       `json:"collateralPrice"`
+	LiquidationPrice       *big.Int       `json:"liquidationPrice"`
+	LiquidationTime        uint64         `json:"liquidationTime"`
+	CollateralValue        *big.Int       `json:"collateralValue"`
+	DebtValue              *big.Int       `json:"debtValue"`
+	HealthFactor           float64        `json:"healthFactor"`          // collateral value / debt value
+	DistanceToLiquidation  float64        `json:"distanceToLiquidation"` // relative collateral price drop that triggers liquidation
+}
+
+// AccruedInterest is the interest owed so far on an open lending trade at the time of a block.
+// Amounts are expressed in lending token.
+type AccruedInterest struct {
+	TradeId         uint64      `json:"tradeId"`
+	Hash            common.Hash `json:"hash"`
+	BlockNumber     uint64      `json:"blockNumber"`
+	Time            uint64      `json:"time"`
+	Amount          *big.Int    `json:"amount"`
+	Interest        uint64      `json:"interest"`
+	Term            uint64      `json:"term"`
+	LiquidationTime uint64      `json:"liquidationTime"`
+	ElapsedTime     uint64      `json:"elapsedTime"`
+	SettledInterest *big.Int    `json:"settledInterest"` // periodic interest already paid, not owed anymore
+	AccruedInterest *big.Int    `json:"accruedInterest"`
+	TotalRepayValue *big.Int    `json:"totalRepayValue"`
+}
+
+// newAccruedInterest computes the interest owed on a lending trade if it was repaid at the given time,
+// with the formula used to settle repayments, less the periodic interest already settled.
+func newAccruedInterest(trade *lendingstate.LendingTrade, settledInterest *big.Int, blockNumber uint64, time uint64) *AccruedInterest {
+	totalRepayValue := lendingstate.CalculateTotalRepayValue(time, trade.LiquidationTime, trade.Term, trade.Interest, trade.Amount)
+	totalRepayValue.Sub(totalRepayValue, settledInterest)
+	if totalRepayValue.Cmp(trade.Amount) < 0 {
+		totalRepayValue.Set(trade.Amount)
+	}
+	elapsedTime := uint64(0)
+	if startTime := trade.LiquidationTime - trade.Term; time > startTime {
+		elapsedTime = time - startTime
+	}
+	return &AccruedInterest{
+		TradeId:         trade.TradeId,
+		Hash:            trade.Hash,
+		BlockNumber:     blockNumber,
+		Time:            time,
+		Amount:          trade.Amount,
+		Interest:        trade.Interest,
+		Term:            trade.Term,
+		LiquidationTime: trade.LiquidationTime,
+		ElapsedTime:     elapsedTime,
+		SettledInterest: settledInterest,
+		AccruedInterest: new(big.Int).Sub(totalRepayValue, trade.Amount),
+		TotalRepayValue: totalRepayValue,
+	}
+}
+
+// OrderBookProof is the lending book of a lending token and a term at a block, along with the
+// proof of its interest levels against the lending state root committed by the block. Light
+// clients check it with lendingstate.VerifyLendingBookProof, after comparing its root to the
+// root committed by the block.
+type OrderBookProof struct {
+	LendingToken common.Address `json:"lendingToken"`
+	Term         uint64         `json:"term"`
+	BlockHash    common.Hash    `json:"blockHash"`
+	BlockNumber  uint64         `json:"blockNumber"`
+	*lendingstate.LendingBookProof
+}
+
+// TradeProof is a lending trade of a lending book at a block, along with its proof against the
+// lending state root committed by the block. Light clients check it with
+// lendingstate.VerifyLendingTradeProof, after comparing its root to the root committed by the block.
+type TradeProof struct {
+	LendingToken common.Address `json:"lendingToken"`
+	Term         uint64         `json:"term"`
+	TradeId      uint64         `json:"tradeId"`
+	BlockHash    common.Hash    `json:"blockHash"`
+	BlockNumber  uint64         `json:"blockNumber"`
+	*lendingstate.LendingTradeProof
+}
+
+// relayerFilter returns the relayer of an optional RPC argument, the empty address matching
+// every relayer.
+func relayerFilter(relayer *common.Address) common.Address {
+	if relayer == nil {
+		return common.Address{}
+	}
+	return *relayer
+}
+
 // PublicTomoXLendingAPI provides the tomoX RPC service that can be
 // use publicly without security implications.
 type PublicTomoXLendingAPI struct {
@@ -35,3 +177,607 @@ func NewPublicTomoXLendingAPI(t *Lending) *PublicTomoXLendingAPI {
 func (api *PublicTomoXLendingAPI) Version(ctx context.Context) string {
 	return ProtocolVersionStr
 }
+
+// GetOrderBookDepth returns the aggregated investing and borrowing volume at each interest
+// rate of a lending book, built from the lending state of the current block.
+// At most levels entries are returned per side, all of them if levels is not positive.
+func (api *PublicTomoXLendingAPI) GetOrderBookDepth(ctx context.Context, lendingToken common.Address, term uint64, levels int) (*OrderBookSnapshot, error) {
+	depth, err := api.t.orderBookSnapshot(lendingToken, term)
+	if err != nil {
+		return nil, err
+	}
+	if levels > 0 {
+		if len(depth.Investing) > levels {
+			depth.Investing = depth.Investing[:levels]
+		}
+		if len(depth.Borrowing) > levels {
+			depth.Borrowing = depth.Borrowing[:levels]
+		}
+	}
+	return depth, nil
+}
+
+// GetOrderBookWithProof returns every interest level of a lending book in the lending state
+// committed by the given block, selected by number or by hash, with a merkle proof of the levels
+// against the lending state root of the block, so that books served by relayers can be verified.
+func (api *PublicTomoXLendingAPI) GetOrderBookWithProof(ctx context.Context, lendingToken common.Address, term uint64, blockNrOrHash rpc.BlockNumberOrHash) (*OrderBookProof, error) {
+	return api.t.orderBookWithProof(lendingToken, term, blockNrOrHash)
+}
+
+// GetTradeProof returns a lending trade of a lending book in the lending state committed by the
+// given block, selected by number or by hash, with a merkle proof of the trade against the lending
+// state root of the block, so that positions presented by relayers can be verified. The trade is
+// null if the proof proves it missing from the book.
+func (api *PublicTomoXLendingAPI) GetTradeProof(ctx context.Context, lendingToken common.Address, term uint64, tradeId uint64, blockNrOrHash rpc.BlockNumberOrHash) (*TradeProof, error) {
+	return api.t.tradeProof(lendingToken, term, tradeId, blockNrOrHash)
+}
+
+// GetLendingOrderNonce returns the nonce of the next lending item of an address at the given block,
+// "pending" to take the lending pool into account, along with the gaps which keep queued lending
+// items of the address from being processed.
+func (api *PublicTomoXLendingAPI) GetLendingOrderNonce(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*LendingOrderNonce, error) {
+	return api.t.lendingOrderNonce(address, blockNr)
+}
+
+// GetMarketStats returns the best interest rates, the open interest and, on SDK nodes,
+// the borrow, supply and trade volumes of the last epoch of a lending book.
+func (api *PublicTomoXLendingAPI) GetMarketStats(ctx context.Context, lendingToken common.Address, term uint64) (*MarketStats, error) {
+	return api.t.marketStats(lendingToken, term)
+}
+
+// GetCandles returns the interest rate candles of an interval (1m, 5m, 1h or 1d) of a lending
+// book opened between the from and to unix times, the oldest first. Candles are only recorded by
+// SDK nodes, and a query returns at most 1000 of them.
+func (api *PublicTomoXLendingAPI) GetCandles(ctx context.Context, lendingToken common.Address, term uint64, interval string, from, to uint64) ([]*lendingstate.LendingCandle, error) {
+	return api.t.getCandles(lendingToken, term, interval, time.Unix(int64(from), 0).UTC(), time.Unix(int64(to), 0).UTC())
+}
+
+// GetLiquidationAuctions returns the open liquidation auctions of a lending book along with
+// the current price of their collateral.
+func (api *PublicTomoXLendingAPI) GetLiquidationAuctions(ctx context.Context, lendingToken common.Address, term uint64) ([]*LiquidationAuction, error) {
+	return api.t.liquidationAuctions(lendingToken, term)
+}
+
+// GetInsuranceFund returns the balance of the insurance fund of a lending token, with the sums of
+// its contributions and draws and the bad debt it couldn't cover, as of the current block.
+func (api *PublicTomoXLendingAPI) GetInsuranceFund(ctx context.Context, lendingToken common.Address) (*lendingstate.LendingInsuranceFund, error) {
+	return api.t.insuranceFund(lendingToken)
+}
+
+// GetShortfallEvents returns the liquidation auctions of a lending book which ended with a debt
+// left between the from and to unix times, with the part of it paid by the insurance fund, the
+// oldest first. The events are only recorded by SDK nodes, and a query spans at most 31 days.
+func (api *PublicTomoXLendingAPI) GetShortfallEvents(ctx context.Context, lendingToken common.Address, term uint64, from, to uint64) ([]*lendingstate.LendingInsuranceRecord, error) {
+	return api.t.shortfallEvents(lendingToken, term, time.Unix(int64(from), 0).UTC(), time.Unix(int64(to), 0).UTC())
+}
+
+// GetBadDebt returns the haircuts charged to the open trades of a lending book to socialize the
+// shortfalls the insurance fund couldn't cover, and the claims of the investors of the auctions on
+// them, as of the current block.
+func (api *PublicTomoXLendingAPI) GetBadDebt(ctx context.Context, lendingToken common.Address, term uint64) (*BadDebt, error) {
+	return api.t.badDebt(lendingToken, term)
+}
+
+// GetLendingStateRoot returns the lending state root committed by the given block,
+// selected by number or by hash.
+func (api *PublicTomoXLendingAPI) GetLendingStateRoot(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (common.Hash, error) {
+	block, err := api.t.blockByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	author, err := api.t.chain.Engine().Author(block.Header())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return api.t.GetLendingStateRoot(block, author)
+}
+
+// HasLendingState returns whether the lending state committed by the given block is still
+// available on the node, or has been garbage collected (see --tomox.lendinggcmode and
+// --tomox.lendingstateepochs).
+func (api *PublicTomoXLendingAPI) HasLendingState(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (bool, error) {
+	block, err := api.t.blockByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return false, err
+	}
+	author, err := api.t.chain.Engine().Author(block.Header())
+	if err != nil {
+		return false, err
+	}
+	return api.t.HasLendingState(block, author), nil
+}
+
+// EstimateFees returns the borrowing, investing and relayer fees which would be charged if the
+// lending item matched at its limit interest, with the fee rates and prices of the current block.
+// The item doesn't need to be signed.
+func (api *PublicTomoXLendingAPI) EstimateFees(ctx context.Context, args LendingItemArgs) (*LendingFees, error) {
+	return api.t.estimateFees(&lendingstate.LendingItem{
+		Quantity:        args.Quantity.ToInt(),
+		Interest:        new(big.Int).SetUint64(uint64(args.Interest)),
+		Side:            args.Side,
+		Type:            args.Type,
+		LendingToken:    args.LendingToken,
+		CollateralToken: args.CollateralToken,
+		Term:            uint64(args.Term),
+		Relayer:         args.RelayerAddress,
+		UserAddress:     args.UserAddress,
+	})
+}
+
+// GetTradeCollaterals returns the collaterals backing a lending trade: its main collateral and
+// the extra collaterals added by the borrower, with the liquidation threshold of the trade.
+func (api *PublicTomoXLendingAPI) GetTradeCollaterals(ctx context.Context, lendingToken common.Address, term uint64, tradeId uint64) (*TradeCollaterals, error) {
+	return api.t.tradeCollaterals(lendingToken, term, tradeId)
+}
+
+// GetCircuitBreaker returns the circuit breaker of a lending book: its threshold in basis points
+// and pause in blocks, the reference medium interest and the last block of the current pause.
+func (api *PublicTomoXLendingAPI) GetCircuitBreaker(ctx context.Context, lendingToken common.Address, term uint64) (*lendingstate.LendingCircuitBreaker, error) {
+	return api.t.circuitBreaker(lendingToken, term)
+}
+
+// GetRateModel returns the interest rate model of a lending book set by its relayers, with the
+// utilization of the book and the rate bounding the interest of market items in the current block.
+func (api *PublicTomoXLendingAPI) GetRateModel(ctx context.Context, lendingToken common.Address, term uint64) (*LendingRateQuote, error) {
+	return api.t.rateQuote(lendingToken, term)
+}
+
+// GetPriceOracle returns the price oracle of a collateral token set by the lending moderator, the
+// last reports of its reporters in a lending token and the price it gives for liquidations in the
+// current block.
+func (api *PublicTomoXLendingAPI) GetPriceOracle(ctx context.Context, collateralToken common.Address, lendingToken common.Address) (*PriceOracleQuote, error) {
+	return api.t.priceOracleQuote(collateralToken, lendingToken)
+}
+
+// GetReferrer returns the share of the borrowing fees of a relayer paid to a referrer, zero if the
+// referrer isn't registered with the relayer, and the fees the referrer has been paid in a lending
+// token as of the current block.
+func (api *PublicTomoXLendingAPI) GetReferrer(ctx context.Context, relayer common.Address, referrer common.Address, lendingToken common.Address) (*LendingReferrer, error) {
+	return api.t.referrer(relayer, referrer, lendingToken)
+}
+
+// SimulateOrder matches a lending item against the current lending books as the next block would,
+// on a copy of the current state, and returns the trades it would open with their average interest
+// and fees, and the resulting position of its user. The item doesn't need to be signed, it takes the
+// next lending nonce of its user and a new status by default.
+func (api *PublicTomoXLendingAPI) SimulateOrder(ctx context.Context, args LendingItemArgs) (*SimulatedLendingItem, error) {
+	status := args.Status
+	if status == "" {
+		status = lendingstate.LendingStatusNew
+	}
+	return api.t.simulateOrder(&lendingstate.LendingItem{
+		Quantity:        args.Quantity.ToInt(),
+		Interest:        new(big.Int).SetUint64(uint64(args.Interest)),
+		Side:            args.Side,
+		Type:            args.Type,
+		LendingToken:    args.LendingToken,
+		CollateralToken: args.CollateralToken,
+		AutoTopUp:       args.AutoTopUp,
+		Term:            uint64(args.Term),
+		Relayer:         args.RelayerAddress,
+		UserAddress:     args.UserAddress,
+		Status:          status,
+		LendingId:       uint64(args.LendingId),
+		LendingTradeId:  uint64(args.LendingTradeId),
+		ExtraData:       args.ExtraData,
+	})
+}
+
+// GetAccountRisk returns the risk of an account across the spot and lending books per token as of
+// the current block: its free balance, the balance committed to open spot orders, the collateral
+// locked in lending trades and the part of it which can be recalled, and the resulting margin.
+func (api *PublicTomoXLendingAPI) GetAccountRisk(ctx context.Context, user common.Address) (*AccountRisk, error) {
+	return api.t.accountRisk(user)
+}
+
+// GetLiquidatablePositions returns the open lending trades which the next block repays or
+// liquidates, for keeper bots: the trades due by liquidation time and the trades whose liquidation
+// price is above the current collateral price. At most maxCount positions are returned, up to
+// 1000, a zero count returns the maximum.
+func (api *PublicTomoXLendingAPI) GetLiquidatablePositions(ctx context.Context, maxCount int) ([]*LiquidatablePosition, error) {
+	return api.t.liquidatablePositions(maxCount)
+}
+
+// GetLendingPairs returns the fee rate and the lending pairs registered by a relayer, with the
+// rates of the collaterals they accept, and the lending tokens and terms supported by the lending
+// registration contract, as of the current block.
+func (api *PublicTomoXLendingAPI) GetLendingPairs(ctx context.Context, relayer common.Address) (*LendingPairs, error) {
+	return api.t.getLendingPairs(relayer)
+}
+
+// GetLendingItemsByUser returns a page of the lending items placed by a user, the most recent first.
+// An empty lending token or a zero term matches every lending book and an empty status every status.
+// The optional relayer (exchange address) restricts the page to the items placed through it.
+// Pages are numbered from zero and hold at most 100 items.
+func (api *PublicTomoXLendingAPI) GetLendingItemsByUser(ctx context.Context, user common.Address, lendingToken common.Address, term uint64, status string, page int, limit int, relayer *common.Address) ([]*lendingstate.LendingItem, error) {
+	return api.t.getLendingItemsByUser(user, lendingToken, term, status, relayerFilter(relayer), page, limit)
+}
+
+// GetLendingTradesByUser returns a page of the lending trades of a user as a borrower or an investor,
+// the most recent first, with the same filters as GetLendingItemsByUser. The relayer matches either
+// the borrowing or the investing relayer of a trade.
+func (api *PublicTomoXLendingAPI) GetLendingTradesByUser(ctx context.Context, user common.Address, lendingToken common.Address, term uint64, status string, page int, limit int, relayer *common.Address) ([]*lendingstate.LendingTrade, error) {
+	return api.t.getLendingTradesByUser(user, lendingToken, term, status, relayerFilter(relayer), page, limit)
+}
+
+// GetLendingItem returns a lending item by hash from the SDK database, or from the lending history
+// index on nodes started with --tomox.lendingindex. SDK nodes rebuild the limit items missing from
+// their database from the recent blocks and the lending state, and cache them.
+func (api *PublicTomoXLendingAPI) GetLendingItem(ctx context.Context, hash common.Hash) (*lendingstate.LendingItem, error) {
+	return api.t.getLendingItem(hash)
+}
+
+// IsOrderHashUsed returns whether a lending item with the given hash has been created. The hashes
+// consumed since TIPTomoXLendingReplayProtection are looked up in the lending state, the older ones
+// like GetLendingItem.
+func (api *PublicTomoXLendingAPI) IsOrderHashUsed(ctx context.Context, hash common.Hash) (bool, error) {
+	return api.t.isLendingHashUsed(hash)
+}
+
+// GetLendingTrade returns a lending trade by hash, like GetLendingItem. SDK nodes rebuild the open
+// trades missing from their database from the lending state, and cache them.
+func (api *PublicTomoXLendingAPI) GetLendingTrade(ctx context.Context, hash common.Hash) (*lendingstate.LendingTrade, error) {
+	return api.t.getLendingTrade(hash)
+}
+
+// GetLogs returns the lending logs (trade creation, repayment, top up and liquidation) of the
+// canonical blocks selected by the filter, in the format of eth_getLogs. A query spans at most
+// 10000 blocks.
+func (api *PublicTomoXLendingAPI) GetLogs(ctx context.Context, filter LendingLogsFilter) ([]*types.Log, error) {
+	return api.t.getLendingLogs(filter)
+}
+
+// GetEpochReport returns the settlement report of a checkpoint epoch of the canonical chain: by
+// lending book the matched volume, the interest paid and the liquidations, and the fees charged by
+// each relayer. Reports are only kept by nodes started with --tomox.lendingindex.
+func (api *PublicTomoXLendingAPI) GetEpochReport(ctx context.Context, epoch uint64) (*EpochReport, error) {
+	return api.t.getEpochReport(epoch)
+}
+
+// GetStateDiff returns the changes of the lending books and trades made by a canonical block: the
+// items added to, updated in and removed from the lending books, and the trades opened and closed.
+// Diffs are only kept by nodes started with --tomox.lendingindex.
+func (api *PublicTomoXLendingAPI) GetStateDiff(ctx context.Context, blockNr rpc.BlockNumber) (*LendingStateDiff, error) {
+	return api.t.getStateDiff(blockNr)
+}
+
+// SendLendingItems validates a batch of signed lending items and injects them into the
+// lending pool. Either all of them are added or none, in which case the error reports
+// the index of the first invalid item. It returns the transaction hashes of the items.
+func (api *PublicTomoXLendingAPI) SendLendingItems(ctx context.Context, items []LendingItemArgs) ([]common.Hash, error) {
+	if len(items) == 0 {
+		return nil, ErrNoLendingItems
+	}
+	if len(items) > maxLendingItemsPerCall {
+		return nil, ErrTooManyLendingItems
+	}
+	if api.t.lendingPool == nil {
+		return nil, errLendingStateUnavailable
+	}
+	txs := make([]*types.LendingTransaction, len(items))
+	hashes := make([]common.Hash, len(items))
+	for i := range items {
+		txs[i] = items[i].toTransaction()
+		hashes[i] = txs[i].Hash()
+	}
+	if err := api.t.lendingPool.AddLocalsAtomic(txs); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// ReserveLendingNonce assigns the next lending nonce of an address, to sign a lending item sent
+// within a minute, on nodes run with --tomox.lendingnonces.
+func (api *PublicTomoXLendingAPI) ReserveLendingNonce(ctx context.Context, address common.Address) (hexutil.Uint64, error) {
+	nonce, err := api.t.reserveLendingNonces(address, 1)
+	return hexutil.Uint64(nonce), err
+}
+
+// GetLendingTypedData returns the EIP-712 typed data of a lending item, to be signed by its user
+// with eth_signTypedData_v4 once TIPTomoXLendingEIP712 is active. The signature fields of the item
+// are ignored.
+func (api *PublicTomoXLendingAPI) GetLendingTypedData(ctx context.Context, item LendingItemArgs) (map[string]interface{}, error) {
+	if api.t.chain == nil {
+		return nil, errLendingStateUnavailable
+	}
+	return types.LendingTypedData(item.toTransaction(), api.t.chain.Config().ChainId), nil
+}
+
+// PendingLendingOrders returns the content of the lending pool like txpool_content: the pending
+// and queued lending transactions by account and nonce, optionally restricted to the lending books
+// of a lending token and of a term.
+func (api *PublicTomoXLendingAPI) PendingLendingOrders(ctx context.Context, lendingToken *common.Address, term *hexutil.Uint64) (map[string]map[string]map[string]*RPCLendingTransaction, error) {
+	var t uint64
+	if term != nil {
+		t = uint64(*term)
+	}
+	return api.t.pendingLendingOrders(lendingToken, t)
+}
+
+// NewLendingTrades creates a subscription that is triggered each time a lending trade
+// matching the filter is recorded by the SDK node.
+func (api *PublicTomoXLendingAPI) NewLendingTrades(ctx context.Context, filter LendingFilter) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		queue := newLendingEventQueue(func(ev interface{}) error {
+			return notifier.Notify(rpcSub.ID, ev)
+		})
+		defer queue.Close()
+
+		trades := make(chan *lendingstate.LendingTrade, lendingEventChanSize)
+		tradesSub := api.t.SubscribeLendingTrades(trades)
+		defer tradesSub.Unsubscribe()
+
+		for {
+			select {
+			case t := <-trades:
+				if filter.MatchTrade(t) && !queue.Push(t) && queue.Dropped() == 1 {
+					log.Warn("Dropping lending events of a slow subscriber", "id", rpcSub.ID)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// LendingItemUpdates creates a subscription that is triggered each time a lending item
+// matching the filter is updated by the SDK node.
+func (api *PublicTomoXLendingAPI) LendingItemUpdates(ctx context.Context, filter LendingFilter) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		queue := newLendingEventQueue(func(ev interface{}) error {
+			return notifier.Notify(rpcSub.ID, ev)
+		})
+		defer queue.Close()
+
+		items := make(chan *lendingstate.LendingItem, lendingEventChanSize)
+		itemsSub := api.t.SubscribeLendingItems(items)
+		defer itemsSub.Unsubscribe()
+
+		for {
+			select {
+			case item := <-items:
+				if filter.MatchItem(item) && !queue.Push(item) && queue.Dropped() == 1 {
+					log.Warn("Dropping lending events of a slow subscriber", "id", rpcSub.ID)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Liquidations creates a subscription that is triggered each time a lending trade
+// matching the filter is liquidated.
+func (api *PublicTomoXLendingAPI) Liquidations(ctx context.Context, filter LendingFilter) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		queue := newLendingEventQueue(func(ev interface{}) error {
+			return notifier.Notify(rpcSub.ID, ev)
+		})
+		defer queue.Close()
+
+		trades := make(chan *lendingstate.LendingTrade, lendingEventChanSize)
+		tradesSub := api.t.SubscribeLiquidations(trades)
+		defer tradesSub.Unsubscribe()
+
+		for {
+			select {
+			case t := <-trades:
+				if filter.MatchTrade(t) && !queue.Push(t) && queue.Dropped() == 1 {
+					log.Warn("Dropping lending events of a slow subscriber", "id", rpcSub.ID)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// GetAccruedInterest returns the interest owed so far on an open lending trade at the given block,
+// which is what the borrower has to pay on top of the borrowed amount to repay the trade at that time.
+func (api *PublicTomoXLendingAPI) GetAccruedInterest(ctx context.Context, tradeHash common.Hash, blockNr rpc.BlockNumber) (*AccruedInterest, error) {
+	block, lendingState, err := api.t.lendingStateAt(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	trade, err := api.t.findLendingTrade(block, lendingState, tradeHash)
+	if err != nil {
+		return nil, err
+	}
+	settledInterest := lendingState.GetSettledInterest(lendingstate.GetLendingOrderBookHash(trade.LendingToken, trade.Term), trade.TradeId)
+	return newAccruedInterest(trade, settledInterest, block.NumberU64(), block.Time().Uint64()), nil
+}
+
+// GetPositionHealth returns the health of each open lending trade of the borrower in the given lending book,
+// valued with the collateral price of the current epoch. The optional relayer restricts the result to the
+// trades borrowed through it.
+func (api *PublicTomoXLendingAPI) GetPositionHealth(ctx context.Context, borrower common.Address, lendingBook common.Hash, relayer *common.Address) ([]PositionHealth, error) {
+	l := api.t
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := l.tomox.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := lendingState.DumpLendingTradeTrie(lendingBook)
+	if err != nil {
+		return nil, err
+	}
+	result := []PositionHealth{}
+	for _, trade := range trades {
+		if trade.Borrower != borrower || trade.Amount == nil || trade.Amount.Sign() <= 0 {
+			continue
+		}
+		if !matchRelayer(relayerFilter(relayer), trade.BorrowingRelayer) {
+			continue
+		}
+		health, err := l.positionHealth(block, statedb, tradingState, &trade)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, health)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TradeId < result[j].TradeId
+	})
+	return result, nil
+}
+
+// PrivateTomoXLendingAPI provides the administrative tomoX lending RPC service, which is
+// not exposed publicly.
+type PrivateTomoXLendingAPI struct {
+	t *Lending
+}
+
+// NewPrivateTomoXLendingAPI creates a new administrative RPC tomoX lending service.
+func NewPrivateTomoXLendingAPI(t *Lending) *PrivateTomoXLendingAPI {
+	return &PrivateTomoXLendingAPI{t: t}
+}
+
+// AuditSDKData cross-checks the statuses and filled amounts of the lending items and trades sent
+// in a range of blocks, as recorded in the SDK database, against the current lending state.
+// It reports the divergences caused by missed reorgs or failed bulk commits, and repairs them if
+// requested. An audit spans at most 10000 blocks.
+func (api *PrivateTomoXLendingAPI) AuditSDKData(ctx context.Context, args SDKAuditArgs) (*SDKAuditResult, error) {
+	return api.t.auditSDKData(args)
+}
+
+// CacheStats returns the number of entries, the limit and the hits and misses of each in-memory
+// lending cache.
+func (api *PrivateTomoXLendingAPI) CacheStats(ctx context.Context) []LendingCacheStats {
+	return api.t.CacheStats()
+}
+
+// FlushCaches empties the in-memory lending cache of the given name, or all of them if no name is
+// given, and returns the names of the caches flushed.
+func (api *PrivateTomoXLendingAPI) FlushCaches(ctx context.Context, name *string) ([]string, error) {
+	var n string
+	if name != nil {
+		n = *name
+	}
+	return api.t.FlushCaches(n)
+}
+
+// SubmitLendingItems assigns the nonces of a batch of lending items, on nodes run with
+// --tomox.lendingnonces, signs them with the accounts of their users, which must be unlocked on the
+// node, and injects them into the lending pool. Either all of them are added or none. The nonces
+// and signatures of the items are ignored. It returns the transaction hashes of the items.
+func (api *PrivateTomoXLendingAPI) SubmitLendingItems(ctx context.Context, items []LendingItemArgs) ([]common.Hash, error) {
+	return api.t.submitLendingItems(items)
+}
+
+// SyncState starts downloading from the peers the trading and lending state of a checkpoint
+// block, usually an epoch block, to bootstrap a node without re-executing the order
+// transactions of the chain. The progress is reported by StateSyncProgress.
+func (api *PrivateTomoXLendingAPI) SyncState(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (bool, error) {
+	block, err := api.t.blockByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return false, err
+	}
+	if progress := api.t.StateSyncProgress(); progress != nil && !progress.Done && progress.Error == "" {
+		return false, errStateSyncRunning
+	}
+	go api.t.SyncState(block)
+	return true, nil
+}
+
+// StateSyncProgress returns the progress of the last state sync, nil if none was started.
+func (api *PrivateTomoXLendingAPI) StateSyncProgress(ctx context.Context) *StateSyncProgress {
+	return api.t.StateSyncProgress()
+}
+
+// CancelAllLendingItems cancels every open lending item of a user, optionally restricted to the
+// lending books of a lending token and of a term, so that market makers can pull their quotes at
+// once. The cancellations are signed with the account of the user, which must be unlocked on the
+// node, and injected into the lending pool all together or not at all. Items already being
+// cancelled by a pool transaction are skipped. It returns the transaction hashes of the
+// cancellations.
+func (api *PrivateTomoXLendingAPI) CancelAllLendingItems(ctx context.Context, user common.Address, lendingToken *common.Address, term *hexutil.Uint64) ([]common.Hash, error) {
+	var t uint64
+	if term != nil {
+		t = uint64(*term)
+	}
+	return api.t.cancelAllLendingItems(user, lendingToken, t)
+}
+
+// UpdateLendingRelayer crafts the transaction of the owner of a relayer registering its fee and
+// its lending pairs, which replace all the pairs registered before. The fee is over TomoXBaseFee.
+// The transaction is checked against the current state and returned unsigned, to be sent with
+// eth_sendTransaction.
+func (api *PrivateTomoXLendingAPI) UpdateLendingRelayer(ctx context.Context, coinbase common.Address, fee hexutil.Uint64, pairs []LendingPairArgs) (*GovernanceTx, error) {
+	return api.t.craftGovernanceTx(func(statedb *state.StateDB) (*GovernanceTx, error) {
+		return updateLendingRelayerTx(statedb, coinbase, uint64(fee), pairs)
+	})
+}
+
+// UpdateLendingFee crafts the transaction of the owner of a relayer updating its lending fee,
+// over TomoXBaseFee.
+func (api *PrivateTomoXLendingAPI) UpdateLendingFee(ctx context.Context, coinbase common.Address, fee hexutil.Uint64) (*GovernanceTx, error) {
+	return api.t.craftGovernanceTx(func(statedb *state.StateDB) (*GovernanceTx, error) {
+		return updateLendingFeeTx(statedb, coinbase, uint64(fee))
+	})
+}
+
+// AddLendingToken crafts the transaction of the moderator allowing a token to be lent.
+func (api *PrivateTomoXLendingAPI) AddLendingToken(ctx context.Context, token common.Address) (*GovernanceTx, error) {
+	return api.t.craftGovernanceTx(func(statedb *state.StateDB) (*GovernanceTx, error) {
+		return addLendingTokenTx(statedb, token)
+	})
+}
+
+// AddLendingTerm crafts the transaction of the moderator allowing a term, in seconds.
+func (api *PrivateTomoXLendingAPI) AddLendingTerm(ctx context.Context, term hexutil.Uint64) (*GovernanceTx, error) {
+	return api.t.craftGovernanceTx(func(statedb *state.StateDB) (*GovernanceTx, error) {
+		return addLendingTermTx(statedb, uint64(term))
+	})
+}
+
+// SetCollateral crafts the transaction of the moderator adding a collateral or updating its
+// deposit, liquidation and recall rates, in percent.
+func (api *PrivateTomoXLendingAPI) SetCollateral(ctx context.Context, token common.Address, depositRate, liquidationRate, recallRate hexutil.Uint64) (*GovernanceTx, error) {
+	return api.t.craftGovernanceTx(func(statedb *state.StateDB) (*GovernanceTx, error) {
+		return setCollateralTx(statedb, token, uint64(depositRate), uint64(liquidationRate), uint64(recallRate))
+	})
+}
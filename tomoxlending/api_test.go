@@ -0,0 +1,126 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestNewAccruedInterest(t *testing.T) {
+	startTime := uint64(1600000000)
+	trade := &lendingstate.LendingTrade{
+		TradeId:         1,
+		Amount:          big.NewInt(1000),
+		Interest:        new(big.Int).Mul(big.NewInt(10), common.BaseLendingInterest).Uint64(), // 10% per year
+		Term:            common.OneYear,
+		LiquidationTime: startTime + common.OneYear,
+	}
+	// the borrower pays interest for (term + elapsed time) / 2: 75% of a year after half a year
+	accrued := newAccruedInterest(trade, new(big.Int), 10, startTime+common.OneYear/2)
+	if accrued.ElapsedTime != common.OneYear/2 {
+		t.Fatalf("wrong elapsed time: have %d, want %d", accrued.ElapsedTime, common.OneYear/2)
+	}
+	if accrued.AccruedInterest.Cmp(big.NewInt(75)) != 0 {
+		t.Fatalf("wrong accrued interest: have %v, want 75", accrued.AccruedInterest)
+	}
+	if accrued.TotalRepayValue.Cmp(big.NewInt(1075)) != 0 {
+		t.Fatalf("wrong total repay value: have %v, want 1075", accrued.TotalRepayValue)
+	}
+	// half a year of periodic interest has been settled already
+	accrued = newAccruedInterest(trade, big.NewInt(50), 10, startTime+common.OneYear/2)
+	if accrued.AccruedInterest.Cmp(big.NewInt(25)) != 0 || accrued.TotalRepayValue.Cmp(big.NewInt(1025)) != 0 {
+		t.Fatalf("wrong accrued interest after settlement: have %v, total %v", accrued.AccruedInterest, accrued.TotalRepayValue)
+	}
+}
+
+func TestMarketStatsVolume(t *testing.T) {
+	items := []*lendingstate.LendingItem{
+		{Side: lendingstate.Borrowing, Quantity: big.NewInt(10)},
+		{Side: lendingstate.Borrowing, Quantity: big.NewInt(5)},
+		{Side: lendingstate.Investing, Quantity: big.NewInt(7)},
+		{Type: lendingstate.TopUpReserve, Quantity: big.NewInt(100)},
+	}
+	trades := []*lendingstate.LendingTrade{
+		{Amount: big.NewInt(3)},
+		{Amount: big.NewInt(4)},
+	}
+	stats := &MarketStats{}
+	stats.addLendingVolume(items, trades)
+	if stats.BorrowVolume.Cmp(big.NewInt(15)) != 0 {
+		t.Errorf("wrong borrow volume: have %v, want 15", stats.BorrowVolume)
+	}
+	if stats.SupplyVolume.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("wrong supply volume: have %v, want 7", stats.SupplyVolume)
+	}
+	if stats.TradeVolume.Cmp(big.NewInt(7)) != 0 || stats.TradeCount != 2 {
+		t.Errorf("wrong trade volume: have %v in %d trades, want 7 in 2 trades", stats.TradeVolume, stats.TradeCount)
+	}
+}
+
+func TestNonceGaps(t *testing.T) {
+	tests := []struct {
+		next   uint64
+		queued []uint64
+		want   []NonceGap
+	}{
+		{5, nil, []NonceGap{}},
+		{5, []uint64{7}, []NonceGap{{From: 5, To: 6}}},
+		{5, []uint64{9, 6, 7}, []NonceGap{{From: 5, To: 5}, {From: 8, To: 8}}},
+		{5, []uint64{3, 5, 6}, []NonceGap{}},
+	}
+	for i, tt := range tests {
+		if have := nonceGaps(tt.next, tt.queued); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestEstimateLendingFees(t *testing.T) {
+	decimal := big.NewInt(1000000000000000000)
+	item := &lendingstate.LendingItem{
+		Quantity:        new(big.Int).Mul(big.NewInt(1000), decimal),
+		Interest:        new(big.Int).Mul(big.NewInt(10), common.BaseLendingInterest), // 10% per year
+		Term:            common.OneYear,
+		Side:            lendingstate.Investing,
+		LendingToken:    common.HexToAddress(common.TomoNativeAddress),
+		CollateralToken: common.HexToAddress("0x0000000000000000000000000000000000000002"),
+	}
+	feeRate := big.NewInt(10) // 0.1%
+	// collateral worth 2 lending tokens, deposit rate 150%
+	fees, err := estimateLendingFees(true, item, feeRate, common.BasePrice, new(big.Int).Mul(big.NewInt(2), decimal), big.NewInt(150), decimal, decimal)
+	if err != nil {
+		t.Fatalf("failed to estimate fees: %v", err)
+	}
+	if want := new(big.Int).Mul(big.NewInt(1), decimal); fees.BorrowingFee.Cmp(want) != 0 {
+		t.Errorf("wrong borrowing fee: have %v, want %v", fees.BorrowingFee, want)
+	}
+	if fees.InvestingFee.Sign() != 0 {
+		t.Errorf("wrong investing fee: have %v, want 0", fees.InvestingFee)
+	}
+	if fees.RelayerFee.Cmp(common.RelayerLendingFee) != 0 {
+		t.Errorf("wrong relayer fee: have %v, want %v", fees.RelayerFee, common.RelayerLendingFee)
+	}
+	if want := new(big.Int).Mul(big.NewInt(750), decimal); fees.CollateralLockedAmount.Cmp(want) != 0 {
+		t.Errorf("wrong collateral locked amount: have %v, want %v", fees.CollateralLockedAmount, want)
+	}
+	if want := new(big.Int).Mul(big.NewInt(100), decimal); fees.InterestAmount.Cmp(want) != 0 {
+		t.Errorf("wrong interest amount: have %v, want %v", fees.InterestAmount, want)
+	}
+
+	// an investing item doesn't know the collateral of its borrower
+	fees, err = estimateLendingFees(true, item, feeRate, common.BasePrice, nil, nil, decimal, nil)
+	if err != nil {
+		t.Fatalf("failed to estimate fees without collateral: %v", err)
+	}
+	if fees.CollateralLockedAmount != nil || fees.BorrowingFee.Cmp(decimal) != 0 {
+		t.Errorf("wrong fees without collateral: %+v", fees)
+	}
+
+	item.Quantity = big.NewInt(1000)
+	if _, err := estimateLendingFees(true, item, feeRate, common.BasePrice, nil, nil, decimal, nil); err != lendingstate.ErrQuantityTradeTooSmall {
+		t.Errorf("wrong error for a small quantity: have %v, want %v", err, lendingstate.ErrQuantityTradeTooSmall)
+	}
+}
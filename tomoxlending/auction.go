@@ -0,0 +1,345 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Since TIPTomoXLendingV2 the collateral of a liquidated trade is not seized at once: a Dutch auction
+// is opened in the auction book of the lending book (see lendingstate.GetLendingAuctionBookHash).
+// The auction is stored as a lending trade with the id of the liquidated trade where
+//   - Amount is the debt still owed to the investor, in lending token
+//   - CollateralLockedAmount is the collateral still for sale, locked in LendingLockAddress
+//   - CollateralPrice is the start price and LiquidationPrice the floor price of the auction
+//   - LiquidationTime is the end of the auction, also indexed in the liquidation time trie of the auction book
+//
+// AuctionBid lending items buy collateral at the current auction price, the payment goes to the investor.
+// Once the debt is paid, the remaining collateral goes back to the borrower. Expired auctions are
+// settled by ProcessLiquidationData: the unsold collateral is seized in favor of the investor.
+// An auction ending with a debt left draws the shortfall from the insurance fund, see insurance.go,
+// and socializes what the fund can't cover, see socialization.go.
+
+// AuctionBidData is recorded in the ExtraData of an auction after a bid.
+type AuctionBidData struct {
+	Bidder     common.Address
+	Collateral *big.Int // collateral bought
+	Price      *big.Int // auction price
+	Paid       *big.Int // lending token paid to the investor
+	// debt left once the collateral is sold out, and the part of it paid by the insurance fund
+	Shortfall     *big.Int `json:",omitempty"`
+	InsuranceDraw *big.Int `json:",omitempty"`
+	// part of the shortfall charged to the investors of the lending book, see socialization.go
+	Socialized *big.Int                    `json:",omitempty"`
+	Haircuts   []lendingstate.TradeHaircut `json:",omitempty"`
+}
+
+// auctionPrice returns the price of the collateral of an auction at the given time, it decreases
+// linearly from the start price to the floor price.
+func auctionPrice(auction *lendingstate.LendingTrade, time uint64) *big.Int {
+	startTime := auction.LiquidationTime - common.LendingAuctionDuration
+	if time <= startTime {
+		return new(big.Int).Set(auction.CollateralPrice)
+	}
+	if time >= auction.LiquidationTime {
+		return new(big.Int).Set(auction.LiquidationPrice)
+	}
+	// price = startPrice - (startPrice - floorPrice) * elapsed / duration
+	decrease := new(big.Int).Sub(auction.CollateralPrice, auction.LiquidationPrice)
+	decrease = new(big.Int).Mul(decrease, new(big.Int).SetUint64(time-startTime))
+	decrease = new(big.Int).Div(decrease, new(big.Int).SetUint64(common.LendingAuctionDuration))
+	return new(big.Int).Sub(auction.CollateralPrice, decrease)
+}
+
+// openLiquidationAuction closes a lending trade to be liquidated and puts its collateral on sale
+// in a liquidation auction starting from the given collateral price. It returns the trade with
+// status AUCTION.
+func (l *Lending) openLiquidationAuction(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, collateralPrice *big.Int, reason uint64) (*lendingstate.LendingTrade, error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade.TradeId != lendingTradeId {
+		return nil, fmt.Errorf("Lending Trade Id not found : %d ", lendingTradeId)
+	}
+	time := header.Time.Uint64()
+	debt := lendingStateDB.GetTotalRepayValue(lendingBook, &lendingTrade, time)
+
+	if err := lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime); err != nil {
+		log.Debug("openLiquidationAuction RemoveLiquidationTime", "err", err)
+		return nil, err
+	}
+	if err := tradingStateDB.RemoveLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId); err != nil {
+		log.Debug("openLiquidationAuction RemoveLiquidationPrice", "err", err)
+		return nil, err
+	}
+	if err := lendingStateDB.CancelLendingTrade(lendingBook, lendingTradeId); err != nil {
+		log.Debug("openLiquidationAuction CancelLendingTrade", "err", err)
+		return nil, err
+	}
+	// only the main collateral is auctioned, the extra collaterals are seized at once
+	releaseTradeCollaterals(lendingStateDB, statedb, lendingBook, lendingTradeId, lendingTrade.Investor)
+	forfeitHaircut(lendingStateDB, lendingBook, lendingTradeId)
+	clearSettledInterest(lendingStateDB, lendingBook, lendingTradeId)
+	liquidateMarginPosition(lendingStateDB, tradingStateDB, lendingBook, lendingTradeId)
+
+	// the collateral stays locked until it is sold
+	auction := lendingTrade
+	auction.Amount = debt
+	auction.CollateralPrice = new(big.Int).Div(new(big.Int).Mul(collateralPrice, common.LendingAuctionStartRate), common.BaseLendingAuction)
+	auction.LiquidationPrice = new(big.Int).Div(new(big.Int).Mul(collateralPrice, common.LendingAuctionFloorRate), common.BaseLendingAuction)
+	auction.LiquidationTime = time + common.LendingAuctionDuration
+	auction.Status = lendingstate.TradeStatusAuction
+	auctionBook := lendingstate.GetLendingAuctionBookHash(lendingBook)
+	lendingStateDB.InsertTradingItem(auctionBook, lendingTradeId, auction)
+	lendingStateDB.InsertLiquidationTime(auctionBook, new(big.Int).SetUint64(auction.LiquidationTime), lendingTradeId)
+	log.Debug("Open liquidation auction", "lendingBook", lendingBook.Hex(), "tradeId", lendingTradeId, "debt", debt, "collateral", auction.CollateralLockedAmount, "startPrice", auction.CollateralPrice, "floorPrice", auction.LiquidationPrice)
+
+	lendingTrade.Status = lendingstate.TradeStatusAuction
+	extraData, _ := json.Marshal(lendingstate.LiquidationData{
+		RecallAmount:      common.Big0,
+		LiquidationAmount: common.Big0,
+		CollateralPrice:   collateralPrice,
+		Reason:            reason,
+	})
+	lendingTrade.ExtraData = string(extraData)
+	return &lendingTrade, nil
+}
+
+// closeLiquidationAuction removes an auction from the auction book.
+func closeLiquidationAuction(lendingStateDB *lendingstate.LendingStateDB, auctionBook common.Hash, auction *lendingstate.LendingTrade) error {
+	if err := lendingStateDB.RemoveLiquidationTime(auctionBook, auction.TradeId, auction.LiquidationTime); err != nil {
+		return err
+	}
+	return lendingStateDB.CancelLendingTrade(auctionBook, auction.TradeId)
+}
+
+// ProcessAuctionBid buys collateral from the liquidation auction of the trade order.LendingTradeId at the
+// current auction price. At most order.Quantity collateral is bought, and no more than needed to pay the debt.
+// It returns the auction after the bid, with status LIQUIDATED if the auction is over.
+func (l *Lending) ProcessAuctionBid(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash, order *lendingstate.LendingItem) (*lendingstate.LendingTrade, error) {
+	auctionBook := lendingstate.GetLendingAuctionBookHash(lendingBook)
+	auction := lendingStateDB.GetLendingTrade(auctionBook, common.Uint64ToHash(order.LendingTradeId))
+	if auction == lendingstate.EmptyLendingTrade || auction.Status != lendingstate.TradeStatusAuction {
+		return nil, fmt.Errorf("liquidation auction not found. lendingTradeId: %d", order.LendingTradeId)
+	}
+	time := header.Time.Uint64()
+	if time >= auction.LiquidationTime {
+		return nil, fmt.Errorf("liquidation auction expired. lendingTradeId: %d", order.LendingTradeId)
+	}
+	collateralTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, auction.CollateralToken)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get tokenDecimal. Token: %v . Err: %v", auction.CollateralToken.String(), err)
+	}
+	price := auctionPrice(&auction, time)
+	quantity := order.Quantity
+	if quantity.Cmp(auction.CollateralLockedAmount) > 0 {
+		quantity = auction.CollateralLockedAmount
+	}
+	// paid = quantity * price / collateralTokenDecimal
+	paid := new(big.Int).Div(new(big.Int).Mul(quantity, price), collateralTokenDecimal)
+	if paid.Cmp(auction.Amount) > 0 {
+		paid = auction.Amount
+		quantity = new(big.Int).Div(new(big.Int).Mul(paid, collateralTokenDecimal), price)
+	}
+	if quantity.Sign() <= 0 || paid.Sign() <= 0 {
+		return nil, fmt.Errorf("auction bid too small. quantity: %v . price: %v", order.Quantity, price)
+	}
+	if balance := lendingstate.GetTokenBalance(order.UserAddress, auction.LendingToken, statedb); balance.Cmp(paid) < 0 {
+		return nil, fmt.Errorf("not enough balance to bid. need: %v . have: %v . Token: %s", paid, balance, auction.LendingToken.Hex())
+	}
+	lendingstate.SubTokenBalance(order.UserAddress, paid, auction.LendingToken, statedb)
+	lendingstate.AddTokenBalance(auction.Investor, paid, auction.LendingToken, statedb)
+	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), quantity, auction.CollateralToken, statedb)
+	lendingstate.AddTokenBalance(order.UserAddress, quantity, auction.CollateralToken, statedb)
+
+	debt := new(big.Int).Sub(auction.Amount, paid)
+	collateral := new(big.Int).Sub(auction.CollateralLockedAmount, quantity)
+	var (
+		shortfall, drawn, socialized *big.Int
+		haircuts                     []lendingstate.TradeHaircut
+	)
+	if debt.Sign() > 0 && collateral.Sign() == 0 {
+		shortfall = debt
+		if drawn, haircuts, socialized, err = resolveShortfall(lendingStateDB, statedb, lendingBook, &auction, shortfall); err != nil {
+			return nil, err
+		}
+	}
+	if debt.Sign() == 0 || collateral.Sign() == 0 {
+		// the investor has been paid back, or there is nothing left to sell
+		if collateral.Sign() > 0 {
+			lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), collateral, auction.CollateralToken, statedb)
+			lendingstate.AddTokenBalance(auction.Borrower, collateral, auction.CollateralToken, statedb)
+		}
+		if err := closeLiquidationAuction(lendingStateDB, auctionBook, &auction); err != nil {
+			return nil, err
+		}
+		auction.Status = lendingstate.TradeStatusLiquidated
+	} else {
+		lendingStateDB.UpdateLendingTradeAmount(auctionBook, auction.TradeId, debt)
+		lendingStateDB.UpdateCollateralLockedAmount(auctionBook, auction.TradeId, collateral)
+	}
+	log.Debug("ProcessAuctionBid", "bidder", order.UserAddress.Hex(), "tradeId", auction.TradeId, "price", price, "collateral", quantity, "paid", paid, "debt", debt)
+	auction.Amount = debt
+	auction.CollateralLockedAmount = collateral
+	extraData, _ := json.Marshal(AuctionBidData{
+		Bidder:        order.UserAddress,
+		Collateral:    quantity,
+		Price:         price,
+		Paid:          paid,
+		Shortfall:     shortfall,
+		InsuranceDraw: drawn,
+		Socialized:    socialized,
+		Haircuts:      haircuts,
+	})
+	auction.ExtraData = string(extraData)
+	return &auction, nil
+}
+
+// settleExpiredAuctions seizes the unsold collateral of the expired liquidation auctions of a lending book
+// in favor of the investors, along with the shortfall of the debt over the collateral valued at the floor
+// price, drawn from the insurance fund. It returns the settled auctions.
+func (l *Lending) settleExpiredAuctions(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash) ([]*lendingstate.LendingTrade, error) {
+	settled := []*lendingstate.LendingTrade{}
+	auctionBook := lendingstate.GetLendingAuctionBookHash(lendingBook)
+	if !lendingStateDB.Exist(auctionBook) {
+		return settled, nil
+	}
+	time := header.Time
+	lowestTime, tradeIds := lendingStateDB.GetLowestLiquidationTime(auctionBook, time)
+	for lowestTime.Sign() > 0 && lowestTime.Cmp(time) <= 0 {
+		for _, tradeIdHash := range tradeIds {
+			auction := lendingStateDB.GetLendingTrade(auctionBook, tradeIdHash)
+			if auction == lendingstate.EmptyLendingTrade {
+				return settled, fmt.Errorf("liquidation auction not found. lendingTradeId: %s", tradeIdHash.Hex())
+			}
+			shortfall, err := l.auctionShortfall(chain, statedb, &auction)
+			if err != nil {
+				return settled, err
+			}
+			var (
+				drawn, socialized *big.Int
+				haircuts          []lendingstate.TradeHaircut
+			)
+			if shortfall.Sign() > 0 {
+				if drawn, haircuts, socialized, err = resolveShortfall(lendingStateDB, statedb, lendingBook, &auction, shortfall); err != nil {
+					return settled, err
+				}
+			} else {
+				shortfall = nil
+			}
+			lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), auction.CollateralLockedAmount, auction.CollateralToken, statedb)
+			lendingstate.AddTokenBalance(auction.Investor, auction.CollateralLockedAmount, auction.CollateralToken, statedb)
+			if err := closeLiquidationAuction(lendingStateDB, auctionBook, &auction); err != nil {
+				return settled, err
+			}
+			log.Debug("Settle expired liquidation auction", "lendingBook", lendingBook.Hex(), "tradeId", auction.TradeId, "collateral", auction.CollateralLockedAmount, "debt", auction.Amount, "shortfall", shortfall, "insuranceDraw", drawn, "socialized", socialized)
+			auction.Status = lendingstate.TradeStatusLiquidated
+			extraData, _ := json.Marshal(lendingstate.LiquidationData{
+				RecallAmount:      common.Big0,
+				LiquidationAmount: auction.CollateralLockedAmount,
+				CollateralPrice:   auction.LiquidationPrice,
+				Reason:            lendingstate.LiquidatedByAuction,
+				Shortfall:         shortfall,
+				InsuranceDraw:     drawn,
+				Socialized:        socialized,
+				Haircuts:          haircuts,
+			})
+			auction.ExtraData = string(extraData)
+			settled = append(settled, &auction)
+		}
+		lowestTime, tradeIds = lendingStateDB.GetLowestLiquidationTime(auctionBook, time)
+	}
+	return settled, nil
+}
+
+// auctionShortfall returns the debt of an expired auction left over its unsold collateral valued
+// at the floor price, zero if the collateral covers the debt.
+func (l *Lending) auctionShortfall(chain consensus.ChainContext, statedb *state.StateDB, auction *lendingstate.LendingTrade) (*big.Int, error) {
+	if auction.Amount == nil || auction.Amount.Sign() <= 0 {
+		return new(big.Int), nil
+	}
+	value := new(big.Int)
+	if auction.CollateralLockedAmount != nil && auction.CollateralLockedAmount.Sign() > 0 {
+		collateralTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, auction.CollateralToken)
+		if err != nil {
+			return nil, fmt.Errorf("fail to get tokenDecimal. Token: %v . Err: %v", auction.CollateralToken.String(), err)
+		}
+		// value = collateral * floorPrice / collateralTokenDecimal
+		value = new(big.Int).Div(new(big.Int).Mul(auction.CollateralLockedAmount, auction.LiquidationPrice), collateralTokenDecimal)
+	}
+	if value.Cmp(auction.Amount) >= 0 {
+		return new(big.Int), nil
+	}
+	return new(big.Int).Sub(auction.Amount, value), nil
+}
+
+// LiquidationAuction is an open liquidation auction as returned by the RPC API.
+type LiquidationAuction struct {
+	TradeId         uint64         `json:"tradeId"`
+	Hash            common.Hash    `json:"hash"`
+	Borrower        common.Address `json:"borrower"`
+	Investor        common.Address `json:"investor"`
+	LendingToken    common.Address `json:"lendingToken"`
+	CollateralToken common.Address `json:"collateralToken"`
+	Term            uint64         `json:"term"`
+	Debt            *big.Int       `json:"debt"`
+	Collateral      *big.Int       `json:"collateral"`
+	StartPrice      *big.Int       `json:"startPrice"`
+	FloorPrice      *big.Int       `json:"floorPrice"`
+	CurrentPrice    *big.Int       `json:"currentPrice"`
+	StartTime       uint64         `json:"startTime"`
+	EndTime         uint64         `json:"endTime"`
+}
+
+func newLiquidationAuction(auction *lendingstate.LendingTrade, time uint64) *LiquidationAuction {
+	return &LiquidationAuction{
+		TradeId:         auction.TradeId,
+		Hash:            auction.Hash,
+		Borrower:        auction.Borrower,
+		Investor:        auction.Investor,
+		LendingToken:    auction.LendingToken,
+		CollateralToken: auction.CollateralToken,
+		Term:            auction.Term,
+		Debt:            auction.Amount,
+		Collateral:      auction.CollateralLockedAmount,
+		StartPrice:      auction.CollateralPrice,
+		FloorPrice:      auction.LiquidationPrice,
+		CurrentPrice:    auctionPrice(auction, time),
+		StartTime:       auction.LiquidationTime - common.LendingAuctionDuration,
+		EndTime:         auction.LiquidationTime,
+	}
+}
+
+// liquidationAuctions returns the open liquidation auctions of a lending book at the current block.
+func (l *Lending) liquidationAuctions(lendingToken common.Address, term uint64) ([]*LiquidationAuction, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	result := []*LiquidationAuction{}
+	auctionBook := lendingstate.GetLendingAuctionBookHash(lendingstate.GetLendingOrderBookHash(lendingToken, term))
+	if !lendingState.Exist(auctionBook) {
+		return result, nil
+	}
+	auctions, err := lendingState.DumpLendingTradeTrie(auctionBook)
+	if err != nil {
+		return nil, err
+	}
+	for _, auction := range auctions {
+		if auction.Amount == nil || auction.Amount.Sign() == 0 {
+			continue
+		}
+		result = append(result, newLiquidationAuction(&auction, block.Time().Uint64()))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TradeId < result[j].TradeId
+	})
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestAuctionPrice(t *testing.T) {
+	endTime := uint64(1600000000)
+	auction := &lendingstate.LendingTrade{
+		CollateralPrice:  big.NewInt(1100),
+		LiquidationPrice: big.NewInt(700),
+		LiquidationTime:  endTime,
+	}
+	startTime := endTime - common.LendingAuctionDuration
+	tests := []struct {
+		time  uint64
+		price int64
+	}{
+		{startTime - 1, 1100},
+		{startTime, 1100},
+		{startTime + common.LendingAuctionDuration/4, 1000},
+		{startTime + common.LendingAuctionDuration/2, 900},
+		{endTime, 700},
+		{endTime + 1, 700},
+	}
+	for i, test := range tests {
+		if price := auctionPrice(auction, test.time); price.Cmp(big.NewInt(test.price)) != 0 {
+			t.Errorf("test %d: wrong auction price: have %v, want %d", i, price, test.price)
+		}
+	}
+}
+
+func TestSettleExpiredAuctions(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+
+	var (
+		collateral  = common.HexToAddress(common.TomoNativeAddress)
+		lockAddress = common.HexToAddress(common.LendingLockAddress)
+		investor    = common.HexToAddress("0x1")
+		lendingBook = lendingstate.GetLendingOrderBookHash(common.HexToAddress("0x2"), 86400)
+		auctionBook = lendingstate.GetLendingAuctionBookHash(lendingBook)
+		endTime     = uint64(1600000000)
+	)
+	auction := lendingstate.LendingTrade{
+		TradeId:                1,
+		Investor:               investor,
+		CollateralToken:        collateral,
+		Amount:                 big.NewInt(1000),
+		CollateralLockedAmount: big.NewInt(500),
+		CollateralPrice:        big.NewInt(1100),
+		LiquidationPrice:       big.NewInt(700),
+		LiquidationTime:        endTime,
+		Status:                 lendingstate.TradeStatusAuction,
+	}
+	lendingStateDB.InsertTradingItem(auctionBook, auction.TradeId, auction)
+	lendingStateDB.InsertLiquidationTime(auctionBook, new(big.Int).SetUint64(endTime), auction.TradeId)
+	statedb.AddBalance(lockAddress, big.NewInt(500))
+
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	settled, err := l.settleExpiredAuctions(&types.Header{Time: new(big.Int).SetUint64(endTime - 1)}, nil, lendingStateDB, statedb, lendingBook)
+	if err != nil {
+		t.Fatalf("failed to settle auctions: %v", err)
+	}
+	if len(settled) != 0 {
+		t.Fatalf("settled an auction before its end")
+	}
+	settled, err = l.settleExpiredAuctions(&types.Header{Time: new(big.Int).SetUint64(endTime)}, nil, lendingStateDB, statedb, lendingBook)
+	if err != nil {
+		t.Fatalf("failed to settle auctions: %v", err)
+	}
+	if len(settled) != 1 || settled[0].Status != lendingstate.TradeStatusLiquidated {
+		t.Fatalf("wrong settled auctions: %v", settled)
+	}
+	if balance := statedb.GetBalance(investor); balance.Cmp(big.NewInt(500)) != 0 {
+		t.Errorf("wrong investor balance: have %v, want 500", balance)
+	}
+	if balance := statedb.GetBalance(lockAddress); balance.Sign() != 0 {
+		t.Errorf("wrong locked balance: have %v, want 0", balance)
+	}
+	if trade := lendingStateDB.GetLendingTrade(auctionBook, common.Uint64ToHash(auction.TradeId)); trade != lendingstate.EmptyLendingTrade {
+		t.Errorf("auction still open after settlement: %v", trade)
+	}
+}
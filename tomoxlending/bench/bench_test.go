@@ -0,0 +1,185 @@
+package bench
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// pairQuantity is the quantity of the trades of the liquidation sweeps.
+var pairQuantity = new(big.Int).Mul(big.NewInt(10), common.BasePrice)
+
+func newTestEnv(tb testing.TB) (*Env, *Flow) {
+	env, err := NewEnv(tb.TempDir())
+	if err != nil {
+		tb.Fatalf("failed to create environment: %v", err)
+	}
+	flow, err := NewFlow(env, DefaultFlowConfig)
+	if err != nil {
+		tb.Fatalf("failed to create flow: %v", err)
+	}
+	return env, flow
+}
+
+func TestFlowReproducible(t *testing.T) {
+	_, flow1 := newTestEnv(t)
+	_, flow2 := newTestEnv(t)
+	items1, err := flow1.Items(50)
+	if err != nil {
+		t.Fatalf("failed to generate items: %v", err)
+	}
+	items2, err := flow2.Items(50)
+	if err != nil {
+		t.Fatalf("failed to generate items: %v", err)
+	}
+	for i := range items1 {
+		if items1[i].Hash != items2[i].Hash || *items1[i].Signature != *items2[i].Signature {
+			t.Fatalf("item %d differs between flows of the same seed", i)
+		}
+	}
+}
+
+func TestLiquidationSweep(t *testing.T) {
+	env, flow := newTestEnv(t)
+	items, err := flow.Pairs(10, pairQuantity)
+	if err != nil {
+		t.Fatalf("failed to generate items: %v", err)
+	}
+	for _, item := range items {
+		trades, rejects, err := env.CommitOrder(item)
+		if err != nil || len(rejects) > 0 {
+			t.Fatalf("failed to commit item: err %v, rejects %d", err, len(rejects))
+		}
+		if item.Side == lendingstate.Borrowing && len(trades) != 1 {
+			t.Fatalf("wrong number of trades: have %d, want 1", len(trades))
+		}
+	}
+	env.SetCollateralPrice(new(big.Int).Div(CollateralPrice, big.NewInt(2)))
+	if err := env.Commit(); err != nil {
+		t.Fatalf("failed to commit states: %v", err)
+	}
+	sweep, err := env.Fork()
+	if err != nil {
+		t.Fatalf("failed to fork environment: %v", err)
+	}
+	_, liquidated, _, _, _, err := sweep.Lending.ProcessLiquidationData(sweep.Header, sweep.Chain, sweep.Statedb, sweep.TradingState, sweep.LendingState)
+	if err != nil {
+		t.Fatalf("failed to process liquidations: %v", err)
+	}
+	if len(liquidated) != 10 {
+		t.Fatalf("wrong number of liquidated trades: have %d, want 10", len(liquidated))
+	}
+}
+
+func BenchmarkCommitOrder(b *testing.B) {
+	env, flow := newTestEnv(b)
+	items, err := flow.Items(b.N)
+	if err != nil {
+		b.Fatalf("failed to generate items: %v", err)
+	}
+	var trades int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for _, item := range items {
+		newTrades, _, err := env.CommitOrder(item)
+		if err != nil {
+			b.Fatalf("failed to commit item: %v", err)
+		}
+		trades += len(newTrades)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(trades)/float64(b.N), "trades/op")
+}
+
+// benchmarkBookDepths are the numbers of items resting in the lending book before the
+// orderbook benchmarks.
+var benchmarkBookDepths = []int{0, 1000, 10000}
+
+// fillBook inserts items into the lending book of an environment without matching them, giving
+// them the next lending ids of the book.
+func fillBook(env *Env, items []*lendingstate.LendingItem) {
+	for _, item := range items {
+		id := env.LendingState.GetNonce(env.LendingBook) + 1
+		item.LendingId = id
+		env.LendingState.SetNonce(env.LendingBook, id)
+		env.LendingState.InsertLendingItem(env.LendingBook, common.BigToHash(new(big.Int).SetUint64(id)), *item)
+	}
+}
+
+func BenchmarkOrderbookInsert(b *testing.B) {
+	for _, depth := range benchmarkBookDepths {
+		b.Run(fmt.Sprintf("depth-%d", depth), func(b *testing.B) {
+			env, flow := newTestEnv(b)
+			items, err := flow.Items(depth + b.N)
+			if err != nil {
+				b.Fatalf("failed to generate items: %v", err)
+			}
+			fillBook(env, items[:depth])
+			b.ReportAllocs()
+			b.ResetTimer()
+			fillBook(env, items[depth:])
+		})
+	}
+}
+
+func BenchmarkOrderbookDelete(b *testing.B) {
+	for _, depth := range benchmarkBookDepths {
+		b.Run(fmt.Sprintf("depth-%d", depth), func(b *testing.B) {
+			env, flow := newTestEnv(b)
+			items, err := flow.Items(depth + b.N)
+			if err != nil {
+				b.Fatalf("failed to generate items: %v", err)
+			}
+			fillBook(env, items)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for _, item := range items[depth:] {
+				if err := env.LendingState.CancelLendingOrder(env.LendingBook, item); err != nil {
+					b.Fatalf("failed to cancel item: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLiquidationSweep(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("trades-%d", size), func(b *testing.B) {
+			env, flow := newTestEnv(b)
+			items, err := flow.Pairs(size, pairQuantity)
+			if err != nil {
+				b.Fatalf("failed to generate items: %v", err)
+			}
+			for _, item := range items {
+				if _, _, err := env.CommitOrder(item); err != nil {
+					b.Fatalf("failed to commit item: %v", err)
+				}
+			}
+			// every trade is liquidated once the collateral lost half of its price
+			env.SetCollateralPrice(new(big.Int).Div(CollateralPrice, big.NewInt(2)))
+			if err := env.Commit(); err != nil {
+				b.Fatalf("failed to commit states: %v", err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				sweep, err := env.Fork()
+				if err != nil {
+					b.Fatalf("failed to fork environment: %v", err)
+				}
+				b.StartTimer()
+				_, liquidated, _, _, _, err := sweep.Lending.ProcessLiquidationData(sweep.Header, sweep.Chain, sweep.Statedb, sweep.TradingState, sweep.LendingState)
+				if err != nil {
+					b.Fatalf("failed to process liquidations: %v", err)
+				}
+				if len(liquidated) != size {
+					b.Fatalf("wrong number of liquidated trades: have %d, want %d", len(liquidated), size)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package bench
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// maxFuzzOps bounds the number of operations of a fuzzed sequence.
+const maxFuzzOps = 64
+
+// instance is an independent matching engine, with its own databases, fed with a flow of the
+// same seed as the other instances.
+type instance struct {
+	env     *Env
+	flow    *Flow
+	resting []*lendingstate.LendingItem // items added to the lending book, possibly filled since
+}
+
+func newInstance(t *testing.T, config FlowConfig) *instance {
+	env, err := NewEnv(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create environment: %v", err)
+	}
+	flow, err := NewFlow(env, config)
+	if err != nil {
+		t.Fatalf("failed to create flow: %v", err)
+	}
+	return &instance{env: env, flow: flow}
+}
+
+// apply runs an operation on the instance and returns a summary of its result. Out of 8
+// operations, 5 place a new item, 2 cancel a resting item and 1 commits the states, as done at
+// the end of a block.
+func (in *instance) apply(op byte) (string, error) {
+	switch {
+	case op%8 < 5:
+		item, err := in.flow.Next()
+		if err != nil {
+			return "", err
+		}
+		trades, rejects, err := in.env.CommitOrder(item)
+		if item.LendingId > 0 {
+			in.resting = append(in.resting, item)
+		}
+		return fmt.Sprintf("new %x: trades %d, rejects %d, err %v", item.Hash, len(trades), len(rejects), err), nil
+	case op%8 < 7:
+		if len(in.resting) == 0 {
+			return "no resting item", nil
+		}
+		i := int(op/8) % len(in.resting)
+		item := in.resting[i]
+		in.resting = append(in.resting[:i], in.resting[i+1:]...)
+		cancel, err := in.flow.Cancellation(item)
+		if err != nil {
+			return "", err
+		}
+		_, rejects, err := in.env.CommitOrder(cancel)
+		return fmt.Sprintf("cancel %d: rejects %d, err %v", item.LendingId, len(rejects), err), nil
+	default:
+		return "commit", in.env.Commit()
+	}
+}
+
+// roots returns the roots of the states of the instance.
+func (in *instance) roots() [3]common.Hash {
+	return [3]common.Hash{in.env.Statedb.IntermediateRoot(false), in.env.LendingState.IntermediateRoot(), in.env.TradingState.IntermediateRoot()}
+}
+
+// checkDeterminism feeds the same sequence of operations to two independent instances and fails
+// as soon as their results or their state roots differ.
+func checkDeterminism(t *testing.T, seed int64, ops []byte) {
+	if len(ops) > maxFuzzOps {
+		ops = ops[:maxFuzzOps]
+	}
+	// few users and a narrow spread give equal interests, self-trades and partial fills
+	config := FlowConfig{Seed: seed, Users: 4, MidInterest: DefaultFlowConfig.MidInterest, Spread: 2, MinQuantity: 10, MaxQuantity: 30}
+	a, b := newInstance(t, config), newInstance(t, config)
+	for i, op := range ops {
+		resultA, err := a.apply(op)
+		if err != nil {
+			t.Fatalf("op %d: instance a failed: %v", i, err)
+		}
+		resultB, err := b.apply(op)
+		if err != nil {
+			t.Fatalf("op %d: instance b failed: %v", i, err)
+		}
+		if resultA != resultB {
+			t.Fatalf("op %d: results differ: %s, %s", i, resultA, resultB)
+		}
+		if rootsA, rootsB := a.roots(), b.roots(); rootsA != rootsB {
+			t.Fatalf("op %d (%s): state roots differ: %x, %x", i, resultA, rootsA, rootsB)
+		}
+	}
+}
+
+func FuzzMatchingDeterminism(f *testing.F) {
+	f.Add(int64(1), []byte{0, 1, 2, 3, 4, 5, 6, 7, 0, 1, 2, 3, 4, 13, 21, 7})
+	f.Add(int64(2), []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5, 5, 5})
+	f.Add(int64(3), []byte{1, 2, 3, 7, 4, 0, 7, 6, 14, 22, 30, 7, 0, 1})
+	f.Fuzz(func(t *testing.T, seed int64, ops []byte) {
+		checkDeterminism(t, seed, ops)
+	})
+}
+
+func TestEqualInterestPriority(t *testing.T) {
+	env, flow := newTestEnv(t)
+	var (
+		interest = DefaultFlowConfig.MidInterest
+		quantity = new(big.Int).Mul(big.NewInt(10), common.BasePrice)
+	)
+	// three investing items of equal interest, the first user places the last one
+	var investing []*lendingstate.LendingItem
+	for _, user := range []int{1, 2, 0} {
+		item, err := flow.item(user, lendingstate.Investing, interest, quantity)
+		if err != nil {
+			t.Fatalf("failed to create item: %v", err)
+		}
+		if _, rejects, err := env.CommitOrder(item); err != nil || len(rejects) > 0 {
+			t.Fatalf("failed to commit item: err %v, rejects %d", err, len(rejects))
+		}
+		investing = append(investing, item)
+	}
+	borrow, err := flow.item(3, lendingstate.Borrowing, interest, new(big.Int).Mul(big.NewInt(25), common.BasePrice))
+	if err != nil {
+		t.Fatalf("failed to create item: %v", err)
+	}
+	trades, _, err := env.CommitOrder(borrow)
+	if err != nil {
+		t.Fatalf("failed to commit item: %v", err)
+	}
+	if len(trades) != 3 {
+		t.Fatalf("wrong number of trades: have %d, want 3", len(trades))
+	}
+	for i, trade := range trades {
+		if trade.InvestingOrderHash != investing[i].Hash {
+			t.Errorf("trade %d: matched item %x, want %x", i, trade.InvestingOrderHash, investing[i].Hash)
+		}
+	}
+	if trades[2].Amount.Cmp(new(big.Int).Mul(big.NewInt(5), common.BasePrice)) != 0 {
+		t.Errorf("wrong amount of the last trade: have %v", trades[2].Amount)
+	}
+}
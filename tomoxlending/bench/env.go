@@ -0,0 +1,203 @@
+// Package bench provides reproducible synthetic lending order flows, and the states they run
+// against, to benchmark the lending matching engine.
+package bench
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+const (
+	// Term is the term of the lending book of the environments.
+	Term = uint64(86400)
+	// epoch is the epoch length of the chain of the environments.
+	epoch = uint64(900)
+)
+
+var (
+	// DepositRate, LiquidationRate and RecallRate are the rates of the collateral, in percent.
+	DepositRate     = big.NewInt(150)
+	LiquidationRate = big.NewInt(110)
+	RecallRate      = big.NewInt(200)
+	// CollateralPrice is the initial price of the collateral in the lending token: 1 collateral
+	// token is worth 2 TOMO.
+	CollateralPrice = new(big.Int).Mul(big.NewInt(2), common.BasePrice)
+
+	// blockNumber is the number of the block the environments process, after TIPTomoXLending.
+	blockNumber = new(big.Int).Add(common.TIPTomoXLending, big.NewInt(1))
+	// relayerFee is the borrowing fee of the relayer, over 10000.
+	relayerFee = big.NewInt(100)
+	// relayerFund is the part of the relayer deposit above the locked fund, in TOMO.
+	relayerFund = big.NewInt(1000000)
+	// userBalance is the TOMO and collateral balance of the users of the flows.
+	userBalance = new(big.Int).Mul(big.NewInt(1000000000), common.BasePrice)
+)
+
+// chain is a chain context serving the chain config only, the benchmarks never read blocks.
+type chain struct {
+	config *params.ChainConfig
+}
+
+func (c *chain) Engine() consensus.Engine                    { return nil }
+func (c *chain) GetHeader(common.Hash, uint64) *types.Header { return nil }
+func (c *chain) CurrentHeader() *types.Header                { return nil }
+func (c *chain) Config() *params.ChainConfig                 { return c.config }
+
+// Env is a lending engine and the states of a single relayer listing one lending book: TOMO
+// lent for Term against one collateral token, whose price is set in the lending contract.
+type Env struct {
+	Lending *tomoxlending.Lending
+	Chain   consensus.ChainContext
+	Header  *types.Header
+
+	Relayer         common.Address
+	LendingToken    common.Address
+	CollateralToken common.Address
+	LendingBook     common.Hash
+
+	Statedb      *state.StateDB
+	LendingState *lendingstate.LendingStateDB
+	TradingState *tradingstate.TradingStateDB
+
+	stateDatabase   state.Database
+	lendingDatabase lendingstate.Database
+	tradingDatabase tradingstate.Database
+	root            common.Hash // roots of the last commit
+	lendingRoot     common.Hash
+	tradingRoot     common.Hash
+}
+
+// NewEnv creates an environment whose lending engine keeps its data in dataDir.
+func NewEnv(dataDir string) (*Env, error) {
+	tomoX := tomox.New(&tomox.Config{DataDir: dataDir})
+	env := &Env{
+		Lending:         tomoxlending.New(tomoX),
+		Chain:           &chain{config: &params.ChainConfig{Posv: &params.PosvConfig{Epoch: epoch}}},
+		Header:          &types.Header{Number: new(big.Int).Set(blockNumber), Time: big.NewInt(1000)},
+		Relayer:         common.HexToAddress("0x0000000000000000000000000000000000000b01"),
+		LendingToken:    common.HexToAddress(common.TomoNativeAddress),
+		CollateralToken: common.HexToAddress("0x0000000000000000000000000000000000000b02"),
+		stateDatabase:   state.NewDatabase(rawdb.NewMemoryDatabase()),
+		lendingDatabase: lendingstate.NewDatabase(rawdb.NewMemoryDatabase()),
+		tradingDatabase: tradingstate.NewDatabase(rawdb.NewMemoryDatabase()),
+		lendingRoot:     lendingstate.EmptyRoot,
+		tradingRoot:     tradingstate.EmptyRoot,
+	}
+	env.LendingBook = lendingstate.GetLendingOrderBookHash(env.LendingToken, Term)
+	// the collateral isn't a deployed token, its decimals can't be read from its contract
+	tomoX.SetTokenDecimal(env.CollateralToken, common.BasePrice)
+
+	if err := env.open(); err != nil {
+		return nil, err
+	}
+	env.registerRelayer()
+	env.SetCollateralPrice(CollateralPrice)
+	return env, nil
+}
+
+// registerRelayer writes the relayer, its lending book and the collateral to the storage of the
+// relayer and lending contracts.
+func (env *Env) registerRelayer() {
+	var (
+		relayerContract = common.HexToAddress(common.RelayerRegistrationSMC)
+		lendingContract = common.HexToAddress(common.LendingRegistrationSMC)
+	)
+	// the relayer deposit must exceed the locked fund while the matching fees are taken from it,
+	// the contract holds the deposit
+	locRelayer := state.GetLocMappingAtKey(env.Relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	deposit := new(big.Int).Mul(common.BasePrice, new(big.Int).Add(common.RelayerLockedFund, relayerFund))
+	env.Statedb.SetState(relayerContract, state.GetLocOfStructElement(locRelayer, tradingstate.RelayerStructMappingSlot["_deposit"]), common.BigToHash(deposit))
+	env.Statedb.SetState(relayerContract, state.GetLocOfStructElement(locRelayer, tradingstate.RelayerStructMappingSlot["_owner"]), env.Relayer.Hash())
+	env.Statedb.SetBalance(relayerContract, deposit)
+
+	locLendingRelayer := state.GetLocMappingAtKey(env.Relayer.Hash(), lendingstate.LendingRelayerListSlot)
+	env.Statedb.SetState(lendingContract, state.GetLocOfStructElement(locLendingRelayer, lendingstate.LendingRelayerStructSlots["fee"]), common.BigToHash(relayerFee))
+	setArray(env.Statedb, lendingContract, state.GetLocOfStructElement(locLendingRelayer, lendingstate.LendingRelayerStructSlots["bases"]), env.LendingToken.Hash())
+	setArray(env.Statedb, lendingContract, state.GetLocOfStructElement(locLendingRelayer, lendingstate.LendingRelayerStructSlots["terms"]), common.Uint64ToHash(Term))
+	setArray(env.Statedb, lendingContract, state.GetLocSimpleVariable(lendingstate.SupportedBaseSlot), env.LendingToken.Hash())
+	setArray(env.Statedb, lendingContract, state.GetLocSimpleVariable(lendingstate.SupportedTermSlot), common.Uint64ToHash(Term))
+	setArray(env.Statedb, lendingContract, state.GetLocSimpleVariable(lendingstate.DefaultCollateralSlot), env.CollateralToken.Hash())
+
+	locCollateral := lendingstate.GetLocMappingAtKey(env.CollateralToken.Hash(), lendingstate.CollateralMapSlot)
+	env.Statedb.SetState(lendingContract, state.GetLocOfStructElement(locCollateral, lendingstate.CollateralStructSlots["depositRate"]), common.BigToHash(DepositRate))
+	env.Statedb.SetState(lendingContract, state.GetLocOfStructElement(locCollateral, lendingstate.CollateralStructSlots["liquidationRate"]), common.BigToHash(LiquidationRate))
+	env.Statedb.SetState(lendingContract, state.GetLocOfStructElement(locCollateral, lendingstate.CollateralStructSlots["recallRate"]), common.BigToHash(RecallRate))
+}
+
+// setArray writes a dynamic array of one element at a storage location.
+func setArray(statedb *state.StateDB, contract common.Address, loc common.Hash, element common.Hash) {
+	statedb.SetState(contract, loc, common.BigToHash(common.Big1))
+	statedb.SetState(contract, state.GetLocDynamicArrAtElement(loc, 0, 1), element)
+}
+
+// SetCollateralPrice sets the price of the collateral in the lending token in the lending
+// contract, updated at the block of the environment.
+func (env *Env) SetCollateralPrice(price *big.Int) {
+	locCollateral := lendingstate.GetLocMappingAtKey(env.CollateralToken.Hash(), lendingstate.CollateralMapSlot)
+	locPrices := new(big.Int).Add(locCollateral, lendingstate.CollateralStructSlots["price"])
+	locPrice := new(big.Int).SetBytes(crypto.Keccak256(env.LendingToken.Hash().Bytes(), common.BigToHash(locPrices).Bytes()))
+	lendingContract := common.HexToAddress(common.LendingRegistrationSMC)
+	env.Statedb.SetState(lendingContract, common.BigToHash(new(big.Int).Add(locPrice, lendingstate.PriceStructSlots["price"])), common.BigToHash(price))
+	env.Statedb.SetState(lendingContract, common.BigToHash(new(big.Int).Add(locPrice, lendingstate.PriceStructSlots["blockNumber"])), common.BigToHash(env.Header.Number))
+}
+
+// Fund gives a user enough TOMO and collateral to place any item of the flows.
+func (env *Env) Fund(user common.Address) error {
+	env.Statedb.GetOrNewStateObject(env.CollateralToken)
+	if err := tradingstate.SetTokenBalance(user, userBalance, env.LendingToken, env.Statedb); err != nil {
+		return err
+	}
+	return tradingstate.SetTokenBalance(user, userBalance, env.CollateralToken, env.Statedb)
+}
+
+// CommitOrder matches a lending item in the lending book of the environment.
+func (env *Env) CommitOrder(item *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
+	return env.Lending.CommitOrder(env.Header, env.Relayer, env.Chain, env.Statedb, env.LendingState, env.TradingState, env.LendingBook, item)
+}
+
+// open opens the states of the environment at the roots of the last commit.
+func (env *Env) open() error {
+	var err error
+	if env.Statedb, err = state.New(env.root, env.stateDatabase); err != nil {
+		return err
+	}
+	if env.LendingState, err = lendingstate.New(env.lendingRoot, env.lendingDatabase); err != nil {
+		return err
+	}
+	env.TradingState, err = tradingstate.New(env.tradingRoot, env.tradingDatabase)
+	return err
+}
+
+// Commit writes the states of the environment to their databases, as done at the end of a block.
+func (env *Env) Commit() error {
+	var err error
+	if env.root, err = env.Statedb.Commit(false); err != nil {
+		return err
+	}
+	if env.lendingRoot, err = env.LendingState.Commit(); err != nil {
+		return err
+	}
+	env.tradingRoot, err = env.TradingState.Commit()
+	return err
+}
+
+// Fork returns an environment sharing the lending engine and the databases of the environment,
+// with its states opened at the roots of the last commit.
+func (env *Env) Fork() (*Env, error) {
+	fork := *env
+	if err := fork.open(); err != nil {
+		return nil, err
+	}
+	return &fork, nil
+}
@@ -0,0 +1,180 @@
+package bench
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// FlowConfig describes a synthetic order flow. Flows with the same config generate the same
+// items.
+type FlowConfig struct {
+	Seed        int64  // seed of the generator
+	Users       int    // number of users placing items
+	MidInterest uint64 // interest the items are placed around, over common.BaseLendingInterest
+	Spread      uint64 // maximum distance between the interest of an item and MidInterest
+	MinQuantity int64  // minimum quantity of an item, in TOMO
+	MaxQuantity int64  // maximum quantity of an item, in TOMO
+}
+
+// DefaultFlowConfig is a flow of 100 users placing items of 10 to 100 TOMO around 10%. Smaller
+// items would pay less than the minimum borrowing fee.
+var DefaultFlowConfig = FlowConfig{
+	Seed:        1,
+	Users:       100,
+	MidInterest: 10 * common.BaseLendingInterest.Uint64(),
+	Spread:      2 * common.BaseLendingInterest.Uint64(),
+	MinQuantity: 10,
+	MaxQuantity: 100,
+}
+
+// Flow generates signed limit items for the lending book of an environment. Investing and
+// borrowing items are drawn around the same interest, so that part of them match the items
+// resting in the book and the rest is added to it.
+type Flow struct {
+	config FlowConfig
+	env    *Env
+	rand   *rand.Rand
+	keys   []*ecdsa.PrivateKey
+	users  []common.Address
+	nonces []uint64
+	pairs  uint64 // number of pairs generated so far
+}
+
+// NewFlow creates a flow of items for an environment, and funds its users in the environment.
+func NewFlow(env *Env, config FlowConfig) (*Flow, error) {
+	flow := &Flow{
+		config: config,
+		env:    env,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+		nonces: make([]uint64, config.Users),
+	}
+	for i := 0; i < config.Users; i++ {
+		// the keys are derived from the seed, so the users are the same across runs
+		var seed [16]byte
+		binary.BigEndian.PutUint64(seed[:8], uint64(config.Seed))
+		binary.BigEndian.PutUint64(seed[8:], uint64(i))
+		key, err := crypto.ToECDSA(crypto.Keccak256(seed[:]))
+		if err != nil {
+			return nil, err
+		}
+		user := crypto.PubkeyToAddress(key.PublicKey)
+		if err := env.Fund(user); err != nil {
+			return nil, err
+		}
+		flow.keys = append(flow.keys, key)
+		flow.users = append(flow.users, user)
+	}
+	return flow, nil
+}
+
+// Next returns the next item of the flow.
+func (f *Flow) Next() (*lendingstate.LendingItem, error) {
+	side := lendingstate.Investing
+	if f.rand.Intn(2) == 0 {
+		side = lendingstate.Borrowing
+	}
+	spread := uint64(f.rand.Int63n(int64(2*f.config.Spread) + 1))
+	quantity := new(big.Int).Mul(big.NewInt(f.config.MinQuantity+f.rand.Int63n(f.config.MaxQuantity-f.config.MinQuantity+1)), common.BasePrice)
+	return f.item(f.rand.Intn(len(f.users)), side, f.config.MidInterest-f.config.Spread+spread, quantity)
+}
+
+// Items returns the next n items of the flow.
+func (f *Flow) Items(n int) ([]*lendingstate.LendingItem, error) {
+	items := make([]*lendingstate.LendingItem, 0, n)
+	for i := 0; i < n; i++ {
+		item, err := f.Next()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Pairs returns n pairs of an investing item and a borrowing item of distinct users, each pair
+// matching into one lending trade of the given quantity. Every pair has its own interest above
+// the mid interest: an interest emptied by a match isn't found again in the lending book until
+// the lending state is committed.
+func (f *Flow) Pairs(n int, quantity *big.Int) ([]*lendingstate.LendingItem, error) {
+	items := make([]*lendingstate.LendingItem, 0, 2*n)
+	for i := 0; i < n; i++ {
+		investor := f.rand.Intn(len(f.users))
+		borrower := (investor + 1 + f.rand.Intn(len(f.users)-1)) % len(f.users)
+		interest := f.config.MidInterest + f.pairs
+		f.pairs++
+		invest, err := f.item(investor, lendingstate.Investing, interest, quantity)
+		if err != nil {
+			return nil, err
+		}
+		borrow, err := f.item(borrower, lendingstate.Borrowing, interest, quantity)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, invest, borrow)
+	}
+	return items, nil
+}
+
+// item creates and signs a limit item of a user, with the next nonce of the user.
+func (f *Flow) item(user int, side string, interest uint64, quantity *big.Int) (*lendingstate.LendingItem, error) {
+	item := &lendingstate.LendingItem{
+		Nonce:        new(big.Int).SetUint64(f.nonces[user]),
+		Quantity:     quantity,
+		Interest:     new(big.Int).SetUint64(interest),
+		Relayer:      f.env.Relayer,
+		Term:         Term,
+		UserAddress:  f.users[user],
+		LendingToken: f.env.LendingToken,
+		Status:       lendingstate.LendingStatusNew,
+		Side:         side,
+		Type:         lendingstate.Limit,
+	}
+	if side == lendingstate.Borrowing {
+		item.CollateralToken = f.env.CollateralToken
+	}
+	item.Hash = item.ComputeHash(false)
+	f.nonces[user]++
+	return f.sign(user, item)
+}
+
+// Cancellation creates and signs the cancellation of an item of the flow resting in the lending
+// book, with the next nonce of its user.
+func (f *Flow) Cancellation(item *lendingstate.LendingItem) (*lendingstate.LendingItem, error) {
+	user := -1
+	for i, address := range f.users {
+		if address == item.UserAddress {
+			user = i
+			break
+		}
+	}
+	if user < 0 {
+		return nil, fmt.Errorf("item %x not placed by a user of the flow", item.Hash)
+	}
+	cancel := *item
+	cancel.Nonce = new(big.Int).SetUint64(f.nonces[user])
+	cancel.Quantity = lendingstate.CloneBigInt(item.Quantity)
+	cancel.Status = lendingstate.LendingStatusCancelled
+	f.nonces[user]++
+	return f.sign(user, &cancel)
+}
+
+// sign signs an item with the key of its user.
+func (f *Flow) sign(user int, item *lendingstate.LendingItem) (*lendingstate.LendingItem, error) {
+	tx := types.NewLendingTransaction(item.Nonce.Uint64(), item.Quantity, item.Interest.Uint64(), item.Term, item.Relayer, item.UserAddress,
+		item.LendingToken, item.CollateralToken, item.AutoTopUp, item.Status, item.Side, item.Type, item.Hash, item.LendingId, item.LendingTradeId, item.ExtraData)
+	tx, err := types.LendingSignTx(tx, types.LendingTxSigner{}, f.keys[user])
+	if err != nil {
+		return nil, err
+	}
+	V, R, S := tx.Signature()
+	item.Signature = &lendingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)}
+	return item, nil
+}
@@ -0,0 +1,127 @@
+package tomoxlending
+
+import (
+	"errors"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tomochain/tomochain/metrics"
+)
+
+var errUnknownLendingCache = errors.New("unknown lending cache")
+
+// Names of the in-memory lending caches, as reported by tomoxlending_cacheStats.
+const (
+	itemHistoryCache    = "itemHistory"    // reorg history of the lending items, by transaction
+	tradeHistoryCache   = "tradeHistory"   // reorg history of the lending trades, by transaction
+	rootsCache          = "roots"          // lending state roots of recent blocks, by block hash
+	backfillMissesCache = "backfillMisses" // hashes of the SDK records which couldn't be backfilled
+)
+
+// lendingCache is an LRU cache counting its hits and misses, reported by the
+// tomoxlending/cache/<name>/hit and tomoxlending/cache/<name>/miss metrics.
+type lendingCache struct {
+	*lru.Cache
+	name   string
+	limit  int
+	hits   uint64 // atomic
+	misses uint64 // atomic
+
+	hitCounter  metrics.Counter
+	missCounter metrics.Counter
+}
+
+func newLendingCache(name string, limit int) *lendingCache {
+	cache, _ := lru.New(limit)
+	return &lendingCache{
+		Cache:       cache,
+		name:        name,
+		limit:       limit,
+		hitCounter:  metrics.GetOrRegisterCounter("tomoxlending/cache/"+name+"/hit", nil),
+		missCounter: metrics.GetOrRegisterCounter("tomoxlending/cache/"+name+"/miss", nil),
+	}
+}
+
+func (c *lendingCache) count(hit bool) {
+	if hit {
+		atomic.AddUint64(&c.hits, 1)
+		c.hitCounter.Inc(1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+		c.missCounter.Inc(1)
+	}
+}
+
+func (c *lendingCache) Get(key interface{}) (interface{}, bool) {
+	value, ok := c.Cache.Get(key)
+	c.count(ok)
+	return value, ok
+}
+
+func (c *lendingCache) Contains(key interface{}) bool {
+	ok := c.Cache.Contains(key)
+	c.count(ok)
+	return ok
+}
+
+// LendingCacheStats reports the usage of an in-memory lending cache since the node started.
+type LendingCacheStats struct {
+	Name    string  `json:"name"`
+	Entries int     `json:"entries"`
+	Limit   int     `json:"limit"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"` // hits over lookups, 0 before the first lookup
+}
+
+func (c *lendingCache) stats() LendingCacheStats {
+	stats := LendingCacheStats{
+		Name:    c.name,
+		Entries: c.Len(),
+		Limit:   c.limit,
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// caches returns the in-memory lending caches.
+func (l *Lending) caches() []*lendingCache {
+	return []*lendingCache{l.lendingItemHistory, l.lendingTradeHistory, l.lendingRoots, l.sdkBackfillMisses}
+}
+
+// CacheStats returns the usage of the in-memory lending caches.
+func (l *Lending) CacheStats() []LendingCacheStats {
+	var stats []LendingCacheStats
+	for _, cache := range l.caches() {
+		stats = append(stats, cache.stats())
+	}
+	return stats
+}
+
+// FlushCaches empties the in-memory lending cache of the given name, or all of them if the name is
+// empty, and returns the names of the caches flushed. The reorg history is flushed between two
+// transactions recorded to the SDK database, once it has been persisted (see history.go).
+func (l *Lending) FlushCaches(name string) ([]string, error) {
+	var flushed []string
+	for _, cache := range l.caches() {
+		if name != "" && cache.name != name {
+			continue
+		}
+		if cache == l.lendingItemHistory || cache == l.lendingTradeHistory {
+			l.sdkSyncLock.Lock()
+			cache.Purge()
+			l.sdkSyncLock.Unlock()
+		} else {
+			cache.Purge()
+		}
+		flushed = append(flushed, cache.name)
+	}
+	if len(flushed) == 0 {
+		return nil, errUnknownLendingCache
+	}
+	return flushed, nil
+}
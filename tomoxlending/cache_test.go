@@ -0,0 +1,46 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+)
+
+func TestLendingCaches(t *testing.T) {
+	tomoX := tomox.New(&tomox.Config{DevDB: true, LendingCacheSize: 2, LendingRootsCache: 8})
+	defer tomoX.GetMongoDB().Close()
+	l := New(tomoX)
+
+	for i := int64(0); i < 3; i++ {
+		l.sdkBackfillMisses.Add(common.BigToHash(big.NewInt(i)), struct{}{})
+	}
+	l.sdkBackfillMisses.Contains(common.HexToHash("0x2"))
+	l.sdkBackfillMisses.Contains(common.HexToHash("0x0"))
+	l.lendingRoots.Add(common.HexToHash("0x1"), common.HexToHash("0x2"))
+
+	stats := make(map[string]LendingCacheStats)
+	for _, s := range l.CacheStats() {
+		stats[s.Name] = s
+	}
+	if s := stats[backfillMissesCache]; s.Entries != 2 || s.Limit != 2 || s.Hits != 1 || s.Misses != 1 || s.HitRate != 0.5 {
+		t.Errorf("wrong backfill misses stats: %+v", s)
+	}
+	if s := stats[rootsCache]; s.Entries != 1 || s.Limit != 8 {
+		t.Errorf("wrong roots stats: %+v", s)
+	}
+	if s := stats[itemHistoryCache]; s.Limit != 2 {
+		t.Errorf("wrong item history stats: %+v", s)
+	}
+
+	if flushed, err := l.FlushCaches(rootsCache); err != nil || len(flushed) != 1 || l.lendingRoots.Len() != 0 || l.sdkBackfillMisses.Len() != 2 {
+		t.Errorf("wrong flush of the roots: %v, %v", flushed, err)
+	}
+	if flushed, err := l.FlushCaches(""); err != nil || len(flushed) != 4 || l.sdkBackfillMisses.Len() != 0 {
+		t.Errorf("wrong flush of all the caches: %v, %v", flushed, err)
+	}
+	if _, err := l.FlushCaches("unknown"); err != errUnknownLendingCache {
+		t.Errorf("wrong error for an unknown cache: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package tomoxlending
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/accounts"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+var errAccountManagerUnavailable = errors.New("account manager is unavailable")
+
+// SetAccountManager injects the account manager holding the keys used to sign the lending
+// transactions generated by the node, such as the cancellations of tomoxlending_cancelAllLendingItems.
+func (l *Lending) SetAccountManager(am *accounts.Manager) {
+	l.accountManager = am
+}
+
+// openLendingItems returns the open lending items of a user in the current lending state, sorted
+// by lending book and lending id. A nil lending token matches every lending token and a zero term
+// every term. Untriggered stop-limit items are not returned, they can't be cancelled before they
+// enter their lending book.
+func (l *Lending) openLendingItems(user common.Address, lendingToken *common.Address, term uint64) ([]*lendingstate.LendingItem, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	lendingTokens := lendingstate.GetSupportedBaseToken(statedb)
+	if lendingToken != nil {
+		lendingTokens = []common.Address{*lendingToken}
+	}
+	terms := lendingstate.GetSupportedTerms(statedb)
+	if term > 0 {
+		terms = []uint64{term}
+	}
+	items := []*lendingstate.LendingItem{}
+	for _, token := range lendingTokens {
+		for _, t := range terms {
+			lendingBook := lendingstate.GetLendingOrderBookHash(token, t)
+			if !lendingState.Exist(lendingBook) {
+				continue
+			}
+			bookItems, err := lendingState.DumpLendingOrderTrie(lendingBook)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, userLendingItems(bookItems, user)...)
+		}
+	}
+	return items, nil
+}
+
+// userLendingItems returns the items of a lending book which are placed by a user and not
+// filled nor cancelled yet, sorted by lending id.
+func userLendingItems(bookItems map[*big.Int]lendingstate.LendingItem, user common.Address) []*lendingstate.LendingItem {
+	items := []*lendingstate.LendingItem{}
+	for _, item := range bookItems {
+		if item.UserAddress != user || item.Quantity == nil || item.Quantity.Sign() <= 0 {
+			continue
+		}
+		item := item
+		items = append(items, &item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LendingId < items[j].LendingId
+	})
+	return items
+}
+
+// cancelLendingItemTxs returns the unsigned transactions cancelling the given lending items, with
+// consecutive nonces starting at nonce. The items whose hash is in skip are left open.
+func cancelLendingItemTxs(items []*lendingstate.LendingItem, nonce uint64, skip map[common.Hash]bool) []*types.LendingTransaction {
+	txs := []*types.LendingTransaction{}
+	for _, item := range items {
+		if skip[item.Hash] {
+			continue
+		}
+		tx := types.NewLendingTransaction(nonce, item.Quantity, 0, item.Term, item.Relayer, item.UserAddress, item.LendingToken, item.CollateralToken,
+			item.AutoTopUp, lendingstate.LendingStatusCancelled, item.Side, item.Type, item.Hash, item.LendingId, 0, "")
+		txs = append(txs, tx)
+		nonce++
+	}
+	return txs
+}
+
+// pendingCancellations returns the hashes of the lending items a user has already sent a
+// cancellation for to the lending pool.
+func (l *Lending) pendingCancellations(user common.Address) map[common.Hash]bool {
+	pending, queued := l.lendingPool.ContentFrom(user)
+	cancelled := make(map[common.Hash]bool)
+	for _, txs := range []types.LendingTransactions{pending, queued} {
+		for _, tx := range txs {
+			if tx.IsCancelledLending() {
+				cancelled[tx.LendingHash()] = true
+			}
+		}
+	}
+	return cancelled
+}
+
+// signLendingTx signs a lending transaction with the unlocked account of its user, or with the
+// hardware wallet holding it after the user confirms the terms shown on the device.
+func (l *Lending) signLendingTx(tx *types.LendingTransaction) (*types.LendingTransaction, error) {
+	if l.accountManager == nil {
+		return nil, errAccountManagerUnavailable
+	}
+	account := accounts.Account{Address: tx.UserAddress()}
+	wallet, err := l.accountManager.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	// the legacy signature is the signed message of the hash of the transaction, or since
+	// TIPTomoXTextSigning of its terms, which hardware wallets display before signing
+	signer := l.lendingSigner()
+	text := l.lendingTxSigner().Hash(tx).Bytes()
+	if textSigner, ok := signer.(types.LendingTextSigner); ok {
+		text = []byte(textSigner.Text(tx))
+	}
+	sig, err := accounts.SignText(wallet, account, text)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// cancelAllLendingItems signs a cancellation of every open lending item of a user, in the lending
+// books selected as in openLendingItems, and injects them into the lending pool. Either all of the
+// cancellations are added or none. The items already being cancelled by a transaction of the pool
+// are skipped. It returns the transaction hashes of the cancellations.
+func (l *Lending) cancelAllLendingItems(user common.Address, lendingToken *common.Address, term uint64) ([]common.Hash, error) {
+	if l.lendingPool == nil {
+		return nil, errLendingStateUnavailable
+	}
+	items, err := l.openLendingItems(user, lendingToken, term)
+	if err != nil {
+		return nil, err
+	}
+	nonce := l.lendingPool.State().GetNonce(user.Hash())
+	txs := cancelLendingItemTxs(items, nonce, l.pendingCancellations(user))
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		signed, err := l.signLendingTx(tx)
+		if err != nil {
+			return nil, err
+		}
+		txs[i] = signed
+		hashes[i] = signed.Hash()
+	}
+	if len(txs) == 0 {
+		return hashes, nil
+	}
+	if err := l.lendingPool.AddLocalsAtomic(txs); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
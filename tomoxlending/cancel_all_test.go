@@ -0,0 +1,78 @@
+package tomoxlending
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/tomochain/tomochain/accounts"
+	"github.com/tomochain/tomochain/accounts/keystore"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestCancelLendingItemTxs(t *testing.T) {
+	user, other := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	lendingBook := lendingstate.GetLendingOrderBookHash(common.HexToAddress("0x3"), 60)
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	for id, owner := range []common.Address{user, other, user, user} {
+		lendingId := uint64(id + 1)
+		item := lendingstate.LendingItem{LendingId: lendingId, UserAddress: owner, Quantity: big.NewInt(1), Interest: big.NewInt(5), Term: 60,
+			Side: lendingstate.Investing, Hash: common.Uint64ToHash(lendingId)}
+		lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(lendingId), item)
+	}
+	bookItems, err := lendingStateDB.DumpLendingOrderTrie(lendingBook)
+	if err != nil {
+		t.Fatalf("failed to dump lending book: %v", err)
+	}
+	items := userLendingItems(bookItems, user)
+	if len(items) != 3 || items[0].LendingId != 1 || items[1].LendingId != 3 || items[2].LendingId != 4 {
+		t.Fatalf("wrong open items: %v", items)
+	}
+	// the item 3 is already being cancelled
+	txs := cancelLendingItemTxs(items, 7, map[common.Hash]bool{common.Uint64ToHash(3): true})
+	if len(txs) != 2 {
+		t.Fatalf("wrong number of cancellations: have %d, want 2", len(txs))
+	}
+	for i, want := range []uint64{1, 4} {
+		tx := txs[i]
+		if !tx.IsCancelledLending() || tx.LendingId() != want || tx.LendingHash() != common.Uint64ToHash(want) || tx.Nonce() != uint64(7+i) {
+			t.Errorf("cancellation %d: id %d, hash %x, nonce %d", i, tx.LendingId(), tx.LendingHash(), tx.Nonce())
+		}
+	}
+}
+
+func TestSignLendingTx(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lending-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.NewAccount("")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	l := &Lending{}
+	l.SetAccountManager(accounts.NewManager(ks))
+
+	item := &lendingstate.LendingItem{LendingId: 1, UserAddress: account.Address, Quantity: big.NewInt(1), Term: 60, Hash: common.Uint64ToHash(1)}
+	tx := cancelLendingItemTxs([]*lendingstate.LendingItem{item}, 0, nil)[0]
+	if _, err := l.signLendingTx(tx); err != keystore.ErrLocked {
+		t.Fatalf("signing with a locked account: have %v, want %v", err, keystore.ErrLocked)
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		t.Fatalf("failed to unlock account: %v", err)
+	}
+	signed, err := l.signLendingTx(tx)
+	if err != nil {
+		t.Fatalf("failed to sign cancellation: %v", err)
+	}
+	if from, _ := types.LendingSender(types.LendingTxSigner{}, signed); from != account.Address {
+		t.Fatalf("wrong signer: have %x, want %x", from, account.Address)
+	}
+}
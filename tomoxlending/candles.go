@@ -0,0 +1,139 @@
+package tomoxlending
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The interest rate candles of the SDK nodes are rebuilt from the recorded lending trades rather
+// than updated incrementally: the 1m candle of a trade is recomputed from the trades of its
+// minute, then each longer candle from the shorter candles it covers. Rebuilding a candle twice
+// gives the same result, so the candles stay right when trades are removed by a reorg.
+
+// maxCandles is the maximum number of candles returned by a candle query.
+const maxCandles = 1000
+
+var (
+	errCandlesNotSDKNode     = errors.New("lending candles require an SDK node")
+	errUnknownCandleInterval = errors.New("unknown candle interval")
+	errCandleRange           = fmt.Errorf("candle range exceeds %d candles", maxCandles)
+)
+
+// candleBook identifies the candles of a lending book.
+type candleBook struct {
+	lendingToken common.Address
+	term         uint64
+}
+
+// candleTimes returns the match times of the given lending trades, grouped by lending book.
+func candleTimes(trades []*lendingstate.LendingTrade) map[candleBook][]time.Time {
+	times := make(map[candleBook][]time.Time)
+	for _, trade := range trades {
+		book := candleBook{trade.LendingToken, trade.Term}
+		times[book] = append(times[book], trade.CreatedAt)
+	}
+	return times
+}
+
+// updateCandles rebuilds the candles of every interval of the lending books covering the given
+// match times, and writes them to the SDK database. The candles of an interval are committed
+// before the longer ones are built from them.
+func (l *Lending) updateCandles(times map[candleBook][]time.Time) error {
+	if len(times) == 0 {
+		return nil
+	}
+	db := l.GetMongoDB()
+	for _, interval := range lendingstate.CandleIntervals {
+		db.InitLendingBulk()
+		for book, bookTimes := range times {
+			done := make(map[time.Time]bool)
+			for _, t := range bookTimes {
+				openTime := t.UTC().Truncate(interval.Duration)
+				if done[openTime] {
+					continue
+				}
+				done[openTime] = true
+				if err := l.updateCandle(book, interval.Name, interval.Duration, openTime); err != nil {
+					return fmt.Errorf("failed to update %s lending candle. Err: %v", interval.Name, err)
+				}
+			}
+		}
+		if err := db.CommitLendingBulk(); err != nil {
+			return fmt.Errorf("failed to commit %s lending candles. Err: %v", interval.Name, err)
+		}
+	}
+	log.Debug("Updated lending candles", "books", len(times))
+	return nil
+}
+
+// updateCandle rebuilds a candle from the lending trades of its period for the shortest interval,
+// from the candles of the previous interval otherwise. An empty candle is removed.
+func (l *Lending) updateCandle(book candleBook, interval string, duration time.Duration, openTime time.Time) error {
+	db := l.GetMongoDB()
+	candle := &lendingstate.LendingCandle{
+		LendingToken: book.lendingToken,
+		Term:         book.term,
+		Interval:     interval,
+		OpenTime:     openTime,
+	}
+	candle.Hash = candle.ComputeHash()
+
+	closeTime := openTime.Add(duration)
+	if interval == lendingstate.CandleIntervals[0].Name {
+		trades, _ := db.GetLendingListByTime(book.lendingToken, book.term, openTime, closeTime, &lendingstate.LendingTrade{}).([]*lendingstate.LendingTrade)
+		sort.SliceStable(trades, func(i, j int) bool {
+			if !trades[i].CreatedAt.Equal(trades[j].CreatedAt) {
+				return trades[i].CreatedAt.Before(trades[j].CreatedAt)
+			}
+			return trades[i].TradeId < trades[j].TradeId
+		})
+		for _, trade := range trades {
+			candle.AddTrade(trade)
+		}
+	} else {
+		var shorter string
+		for i, candleInterval := range lendingstate.CandleIntervals {
+			if candleInterval.Name == interval {
+				shorter = lendingstate.CandleIntervals[i-1].Name
+			}
+		}
+		candles, _ := db.GetLendingListByTime(book.lendingToken, book.term, openTime, closeTime, &lendingstate.LendingCandle{Interval: shorter}).([]*lendingstate.LendingCandle)
+		for _, c := range candles {
+			candle.AddCandle(c)
+		}
+	}
+
+	if candle.Count == 0 {
+		if found, _ := db.HasObject(candle.Hash, candle); found {
+			return db.DeleteObject(candle.Hash, candle)
+		}
+		return nil
+	}
+	candle.UpdatedAt = time.Now().UTC()
+	return db.PutObject(candle.Hash, candle)
+}
+
+// getCandles returns the candles of an interval of a lending book opened in [from, to).
+func (l *Lending) getCandles(lendingToken common.Address, term uint64, interval string, from, to time.Time) ([]*lendingstate.LendingCandle, error) {
+	if !l.tomox.IsSDKNode() {
+		return nil, errCandlesNotSDKNode
+	}
+	duration := lendingstate.CandleInterval(interval)
+	if duration == 0 {
+		return nil, errUnknownCandleInterval
+	}
+	if to.Sub(from) > maxCandles*duration {
+		return nil, errCandleRange
+	}
+	candles, _ := l.GetMongoReadDB().GetLendingListByTime(lendingToken, term, from, to, &lendingstate.LendingCandle{Interval: interval}).([]*lendingstate.LendingCandle)
+	if candles == nil {
+		candles = []*lendingstate.LendingCandle{}
+	}
+	return candles, nil
+}
@@ -0,0 +1,85 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestLendingCandles(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir(), DBEngine: "badger"}))
+	defer l.GetMongoDB().Close()
+
+	var (
+		usdt = common.HexToAddress("0x10")
+		day  = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+		db   = l.GetMongoDB()
+	)
+	newTrade := func(id uint64, interest uint64, amount int64, at time.Time) *lendingstate.LendingTrade {
+		trade := &lendingstate.LendingTrade{TradeId: id, LendingToken: usdt, Term: 86400, Interest: interest, Amount: big.NewInt(amount), InvestingOrderHash: common.Uint64ToHash(id), CreatedAt: at, UpdatedAt: at}
+		trade.Hash = trade.ComputeHash()
+		return trade
+	}
+	trades := []*lendingstate.LendingTrade{
+		newTrade(1, 8, 100, day.Add(10*time.Second)),
+		newTrade(2, 12, 50, day.Add(10*time.Second)),
+		newTrade(3, 5, 20, day.Add(3*time.Minute)),
+		newTrade(4, 9, 30, day.Add(2*time.Hour)),
+	}
+	db.InitLendingBulk()
+	for _, trade := range trades {
+		if err := db.PutObject(trade.Hash, trade); err != nil {
+			t.Fatalf("failed to put trade: %v", err)
+		}
+	}
+	if err := db.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit trades: %v", err)
+	}
+	if err := l.updateCandles(candleTimes(trades)); err != nil {
+		t.Fatalf("failed to update candles: %v", err)
+	}
+
+	check := func(interval string, want [][6]int64) {
+		t.Helper()
+		candles, err := l.getCandles(usdt, 86400, interval, day, day.Add(3*time.Hour))
+		if err != nil {
+			t.Fatalf("failed to get %s candles: %v", interval, err)
+		}
+		if len(candles) != len(want) {
+			t.Fatalf("wrong number of %s candles: have %d, want %d", interval, len(candles), len(want))
+		}
+		for i, c := range candles {
+			have := [6]int64{c.Open.Int64(), c.High.Int64(), c.Low.Int64(), c.Close.Int64(), c.Volume.Int64(), int64(c.Count)}
+			if have != want[i] {
+				t.Errorf("wrong %s candle %d: have %v, want %v", interval, i, have, want[i])
+			}
+		}
+	}
+	check("1m", [][6]int64{{8, 12, 8, 12, 150, 2}, {5, 5, 5, 5, 20, 1}, {9, 9, 9, 9, 30, 1}})
+	check("5m", [][6]int64{{8, 12, 5, 5, 170, 3}, {9, 9, 9, 9, 30, 1}})
+	check("1h", [][6]int64{{8, 12, 5, 5, 170, 3}, {9, 9, 9, 9, 30, 1}})
+	check("1d", [][6]int64{{8, 12, 5, 9, 200, 4}})
+
+	// removing trades rebuilds the candles, and removes the empty ones
+	for _, trade := range trades[2:] {
+		if err := db.DeleteObject(trade.Hash, &lendingstate.LendingTrade{}); err != nil {
+			t.Fatalf("failed to delete trade: %v", err)
+		}
+	}
+	if err := l.updateCandles(candleTimes(trades[2:])); err != nil {
+		t.Fatalf("failed to update candles: %v", err)
+	}
+	check("1m", [][6]int64{{8, 12, 8, 12, 150, 2}})
+	check("1d", [][6]int64{{8, 12, 8, 12, 150, 2}})
+
+	if _, err := l.getCandles(usdt, 86400, "2m", day, day.Add(time.Hour)); err != errUnknownCandleInterval {
+		t.Fatalf("wrong error for an unknown interval: %v", err)
+	}
+	if _, err := l.getCandles(usdt, 86400, "1m", day, day.Add(24*time.Hour)); err != errCandleRange {
+		t.Fatalf("wrong error for a too long range: %v", err)
+	}
+}
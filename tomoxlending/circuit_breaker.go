@@ -0,0 +1,43 @@
+package tomoxlending
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// isMatchingType returns whether the lending items of a type are matched against the lending
+// book, and so are held back while the circuit breaker of the book is tripped.
+func isMatchingType(itemType string) bool {
+	switch itemType {
+	case lendingstate.Market, lendingstate.Limit, lendingstate.StopLimit, lendingstate.Iceberg, lendingstate.Margin:
+		return true
+	}
+	return false
+}
+
+// recordCircuitBreakerTrades adds the trades matched by a lending item to the medium interest of
+// the block, tripping the circuit breaker of the lending book on an extreme move.
+func recordCircuitBreakerTrades(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, trades []*lendingstate.LendingTrade) {
+	for _, trade := range trades {
+		if trade == nil {
+			continue
+		}
+		if lendingStateDB.RecordCircuitBreakerTrade(lendingOrderBook, header.Number.Uint64(), trade.Interest, trade.Amount) {
+			breaker := lendingStateDB.GetCircuitBreaker(lendingOrderBook)
+			log.Info("Lending circuit breaker tripped", "lendingBook", lendingOrderBook.Hex(), "number", header.Number, "interest", trade.Interest, "reference", breaker.Reference, "pausedUntil", breaker.PausedUntil)
+			return
+		}
+	}
+}
+
+// circuitBreaker returns the circuit breaker of a lending book at the current block.
+func (l *Lending) circuitBreaker(lendingToken common.Address, term uint64) (*lendingstate.LendingCircuitBreaker, error) {
+	_, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	breaker := lendingState.GetCircuitBreaker(lendingstate.GetLendingOrderBookHash(lendingToken, term))
+	return &breaker, nil
+}
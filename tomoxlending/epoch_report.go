@@ -0,0 +1,285 @@
+package tomoxlending
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Non-SDK nodes started with --tomox.lendingindex keep a settlement report of each checkpoint
+// epoch in the tomox leveldb, for the relayers to reconcile their revenue. Epoch n spans the
+// blocks (n-1)*Epoch+1 to the checkpoint block n*Epoch:
+//   - the settlement of each block with lending activity is recorded by block number and hash
+//   - at the checkpoint block, the settlements of the epoch are summed along the chain of the
+//     checkpoint, so that the report only depends on the canonical blocks, and recorded by epoch
+//
+// A report has, by lending book, the volume and number of the matched trades, the interest paid by
+// the repayments and the liquidated trades, and by relayer and lending token the fees charged.
+var (
+	epochSettlementPrefix = []byte("lendingEpochSettlement-") // epochSettlementPrefix + number + hash -> EpochReport of the block
+	epochReportPrefix     = []byte("lendingEpochReport-")     // epochReportPrefix + epoch -> EpochReport
+)
+
+var errEpochReportUnavailable = errors.New("epoch reports need a checkpoint epoch length")
+
+// EpochBookReport is the settlement of a lending book over an epoch.
+type EpochBookReport struct {
+	LendingToken     common.Address `json:"lendingToken"`
+	Term             uint64         `json:"term"`
+	MatchedVolume    *big.Int       `json:"matchedVolume"`
+	Trades           uint64         `json:"trades"`
+	InterestPaid     *big.Int       `json:"interestPaid"`
+	Liquidations     uint64         `json:"liquidations"`
+	LiquidatedVolume *big.Int       `json:"liquidatedVolume"`
+}
+
+// EpochRelayerFees are the fees charged by a relayer in a lending token over an epoch.
+type EpochRelayerFees struct {
+	Relayer      common.Address `json:"relayer"`
+	LendingToken common.Address `json:"lendingToken"`
+	BorrowingFee *big.Int       `json:"borrowingFee"`
+	InvestingFee *big.Int       `json:"investingFee"`
+}
+
+// EpochReport is the settlement report of a checkpoint epoch, its books and relayers sorted by
+// lending token, term and relayer address.
+type EpochReport struct {
+	Epoch     uint64              `json:"epoch"`
+	FromBlock uint64              `json:"fromBlock"`
+	ToBlock   uint64              `json:"toBlock"`
+	BlockHash common.Hash         `json:"blockHash"` // hash of the checkpoint block
+	Books     []*EpochBookReport  `json:"books"`
+	Relayers  []*EpochRelayerFees `json:"relayers"`
+}
+
+func epochSettlementKey(number uint64, hash common.Hash) []byte {
+	key := make([]byte, len(epochSettlementPrefix)+8+common.HashLength)
+	copy(key, epochSettlementPrefix)
+	binary.BigEndian.PutUint64(key[len(epochSettlementPrefix):], number)
+	copy(key[len(epochSettlementPrefix)+8:], hash.Bytes())
+	return key
+}
+
+func epochReportKey(epoch uint64) []byte {
+	key := make([]byte, len(epochReportPrefix)+8)
+	copy(key, epochReportPrefix)
+	binary.BigEndian.PutUint64(key[len(epochReportPrefix):], epoch)
+	return key
+}
+
+func (r *EpochReport) book(lendingToken common.Address, term uint64) *EpochBookReport {
+	for _, book := range r.Books {
+		if book.LendingToken == lendingToken && book.Term == term {
+			return book
+		}
+	}
+	book := &EpochBookReport{LendingToken: lendingToken, Term: term, MatchedVolume: new(big.Int), InterestPaid: new(big.Int), LiquidatedVolume: new(big.Int)}
+	r.Books = append(r.Books, book)
+	return book
+}
+
+func (r *EpochReport) relayer(relayer common.Address, lendingToken common.Address) *EpochRelayerFees {
+	for _, fees := range r.Relayers {
+		if fees.Relayer == relayer && fees.LendingToken == lendingToken {
+			return fees
+		}
+	}
+	fees := &EpochRelayerFees{Relayer: relayer, LendingToken: lendingToken, BorrowingFee: new(big.Int), InvestingFee: new(big.Int)}
+	r.Relayers = append(r.Relayers, fees)
+	return fees
+}
+
+// addMatchedTrade adds a trade matched by a lending order.
+func (r *EpochReport) addMatchedTrade(trade *lendingstate.LendingTrade) {
+	book := r.book(trade.LendingToken, trade.Term)
+	if trade.Amount != nil {
+		book.MatchedVolume.Add(book.MatchedVolume, trade.Amount)
+	}
+	book.Trades++
+	if trade.BorrowingFee != nil && trade.BorrowingFee.Sign() > 0 {
+		fees := r.relayer(trade.BorrowingRelayer, trade.LendingToken)
+		fees.BorrowingFee.Add(fees.BorrowingFee, trade.BorrowingFee)
+	}
+	if trade.InvestingFee != nil && trade.InvestingFee.Sign() > 0 {
+		fees := r.relayer(trade.InvestingRelayer, trade.LendingToken)
+		fees.InvestingFee.Add(fees.InvestingFee, trade.InvestingFee)
+	}
+}
+
+// addSettledTrade adds a trade repaid or liquidated by a repayment or the liquidation of a block.
+func (r *EpochReport) addSettledTrade(trade *lendingstate.LendingTrade) {
+	if trade.Status == lendingstate.TradeStatusLiquidated {
+		book := r.book(trade.LendingToken, trade.Term)
+		book.Liquidations++
+		if trade.Amount != nil {
+			book.LiquidatedVolume.Add(book.LiquidatedVolume, trade.Amount)
+		}
+		return
+	}
+	var repayment struct {
+		Profit *big.Int
+	}
+	if trade.ExtraData == "" || json.Unmarshal([]byte(trade.ExtraData), &repayment) != nil || repayment.Profit == nil || repayment.Profit.Sign() <= 0 {
+		return
+	}
+	book := r.book(trade.LendingToken, trade.Term)
+	book.InterestPaid.Add(book.InterestPaid, repayment.Profit)
+}
+
+// merge adds the settlement of a block to the report.
+func (r *EpochReport) merge(settlement *EpochReport) {
+	for _, book := range settlement.Books {
+		total := r.book(book.LendingToken, book.Term)
+		total.MatchedVolume.Add(total.MatchedVolume, book.MatchedVolume)
+		total.Trades += book.Trades
+		total.InterestPaid.Add(total.InterestPaid, book.InterestPaid)
+		total.Liquidations += book.Liquidations
+		total.LiquidatedVolume.Add(total.LiquidatedVolume, book.LiquidatedVolume)
+	}
+	for _, fees := range settlement.Relayers {
+		total := r.relayer(fees.Relayer, fees.LendingToken)
+		total.BorrowingFee.Add(total.BorrowingFee, fees.BorrowingFee)
+		total.InvestingFee.Add(total.InvestingFee, fees.InvestingFee)
+	}
+}
+
+// sort orders the books and relayers of the report, so that its encoding is deterministic.
+func (r *EpochReport) sort() {
+	sort.Slice(r.Books, func(i, j int) bool {
+		if c := bytes.Compare(r.Books[i].LendingToken.Bytes(), r.Books[j].LendingToken.Bytes()); c != 0 {
+			return c < 0
+		}
+		return r.Books[i].Term < r.Books[j].Term
+	})
+	sort.Slice(r.Relayers, func(i, j int) bool {
+		if c := bytes.Compare(r.Relayers[i].Relayer.Bytes(), r.Relayers[j].Relayer.Bytes()); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(r.Relayers[i].LendingToken.Bytes(), r.Relayers[j].LendingToken.Bytes()) < 0
+	})
+}
+
+// blockSettlement returns the settlement of the lending items of a block, with their trades, and
+// of its finalized trades.
+func blockSettlement(items []*lendingstate.LendingItem, trades [][]*lendingstate.LendingTrade, finalizedTrades map[common.Hash]*lendingstate.LendingTrade) *EpochReport {
+	settlement := &EpochReport{}
+	for i, item := range items {
+		isOrder := item.Type == lendingstate.Limit || item.Type == lendingstate.Market || item.Type == lendingstate.StopLimit || item.Type == lendingstate.Iceberg
+		for _, trade := range trades[i] {
+			if trade == nil {
+				continue
+			}
+			if isOrder && item.Status == lendingstate.LendingStatusNew {
+				settlement.addMatchedTrade(trade)
+				continue
+			}
+			settlement.addSettledTrade(trade)
+		}
+	}
+	for _, trade := range finalizedTrades {
+		settlement.addSettledTrade(trade)
+	}
+	settlement.sort()
+	return settlement
+}
+
+func (l *Lending) epochLength() uint64 {
+	if l.chain == nil || l.chain.Config() == nil || l.chain.Config().Posv == nil {
+		return 0
+	}
+	return l.chain.Config().Posv.Epoch
+}
+
+// IndexEpochReport records the settlement of the lending items of a block, with their trades, and
+// of its finalized trades, and the report of the epoch if the block is a checkpoint.
+func (l *Lending) IndexEpochReport(block *types.Block, items []*lendingstate.LendingItem, trades [][]*lendingstate.LendingTrade, finalizedTrades map[common.Hash]*lendingstate.LendingTrade) error {
+	if !l.HasLendingIndex() {
+		return nil
+	}
+	epochLength := l.epochLength()
+	if epochLength == 0 {
+		return nil
+	}
+	db := l.GetLevelDB()
+	settlement := blockSettlement(items, trades, finalizedTrades)
+	if len(settlement.Books) > 0 || len(settlement.Relayers) > 0 {
+		data, err := json.Marshal(settlement)
+		if err != nil {
+			return err
+		}
+		if err := db.Put(epochSettlementKey(block.NumberU64(), block.Hash()), data); err != nil {
+			return err
+		}
+	}
+	if block.NumberU64() == 0 || block.NumberU64()%epochLength != 0 {
+		return nil
+	}
+	report := &EpochReport{
+		Epoch:     block.NumberU64() / epochLength,
+		FromBlock: block.NumberU64() - epochLength + 1,
+		ToBlock:   block.NumberU64(),
+		BlockHash: block.Hash(),
+		Books:     []*EpochBookReport{},
+		Relayers:  []*EpochRelayerFees{},
+	}
+	number, hash := block.NumberU64(), block.Hash()
+	for number >= report.FromBlock {
+		data, err := db.Get(epochSettlementKey(number, hash))
+		if err == nil && len(data) > 0 {
+			var blockReport EpochReport
+			if err := json.Unmarshal(data, &blockReport); err != nil {
+				return err
+			}
+			report.merge(&blockReport)
+		}
+		if number == report.FromBlock {
+			break
+		}
+		header := l.chain.GetHeader(hash, number)
+		if header == nil {
+			return fmt.Errorf("header %d %x of epoch %d not found", number, hash, report.Epoch)
+		}
+		number, hash = number-1, header.ParentHash
+	}
+	report.sort()
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return db.Put(epochReportKey(report.Epoch), data)
+}
+
+// getEpochReport returns the settlement report of a checkpoint epoch of the canonical chain.
+func (l *Lending) getEpochReport(epoch uint64) (*EpochReport, error) {
+	if !l.HasLendingIndex() {
+		return nil, errLendingHistoryUnavailable
+	}
+	if l.chain == nil {
+		return nil, errLendingStateUnavailable
+	}
+	epochLength := l.epochLength()
+	if epochLength == 0 {
+		return nil, errEpochReportUnavailable
+	}
+	data, err := l.GetLevelDB().Get(epochReportKey(epoch))
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("epoch report %d not found", epoch)
+	}
+	report := &EpochReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, err
+	}
+	// skip the report of a checkpoint reorganised out of the canonical chain
+	if block := l.chain.GetBlockByNumber(report.ToBlock); block == nil || block.Hash() != report.BlockHash {
+		return nil, fmt.Errorf("epoch report %d not found", epoch)
+	}
+	return report, nil
+}
@@ -0,0 +1,93 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// epochTestChain serves a canonical chain with checkpoints every two blocks.
+type epochTestChain struct {
+	logsTestChain
+}
+
+func (c *epochTestChain) Config() *params.ChainConfig {
+	return &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 2}}
+}
+
+func (c *epochTestChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if block := c.GetBlockByHash(hash); block != nil && block.NumberU64() == number {
+		return block.Header()
+	}
+	return nil
+}
+
+func TestEpochReport(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir(), LendingIndex: true}))
+	var (
+		usdt      = common.HexToAddress("0x10")
+		relayerA  = common.HexToAddress("0x31")
+		relayerB  = common.HexToAddress("0x32")
+		chain     = &epochTestChain{}
+		parent    = common.Hash{}
+		limit     = &lendingstate.LendingItem{Type: lendingstate.Limit, Status: lendingstate.LendingStatusNew}
+		repay     = &lendingstate.LendingItem{Type: lendingstate.Repay}
+		newTrades = func(amount int64) []*lendingstate.LendingTrade {
+			return []*lendingstate.LendingTrade{{LendingToken: usdt, Term: 86400, Amount: big.NewInt(amount), BorrowingRelayer: relayerA, InvestingRelayer: relayerB, BorrowingFee: big.NewInt(2), InvestingFee: big.NewInt(1), Status: lendingstate.TradeStatusOpen}}
+		}
+	)
+	for i := int64(0); i < 5; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(i), Time: big.NewInt(i), ParentHash: parent})
+		chain.blocks = append(chain.blocks, block)
+		parent = block.Hash()
+	}
+	l.chain = chain
+
+	// a block reorganised out of the canonical chain doesn't count
+	fork := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Time: big.NewInt(7), ParentHash: chain.blocks[0].Hash()})
+	if err := l.IndexEpochReport(fork, []*lendingstate.LendingItem{limit}, [][]*lendingstate.LendingTrade{newTrades(5000)}, nil); err != nil {
+		t.Fatalf("failed to index the fork: %v", err)
+	}
+	if err := l.IndexEpochReport(chain.blocks[1], []*lendingstate.LendingItem{limit, limit}, [][]*lendingstate.LendingTrade{newTrades(100), newTrades(50)}, nil); err != nil {
+		t.Fatalf("failed to index block 1: %v", err)
+	}
+	repaid := &lendingstate.LendingTrade{LendingToken: usdt, Term: 86400, Amount: big.NewInt(100), Status: lendingstate.TradeStatusClosed, ExtraData: `{"Profit":12}`}
+	liquidated := &lendingstate.LendingTrade{LendingToken: usdt, Term: 86400, Amount: big.NewInt(50), Status: lendingstate.TradeStatusLiquidated, Hash: common.HexToHash("0x2")}
+	if err := l.IndexEpochReport(chain.blocks[2], []*lendingstate.LendingItem{repay}, [][]*lendingstate.LendingTrade{{repaid, nil}}, map[common.Hash]*lendingstate.LendingTrade{liquidated.Hash: liquidated}); err != nil {
+		t.Fatalf("failed to index block 2: %v", err)
+	}
+
+	report, err := l.getEpochReport(1)
+	if err != nil {
+		t.Fatalf("failed to get the report: %v", err)
+	}
+	if report.FromBlock != 1 || report.ToBlock != 2 || report.BlockHash != chain.blocks[2].Hash() || len(report.Books) != 1 || len(report.Relayers) != 2 {
+		t.Fatalf("wrong report: %v", lendingstate.ToJSON(report))
+	}
+	book := report.Books[0]
+	if book.MatchedVolume.Int64() != 150 || book.Trades != 2 || book.InterestPaid.Int64() != 12 || book.Liquidations != 1 || book.LiquidatedVolume.Int64() != 50 {
+		t.Errorf("wrong book report: %v", lendingstate.ToJSON(book))
+	}
+	if fees := report.Relayers[0]; fees.Relayer != relayerA || fees.BorrowingFee.Int64() != 4 || fees.InvestingFee.Sign() != 0 {
+		t.Errorf("wrong fees of relayer A: %v", lendingstate.ToJSON(fees))
+	}
+	if fees := report.Relayers[1]; fees.Relayer != relayerB || fees.BorrowingFee.Sign() != 0 || fees.InvestingFee.Int64() != 2 {
+		t.Errorf("wrong fees of relayer B: %v", lendingstate.ToJSON(fees))
+	}
+
+	// an epoch without lending activity has an empty report, an epoch not reached has none
+	if err := l.IndexEpochReport(chain.blocks[4], nil, nil, nil); err != nil {
+		t.Fatalf("failed to index block 4: %v", err)
+	}
+	if report, err := l.getEpochReport(2); err != nil || len(report.Books) != 0 || len(report.Relayers) != 0 {
+		t.Errorf("wrong empty report: %v, %v", lendingstate.ToJSON(report), err)
+	}
+	if _, err := l.getEpochReport(3); err == nil {
+		t.Errorf("report of an epoch not reached")
+	}
+}
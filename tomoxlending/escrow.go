@@ -0,0 +1,147 @@
+package tomoxlending
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// EscrowBalances is the RPC representation of the escrow of an account, see lendingstate.Escrow.
+type EscrowBalances struct {
+	User        common.Address              `json:"user"`
+	BlockNumber uint64                      `json:"blockNumber"`
+	Tokens      []*lendingstate.TokenEscrow `json:"tokens"`
+}
+
+// PublicTomoXEscrowAPI provides the escrow ledger of the accounts in the tomox namespace, as it
+// spans the spot and the lending books.
+type PublicTomoXEscrowAPI struct {
+	t *Lending
+}
+
+// NewPublicTomoXEscrowAPI creates a new escrow RPC service.
+func NewPublicTomoXEscrowAPI(t *Lending) *PublicTomoXEscrowAPI {
+	return &PublicTomoXEscrowAPI{t: t}
+}
+
+// GetEscrowBalances returns the balances of an account per token as of the current block: its free
+// balance, the balance committed to its open spot orders and lending items, and the collateral
+// locked in its lending trades, so that wallets can tell its available balance from its locked one.
+func (api *PublicTomoXEscrowAPI) GetEscrowBalances(ctx context.Context, address common.Address) (*EscrowBalances, error) {
+	return api.t.escrowBalances(address)
+}
+
+// addLendingItems adds the open lending items of the account to its escrow. The collateral of a
+// borrowing item is valued at the current collateral price of its pair.
+func (l *Lending) addLendingItems(escrow *lendingstate.Escrow, header *types.Header, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) error {
+	lendingBooks, err := lendingstate.GetAllLendingBooks(statedb)
+	if err != nil {
+		// no lending book registered yet
+		return nil
+	}
+	collateralPrices := make(map[lendingstate.LendingPair]*big.Int)
+	for lendingBook := range lendingBooks {
+		if !lendingState.Exist(lendingBook) {
+			continue
+		}
+		items, err := lendingState.DumpLendingOrderTrie(lendingBook)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if item.UserAddress != escrow.User {
+				continue
+			}
+			item := item
+			remaining := new(big.Int).Add(item.Quantity, hiddenQuantity(lendingState, lendingBook, &item))
+			if item.Side == lendingstate.Investing {
+				escrow.AddLendingItem(&item, remaining, nil, nil, nil)
+				continue
+			}
+			pair := lendingstate.LendingPair{LendingToken: item.LendingToken, CollateralToken: item.CollateralToken}
+			collateralPrice, ok := collateralPrices[pair]
+			if !ok {
+				_, collateralPrice, err = l.GetCollateralPrices(header, l.chain, statedb, tradingState, item.CollateralToken, item.LendingToken)
+				if err != nil {
+					collateralPrice = nil
+				}
+				collateralPrices[pair] = collateralPrice
+			}
+			collateralTokenDecimal, err := l.tomox.GetTokenDecimal(l.chain, statedb, item.CollateralToken)
+			if err != nil {
+				return err
+			}
+			depositRate, _, _ := lendingstate.GetCollateralDetail(statedb, item.CollateralToken)
+			escrow.AddLendingItem(&item, remaining, collateralPrice, depositRate, collateralTokenDecimal)
+		}
+	}
+	return nil
+}
+
+// addEscrowedTrades adds the collaterals of the open lending trades borrowed by the account to its
+// escrow.
+func addEscrowedTrades(escrow *lendingstate.Escrow, statedb *state.StateDB, lendingState *lendingstate.LendingStateDB) error {
+	lendingBooks, err := lendingstate.GetAllLendingBooks(statedb)
+	if err != nil {
+		// no lending book registered yet
+		return nil
+	}
+	for lendingBook := range lendingBooks {
+		if !lendingState.Exist(lendingBook) {
+			continue
+		}
+		trades, err := lendingState.DumpLendingTradeTrie(lendingBook)
+		if err != nil {
+			return err
+		}
+		for _, trade := range trades {
+			if trade.Borrower != escrow.User {
+				continue
+			}
+			trade := trade
+			escrow.AddLendingTrade(&trade, lendingState.GetTradeCollaterals(lendingBook, trade.TradeId))
+		}
+	}
+	return nil
+}
+
+// escrowBalances returns the escrow of an account across the spot and lending books at the current
+// block.
+func (l *Lending) escrowBalances(user common.Address) (*EscrowBalances, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := l.tomox.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	escrow := lendingstate.NewEscrow(user)
+	if err := l.addSpotOrders(user, statedb, tradingState, escrow.AddSpotOrder); err != nil {
+		return nil, err
+	}
+	if err := l.addLendingItems(escrow, block.Header(), statedb, tradingState, lendingState); err != nil {
+		return nil, err
+	}
+	if err := addEscrowedTrades(escrow, statedb, lendingState); err != nil {
+		return nil, err
+	}
+	escrow.Token(common.HexToAddress(common.TomoNativeAddress))
+	for _, tokenEscrow := range escrow.Tokens() {
+		tokenEscrow.Available = lendingstate.GetTokenBalance(user, tokenEscrow.Token, statedb)
+	}
+	return &EscrowBalances{User: user, BlockNumber: block.NumberU64(), Tokens: escrow.Tokens()}, nil
+}
@@ -0,0 +1,117 @@
+package tomoxlending
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// LendingFilter restricts lending subscriptions to a user and/or a lending pair.
+// Zero valued fields match everything.
+type LendingFilter struct {
+	UserAddress     common.Address `json:"userAddress"`
+	LendingToken    common.Address `json:"lendingToken"`
+	CollateralToken common.Address `json:"collateralToken"`
+	Term            uint64         `json:"term"`
+	Relayer         common.Address `json:"relayer"` // exchange address of the relayer
+}
+
+func (f *LendingFilter) matchPair(lendingToken, collateralToken common.Address, term uint64) bool {
+	if f.LendingToken != (common.Address{}) && f.LendingToken != lendingToken {
+		return false
+	}
+	// collateral token is not known for investing items
+	if f.CollateralToken != (common.Address{}) && collateralToken != (common.Address{}) && f.CollateralToken != collateralToken {
+		return false
+	}
+	if f.Term != 0 && f.Term != term {
+		return false
+	}
+	return true
+}
+
+// MatchItem returns whether the lending item passes the filter.
+func (f *LendingFilter) MatchItem(item *lendingstate.LendingItem) bool {
+	if f.UserAddress != (common.Address{}) && f.UserAddress != item.UserAddress {
+		return false
+	}
+	if f.Relayer != (common.Address{}) && f.Relayer != item.Relayer {
+		return false
+	}
+	return f.matchPair(item.LendingToken, item.CollateralToken, item.Term)
+}
+
+// MatchTrade returns whether the lending trade passes the filter.
+func (f *LendingFilter) MatchTrade(trade *lendingstate.LendingTrade) bool {
+	if f.UserAddress != (common.Address{}) && f.UserAddress != trade.Borrower && f.UserAddress != trade.Investor {
+		return false
+	}
+	if f.Relayer != (common.Address{}) && f.Relayer != trade.BorrowingRelayer && f.Relayer != trade.InvestingRelayer {
+		return false
+	}
+	return f.matchPair(trade.LendingToken, trade.CollateralToken, trade.Term)
+}
+
+// SubscribeLendingTrades registers a subscription for new lending trades recorded by the SDK node.
+func (l *Lending) SubscribeLendingTrades(ch chan<- *lendingstate.LendingTrade) event.Subscription {
+	return l.scope.Track(l.tradeFeed.Subscribe(ch))
+}
+
+// SubscribeLendingItems registers a subscription for lending item updates recorded by the SDK node.
+func (l *Lending) SubscribeLendingItems(ch chan<- *lendingstate.LendingItem) event.Subscription {
+	return l.scope.Track(l.itemFeed.Subscribe(ch))
+}
+
+// SubscribeLiquidations registers a subscription for lending trades liquidated by the SDK node.
+func (l *Lending) SubscribeLiquidations(ch chan<- *lendingstate.LendingTrade) event.Subscription {
+	return l.scope.Track(l.liquidationFeed.Subscribe(ch))
+}
+
+// newLendingEventQueue returns the queue forwarding the lending events of a subscription to send,
+// so that a slow subscriber doesn't block the posting of the events, which happens during the
+// chain import.
+func newLendingEventQueue(send func(interface{}) error) *tomox.EventQueue {
+	return tomox.NewEventQueue(lendingEventQueueSize, send)
+}
+
+// Topics of the event sink the SDK node publishes the committed lending records to,
+// after the topic prefix (see tomox.Config.EventSinkTopic).
+const (
+	lendingTradesTopic = "lendingTrades"
+	lendingItemsTopic  = "lendingItems"
+	liquidationsTopic  = "lendingLiquidations"
+)
+
+// postLendingTrades notifies subscribers about committed lending trades.
+func (l *Lending) postLendingTrades(trades []*lendingstate.LendingTrade) {
+	for _, trade := range trades {
+		l.tradeFeed.Send(trade)
+		l.tomox.PublishEvent(lendingTradesTopic, trade.Hash, trade)
+	}
+}
+
+// postLendingItems notifies subscribers about committed lending items, once per item
+// with its latest state.
+func (l *Lending) postLendingItems(items []*lendingstate.LendingItem) {
+	latest := make(map[common.Hash]int, len(items))
+	for i, item := range items {
+		latest[item.Hash] = i
+	}
+	for i, item := range items {
+		if latest[item.Hash] == i {
+			l.itemFeed.Send(item)
+			l.tomox.PublishEvent(lendingItemsTopic, item.Hash, item)
+		}
+	}
+}
+
+// postLiquidations notifies subscribers about the liquidated trades among the committed ones.
+func (l *Lending) postLiquidations(trades map[common.Hash]*lendingstate.LendingTrade) {
+	for _, trade := range trades {
+		if trade.Status == lendingstate.TradeStatusLiquidated {
+			l.liquidationFeed.Send(trade)
+			l.tomox.PublishEvent(liquidationsTopic, trade.Hash, trade)
+		}
+	}
+}
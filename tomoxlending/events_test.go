@@ -0,0 +1,45 @@
+package tomoxlending
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"testing"
+)
+
+func TestLendingFilter_MatchTrade(t *testing.T) {
+	borrower := common.HexToAddress("0x1")
+	investor := common.HexToAddress("0x2")
+	lendingToken := common.HexToAddress("0x3")
+	trade := &lendingstate.LendingTrade{
+		Borrower:         borrower,
+		Investor:         investor,
+		LendingToken:     lendingToken,
+		CollateralToken:  common.HexToAddress("0x4"),
+		Term:             86400,
+		BorrowingRelayer: common.HexToAddress("0x6"),
+		InvestingRelayer: common.HexToAddress("0x7"),
+	}
+	tests := []struct {
+		name   string
+		filter LendingFilter
+		want   bool
+	}{
+		{"empty filter", LendingFilter{}, true},
+		{"borrower", LendingFilter{UserAddress: borrower}, true},
+		{"investor", LendingFilter{UserAddress: investor}, true},
+		{"other user", LendingFilter{UserAddress: common.HexToAddress("0x5")}, false},
+		{"lending pair", LendingFilter{LendingToken: lendingToken, Term: 86400}, true},
+		{"other term", LendingFilter{LendingToken: lendingToken, Term: 60}, false},
+		{"other collateral", LendingFilter{CollateralToken: common.HexToAddress("0x5")}, false},
+		{"borrowing relayer", LendingFilter{Relayer: common.HexToAddress("0x6")}, true},
+		{"investing relayer", LendingFilter{Relayer: common.HexToAddress("0x7")}, true},
+		{"other relayer", LendingFilter{Relayer: common.HexToAddress("0x5")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.MatchTrade(trade); got != tt.want {
+				t.Errorf("MatchTrade() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package tomoxlending
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// LendingFees are the fees charged when a lending item matches at its limit interest.
+// Token amounts are expressed in lending token, the relayer fee in TOMO.
+type LendingFees struct {
+	Quantity               *big.Int       `json:"quantity"`
+	Interest               uint64         `json:"interest"`
+	Term                   uint64         `json:"term"`
+	FeeRate                *big.Int       `json:"feeRate"`                // borrowing fee rate of the relayer, over TomoXBaseFee
+	BorrowingFee           *big.Int       `json:"borrowingFee"`           // paid by the borrower to the borrowing relayer
+	InvestingFee           *big.Int       `json:"investingFee"`           // paid by the investor
+	RelayerFee             *big.Int       `json:"relayerFee"`             // paid by the borrowing relayer to the masternode
+	CollateralToken        common.Address `json:"collateralToken"`        // empty for an investing item without collateral
+	CollateralLockedAmount *big.Int       `json:"collateralLockedAmount"` // nil if the collateral is unknown
+	InterestAmount         *big.Int       `json:"interestAmount"`         // owed by the borrower at the end of the term
+}
+
+// estimateLendingFees computes the fees of a lending item with the settlement of CommitOrder,
+// as if the whole quantity of the item matched. A nil collateral price estimates the fees of an
+// investing item which doesn't know the collateral of its borrower yet.
+func estimateLendingFees(isTomoXLendingFork bool, item *lendingstate.LendingItem, feeRate, lendTokenTOMOPrice, collateralPrice, depositRate, lendTokenDecimal, collateralTokenDecimal *big.Int) (*LendingFees, error) {
+	if item.Quantity == nil || item.Quantity.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid quantity %v", item.Quantity)
+	}
+	knownCollateral := collateralPrice != nil && collateralPrice.Sign() > 0
+	if !knownCollateral {
+		// the fees don't depend on the collateral
+		collateralPrice, depositRate, collateralTokenDecimal = common.BasePrice, big.NewInt(100), common.BasePrice
+	}
+	// the borrower pays the fee whichever side takes
+	settleBalance, err := lendingstate.GetSettleBalance(isTomoXLendingFork, lendingstate.Borrowing, lendTokenTOMOPrice, collateralPrice, depositRate, feeRate, item.LendingToken, item.CollateralToken, lendTokenDecimal, collateralTokenDecimal, item.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	fees := &LendingFees{
+		Quantity:        item.Quantity,
+		Interest:        item.Interest.Uint64(),
+		Term:            item.Term,
+		FeeRate:         feeRate,
+		BorrowingFee:    settleBalance.Taker.Fee,
+		InvestingFee:    settleBalance.Maker.Fee,
+		RelayerFee:      common.RelayerLendingFee,
+		CollateralToken: item.CollateralToken,
+	}
+	if knownCollateral {
+		fees.CollateralLockedAmount = settleBalance.CollateralLockedAmount
+	}
+	// a trade repaid at its liquidation time pays the interest of the whole term
+	totalRepayValue := lendingstate.CalculateTotalRepayValue(item.Term, item.Term, item.Term, fees.Interest, item.Quantity)
+	fees.InterestAmount = new(big.Int).Sub(totalRepayValue, item.Quantity)
+	return fees, nil
+}
+
+// estimateFees estimates the fees of a lending item with the prices of the current block.
+func (l *Lending) estimateFees(item *lendingstate.LendingItem) (*LendingFees, error) {
+	block, _, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	if item.Side != lendingstate.Borrowing && item.Side != lendingstate.Investing {
+		return nil, fmt.Errorf("invalid side %q", item.Side)
+	}
+	if item.Side == lendingstate.Borrowing && item.CollateralToken.String() == lendingstate.EmptyAddress {
+		return nil, fmt.Errorf("empty collateral")
+	}
+	if item.Interest == nil {
+		item.Interest = new(big.Int)
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := l.tomox.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	if valid, _ := lendingstate.IsValidPair(statedb, item.Relayer, item.LendingToken, item.Term); !valid {
+		return nil, fmt.Errorf("invalid pair. Relayer: %s. LendingToken: %s. Term: %d", item.Relayer.Hex(), item.LendingToken.Hex(), item.Term)
+	}
+	lendTokenDecimal, err := l.tomox.GetTokenDecimal(l.chain, statedb, item.LendingToken)
+	if err != nil || lendTokenDecimal.Sign() == 0 {
+		return nil, fmt.Errorf("fail to get tokenDecimal. Token: %v . Err: %v", item.LendingToken.String(), err)
+	}
+	var lendTokenTOMOPrice, collateralPrice, depositRate, collateralTokenDecimal *big.Int
+	if item.CollateralToken.String() != lendingstate.EmptyAddress {
+		depositRate, _, _ = lendingstate.GetCollateralDetail(statedb, item.CollateralToken)
+		if depositRate == nil || depositRate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid depositRate %v", depositRate)
+		}
+		collateralTokenDecimal, err = l.tomox.GetTokenDecimal(l.chain, statedb, item.CollateralToken)
+		if err != nil || collateralTokenDecimal.Sign() == 0 {
+			return nil, fmt.Errorf("fail to get tokenDecimal. Token: %v . Err: %v", item.CollateralToken.String(), err)
+		}
+		lendTokenTOMOPrice, collateralPrice, err = l.GetCollateralPrices(block.Header(), l.chain, statedb, tradingState, item.CollateralToken, item.LendingToken)
+		if err != nil {
+			return nil, err
+		}
+		if collateralPrice == nil || collateralPrice.Sign() <= 0 {
+			return nil, lendingstate.ErrInvalidCollateralPrice
+		}
+	} else {
+		lendTokenTOMOPrice, err = l.GetTOMOBasePrices(block.Header(), l.chain, statedb, tradingState, item.LendingToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if lendTokenTOMOPrice == nil || lendTokenTOMOPrice.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid lendToken price")
+	}
+	isTomoXLendingFork := l.chain.Config().IsTIPTomoXLending(block.Number())
+	return estimateLendingFees(isTomoXLendingFork, item, lendingstate.GetFee(statedb, item.Relayer), lendTokenTOMOPrice, collateralPrice, depositRate, lendTokenDecimal, collateralTokenDecimal)
+}
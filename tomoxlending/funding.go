@@ -0,0 +1,63 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// settlePeriodicInterest settles the interest of the open trades of a lending book whose interest
+// is periodic (see lendingstate.IsPeriodicInterest), once per epoch: each borrower pays the investor
+// the interest accrued by its trade since it was opened, less the interest already settled, or as
+// much of it as its balance allows. Trades are settled by trade id as borrowers may be short of
+// balance. Expired trades are left to be repaid.
+func settlePeriodicInterest(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash) error {
+	if !lendingStateDB.IsPeriodicInterest(lendingBook) {
+		return nil
+	}
+	trades, err := lendingStateDB.DumpLendingTradeTrie(lendingBook)
+	if err != nil {
+		return err
+	}
+	openTrades := []lendingstate.LendingTrade{}
+	time := header.Time.Uint64()
+	for _, trade := range trades {
+		if trade.Amount != nil && trade.Amount.Sign() > 0 && trade.LiquidationTime > time {
+			openTrades = append(openTrades, trade)
+		}
+	}
+	sort.Slice(openTrades, func(i, j int) bool {
+		return openTrades[i].TradeId < openTrades[j].TradeId
+	})
+	for _, trade := range openTrades {
+		settled := lendingStateDB.GetSettledInterest(lendingBook, trade.TradeId)
+		due := lendingstate.CalculateAccruedInterest(time, trade.LiquidationTime, trade.Term, trade.Interest, trade.Amount)
+		due.Sub(due, settled)
+		if due.Sign() <= 0 {
+			continue
+		}
+		if balance := lendingstate.GetTokenBalance(trade.Borrower, trade.LendingToken, statedb); balance.Cmp(due) < 0 {
+			// the rest is settled at the next epoch or at close
+			due = balance
+		}
+		if due.Sign() <= 0 {
+			log.Debug("Borrower can't settle periodic interest", "lendingBook", lendingBook.Hex(), "tradeId", trade.TradeId, "borrower", trade.Borrower.Hex())
+			continue
+		}
+		lendingstate.SubTokenBalance(trade.Borrower, due, trade.LendingToken, statedb)
+		lendingstate.AddTokenBalance(trade.Investor, due, trade.LendingToken, statedb)
+		lendingStateDB.SetSettledInterest(lendingBook, trade.TradeId, new(big.Int).Add(settled, due))
+		log.Debug("Settle periodic interest", "lendingBook", lendingBook.Hex(), "tradeId", trade.TradeId, "interest", due)
+	}
+	return nil
+}
+
+// clearSettledInterest drops the interest settled by a closed lending trade.
+func clearSettledInterest(lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash, lendingTradeId uint64) {
+	lendingStateDB.SetSettledInterest(lendingBook, lendingTradeId, new(big.Int))
+}
@@ -0,0 +1,84 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestSettlePeriodicInterest(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+
+	var (
+		lendingToken = common.HexToAddress(common.TomoNativeAddress)
+		term         = common.OneYear
+		lendingBook  = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		borrowerA    = common.HexToAddress("0x11")
+		borrowerB    = common.HexToAddress("0x12")
+		investor     = common.HexToAddress("0x21")
+		startTime    = uint64(1600000000)
+	)
+	for id, borrower := range map[uint64]common.Address{1: borrowerA, 2: borrowerB} {
+		trade := lendingstate.LendingTrade{
+			TradeId:         id,
+			Borrower:        borrower,
+			Investor:        investor,
+			LendingToken:    lendingToken,
+			Term:            term,
+			Interest:        new(big.Int).Mul(big.NewInt(10), common.BaseLendingInterest).Uint64(), // 10% per year
+			Amount:          big.NewInt(1000),
+			LiquidationTime: startTime + term,
+		}
+		trade.Hash = trade.ComputeHash()
+		lendingStateDB.InsertTradingItem(lendingBook, id, trade)
+	}
+	statedb.AddBalance(borrowerA, big.NewInt(100))
+	statedb.AddBalance(borrowerB, big.NewInt(30))
+	header := &types.Header{Time: new(big.Int).SetUint64(startTime + term/2)}
+
+	// the interest is settled at close until the lending book switches to periodic interest
+	if err := settlePeriodicInterest(header, lendingStateDB, statedb, lendingBook); err != nil {
+		t.Fatalf("failed to settle periodic interest: %v", err)
+	}
+	if balance := statedb.GetBalance(investor); balance.Sign() != 0 {
+		t.Fatalf("interest settled at maturity paid before close: %v", balance)
+	}
+	lendingStateDB.SetPeriodicInterest(lendingBook, true)
+	if err := settlePeriodicInterest(header, lendingStateDB, statedb, lendingBook); err != nil {
+		t.Fatalf("failed to settle periodic interest: %v", err)
+	}
+	// half a year of interest, borrower B pays what it can
+	if settled := lendingStateDB.GetSettledInterest(lendingBook, 1); settled.Int64() != 50 {
+		t.Errorf("wrong interest settled by trade 1: have %v, want 50", settled)
+	}
+	if settled := lendingStateDB.GetSettledInterest(lendingBook, 2); settled.Int64() != 30 {
+		t.Errorf("wrong interest settled by trade 2: have %v, want 30", settled)
+	}
+	if balance := statedb.GetBalance(investor); balance.Int64() != 80 {
+		t.Errorf("wrong balance of the investor: have %v, want 80", balance)
+	}
+	// settling again at the same time pays nothing more
+	if err := settlePeriodicInterest(header, lendingStateDB, statedb, lendingBook); err != nil {
+		t.Fatalf("failed to settle periodic interest: %v", err)
+	}
+	if balance := statedb.GetBalance(borrowerA); balance.Int64() != 50 {
+		t.Errorf("wrong balance of borrower A: have %v, want 50", balance)
+	}
+
+	// the unpaid interest stays due at close
+	trade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(2))
+	if repay := lendingStateDB.GetTotalRepayValue(lendingBook, &trade, startTime+term/2); repay.Int64() != 1045 {
+		t.Errorf("wrong total repay value of trade 2: have %v, want 1045", repay)
+	}
+	clearSettledInterest(lendingStateDB, lendingBook, 2)
+	if settled := lendingStateDB.GetSettledInterest(lendingBook, 2); settled.Sign() != 0 {
+		t.Errorf("settled interest of a closed trade not cleared: %v", settled)
+	}
+}
@@ -0,0 +1,228 @@
+package tomoxlending
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/tomochain/tomochain/accounts/abi"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/contracts/tomox/contract"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// maxLendingFee is the exclusive upper bound of the trade fee of a lending relayer accepted by the
+// lending registration contract, 10% over TomoXBaseFee.
+const maxLendingFee = 1000
+
+// minLendingTerm is the shortest term accepted by the lending registration contract, in seconds.
+const minLendingTerm = 60
+
+var (
+	errNoLendingPairs        = errors.New("no lending pairs")
+	errNotLendingRelayer     = errors.New("not a registered relayer")
+	errResignedRelayer       = errors.New("relayer is resigning")
+	errInvalidLendingFee     = fmt.Errorf("invalid fee, must be lower than %d", maxLendingFee)
+	errInvalidLendingTerm    = fmt.Errorf("invalid term, must be at least %d seconds", minLendingTerm)
+	errInvalidLendingRates   = errors.New("invalid rates, want 100 < liquidation rate < deposit rate < recall rate")
+	errUnsupportedCollateral = errors.New("ILO collaterals are not supported")
+)
+
+// lendingABI is the ABI of the lending registration contract, used to craft its calldata.
+var lendingABI, _ = abi.JSON(strings.NewReader(contract.LendingABI))
+
+// LendingCollateral holds the rates of a collateral, in percent of the value of the loan.
+type LendingCollateral struct {
+	Token           common.Address `json:"token"`
+	DepositRate     *big.Int       `json:"depositRate"`     // collateral locked when the loan is taken
+	LiquidationRate *big.Int       `json:"liquidationRate"` // the loan is liquidated under this rate
+	RecallRate      *big.Int       `json:"recallRate"`      // collateral above this rate may be recalled
+}
+
+// LendingPairParams is a lending book served by a relayer, with the collaterals it accepts.
+type LendingPairParams struct {
+	LendingToken common.Address      `json:"lendingToken"`
+	Term         uint64              `json:"term"`
+	Collaterals  []LendingCollateral `json:"collaterals"`
+}
+
+// LendingPairs are the lending pairs of a relayer and the parameters they are traded with, as
+// registered in the lending registration contract. The lending tokens and terms supported by
+// the contract are the ones a relayer may register pairs of.
+type LendingPairs struct {
+	Relayer         common.Address      `json:"relayer"`
+	FeeRate         *big.Int            `json:"feeRate"` // borrowing fee rate, over TomoXBaseFee
+	Pairs           []LendingPairParams `json:"pairs"`
+	LendingTokens   []common.Address    `json:"lendingTokens"`
+	Terms           []uint64            `json:"terms"`
+	Moderator       common.Address      `json:"moderator"`
+	ResignRequested bool                `json:"resignRequested"`
+}
+
+// lendingPairs reads the lending pairs of a relayer from the state.
+func lendingPairs(statedb *state.StateDB, relayer common.Address) *LendingPairs {
+	pairs := &LendingPairs{
+		Relayer:         relayer,
+		FeeRate:         lendingstate.GetFee(statedb, relayer),
+		Pairs:           []LendingPairParams{},
+		LendingTokens:   lendingstate.GetSupportedBaseToken(statedb),
+		Terms:           lendingstate.GetSupportedTerms(statedb),
+		Moderator:       lendingstate.GetModerator(statedb),
+		ResignRequested: tradingstate.IsResignedRelayer(relayer, statedb),
+	}
+	bases, terms := lendingstate.GetBaseList(statedb, relayer), lendingstate.GetTerms(statedb, relayer)
+	for i := 0; i < len(bases) && i < len(terms); i++ {
+		pair := LendingPairParams{LendingToken: bases[i], Term: terms[i], Collaterals: []LendingCollateral{}}
+		collaterals, _ := lendingstate.GetCollaterals(statedb, relayer, bases[i], terms[i])
+		for _, token := range collaterals {
+			depositRate, liquidationRate, recallRate := lendingstate.GetCollateralDetail(statedb, token)
+			pair.Collaterals = append(pair.Collaterals, LendingCollateral{Token: token, DepositRate: depositRate, LiquidationRate: liquidationRate, RecallRate: recallRate})
+		}
+		pairs.Pairs = append(pairs.Pairs, pair)
+	}
+	return pairs
+}
+
+// currentState returns the state of the current block.
+func (l *Lending) currentState() (*state.StateDB, error) {
+	if l.chain == nil {
+		return nil, errLendingStateUnavailable
+	}
+	return l.chain.StateAt(l.chain.CurrentBlock().Root())
+}
+
+// getLendingPairs returns the lending pairs of a relayer in the current state.
+func (l *Lending) getLendingPairs(relayer common.Address) (*LendingPairs, error) {
+	statedb, err := l.currentState()
+	if err != nil {
+		return nil, err
+	}
+	return lendingPairs(statedb, relayer), nil
+}
+
+// craftGovernanceTx crafts a transaction to the lending registration contract, checked against
+// the current state.
+func (l *Lending) craftGovernanceTx(craft func(statedb *state.StateDB) (*GovernanceTx, error)) (*GovernanceTx, error) {
+	statedb, err := l.currentState()
+	if err != nil {
+		return nil, err
+	}
+	return craft(statedb)
+}
+
+// LendingPairArgs is a lending pair registered by UpdateLendingRelayer. Collateral is empty for
+// the pairs which accept the default collaterals.
+type LendingPairArgs struct {
+	LendingToken common.Address `json:"lendingToken"`
+	Term         hexutil.Uint64 `json:"term"`
+	Collateral   common.Address `json:"collateral"`
+}
+
+// GovernanceTx is an unsigned call to the lending registration contract, to be sent from the
+// relayer owner or the moderator, e.g. with eth_sendTransaction.
+type GovernanceTx struct {
+	From common.Address `json:"from"`
+	To   common.Address `json:"to"`
+	Data hexutil.Bytes  `json:"data"`
+}
+
+// newGovernanceTx packs a call of a method of the lending registration contract.
+func newGovernanceTx(from common.Address, method string, args ...interface{}) (*GovernanceTx, error) {
+	data, err := lendingABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &GovernanceTx{From: from, To: common.HexToAddress(common.LendingRegistrationSMC), Data: data}, nil
+}
+
+// relayerOwner returns the owner of a relayer allowed to update its lending pairs.
+func relayerOwner(statedb *state.StateDB, coinbase common.Address) (common.Address, error) {
+	owner := tradingstate.GetRelayerOwner(coinbase, statedb)
+	if owner == (common.Address{}) {
+		return common.Address{}, errNotLendingRelayer
+	}
+	if tradingstate.IsResignedRelayer(coinbase, statedb) {
+		return common.Address{}, errResignedRelayer
+	}
+	return owner, nil
+}
+
+// updateLendingRelayerTx crafts the transaction registering the fee and the lending pairs of a
+// relayer, which replace the pairs registered before. The pairs are checked against the lending
+// tokens and terms supported by the contract.
+func updateLendingRelayerTx(statedb *state.StateDB, coinbase common.Address, fee uint64, pairs []LendingPairArgs) (*GovernanceTx, error) {
+	owner, err := relayerOwner(statedb, coinbase)
+	if err != nil {
+		return nil, err
+	}
+	if fee >= maxLendingFee {
+		return nil, errInvalidLendingFee
+	}
+	if len(pairs) == 0 {
+		return nil, errNoLendingPairs
+	}
+	lendingTokens := make(map[common.Address]bool)
+	for _, token := range lendingstate.GetSupportedBaseToken(statedb) {
+		lendingTokens[token] = true
+	}
+	terms := make(map[uint64]bool)
+	for _, term := range lendingstate.GetSupportedTerms(statedb) {
+		terms[term] = true
+	}
+	var (
+		bases       = make([]common.Address, len(pairs))
+		pairTerms   = make([]*big.Int, len(pairs))
+		collaterals = make([]common.Address, len(pairs))
+	)
+	for i, pair := range pairs {
+		if !lendingTokens[pair.LendingToken] {
+			return nil, fmt.Errorf("pair %d: unsupported lending token %s", i, pair.LendingToken.Hex())
+		}
+		if !terms[uint64(pair.Term)] {
+			return nil, fmt.Errorf("pair %d: unsupported term %d", i, pair.Term)
+		}
+		if pair.Collateral != (common.Address{}) {
+			return nil, fmt.Errorf("pair %d: %v", i, errUnsupportedCollateral)
+		}
+		bases[i], pairTerms[i], collaterals[i] = pair.LendingToken, new(big.Int).SetUint64(uint64(pair.Term)), pair.Collateral
+	}
+	return newGovernanceTx(owner, "update", coinbase, uint16(fee), bases, pairTerms, collaterals)
+}
+
+// updateLendingFeeTx crafts the transaction updating the fee of a relayer.
+func updateLendingFeeTx(statedb *state.StateDB, coinbase common.Address, fee uint64) (*GovernanceTx, error) {
+	owner, err := relayerOwner(statedb, coinbase)
+	if err != nil {
+		return nil, err
+	}
+	if fee >= maxLendingFee {
+		return nil, errInvalidLendingFee
+	}
+	return newGovernanceTx(owner, "updateFee", coinbase, uint16(fee))
+}
+
+// addLendingTokenTx crafts the transaction of the moderator adding a lending token.
+func addLendingTokenTx(statedb *state.StateDB, token common.Address) (*GovernanceTx, error) {
+	return newGovernanceTx(lendingstate.GetModerator(statedb), "addBaseToken", token)
+}
+
+// addLendingTermTx crafts the transaction of the moderator adding a term.
+func addLendingTermTx(statedb *state.StateDB, term uint64) (*GovernanceTx, error) {
+	if term < minLendingTerm {
+		return nil, errInvalidLendingTerm
+	}
+	return newGovernanceTx(lendingstate.GetModerator(statedb), "addTerm", new(big.Int).SetUint64(term))
+}
+
+// setCollateralTx crafts the transaction of the moderator adding a collateral or updating its
+// rates.
+func setCollateralTx(statedb *state.StateDB, token common.Address, depositRate, liquidationRate, recallRate uint64) (*GovernanceTx, error) {
+	if liquidationRate <= 100 || depositRate <= liquidationRate || recallRate <= depositRate {
+		return nil, errInvalidLendingRates
+	}
+	return newGovernanceTx(lendingstate.GetModerator(statedb), "addCollateral", token, new(big.Int).SetUint64(depositRate), new(big.Int).SetUint64(liquidationRate), new(big.Int).SetUint64(recallRate))
+}
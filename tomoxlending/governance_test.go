@@ -0,0 +1,121 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// setStateArray writes a dynamic array at a storage location of the lending contract.
+func setStateArray(statedb *state.StateDB, loc common.Hash, elements ...common.Hash) {
+	lendingContract := common.HexToAddress(common.LendingRegistrationSMC)
+	statedb.SetState(lendingContract, loc, common.BigToHash(big.NewInt(int64(len(elements)))))
+	for i, element := range elements {
+		statedb.SetState(lendingContract, state.GetLocDynamicArrAtElement(loc, uint64(i), 1), element)
+	}
+}
+
+func TestGovernance(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		lendingContract = common.HexToAddress(common.LendingRegistrationSMC)
+		relayer         = common.HexToAddress("0x11")
+		owner           = common.HexToAddress("0x12")
+		moderator       = common.HexToAddress("0x13")
+		usdt            = common.HexToAddress("0x21")
+		btc             = common.HexToAddress("0x22")
+		collateral      = common.HexToAddress("0x31")
+	)
+	locRelayer := state.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	statedb.SetState(common.HexToAddress(common.RelayerRegistrationSMC), state.GetLocOfStructElement(locRelayer, tradingstate.RelayerStructMappingSlot["_owner"]), owner.Hash())
+	statedb.SetState(lendingContract, state.GetLocSimpleVariable(lendingstate.ModeratorSlot), moderator.Hash())
+
+	locLendingRelayer := state.GetLocMappingAtKey(relayer.Hash(), lendingstate.LendingRelayerListSlot)
+	statedb.SetState(lendingContract, state.GetLocOfStructElement(locLendingRelayer, lendingstate.LendingRelayerStructSlots["fee"]), common.BigToHash(big.NewInt(10)))
+	setStateArray(statedb, state.GetLocOfStructElement(locLendingRelayer, lendingstate.LendingRelayerStructSlots["bases"]), usdt.Hash(), btc.Hash())
+	setStateArray(statedb, state.GetLocOfStructElement(locLendingRelayer, lendingstate.LendingRelayerStructSlots["terms"]), common.Uint64ToHash(86400), common.Uint64ToHash(60))
+	setStateArray(statedb, state.GetLocSimpleVariable(lendingstate.SupportedBaseSlot), usdt.Hash(), btc.Hash())
+	setStateArray(statedb, state.GetLocSimpleVariable(lendingstate.SupportedTermSlot), common.Uint64ToHash(60), common.Uint64ToHash(86400))
+	setStateArray(statedb, state.GetLocSimpleVariable(lendingstate.DefaultCollateralSlot), collateral.Hash())
+	locCollateral := lendingstate.GetLocMappingAtKey(collateral.Hash(), lendingstate.CollateralMapSlot)
+	statedb.SetState(lendingContract, state.GetLocOfStructElement(locCollateral, lendingstate.CollateralStructSlots["depositRate"]), common.BigToHash(big.NewInt(150)))
+	statedb.SetState(lendingContract, state.GetLocOfStructElement(locCollateral, lendingstate.CollateralStructSlots["liquidationRate"]), common.BigToHash(big.NewInt(110)))
+	statedb.SetState(lendingContract, state.GetLocOfStructElement(locCollateral, lendingstate.CollateralStructSlots["recallRate"]), common.BigToHash(big.NewInt(200)))
+
+	pairs := lendingPairs(statedb, relayer)
+	if pairs.FeeRate.Int64() != 10 || pairs.Moderator != moderator || pairs.ResignRequested {
+		t.Fatalf("wrong relayer parameters: %v", lendingstate.ToJSON(pairs))
+	}
+	if len(pairs.Pairs) != 2 || pairs.Pairs[0].LendingToken != usdt || pairs.Pairs[0].Term != 86400 || pairs.Pairs[1].LendingToken != btc || pairs.Pairs[1].Term != 60 {
+		t.Fatalf("wrong pairs: %v", lendingstate.ToJSON(pairs.Pairs))
+	}
+	if c := pairs.Pairs[1].Collaterals; len(c) != 1 || c[0].Token != collateral || c[0].DepositRate.Int64() != 150 || c[0].LiquidationRate.Int64() != 110 || c[0].RecallRate.Int64() != 200 {
+		t.Fatalf("wrong collaterals: %v", lendingstate.ToJSON(c))
+	}
+	if len(pairs.LendingTokens) != 2 || len(pairs.Terms) != 2 {
+		t.Fatalf("wrong supported tokens and terms: %v", lendingstate.ToJSON(pairs))
+	}
+
+	// the relayer update replaces its pairs
+	tx, err := updateLendingRelayerTx(statedb, relayer, 20, []LendingPairArgs{{LendingToken: btc, Term: 86400}})
+	if err != nil {
+		t.Fatalf("failed to craft update: %v", err)
+	}
+	if tx.From != owner || tx.To != lendingContract {
+		t.Fatalf("wrong update sender or recipient: %v", lendingstate.ToJSON(tx))
+	}
+	method := lendingABI.Methods["update"]
+	if string(tx.Data[:4]) != string(method.Id()) {
+		t.Fatalf("wrong update method id %x", tx.Data[:4])
+	}
+	values, err := method.Inputs.UnpackValues(tx.Data[4:])
+	if err != nil {
+		t.Fatalf("failed to unpack update: %v", err)
+	}
+	if values[0].(common.Address) != relayer || values[1].(uint16) != 20 || values[2].([]common.Address)[0] != btc || values[3].([]*big.Int)[0].Uint64() != 86400 {
+		t.Fatalf("wrong update arguments: %v", values)
+	}
+
+	for i, test := range []struct {
+		coinbase common.Address
+		fee      uint64
+		pairs    []LendingPairArgs
+	}{
+		{common.HexToAddress("0x14"), 20, []LendingPairArgs{{LendingToken: btc, Term: 86400}}}, // unknown relayer
+		{relayer, 1000, []LendingPairArgs{{LendingToken: btc, Term: 86400}}},                   // fee too high
+		{relayer, 20, nil}, // no pair
+		{relayer, 20, []LendingPairArgs{{LendingToken: collateral, Term: 86400}}},                  // unsupported lending token
+		{relayer, 20, []LendingPairArgs{{LendingToken: btc, Term: 3600}}},                          // unsupported term
+		{relayer, 20, []LendingPairArgs{{LendingToken: btc, Term: 86400, Collateral: collateral}}}, // ILO collateral
+	} {
+		if _, err := updateLendingRelayerTx(statedb, test.coinbase, test.fee, test.pairs); err == nil {
+			t.Errorf("test %d: invalid update crafted", i)
+		}
+	}
+
+	// moderator transactions
+	if tx, err := addLendingTermTx(statedb, 3600); err != nil || tx.From != moderator {
+		t.Fatalf("failed to craft term: %v", err)
+	}
+	if _, err := addLendingTermTx(statedb, 59); err != errInvalidLendingTerm {
+		t.Errorf("wrong error for a short term: %v", err)
+	}
+	if _, err := setCollateralTx(statedb, collateral, 150, 150, 200); err != errInvalidLendingRates {
+		t.Errorf("wrong error for invalid rates: %v", err)
+	}
+	if tx, err := setCollateralTx(statedb, collateral, 150, 120, 200); err != nil || string(tx.Data[:4]) != string(lendingABI.Methods["addCollateral"].Id()) {
+		t.Fatalf("failed to craft collateral: %v", err)
+	}
+
+	// a resigning relayer can't be updated
+	locResign := state.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RESIGN_REQUESTS"])
+	statedb.SetState(common.HexToAddress(common.RelayerRegistrationSMC), common.BigToHash(locResign), common.BigToHash(big.NewInt(1)))
+	if _, err := updateLendingFeeTx(statedb, relayer, 20); err != errResignedRelayer {
+		t.Errorf("wrong error for a resigning relayer: %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+package tomoxlending
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingpb"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errSlowSubscriber ends the lending event streams of the clients which can't keep up with the
+// events, rather than dropping some of them silently.
+var errSlowSubscriber = status.Error(codes.ResourceExhausted, "lending events dropped, the subscriber is too slow")
+
+// chainHeadSubscriber is implemented by the chains announcing their new head blocks.
+type chainHeadSubscriber interface {
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// grpcServer serves the lending gRPC service, mirroring PublicTomoXLendingAPI.
+type grpcServer struct {
+	l   *Lending
+	api *PublicTomoXLendingAPI
+}
+
+func newGRPCServer(l *Lending) *grpcServer {
+	return &grpcServer{l: l, api: NewPublicTomoXLendingAPI(l)}
+}
+
+// startGRPC serves the lending gRPC service on a TCP address until the service stops.
+func (l *Lending) startGRPC(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	l.grpc = grpc.NewServer()
+	lendingpb.RegisterLendingServer(l.grpc, newGRPCServer(l))
+	go l.grpc.Serve(listener)
+	log.Info("Lending gRPC endpoint opened", "addr", listener.Addr())
+	return nil
+}
+
+func (s *grpcServer) SendLendingItems(ctx context.Context, req *lendingpb.SendLendingItemsRequest) (*lendingpb.SendLendingItemsReply, error) {
+	items := make([]LendingItemArgs, len(req.Items))
+	for i, item := range req.Items {
+		items[i] = lendingItemArgsFromPB(item)
+	}
+	hashes, err := s.api.SendLendingItems(ctx, items)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	reply := &lendingpb.SendLendingItemsReply{Hashes: make([][]byte, len(hashes))}
+	for i, hash := range hashes {
+		reply.Hashes[i] = hash.Bytes()
+	}
+	return reply, nil
+}
+
+func (s *grpcServer) GetOrderBook(ctx context.Context, req *lendingpb.OrderBookRequest) (*lendingpb.OrderBook, error) {
+	depth, err := s.api.GetOrderBookDepth(ctx, common.BytesToAddress(req.LendingToken), req.Term, int(req.Levels))
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return orderBookToPB(depth), nil
+}
+
+func (s *grpcServer) GetLendingTrades(ctx context.Context, req *lendingpb.LendingTradesRequest) (*lendingpb.LendingTrades, error) {
+	var relayer *common.Address
+	if len(req.Relayer) > 0 {
+		addr := common.BytesToAddress(req.Relayer)
+		relayer = &addr
+	}
+	trades, err := s.api.GetLendingTradesByUser(ctx, common.BytesToAddress(req.User), common.BytesToAddress(req.LendingToken), req.Term, req.Status, int(req.Page), int(req.Limit), relayer)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	reply := &lendingpb.LendingTrades{Trades: make([]*lendingpb.LendingTrade, len(trades))}
+	for i, trade := range trades {
+		reply.Trades[i] = lendingTradeToPB(trade)
+	}
+	return reply, nil
+}
+
+// SubscribeOrderBook sends the lending book at the current block, then again at every new head
+// block changing its levels.
+func (s *grpcServer) SubscribeOrderBook(req *lendingpb.OrderBookRequest, stream lendingpb.Lending_SubscribeOrderBookServer) error {
+	chain, ok := s.l.chain.(chainHeadSubscriber)
+	if !ok {
+		return status.Error(codes.Unimplemented, "chain head events are unavailable")
+	}
+	heads := make(chan core.ChainHeadEvent, lendingEventChanSize)
+	sub := chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	var last *lendingpb.OrderBook
+	for {
+		depth, err := s.GetOrderBook(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		if last == nil || !sameOrderBookLevels(last.Investing, depth.Investing) || !sameOrderBookLevels(last.Borrowing, depth.Borrowing) {
+			if err := stream.Send(depth); err != nil {
+				return err
+			}
+			last = depth
+		}
+		select {
+		case <-heads:
+		case err := <-sub.Err():
+			return err
+		case <-stream.Context().Done():
+			return nil
+		case <-s.l.quit:
+			return nil
+		}
+	}
+}
+
+func (s *grpcServer) SubscribeLendingTrades(req *lendingpb.LendingFilter, stream lendingpb.Lending_SubscribeLendingTradesServer) error {
+	filter := lendingFilterFromPB(req)
+	queue := newLendingEventQueue(func(ev interface{}) error {
+		return stream.Send(lendingTradeToPB(ev.(*lendingstate.LendingTrade)))
+	})
+	defer queue.Close()
+
+	trades := make(chan *lendingstate.LendingTrade, lendingEventChanSize)
+	sub := s.l.SubscribeLendingTrades(trades)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case trade := <-trades:
+			if filter.MatchTrade(trade) && !queue.Push(trade) {
+				return errSlowSubscriber
+			}
+		case err := <-sub.Err():
+			return err
+		case <-stream.Context().Done():
+			return nil
+		case <-s.l.quit:
+			return nil
+		}
+	}
+}
+
+func (s *grpcServer) SubscribeLendingItems(req *lendingpb.LendingFilter, stream lendingpb.Lending_SubscribeLendingItemsServer) error {
+	filter := lendingFilterFromPB(req)
+	queue := newLendingEventQueue(func(ev interface{}) error {
+		return stream.Send(lendingItemToPB(ev.(*lendingstate.LendingItem)))
+	})
+	defer queue.Close()
+
+	items := make(chan *lendingstate.LendingItem, lendingEventChanSize)
+	sub := s.l.SubscribeLendingItems(items)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case item := <-items:
+			if filter.MatchItem(item) && !queue.Push(item) {
+				return errSlowSubscriber
+			}
+		case err := <-sub.Err():
+			return err
+		case <-stream.Context().Done():
+			return nil
+		case <-s.l.quit:
+			return nil
+		}
+	}
+}
+
+func sameOrderBookLevels(a, b []*lendingpb.OrderBookLevel) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Interest != b[i].Interest || new(big.Int).SetBytes(a[i].Volume).Cmp(new(big.Int).SetBytes(b[i].Volume)) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func bigToPB(x *big.Int) []byte {
+	if x == nil {
+		return nil
+	}
+	return x.Bytes()
+}
+
+func timeToPB(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func lendingItemArgsFromPB(item *lendingpb.LendingItem) LendingItemArgs {
+	return LendingItemArgs{
+		AccountNonce:    hexutil.Uint64(item.Nonce),
+		Quantity:        hexutil.Big(*new(big.Int).SetBytes(item.Quantity)),
+		RelayerAddress:  common.BytesToAddress(item.Relayer),
+		UserAddress:     common.BytesToAddress(item.UserAddress),
+		CollateralToken: common.BytesToAddress(item.CollateralToken),
+		AutoTopUp:       item.AutoTopUp,
+		LendingToken:    common.BytesToAddress(item.LendingToken),
+		Term:            hexutil.Uint64(item.Term),
+		Interest:        hexutil.Uint64(item.Interest),
+		Status:          item.Status,
+		Side:            item.Side,
+		Type:            item.Type,
+		LendingId:       hexutil.Uint64(item.LendingId),
+		LendingTradeId:  hexutil.Uint64(item.LendingTradeId),
+		ExtraData:       item.ExtraData,
+		V:               hexutil.Big(*new(big.Int).SetUint64(uint64(item.V))),
+		R:               hexutil.Big(*new(big.Int).SetBytes(item.R)),
+		S:               hexutil.Big(*new(big.Int).SetBytes(item.S)),
+		Hash:            common.BytesToHash(item.Hash),
+	}
+}
+
+func lendingFilterFromPB(filter *lendingpb.LendingFilter) LendingFilter {
+	return LendingFilter{
+		UserAddress:     common.BytesToAddress(filter.UserAddress),
+		LendingToken:    common.BytesToAddress(filter.LendingToken),
+		CollateralToken: common.BytesToAddress(filter.CollateralToken),
+		Term:            filter.Term,
+		Relayer:         common.BytesToAddress(filter.Relayer),
+	}
+}
+
+func lendingItemToPB(item *lendingstate.LendingItem) *lendingpb.LendingItem {
+	msg := &lendingpb.LendingItem{
+		Quantity:        bigToPB(item.Quantity),
+		Term:            item.Term,
+		Relayer:         item.Relayer.Bytes(),
+		UserAddress:     item.UserAddress.Bytes(),
+		LendingToken:    item.LendingToken.Bytes(),
+		CollateralToken: item.CollateralToken.Bytes(),
+		AutoTopUp:       item.AutoTopUp,
+		Status:          item.Status,
+		Side:            item.Side,
+		Type:            item.Type,
+		LendingId:       item.LendingId,
+		LendingTradeId:  item.LendingTradeId,
+		ExtraData:       item.ExtraData,
+		Hash:            item.Hash.Bytes(),
+		TxHash:          item.TxHash.Bytes(),
+		FilledAmount:    bigToPB(item.FilledAmount),
+		RejectReason:    item.RejectReason,
+		CreatedAt:       timeToPB(item.CreatedAt),
+		UpdatedAt:       timeToPB(item.UpdatedAt),
+	}
+	if item.Nonce != nil {
+		msg.Nonce = item.Nonce.Uint64()
+	}
+	if item.Interest != nil {
+		msg.Interest = item.Interest.Uint64()
+	}
+	if item.Signature != nil {
+		msg.V, msg.R, msg.S = uint32(item.Signature.V), item.Signature.R.Bytes(), item.Signature.S.Bytes()
+	}
+	return msg
+}
+
+func lendingTradeToPB(trade *lendingstate.LendingTrade) *lendingpb.LendingTrade {
+	return &lendingpb.LendingTrade{
+		Hash:                   trade.Hash.Bytes(),
+		TxHash:                 trade.TxHash.Bytes(),
+		TradeId:                trade.TradeId,
+		Borrower:               trade.Borrower.Bytes(),
+		Investor:               trade.Investor.Bytes(),
+		LendingToken:           trade.LendingToken.Bytes(),
+		CollateralToken:        trade.CollateralToken.Bytes(),
+		BorrowingOrderHash:     trade.BorrowingOrderHash.Bytes(),
+		InvestingOrderHash:     trade.InvestingOrderHash.Bytes(),
+		BorrowingRelayer:       trade.BorrowingRelayer.Bytes(),
+		InvestingRelayer:       trade.InvestingRelayer.Bytes(),
+		Term:                   trade.Term,
+		Interest:               trade.Interest,
+		Amount:                 bigToPB(trade.Amount),
+		CollateralPrice:        bigToPB(trade.CollateralPrice),
+		LiquidationPrice:       bigToPB(trade.LiquidationPrice),
+		CollateralLockedAmount: bigToPB(trade.CollateralLockedAmount),
+		AutoTopUp:              trade.AutoTopUp,
+		LiquidationTime:        trade.LiquidationTime,
+		BorrowingFee:           bigToPB(trade.BorrowingFee),
+		InvestingFee:           bigToPB(trade.InvestingFee),
+		Status:                 trade.Status,
+		TakerOrderSide:         trade.TakerOrderSide,
+		TakerOrderType:         trade.TakerOrderType,
+		MakerOrderType:         trade.MakerOrderType,
+		ExtraData:              trade.ExtraData,
+		CreatedAt:              timeToPB(trade.CreatedAt),
+		UpdatedAt:              timeToPB(trade.UpdatedAt),
+	}
+}
+
+func orderBookToPB(depth *OrderBookSnapshot) *lendingpb.OrderBook {
+	levels := func(levels []OrderBookLevel) []*lendingpb.OrderBookLevel {
+		msgs := make([]*lendingpb.OrderBookLevel, len(levels))
+		for i, level := range levels {
+			msgs[i] = &lendingpb.OrderBookLevel{Interest: level.Interest.Uint64(), Volume: bigToPB(level.Volume)}
+		}
+		return msgs
+	}
+	return &lendingpb.OrderBook{
+		LendingToken: depth.LendingToken.Bytes(),
+		Term:         depth.Term,
+		BlockHash:    depth.BlockHash.Bytes(),
+		BlockNumber:  depth.BlockNumber,
+		Investing:    levels(depth.Investing),
+		Borrowing:    levels(depth.Borrowing),
+	}
+}
@@ -0,0 +1,136 @@
+package tomoxlending
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingpb"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestGRPCClient(t *testing.T, l *Lending) lendingpb.LendingClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	lendingpb.RegisterLendingServer(server, newGRPCServer(l))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return lendingpb.NewLendingClient(conn)
+}
+
+func TestGRPCSendLendingItems(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	client := newTestGRPCClient(t, l)
+
+	item := &lendingpb.LendingItem{Nonce: 1, Quantity: common.BasePrice.Bytes(), Term: 86400, Side: lendingstate.Investing}
+	_, err := client.SendLendingItems(context.Background(), &lendingpb.SendLendingItemsRequest{Items: []*lendingpb.LendingItem{item}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected an invalid argument error without a lending pool, got %v", err)
+	}
+}
+
+func TestGRPCSubscribeLendingTrades(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	client := newTestGRPCClient(t, l)
+
+	user := common.HexToAddress("0x1")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.SubscribeLendingTrades(ctx, &lendingpb.LendingFilter{UserAddress: user.Bytes()})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	// the subscription is set up asynchronously, the trades are posted until one is received
+	other := &lendingstate.LendingTrade{Hash: common.HexToHash("0x2"), Borrower: common.HexToAddress("0x2"), CreatedAt: time.Now()}
+	match := &lendingstate.LendingTrade{Hash: common.HexToHash("0x1"), Borrower: user, Amount: common.BasePrice, CreatedAt: time.Now()}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			l.postLendingTrades([]*lendingstate.LendingTrade{other, match})
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+	trade, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive trade: %v", err)
+	}
+	if common.BytesToHash(trade.Hash) != match.Hash {
+		t.Fatalf("trade hash mismatch: have %x, want %x", trade.Hash, match.Hash)
+	}
+	if common.BytesToAddress(trade.Borrower) != user || string(trade.Amount) != string(common.BasePrice.Bytes()) {
+		t.Fatalf("trade fields mismatch: %v", trade)
+	}
+	if trade.CreatedAt != match.CreatedAt.UnixNano() {
+		t.Fatalf("creation time mismatch: have %d, want %d", trade.CreatedAt, match.CreatedAt.UnixNano())
+	}
+}
+
+func TestGRPCSubscribeLendingTradesSlowClient(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	client := newTestGRPCClient(t, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.SubscribeLendingTrades(ctx, &lendingpb.LendingFilter{})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	trade := &lendingstate.LendingTrade{Hash: common.HexToHash("0x1"), Amount: common.BasePrice, CreatedAt: time.Now()}
+	done := make(chan struct{})
+	go func() {
+		for {
+			l.postLendingTrades([]*lendingstate.LendingTrade{trade})
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("failed to receive trade: %v", err)
+	}
+	close(done)
+
+	// the client stops reading: the posting of the trades must not block on its stream
+	posted := make(chan struct{})
+	go func() {
+		for i := 0; i < 20000; i++ {
+			l.postLendingTrades([]*lendingstate.LendingTrade{trade})
+		}
+		close(posted)
+	}()
+	select {
+	case <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("lending trades posting blocked by a slow subscriber")
+	}
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the stream of the slow subscriber to end, got %v", err)
+	}
+}
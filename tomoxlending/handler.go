@@ -0,0 +1,385 @@
+package tomoxlending
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// blockChain is the subset of the chain used by the lending protocol and APIs.
+type blockChain interface {
+	consensus.ChainContext
+	CurrentBlock() *types.Block
+	GetBlock(hash common.Hash, number uint64) *types.Block
+	GetBlockByHash(hash common.Hash) *types.Block
+	GetBlockByNumber(number uint64) *types.Block
+	Genesis() *types.Block
+	StateAt(root common.Hash) (*state.StateDB, error)
+}
+
+// lendingTxPool is the subset of the lending transaction pool used by the lending protocol.
+type lendingTxPool interface {
+	AddRemotes(txs []*types.LendingTransaction) []error
+	AddLocalsAtomic(txs []*types.LendingTransaction) error
+	Pending() (map[common.Address]types.LendingTransactions, error)
+	Content() (map[common.Address]types.LendingTransactions, map[common.Address]types.LendingTransactions)
+	ContentFrom(addr common.Address) (types.LendingTransactions, types.LendingTransactions)
+	State() *lendingstate.LendingManagedState
+	SubscribeTxPreEvent(ch chan<- core.LendingTxPreEvent) event.Subscription
+}
+
+// SetChain injects the blockchain used to serve orderbook snapshots and state queries.
+func (l *Lending) SetChain(chain blockChain) {
+	l.chain = chain
+}
+
+// SetLendingPool injects the pool that received lending transactions are delivered to
+// and whose new transactions are gossiped to the peers.
+func (l *Lending) SetLendingPool(pool lendingTxPool) {
+	l.lendingPool = pool
+}
+
+// SubscribeOrderBook registers a subscription for orderbook snapshots received from peers.
+func (l *Lending) SubscribeOrderBook(ch chan<- *OrderBookSnapshot) event.Subscription {
+	return l.scope.Track(l.orderBookFeed.Subscribe(ch))
+}
+
+// RequestOrderBook asks all connected peers for a snapshot of the given lending book.
+// The answers are delivered to SubscribeOrderBook subscribers once checked against the local
+// lending state of the block they were taken at, the others are dropped.
+func (l *Lending) RequestOrderBook(lendingToken common.Address, term uint64) int {
+	peers := l.peers.Peers()
+	for _, p := range peers {
+		if err := p.RequestOrderBook(lendingToken, term); err != nil {
+			p.Log().Debug("Failed to request lending orderbook", "err", err)
+		}
+	}
+	return len(peers)
+}
+
+// handle is the callback invoked to manage the life cycle of a tomoxlending peer.
+// When this function terminates, the peer is disconnected.
+func (l *Lending) handle(p *peer) error {
+	var genesis common.Hash
+	if l.chain != nil {
+		genesis = l.chain.Genesis().Hash()
+	}
+	if err := p.Handshake(genesis); err != nil {
+		p.Log().Debug("TomoX lending handshake failed", "err", err)
+		return err
+	}
+	l.peers.Register(p)
+	defer l.peers.Unregister(p)
+
+	p.Log().Debug("TomoX lending peer connected", "name", p.Name())
+	go l.syncLendingTransactions(p)
+
+	for {
+		if err := l.handleMsg(p); err != nil {
+			p.Log().Debug("TomoX lending message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg is invoked whenever an inbound message is received from a remote
+// peer. The remote connection is torn down upon returning any error.
+func (l *Lending) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return fmt.Errorf("%v: %v > %v", errMsgTooLarge, msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case StatusMsg:
+		return fmt.Errorf("%v: uncontrolled status message", errNoStatusMsg)
+
+	case LendingTxMsg:
+		// Lending transactions (new orders and cancellations) arrived, deliver them to the pool
+		var txs []*types.LendingTransaction
+		if err := msg.Decode(&txs); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		for i, tx := range txs {
+			if tx == nil {
+				return fmt.Errorf("%v: lending transaction %d is nil", errDecode, i)
+			}
+			p.MarkLendingTransaction(tx.Hash())
+		}
+		if l.lendingPool != nil {
+			l.lendingPool.AddRemotes(txs)
+		}
+
+	case GetOrderBookMsg:
+		var req getOrderBookData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		snapshot, err := l.orderBookSnapshot(req.LendingToken, req.Term)
+		if err != nil {
+			// The book might not exist yet, don't punish the peer for asking
+			p.Log().Debug("Failed to serve lending orderbook", "lendingToken", req.LendingToken.Hex(), "term", req.Term, "err", err)
+			return nil
+		}
+		return p.SendOrderBook(snapshot)
+
+	case OrderBookMsg:
+		var snapshot OrderBookSnapshot
+		if err := msg.Decode(&snapshot); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		if !p.answerOrderBook(snapshot.LendingToken, snapshot.Term) {
+			p.Log().Debug("Dropped unrequested lending orderbook", "lendingToken", snapshot.LendingToken.Hex(), "term", snapshot.Term)
+			return nil
+		}
+		if err := l.verifyOrderBookSnapshot(&snapshot); err != nil {
+			if err == errInvalidOrderBook {
+				return fmt.Errorf("%v: block %d [%x]", err, snapshot.BlockNumber, snapshot.BlockHash[:4])
+			}
+			p.Log().Debug("Dropped unverifiable lending orderbook", "lendingToken", snapshot.LendingToken.Hex(), "term", snapshot.Term, "block", snapshot.BlockNumber, "err", err)
+			return nil
+		}
+		l.orderBookFeed.Send(&snapshot)
+
+	case GetTrieNodesMsg:
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		return p.SendTrieNodes(l.trieNodes(hashes))
+
+	case TrieNodesMsg:
+		var nodes [][]byte
+		if err := msg.Decode(&nodes); err != nil {
+			return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+		}
+		l.deliverTrieNodes(p, nodes)
+
+	default:
+		return fmt.Errorf("%v: %v", errInvalidMsgCode, msg.Code)
+	}
+	return nil
+}
+
+// syncLendingTransactions sends all pending lending transactions to a newly connected peer.
+func (l *Lending) syncLendingTransactions(p *peer) {
+	if l.lendingPool == nil {
+		return
+	}
+	pending, err := l.lendingPool.Pending()
+	if err != nil {
+		log.Debug("Failed to retrieve pending lending transactions", "err", err)
+		return
+	}
+	var (
+		pack types.LendingTransactions
+		size common.StorageSize
+	)
+	for _, batch := range pending {
+		for _, tx := range batch {
+			pack = append(pack, tx)
+			size += tx.Size()
+			if size >= txSyncPackSize {
+				if err := p.SendLendingTransactions(pack); err != nil {
+					return
+				}
+				pack, size = nil, 0
+			}
+		}
+	}
+	if len(pack) > 0 {
+		p.SendLendingTransactions(pack)
+	}
+}
+
+// BroadcastLendingTx will propagate a lending transaction to all peers which are not known to
+// already have the given transaction.
+func (l *Lending) BroadcastLendingTx(hash common.Hash, tx *types.LendingTransaction) {
+	peers := l.peers.PeersWithoutLendingTx(hash)
+	for _, p := range peers {
+		p.AsyncSendLendingTransactions(types.LendingTransactions{tx})
+	}
+	log.Trace("Broadcast lending transaction", "hash", hash, "recipients", len(peers))
+}
+
+func (l *Lending) lendingTxBroadcastLoop() {
+	for {
+		select {
+		case event := <-l.lendingTxCh:
+			l.BroadcastLendingTx(event.Tx.Hash(), event.Tx)
+
+		// Err() channel will be closed when unsubscribing.
+		case <-l.lendingTxSub.Err():
+			return
+		}
+	}
+}
+
+// orderBookSnapshot builds the aggregated snapshot of a lending book from the
+// lending state of the current block.
+func (l *Lending) orderBookSnapshot(lendingToken common.Address, term uint64) (*OrderBookSnapshot, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	return orderBookSnapshotAt(block, lendingState, lendingToken, term)
+}
+
+// verifyOrderBookSnapshot checks a lending book snapshot received from a peer against the local
+// lending state of the block it was taken at. It returns errInvalidOrderBook if the levels differ,
+// another error if the snapshot can't be checked.
+func (l *Lending) verifyOrderBookSnapshot(snapshot *OrderBookSnapshot) error {
+	if l.chain == nil {
+		return errLendingStateUnavailable
+	}
+	block := l.chain.GetBlockByHash(snapshot.BlockHash)
+	if block == nil || block.NumberU64() != snapshot.BlockNumber {
+		return fmt.Errorf("block #%d [%x] not found", snapshot.BlockNumber, snapshot.BlockHash[:4])
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return err
+	}
+	lendingState, err := l.GetLendingState(block, author)
+	if err != nil {
+		return err
+	}
+	local, err := orderBookSnapshotAt(block, lendingState, snapshot.LendingToken, snapshot.Term)
+	if err != nil {
+		return err
+	}
+	if !sameLevels(local.Investing, snapshot.Investing) || !sameLevels(local.Borrowing, snapshot.Borrowing) {
+		return errInvalidOrderBook
+	}
+	return nil
+}
+
+// orderBookSnapshotAt builds the aggregated snapshot of a lending book from the lending state of
+// a block.
+func orderBookSnapshotAt(block *types.Block, lendingState *lendingstate.LendingStateDB, lendingToken common.Address, term uint64) (*OrderBookSnapshot, error) {
+	lendingBook := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	investing, err := lendingState.GetInvestings(lendingBook)
+	if err != nil {
+		return nil, err
+	}
+	borrowing, err := lendingState.GetBorrowings(lendingBook)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderBookSnapshot{
+		LendingToken: lendingToken,
+		Term:         term,
+		BlockHash:    block.Hash(),
+		BlockNumber:  block.NumberU64(),
+		Investing:    orderBookLevels(investing, false),
+		Borrowing:    orderBookLevels(borrowing, true),
+	}, nil
+}
+
+// orderBookWithProof returns the interest levels of a lending book in the lending state committed
+// by a block, along with their proof against the lending state root of the block.
+func (l *Lending) orderBookWithProof(lendingToken common.Address, term uint64, blockNrOrHash rpc.BlockNumberOrHash) (*OrderBookProof, error) {
+	block, err := l.blockByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := l.GetLendingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := lendingState.ProveLendingBook(lendingstate.GetLendingOrderBookHash(lendingToken, term))
+	if err != nil {
+		return nil, err
+	}
+	return &OrderBookProof{
+		LendingToken:     lendingToken,
+		Term:             term,
+		BlockHash:        block.Hash(),
+		BlockNumber:      block.NumberU64(),
+		LendingBookProof: proof,
+	}, nil
+}
+
+// tradeProof returns a lending trade of a lending book in the lending state committed by a block,
+// along with its proof against the lending state root of the block.
+func (l *Lending) tradeProof(lendingToken common.Address, term uint64, tradeId uint64, blockNrOrHash rpc.BlockNumberOrHash) (*TradeProof, error) {
+	block, err := l.blockByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := l.GetLendingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := lendingState.ProveLendingTrade(lendingstate.GetLendingOrderBookHash(lendingToken, term), common.Uint64ToHash(tradeId))
+	if err != nil {
+		return nil, err
+	}
+	return &TradeProof{
+		LendingToken:      lendingToken,
+		Term:              term,
+		TradeId:           tradeId,
+		BlockHash:         block.Hash(),
+		BlockNumber:       block.NumberU64(),
+		LendingTradeProof: proof,
+	}, nil
+}
+
+// orderBookLevels turns an interest => volume map into levels sorted by interest.
+func orderBookLevels(volumes map[*big.Int]*big.Int, descending bool) []OrderBookLevel {
+	levels := make([]OrderBookLevel, 0, len(volumes))
+	for interest, volume := range volumes {
+		levels = append(levels, OrderBookLevel{Interest: interest, Volume: volume})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if descending {
+			return levels[i].Interest.Cmp(levels[j].Interest) > 0
+		}
+		return levels[i].Interest.Cmp(levels[j].Interest) < 0
+	})
+	return levels
+}
+
+// sameLevels returns whether two lists of lending book levels are the same.
+func sameLevels(a, b []OrderBookLevel) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Interest == nil || b[i].Interest == nil || a[i].Volume == nil || b[i].Volume == nil {
+			return false
+		}
+		if a[i].Interest.Cmp(b[i].Interest) != 0 || a[i].Volume.Cmp(b[i].Volume) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// runPeer is the p2p.Protocol run function of the tomoxlending sub-protocol.
+func (l *Lending) runPeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	return l.handle(newPeer(p, rw))
+}
@@ -0,0 +1,139 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestOrderBookSnapshotVerification(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	var (
+		usdt        = common.HexToAddress("0x10")
+		lendingBook = lendingstate.GetLendingOrderBookHash(usdt, 86400)
+		chain       = &auditTestChain{}
+	)
+	lendingState, err := lendingstate.New(lendingstate.EmptyRoot, l.StateCache)
+	if err != nil {
+		t.Fatalf("failed to create lending state: %v", err)
+	}
+	lendingState.InsertLendingItem(lendingBook, common.BigToHash(big.NewInt(1)), lendingstate.LendingItem{LendingId: 1, Quantity: big.NewInt(100), Interest: big.NewInt(10), Side: lendingstate.Investing, Signature: &lendingstate.Signature{}})
+	lendingRoot, err := lendingState.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	chain.blocks = append(chain.blocks, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0)}))
+	l.lendingRoots.Add(chain.blocks[0].Hash(), lendingRoot)
+	l.chain = chain
+
+	valid, err := l.orderBookSnapshot(usdt, 86400)
+	if err != nil {
+		t.Fatalf("failed to build snapshot: %v", err)
+	}
+	if len(valid.Investing) != 1 {
+		t.Fatalf("investing levels mismatch: have %d, want 1", len(valid.Investing))
+	}
+	invalid := *valid
+	invalid.Investing = []OrderBookLevel{{Interest: big.NewInt(10), Volume: big.NewInt(1000)}}
+
+	localRW, remoteRW := p2p.MsgPipe()
+	defer localRW.Close()
+	local := newPeer(p2p.NewPeer(discover.NodeID{1}, "remote", nil), localRW)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			if err := l.handleMsg(local); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	snapshots := make(chan *OrderBookSnapshot, 4)
+	sub := l.SubscribeOrderBook(snapshots)
+	defer sub.Unsubscribe()
+
+	request := func() {
+		go local.RequestOrderBook(usdt, 86400)
+		msg, err := remoteRW.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read request: %v", err)
+		}
+		msg.Discard()
+	}
+	// an unrequested snapshot is dropped, a requested one matching the local state is delivered
+	if err := p2p.Send(remoteRW, OrderBookMsg, valid); err != nil {
+		t.Fatalf("failed to send snapshot: %v", err)
+	}
+	request()
+	if err := p2p.Send(remoteRW, OrderBookMsg, valid); err != nil {
+		t.Fatalf("failed to send snapshot: %v", err)
+	}
+	select {
+	case snapshot := <-snapshots:
+		if snapshot.BlockHash != valid.BlockHash || !sameLevels(snapshot.Investing, valid.Investing) {
+			t.Errorf("snapshot mismatch: have %+v, want %+v", snapshot, valid)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("requested snapshot not delivered")
+	}
+	if len(snapshots) != 0 {
+		t.Fatalf("unrequested snapshot delivered")
+	}
+	// a requested snapshot differing from the local state disconnects the peer
+	request()
+	if err := p2p.Send(remoteRW, OrderBookMsg, &invalid); err != nil {
+		t.Fatalf("failed to send snapshot: %v", err)
+	}
+	select {
+	case err := <-errc:
+		if !strings.Contains(err.Error(), errInvalidOrderBook.Error()) {
+			t.Errorf("disconnection error mismatch: have %v, want %v", err, errInvalidOrderBook)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("peer not disconnected")
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("invalid snapshot delivered")
+	}
+}
+
+func TestAsyncSendLendingTransactions(t *testing.T) {
+	localRW, remoteRW := p2p.MsgPipe()
+	defer localRW.Close()
+	p := newPeer(p2p.NewPeer(discover.NodeID{1}, "remote", nil), localRW)
+
+	// the remote peer doesn't read: the broadcasts are queued, then dropped, without blocking
+	ps := newPeerSet()
+	ps.Register(p)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < maxQueuedLendingTxs+10; i++ {
+			tx := types.NewLendingTransaction(uint64(i), big.NewInt(1), 10, 86400, common.Address{}, common.Address{}, common.Address{}, common.Address{}, false, lendingstate.LendingStatusNew, lendingstate.Investing, lendingstate.Limit, common.Hash{}, 0, 0, "")
+			p.AsyncSendLendingTransactions(types.LendingTransactions{tx})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("lending transaction broadcast blocked by a slow peer")
+	}
+	// the queued transactions are sent once the remote peer reads
+	msg, err := remoteRW.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read lending transactions: %v", err)
+	}
+	if msg.Code != LendingTxMsg {
+		t.Fatalf("message code mismatch: have %d, want %d", msg.Code, LendingTxMsg)
+	}
+	msg.Discard()
+	ps.Unregister(p)
+}
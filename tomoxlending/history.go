@@ -0,0 +1,142 @@
+package tomoxlending
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The reorg history of lending items and trades is kept in the lru caches and mirrored
+// into the tomox leveldb, so that SDK records can still be rolled back after a restart. It is
+// kept for a day, and for the blocks of the SDK journal (see sdk_journal.go).
+var (
+	lendingHistoryPrefix      = []byte("lendingHistory-")      // lendingHistoryPrefix + txhash -> lendingHistory
+	lendingHistoryIndexPrefix = []byte("lendingHistoryIndex-") // lendingHistoryIndexPrefix + time (uint64 big endian) + txhash -> nil
+)
+
+const (
+	lendingHistoryRetention     = 24 * time.Hour // Time after which persisted history can't be used for a rollback anymore
+	lendingHistoryPruneInterval = time.Hour      // Minimum time between two pruning rounds
+)
+
+// lendingHistory is the persisted form of the reorg history of one transaction.
+type lendingHistory struct {
+	Items  map[common.Hash]lendingstate.LendingItemHistoryItem  `json:"items"`
+	Trades map[common.Hash]lendingstate.LendingTradeHistoryItem `json:"trades"`
+}
+
+func lendingHistoryKey(txhash common.Hash) []byte {
+	return append(append([]byte{}, lendingHistoryPrefix...), txhash.Bytes()...)
+}
+
+func lendingHistoryIndexKey(txTime time.Time, txhash common.Hash) []byte {
+	key := make([]byte, len(lendingHistoryIndexPrefix)+8+common.HashLength)
+	copy(key, lendingHistoryIndexPrefix)
+	binary.BigEndian.PutUint64(key[len(lendingHistoryIndexPrefix):], uint64(txTime.Unix()))
+	copy(key[len(lendingHistoryIndexPrefix)+8:], txhash.Bytes())
+	return key
+}
+
+// saveLendingHistory persists the cached reorg history of a transaction and prunes
+// the entries older than the retention period.
+func (l *Lending) saveLendingHistory(txhash common.Hash, txTime time.Time) {
+	history := lendingHistory{
+		Items:  map[common.Hash]lendingstate.LendingItemHistoryItem{},
+		Trades: map[common.Hash]lendingstate.LendingTradeHistoryItem{},
+	}
+	if c, ok := l.lendingItemHistory.Get(txhash); ok && c != nil {
+		history.Items = c.(map[common.Hash]lendingstate.LendingItemHistoryItem)
+	}
+	if c, ok := l.lendingTradeHistory.Get(txhash); ok && c != nil {
+		history.Trades = c.(map[common.Hash]lendingstate.LendingTradeHistoryItem)
+	}
+	if len(history.Items) == 0 && len(history.Trades) == 0 {
+		return
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		log.Error("Failed to encode lending history", "txhash", txhash.Hex(), "err", err)
+		return
+	}
+	db := l.GetLevelDB()
+	batch := db.NewBatch()
+	batch.Put(lendingHistoryKey(txhash), data)
+	batch.Put(lendingHistoryIndexKey(txTime, txhash), nil)
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to persist lending history", "txhash", txhash.Hex(), "err", err)
+		return
+	}
+	if txTime.Sub(l.lastHistoryPrune) >= lendingHistoryPruneInterval {
+		// the history of the journaled blocks is pruned along with their journal
+		before := txTime.Add(-lendingHistoryRetention)
+		if oldest, ok := l.oldestSDKJournalTime(); ok && oldest.Before(before) {
+			before = oldest
+		}
+		l.pruneLendingHistory(before)
+		l.lastHistoryPrune = txTime
+	}
+}
+
+// loadLendingHistory reads back the persisted reorg history of a transaction.
+func (l *Lending) loadLendingHistory(txhash common.Hash) *lendingHistory {
+	data, err := l.GetLevelDB().Get(lendingHistoryKey(txhash))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	var history lendingHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		log.Error("Failed to decode lending history", "txhash", txhash.Hex(), "err", err)
+		return nil
+	}
+	return &history
+}
+
+// pruneLendingHistory removes the persisted history recorded before the given time.
+func (l *Lending) pruneLendingHistory(before time.Time) {
+	db := l.GetLevelDB()
+	it := db.NewIterator(lendingHistoryIndexPrefix, nil)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(lendingHistoryIndexPrefix)+8+common.HashLength {
+			continue
+		}
+		if int64(binary.BigEndian.Uint64(key[len(lendingHistoryIndexPrefix):])) >= before.Unix() {
+			break
+		}
+		txhash := common.BytesToHash(key[len(lendingHistoryIndexPrefix)+8:])
+		batch.Delete(lendingHistoryKey(txhash))
+		batch.Delete(common.CopyBytes(key))
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to prune lending history", "err", err)
+	}
+}
+
+// getLendingItemHistory returns the reorg history of the lending items updated by a transaction.
+func (l *Lending) getLendingItemHistory(txhash common.Hash) (map[common.Hash]lendingstate.LendingItemHistoryItem, bool) {
+	if c, ok := l.lendingItemHistory.Get(txhash); ok && c != nil {
+		return c.(map[common.Hash]lendingstate.LendingItemHistoryItem), true
+	}
+	if history := l.loadLendingHistory(txhash); history != nil && len(history.Items) > 0 {
+		return history.Items, true
+	}
+	return nil, false
+}
+
+// getLendingTradeHistory returns the reorg history of the lending trades updated by a transaction.
+func (l *Lending) getLendingTradeHistory(txhash common.Hash) (map[common.Hash]lendingstate.LendingTradeHistoryItem, bool) {
+	if c, ok := l.lendingTradeHistory.Get(txhash); ok && c != nil {
+		return c.(map[common.Hash]lendingstate.LendingTradeHistoryItem), true
+	}
+	if history := l.loadLendingHistory(txhash); history != nil && len(history.Trades) > 0 {
+		return history.Trades, true
+	}
+	return nil, false
+}
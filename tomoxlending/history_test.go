@@ -0,0 +1,40 @@
+package tomoxlending
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestLendingHistoryPersistence(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+
+	now := time.Unix(1600000000, 0).UTC()
+	oldTx, newTx := common.HexToHash("0x1"), common.HexToHash("0x2")
+	item := lendingstate.LendingItemHistoryItem{TxHash: common.HexToHash("0x3"), FilledAmount: big.NewInt(1), Status: lendingstate.LendingStatusOpen, UpdatedAt: now}
+	trade := lendingstate.LendingTradeHistoryItem{TxHash: common.HexToHash("0x4"), CollateralLockedAmount: big.NewInt(2), LiquidationPrice: big.NewInt(3), Amount: big.NewInt(4), Status: lendingstate.TradeStatusOpen, UpdatedAt: now}
+
+	l.UpdateLendingItemCache(common.Address{}, common.Address{}, common.HexToHash("0x5"), oldTx, item)
+	l.saveLendingHistory(oldTx, now.Add(-2*lendingHistoryRetention))
+	l.UpdateLendingTradeCache(common.HexToHash("0x6"), newTx, trade)
+	l.saveLendingHistory(newTx, now)
+
+	// simulate a restart
+	l.lendingItemHistory.Purge()
+	l.lendingTradeHistory.Purge()
+
+	if _, ok := l.getLendingItemHistory(oldTx); ok {
+		t.Fatalf("expired lending item history not pruned")
+	}
+	trades, ok := l.getLendingTradeHistory(newTx)
+	if !ok {
+		t.Fatalf("lending trade history not persisted")
+	}
+	got := trades[common.HexToHash("0x6")]
+	if got.TxHash != trade.TxHash || got.Amount.Cmp(trade.Amount) != 0 || got.Status != trade.Status || !got.UpdatedAt.Equal(trade.UpdatedAt) {
+		t.Fatalf("lending trade history mismatch: have %+v, want %+v", got, trade)
+	}
+}
@@ -0,0 +1,78 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// An iceberg item rests in the lending book with at most its displayed quantity (ExtraData), the rest
+// of its quantity is kept in the iceberg book of the lending book (see lendingstate.GetLendingIcebergBookHash)
+// under the same lending id. Once the displayed slice is filled, the next slice is taken from the hidden
+// quantity and the item keeps its lending id, so it can still be cancelled by the user.
+
+// insertLendingItem adds the unmatched part of a limit or iceberg item to the lending book.
+func insertLendingItem(lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) {
+	orderIdHash := common.BigToHash(new(big.Int).SetUint64(order.LendingId))
+	item := *order
+	if order.Type == lendingstate.Iceberg {
+		if display := order.DisplayQuantity(); display != nil && display.Sign() > 0 && display.Cmp(order.Quantity) < 0 {
+			hidden := *order
+			hidden.Quantity = new(big.Int).Sub(order.Quantity, display)
+			lendingStateDB.InsertLendingItem(lendingstate.GetLendingIcebergBookHash(lendingOrderBook), orderIdHash, hidden)
+			item.Quantity = display
+			log.Debug("Iceberg order hidden quantity added to iceberg book", "LendingId", order.LendingId, "display", display, "hidden", hidden.Quantity)
+		}
+	}
+	lendingStateDB.InsertLendingItem(lendingOrderBook, orderIdHash, item)
+}
+
+// hiddenQuantity returns the quantity of an iceberg item which is not shown in the lending book.
+func hiddenQuantity(lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) *big.Int {
+	if order.Type != lendingstate.Iceberg {
+		return lendingstate.Zero
+	}
+	icebergBook := lendingstate.GetLendingIcebergBookHash(lendingOrderBook)
+	if !lendingStateDB.Exist(icebergBook) {
+		return lendingstate.Zero
+	}
+	hidden := lendingStateDB.GetLendingOrder(icebergBook, common.BigToHash(new(big.Int).SetUint64(order.LendingId)))
+	if hidden.Hash != order.Hash || hidden.Quantity == nil {
+		return lendingstate.Zero
+	}
+	return hidden.Quantity
+}
+
+// refreshIcebergItem shows the next slice of a filled iceberg item in the lending book.
+func refreshIcebergItem(lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) error {
+	hidden := hiddenQuantity(lendingStateDB, lendingOrderBook, order)
+	if hidden.Sign() == 0 {
+		return nil
+	}
+	slice := order.DisplayQuantity()
+	if slice == nil || slice.Sign() <= 0 || slice.Cmp(hidden) > 0 {
+		slice = hidden
+	}
+	orderIdHash := common.BigToHash(new(big.Int).SetUint64(order.LendingId))
+	if err := lendingStateDB.SubAmountLendingItem(lendingstate.GetLendingIcebergBookHash(lendingOrderBook), orderIdHash, order.Interest, slice, order.Side); err != nil {
+		return err
+	}
+	item := *order
+	item.Quantity = lendingstate.CloneBigInt(slice)
+	lendingStateDB.InsertLendingItem(lendingOrderBook, orderIdHash, item)
+	log.Debug("Iceberg order refreshed", "LendingId", order.LendingId, "slice", slice, "hidden", new(big.Int).Sub(hidden, slice))
+	return nil
+}
+
+// cancelLendingItem removes an item from the lending book, with the hidden quantity of an iceberg item.
+func cancelLendingItem(lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) error {
+	if err := lendingStateDB.CancelLendingOrder(lendingOrderBook, order); err != nil {
+		return err
+	}
+	if hiddenQuantity(lendingStateDB, lendingOrderBook, order).Sign() == 0 {
+		return nil
+	}
+	return lendingStateDB.CancelLendingOrder(lendingstate.GetLendingIcebergBookHash(lendingOrderBook), order)
+}
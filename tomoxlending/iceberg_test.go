@@ -0,0 +1,67 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestIcebergItem(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+
+	var (
+		lendingBook = lendingstate.GetLendingOrderBookHash(common.HexToAddress(common.TomoNativeAddress), 86400)
+		interest    = big.NewInt(5)
+		orderId     = common.BigToHash(big.NewInt(1))
+	)
+	order := &lendingstate.LendingItem{
+		LendingId:   1,
+		Quantity:    big.NewInt(250),
+		Interest:    interest,
+		Side:        lendingstate.Investing,
+		Type:        lendingstate.Iceberg,
+		UserAddress: common.HexToAddress("0x1"),
+		ExtraData:   "100",
+		Hash:        common.HexToHash("0x1"),
+	}
+	insertLendingItem(lendingStateDB, lendingBook, order)
+	if order.Quantity.Cmp(big.NewInt(250)) != 0 {
+		t.Fatalf("order quantity changed: %v", order.Quantity)
+	}
+	shown := []int64{100, 100, 50}
+	for i, want := range shown {
+		id, amount, err := lendingStateDB.GetBestLendingIdAndAmount(lendingBook, interest, lendingstate.Investing)
+		if err != nil || id != orderId || amount.Cmp(big.NewInt(want)) != 0 {
+			t.Fatalf("slice %d: have %v %v %v, want %d", i, id.Hex(), amount, err, want)
+		}
+		if hidden, want := hiddenQuantity(lendingStateDB, lendingBook, order), int64(150-100*i); want > 0 && hidden.Cmp(big.NewInt(want)) != 0 {
+			t.Fatalf("slice %d: wrong hidden quantity: have %v, want %d", i, hidden, want)
+		}
+		if err := lendingStateDB.SubAmountLendingItem(lendingBook, orderId, interest, amount, lendingstate.Investing); err != nil {
+			t.Fatalf("slice %d: failed to fill: %v", i, err)
+		}
+		if err := refreshIcebergItem(lendingStateDB, lendingBook, order); err != nil {
+			t.Fatalf("slice %d: failed to refresh: %v", i, err)
+		}
+	}
+	if best, _ := lendingStateDB.GetBestInvestingRate(lendingBook); best.Sign() != 0 {
+		t.Fatalf("filled iceberg order still in the lending book at %v", best)
+	}
+
+	// cancelling an iceberg item removes its hidden quantity
+	order.LendingId, order.Hash = 2, common.HexToHash("0x2")
+	insertLendingItem(lendingStateDB, lendingBook, order)
+	if err := cancelLendingItem(lendingStateDB, lendingBook, order); err != nil {
+		t.Fatalf("failed to cancel: %v", err)
+	}
+	if hidden := hiddenQuantity(lendingStateDB, lendingBook, order); hidden.Sign() != 0 {
+		t.Fatalf("hidden quantity not cancelled: %v", hidden)
+	}
+	if best, _ := lendingStateDB.GetBestInvestingRate(lendingBook); best.Sign() != 0 {
+		t.Fatalf("cancelled iceberg order still in the lending book at %v", best)
+	}
+}
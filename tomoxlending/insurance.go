@@ -0,0 +1,191 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomoxDAO"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Since TIPTomoXLendingV2 each lending token has an insurance fund (see lendingstate.GetInsuranceFund)
+// whose tokens are held by LendingInsuranceAddress:
+//   - the owner of the borrowing relayer of a new trade pays LendingInsuranceRate of the borrowing
+//     fee to the fund
+//   - a liquidation auction ending with a debt left, because its collateral is sold out or is worth
+//     less than the debt at the floor price once expired, draws this shortfall from the fund in favor
+//     of the investor. The part the fund can't pay is recorded as uncovered.
+//
+// The shortfall and the draw of an auction are recorded in its ExtraData, SDK nodes record a
+// LendingInsuranceRecord for each contribution and draw.
+
+// maxShortfallRange is the longest period of a shortfall events query.
+const maxShortfallRange = 31 * 24 * time.Hour
+
+var (
+	errInsuranceNotSDKNode = errors.New("insurance fund records require an SDK node")
+	errShortfallRange      = errors.New("shortfall events range exceeds 31 days")
+)
+
+// insuranceContribution returns the share of a borrowing fee paid to the insurance fund.
+func insuranceContribution(borrowingFee *big.Int) *big.Int {
+	if borrowingFee == nil || borrowingFee.Sign() <= 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Div(new(big.Int).Mul(borrowingFee, common.LendingInsuranceRate), common.BaseLendingInsurance)
+}
+
+// contributeInsuranceFund pays the insurance share of the borrowing fee of a new lending trade from
+// the owner of its borrowing relayer, who has just been paid the fee, to the insurance fund.
+func contributeInsuranceFund(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, trade *lendingstate.LendingTrade) error {
+	amount := insuranceContribution(trade.BorrowingFee)
+	if amount.Sign() == 0 {
+		return nil
+	}
+	owner := lendingstate.GetRelayerOwner(trade.BorrowingRelayer, statedb)
+	if err := lendingstate.SubTokenBalance(owner, amount, trade.LendingToken, statedb); err != nil {
+		return err
+	}
+	if err := lendingstate.AddTokenBalance(common.HexToAddress(common.LendingInsuranceAddress), amount, trade.LendingToken, statedb); err != nil {
+		return err
+	}
+	lendingStateDB.AddInsuranceContribution(trade.LendingToken, amount)
+	return nil
+}
+
+// coverShortfall draws the shortfall of a liquidation auction from the insurance fund to the
+// investor, as much as the fund allows, and returns the amount drawn.
+func coverShortfall(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, auction *lendingstate.LendingTrade, shortfall *big.Int) (*big.Int, error) {
+	drawn := lendingStateDB.DrawInsuranceFund(auction.LendingToken, shortfall)
+	if drawn.Sign() == 0 {
+		return drawn, nil
+	}
+	if err := lendingstate.SubTokenBalance(common.HexToAddress(common.LendingInsuranceAddress), drawn, auction.LendingToken, statedb); err != nil {
+		return nil, err
+	}
+	if err := lendingstate.AddTokenBalance(auction.Investor, drawn, auction.LendingToken, statedb); err != nil {
+		return nil, err
+	}
+	return drawn, nil
+}
+
+// newInsuranceRecord returns the record of a contribution or a draw of a lending trade.
+func newInsuranceRecord(kind string, trade *lendingstate.LendingTrade, amount, shortfall *big.Int, txHash common.Hash, txTime time.Time) *lendingstate.LendingInsuranceRecord {
+	record := &lendingstate.LendingInsuranceRecord{
+		TxHash:       txHash,
+		Kind:         kind,
+		LendingToken: trade.LendingToken,
+		Term:         trade.Term,
+		TradeId:      trade.TradeId,
+		TradeHash:    trade.Hash,
+		Amount:       amount,
+		Shortfall:    shortfall,
+		CreatedAt:    txTime,
+		UpdatedAt:    txTime,
+	}
+	if kind == lendingstate.InsuranceContribution {
+		record.Relayer = trade.BorrowingRelayer
+	} else {
+		record.Investor = trade.Investor
+	}
+	record.Hash = record.ComputeHash()
+	return record
+}
+
+// newHaircutRecord returns the record of a haircut socializing the shortfall of an auction.
+func newHaircutRecord(auction *lendingstate.LendingTrade, haircut lendingstate.TradeHaircut, shortfall *big.Int, txHash common.Hash, txTime time.Time) *lendingstate.LendingInsuranceRecord {
+	record := &lendingstate.LendingInsuranceRecord{
+		TxHash:       txHash,
+		Kind:         lendingstate.InsuranceHaircut,
+		LendingToken: auction.LendingToken,
+		Term:         auction.Term,
+		TradeId:      haircut.TradeId,
+		TradeHash:    haircut.Hash,
+		Investor:     haircut.Investor,
+		Amount:       haircut.Amount,
+		Shortfall:    shortfall,
+		AuctionHash:  auction.Hash,
+		CreatedAt:    txTime,
+		UpdatedAt:    txTime,
+	}
+	record.Hash = record.ComputeHash()
+	return record
+}
+
+// insuranceRecords returns the insurance fund records of a transaction: the contributions of the
+// new trades, then the draws of the auctions which ended with a shortfall, each followed by the
+// haircuts socializing the part the fund couldn't cover.
+func insuranceRecords(newTrades []*lendingstate.LendingTrade, auctions []*lendingstate.LendingTrade, txHash common.Hash, txTime time.Time) []*lendingstate.LendingInsuranceRecord {
+	records := []*lendingstate.LendingInsuranceRecord{}
+	for _, trade := range newTrades {
+		if trade == nil {
+			continue
+		}
+		if amount := insuranceContribution(trade.BorrowingFee); amount.Sign() > 0 {
+			records = append(records, newInsuranceRecord(lendingstate.InsuranceContribution, trade, amount, new(big.Int), txHash, txTime))
+		}
+	}
+	for _, auction := range auctions {
+		if auction == nil {
+			continue
+		}
+		// AuctionBidData and LiquidationData share the shortfall fields
+		var data struct {
+			Shortfall     *big.Int
+			InsuranceDraw *big.Int
+			Haircuts      []lendingstate.TradeHaircut
+		}
+		if auction.ExtraData == "" || json.Unmarshal([]byte(auction.ExtraData), &data) != nil || data.Shortfall == nil || data.Shortfall.Sign() <= 0 {
+			continue
+		}
+		drawn := data.InsuranceDraw
+		if drawn == nil {
+			drawn = new(big.Int)
+		}
+		records = append(records, newInsuranceRecord(lendingstate.InsuranceDraw, auction, drawn, data.Shortfall, txHash, txTime))
+		for _, haircut := range data.Haircuts {
+			records = append(records, newHaircutRecord(auction, haircut, data.Shortfall, txHash, txTime))
+		}
+	}
+	return records
+}
+
+// putInsuranceRecords adds the insurance fund records to the lending bulk of the SDK database.
+func putInsuranceRecords(db tomoxDAO.TomoXDAO, records []*lendingstate.LendingInsuranceRecord) error {
+	for _, record := range records {
+		if err := db.PutObject(record.Hash, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insuranceFund returns the insurance fund of a lending token at the current block.
+func (l *Lending) insuranceFund(lendingToken common.Address) (*lendingstate.LendingInsuranceFund, error) {
+	_, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	fund := lendingState.GetInsuranceFund(lendingToken)
+	return &fund, nil
+}
+
+// shortfallEvents returns the draws from the insurance fund of the auctions of a lending book
+// settled in [from, to), the oldest first.
+func (l *Lending) shortfallEvents(lendingToken common.Address, term uint64, from, to time.Time) ([]*lendingstate.LendingInsuranceRecord, error) {
+	if !l.tomox.IsSDKNode() {
+		return nil, errInsuranceNotSDKNode
+	}
+	if to.Sub(from) > maxShortfallRange {
+		return nil, errShortfallRange
+	}
+	records, _ := l.GetMongoReadDB().GetLendingListByTime(lendingToken, term, from, to, &lendingstate.LendingInsuranceRecord{Kind: lendingstate.InsuranceDraw}).([]*lendingstate.LendingInsuranceRecord)
+	if records == nil {
+		records = []*lendingstate.LendingInsuranceRecord{}
+	}
+	return records, nil
+}
@@ -0,0 +1,109 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestInsuranceFund(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+
+	var (
+		lendingToken = common.HexToAddress(common.TomoNativeAddress)
+		relayer      = common.HexToAddress("0x21")
+		owner        = common.HexToAddress("0x22")
+		investor     = common.HexToAddress("0x23")
+		insurance    = common.HexToAddress(common.LendingInsuranceAddress)
+	)
+	locRelayer := new(big.Int).Add(lendingstate.GetLocMappingAtKey(relayer.Hash(), lendingstate.RelayerMappingSlot["RELAYER_LIST"]), lendingstate.RelayerStructMappingSlot["_owner"])
+	statedb.SetState(common.HexToAddress(common.RelayerRegistrationSMC), common.BigToHash(locRelayer), owner.Hash())
+	statedb.AddBalance(owner, big.NewInt(1000))
+
+	// the relayer owner pays 10% of the borrowing fee
+	trade := &lendingstate.LendingTrade{TradeId: 1, LendingToken: lendingToken, BorrowingRelayer: relayer, Investor: investor, BorrowingFee: big.NewInt(1000)}
+	if err := contributeInsuranceFund(lendingStateDB, statedb, trade); err != nil {
+		t.Fatalf("failed to contribute: %v", err)
+	}
+	if balance := statedb.GetBalance(owner); balance.Int64() != 900 {
+		t.Errorf("wrong relayer owner balance: have %v, want 900", balance)
+	}
+	if balance := statedb.GetBalance(insurance); balance.Int64() != 100 {
+		t.Errorf("wrong insurance balance: have %v, want 100", balance)
+	}
+
+	// the fund covers what it can of a shortfall
+	drawn, err := coverShortfall(lendingStateDB, statedb, trade, big.NewInt(60))
+	if err != nil || drawn.Int64() != 60 {
+		t.Fatalf("wrong first draw: %v, %v", drawn, err)
+	}
+	drawn, err = coverShortfall(lendingStateDB, statedb, trade, big.NewInt(70))
+	if err != nil || drawn.Int64() != 40 {
+		t.Fatalf("wrong second draw: %v, %v", drawn, err)
+	}
+	fund := lendingStateDB.GetInsuranceFund(lendingToken)
+	if fund.Balance.Sign() != 0 || fund.Contributions.Int64() != 100 || fund.Draws.Int64() != 100 || fund.Uncovered.Int64() != 30 {
+		t.Errorf("wrong fund: %v", lendingstate.ToJSON(fund))
+	}
+	if balance := statedb.GetBalance(investor); balance.Int64() != 100 {
+		t.Errorf("wrong investor balance: have %v, want 100", balance)
+	}
+	if balance := statedb.GetBalance(insurance); balance.Sign() != 0 {
+		t.Errorf("wrong insurance balance: have %v, want 0", balance)
+	}
+}
+
+func TestShortfallEvents(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir(), DBEngine: "badger"}))
+	defer l.GetMongoDB().Close()
+
+	var (
+		usdt   = common.HexToAddress("0x10")
+		txTime = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+		db     = l.GetMongoDB()
+	)
+	trade := &lendingstate.LendingTrade{TradeId: 1, LendingToken: usdt, Term: 86400, BorrowingFee: big.NewInt(50)}
+	trade.Hash = trade.ComputeHash()
+	extraData, _ := json.Marshal(lendingstate.LiquidationData{Reason: lendingstate.LiquidatedByAuction, Shortfall: big.NewInt(30), InsuranceDraw: big.NewInt(20)})
+	auction := &lendingstate.LendingTrade{TradeId: 2, LendingToken: usdt, Term: 86400, Investor: common.HexToAddress("0x1"), Status: lendingstate.TradeStatusLiquidated, ExtraData: string(extraData)}
+	auction.Hash = auction.ComputeHash()
+	covered := &lendingstate.LendingTrade{TradeId: 3, LendingToken: usdt, Term: 86400, Status: lendingstate.TradeStatusLiquidated, ExtraData: `{"Reason":2}`}
+
+	records := insuranceRecords([]*lendingstate.LendingTrade{trade}, []*lendingstate.LendingTrade{auction, covered}, common.HexToHash("0x1"), txTime)
+	if len(records) != 2 || records[0].Kind != lendingstate.InsuranceContribution || records[0].Amount.Int64() != 5 {
+		t.Fatalf("wrong records: %v", lendingstate.ToJSON(records))
+	}
+	db.InitLendingBulk()
+	if err := putInsuranceRecords(db, records); err != nil {
+		t.Fatalf("failed to put records: %v", err)
+	}
+	if err := db.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit records: %v", err)
+	}
+
+	events, err := l.shortfallEvents(usdt, 86400, txTime, txTime.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get shortfall events: %v", err)
+	}
+	if len(events) != 1 || events[0].TradeId != 2 || events[0].Shortfall.Int64() != 30 || events[0].Amount.Int64() != 20 || events[0].Investor != auction.Investor {
+		t.Fatalf("wrong shortfall events: %v", lendingstate.ToJSON(events))
+	}
+	if _, err := l.shortfallEvents(usdt, 86400, txTime, txTime.Add(32*24*time.Hour)); err != errShortfallRange {
+		t.Errorf("wrong error for a long range: %v", err)
+	}
+
+	// the records of a transaction are removed by a reorg
+	db.DeleteItemByTxHash(common.HexToHash("0x1"), &lendingstate.LendingInsuranceRecord{})
+	if events, _ := l.shortfallEvents(usdt, 86400, txTime, txTime.Add(time.Hour)); len(events) != 0 {
+		t.Errorf("shortfall events left after rollback: %v", lendingstate.ToJSON(events))
+	}
+}
@@ -0,0 +1,176 @@
+package tomoxlending
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/rpc"
+)
+
+// Non-SDK nodes started with --tomox.lendingindex also keep the lending logs of each block (see
+// lendingstate.LendingItemLogs) in the tomox leveldb, so that they can be queried like eth_getLogs.
+// The records are keyed by block number and hash, and only the ones of canonical blocks are returned.
+var lendingLogsPrefix = []byte("lendingLogs-") // lendingLogsPrefix + number + hash -> []*types.LogForStorage
+
+const maxLendingLogsRange = 10000 // Maximum number of blocks scanned by a lending logs query
+
+var errLendingLogsRange = errors.New("lending logs query exceeds the maximum block range")
+
+// LendingLogsFilter selects the lending logs returned by GetLogs. Topics are matched as in eth_getLogs.
+type LendingLogsFilter struct {
+	BlockHash *common.Hash     `json:"blockHash"`
+	FromBlock *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`
+	Topics    [][]common.Hash  `json:"topics"`
+}
+
+func lendingLogsKey(number uint64, hash common.Hash) []byte {
+	key := make([]byte, len(lendingLogsPrefix)+8+common.HashLength)
+	copy(key, lendingLogsPrefix)
+	binary.BigEndian.PutUint64(key[len(lendingLogsPrefix):], number)
+	copy(key[len(lendingLogsPrefix)+8:], hash.Bytes())
+	return key
+}
+
+// IndexLendingLogs records the lending logs of a block, after filling in their block fields.
+func (l *Lending) IndexLendingLogs(block *types.Block, logs []*types.Log) error {
+	if !l.HasLendingIndex() || len(logs) == 0 {
+		return nil
+	}
+	txIndex := make(map[common.Hash]uint, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		txIndex[tx.Hash()] = uint(i)
+	}
+	stored := make([]*types.LogForStorage, len(logs))
+	for i, log := range logs {
+		log.BlockNumber = block.NumberU64()
+		log.BlockHash = block.Hash()
+		log.TxIndex = txIndex[log.TxHash]
+		log.Index = uint(i)
+		stored[i] = (*types.LogForStorage)(log)
+	}
+	data, err := rlp.EncodeToBytes(stored)
+	if err != nil {
+		return err
+	}
+	return l.GetLevelDB().Put(lendingLogsKey(block.NumberU64(), block.Hash()), data)
+}
+
+func (l *Lending) getBlockLendingLogs(number uint64, hash common.Hash) ([]*types.Log, error) {
+	data, err := l.GetLevelDB().Get(lendingLogsKey(number, hash))
+	if err != nil || len(data) == 0 {
+		return nil, nil
+	}
+	var stored []*types.LogForStorage
+	if err := rlp.DecodeBytes(data, &stored); err != nil {
+		return nil, err
+	}
+	logs := make([]*types.Log, len(stored))
+	for i, log := range stored {
+		logs[i] = (*types.Log)(log)
+	}
+	return logs, nil
+}
+
+// matchLendingLogTopics returns whether the log matches the topics of a filter: an empty
+// position matches any topic, otherwise one of its topics must match.
+func matchLendingLogTopics(log *types.Log, topics [][]common.Hash) bool {
+	if len(topics) > len(log.Topics) {
+		return false
+	}
+	for i, sub := range topics {
+		if len(sub) == 0 {
+			continue
+		}
+		match := false
+		for _, topic := range sub {
+			if log.Topics[i] == topic {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+func resolveLendingLogsBlock(number *rpc.BlockNumber, head uint64) uint64 {
+	if number == nil || *number == rpc.LatestBlockNumber || *number == rpc.PendingBlockNumber {
+		return head
+	}
+	return uint64(number.Int64())
+}
+
+// getLendingLogs returns the lending logs of the canonical blocks selected by the filter.
+func (l *Lending) getLendingLogs(filter LendingLogsFilter) ([]*types.Log, error) {
+	if !l.HasLendingIndex() {
+		return nil, errLendingHistoryUnavailable
+	}
+	if l.chain == nil {
+		return nil, errLendingStateUnavailable
+	}
+	result := []*types.Log{}
+	if filter.BlockHash != nil {
+		block := l.chain.GetBlockByHash(*filter.BlockHash)
+		if block == nil {
+			return nil, fmt.Errorf("block %x not found", *filter.BlockHash)
+		}
+		logs, err := l.getBlockLendingLogs(block.NumberU64(), block.Hash())
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			if matchLendingLogTopics(log, filter.Topics) {
+				result = append(result, log)
+			}
+		}
+		return result, nil
+	}
+	head := l.chain.CurrentBlock().NumberU64()
+	from, to := resolveLendingLogsBlock(filter.FromBlock, head), resolveLendingLogsBlock(filter.ToBlock, head)
+	if to > head {
+		to = head
+	}
+	if from > to {
+		return result, nil
+	}
+	if to-from >= maxLendingLogsRange {
+		return nil, errLendingLogsRange
+	}
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, from)
+	it := l.GetLevelDB().NewIterator(lendingLogsPrefix, start)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(lendingLogsPrefix)+8+common.HashLength {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(lendingLogsPrefix):])
+		if number > to {
+			break
+		}
+		// skip the logs of the blocks reorganised out of the canonical chain
+		block := l.chain.GetBlockByNumber(number)
+		if block == nil || block.Hash() != common.BytesToHash(key[len(lendingLogsPrefix)+8:]) {
+			continue
+		}
+		var stored []*types.LogForStorage
+		if err := rlp.DecodeBytes(it.Value(), &stored); err != nil {
+			return nil, err
+		}
+		for _, log := range stored {
+			if matchLendingLogTopics((*types.Log)(log), filter.Topics) {
+				result = append(result, (*types.Log)(log))
+			}
+		}
+	}
+	return result, it.Error()
+}
@@ -0,0 +1,100 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// logsTestChain serves a fixed canonical chain to the lending logs queries.
+type logsTestChain struct {
+	blockChain
+	blocks []*types.Block
+}
+
+func (c *logsTestChain) CurrentBlock() *types.Block { return c.blocks[len(c.blocks)-1] }
+
+func (c *logsTestChain) GetBlockByNumber(number uint64) *types.Block {
+	if number >= uint64(len(c.blocks)) {
+		return nil
+	}
+	return c.blocks[number]
+}
+
+func (c *logsTestChain) GetBlockByHash(hash common.Hash) *types.Block {
+	for _, block := range c.blocks {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return nil
+}
+
+func TestLendingLogs(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir(), LendingIndex: true}))
+	var (
+		borrower = common.HexToAddress("0x1")
+		investor = common.HexToAddress("0x2")
+		chain    = &logsTestChain{}
+	)
+	for i := int64(0); i < 3; i++ {
+		chain.blocks = append(chain.blocks, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(i), Time: big.NewInt(i)}))
+	}
+	l.chain = chain
+
+	trade := &lendingstate.LendingTrade{
+		TradeId:                1,
+		Borrower:               borrower,
+		Investor:               investor,
+		Amount:                 big.NewInt(1000),
+		CollateralLockedAmount: big.NewInt(50),
+		LiquidationPrice:       big.NewInt(7),
+		Status:                 lendingstate.TradeStatusOpen,
+	}
+	item := &lendingstate.LendingItem{Type: lendingstate.Limit}
+	if err := l.IndexLendingLogs(chain.blocks[1], lendingstate.LendingItemLogs(common.HexToHash("0x11"), item, []*lendingstate.LendingTrade{trade})); err != nil {
+		t.Fatalf("failed to index logs: %v", err)
+	}
+	liquidated := *trade
+	liquidated.Status = lendingstate.TradeStatusLiquidated
+	finalized := lendingstate.FinalizedResult{Liquidated: []common.Hash{liquidated.Hash}, TxHash: common.HexToHash("0x12")}
+	if err := l.IndexLendingLogs(chain.blocks[2], lendingstate.FinalizedTradeLogs(finalized, map[common.Hash]*lendingstate.LendingTrade{liquidated.Hash: &liquidated})); err != nil {
+		t.Fatalf("failed to index logs: %v", err)
+	}
+	// the logs of a block reorganised out of the chain are not returned
+	fork := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Time: big.NewInt(100)})
+	if err := l.IndexLendingLogs(fork, lendingstate.LendingItemLogs(common.HexToHash("0x13"), item, []*lendingstate.LendingTrade{trade})); err != nil {
+		t.Fatalf("failed to index logs: %v", err)
+	}
+
+	from := rpc.BlockNumber(0)
+	logs, err := l.getLendingLogs(LendingLogsFilter{FromBlock: &from})
+	if err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	if len(logs) != 2 || logs[0].Topics[0] != lendingstate.LendingTradeEvent || logs[1].Topics[0] != lendingstate.LendingLiquidationEvent {
+		t.Fatalf("wrong logs: %v", logs)
+	}
+	if logs[0].BlockHash != chain.blocks[1].Hash() || logs[0].TxHash != common.HexToHash("0x11") || len(logs[0].Data) != 7*common.HashLength {
+		t.Fatalf("wrong trade log: %v", logs[0])
+	}
+
+	// the borrower is the third topic of the events
+	topics := [][]common.Hash{{lendingstate.LendingLiquidationEvent}, nil, {common.BytesToHash(borrower.Bytes())}}
+	if logs, _ := l.getLendingLogs(LendingLogsFilter{FromBlock: &from, Topics: topics}); len(logs) != 1 || logs[0].BlockNumber != 2 {
+		t.Fatalf("wrong filtered logs: %v", logs)
+	}
+	hash := fork.Hash()
+	if _, err := l.getLendingLogs(LendingLogsFilter{BlockHash: &hash}); err == nil {
+		t.Fatalf("got logs of an unknown block")
+	}
+	to := rpc.BlockNumber(maxLendingLogsRange)
+	if _, err := l.getLendingLogs(LendingLogsFilter{FromBlock: &from, ToBlock: &to}); err != nil {
+		t.Fatalf("range clamped to the head: %v", err)
+	}
+}
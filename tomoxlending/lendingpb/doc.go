@@ -0,0 +1,5 @@
+//go:generate protoc --go_out=plugins=grpc:. lending.proto
+
+// Package lendingpb contains the protobuf messages and the gRPC service of the lending SDK
+// nodes, served by tomoxlending.
+package lendingpb
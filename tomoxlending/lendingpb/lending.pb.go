@@ -0,0 +1,1413 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: lending.proto
+
+package lendingpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type LendingItem struct {
+	Nonce           uint64 `protobuf:"varint,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Quantity        []byte `protobuf:"bytes,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Interest        uint64 `protobuf:"varint,3,opt,name=interest,proto3" json:"interest,omitempty"`
+	Term            uint64 `protobuf:"varint,4,opt,name=term,proto3" json:"term,omitempty"`
+	Relayer         []byte `protobuf:"bytes,5,opt,name=relayer,proto3" json:"relayer,omitempty"`
+	UserAddress     []byte `protobuf:"bytes,6,opt,name=user_address,json=userAddress,proto3" json:"user_address,omitempty"`
+	LendingToken    []byte `protobuf:"bytes,7,opt,name=lending_token,json=lendingToken,proto3" json:"lending_token,omitempty"`
+	CollateralToken []byte `protobuf:"bytes,8,opt,name=collateral_token,json=collateralToken,proto3" json:"collateral_token,omitempty"`
+	AutoTopUp       bool   `protobuf:"varint,9,opt,name=auto_top_up,json=autoTopUp,proto3" json:"auto_top_up,omitempty"`
+	Status          string `protobuf:"bytes,10,opt,name=status,proto3" json:"status,omitempty"`
+	Side            string `protobuf:"bytes,11,opt,name=side,proto3" json:"side,omitempty"`
+	Type            string `protobuf:"bytes,12,opt,name=type,proto3" json:"type,omitempty"`
+	LendingId       uint64 `protobuf:"varint,13,opt,name=lending_id,json=lendingId,proto3" json:"lending_id,omitempty"`
+	LendingTradeId  uint64 `protobuf:"varint,14,opt,name=lending_trade_id,json=lendingTradeId,proto3" json:"lending_trade_id,omitempty"`
+	ExtraData       string `protobuf:"bytes,15,opt,name=extra_data,json=extraData,proto3" json:"extra_data,omitempty"`
+	Hash            []byte `protobuf:"bytes,16,opt,name=hash,proto3" json:"hash,omitempty"`
+	V               uint32 `protobuf:"varint,17,opt,name=v,proto3" json:"v,omitempty"`
+	R               []byte `protobuf:"bytes,18,opt,name=r,proto3" json:"r,omitempty"`
+	S               []byte `protobuf:"bytes,19,opt,name=s,proto3" json:"s,omitempty"`
+	// recorded by the SDK node
+	TxHash               []byte   `protobuf:"bytes,20,opt,name=tx_hash,json=txHash,proto3" json:"tx_hash,omitempty"`
+	FilledAmount         []byte   `protobuf:"bytes,21,opt,name=filled_amount,json=filledAmount,proto3" json:"filled_amount,omitempty"`
+	RejectReason         string   `protobuf:"bytes,22,opt,name=reject_reason,json=rejectReason,proto3" json:"reject_reason,omitempty"`
+	CreatedAt            int64    `protobuf:"varint,23,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            int64    `protobuf:"varint,24,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LendingItem) Reset()         { *m = LendingItem{} }
+func (m *LendingItem) String() string { return proto.CompactTextString(m) }
+func (*LendingItem) ProtoMessage()    {}
+func (*LendingItem) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{0}
+}
+
+func (m *LendingItem) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LendingItem.Unmarshal(m, b)
+}
+func (m *LendingItem) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LendingItem.Marshal(b, m, deterministic)
+}
+func (m *LendingItem) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LendingItem.Merge(m, src)
+}
+func (m *LendingItem) XXX_Size() int {
+	return xxx_messageInfo_LendingItem.Size(m)
+}
+func (m *LendingItem) XXX_DiscardUnknown() {
+	xxx_messageInfo_LendingItem.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LendingItem proto.InternalMessageInfo
+
+func (m *LendingItem) GetNonce() uint64 {
+	if m != nil {
+		return m.Nonce
+	}
+	return 0
+}
+
+func (m *LendingItem) GetQuantity() []byte {
+	if m != nil {
+		return m.Quantity
+	}
+	return nil
+}
+
+func (m *LendingItem) GetInterest() uint64 {
+	if m != nil {
+		return m.Interest
+	}
+	return 0
+}
+
+func (m *LendingItem) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *LendingItem) GetRelayer() []byte {
+	if m != nil {
+		return m.Relayer
+	}
+	return nil
+}
+
+func (m *LendingItem) GetUserAddress() []byte {
+	if m != nil {
+		return m.UserAddress
+	}
+	return nil
+}
+
+func (m *LendingItem) GetLendingToken() []byte {
+	if m != nil {
+		return m.LendingToken
+	}
+	return nil
+}
+
+func (m *LendingItem) GetCollateralToken() []byte {
+	if m != nil {
+		return m.CollateralToken
+	}
+	return nil
+}
+
+func (m *LendingItem) GetAutoTopUp() bool {
+	if m != nil {
+		return m.AutoTopUp
+	}
+	return false
+}
+
+func (m *LendingItem) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *LendingItem) GetSide() string {
+	if m != nil {
+		return m.Side
+	}
+	return ""
+}
+
+func (m *LendingItem) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *LendingItem) GetLendingId() uint64 {
+	if m != nil {
+		return m.LendingId
+	}
+	return 0
+}
+
+func (m *LendingItem) GetLendingTradeId() uint64 {
+	if m != nil {
+		return m.LendingTradeId
+	}
+	return 0
+}
+
+func (m *LendingItem) GetExtraData() string {
+	if m != nil {
+		return m.ExtraData
+	}
+	return ""
+}
+
+func (m *LendingItem) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *LendingItem) GetV() uint32 {
+	if m != nil {
+		return m.V
+	}
+	return 0
+}
+
+func (m *LendingItem) GetR() []byte {
+	if m != nil {
+		return m.R
+	}
+	return nil
+}
+
+func (m *LendingItem) GetS() []byte {
+	if m != nil {
+		return m.S
+	}
+	return nil
+}
+
+func (m *LendingItem) GetTxHash() []byte {
+	if m != nil {
+		return m.TxHash
+	}
+	return nil
+}
+
+func (m *LendingItem) GetFilledAmount() []byte {
+	if m != nil {
+		return m.FilledAmount
+	}
+	return nil
+}
+
+func (m *LendingItem) GetRejectReason() string {
+	if m != nil {
+		return m.RejectReason
+	}
+	return ""
+}
+
+func (m *LendingItem) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *LendingItem) GetUpdatedAt() int64 {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return 0
+}
+
+type LendingTrade struct {
+	Hash                   []byte   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	TxHash                 []byte   `protobuf:"bytes,2,opt,name=tx_hash,json=txHash,proto3" json:"tx_hash,omitempty"`
+	TradeId                uint64   `protobuf:"varint,3,opt,name=trade_id,json=tradeId,proto3" json:"trade_id,omitempty"`
+	Borrower               []byte   `protobuf:"bytes,4,opt,name=borrower,proto3" json:"borrower,omitempty"`
+	Investor               []byte   `protobuf:"bytes,5,opt,name=investor,proto3" json:"investor,omitempty"`
+	LendingToken           []byte   `protobuf:"bytes,6,opt,name=lending_token,json=lendingToken,proto3" json:"lending_token,omitempty"`
+	CollateralToken        []byte   `protobuf:"bytes,7,opt,name=collateral_token,json=collateralToken,proto3" json:"collateral_token,omitempty"`
+	BorrowingOrderHash     []byte   `protobuf:"bytes,8,opt,name=borrowing_order_hash,json=borrowingOrderHash,proto3" json:"borrowing_order_hash,omitempty"`
+	InvestingOrderHash     []byte   `protobuf:"bytes,9,opt,name=investing_order_hash,json=investingOrderHash,proto3" json:"investing_order_hash,omitempty"`
+	BorrowingRelayer       []byte   `protobuf:"bytes,10,opt,name=borrowing_relayer,json=borrowingRelayer,proto3" json:"borrowing_relayer,omitempty"`
+	InvestingRelayer       []byte   `protobuf:"bytes,11,opt,name=investing_relayer,json=investingRelayer,proto3" json:"investing_relayer,omitempty"`
+	Term                   uint64   `protobuf:"varint,12,opt,name=term,proto3" json:"term,omitempty"`
+	Interest               uint64   `protobuf:"varint,13,opt,name=interest,proto3" json:"interest,omitempty"`
+	Amount                 []byte   `protobuf:"bytes,14,opt,name=amount,proto3" json:"amount,omitempty"`
+	CollateralPrice        []byte   `protobuf:"bytes,15,opt,name=collateral_price,json=collateralPrice,proto3" json:"collateral_price,omitempty"`
+	LiquidationPrice       []byte   `protobuf:"bytes,16,opt,name=liquidation_price,json=liquidationPrice,proto3" json:"liquidation_price,omitempty"`
+	CollateralLockedAmount []byte   `protobuf:"bytes,17,opt,name=collateral_locked_amount,json=collateralLockedAmount,proto3" json:"collateral_locked_amount,omitempty"`
+	AutoTopUp              bool     `protobuf:"varint,18,opt,name=auto_top_up,json=autoTopUp,proto3" json:"auto_top_up,omitempty"`
+	LiquidationTime        uint64   `protobuf:"varint,19,opt,name=liquidation_time,json=liquidationTime,proto3" json:"liquidation_time,omitempty"`
+	BorrowingFee           []byte   `protobuf:"bytes,20,opt,name=borrowing_fee,json=borrowingFee,proto3" json:"borrowing_fee,omitempty"`
+	InvestingFee           []byte   `protobuf:"bytes,21,opt,name=investing_fee,json=investingFee,proto3" json:"investing_fee,omitempty"`
+	Status                 string   `protobuf:"bytes,22,opt,name=status,proto3" json:"status,omitempty"`
+	TakerOrderSide         string   `protobuf:"bytes,23,opt,name=taker_order_side,json=takerOrderSide,proto3" json:"taker_order_side,omitempty"`
+	TakerOrderType         string   `protobuf:"bytes,24,opt,name=taker_order_type,json=takerOrderType,proto3" json:"taker_order_type,omitempty"`
+	MakerOrderType         string   `protobuf:"bytes,25,opt,name=maker_order_type,json=makerOrderType,proto3" json:"maker_order_type,omitempty"`
+	ExtraData              string   `protobuf:"bytes,26,opt,name=extra_data,json=extraData,proto3" json:"extra_data,omitempty"`
+	CreatedAt              int64    `protobuf:"varint,27,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt              int64    `protobuf:"varint,28,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral   struct{} `json:"-"`
+	XXX_unrecognized       []byte   `json:"-"`
+	XXX_sizecache          int32    `json:"-"`
+}
+
+func (m *LendingTrade) Reset()         { *m = LendingTrade{} }
+func (m *LendingTrade) String() string { return proto.CompactTextString(m) }
+func (*LendingTrade) ProtoMessage()    {}
+func (*LendingTrade) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{1}
+}
+
+func (m *LendingTrade) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LendingTrade.Unmarshal(m, b)
+}
+func (m *LendingTrade) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LendingTrade.Marshal(b, m, deterministic)
+}
+func (m *LendingTrade) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LendingTrade.Merge(m, src)
+}
+func (m *LendingTrade) XXX_Size() int {
+	return xxx_messageInfo_LendingTrade.Size(m)
+}
+func (m *LendingTrade) XXX_DiscardUnknown() {
+	xxx_messageInfo_LendingTrade.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LendingTrade proto.InternalMessageInfo
+
+func (m *LendingTrade) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetTxHash() []byte {
+	if m != nil {
+		return m.TxHash
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetTradeId() uint64 {
+	if m != nil {
+		return m.TradeId
+	}
+	return 0
+}
+
+func (m *LendingTrade) GetBorrower() []byte {
+	if m != nil {
+		return m.Borrower
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetInvestor() []byte {
+	if m != nil {
+		return m.Investor
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetLendingToken() []byte {
+	if m != nil {
+		return m.LendingToken
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetCollateralToken() []byte {
+	if m != nil {
+		return m.CollateralToken
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetBorrowingOrderHash() []byte {
+	if m != nil {
+		return m.BorrowingOrderHash
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetInvestingOrderHash() []byte {
+	if m != nil {
+		return m.InvestingOrderHash
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetBorrowingRelayer() []byte {
+	if m != nil {
+		return m.BorrowingRelayer
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetInvestingRelayer() []byte {
+	if m != nil {
+		return m.InvestingRelayer
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *LendingTrade) GetInterest() uint64 {
+	if m != nil {
+		return m.Interest
+	}
+	return 0
+}
+
+func (m *LendingTrade) GetAmount() []byte {
+	if m != nil {
+		return m.Amount
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetCollateralPrice() []byte {
+	if m != nil {
+		return m.CollateralPrice
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetLiquidationPrice() []byte {
+	if m != nil {
+		return m.LiquidationPrice
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetCollateralLockedAmount() []byte {
+	if m != nil {
+		return m.CollateralLockedAmount
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetAutoTopUp() bool {
+	if m != nil {
+		return m.AutoTopUp
+	}
+	return false
+}
+
+func (m *LendingTrade) GetLiquidationTime() uint64 {
+	if m != nil {
+		return m.LiquidationTime
+	}
+	return 0
+}
+
+func (m *LendingTrade) GetBorrowingFee() []byte {
+	if m != nil {
+		return m.BorrowingFee
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetInvestingFee() []byte {
+	if m != nil {
+		return m.InvestingFee
+	}
+	return nil
+}
+
+func (m *LendingTrade) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *LendingTrade) GetTakerOrderSide() string {
+	if m != nil {
+		return m.TakerOrderSide
+	}
+	return ""
+}
+
+func (m *LendingTrade) GetTakerOrderType() string {
+	if m != nil {
+		return m.TakerOrderType
+	}
+	return ""
+}
+
+func (m *LendingTrade) GetMakerOrderType() string {
+	if m != nil {
+		return m.MakerOrderType
+	}
+	return ""
+}
+
+func (m *LendingTrade) GetExtraData() string {
+	if m != nil {
+		return m.ExtraData
+	}
+	return ""
+}
+
+func (m *LendingTrade) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *LendingTrade) GetUpdatedAt() int64 {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return 0
+}
+
+type SendLendingItemsRequest struct {
+	Items                []*LendingItem `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *SendLendingItemsRequest) Reset()         { *m = SendLendingItemsRequest{} }
+func (m *SendLendingItemsRequest) String() string { return proto.CompactTextString(m) }
+func (*SendLendingItemsRequest) ProtoMessage()    {}
+func (*SendLendingItemsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{2}
+}
+
+func (m *SendLendingItemsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SendLendingItemsRequest.Unmarshal(m, b)
+}
+func (m *SendLendingItemsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SendLendingItemsRequest.Marshal(b, m, deterministic)
+}
+func (m *SendLendingItemsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SendLendingItemsRequest.Merge(m, src)
+}
+func (m *SendLendingItemsRequest) XXX_Size() int {
+	return xxx_messageInfo_SendLendingItemsRequest.Size(m)
+}
+func (m *SendLendingItemsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SendLendingItemsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SendLendingItemsRequest proto.InternalMessageInfo
+
+func (m *SendLendingItemsRequest) GetItems() []*LendingItem {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+type SendLendingItemsReply struct {
+	Hashes               [][]byte `protobuf:"bytes,1,rep,name=hashes,proto3" json:"hashes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SendLendingItemsReply) Reset()         { *m = SendLendingItemsReply{} }
+func (m *SendLendingItemsReply) String() string { return proto.CompactTextString(m) }
+func (*SendLendingItemsReply) ProtoMessage()    {}
+func (*SendLendingItemsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{3}
+}
+
+func (m *SendLendingItemsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SendLendingItemsReply.Unmarshal(m, b)
+}
+func (m *SendLendingItemsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SendLendingItemsReply.Marshal(b, m, deterministic)
+}
+func (m *SendLendingItemsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SendLendingItemsReply.Merge(m, src)
+}
+func (m *SendLendingItemsReply) XXX_Size() int {
+	return xxx_messageInfo_SendLendingItemsReply.Size(m)
+}
+func (m *SendLendingItemsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_SendLendingItemsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SendLendingItemsReply proto.InternalMessageInfo
+
+func (m *SendLendingItemsReply) GetHashes() [][]byte {
+	if m != nil {
+		return m.Hashes
+	}
+	return nil
+}
+
+type OrderBookRequest struct {
+	LendingToken []byte `protobuf:"bytes,1,opt,name=lending_token,json=lendingToken,proto3" json:"lending_token,omitempty"`
+	Term         uint64 `protobuf:"varint,2,opt,name=term,proto3" json:"term,omitempty"`
+	// maximum number of levels per side, all of them if zero
+	Levels               uint32   `protobuf:"varint,3,opt,name=levels,proto3" json:"levels,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OrderBookRequest) Reset()         { *m = OrderBookRequest{} }
+func (m *OrderBookRequest) String() string { return proto.CompactTextString(m) }
+func (*OrderBookRequest) ProtoMessage()    {}
+func (*OrderBookRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{4}
+}
+
+func (m *OrderBookRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OrderBookRequest.Unmarshal(m, b)
+}
+func (m *OrderBookRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OrderBookRequest.Marshal(b, m, deterministic)
+}
+func (m *OrderBookRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OrderBookRequest.Merge(m, src)
+}
+func (m *OrderBookRequest) XXX_Size() int {
+	return xxx_messageInfo_OrderBookRequest.Size(m)
+}
+func (m *OrderBookRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_OrderBookRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OrderBookRequest proto.InternalMessageInfo
+
+func (m *OrderBookRequest) GetLendingToken() []byte {
+	if m != nil {
+		return m.LendingToken
+	}
+	return nil
+}
+
+func (m *OrderBookRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *OrderBookRequest) GetLevels() uint32 {
+	if m != nil {
+		return m.Levels
+	}
+	return 0
+}
+
+type OrderBookLevel struct {
+	Interest             uint64   `protobuf:"varint,1,opt,name=interest,proto3" json:"interest,omitempty"`
+	Volume               []byte   `protobuf:"bytes,2,opt,name=volume,proto3" json:"volume,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OrderBookLevel) Reset()         { *m = OrderBookLevel{} }
+func (m *OrderBookLevel) String() string { return proto.CompactTextString(m) }
+func (*OrderBookLevel) ProtoMessage()    {}
+func (*OrderBookLevel) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{5}
+}
+
+func (m *OrderBookLevel) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OrderBookLevel.Unmarshal(m, b)
+}
+func (m *OrderBookLevel) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OrderBookLevel.Marshal(b, m, deterministic)
+}
+func (m *OrderBookLevel) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OrderBookLevel.Merge(m, src)
+}
+func (m *OrderBookLevel) XXX_Size() int {
+	return xxx_messageInfo_OrderBookLevel.Size(m)
+}
+func (m *OrderBookLevel) XXX_DiscardUnknown() {
+	xxx_messageInfo_OrderBookLevel.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OrderBookLevel proto.InternalMessageInfo
+
+func (m *OrderBookLevel) GetInterest() uint64 {
+	if m != nil {
+		return m.Interest
+	}
+	return 0
+}
+
+func (m *OrderBookLevel) GetVolume() []byte {
+	if m != nil {
+		return m.Volume
+	}
+	return nil
+}
+
+type OrderBook struct {
+	LendingToken []byte `protobuf:"bytes,1,opt,name=lending_token,json=lendingToken,proto3" json:"lending_token,omitempty"`
+	Term         uint64 `protobuf:"varint,2,opt,name=term,proto3" json:"term,omitempty"`
+	BlockHash    []byte `protobuf:"bytes,3,opt,name=block_hash,json=blockHash,proto3" json:"block_hash,omitempty"`
+	BlockNumber  uint64 `protobuf:"varint,4,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	// sorted by ascending interest
+	Investing []*OrderBookLevel `protobuf:"bytes,5,rep,name=investing,proto3" json:"investing,omitempty"`
+	// sorted by descending interest
+	Borrowing            []*OrderBookLevel `protobuf:"bytes,6,rep,name=borrowing,proto3" json:"borrowing,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *OrderBook) Reset()         { *m = OrderBook{} }
+func (m *OrderBook) String() string { return proto.CompactTextString(m) }
+func (*OrderBook) ProtoMessage()    {}
+func (*OrderBook) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{6}
+}
+
+func (m *OrderBook) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OrderBook.Unmarshal(m, b)
+}
+func (m *OrderBook) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OrderBook.Marshal(b, m, deterministic)
+}
+func (m *OrderBook) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OrderBook.Merge(m, src)
+}
+func (m *OrderBook) XXX_Size() int {
+	return xxx_messageInfo_OrderBook.Size(m)
+}
+func (m *OrderBook) XXX_DiscardUnknown() {
+	xxx_messageInfo_OrderBook.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OrderBook proto.InternalMessageInfo
+
+func (m *OrderBook) GetLendingToken() []byte {
+	if m != nil {
+		return m.LendingToken
+	}
+	return nil
+}
+
+func (m *OrderBook) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *OrderBook) GetBlockHash() []byte {
+	if m != nil {
+		return m.BlockHash
+	}
+	return nil
+}
+
+func (m *OrderBook) GetBlockNumber() uint64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+func (m *OrderBook) GetInvesting() []*OrderBookLevel {
+	if m != nil {
+		return m.Investing
+	}
+	return nil
+}
+
+func (m *OrderBook) GetBorrowing() []*OrderBookLevel {
+	if m != nil {
+		return m.Borrowing
+	}
+	return nil
+}
+
+type LendingTradesRequest struct {
+	User []byte `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	// empty lending token, zero term and empty status match everything
+	LendingToken []byte `protobuf:"bytes,2,opt,name=lending_token,json=lendingToken,proto3" json:"lending_token,omitempty"`
+	Term         uint64 `protobuf:"varint,3,opt,name=term,proto3" json:"term,omitempty"`
+	Status       string `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	Page         uint32 `protobuf:"varint,5,opt,name=page,proto3" json:"page,omitempty"`
+	Limit        uint32 `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`
+	// empty to match every relayer
+	Relayer              []byte   `protobuf:"bytes,7,opt,name=relayer,proto3" json:"relayer,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LendingTradesRequest) Reset()         { *m = LendingTradesRequest{} }
+func (m *LendingTradesRequest) String() string { return proto.CompactTextString(m) }
+func (*LendingTradesRequest) ProtoMessage()    {}
+func (*LendingTradesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{7}
+}
+
+func (m *LendingTradesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LendingTradesRequest.Unmarshal(m, b)
+}
+func (m *LendingTradesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LendingTradesRequest.Marshal(b, m, deterministic)
+}
+func (m *LendingTradesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LendingTradesRequest.Merge(m, src)
+}
+func (m *LendingTradesRequest) XXX_Size() int {
+	return xxx_messageInfo_LendingTradesRequest.Size(m)
+}
+func (m *LendingTradesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LendingTradesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LendingTradesRequest proto.InternalMessageInfo
+
+func (m *LendingTradesRequest) GetUser() []byte {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *LendingTradesRequest) GetLendingToken() []byte {
+	if m != nil {
+		return m.LendingToken
+	}
+	return nil
+}
+
+func (m *LendingTradesRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *LendingTradesRequest) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *LendingTradesRequest) GetPage() uint32 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *LendingTradesRequest) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *LendingTradesRequest) GetRelayer() []byte {
+	if m != nil {
+		return m.Relayer
+	}
+	return nil
+}
+
+type LendingTrades struct {
+	Trades               []*LendingTrade `protobuf:"bytes,1,rep,name=trades,proto3" json:"trades,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *LendingTrades) Reset()         { *m = LendingTrades{} }
+func (m *LendingTrades) String() string { return proto.CompactTextString(m) }
+func (*LendingTrades) ProtoMessage()    {}
+func (*LendingTrades) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{8}
+}
+
+func (m *LendingTrades) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LendingTrades.Unmarshal(m, b)
+}
+func (m *LendingTrades) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LendingTrades.Marshal(b, m, deterministic)
+}
+func (m *LendingTrades) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LendingTrades.Merge(m, src)
+}
+func (m *LendingTrades) XXX_Size() int {
+	return xxx_messageInfo_LendingTrades.Size(m)
+}
+func (m *LendingTrades) XXX_DiscardUnknown() {
+	xxx_messageInfo_LendingTrades.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LendingTrades proto.InternalMessageInfo
+
+func (m *LendingTrades) GetTrades() []*LendingTrade {
+	if m != nil {
+		return m.Trades
+	}
+	return nil
+}
+
+// LendingFilter selects the streamed items and trades, empty fields match everything.
+type LendingFilter struct {
+	UserAddress          []byte   `protobuf:"bytes,1,opt,name=user_address,json=userAddress,proto3" json:"user_address,omitempty"`
+	LendingToken         []byte   `protobuf:"bytes,2,opt,name=lending_token,json=lendingToken,proto3" json:"lending_token,omitempty"`
+	CollateralToken      []byte   `protobuf:"bytes,3,opt,name=collateral_token,json=collateralToken,proto3" json:"collateral_token,omitempty"`
+	Term                 uint64   `protobuf:"varint,4,opt,name=term,proto3" json:"term,omitempty"`
+	Relayer              []byte   `protobuf:"bytes,5,opt,name=relayer,proto3" json:"relayer,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LendingFilter) Reset()         { *m = LendingFilter{} }
+func (m *LendingFilter) String() string { return proto.CompactTextString(m) }
+func (*LendingFilter) ProtoMessage()    {}
+func (*LendingFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_71ed9f67089a5657, []int{9}
+}
+
+func (m *LendingFilter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LendingFilter.Unmarshal(m, b)
+}
+func (m *LendingFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LendingFilter.Marshal(b, m, deterministic)
+}
+func (m *LendingFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LendingFilter.Merge(m, src)
+}
+func (m *LendingFilter) XXX_Size() int {
+	return xxx_messageInfo_LendingFilter.Size(m)
+}
+func (m *LendingFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_LendingFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LendingFilter proto.InternalMessageInfo
+
+func (m *LendingFilter) GetUserAddress() []byte {
+	if m != nil {
+		return m.UserAddress
+	}
+	return nil
+}
+
+func (m *LendingFilter) GetLendingToken() []byte {
+	if m != nil {
+		return m.LendingToken
+	}
+	return nil
+}
+
+func (m *LendingFilter) GetCollateralToken() []byte {
+	if m != nil {
+		return m.CollateralToken
+	}
+	return nil
+}
+
+func (m *LendingFilter) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *LendingFilter) GetRelayer() []byte {
+	if m != nil {
+		return m.Relayer
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*LendingItem)(nil), "lendingpb.LendingItem")
+	proto.RegisterType((*LendingTrade)(nil), "lendingpb.LendingTrade")
+	proto.RegisterType((*SendLendingItemsRequest)(nil), "lendingpb.SendLendingItemsRequest")
+	proto.RegisterType((*SendLendingItemsReply)(nil), "lendingpb.SendLendingItemsReply")
+	proto.RegisterType((*OrderBookRequest)(nil), "lendingpb.OrderBookRequest")
+	proto.RegisterType((*OrderBookLevel)(nil), "lendingpb.OrderBookLevel")
+	proto.RegisterType((*OrderBook)(nil), "lendingpb.OrderBook")
+	proto.RegisterType((*LendingTradesRequest)(nil), "lendingpb.LendingTradesRequest")
+	proto.RegisterType((*LendingTrades)(nil), "lendingpb.LendingTrades")
+	proto.RegisterType((*LendingFilter)(nil), "lendingpb.LendingFilter")
+}
+
+func init() { proto.RegisterFile("lending.proto", fileDescriptor_71ed9f67089a5657) }
+
+var fileDescriptor_71ed9f67089a5657 = []byte{
+	// 1143 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x57, 0x5f, 0x73, 0xdb, 0x44,
+	0x10, 0x1f, 0xc5, 0x8e, 0x1d, 0xaf, 0xe5, 0xc4, 0x39, 0x1c, 0xe7, 0x92, 0x52, 0x70, 0xdd, 0x17,
+	0x77, 0x60, 0xd2, 0x4c, 0x79, 0x80, 0x47, 0xd2, 0xe9, 0x34, 0x64, 0x48, 0x81, 0x51, 0xc2, 0x0c,
+	0xc3, 0x8b, 0x47, 0xb6, 0xb6, 0xad, 0x88, 0x2c, 0x29, 0xa7, 0x53, 0x48, 0x3e, 0x0f, 0x5f, 0x80,
+	0x6f, 0xc0, 0x17, 0xe1, 0x83, 0xf0, 0xc4, 0x30, 0xb7, 0x27, 0x9d, 0xfe, 0xd8, 0x0e, 0x81, 0xbe,
+	0x69, 0x7f, 0xfb, 0xbb, 0xbd, 0xdb, 0xbb, 0xdf, 0xee, 0x9d, 0xa0, 0x17, 0x60, 0xe8, 0xf9, 0xe1,
+	0xbb, 0xa3, 0x58, 0x44, 0x32, 0x62, 0x9d, 0xcc, 0x8c, 0x67, 0xe3, 0xdf, 0x36, 0xa1, 0x7b, 0xae,
+	0xad, 0x33, 0x89, 0x0b, 0x36, 0x80, 0xcd, 0x30, 0x0a, 0xe7, 0xc8, 0xad, 0x91, 0x35, 0x69, 0x3a,
+	0xda, 0x60, 0x87, 0xb0, 0x75, 0x9d, 0xba, 0xa1, 0xf4, 0xe5, 0x1d, 0xdf, 0x18, 0x59, 0x13, 0xdb,
+	0x31, 0xb6, 0xf2, 0xf9, 0xa1, 0x44, 0x81, 0x89, 0xe4, 0x0d, 0x1a, 0x64, 0x6c, 0xc6, 0xa0, 0x29,
+	0x51, 0x2c, 0x78, 0x93, 0x70, 0xfa, 0x66, 0x1c, 0xda, 0x02, 0x03, 0xf7, 0x0e, 0x05, 0xdf, 0xa4,
+	0x50, 0xb9, 0xc9, 0x9e, 0x80, 0x9d, 0x26, 0x28, 0xa6, 0xae, 0xe7, 0x09, 0x4c, 0x12, 0xde, 0x22,
+	0x77, 0x57, 0x61, 0x27, 0x1a, 0x62, 0x4f, 0x4d, 0x2a, 0x53, 0x19, 0x5d, 0x61, 0xc8, 0xdb, 0xc4,
+	0xb1, 0x33, 0xf0, 0x52, 0x61, 0xec, 0x19, 0xf4, 0xe7, 0x51, 0x10, 0xb8, 0x12, 0x85, 0x1b, 0x64,
+	0xbc, 0x2d, 0xe2, 0xed, 0x14, 0xb8, 0xa6, 0x7e, 0x02, 0x5d, 0x37, 0x95, 0xd1, 0x54, 0x46, 0xf1,
+	0x34, 0x8d, 0x79, 0x67, 0x64, 0x4d, 0xb6, 0x9c, 0x8e, 0x82, 0x2e, 0xa3, 0xf8, 0xc7, 0x98, 0x0d,
+	0xa1, 0x95, 0x48, 0x57, 0xa6, 0x09, 0x87, 0x91, 0x35, 0xe9, 0x38, 0x99, 0xa5, 0x12, 0x4b, 0x7c,
+	0x0f, 0x79, 0x97, 0x50, 0xfa, 0xa6, 0x64, 0xef, 0x62, 0xe4, 0xb6, 0xc6, 0xd4, 0x37, 0x7b, 0x0c,
+	0x90, 0xaf, 0xd7, 0xf7, 0x78, 0x8f, 0xb6, 0x21, 0xdf, 0xfd, 0x33, 0x8f, 0x4d, 0xa0, 0x6f, 0xd2,
+	0x11, 0xae, 0x87, 0x8a, 0xb4, 0x4d, 0xa4, 0xed, 0x3c, 0x23, 0x05, 0x9f, 0x79, 0x2a, 0x10, 0xde,
+	0x4a, 0xe1, 0x4e, 0x3d, 0x57, 0xba, 0x7c, 0x87, 0xa6, 0xe8, 0x10, 0xf2, 0xca, 0x95, 0xae, 0x9a,
+	0xfb, 0xbd, 0x9b, 0xbc, 0xe7, 0x7d, 0x4a, 0x93, 0xbe, 0x99, 0x0d, 0xd6, 0x0d, 0xdf, 0x1d, 0x59,
+	0x93, 0x9e, 0x63, 0xdd, 0x28, 0x4b, 0x70, 0x46, 0x6e, 0x4b, 0x28, 0x2b, 0xe1, 0x1f, 0x69, 0x2b,
+	0x61, 0xfb, 0xd0, 0x96, 0xb7, 0x53, 0x0a, 0x30, 0x20, 0xac, 0x25, 0x6f, 0xbf, 0x51, 0x21, 0x9e,
+	0x42, 0xef, 0xad, 0x1f, 0x04, 0xe8, 0x4d, 0xdd, 0x45, 0x94, 0x86, 0x92, 0xef, 0xe9, 0xed, 0xd6,
+	0xe0, 0x09, 0x61, 0x8a, 0x24, 0xf0, 0x17, 0x9c, 0xcb, 0xa9, 0x40, 0x37, 0x89, 0x42, 0x3e, 0xa4,
+	0xd5, 0xd9, 0x1a, 0x74, 0x08, 0x53, 0xeb, 0x9f, 0x0b, 0x74, 0xa5, 0x0a, 0x25, 0xf9, 0xfe, 0xc8,
+	0x9a, 0x34, 0x9c, 0x4e, 0x86, 0x9c, 0x48, 0xe5, 0x4e, 0x63, 0x2f, 0x77, 0x73, 0xed, 0xce, 0x90,
+	0x13, 0x39, 0xfe, 0xbb, 0x0d, 0xf6, 0x79, 0x69, 0x43, 0x4c, 0xbe, 0x56, 0x29, 0xdf, 0x52, 0x16,
+	0x1b, 0x95, 0x2c, 0x0e, 0x60, 0xcb, 0xec, 0xae, 0x56, 0x68, 0x5b, 0x66, 0xdb, 0x7a, 0x08, 0x5b,
+	0xb3, 0x48, 0x88, 0xe8, 0x57, 0x14, 0x24, 0x52, 0xdb, 0x31, 0xb6, 0x16, 0xf6, 0x0d, 0x26, 0x32,
+	0xca, 0x95, 0x6a, 0xec, 0x65, 0x1d, 0xb6, 0x1e, 0xa8, 0xc3, 0xf6, 0x6a, 0x1d, 0x1e, 0xc3, 0x40,
+	0xcf, 0xab, 0x22, 0x46, 0xc2, 0x43, 0xa1, 0x13, 0xd1, 0xb2, 0x65, 0xc6, 0xf7, 0xbd, 0x72, 0x51,
+	0x52, 0xc7, 0x30, 0xd0, 0xab, 0xa9, 0x8d, 0xe8, 0xe8, 0x11, 0xc6, 0x57, 0x8c, 0xf8, 0x0c, 0x76,
+	0x8b, 0x39, 0xf2, 0x12, 0x04, 0xa2, 0xf7, 0x8d, 0xc3, 0xd1, 0xb8, 0x22, 0x17, 0xe1, 0x73, 0x72,
+	0x57, 0x93, 0x8d, 0x23, 0x27, 0xe7, 0x65, 0x6e, 0x97, 0xca, 0xbc, 0xdc, 0x16, 0x7a, 0xb5, 0xb6,
+	0x30, 0x84, 0x56, 0xa6, 0xa7, 0x6d, 0x7d, 0x50, 0xda, 0xaa, 0x6d, 0x58, 0x2c, 0xfc, 0x39, 0xf2,
+	0x9d, 0xfa, 0x86, 0xfd, 0xa0, 0x60, 0xb5, 0xbe, 0xc0, 0xbf, 0x4e, 0x7d, 0xcf, 0x95, 0x7e, 0x14,
+	0x66, 0x5c, 0xad, 0xfe, 0x7e, 0xc9, 0xa1, 0xc9, 0x5f, 0x01, 0x2f, 0xc5, 0x0d, 0xa2, 0xf9, 0x55,
+	0xa1, 0xe8, 0x5d, 0x1a, 0x33, 0x2c, 0xfc, 0xe7, 0xe4, 0xce, 0xb4, 0x5d, 0xeb, 0x0f, 0xac, 0xde,
+	0x1f, 0x9e, 0x41, 0x79, 0xb6, 0xa9, 0xf4, 0x17, 0x48, 0x65, 0xd5, 0x74, 0x76, 0x4a, 0xf8, 0xa5,
+	0xbf, 0x40, 0x25, 0x99, 0x62, 0xfb, 0xdf, 0x22, 0x66, 0xa5, 0x66, 0x1b, 0xf0, 0x35, 0x12, 0xa9,
+	0xd8, 0x76, 0x45, 0xca, 0x0a, 0xce, 0x80, 0x8a, 0x54, 0x34, 0xa5, 0x61, 0xa5, 0x29, 0x4d, 0xa0,
+	0x2f, 0xdd, 0x2b, 0x14, 0x99, 0x1c, 0xa8, 0x41, 0xed, 0x13, 0x63, 0x9b, 0x70, 0x92, 0xc2, 0x85,
+	0x6a, 0x55, 0x35, 0x26, 0xb5, 0x2d, 0x5e, 0x67, 0x5e, 0xaa, 0x06, 0x36, 0x81, 0xfe, 0xa2, 0xce,
+	0x3c, 0xd0, 0xcc, 0x45, 0x95, 0x59, 0xed, 0x50, 0x87, 0xf5, 0x0e, 0x55, 0x6d, 0x00, 0x8f, 0xee,
+	0x6f, 0x00, 0x1f, 0xd7, 0x1b, 0xc0, 0x29, 0xec, 0x5f, 0x60, 0xe8, 0x95, 0x6e, 0xaa, 0xc4, 0xc1,
+	0xeb, 0x54, 0x89, 0xe9, 0x73, 0xd8, 0xf4, 0x95, 0xcd, 0xad, 0x51, 0x63, 0xd2, 0x7d, 0x31, 0x3c,
+	0x32, 0x97, 0xdb, 0x51, 0x89, 0xee, 0x68, 0xd2, 0xf8, 0x39, 0xec, 0x2d, 0x07, 0x8a, 0x83, 0x3b,
+	0xb5, 0xa9, 0xaa, 0x7e, 0x50, 0xc7, 0xb1, 0x9d, 0xcc, 0x1a, 0xcf, 0xa1, 0x4f, 0x39, 0xbe, 0x8c,
+	0xa2, 0xab, 0x7c, 0xca, 0xa5, 0xea, 0xb7, 0x56, 0x54, 0x7f, 0x5e, 0x14, 0x1b, 0xa5, 0xa2, 0x18,
+	0x42, 0x2b, 0xc0, 0x1b, 0x0c, 0x12, 0xea, 0x43, 0x3d, 0x27, 0xb3, 0xc6, 0xaf, 0x60, 0xdb, 0x4c,
+	0x72, 0xae, 0xa0, 0x4a, 0xf9, 0x58, 0xcb, 0xe5, 0x73, 0x13, 0x05, 0xe9, 0x02, 0xf3, 0x3e, 0xa7,
+	0xad, 0xf1, 0x5f, 0x16, 0x74, 0x4c, 0x98, 0xff, 0xbf, 0xc8, 0xc7, 0x00, 0x33, 0x55, 0x23, 0xba,
+	0x9f, 0x34, 0x68, 0x54, 0x87, 0x10, 0x6a, 0x23, 0x4f, 0xc0, 0xd6, 0xee, 0x30, 0x5d, 0xcc, 0xb2,
+	0xb6, 0xd9, 0x74, 0xba, 0x84, 0x7d, 0x47, 0x10, 0xfb, 0x12, 0x3a, 0x46, 0xb0, 0x7c, 0x93, 0x8e,
+	0xe5, 0xa0, 0x74, 0x2c, 0xd5, 0x54, 0x9d, 0x82, 0xab, 0x06, 0x9a, 0x72, 0xe0, 0xad, 0x7f, 0x1d,
+	0x68, 0xb8, 0xe3, 0x3f, 0x2c, 0x18, 0x94, 0x2f, 0x08, 0xa3, 0x0e, 0x06, 0x4d, 0xf5, 0x7e, 0xc8,
+	0x2f, 0x0a, 0xf5, 0xbd, 0xbc, 0x33, 0x1b, 0xf7, 0xec, 0x4c, 0xa3, 0x7a, 0x7c, 0x59, 0xe1, 0x35,
+	0xeb, 0xaf, 0x81, 0xd8, 0x7d, 0x87, 0x74, 0x4b, 0xf4, 0x1c, 0xfa, 0x56, 0x0f, 0xa9, 0xc0, 0x5f,
+	0xf8, 0x92, 0x6e, 0x86, 0x9e, 0xa3, 0x8d, 0xf2, 0xe3, 0xa7, 0x5d, 0x79, 0xfc, 0x8c, 0xbf, 0x86,
+	0x5e, 0x25, 0x01, 0xf6, 0x1c, 0x5a, 0x74, 0x4b, 0xe5, 0xc2, 0xde, 0x5f, 0x16, 0x36, 0x31, 0x9d,
+	0x8c, 0x36, 0xfe, 0xdd, 0x32, 0x21, 0x5e, 0xfb, 0x81, 0x5c, 0xf1, 0xa0, 0xb2, 0x1e, 0xf0, 0xa0,
+	0xda, 0x78, 0xe0, 0x45, 0xd6, 0x58, 0x7d, 0x91, 0xfd, 0xa7, 0x17, 0xdf, 0x8b, 0x3f, 0x1b, 0xd0,
+	0xce, 0x96, 0xcc, 0x7e, 0x82, 0x7e, 0xbd, 0x32, 0xd9, 0xb8, 0x94, 0xf3, 0x9a, 0xfa, 0x3f, 0x1c,
+	0xdd, 0xcb, 0x51, 0xa5, 0x7d, 0x02, 0xf6, 0x29, 0xca, 0xa2, 0x32, 0x1e, 0xad, 0x92, 0x54, 0x1e,
+	0x6e, 0xb0, 0xca, 0xc9, 0xde, 0x40, 0xff, 0x14, 0x65, 0xf5, 0x80, 0x3e, 0x5d, 0x73, 0x20, 0x66,
+	0x65, 0x7c, 0x1d, 0x81, 0x9d, 0x01, 0xbb, 0x48, 0x67, 0xc9, 0x5c, 0xf8, 0x33, 0xfc, 0x90, 0x75,
+	0x1d, 0x5b, 0xec, 0x0d, 0x0c, 0x4d, 0xa8, 0xea, 0x24, 0x2b, 0xa6, 0xd7, 0xba, 0x38, 0x5c, 0x27,
+	0xa5, 0x63, 0x8b, 0x7d, 0x0b, 0x7b, 0xf5, 0x70, 0xfa, 0x28, 0xd6, 0x47, 0x5b, 0xd3, 0x71, 0x8f,
+	0xad, 0x97, 0xdd, 0x9f, 0x8b, 0x3f, 0x8d, 0x59, 0x8b, 0xfe, 0x3d, 0xbe, 0xf8, 0x67, 0x00, 0xb6,
+	0x08, 0x40, 0xa6, 0x8c, 0x0c, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// LendingClient is the client API for Lending service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type LendingClient interface {
+	// SendLendingItems adds a batch of signed lending items to the lending pool, all of them or
+	// none, and returns the transaction hashes of the items.
+	SendLendingItems(ctx context.Context, in *SendLendingItemsRequest, opts ...grpc.CallOption) (*SendLendingItemsReply, error)
+	// GetOrderBook returns the aggregated volumes of a lending book at the current block.
+	GetOrderBook(ctx context.Context, in *OrderBookRequest, opts ...grpc.CallOption) (*OrderBook, error)
+	// GetLendingTrades returns a page of the lending trades of a user, the most recent first.
+	GetLendingTrades(ctx context.Context, in *LendingTradesRequest, opts ...grpc.CallOption) (*LendingTrades, error)
+	// SubscribeOrderBook streams the lending book at every new head block changing it.
+	SubscribeOrderBook(ctx context.Context, in *OrderBookRequest, opts ...grpc.CallOption) (Lending_SubscribeOrderBookClient, error)
+	// SubscribeLendingTrades streams the lending trades recorded by the SDK node.
+	SubscribeLendingTrades(ctx context.Context, in *LendingFilter, opts ...grpc.CallOption) (Lending_SubscribeLendingTradesClient, error)
+	// SubscribeLendingItems streams the updates of the lending items recorded by the SDK node.
+	SubscribeLendingItems(ctx context.Context, in *LendingFilter, opts ...grpc.CallOption) (Lending_SubscribeLendingItemsClient, error)
+}
+
+type lendingClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewLendingClient(cc *grpc.ClientConn) LendingClient {
+	return &lendingClient{cc}
+}
+
+func (c *lendingClient) SendLendingItems(ctx context.Context, in *SendLendingItemsRequest, opts ...grpc.CallOption) (*SendLendingItemsReply, error) {
+	out := new(SendLendingItemsReply)
+	err := c.cc.Invoke(ctx, "/lendingpb.Lending/SendLendingItems", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lendingClient) GetOrderBook(ctx context.Context, in *OrderBookRequest, opts ...grpc.CallOption) (*OrderBook, error) {
+	out := new(OrderBook)
+	err := c.cc.Invoke(ctx, "/lendingpb.Lending/GetOrderBook", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lendingClient) GetLendingTrades(ctx context.Context, in *LendingTradesRequest, opts ...grpc.CallOption) (*LendingTrades, error) {
+	out := new(LendingTrades)
+	err := c.cc.Invoke(ctx, "/lendingpb.Lending/GetLendingTrades", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lendingClient) SubscribeOrderBook(ctx context.Context, in *OrderBookRequest, opts ...grpc.CallOption) (Lending_SubscribeOrderBookClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Lending_serviceDesc.Streams[0], "/lendingpb.Lending/SubscribeOrderBook", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lendingSubscribeOrderBookClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Lending_SubscribeOrderBookClient interface {
+	Recv() (*OrderBook, error)
+	grpc.ClientStream
+}
+
+type lendingSubscribeOrderBookClient struct {
+	grpc.ClientStream
+}
+
+func (x *lendingSubscribeOrderBookClient) Recv() (*OrderBook, error) {
+	m := new(OrderBook)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *lendingClient) SubscribeLendingTrades(ctx context.Context, in *LendingFilter, opts ...grpc.CallOption) (Lending_SubscribeLendingTradesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Lending_serviceDesc.Streams[1], "/lendingpb.Lending/SubscribeLendingTrades", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lendingSubscribeLendingTradesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Lending_SubscribeLendingTradesClient interface {
+	Recv() (*LendingTrade, error)
+	grpc.ClientStream
+}
+
+type lendingSubscribeLendingTradesClient struct {
+	grpc.ClientStream
+}
+
+func (x *lendingSubscribeLendingTradesClient) Recv() (*LendingTrade, error) {
+	m := new(LendingTrade)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *lendingClient) SubscribeLendingItems(ctx context.Context, in *LendingFilter, opts ...grpc.CallOption) (Lending_SubscribeLendingItemsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Lending_serviceDesc.Streams[2], "/lendingpb.Lending/SubscribeLendingItems", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lendingSubscribeLendingItemsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Lending_SubscribeLendingItemsClient interface {
+	Recv() (*LendingItem, error)
+	grpc.ClientStream
+}
+
+type lendingSubscribeLendingItemsClient struct {
+	grpc.ClientStream
+}
+
+func (x *lendingSubscribeLendingItemsClient) Recv() (*LendingItem, error) {
+	m := new(LendingItem)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LendingServer is the server API for Lending service.
+type LendingServer interface {
+	// SendLendingItems adds a batch of signed lending items to the lending pool, all of them or
+	// none, and returns the transaction hashes of the items.
+	SendLendingItems(context.Context, *SendLendingItemsRequest) (*SendLendingItemsReply, error)
+	// GetOrderBook returns the aggregated volumes of a lending book at the current block.
+	GetOrderBook(context.Context, *OrderBookRequest) (*OrderBook, error)
+	// GetLendingTrades returns a page of the lending trades of a user, the most recent first.
+	GetLendingTrades(context.Context, *LendingTradesRequest) (*LendingTrades, error)
+	// SubscribeOrderBook streams the lending book at every new head block changing it.
+	SubscribeOrderBook(*OrderBookRequest, Lending_SubscribeOrderBookServer) error
+	// SubscribeLendingTrades streams the lending trades recorded by the SDK node.
+	SubscribeLendingTrades(*LendingFilter, Lending_SubscribeLendingTradesServer) error
+	// SubscribeLendingItems streams the updates of the lending items recorded by the SDK node.
+	SubscribeLendingItems(*LendingFilter, Lending_SubscribeLendingItemsServer) error
+}
+
+// UnimplementedLendingServer can be embedded to have forward compatible implementations.
+type UnimplementedLendingServer struct {
+}
+
+func (*UnimplementedLendingServer) SendLendingItems(ctx context.Context, req *SendLendingItemsRequest) (*SendLendingItemsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendLendingItems not implemented")
+}
+func (*UnimplementedLendingServer) GetOrderBook(ctx context.Context, req *OrderBookRequest) (*OrderBook, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrderBook not implemented")
+}
+func (*UnimplementedLendingServer) GetLendingTrades(ctx context.Context, req *LendingTradesRequest) (*LendingTrades, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLendingTrades not implemented")
+}
+func (*UnimplementedLendingServer) SubscribeOrderBook(req *OrderBookRequest, srv Lending_SubscribeOrderBookServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeOrderBook not implemented")
+}
+func (*UnimplementedLendingServer) SubscribeLendingTrades(req *LendingFilter, srv Lending_SubscribeLendingTradesServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeLendingTrades not implemented")
+}
+func (*UnimplementedLendingServer) SubscribeLendingItems(req *LendingFilter, srv Lending_SubscribeLendingItemsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeLendingItems not implemented")
+}
+
+func RegisterLendingServer(s *grpc.Server, srv LendingServer) {
+	s.RegisterService(&_Lending_serviceDesc, srv)
+}
+
+func _Lending_SendLendingItems_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendLendingItemsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LendingServer).SendLendingItems(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lendingpb.Lending/SendLendingItems",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LendingServer).SendLendingItems(ctx, req.(*SendLendingItemsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lending_GetOrderBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LendingServer).GetOrderBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lendingpb.Lending/GetOrderBook",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LendingServer).GetOrderBook(ctx, req.(*OrderBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lending_GetLendingTrades_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LendingTradesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LendingServer).GetLendingTrades(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lendingpb.Lending/GetLendingTrades",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LendingServer).GetLendingTrades(ctx, req.(*LendingTradesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lending_SubscribeOrderBook_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(OrderBookRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LendingServer).SubscribeOrderBook(m, &lendingSubscribeOrderBookServer{stream})
+}
+
+type Lending_SubscribeOrderBookServer interface {
+	Send(*OrderBook) error
+	grpc.ServerStream
+}
+
+type lendingSubscribeOrderBookServer struct {
+	grpc.ServerStream
+}
+
+func (x *lendingSubscribeOrderBookServer) Send(m *OrderBook) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Lending_SubscribeLendingTrades_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LendingFilter)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LendingServer).SubscribeLendingTrades(m, &lendingSubscribeLendingTradesServer{stream})
+}
+
+type Lending_SubscribeLendingTradesServer interface {
+	Send(*LendingTrade) error
+	grpc.ServerStream
+}
+
+type lendingSubscribeLendingTradesServer struct {
+	grpc.ServerStream
+}
+
+func (x *lendingSubscribeLendingTradesServer) Send(m *LendingTrade) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Lending_SubscribeLendingItems_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LendingFilter)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LendingServer).SubscribeLendingItems(m, &lendingSubscribeLendingItemsServer{stream})
+}
+
+type Lending_SubscribeLendingItemsServer interface {
+	Send(*LendingItem) error
+	grpc.ServerStream
+}
+
+type lendingSubscribeLendingItemsServer struct {
+	grpc.ServerStream
+}
+
+func (x *lendingSubscribeLendingItemsServer) Send(m *LendingItem) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Lending_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "lendingpb.Lending",
+	HandlerType: (*LendingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendLendingItems",
+			Handler:    _Lending_SendLendingItems_Handler,
+		},
+		{
+			MethodName: "GetOrderBook",
+			Handler:    _Lending_GetOrderBook_Handler,
+		},
+		{
+			MethodName: "GetLendingTrades",
+			Handler:    _Lending_GetLendingTrades_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeOrderBook",
+			Handler:       _Lending_SubscribeOrderBook_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeLendingTrades",
+			Handler:       _Lending_SubscribeLendingTrades_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeLendingItems",
+			Handler:       _Lending_SubscribeLendingItems_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "lending.proto",
+}
@@ -0,0 +1,38 @@
+package lendingstate
+
+import (
+	"github.com/tomochain/tomochain/common"
+)
+
+// RecordAccesses starts recording the lending books and other state objects accessed through the
+// state, dropping the accesses recorded so far. Objects are recorded whether they are read or
+// written.
+func (self *LendingStateDB) RecordAccesses() {
+	self.accessed = make(map[common.Hash]struct{})
+}
+
+// Accessed returns the state objects accessed since RecordAccesses, nil if they aren't recorded.
+func (self *LendingStateDB) Accessed() map[common.Hash]struct{} {
+	return self.accessed
+}
+
+func (self *LendingStateDB) recordAccess(addr common.Hash) {
+	if self.accessed != nil {
+		self.accessed[addr] = struct{}{}
+	}
+}
+
+// ApplyAccessed copies into the state the objects accessed through a copy of it since the copy
+// started recording its accesses.
+func (self *LendingStateDB) ApplyAccessed(src *LendingStateDB) {
+	for addr := range src.accessed {
+		obj := src.lendingExchangeStates[addr]
+		if obj == nil {
+			continue
+		}
+		self.lendingExchangeStates[addr] = obj.deepCopy(self, self.MarkLendingExchangeObjectDirty)
+		if _, dirty := src.lendingExchangeStatesDirty[addr]; dirty {
+			self.lendingExchangeStatesDirty[addr] = struct{}{}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package lendingstate
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// AccountRisk is the risk of an account across the spot books of the TradingStateDB and the lending
+// books of the LendingStateDB, per token. The collateral locked in lending trades above what keeps
+// their health factor at the one of a new trade counts toward the margin of the account, as its free
+// balance does, while the balance committed to open spot orders doesn't.
+type AccountRisk struct {
+	User   common.Address
+	tokens map[common.Address]*TokenRisk
+}
+
+// TokenRisk is the risk of an account in a token.
+type TokenRisk struct {
+	Token            common.Address `json:"token"`
+	Balance          *big.Int       `json:"balance"`          // free balance
+	SpotCommitted    *big.Int       `json:"spotCommitted"`    // committed to open spot orders
+	CollateralLocked *big.Int       `json:"collateralLocked"` // locked as collateral of lending trades
+	ExcessCollateral *big.Int       `json:"excessCollateral"` // recallable part of the locked collateral
+	Debt             *big.Int       `json:"debt"`             // borrowed by lending trades
+	Lent             *big.Int       `json:"lent"`             // invested in lending trades
+	Margin           *big.Int       `json:"margin"`           // see AccountRisk.Margin
+}
+
+// NewAccountRisk returns an empty account risk.
+func NewAccountRisk(user common.Address) *AccountRisk {
+	return &AccountRisk{User: user, tokens: make(map[common.Address]*TokenRisk)}
+}
+
+// Token returns the risk of the account in a token, created empty.
+func (r *AccountRisk) Token(token common.Address) *TokenRisk {
+	if risk, ok := r.tokens[token]; ok {
+		return risk
+	}
+	risk := &TokenRisk{
+		Token:            token,
+		Balance:          new(big.Int),
+		SpotCommitted:    new(big.Int),
+		CollateralLocked: new(big.Int),
+		ExcessCollateral: new(big.Int),
+		Debt:             new(big.Int),
+		Lent:             new(big.Int),
+	}
+	r.tokens[token] = risk
+	return risk
+}
+
+// spotOrderCommitment returns the token and the amount committed by the remaining quantity of an
+// open spot order: the base token of a sell order, the quote token of a buy order at its price.
+// The amount is nil if nothing is committed.
+func spotOrderCommitment(order *tradingstate.OrderItem, remaining *big.Int, baseTokenDecimal *big.Int) (common.Address, *big.Int) {
+	if remaining == nil || remaining.Sign() <= 0 {
+		return common.Address{}, nil
+	}
+	if order.Side == tradingstate.Ask {
+		return order.BaseToken, remaining
+	}
+	if baseTokenDecimal == nil || baseTokenDecimal.Sign() <= 0 {
+		return common.Address{}, nil
+	}
+	quoteQuantity := new(big.Int).Mul(remaining, order.Price)
+	return order.QuoteToken, quoteQuantity.Div(quoteQuantity, baseTokenDecimal)
+}
+
+// AddSpotOrder commits the remaining quantity of an open spot order of the account, see
+// spotOrderCommitment.
+func (r *AccountRisk) AddSpotOrder(order *tradingstate.OrderItem, remaining *big.Int, baseTokenDecimal *big.Int) {
+	if order.UserAddress != r.User {
+		return
+	}
+	token, amount := spotOrderCommitment(order, remaining, baseTokenDecimal)
+	if amount == nil {
+		return
+	}
+	risk := r.Token(token)
+	risk.SpotCommitted = new(big.Int).Add(risk.SpotCommitted, amount)
+}
+
+// AddLendingTrade adds an open lending trade of the account, as borrower or investor. The locked
+// collateral in excess is the amount CalculateMaxRecallAmount allows to recall at the collateral
+// price, none if the price is unknown or the trade can't be recalled from.
+func (r *AccountRisk) AddLendingTrade(trade *LendingTrade, collateralPrice, depositRate, liquidationRate *big.Int, recallable bool) {
+	if trade.Amount == nil || trade.Amount.Sign() <= 0 {
+		return
+	}
+	if trade.Borrower == r.User {
+		debt := r.Token(trade.LendingToken)
+		debt.Debt = new(big.Int).Add(debt.Debt, trade.Amount)
+		if trade.CollateralLockedAmount != nil {
+			collateral := r.Token(trade.CollateralToken)
+			collateral.CollateralLocked = new(big.Int).Add(collateral.CollateralLocked, trade.CollateralLockedAmount)
+			if recallable && trade.LiquidationPrice != nil {
+				excess := CalculateMaxRecallAmount(trade.CollateralLockedAmount, trade.LiquidationPrice, collateralPrice, depositRate, liquidationRate)
+				collateral.ExcessCollateral = new(big.Int).Add(collateral.ExcessCollateral, excess)
+			}
+		}
+	}
+	if trade.Investor == r.User {
+		lent := r.Token(trade.LendingToken)
+		lent.Lent = new(big.Int).Add(lent.Lent, trade.Amount)
+	}
+}
+
+// Margin returns the amount of a token the account can commit to new spot orders or lock as the
+// collateral of new lending trades: its free balance and excess collateral, less the balance
+// committed to open spot orders. It is negative when the open spot orders can't all be filled.
+func (r *AccountRisk) Margin(token common.Address) *big.Int {
+	risk, ok := r.tokens[token]
+	if !ok {
+		return new(big.Int)
+	}
+	margin := new(big.Int).Add(risk.Balance, risk.ExcessCollateral)
+	return margin.Sub(margin, risk.SpotCommitted)
+}
+
+// Tokens returns the risk of the account in every token, with its margin, sorted by token.
+func (r *AccountRisk) Tokens() []*TokenRisk {
+	tokens := make([]*TokenRisk, 0, len(r.tokens))
+	for token, risk := range r.tokens {
+		risk.Margin = r.Margin(token)
+		tokens = append(tokens, risk)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return bytes.Compare(tokens[i].Token.Bytes(), tokens[j].Token.Bytes()) < 0
+	})
+	return tokens
+}
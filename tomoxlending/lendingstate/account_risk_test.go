@@ -0,0 +1,52 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestAccountRisk(t *testing.T) {
+	var (
+		user      = common.HexToAddress("0x41")
+		other     = common.HexToAddress("0x42")
+		tomo      = common.HexToAddress(common.TomoNativeAddress)
+		usdt      = common.HexToAddress("0x10")
+		decimal   = big.NewInt(1000)
+		risk      = NewAccountRisk(user)
+		sellOrder = &tradingstate.OrderItem{UserAddress: user, Side: tradingstate.Ask, BaseToken: tomo, QuoteToken: usdt, Price: big.NewInt(2000)}
+		buyOrder  = &tradingstate.OrderItem{UserAddress: user, Side: tradingstate.Bid, BaseToken: tomo, QuoteToken: usdt, Price: big.NewInt(2000)}
+	)
+	risk.AddSpotOrder(sellOrder, big.NewInt(200), decimal)
+	risk.AddSpotOrder(buyOrder, big.NewInt(100), decimal)
+	// the orders of other accounts aren't committed
+	risk.AddSpotOrder(&tradingstate.OrderItem{UserAddress: other, Side: tradingstate.Ask, BaseToken: tomo}, big.NewInt(100), decimal)
+
+	// half of the collateral of the borrowed trade is in excess at 300, where it is worth twice its debt at the deposit rate,
+	// none of the collateral of a multi-collateral trade is
+	borrowed := &LendingTrade{Borrower: user, Investor: other, LendingToken: usdt, CollateralToken: tomo, Amount: big.NewInt(100), CollateralLockedAmount: big.NewInt(1000), LiquidationPrice: big.NewInt(110)}
+	risk.AddLendingTrade(borrowed, big.NewInt(300), big.NewInt(150), big.NewInt(110), true)
+	risk.AddLendingTrade(borrowed, big.NewInt(300), big.NewInt(150), big.NewInt(110), false)
+	lent := &LendingTrade{Borrower: other, Investor: user, LendingToken: usdt, CollateralToken: tomo, Amount: big.NewInt(70), CollateralLockedAmount: big.NewInt(500), LiquidationPrice: big.NewInt(110)}
+	risk.AddLendingTrade(lent, big.NewInt(300), big.NewInt(150), big.NewInt(110), true)
+
+	risk.Token(tomo).Balance = big.NewInt(100)
+	risk.Token(usdt).Balance = big.NewInt(300)
+	tokens := risk.Tokens()
+	if len(tokens) != 2 || tokens[0].Token != tomo || tokens[1].Token != usdt {
+		t.Fatalf("wrong tokens: %v", ToJSON(tokens))
+	}
+	if risk := tokens[0]; risk.SpotCommitted.Int64() != 200 || risk.CollateralLocked.Int64() != 2000 || risk.ExcessCollateral.Int64() != 500 || risk.Margin.Int64() != 400 {
+		t.Errorf("wrong collateral token risk: %v", ToJSON(risk))
+	}
+	if risk := tokens[1]; risk.SpotCommitted.Int64() != 200 || risk.Debt.Int64() != 200 || risk.Lent.Int64() != 70 || risk.Margin.Int64() != 100 {
+		t.Errorf("wrong lending token risk: %v", ToJSON(risk))
+	}
+	// committing more than the free balance and excess collateral leaves a negative margin
+	risk.AddSpotOrder(buyOrder, big.NewInt(200), decimal)
+	if margin := risk.Margin(usdt); margin.Int64() != -300 {
+		t.Errorf("wrong margin: have %v, want -300", margin)
+	}
+}
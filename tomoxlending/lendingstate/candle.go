@@ -0,0 +1,144 @@
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+// CandleIntervals are the periods of the interest rate candles recorded by SDK nodes, from the
+// shortest to the longest: each one is a multiple of the previous one.
+var CandleIntervals = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+// CandleInterval returns the duration of a candle interval, 0 if it is unknown.
+func CandleInterval(name string) time.Duration {
+	for _, interval := range CandleIntervals {
+		if interval.Name == name {
+			return interval.Duration
+		}
+	}
+	return 0
+}
+
+// LendingCandle summarizes the interests of the lending trades of a lending book matched during
+// an interval starting at OpenTime.
+type LendingCandle struct {
+	LendingToken common.Address `bson:"lendingToken" json:"lendingToken"`
+	Term         uint64         `bson:"term" json:"term"`
+	Interval     string         `bson:"interval" json:"interval"`
+	OpenTime     time.Time      `bson:"openTime" json:"openTime"`
+	Open         *big.Int       `bson:"open" json:"open"`   // interest of the first trade
+	High         *big.Int       `bson:"high" json:"high"`   // highest interest
+	Low          *big.Int       `bson:"low" json:"low"`     // lowest interest
+	Close        *big.Int       `bson:"close" json:"close"` // interest of the last trade
+	Volume       *big.Int       `bson:"volume" json:"volume"`
+	Count        uint64         `bson:"count" json:"count"`
+	Hash         common.Hash    `bson:"hash" json:"hash"`
+	UpdatedAt    time.Time      `bson:"updatedAt" json:"updatedAt"`
+}
+
+type LendingCandleBSON struct {
+	LendingToken string    `bson:"lendingToken" json:"lendingToken"`
+	Term         string    `bson:"term" json:"term"`
+	Interval     string    `bson:"interval" json:"interval"`
+	OpenTime     time.Time `bson:"openTime" json:"openTime"`
+	Open         string    `bson:"open" json:"open"`
+	High         string    `bson:"high" json:"high"`
+	Low          string    `bson:"low" json:"low"`
+	Close        string    `bson:"close" json:"close"`
+	Volume       string    `bson:"volume" json:"volume"`
+	Count        string    `bson:"count" json:"count"`
+	Hash         string    `bson:"hash" json:"hash"` // Keccak256Hash of the lending book, interval and open time
+	UpdatedAt    time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (c *LendingCandle) GetBSON() (interface{}, error) {
+	return LendingCandleBSON{
+		LendingToken: c.LendingToken.Hex(),
+		Term:         strconv.FormatUint(c.Term, 10),
+		Interval:     c.Interval,
+		OpenTime:     c.OpenTime,
+		Open:         c.Open.String(),
+		High:         c.High.String(),
+		Low:          c.Low.String(),
+		Close:        c.Close.String(),
+		Volume:       c.Volume.String(),
+		Count:        strconv.FormatUint(c.Count, 10),
+		Hash:         c.Hash.Hex(),
+		UpdatedAt:    c.UpdatedAt,
+	}, nil
+}
+
+func (c *LendingCandle) SetBSON(raw bson.Raw) error {
+	decoded := new(LendingCandleBSON)
+	if err := raw.Unmarshal(decoded); err != nil {
+		return fmt.Errorf("failed to decode LendingCandle. Err: %v", err)
+	}
+	term, err := strconv.ParseUint(decoded.Term, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse LendingCandle.Term. Err: %v", err)
+	}
+	count, err := strconv.ParseUint(decoded.Count, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse LendingCandle.Count. Err: %v", err)
+	}
+	c.LendingToken = common.HexToAddress(decoded.LendingToken)
+	c.Term = term
+	c.Interval = decoded.Interval
+	c.OpenTime = decoded.OpenTime
+	c.Open = ToBigInt(decoded.Open)
+	c.High = ToBigInt(decoded.High)
+	c.Low = ToBigInt(decoded.Low)
+	c.Close = ToBigInt(decoded.Close)
+	c.Volume = ToBigInt(decoded.Volume)
+	c.Count = count
+	c.Hash = common.HexToHash(decoded.Hash)
+	c.UpdatedAt = decoded.UpdatedAt
+	return nil
+}
+
+func (c *LendingCandle) ComputeHash() common.Hash {
+	return crypto.Keccak256Hash(c.LendingToken.Bytes(), new(big.Int).SetUint64(c.Term).Bytes(), []byte(c.Interval), new(big.Int).SetInt64(c.OpenTime.Unix()).Bytes())
+}
+
+// AddTrade adds a lending trade to the candle. The trades must be added in matching order.
+func (c *LendingCandle) AddTrade(trade *LendingTrade) {
+	interest := new(big.Int).SetUint64(trade.Interest)
+	c.add(interest, interest, interest, interest, trade.Amount, 1)
+}
+
+// AddCandle merges a candle of a shorter interval into the candle. The candles must be added in
+// time order.
+func (c *LendingCandle) AddCandle(candle *LendingCandle) {
+	c.add(candle.Open, candle.High, candle.Low, candle.Close, candle.Volume, candle.Count)
+}
+
+func (c *LendingCandle) add(open, high, low, close, volume *big.Int, count uint64) {
+	if c.Count == 0 {
+		c.Open, c.High, c.Low, c.Volume = CloneBigInt(open), CloneBigInt(high), CloneBigInt(low), new(big.Int)
+	}
+	if high.Cmp(c.High) > 0 {
+		c.High = CloneBigInt(high)
+	}
+	if low.Cmp(c.Low) < 0 {
+		c.Low = CloneBigInt(low)
+	}
+	c.Close = CloneBigInt(close)
+	if volume != nil {
+		c.Volume = new(big.Int).Add(c.Volume, volume)
+	}
+	c.Count += count
+}
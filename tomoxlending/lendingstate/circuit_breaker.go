@@ -0,0 +1,122 @@
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// The circuit breaker of a lending book is the book returned by GetLendingCircuitBreakerHash, each
+// of its items holds one value in its quantity. The threshold and the pause are set by the owner of
+// a relayer listing the lending book, the other values are updated by the matching engine.
+//
+// The medium interest of a block is the average interest of the trades matched in the lending book
+// during the block, weighted by their amount. The breaker trips when the medium interest of the
+// current block moves away from the one of the previous block with trades by more than the
+// threshold, then matching is paused until the pause is over.
+const (
+	circuitBreakerThresholdId      = uint64(1) // move tripping the breaker, in basis points; zero disables it
+	circuitBreakerPauseId          = uint64(2) // blocks during which matching is paused once tripped
+	circuitBreakerReferenceId      = uint64(3) // medium interest of the previous block with trades
+	circuitBreakerBlockId          = uint64(4) // block of the trades being accumulated
+	circuitBreakerVolumeId         = uint64(5) // amount of the trades of the block
+	circuitBreakerInterestVolumeId = uint64(6) // sum of interest times amount of the trades of the block
+	circuitBreakerPausedUntilId    = uint64(7) // last block during which matching is paused
+)
+
+// circuitBreakerBasis is the basis of the circuit breaker threshold: 10000 basis points are 100%.
+var circuitBreakerBasis = big.NewInt(10000)
+
+// LendingCircuitBreaker is the circuit breaker of a lending book.
+type LendingCircuitBreaker struct {
+	Threshold   uint64 `json:"threshold"`   // in basis points, zero if disabled
+	PauseBlocks uint64 `json:"pauseBlocks"` // length of a pause
+	Reference   uint64 `json:"reference"`   // medium interest of the previous block with trades
+	PausedUntil uint64 `json:"pausedUntil"` // last paused block
+}
+
+// getCircuitBreakerValue returns a value of the circuit breaker of a lending book.
+func (self *LendingStateDB) getCircuitBreakerValue(lendingBook common.Hash, id uint64) *big.Int {
+	breakerBook := GetLendingCircuitBreakerHash(lendingBook)
+	if !self.Exist(breakerBook) {
+		return new(big.Int)
+	}
+	stateItem := self.getLendingExchange(breakerBook).getLendingItem(self.db, common.BigToHash(new(big.Int).SetUint64(id)))
+	if stateItem == nil || stateItem.empty() {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(stateItem.Quantity())
+}
+
+// setCircuitBreakerValue sets a value of the circuit breaker of a lending book.
+func (self *LendingStateDB) setCircuitBreakerValue(lendingBook common.Hash, id uint64, value *big.Int) {
+	if value.Sign() == 0 && self.getCircuitBreakerValue(lendingBook, id).Sign() == 0 {
+		return
+	}
+	self.setItemVolume(GetLendingCircuitBreakerHash(lendingBook), LendingItem{LendingId: id, Type: CircuitBreaker}, value)
+}
+
+// GetCircuitBreaker returns the circuit breaker of a lending book.
+func (self *LendingStateDB) GetCircuitBreaker(lendingBook common.Hash) LendingCircuitBreaker {
+	return LendingCircuitBreaker{
+		Threshold:   self.getCircuitBreakerValue(lendingBook, circuitBreakerThresholdId).Uint64(),
+		PauseBlocks: self.getCircuitBreakerValue(lendingBook, circuitBreakerPauseId).Uint64(),
+		Reference:   self.getCircuitBreakerValue(lendingBook, circuitBreakerReferenceId).Uint64(),
+		PausedUntil: self.getCircuitBreakerValue(lendingBook, circuitBreakerPausedUntilId).Uint64(),
+	}
+}
+
+// SetCircuitBreaker sets the threshold in basis points and the pause in blocks of the circuit
+// breaker of a lending book, a zero threshold disables it. The medium interests recorded so far
+// are dropped, the current pause isn't lifted.
+func (self *LendingStateDB) SetCircuitBreaker(lendingBook common.Hash, threshold *big.Int, pauseBlocks *big.Int) {
+	self.setCircuitBreakerValue(lendingBook, circuitBreakerThresholdId, threshold)
+	self.setCircuitBreakerValue(lendingBook, circuitBreakerPauseId, pauseBlocks)
+	for _, id := range []uint64{circuitBreakerReferenceId, circuitBreakerBlockId, circuitBreakerVolumeId, circuitBreakerInterestVolumeId} {
+		self.setCircuitBreakerValue(lendingBook, id, new(big.Int))
+	}
+}
+
+// IsLendingBookPaused returns whether matching is paused in a lending book at a block.
+func (self *LendingStateDB) IsLendingBookPaused(lendingBook common.Hash, blockNumber uint64) bool {
+	pausedUntil := self.getCircuitBreakerValue(lendingBook, circuitBreakerPausedUntilId)
+	return pausedUntil.Sign() > 0 && pausedUntil.Uint64() >= blockNumber
+}
+
+// RecordCircuitBreakerTrade adds a lending trade matched at a block to the medium interest of the
+// block, and trips the circuit breaker of the lending book if the medium interest moved beyond the
+// threshold. It returns whether the breaker tripped.
+func (self *LendingStateDB) RecordCircuitBreakerTrade(lendingBook common.Hash, blockNumber uint64, interest uint64, amount *big.Int) bool {
+	threshold := self.getCircuitBreakerValue(lendingBook, circuitBreakerThresholdId)
+	if threshold.Sign() == 0 || amount == nil || amount.Sign() <= 0 {
+		return false
+	}
+	volume := self.getCircuitBreakerValue(lendingBook, circuitBreakerVolumeId)
+	interestVolume := self.getCircuitBreakerValue(lendingBook, circuitBreakerInterestVolumeId)
+	if self.getCircuitBreakerValue(lendingBook, circuitBreakerBlockId).Uint64() != blockNumber {
+		// first trade of the block: the medium interest of the previous block becomes the reference
+		if volume.Sign() > 0 {
+			self.setCircuitBreakerValue(lendingBook, circuitBreakerReferenceId, new(big.Int).Div(interestVolume, volume))
+		}
+		self.setCircuitBreakerValue(lendingBook, circuitBreakerBlockId, new(big.Int).SetUint64(blockNumber))
+		volume, interestVolume = new(big.Int), new(big.Int)
+	}
+	volume.Add(volume, amount)
+	interestVolume.Add(interestVolume, new(big.Int).Mul(new(big.Int).SetUint64(interest), amount))
+	self.setCircuitBreakerValue(lendingBook, circuitBreakerVolumeId, volume)
+	self.setCircuitBreakerValue(lendingBook, circuitBreakerInterestVolumeId, interestVolume)
+
+	reference := self.getCircuitBreakerValue(lendingBook, circuitBreakerReferenceId)
+	if reference.Sign() == 0 {
+		return false
+	}
+	medium := new(big.Int).Div(interestVolume, volume)
+	move := new(big.Int).Abs(new(big.Int).Sub(medium, reference))
+	move.Mul(move, circuitBreakerBasis)
+	if move.Cmp(new(big.Int).Mul(threshold, reference)) <= 0 {
+		return false
+	}
+	pause := self.getCircuitBreakerValue(lendingBook, circuitBreakerPauseId)
+	self.setCircuitBreakerValue(lendingBook, circuitBreakerPausedUntilId, new(big.Int).Add(new(big.Int).SetUint64(blockNumber), pause))
+	return true
+}
@@ -42,15 +42,31 @@ type lendingObject struct {
 
 // liquidation reasons
 const (
-	LiquidatedByTime  = uint64(0)
-	LiquidatedByPrice = uint64(1)
+	LiquidatedByTime    = uint64(0)
+	LiquidatedByPrice   = uint64(1)
+	LiquidatedByAuction = uint64(2) // the liquidation auction expired, the unsold collateral is seized
 )
 
+// self-trade prevention policies, applied since TIPTomoXLendingV2 when the taker and a maker share a UserAddress
+const (
+	SelfTradeCancelNewest = "cancel-newest" // reject the taker
+	SelfTradeCancelOldest = "cancel-oldest" // reject the maker, the taker goes on matching
+	SelfTradeCancelBoth   = "cancel-both"   // reject both the taker and the maker
+)
+
+// SelfTradePrevention is the self-trade prevention policy of the lending matching engine.
+// It is part of the consensus rules, all the nodes of a network must use the same policy.
+var SelfTradePrevention = SelfTradeCancelNewest
+
 type LiquidationData struct {
 	RecallAmount      *big.Int
 	LiquidationAmount *big.Int
 	CollateralPrice   *big.Int
 	Reason            uint64
+	Shortfall         *big.Int       `json:",omitempty"` // debt left once an auction is settled
+	InsuranceDraw     *big.Int       `json:",omitempty"` // part of the shortfall paid by the insurance fund
+	Socialized        *big.Int       `json:",omitempty"` // part of the shortfall left charged to the other investors
+	Haircuts          []TradeHaircut `json:",omitempty"` // charges of the socialized shortfall
 }
 
 var (
@@ -84,6 +100,9 @@ type TxLendingBatch struct {
 	TxHash    common.Hash
 }
 
+// MatchingResult holds the outcome of processing one lending item: the trades it matched
+// (or the trade it topped up, repaid or recalled) and the items rejected on the way.
+// ProcessOrderPending keys these results by GetLendingCacheKey of the item.
 type MatchingResult struct {
 	Trades  []*LendingTrade
 	Rejects []*LendingItem
@@ -115,6 +134,7 @@ type LendingTradeHistoryItem struct {
 	TxHash                 common.Hash
 	CollateralLockedAmount *big.Int
 	LiquidationPrice       *big.Int
+	Amount                 *big.Int
 	Status                 string
 	UpdatedAt              time.Time
 }
@@ -182,6 +202,108 @@ func GetLendingOrderBookHash(lendingToken common.Address, term uint64) common.Ha
 	return crypto.Keccak256Hash(append(common.Uint64ToHash(term).Bytes(), lendingToken.Bytes()...))
 }
 
+// GetLendingTriggerBookHash returns the hash of the book holding the stop-limit items of a lending book
+// which have not been triggered yet, indexed by trigger interest.
+func GetLendingTriggerBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("trigger"))
+}
+
+// GetLendingIcebergBookHash returns the hash of the book holding the hidden quantity of the iceberg items
+// of a lending book, under the lending id of the item.
+func GetLendingIcebergBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("iceberg"))
+}
+
+// GetLendingExpiryBookHash returns the hash of the book indexing the lending ids of the good-till-time items
+// of a lending book by expiry time.
+func GetLendingExpiryBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("expiry"))
+}
+
+// GetLendingAuctionBookHash returns the hash of the book holding the liquidation auctions of a lending book.
+// An auction is stored as a lending trade with the id of the liquidated trade.
+func GetLendingAuctionBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("auction"))
+}
+
+// GetLendingTradeCollateralBookHash returns the hash of the book holding the extra collaterals
+// of a multi-collateral lending trade.
+func GetLendingTradeCollateralBookHash(lendingBook common.Hash, tradeId uint64) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), common.Uint64ToHash(tradeId).Bytes(), []byte("collateral"))
+}
+
+// GetLendingMultiCollateralBookHash returns the hash of the book indexing the multi-collateral
+// lending trades of a lending book.
+func GetLendingMultiCollateralBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("multiCollateral"))
+}
+
+// GetLendingRolloverBookHash returns the hash of the book holding the rollover requests of the
+// lending trades of a lending book.
+func GetLendingRolloverBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("rollover"))
+}
+
+// GetLendingCircuitBreakerHash returns the hash of the book holding the circuit breaker of a
+// lending book.
+func GetLendingCircuitBreakerHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("circuitBreaker"))
+}
+
+// GetLendingRateModelHash returns the hash of the book holding the interest rate model of a lending
+// book.
+func GetLendingRateModelHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("rateModel"))
+}
+
+// GetLendingReferralHash returns the hash of the book holding the registration of a referrer with
+// a relayer.
+func GetLendingReferralHash(relayer common.Address, referrer common.Address) common.Hash {
+	return crypto.Keccak256Hash(relayer.Bytes(), referrer.Bytes(), []byte("referral"))
+}
+
+// GetLendingReferralFeeHash returns the hash of the book holding the fees paid to a referrer in a
+// lending token.
+func GetLendingReferralFeeHash(referrer common.Address, lendingToken common.Address) common.Hash {
+	return crypto.Keccak256Hash(referrer.Bytes(), lendingToken.Bytes(), []byte("referralFee"))
+}
+
+// GetLendingMarginBookHash returns the hash of the book linking the lending trades of a lending
+// book opened by margin items to their spot orders.
+func GetLendingMarginBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("margin"))
+}
+
+// GetLendingTopUpReserveHash returns the hash of the book holding the top-up reserve of a user
+// for a collateral token.
+func GetLendingTopUpReserveHash(user common.Address, collateralToken common.Address) common.Hash {
+	return crypto.Keccak256Hash(user.Bytes(), collateralToken.Bytes(), []byte("topUpReserve"))
+}
+
+// GetLendingInsuranceFundHash returns the hash of the book holding the insurance fund of a lending
+// token.
+func GetLendingInsuranceFundHash(lendingToken common.Address) common.Hash {
+	return crypto.Keccak256Hash(lendingToken.Bytes(), []byte("insuranceFund"))
+}
+
+// GetLendingHaircutBookHash returns the hash of the book holding the haircuts charged to the
+// lending trades of a lending book.
+func GetLendingHaircutBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("haircut"))
+}
+
+// GetLendingBadDebtClaimBookHash returns the hash of the book holding the claims of the investors
+// of a lending book on its haircuts.
+func GetLendingBadDebtClaimBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("badDebtClaim"))
+}
+
+// GetLendingConsumedHashBookHash returns the hash of the book recording the creation of the lending
+// item with the given hash since TIPTomoXLendingReplayProtection.
+func GetLendingConsumedHashBookHash(hash common.Hash) common.Hash {
+	return crypto.Keccak256Hash(hash.Bytes(), []byte("consumedHash"))
+}
+
 func EncodeTxLendingBatch(batch TxLendingBatch) ([]byte, error) {
 	data, err := json.Marshal(batch)
 	if err != nil || data == nil {
@@ -249,3 +371,32 @@ func DecodeFinalizedResult(data []byte) (result FinalizedResult, err error) {
 func GetLendingCacheKey(item *LendingItem) common.Hash {
 	return crypto.Keccak256Hash(item.UserAddress.Bytes(), item.Nonce.Bytes())
 }
+
+// GetLendingInterestModeHash returns the hash of the book holding the interest mode of a lending
+// book.
+func GetLendingInterestModeHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("interestMode"))
+}
+
+// GetLendingFundingBookHash returns the hash of the book holding the interest already settled by
+// the open lending trades of a lending book whose interest is settled every epoch.
+func GetLendingFundingBookHash(lendingBook common.Hash) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), []byte("funding"))
+}
+
+// GetPriceOracleHash returns the hash of the book holding the price oracle of a collateral token.
+func GetPriceOracleHash(collateralToken common.Address) common.Hash {
+	return crypto.Keccak256Hash(collateralToken.Bytes(), []byte("priceOracle"))
+}
+
+// GetPriceReportersHash returns the hash of the book holding the reporters or the signers of the
+// price oracle of a collateral token.
+func GetPriceReportersHash(collateralToken common.Address) common.Hash {
+	return crypto.Keccak256Hash(collateralToken.Bytes(), []byte("priceReporters"))
+}
+
+// GetPriceReportHash returns the hash of the book holding the last price of a collateral token in a
+// lending token reported by a reporter.
+func GetPriceReportHash(collateralToken common.Address, lendingToken common.Address, reporter common.Address) common.Hash {
+	return crypto.Keccak256Hash(collateralToken.Bytes(), lendingToken.Bytes(), reporter.Bytes(), []byte("priceReport"))
+}
@@ -0,0 +1,119 @@
+package lendingstate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// List of SDK node sync errors
+var (
+	ErrSDKGetObject  = errors.New("failed to read lending data from SDK database")
+	ErrSDKPutObject  = errors.New("failed to write lending data to SDK database")
+	ErrSDKCommitBulk = errors.New("failed to commit lending data to SDK database")
+)
+
+// SDKSyncError is returned when lending data can't be recorded to the SDK database.
+// If Queued is set, the transaction has been queued and will be replayed by the next sync.
+type SDKSyncError struct {
+	Kind   error       // one of ErrSDKGetObject, ErrSDKPutObject, ErrSDKCommitBulk
+	TxHash common.Hash // transaction being recorded
+	Hash   common.Hash // lending item or trade hash, if any
+	Err    error       // underlying database error
+	Queued bool
+}
+
+func (e *SDKSyncError) Error() string {
+	if e.Hash != (common.Hash{}) {
+		return fmt.Sprintf("%v. TxHash: %s Hash: %s Error: %v", e.Kind, e.TxHash.Hex(), e.Hash.Hex(), e.Err)
+	}
+	return fmt.Sprintf("%v. TxHash: %s Error: %v", e.Kind, e.TxHash.Hex(), e.Err)
+}
+
+// Unwrap returns the underlying database error.
+func (e *SDKSyncError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of this error.
+func (e *SDKSyncError) Is(target error) bool {
+	return e.Kind == target
+}
+
+// IsQueuedSDKSyncError returns whether err is an SDK sync failure that will be replayed later.
+func IsQueuedSDKSyncError(err error) bool {
+	var syncErr *SDKSyncError
+	return errors.As(err, &syncErr) && syncErr.Queued
+}
+
+// Reasons recorded in the RejectReason of rejected lending items
+const (
+	RejectReasonUnknown                = "UNKNOWN"
+	RejectReasonInvalidStatus          = "INVALID_STATUS"
+	RejectReasonInvalidType            = "INVALID_TYPE" // unknown type, or type not enabled yet
+	RejectReasonInvalidPair            = "INVALID_PAIR"
+	RejectReasonInvalidRelayer         = "INVALID_RELAYER"
+	RejectReasonInvalidSignature       = "INVALID_SIGNATURE"
+	RejectReasonInvalidSide            = "INVALID_SIDE"
+	RejectReasonInvalidCollateral      = "INVALID_COLLATERAL"
+	RejectReasonInvalidQuantity        = "INVALID_QUANTITY"
+	RejectReasonInvalidInterest        = "INVALID_INTEREST" // interest or trigger interest out of range
+	RejectReasonInvalidTrade           = "INVALID_TRADE"    // lending trade not found or not owned by the user
+	RejectReasonInvalidPrice           = "INVALID_PRICE"    // collateral price unavailable
+	RejectReasonInsufficientBalance    = "INSUFFICIENT_BALANCE"
+	RejectReasonInsufficientRelayerFee = "INSUFFICIENT_RELAYER_FEE"
+	RejectReasonQuantityTooSmall       = "QUANTITY_TOO_SMALL"
+	RejectReasonLowHealthFactor        = "LOW_HEALTH_FACTOR"
+	RejectReasonSelfTrade              = "SELF_TRADE"
+	RejectReasonCancelFailed           = "CANCEL_FAILED"
+	RejectReasonAmendFailed            = "AMEND_FAILED" // amended item not found, not owned by the user or not a limit item
+	RejectReasonInvalidTimeInForce     = "INVALID_TIME_IN_FORCE"
+	RejectReasonTimeInForce            = "TIME_IN_FORCE" // unmatched part of an immediate-or-cancel or fill-or-kill item
+	RejectReasonPostOnly               = "POST_ONLY"     // post-only item crossing the spread
+	RejectReasonExpired                = "EXPIRED"       // good-till-time item expired
+	RejectReasonInvalidRateModel       = "INVALID_RATE_MODEL"
+	RejectReasonInvalidReferrer        = "INVALID_REFERRER"     // referrer not registered with the relayer
+	RejectReasonInvalidHash            = "INVALID_HASH"         // hash not computed from the signed terms of the item
+	RejectReasonReplayed               = "REPLAYED"             // hash of an item created in an earlier transaction
+	RejectReasonInvalidMargin          = "INVALID_MARGIN"       // spot order or leverage of a margin item invalid
+	RejectReasonMarginNotFilled        = "MARGIN_NOT_FILLED"    // borrow of a margin item not fully matched, or its spot order rejected
+	RejectReasonInvalidPriceOracle     = "INVALID_PRICE_ORACLE" // price oracle invalid or not set by the moderator
+	RejectReasonInvalidPriceReport     = "INVALID_PRICE_REPORT" // reporter not registered with the price oracle, or signed report stale
+)
+
+// RejectError is an error rejecting a lending item, with the reason recorded in its RejectReason.
+type RejectError struct {
+	Reason string
+	Err    error
+}
+
+func (e *RejectError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
+
+// rejectReasons maps the errors shared by the lending engine and the lending pool to their reject reason.
+var rejectReasons = map[error]string{
+	ErrQuantityTradeTooSmall:  RejectReasonQuantityTooSmall,
+	ErrInvalidCollateralPrice: RejectReasonInvalidPrice,
+	ErrLowHealthFactor:        RejectReasonLowHealthFactor,
+}
+
+// GetRejectReason returns the reason to reject a lending item because of err, or fallback if err has none.
+func GetRejectReason(err error, fallback string) string {
+	var rejectErr *RejectError
+	if errors.As(err, &rejectErr) {
+		return rejectErr.Reason
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if reason, ok := rejectReasons[e]; ok {
+			return reason
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,50 @@
+package lendingstate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+func TestSDKSyncError(t *testing.T) {
+	dbErr := errors.New("connection refused")
+	var err error = &SDKSyncError{Kind: ErrSDKCommitBulk, TxHash: common.HexToHash("0x1"), Err: dbErr}
+	if !errors.Is(err, ErrSDKCommitBulk) || errors.Is(err, ErrSDKPutObject) {
+		t.Fatalf("unexpected error kind: %v", err)
+	}
+	if !errors.Is(err, dbErr) {
+		t.Fatalf("underlying error not unwrapped: %v", err)
+	}
+	if IsQueuedSDKSyncError(err) {
+		t.Fatalf("error reported as queued")
+	}
+	err.(*SDKSyncError).Queued = true
+	if !IsQueuedSDKSyncError(err) {
+		t.Fatalf("error not reported as queued")
+	}
+}
+
+func TestGetRejectReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, RejectReasonUnknown},
+		{errors.New("unknown"), RejectReasonUnknown},
+		{ErrLowHealthFactor, RejectReasonLowHealthFactor},
+		{fmt.Errorf("wrapped: %w", ErrQuantityTradeTooSmall), RejectReasonQuantityTooSmall},
+		{&RejectError{Reason: RejectReasonInvalidRelayer, Err: ErrInvalidCollateralPrice}, RejectReasonInvalidRelayer},
+	}
+	for i, test := range tests {
+		if reason := GetRejectReason(test.err, RejectReasonUnknown); reason != test.want {
+			t.Errorf("test %d: have %s, want %s", i, reason, test.want)
+		}
+	}
+	item := &LendingItem{Status: "INVALID"}
+	if reason := GetRejectReason(item.VerifyLendingItem(nil, types.LendingTxSigner{}), RejectReasonUnknown); reason != RejectReasonInvalidStatus {
+		t.Errorf("wrong reason of invalid status: have %s, want %s", reason, RejectReasonInvalidStatus)
+	}
+}
@@ -0,0 +1,123 @@
+package lendingstate
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Escrow is the ledger of the balances of an account locked in the spot books of the
+// TradingStateDB and the lending books of the LendingStateDB, per token. The open spot orders and
+// lending items only commit the balance they will take once matched, which stays in the account,
+// while the collateral of the lending trades has left it for LendingLockAddress.
+type Escrow struct {
+	User   common.Address
+	tokens map[common.Address]*TokenEscrow
+}
+
+// TokenEscrow is the escrow of an account in a token. Available is the free balance of the account,
+// the part of it not committed to open orders is Available - SpotOrders - LendingOrders.
+type TokenEscrow struct {
+	Token         common.Address `json:"token"`
+	Available     *big.Int       `json:"available"`     // free balance
+	SpotOrders    *big.Int       `json:"spotOrders"`    // committed to open spot orders
+	LendingOrders *big.Int       `json:"lendingOrders"` // committed to open lending items
+	Collateral    *big.Int       `json:"collateral"`    // locked as collateral of lending trades
+	Locked        *big.Int       `json:"locked"`        // sum of the three above
+}
+
+// NewEscrow returns an empty escrow.
+func NewEscrow(user common.Address) *Escrow {
+	return &Escrow{User: user, tokens: make(map[common.Address]*TokenEscrow)}
+}
+
+// Token returns the escrow of the account in a token, created empty.
+func (e *Escrow) Token(token common.Address) *TokenEscrow {
+	if escrow, ok := e.tokens[token]; ok {
+		return escrow
+	}
+	escrow := &TokenEscrow{
+		Token:         token,
+		Available:     new(big.Int),
+		SpotOrders:    new(big.Int),
+		LendingOrders: new(big.Int),
+		Collateral:    new(big.Int),
+	}
+	e.tokens[token] = escrow
+	return escrow
+}
+
+// AddSpotOrder commits the remaining quantity of an open spot order of the account, see
+// spotOrderCommitment.
+func (e *Escrow) AddSpotOrder(order *tradingstate.OrderItem, remaining *big.Int, baseTokenDecimal *big.Int) {
+	if order.UserAddress != e.User {
+		return
+	}
+	token, amount := spotOrderCommitment(order, remaining, baseTokenDecimal)
+	if amount == nil {
+		return
+	}
+	escrow := e.Token(token)
+	escrow.SpotOrders = new(big.Int).Add(escrow.SpotOrders, amount)
+}
+
+// AddLendingItem commits the remaining quantity of an open lending item of the account: the lending
+// token of an investing item, the collateral a borrowing item will lock at the collateral price and
+// deposit rate, none if the price is unknown.
+func (e *Escrow) AddLendingItem(item *LendingItem, remaining *big.Int, collateralPrice, depositRate, collateralTokenDecimal *big.Int) {
+	if item.UserAddress != e.User || remaining == nil || remaining.Sign() <= 0 {
+		return
+	}
+	if item.Side == Investing {
+		escrow := e.Token(item.LendingToken)
+		escrow.LendingOrders = new(big.Int).Add(escrow.LendingOrders, remaining)
+		return
+	}
+	if collateralPrice == nil || collateralPrice.Sign() <= 0 || depositRate == nil || collateralTokenDecimal == nil {
+		return
+	}
+	// the collateral locked by GetSettleBalance: quantity * collateral decimal / price * deposit rate
+	collateral := new(big.Int).Mul(remaining, collateralTokenDecimal)
+	collateral.Mul(collateral, depositRate)
+	collateral.Div(collateral, big.NewInt(100))
+	collateral.Div(collateral, collateralPrice)
+	escrow := e.Token(item.CollateralToken)
+	escrow.LendingOrders = new(big.Int).Add(escrow.LendingOrders, collateral)
+}
+
+// AddLendingTrade adds the collaterals locked by an open lending trade borrowed by the account: its
+// collateral and the extra collaterals added to it.
+func (e *Escrow) AddLendingTrade(trade *LendingTrade, extraCollaterals []TradeCollateral) {
+	if trade.Borrower != e.User {
+		return
+	}
+	if trade.CollateralLockedAmount != nil {
+		escrow := e.Token(trade.CollateralToken)
+		escrow.Collateral = new(big.Int).Add(escrow.Collateral, trade.CollateralLockedAmount)
+	}
+	for _, collateral := range extraCollaterals {
+		if collateral.Amount == nil {
+			continue
+		}
+		escrow := e.Token(collateral.Token)
+		escrow.Collateral = new(big.Int).Add(escrow.Collateral, collateral.Amount)
+	}
+}
+
+// Tokens returns the escrow of the account in every token, with its locked balance, sorted by
+// token.
+func (e *Escrow) Tokens() []*TokenEscrow {
+	tokens := make([]*TokenEscrow, 0, len(e.tokens))
+	for _, escrow := range e.tokens {
+		escrow.Locked = new(big.Int).Add(escrow.SpotOrders, escrow.LendingOrders)
+		escrow.Locked.Add(escrow.Locked, escrow.Collateral)
+		tokens = append(tokens, escrow)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return bytes.Compare(tokens[i].Token.Bytes(), tokens[j].Token.Bytes()) < 0
+	})
+	return tokens
+}
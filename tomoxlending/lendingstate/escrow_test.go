@@ -0,0 +1,51 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestEscrow(t *testing.T) {
+	var (
+		user    = common.HexToAddress("0x41")
+		other   = common.HexToAddress("0x42")
+		tomo    = common.HexToAddress(common.TomoNativeAddress)
+		usdt    = common.HexToAddress("0x10")
+		btc     = common.HexToAddress("0x20")
+		decimal = big.NewInt(1000)
+		escrow  = NewEscrow(user)
+	)
+	escrow.AddSpotOrder(&tradingstate.OrderItem{UserAddress: user, Side: tradingstate.Ask, BaseToken: tomo, QuoteToken: usdt, Price: big.NewInt(2000)}, big.NewInt(200), decimal)
+	escrow.AddSpotOrder(&tradingstate.OrderItem{UserAddress: user, Side: tradingstate.Bid, BaseToken: tomo, QuoteToken: usdt, Price: big.NewInt(2000)}, big.NewInt(100), decimal)
+	escrow.AddSpotOrder(&tradingstate.OrderItem{UserAddress: other, Side: tradingstate.Ask, BaseToken: tomo}, big.NewInt(100), decimal)
+
+	// investing 50 usdt, borrowing 100 usdt against tomo at 2 usdt per tomo and a deposit rate of 150%
+	escrow.AddLendingItem(&LendingItem{UserAddress: user, Side: Investing, LendingToken: usdt}, big.NewInt(50), nil, nil, nil)
+	borrowing := &LendingItem{UserAddress: user, Side: Borrowing, LendingToken: usdt, CollateralToken: tomo}
+	escrow.AddLendingItem(borrowing, big.NewInt(100), big.NewInt(2000), big.NewInt(150), decimal)
+	// the collateral of a borrowing item isn't known without its price
+	escrow.AddLendingItem(borrowing, big.NewInt(100), nil, big.NewInt(150), decimal)
+
+	trade := &LendingTrade{Borrower: user, Investor: other, LendingToken: usdt, CollateralToken: tomo, CollateralLockedAmount: big.NewInt(300)}
+	escrow.AddLendingTrade(trade, []TradeCollateral{{Token: btc, Amount: big.NewInt(7)}})
+	// the collateral of the trades lent by the account isn't its own
+	escrow.AddLendingTrade(&LendingTrade{Borrower: other, Investor: user, CollateralToken: tomo, CollateralLockedAmount: big.NewInt(500)}, nil)
+
+	escrow.Token(tomo).Available = big.NewInt(1000)
+	tokens := escrow.Tokens()
+	if len(tokens) != 3 || tokens[0].Token != tomo || tokens[1].Token != usdt || tokens[2].Token != btc {
+		t.Fatalf("wrong tokens: %v", ToJSON(tokens))
+	}
+	if escrow := tokens[0]; escrow.SpotOrders.Int64() != 200 || escrow.LendingOrders.Int64() != 75 || escrow.Collateral.Int64() != 300 || escrow.Locked.Int64() != 575 || escrow.Available.Int64() != 1000 {
+		t.Errorf("wrong tomo escrow: %v", ToJSON(escrow))
+	}
+	if escrow := tokens[1]; escrow.SpotOrders.Int64() != 200 || escrow.LendingOrders.Int64() != 50 || escrow.Collateral.Sign() != 0 || escrow.Locked.Int64() != 250 {
+		t.Errorf("wrong usdt escrow: %v", ToJSON(escrow))
+	}
+	if escrow := tokens[2]; escrow.Collateral.Int64() != 7 || escrow.Locked.Int64() != 7 {
+		t.Errorf("wrong btc escrow: %v", ToJSON(escrow))
+	}
+}
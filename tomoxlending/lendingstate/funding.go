@@ -0,0 +1,85 @@
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// The interest of a lending trade is paid at once when it is repaid, liquidated or rolled over,
+// unless the relayer owners listing its lending book switch the book to periodic interest with an
+// InterestMode item. The interest of the open trades of a periodic lending book is then settled
+// every epoch, like the funding of a perpetual contract: the borrower pays the investor the interest
+// accrued since the trade was opened (see CalculateAccruedInterest) less the interest already
+// settled. Interest the borrower can't pay at an epoch is settled at the next one or at close.
+//
+// The interest mode of a lending book is the book returned by GetLendingInterestModeHash, whose
+// item interestModeId holds one in its quantity if the interest is periodic. The interest settled
+// by each trade is the quantity of the item with the trade id in the book returned by
+// GetLendingFundingBookHash. When the trade is closed, the settled interest is deducted from what
+// the borrower pays, see GetTotalRepayValue.
+const interestModeId = uint64(1)
+
+// IsPeriodicInterest returns whether the interest of the trades of a lending book is settled every
+// epoch.
+func (self *LendingStateDB) IsPeriodicInterest(lendingBook common.Hash) bool {
+	return self.getItemVolume(GetLendingInterestModeHash(lendingBook), interestModeId).Sign() > 0
+}
+
+// SetPeriodicInterest sets whether the interest of the trades of a lending book is settled every
+// epoch. The interest already settled by the open trades stays deducted from their repayment.
+func (self *LendingStateDB) SetPeriodicInterest(lendingBook common.Hash, periodic bool) {
+	if periodic == self.IsPeriodicInterest(lendingBook) {
+		return
+	}
+	mode := new(big.Int)
+	if periodic {
+		mode.SetUint64(1)
+	}
+	self.setItemVolume(GetLendingInterestModeHash(lendingBook), LendingItem{LendingId: interestModeId, Type: InterestMode}, mode)
+}
+
+// GetSettledInterest returns the interest already settled by a lending trade, in lending token.
+func (self *LendingStateDB) GetSettledInterest(lendingBook common.Hash, tradeId uint64) *big.Int {
+	return self.getItemVolume(GetLendingFundingBookHash(lendingBook), tradeId)
+}
+
+// SetSettledInterest sets the interest already settled by a lending trade, zero once it is closed.
+func (self *LendingStateDB) SetSettledInterest(lendingBook common.Hash, tradeId uint64, amount *big.Int) {
+	if amount.Sign() == 0 && self.GetSettledInterest(lendingBook, tradeId).Sign() == 0 {
+		return
+	}
+	self.setItemVolume(GetLendingFundingBookHash(lendingBook), LendingItem{LendingId: tradeId, Type: InterestMode}, amount)
+}
+
+// GetTotalRepayValue returns what the borrower of a lending trade pays to repay it at the given
+// time: CalculateTotalRepayValue less the interest already settled, at least the trade amount.
+func (self *LendingStateDB) GetTotalRepayValue(lendingBook common.Hash, trade *LendingTrade, time uint64) *big.Int {
+	paymentBalance := CalculateTotalRepayValue(time, trade.LiquidationTime, trade.Term, trade.Interest, trade.Amount)
+	settled := self.GetSettledInterest(lendingBook, trade.TradeId)
+	if settled.Sign() == 0 {
+		return paymentBalance
+	}
+	paymentBalance.Sub(paymentBalance, settled)
+	if paymentBalance.Cmp(trade.Amount) < 0 {
+		return new(big.Int).Set(trade.Amount)
+	}
+	return paymentBalance
+}
+
+// CalculateAccruedInterest returns the interest accrued by a lending trade from its start up to the
+// given time, capped at its liquidation time, at its annual interest rate.
+func CalculateAccruedInterest(time, liquidationTime, term uint64, apr uint64, tradeAmount *big.Int) *big.Int {
+	startBorrowingTime := liquidationTime - term
+	if time > liquidationTime {
+		time = liquidationTime
+	}
+	if time <= startBorrowingTime {
+		return new(big.Int)
+	}
+	interest := new(big.Int).Mul(tradeAmount, new(big.Int).SetUint64(apr))
+	interest.Mul(interest, new(big.Int).SetUint64(time-startBorrowingTime))
+	baseInterestDecimal := new(big.Int).Mul(common.BaseLendingInterest, new(big.Int).SetUint64(100))
+	interest.Div(interest, baseInterestDecimal)
+	return interest.Div(interest, new(big.Int).SetUint64(common.OneYear))
+}
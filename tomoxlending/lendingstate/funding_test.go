@@ -0,0 +1,71 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestCalculateAccruedInterest(t *testing.T) {
+	startTime := uint64(1600000000)
+	amount := big.NewInt(1000)
+	apr := new(big.Int).Mul(big.NewInt(10), common.BaseLendingInterest).Uint64() // 10% per year
+	for _, test := range []struct {
+		time     uint64
+		expected int64
+	}{
+		{startTime, 0},
+		{startTime + common.OneYear/2, 50},
+		{startTime + common.OneYear, 100},
+		// interest stops accruing at the liquidation time
+		{startTime + 2*common.OneYear, 100},
+	} {
+		if interest := CalculateAccruedInterest(test.time, startTime+common.OneYear, common.OneYear, apr, amount); interest.Int64() != test.expected {
+			t.Errorf("wrong accrued interest at %d: have %v, want %d", test.time, interest, test.expected)
+		}
+	}
+}
+
+func TestPeriodicInterest(t *testing.T) {
+	lendingStateDB, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingBook := GetLendingOrderBookHash(common.HexToAddress("0x10"), common.OneYear)
+	if lendingStateDB.IsPeriodicInterest(lendingBook) {
+		t.Fatalf("interest periodic by default")
+	}
+	lendingStateDB.SetPeriodicInterest(lendingBook, true)
+
+	startTime := uint64(1600000000)
+	trade := &LendingTrade{
+		TradeId:         1,
+		Amount:          big.NewInt(1000),
+		Interest:        new(big.Int).Mul(big.NewInt(10), common.BaseLendingInterest).Uint64(),
+		Term:            common.OneYear,
+		LiquidationTime: startTime + common.OneYear,
+	}
+	lendingStateDB.SetSettledInterest(lendingBook, trade.TradeId, big.NewInt(50))
+	root, err := lendingStateDB.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	lendingStateDB, _ = New(root, lendingStateDB.Database())
+	if !lendingStateDB.IsPeriodicInterest(lendingBook) {
+		t.Errorf("interest mode not committed")
+	}
+	// 75 of interest after half a year, 50 of it already settled
+	if repay := lendingStateDB.GetTotalRepayValue(lendingBook, trade, startTime+common.OneYear/2); repay.Int64() != 1025 {
+		t.Errorf("wrong total repay value: have %v, want 1025", repay)
+	}
+	// the settled interest never lowers the repayment below the principal
+	lendingStateDB.SetSettledInterest(lendingBook, trade.TradeId, big.NewInt(100))
+	if repay := lendingStateDB.GetTotalRepayValue(lendingBook, trade, startTime+common.OneYear/2); repay.Int64() != 1000 {
+		t.Errorf("wrong total repay value: have %v, want 1000", repay)
+	}
+
+	lendingStateDB.SetPeriodicInterest(lendingBook, false)
+	lendingStateDB.SetSettledInterest(lendingBook, trade.TradeId, new(big.Int))
+	if lendingStateDB.IsPeriodicInterest(lendingBook) || lendingStateDB.GetSettledInterest(lendingBook, trade.TradeId).Sign() != 0 {
+		t.Errorf("interest mode or settled interest not reset")
+	}
+}
@@ -0,0 +1,207 @@
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+// The insurance fund of a lending token is the book returned by GetLendingInsuranceFundHash, each
+// of its items holds one value in its quantity. The tokens of the fund are held by
+// LendingInsuranceAddress: the fund receives a share of the borrowing fees of the lending token and
+// pays the investors the debt left when a liquidation auction ends before the debt is paid back.
+const (
+	insuranceBalanceId       = uint64(1) // tokens held by the fund
+	insuranceContributionsId = uint64(2) // sum of the contributions
+	insuranceDrawsId         = uint64(3) // sum of the draws
+	insuranceUncoveredId     = uint64(4) // sum of the shortfalls the fund couldn't cover
+	insuranceSocializedId    = uint64(5) // part of the uncovered shortfalls charged to the investors
+)
+
+// insuranceFundItem is the type of the items of an insurance fund book, they are never sent by users.
+const insuranceFundItem = "INSURANCE_FUND"
+
+// kinds of insurance fund records
+const (
+	InsuranceContribution = "CONTRIBUTION"
+	InsuranceDraw         = "DRAW"
+	InsuranceHaircut      = "HAIRCUT"
+)
+
+// LendingInsuranceFund is the insurance fund of a lending token.
+type LendingInsuranceFund struct {
+	LendingToken  common.Address `json:"lendingToken"`
+	Balance       *big.Int       `json:"balance"`
+	Contributions *big.Int       `json:"contributions"`
+	Draws         *big.Int       `json:"draws"`
+	Uncovered     *big.Int       `json:"uncovered"`  // bad debt left to the investors
+	Socialized    *big.Int       `json:"socialized"` // part of the bad debt charged to the investors of the lending books
+}
+
+// getInsuranceFundValue returns a value of the insurance fund of a lending token.
+func (self *LendingStateDB) getInsuranceFundValue(lendingToken common.Address, id uint64) *big.Int {
+	return self.getItemVolume(GetLendingInsuranceFundHash(lendingToken), id)
+}
+
+// addInsuranceFundValue adds an amount to a value of the insurance fund of a lending token.
+func (self *LendingStateDB) addInsuranceFundValue(lendingToken common.Address, id uint64, amount *big.Int) {
+	value := new(big.Int).Add(self.getInsuranceFundValue(lendingToken, id), amount)
+	self.setItemVolume(GetLendingInsuranceFundHash(lendingToken), LendingItem{LendingId: id, LendingToken: lendingToken, Type: insuranceFundItem}, value)
+}
+
+// GetInsuranceFund returns the insurance fund of a lending token.
+func (self *LendingStateDB) GetInsuranceFund(lendingToken common.Address) LendingInsuranceFund {
+	return LendingInsuranceFund{
+		LendingToken:  lendingToken,
+		Balance:       self.getInsuranceFundValue(lendingToken, insuranceBalanceId),
+		Contributions: self.getInsuranceFundValue(lendingToken, insuranceContributionsId),
+		Draws:         self.getInsuranceFundValue(lendingToken, insuranceDrawsId),
+		Uncovered:     self.getInsuranceFundValue(lendingToken, insuranceUncoveredId),
+		Socialized:    self.getInsuranceFundValue(lendingToken, insuranceSocializedId),
+	}
+}
+
+// AddInsuranceContribution records a contribution to the insurance fund of a lending token.
+func (self *LendingStateDB) AddInsuranceContribution(lendingToken common.Address, amount *big.Int) {
+	if amount.Sign() <= 0 {
+		return
+	}
+	self.addInsuranceFundValue(lendingToken, insuranceBalanceId, amount)
+	self.addInsuranceFundValue(lendingToken, insuranceContributionsId, amount)
+}
+
+// DrawInsuranceFund records the draw covering a shortfall from the insurance fund of a lending
+// token, as much as its balance allows, and returns the amount drawn. The part of the shortfall
+// which isn't covered is recorded as uncovered.
+func (self *LendingStateDB) DrawInsuranceFund(lendingToken common.Address, shortfall *big.Int) *big.Int {
+	if shortfall.Sign() <= 0 {
+		return new(big.Int)
+	}
+	drawn := self.getInsuranceFundValue(lendingToken, insuranceBalanceId)
+	if drawn.Cmp(shortfall) > 0 {
+		drawn = new(big.Int).Set(shortfall)
+	}
+	if drawn.Sign() > 0 {
+		self.addInsuranceFundValue(lendingToken, insuranceBalanceId, new(big.Int).Neg(drawn))
+		self.addInsuranceFundValue(lendingToken, insuranceDrawsId, drawn)
+	}
+	if uncovered := new(big.Int).Sub(shortfall, drawn); uncovered.Sign() > 0 {
+		self.addInsuranceFundValue(lendingToken, insuranceUncoveredId, uncovered)
+	}
+	return drawn
+}
+
+// SocializeInsuranceUncovered records that an uncovered shortfall of a lending token has been
+// charged to the investors of its lending book, it isn't left to the investor of the auction.
+func (self *LendingStateDB) SocializeInsuranceUncovered(lendingToken common.Address, amount *big.Int) {
+	if amount.Sign() <= 0 {
+		return
+	}
+	self.addInsuranceFundValue(lendingToken, insuranceUncoveredId, new(big.Int).Neg(amount))
+	self.addInsuranceFundValue(lendingToken, insuranceSocializedId, amount)
+}
+
+// LendingInsuranceRecord is a contribution to or a draw from the insurance fund of a lending token,
+// recorded by SDK nodes. A contribution is a share of the borrowing fee of a new trade paid by its
+// borrowing relayer, a draw pays the investor of an auction the shortfall of the auction, partly
+// if the fund runs short, a haircut charges a part of the shortfall left to the investor of an open
+// trade of the lending book.
+type LendingInsuranceRecord struct {
+	Hash         common.Hash    `bson:"hash" json:"hash"`
+	TxHash       common.Hash    `bson:"txHash" json:"txHash"`
+	Kind         string         `bson:"kind" json:"kind"` // InsuranceContribution, InsuranceDraw or InsuranceHaircut
+	LendingToken common.Address `bson:"lendingToken" json:"lendingToken"`
+	Term         uint64         `bson:"term" json:"term"`
+	TradeId      uint64         `bson:"tradeId" json:"tradeId"`
+	TradeHash    common.Hash    `bson:"tradeHash" json:"tradeHash"`
+	Relayer      common.Address `bson:"relayer" json:"relayer"`   // borrowing relayer of a contribution
+	Investor     common.Address `bson:"investor" json:"investor"` // investor paid by a draw or charged a haircut
+	Amount       *big.Int       `bson:"amount" json:"amount"`
+	Shortfall    *big.Int       `bson:"shortfall" json:"shortfall"`     // debt left by the auction of a draw or a haircut
+	AuctionHash  common.Hash    `bson:"auctionHash" json:"auctionHash"` // auction whose shortfall a haircut socializes
+	CreatedAt    time.Time      `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time      `bson:"updatedAt" json:"updatedAt"`
+}
+
+type LendingInsuranceRecordBSON struct {
+	Hash         string    `bson:"hash" json:"hash"` // Keccak256Hash of the transaction, the trade and the kind
+	TxHash       string    `bson:"txHash" json:"txHash"`
+	Kind         string    `bson:"kind" json:"kind"`
+	LendingToken string    `bson:"lendingToken" json:"lendingToken"`
+	Term         string    `bson:"term" json:"term"`
+	TradeId      string    `bson:"tradeId" json:"tradeId"`
+	TradeHash    string    `bson:"tradeHash" json:"tradeHash"`
+	Relayer      string    `bson:"relayer" json:"relayer"`
+	Investor     string    `bson:"investor" json:"investor"`
+	Amount       string    `bson:"amount" json:"amount"`
+	Shortfall    string    `bson:"shortfall" json:"shortfall"`
+	AuctionHash  string    `bson:"auctionHash" json:"auctionHash"`
+	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (r *LendingInsuranceRecord) GetBSON() (interface{}, error) {
+	shortfall := "0"
+	if r.Shortfall != nil {
+		shortfall = r.Shortfall.String()
+	}
+	return LendingInsuranceRecordBSON{
+		Hash:         r.Hash.Hex(),
+		TxHash:       r.TxHash.Hex(),
+		Kind:         r.Kind,
+		LendingToken: r.LendingToken.Hex(),
+		Term:         strconv.FormatUint(r.Term, 10),
+		TradeId:      strconv.FormatUint(r.TradeId, 10),
+		TradeHash:    r.TradeHash.Hex(),
+		Relayer:      r.Relayer.Hex(),
+		Investor:     r.Investor.Hex(),
+		Amount:       r.Amount.String(),
+		Shortfall:    shortfall,
+		AuctionHash:  r.AuctionHash.Hex(),
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+	}, nil
+}
+
+func (r *LendingInsuranceRecord) SetBSON(raw bson.Raw) error {
+	decoded := new(LendingInsuranceRecordBSON)
+	if err := raw.Unmarshal(decoded); err != nil {
+		return fmt.Errorf("failed to decode LendingInsuranceRecord. Err: %v", err)
+	}
+	term, err := strconv.ParseUint(decoded.Term, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse LendingInsuranceRecord.Term. Err: %v", err)
+	}
+	tradeId, err := strconv.ParseUint(decoded.TradeId, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse LendingInsuranceRecord.TradeId. Err: %v", err)
+	}
+	r.Hash = common.HexToHash(decoded.Hash)
+	r.TxHash = common.HexToHash(decoded.TxHash)
+	r.Kind = decoded.Kind
+	r.LendingToken = common.HexToAddress(decoded.LendingToken)
+	r.Term = term
+	r.TradeId = tradeId
+	r.TradeHash = common.HexToHash(decoded.TradeHash)
+	r.Relayer = common.HexToAddress(decoded.Relayer)
+	r.Investor = common.HexToAddress(decoded.Investor)
+	r.Amount = ToBigInt(decoded.Amount)
+	r.Shortfall = ToBigInt(decoded.Shortfall)
+	r.AuctionHash = common.HexToHash(decoded.AuctionHash)
+	r.CreatedAt = decoded.CreatedAt
+	r.UpdatedAt = decoded.UpdatedAt
+	return nil
+}
+
+func (r *LendingInsuranceRecord) ComputeHash() common.Hash {
+	if r.Kind == InsuranceHaircut {
+		// a trade may be charged by several auctions settled by the same transaction
+		return crypto.Keccak256Hash(r.TxHash.Bytes(), r.TradeHash.Bytes(), []byte(r.Kind), r.AuctionHash.Bytes())
+	}
+	return crypto.Keccak256Hash(r.TxHash.Bytes(), r.TradeHash.Bytes(), []byte(r.Kind))
+}
@@ -73,6 +73,16 @@ type (
 		tradeId   common.Hash
 		prev      *big.Int
 	}
+	lendingTradeAmountChange struct {
+		orderBook common.Hash
+		tradeId   common.Hash
+		prev      *big.Int
+	}
+	itemVolumeChange struct {
+		orderBook common.Hash
+		orderId   common.Hash
+		prev      *big.Int
+	}
 )
 
 func (ch insertOrder) undo(s *LendingStateDB) {
@@ -93,11 +103,16 @@ func (ch subAmountOrder) undo(s *LendingStateDB) {
 	default:
 		return
 	}
+	// an emptied order list was removed from the interest trie, put it back
+	removed := stateOrderList.empty()
 	stateOrderItem := stateOrderBook.getLendingItem(s.db, ch.orderId)
 	newAmount := new(big.Int).Add(stateOrderItem.Quantity(), ch.amount)
 	stateOrderItem.setVolume(newAmount)
 	stateOrderList.insertLendingItem(s.db, ch.orderId, common.BigToHash(newAmount))
 	stateOrderList.AddVolume(ch.amount)
+	if removed {
+		stateOrderBook.restoreOrderList(s.db, ch.order.Side, stateOrderList)
+	}
 }
 func (ch nonceChange) undo(s *LendingStateDB) {
 	s.SetNonce(ch.hash, ch.prev)
@@ -136,3 +151,27 @@ func (ch collateralLockedAmount) undo(s *LendingStateDB) {
 	}
 	stateLendingTrade.SetCollateralLockedAmount(ch.prev)
 }
+
+func (ch lendingTradeAmountChange) undo(s *LendingStateDB) {
+	stateOrderBook := s.getLendingExchange(ch.orderBook)
+	if stateOrderBook == nil {
+		return
+	}
+	stateLendingTrade := stateOrderBook.getLendingTrade(s.db, ch.tradeId)
+	if stateLendingTrade == nil {
+		return
+	}
+	stateLendingTrade.SetAmount(ch.prev)
+}
+
+func (ch itemVolumeChange) undo(s *LendingStateDB) {
+	stateOrderBook := s.getLendingExchange(ch.orderBook)
+	if stateOrderBook == nil {
+		return
+	}
+	stateItem := stateOrderBook.getLendingItem(s.db, ch.orderId)
+	if stateItem == nil {
+		return
+	}
+	stateItem.setVolume(ch.prev)
+}
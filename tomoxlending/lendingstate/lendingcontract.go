@@ -17,6 +17,7 @@ var (
 	SupportedBaseSlot         = uint64(3)
 	SupportedTermSlot         = uint64(4)
 	ILOCollateralSlot         = uint64(5)
+	ModeratorSlot             = uint64(7)
 	LendingRelayerStructSlots = map[string]*big.Int{
 		"fee":         big.NewInt(0),
 		"bases":       big.NewInt(1),
@@ -202,6 +203,13 @@ func GetCollateralPrice(statedb *state.StateDB, collateralToken common.Address,
 	return price, blockNumber
 }
 
+// @function GetModerator
+// @param statedb : current state
+// @return: address of the moderator, who adds the lending tokens, terms and collaterals
+func GetModerator(statedb *state.StateDB) common.Address {
+	return common.BytesToAddress(statedb.GetState(common.HexToAddress(common.LendingRegistrationSMC), state.GetLocSimpleVariable(ModeratorSlot)).Bytes())
+}
+
 // @function GetSupportedTerms
 // @param statedb : current state
 // @return: list of terms which tomoxlending supports
@@ -9,6 +9,7 @@ import (
 	"github.com/tomochain/tomochain/crypto/sha3"
 	"math/big"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,21 +25,58 @@ const (
 	LendingStatusFilled        = "FILLED"
 	LendingStatusPartialFilled = "PARTIAL_FILLED"
 	LendingStatusCancelled     = "CANCELLED"
+	LendingStatusAmended       = "AMENDED" // sets the quantity and the interest of the open limit item LendingId
 	Market                     = "MO"
 	Limit                      = "LO"
+	StopLimit                  = "SLO"             // limit order entering the orderbook once the trigger interest in ExtraData is crossed
+	TopUpReserve               = "TOPUP_RESERVE"   // amount of collateral token which can be used to top up the trades of the user automatically
+	AuctionBid                 = "AUCTION_BID"     // buys collateral from the liquidation auction of the trade LendingTradeId
+	AddCollateral              = "ADD_COLLATERAL"  // deposits another collateral token to back the trade LendingTradeId
+	Rollover                   = "ROLLOVER"        // renews the trade LendingTradeId at maturity at an interest up to Interest
+	Iceberg                    = "ICE"             // limit order showing in the orderbook at most the displayed quantity in ExtraData
+	CircuitBreaker             = "CIRCUIT_BREAKER" // sets the circuit breaker of the lending book: threshold in basis points in Quantity, pause in blocks in Interest
+	RateModel                  = "RATE_MODEL"      // sets the interest rate model pricing the market items of the lending book, see ParseLendingRateModel
+	Referral                   = "REFERRAL"        // registers the referrer in ExtraData with the relayer, sharing Quantity basis points of its borrowing fees
+	Margin                     = "MARGIN"          // borrows the leveraged part of the spot order in ExtraData and places it, see ParseMarginOrder
+	InterestMode               = "INTEREST_MODE"   // sets how the interest of the trades of the lending book is settled: at maturity if Quantity is zero, every epoch otherwise
+	PriceOracle                = "PRICE_ORACLE"    // sets the price oracle in ExtraData of CollateralToken, see ParsePriceOracle
+	PriceReport                = "PRICE_REPORT"    // reports Quantity as the price of CollateralToken in LendingToken to its price oracle
+)
+
+// time in force of limit items, set in ExtraData. Limit items without time in force are good till cancelled.
+const (
+	TimeInForceGTC = "GTC" // good till cancelled
+	TimeInForceGTT = "GTT" // good till the unix time following the colon, e.g. GTT:1600000000
+	TimeInForceIOC = "IOC" // immediate or cancel: the unmatched part is not added to the lending book
+	TimeInForceFOK = "FOK" // fill or kill: the item is rejected unless it is filled at once
+	TimeInForcePO  = "PO"  // post only: the item is rejected instead of matching a resting item
 )
 
 var ValidInputLendingStatus = map[string]bool{
 	LendingStatusNew:       true,
 	LendingStatusCancelled: true,
+	LendingStatusAmended:   true,
 }
 
 var ValidInputLendingType = map[string]bool{
-	Market: true,
-	Limit:  true,
-	Repay:  true,
-	TopUp:  true,
-	Recall: true,
+	Market:         true,
+	Limit:          true,
+	StopLimit:      true,
+	Repay:          true,
+	TopUp:          true,
+	Recall:         true,
+	TopUpReserve:   true,
+	AuctionBid:     true,
+	AddCollateral:  true,
+	Rollover:       true,
+	Iceberg:        true,
+	CircuitBreaker: true,
+	RateModel:      true,
+	Referral:       true,
+	Margin:         true,
+	InterestMode:   true,
+	PriceOracle:    true,
+	PriceReport:    true,
 }
 
 // Signature struct
@@ -76,6 +114,7 @@ type LendingItem struct {
 	LendingId       uint64         `bson:"lendingId" json:"lendingId"`
 	LendingTradeId  uint64         `bson:"tradeId" json:"tradeId"`
 	ExtraData       string         `bson:"extraData" json:"extraData"`
+	RejectReason    string         `bson:"rejectReason,omitempty" json:"rejectReason,omitempty" rlp:"-"` // why the item was rejected, not part of the lending state
 }
 
 type LendingItemBSON struct {
@@ -100,6 +139,7 @@ type LendingItemBSON struct {
 	LendingId       string           `bson:"lendingId" json:"lendingId"`
 	LendingTradeId  string           `bson:"tradeId" json:"tradeId"`
 	ExtraData       string           `bson:"extraData" json:"extraData"`
+	RejectReason    string           `bson:"rejectReason,omitempty" json:"rejectReason,omitempty"`
 }
 
 func (l *LendingItem) GetBSON() (interface{}, error) {
@@ -123,6 +163,7 @@ func (l *LendingItem) GetBSON() (interface{}, error) {
 		LendingId:       strconv.FormatUint(l.LendingId, 10),
 		LendingTradeId:  strconv.FormatUint(l.LendingTradeId, 10),
 		ExtraData:       l.ExtraData,
+		RejectReason:    l.RejectReason,
 	}
 
 	if l.FilledAmount != nil {
@@ -191,46 +232,132 @@ func (l *LendingItem) SetBSON(raw bson.Raw) error {
 	}
 	l.LendingTradeId = uint64(lendingTradeId)
 	l.ExtraData = decoded.ExtraData
+	l.RejectReason = decoded.RejectReason
 	return nil
 }
 
-func (l *LendingItem) VerifyLendingItem(state *state.StateDB) error {
+func (l *LendingItem) VerifyLendingItem(state *state.StateDB, signer types.LendingSigner) error {
 	if err := l.VerifyLendingStatus(); err != nil {
-		return err
+		return &RejectError{Reason: RejectReasonInvalidStatus, Err: err}
 	}
 	if valid, _ := IsValidPair(state, l.Relayer, l.LendingToken, l.Term); valid == false {
-		return fmt.Errorf("invalid pair . LendToken %s . Term: %v", l.LendingToken.Hex(), l.Term)
+		return &RejectError{Reason: RejectReasonInvalidPair, Err: fmt.Errorf("invalid pair . LendToken %s . Term: %v", l.LendingToken.Hex(), l.Term)}
 	}
 	if l.Status == LendingStatusNew {
 		if err := l.VerifyLendingType(); err != nil {
-			return err
+			return &RejectError{Reason: RejectReasonInvalidType, Err: err}
 		}
-		if l.Type != Repay {
+		if l.Type == CircuitBreaker {
+			// a zero threshold disables the circuit breaker
+			if l.Quantity == nil || l.Quantity.Sign() < 0 {
+				return &RejectError{Reason: RejectReasonInvalidQuantity, Err: fmt.Errorf("VerifyLendingQuantity: invalid threshold. Quantity: %v", l.Quantity)}
+			}
+			if l.Quantity.Sign() > 0 && (l.Interest == nil || l.Interest.Sign() <= 0) {
+				return &RejectError{Reason: RejectReasonInvalidInterest, Err: fmt.Errorf("VerifyLendingInterest: invalid pause. Interest: %v", l.Interest)}
+			}
+			if owner := GetRelayerOwner(l.Relayer, state); l.UserAddress != owner {
+				return &RejectError{Reason: RejectReasonInvalidRelayer, Err: fmt.Errorf("circuit breaker not set by the relayer owner %s", owner.Hex())}
+			}
+		} else if l.Type == InterestMode {
+			if l.Quantity == nil || l.Quantity.Sign() < 0 {
+				return &RejectError{Reason: RejectReasonInvalidQuantity, Err: fmt.Errorf("VerifyLendingQuantity: invalid interest mode. Quantity: %v", l.Quantity)}
+			}
+			if owner := GetRelayerOwner(l.Relayer, state); l.UserAddress != owner {
+				return &RejectError{Reason: RejectReasonInvalidRelayer, Err: fmt.Errorf("interest mode not set by the relayer owner %s", owner.Hex())}
+			}
+		} else if l.Type == PriceOracle {
+			if _, err := ParsePriceOracle(l.ExtraData); err != nil {
+				return &RejectError{Reason: RejectReasonInvalidPriceOracle, Err: err}
+			}
+			if l.CollateralToken == (common.Address{}) {
+				return &RejectError{Reason: RejectReasonInvalidCollateral, Err: fmt.Errorf("price oracle of an empty collateral")}
+			}
+			if moderator := GetModerator(state); l.UserAddress != moderator {
+				return &RejectError{Reason: RejectReasonInvalidPriceOracle, Err: fmt.Errorf("price oracle not set by the moderator %s", moderator.Hex())}
+			}
+		} else if l.Type == RateModel {
+			if _, err := ParseLendingRateModel(l.ExtraData); err != nil {
+				return &RejectError{Reason: RejectReasonInvalidRateModel, Err: err}
+			}
+			if owner := GetRelayerOwner(l.Relayer, state); l.UserAddress != owner {
+				return &RejectError{Reason: RejectReasonInvalidRelayer, Err: fmt.Errorf("rate model not set by the relayer owner %s", owner.Hex())}
+			}
+		} else if l.Type == Referral {
+			// a zero share unregisters the referrer
+			if l.Quantity == nil || l.Quantity.Sign() < 0 || l.Quantity.Cmp(ReferralShareBasis) > 0 {
+				return &RejectError{Reason: RejectReasonInvalidQuantity, Err: fmt.Errorf("VerifyLendingQuantity: invalid referral share. Quantity: %v", l.Quantity)}
+			}
+			if _, err := ParseLendingReferral(l.ExtraData); err != nil {
+				return &RejectError{Reason: RejectReasonInvalidReferrer, Err: err}
+			}
+			if owner := GetRelayerOwner(l.Relayer, state); l.UserAddress != owner {
+				return &RejectError{Reason: RejectReasonInvalidRelayer, Err: fmt.Errorf("referrer not registered by the relayer owner %s", owner.Hex())}
+			}
+		} else if l.Type == TopUpReserve {
+			// a zero reserve disables the automatic top-up
+			if l.Quantity == nil || l.Quantity.Sign() < 0 {
+				return &RejectError{Reason: RejectReasonInvalidQuantity, Err: fmt.Errorf("VerifyLendingQuantity: invalid quantity. Quantity: %v", l.Quantity)}
+			}
+			if err := l.VerifyCollateral(state); err != nil {
+				return &RejectError{Reason: RejectReasonInvalidCollateral, Err: err}
+			}
+		} else if l.Type != Repay && l.Type != Rollover {
 			if err := l.VerifyLendingQuantity(); err != nil {
-				return err
+				return &RejectError{Reason: RejectReasonInvalidQuantity, Err: err}
+			}
+		}
+		if l.Type == Margin {
+			if l.Side != Borrowing {
+				return &RejectError{Reason: RejectReasonInvalidSide, Err: fmt.Errorf("VerifyLendingSide: margin item not borrowing. Side: %s", l.Side)}
+			}
+			if _, err := ParseMarginOrder(l.LendingToken, l.ExtraData); err != nil {
+				return &RejectError{Reason: RejectReasonInvalidMargin, Err: err}
+			}
+		}
+		if l.Type == AddCollateral || l.Type == Margin {
+			if err := l.VerifyCollateral(state); err != nil {
+				return &RejectError{Reason: RejectReasonInvalidCollateral, Err: err}
 			}
 		}
-		if l.Type == Limit || l.Type == Market {
+		if l.Type == Limit || l.Type == Market || l.Type == StopLimit || l.Type == Iceberg {
 			if err := l.VerifyLendingSide(); err != nil {
-				return err
+				return &RejectError{Reason: RejectReasonInvalidSide, Err: err}
 			}
 			if l.Side == Borrowing {
 				if err := l.VerifyCollateral(state); err != nil {
-					return err
+					return &RejectError{Reason: RejectReasonInvalidCollateral, Err: err}
 				}
 			}
 		}
-		if l.Type == Limit {
+		if l.Type == Limit || l.Type == StopLimit || l.Type == Iceberg {
 			if err := l.VerifyLendingInterest(); err != nil {
-				return err
+				return &RejectError{Reason: RejectReasonInvalidInterest, Err: err}
+			}
+		}
+		if l.Type == StopLimit {
+			if err := l.VerifyLendingTrigger(); err != nil {
+				return &RejectError{Reason: RejectReasonInvalidInterest, Err: err}
 			}
 		}
+		if l.Type == Iceberg {
+			if err := l.VerifyLendingDisplayQuantity(); err != nil {
+				return &RejectError{Reason: RejectReasonInvalidQuantity, Err: err}
+			}
+		}
+	}
+	if l.Status == LendingStatusAmended {
+		if err := l.VerifyLendingQuantity(); err != nil {
+			return &RejectError{Reason: RejectReasonInvalidQuantity, Err: err}
+		}
+		if err := l.VerifyLendingInterest(); err != nil {
+			return &RejectError{Reason: RejectReasonInvalidInterest, Err: err}
+		}
 	}
 	if !IsValidRelayer(state, l.Relayer) {
-		return fmt.Errorf("VerifyLendingItem: invalid relayer. address: %s", l.Relayer.Hex())
+		return &RejectError{Reason: RejectReasonInvalidRelayer, Err: fmt.Errorf("VerifyLendingItem: invalid relayer. address: %s", l.Relayer.Hex())}
 	}
-	if err := l.VerifyLendingSignature(); err != nil {
-		return err
+	if err := l.VerifyLendingSignature(signer); err != nil {
+		return &RejectError{Reason: RejectReasonInvalidSignature, Err: err}
 	}
 	return nil
 }
@@ -267,6 +394,83 @@ func (l *LendingItem) VerifyLendingInterest() error {
 	return nil
 }
 
+// VerifyLendingTrigger checks the trigger interest of a stop-limit item.
+func (l *LendingItem) VerifyLendingTrigger() error {
+	if trigger := l.TriggerInterest(); trigger == nil || trigger.Sign() <= 0 || common.BigToHash(trigger).Big().Cmp(trigger) != 0 {
+		return fmt.Errorf("VerifyLendingTrigger: invalid trigger interest. ExtraData: %s", l.ExtraData)
+	}
+	return nil
+}
+
+// TriggerInterest returns the trigger interest of a stop-limit item, nil if it is not a valid number.
+func (l *LendingItem) TriggerInterest() *big.Int {
+	trigger, ok := new(big.Int).SetString(l.ExtraData, 10)
+	if !ok {
+		return nil
+	}
+	return trigger
+}
+
+// VerifyLendingDisplayQuantity checks the displayed quantity of an iceberg item.
+func (l *LendingItem) VerifyLendingDisplayQuantity() error {
+	if display := l.DisplayQuantity(); display == nil || display.Sign() <= 0 || l.Quantity == nil || display.Cmp(l.Quantity) > 0 {
+		return fmt.Errorf("VerifyLendingDisplayQuantity: invalid displayed quantity. ExtraData: %s . Quantity: %v", l.ExtraData, l.Quantity)
+	}
+	return nil
+}
+
+// DisplayQuantity returns the displayed quantity of an iceberg item, nil if it is not a valid number.
+func (l *LendingItem) DisplayQuantity() *big.Int {
+	display, ok := new(big.Int).SetString(l.ExtraData, 10)
+	if !ok {
+		return nil
+	}
+	return display
+}
+
+// VerifyLendingTimeInForce checks the time in force of a limit item.
+func (l *LendingItem) VerifyLendingTimeInForce() error {
+	extraData, _ := types.SplitLendingReferrer(l.ExtraData)
+	switch tif, expiry := l.TimeInForce(); tif {
+	case TimeInForceGTC, TimeInForceIOC, TimeInForceFOK, TimeInForcePO:
+		if extraData == "" || extraData == tif {
+			return nil
+		}
+	case TimeInForceGTT:
+		if expiry > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("VerifyLendingTimeInForce: invalid time in force. ExtraData: %s", l.ExtraData)
+}
+
+// TimeInForce returns the time in force of an item, and the expiry time of a good-till-time item.
+func (l *LendingItem) TimeInForce() (string, uint64) {
+	extraData, _ := types.SplitLendingReferrer(l.ExtraData)
+	if l.Type != Limit || extraData == "" {
+		return TimeInForceGTC, 0
+	}
+	tif := strings.SplitN(extraData, ":", 2)
+	if tif[0] == TimeInForceGTT && len(tif) == 2 {
+		expiry, err := strconv.ParseUint(tif[1], 10, 64)
+		if err != nil {
+			return TimeInForceGTT, 0
+		}
+		return TimeInForceGTT, expiry
+	}
+	return tif[0], 0
+}
+
+// Referrer returns the referrer named by the ExtraData of a limit or market item, after its time in
+// force, empty if it names none.
+func (l *LendingItem) Referrer() common.Address {
+	if l.Type != Limit && l.Type != Market {
+		return common.Address{}
+	}
+	_, referrer := types.SplitLendingReferrer(l.ExtraData)
+	return referrer
+}
+
 func (l *LendingItem) VerifyLendingQuantity() error {
 	if l.Quantity == nil || l.Quantity.Sign() <= 0 {
 		return fmt.Errorf("VerifyLendingQuantity: invalid quantity. Quantity: %v", l.Quantity)
@@ -288,7 +492,10 @@ func (l *LendingItem) VerifyLendingStatus() error {
 	return nil
 }
 
-func (l *LendingItem) ComputeHash() common.Hash {
+// ComputeHash returns the hash of the item. Since TIPTomoXLendingV2 the hash of a limit item also
+// covers its time in force, and the hash of a limit, market or referral item its referrer, in
+// ExtraData.
+func (l *LendingItem) ComputeHash(isLendingV2 bool) common.Hash {
 	sha := sha3.NewKeccak256()
 	if l.Status == LendingStatusNew {
 		sha.Write(l.Relayer.Bytes())
@@ -297,11 +504,30 @@ func (l *LendingItem) ComputeHash() common.Hash {
 		sha.Write(l.CollateralToken.Bytes())
 		sha.Write([]byte(strconv.FormatInt(int64(l.Term), 10)))
 		sha.Write(common.BigToHash(l.Quantity).Bytes())
-		if l.Type == Limit {
+		if l.Type == Limit || l.Type == StopLimit || l.Type == Rollover || l.Type == Iceberg || l.Type == CircuitBreaker {
 			if l.Interest != nil {
 				sha.Write(common.BigToHash(l.Interest).Bytes())
 			}
 		}
+		if l.Type == StopLimit {
+			if trigger := l.TriggerInterest(); trigger != nil {
+				sha.Write(common.BigToHash(trigger).Bytes())
+			}
+		}
+		if l.Type == Iceberg {
+			if display := l.DisplayQuantity(); display != nil {
+				sha.Write(common.BigToHash(display).Bytes())
+			}
+		}
+		if ((isLendingV2 && (l.Type == Limit || l.Type == Referral)) || l.Type == RateModel || l.Type == Margin || l.Type == PriceOracle || l.Type == PriceReport) && l.ExtraData != "" {
+			sha.Write([]byte(l.ExtraData))
+		}
+		if isLendingV2 && l.Type == Market && l.Referrer() != (common.Address{}) {
+			sha.Write([]byte(l.ExtraData))
+		}
+		if l.Type == AuctionBid || l.Type == AddCollateral || l.Type == Rollover || l.Type == Recall {
+			sha.Write(common.BigToHash(new(big.Int).SetUint64(l.LendingTradeId)).Bytes())
+		}
 		sha.Write(common.BigToHash(l.EncodedSide()).Bytes())
 		sha.Write([]byte(l.Status))
 		sha.Write([]byte(l.Type))
@@ -329,8 +555,27 @@ func (l *LendingItem) EncodedSide() *big.Int {
 	return big.NewInt(1)
 }
 
-//verify signatures
-func (l *LendingItem) VerifyLendingSignature() error {
+// VerifyLendingSignature checks that the item is signed by its user with the given lending signer.
+func (l *LendingItem) VerifyLendingSignature(signer types.LendingSigner) error {
+	tx := l.lendingTransaction()
+	from, _ := types.LendingSender(signer, tx)
+	if from != tx.UserAddress() {
+		return fmt.Errorf("verify lending item: invalid signature")
+	}
+	return nil
+}
+
+// VerifyLendingHash checks that the hash of the item is the hash of its terms by the LendingTxSigner
+// of the block, so that it is covered by the signature of its user whatever the signer.
+func (l *LendingItem) VerifyLendingHash(signer types.LendingTxSigner) error {
+	if hash := signer.Hash(l.lendingTransaction()); hash != l.Hash {
+		return fmt.Errorf("verify lending item: invalid hash %s, want %s", l.Hash.Hex(), hash.Hex())
+	}
+	return nil
+}
+
+// lendingTransaction returns the signed lending transaction of the item.
+func (l *LendingItem) lendingTransaction() *types.LendingTransaction {
 	V := big.NewInt(int64(l.Signature.V))
 	R := l.Signature.R.Big()
 	S := l.Signature.S.Big()
@@ -338,12 +583,7 @@ func (l *LendingItem) VerifyLendingSignature() error {
 	//(nonce uint64, quantity *big.Int, interest, duration uint64, relayerAddress, userAddress, lendingToken, collateralToken common.Address, status, side, typeLending string, hash common.Hash, id uint64
 	tx := types.NewLendingTransaction(l.Nonce.Uint64(), l.Quantity, l.Interest.Uint64(), l.Term, l.Relayer, l.UserAddress,
 		l.LendingToken, l.CollateralToken, l.AutoTopUp, l.Status, l.Side, l.Type, l.Hash, l.LendingId, l.LendingTradeId, l.ExtraData)
-	tx.ImportSignature(V, R, S)
-	from, _ := types.LendingSender(types.LendingTxSigner{}, tx)
-	if from != tx.UserAddress() {
-		return fmt.Errorf("verify lending item: invalid signature")
-	}
-	return nil
+	return tx.ImportSignature(V, R, S)
 }
 
 func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingStateDb *LendingStateDB,
@@ -364,6 +604,8 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 				"lendingTradeId: %v. Token: %s. ExpectedBalance: %s. ActualBalance: %s",
 				lendingTradeId, lendingTrade.CollateralToken.Hex(), quantity.String(), tokenBalance.String())
 		}
+	case Recall:
+		// recall items don't need any balance, the collateral is given back to the borrower
 	case Repay:
 		lendingBook := GetLendingOrderBookHash(lendingToken, term)
 		lendingTrade := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
@@ -371,7 +613,11 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 			return fmt.Errorf("VerifyBalance: process payment for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
 		}
 		tokenBalance := GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
-		paymentBalance := CalculateTotalRepayValue(uint64(time.Now().Unix()), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+		paymentBalance := lendingStateDb.GetTotalRepayValue(lendingBook, &lendingTrade, uint64(time.Now().Unix()))
+		if quantity != nil && quantity.Sign() > 0 && quantity.Cmp(paymentBalance) < 0 {
+			// partial repayment
+			paymentBalance = quantity
+		}
 
 		if tokenBalance.Cmp(paymentBalance) < 0 {
 			return fmt.Errorf("VerifyBalance: not enough balance to process payment for lendingTrade."+
@@ -379,11 +625,11 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 				lendingTradeId, lendingTrade.LendingToken.Hex(), paymentBalance.String(), tokenBalance.String())
 
 		}
-	case Market, Limit:
+	case Market, Limit, Iceberg:
 		switch side {
 		case Investing:
 			switch status {
-			case LendingStatusNew:
+			case LendingStatusNew, LendingStatusAmended:
 				// make sure that investor have enough lendingToken
 				if balance := GetTokenBalance(userAddress, lendingToken, statedb); balance.Cmp(quantity) < 0 {
 					return fmt.Errorf("VerifyBalance: investor doesn't have enough lendingToken. User: %s. Token: %s. Expected: %v. Have: %v", userAddress.Hex(), lendingToken.Hex(), quantity, balance)
@@ -425,7 +671,7 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 			return nil
 		case Borrowing:
 			switch status {
-			case LendingStatusNew:
+			case LendingStatusNew, LendingStatusAmended:
 				depositRate, _, _ := GetCollateralDetail(statedb, collateralToken)
 				settleBalanceResult, err := GetSettleBalance(isTomoXLendingFork, Borrowing, lendTokenTOMOPrice, collateralPrice, depositRate, borrowingFeeRate, lendingToken, collateralToken, lendingTokenDecimal, collateralTokenDecimal, quantity)
 				if err != nil {
@@ -5,8 +5,10 @@ import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/rawdb"
 	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/crypto/sha3"
+	"github.com/tomochain/tomochain/params"
 	"github.com/tomochain/tomochain/rpc"
 	"math/big"
 	"math/rand"
@@ -37,6 +39,37 @@ func TestLendingItem_VerifyLendingSide(t *testing.T) {
 	}
 }
 
+func TestLendingItem_VerifyLendingTimeInForce(t *testing.T) {
+	tests := []struct {
+		name      string
+		extraData string
+		wantTif   string
+		wantErr   bool
+	}{
+		{"default", "", TimeInForceGTC, false},
+		{"good till cancelled", "GTC", TimeInForceGTC, false},
+		{"immediate or cancel", "IOC", TimeInForceIOC, false},
+		{"fill or kill", "FOK", TimeInForceFOK, false},
+		{"post only", "PO", TimeInForcePO, false},
+		{"good till time", "GTT:1600000000", TimeInForceGTT, false},
+		{"good till time without expiry", "GTT", TimeInForceGTT, true},
+		{"good till time with invalid expiry", "GTT:tomorrow", TimeInForceGTT, true},
+		{"unknown", "DAY", "DAY", true},
+		{"trailing data", "IOC:1", TimeInForceIOC, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &LendingItem{Type: Limit, ExtraData: tt.extraData}
+			if tif, _ := l.TimeInForce(); tif != tt.wantTif {
+				t.Errorf("TimeInForce() = %v, want %v", tif, tt.wantTif)
+			}
+			if err := l.VerifyLendingTimeInForce(); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyLendingTimeInForce() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestLendingItem_VerifyLendingInterest(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -134,6 +167,90 @@ func TestLendingItem_VerifyLendingStatus(t *testing.T) {
 	}
 }
 
+func TestLendingItem_VerifyLendingHash(t *testing.T) {
+	item := &LendingItem{
+		Nonce:        big.NewInt(1),
+		Quantity:     big.NewInt(1000),
+		Interest:     big.NewInt(10),
+		Term:         86400,
+		Relayer:      common.HexToAddress("0x1"),
+		UserAddress:  common.HexToAddress("0x2"),
+		LendingToken: common.HexToAddress("0x3"),
+		Status:       LendingStatusNew,
+		Side:         Investing,
+		Type:         Limit,
+		Signature:    &Signature{},
+	}
+	item.Hash = types.LendingTxSigner{}.Hash(item.lendingTransaction())
+	if err := item.VerifyLendingHash(types.LendingTxSigner{}); err != nil {
+		t.Fatalf("VerifyLendingHash() of the signed terms: %v", err)
+	}
+	// the hash of an item with the same terms but another relayer
+	item.Relayer = common.HexToAddress("0x4")
+	if err := item.VerifyLendingHash(types.LendingTxSigner{}); err == nil {
+		t.Fatal("VerifyLendingHash() accepted the hash of another relayer")
+	}
+}
+
+func TestLendingItem_VerifyLendingSignature(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	item := &LendingItem{
+		Nonce:          big.NewInt(4),
+		Quantity:       big.NewInt(500),
+		Interest:       big.NewInt(0),
+		Term:           86400,
+		Relayer:        common.HexToAddress("0x1"),
+		UserAddress:    crypto.PubkeyToAddress(key.PublicKey),
+		LendingToken:   common.HexToAddress("0x2"),
+		Status:         Repay,
+		Type:           Repay,
+		LendingTradeId: 7,
+		Signature:      &Signature{},
+	}
+	// the legacy signature of the repayment, as recovered by the signer of the block
+	signer := types.MakeLendingSigner(params.TestChainConfig, common.TIPTomoXLendingV2)
+	tx, err := types.LendingSignTx(item.lendingTransaction(), types.NewLendingTxSignerV2(), key)
+	if err != nil {
+		t.Fatalf("failed to sign repayment: %v", err)
+	}
+	V, R, S := tx.Signature()
+	item.Signature = &Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)}
+	if err := item.VerifyLendingSignature(signer); err != nil {
+		t.Fatalf("VerifyLendingSignature() of the signed repayment: %v", err)
+	}
+	// the relayer can't change the quantity of a partial repayment since TIPTomoXLendingV2
+	item.Quantity = big.NewInt(5000)
+	if err := item.VerifyLendingSignature(signer); err == nil {
+		t.Fatal("VerifyLendingSignature() accepted a tampered repayment quantity")
+	}
+}
+
+func TestLendingItem_ComputeHash(t *testing.T) {
+	item := &LendingItem{
+		Nonce:           big.NewInt(3),
+		Quantity:        big.NewInt(1000),
+		Interest:        big.NewInt(10),
+		Term:            86400,
+		Relayer:         common.HexToAddress("0x1"),
+		UserAddress:     common.HexToAddress("0x5"),
+		LendingToken:    common.HexToAddress("0x2"),
+		CollateralToken: common.HexToAddress("0x3"),
+		AutoTopUp:       true,
+		Status:          LendingStatusNew,
+		Side:            Borrowing,
+		Type:            Limit,
+		ExtraData:       "GTT:1600000000",
+	}
+	// before TIPTomoXLendingV2 the time in force of a limit item isn't hashed
+	if have, want := item.ComputeHash(false), common.HexToHash("0xbb518374ff19dafcd23ca79aaf573d90cf983df0cc8d2836467c0f5614405266"); have != want {
+		t.Errorf("ComputeHash() changed before TIPTomoXLendingV2: have %x, want %x", have, want)
+	}
+	hash := item.ComputeHash(true)
+	if item.ExtraData = ""; item.ComputeHash(true) == hash {
+		t.Error("ComputeHash() doesn't cover the time in force since TIPTomoXLendingV2")
+	}
+}
+
 func SetFee(statedb *state.StateDB, coinbase common.Address, feeRate *big.Int) {
 	locRelayerState := state.GetLocMappingAtKey(coinbase.Hash(), LendingRelayerListSlot)
 	locHash := common.BytesToHash(new(big.Int).Add(locRelayerState, LendingRelayerStructSlots["fee"]).Bytes())
@@ -0,0 +1,129 @@
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+// The lending activity of a block can be exported as EVM-style logs emitted by common.TomoXLendingAddress,
+// so that generic log indexers can consume it. The first topic is the keccak256 hash of the event
+// signature, the indexed fields follow, and the other fields are ABI encoded as 32-byte words in Data.
+var (
+	// LendingTrade(uint64 indexed tradeId, address indexed borrower, address indexed investor, address lendingToken,
+	// address collateralToken, uint64 term, uint64 interest, uint256 amount, uint256 collateralLockedAmount, uint256 liquidationPrice)
+	LendingTradeEvent = crypto.Keccak256Hash([]byte("LendingTrade(uint64,address,address,address,address,uint64,uint64,uint256,uint256,uint256)"))
+	// LendingRepay(uint64 indexed tradeId, address indexed borrower, address indexed investor, uint256 amount, uint256 collateralLockedAmount)
+	LendingRepayEvent = crypto.Keccak256Hash([]byte("LendingRepay(uint64,address,address,uint256,uint256)"))
+	// LendingTopUp(uint64 indexed tradeId, address indexed borrower, uint256 collateralLockedAmount, uint256 liquidationPrice)
+	LendingTopUpEvent = crypto.Keccak256Hash([]byte("LendingTopUp(uint64,address,uint256,uint256)"))
+	// LendingLiquidation(uint64 indexed tradeId, address indexed borrower, address indexed investor, uint256 collateralLockedAmount, uint256 liquidationPrice)
+	LendingLiquidationEvent = crypto.Keccak256Hash([]byte("LendingLiquidation(uint64,address,address,uint256,uint256)"))
+)
+
+func logWord(value *big.Int) []byte {
+	if value == nil {
+		return make([]byte, common.HashLength)
+	}
+	return common.BigToHash(value).Bytes()
+}
+
+func logAddressWord(address common.Address) []byte {
+	return common.BytesToHash(address.Bytes()).Bytes()
+}
+
+func newLendingLog(txHash common.Hash, event common.Hash, tradeId uint64, users []common.Address, data ...[]byte) *types.Log {
+	topics := []common.Hash{event, common.Uint64ToHash(tradeId)}
+	for _, user := range users {
+		topics = append(topics, common.BytesToHash(user.Bytes()))
+	}
+	var logData []byte
+	for _, word := range data {
+		logData = append(logData, word...)
+	}
+	return &types.Log{
+		Address: common.HexToAddress(common.TomoXLendingAddress),
+		Topics:  topics,
+		Data:    logData,
+		TxHash:  txHash,
+	}
+}
+
+func lendingTradeLog(txHash common.Hash, trade *LendingTrade) *types.Log {
+	return newLendingLog(txHash, LendingTradeEvent, trade.TradeId, []common.Address{trade.Borrower, trade.Investor},
+		logAddressWord(trade.LendingToken),
+		logAddressWord(trade.CollateralToken),
+		logWord(new(big.Int).SetUint64(trade.Term)),
+		logWord(new(big.Int).SetUint64(trade.Interest)),
+		logWord(trade.Amount),
+		logWord(trade.CollateralLockedAmount),
+		logWord(trade.LiquidationPrice),
+	)
+}
+
+func lendingRepayLog(txHash common.Hash, trade *LendingTrade) *types.Log {
+	return newLendingLog(txHash, LendingRepayEvent, trade.TradeId, []common.Address{trade.Borrower, trade.Investor},
+		logWord(trade.Amount),
+		logWord(trade.CollateralLockedAmount),
+	)
+}
+
+func lendingTopUpLog(txHash common.Hash, trade *LendingTrade) *types.Log {
+	return newLendingLog(txHash, LendingTopUpEvent, trade.TradeId, []common.Address{trade.Borrower},
+		logWord(trade.CollateralLockedAmount),
+		logWord(trade.LiquidationPrice),
+	)
+}
+
+func lendingLiquidationLog(txHash common.Hash, trade *LendingTrade) *types.Log {
+	return newLendingLog(txHash, LendingLiquidationEvent, trade.TradeId, []common.Address{trade.Borrower, trade.Investor},
+		logWord(trade.CollateralLockedAmount),
+		logWord(trade.LiquidationPrice),
+	)
+}
+
+// LendingItemLogs returns the logs of the trades created, repaid or topped up by a lending item
+// of a lending transaction. The block fields of the logs are left empty.
+func LendingItemLogs(txHash common.Hash, item *LendingItem, trades []*LendingTrade) []*types.Log {
+	var logs []*types.Log
+	for _, trade := range trades {
+		if trade == nil {
+			continue
+		}
+		switch item.Type {
+		case Limit, Market, StopLimit, Iceberg:
+			logs = append(logs, lendingTradeLog(txHash, trade))
+		case Repay:
+			if trade.Status == TradeStatusClosed {
+				logs = append(logs, lendingRepayLog(txHash, trade))
+			}
+		case TopUp:
+			logs = append(logs, lendingTopUpLog(txHash, trade))
+		}
+	}
+	return logs
+}
+
+// FinalizedTradeLogs returns the logs of the trades liquidated, repaid or topped up automatically
+// by the liquidation transaction of an epoch. The block fields of the logs are left empty.
+func FinalizedTradeLogs(result FinalizedResult, trades map[common.Hash]*LendingTrade) []*types.Log {
+	var logs []*types.Log
+	for _, hash := range result.Liquidated {
+		if trade, ok := trades[hash]; ok && trade.Status == TradeStatusLiquidated {
+			logs = append(logs, lendingLiquidationLog(result.TxHash, trade))
+		}
+	}
+	for _, hash := range result.AutoRepay {
+		if trade, ok := trades[hash]; ok {
+			logs = append(logs, lendingRepayLog(result.TxHash, trade))
+		}
+	}
+	for _, hash := range result.AutoTopUp {
+		if trade, ok := trades[hash]; ok {
+			logs = append(logs, lendingTopUpLog(result.TxHash, trade))
+		}
+	}
+	return logs
+}
@@ -0,0 +1,143 @@
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// A Margin item places a spot order whose leverage is funded by a borrow. Its ExtraData is the spot
+// order, side:token:price:leverage (see ParseMarginOrder), its Quantity the quantity of the spot
+// order in base token. The lending token of the item is the token spent by the spot order, the quote
+// token of a buy order and the base token of a sell order, token is the other token of the pair.
+// The item borrows (leverage - 1) / leverage of the spent amount at market against its collateral
+// token, and the user funds the rest.
+//
+// While its spot order rests in the order book, each lending trade opened by a margin item is linked
+// to it in the margin book of the lending book (see GetLendingMarginBookHash): the item with the
+// trade id holds the id of the spot order in its quantity, the hash of the order and its base and
+// quote tokens in LendingToken and CollateralToken. The spot order is cancelled with the lending
+// trade once it is liquidated.
+
+// MaxMarginLeverage is the highest leverage of a margin item.
+const MaxMarginLeverage = uint64(5)
+
+// MarginOrder is the spot order placed by a margin item.
+type MarginOrder struct {
+	Side       string // tradingstate.Bid or tradingstate.Ask
+	BaseToken  common.Address
+	QuoteToken common.Address
+	Price      *big.Int
+	Leverage   uint64
+}
+
+// ParseMarginOrder parses the spot order of a margin item lending lendingToken.
+func ParseMarginOrder(lendingToken common.Address, extraData string) (MarginOrder, error) {
+	params := strings.Split(extraData, ":")
+	if len(params) != 4 || !common.IsHexAddress(params[1]) {
+		return MarginOrder{}, fmt.Errorf("invalid margin order %s", extraData)
+	}
+	order := MarginOrder{Side: params[0]}
+	token := common.HexToAddress(params[1])
+	switch order.Side {
+	case tradingstate.Bid:
+		order.BaseToken, order.QuoteToken = token, lendingToken
+	case tradingstate.Ask:
+		order.BaseToken, order.QuoteToken = lendingToken, token
+	default:
+		return MarginOrder{}, fmt.Errorf("invalid margin order side %s", order.Side)
+	}
+	if token == lendingToken {
+		return MarginOrder{}, fmt.Errorf("invalid margin order pair %s", extraData)
+	}
+	price, ok := new(big.Int).SetString(params[2], 10)
+	if !ok || price.Sign() <= 0 {
+		return MarginOrder{}, fmt.Errorf("invalid margin order price %s", params[2])
+	}
+	order.Price = price
+	leverage, err := strconv.ParseUint(params[3], 10, 64)
+	if err != nil || leverage < 2 || leverage > MaxMarginLeverage {
+		return MarginOrder{}, fmt.Errorf("invalid margin order leverage %s", params[3])
+	}
+	order.Leverage = leverage
+	return order, nil
+}
+
+// MarginBorrow returns the amount of lending token the spot order of a margin item spends for a
+// quantity of base token, and the part of it borrowed.
+func (o MarginOrder) MarginBorrow(quantity *big.Int, baseTokenDecimal *big.Int) (*big.Int, *big.Int) {
+	spent := new(big.Int).Set(quantity)
+	if o.Side == tradingstate.Bid {
+		spent.Mul(spent, o.Price)
+		spent.Div(spent, baseTokenDecimal)
+	}
+	borrowed := new(big.Int).Mul(spent, new(big.Int).SetUint64(o.Leverage-1))
+	borrowed.Div(borrowed, new(big.Int).SetUint64(o.Leverage))
+	return spent, borrowed
+}
+
+// MarginPosition links a lending trade opened by a margin item to its spot order.
+type MarginPosition struct {
+	TradeId    uint64         `json:"tradeId"`
+	BaseToken  common.Address `json:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken"`
+	OrderId    uint64         `json:"orderId"`
+	OrderHash  common.Hash    `json:"orderHash"`
+}
+
+// GetMarginPosition returns the spot order linked to a lending trade, if any.
+func (self *LendingStateDB) GetMarginPosition(lendingBook common.Hash, tradeId uint64) (MarginPosition, bool) {
+	marginBook := GetLendingMarginBookHash(lendingBook)
+	if !self.Exist(marginBook) {
+		return MarginPosition{}, false
+	}
+	stateItem := self.getLendingExchange(marginBook).getLendingItem(self.db, common.BigToHash(new(big.Int).SetUint64(tradeId)))
+	if stateItem == nil || stateItem.empty() {
+		return MarginPosition{}, false
+	}
+	return toMarginPosition(stateItem.data), true
+}
+
+// GetMarginPositions returns the links of the lending trades of a lending book to their spot
+// orders, by trade id.
+func (self *LendingStateDB) GetMarginPositions(lendingBook common.Hash) []MarginPosition {
+	positions := []MarginPosition{}
+	for _, item := range self.getItems(GetLendingMarginBookHash(lendingBook)) {
+		positions = append(positions, toMarginPosition(item))
+	}
+	return positions
+}
+
+// SetMarginPosition links a lending trade to the spot order of the margin item which opened it.
+func (self *LendingStateDB) SetMarginPosition(lendingBook common.Hash, position MarginPosition) {
+	template := LendingItem{
+		LendingId:       position.TradeId,
+		Hash:            position.OrderHash,
+		LendingToken:    position.BaseToken,
+		CollateralToken: position.QuoteToken,
+		Type:            Margin,
+	}
+	self.setItemVolume(GetLendingMarginBookHash(lendingBook), template, new(big.Int).SetUint64(position.OrderId))
+}
+
+// RemoveMarginPosition unlinks a lending trade from its spot order.
+func (self *LendingStateDB) RemoveMarginPosition(lendingBook common.Hash, tradeId uint64) {
+	if _, ok := self.GetMarginPosition(lendingBook, tradeId); !ok {
+		return
+	}
+	self.setItemVolume(GetLendingMarginBookHash(lendingBook), LendingItem{LendingId: tradeId, Type: Margin}, new(big.Int))
+}
+
+func toMarginPosition(item LendingItem) MarginPosition {
+	return MarginPosition{
+		TradeId:    item.LendingId,
+		BaseToken:  item.LendingToken,
+		QuoteToken: item.CollateralToken,
+		OrderId:    item.Quantity.Uint64(),
+		OrderHash:  item.Hash,
+	}
+}
@@ -0,0 +1,82 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestParseMarginOrder(t *testing.T) {
+	var (
+		usdt = common.HexToAddress("0x10")
+		btc  = common.HexToAddress("0x20")
+	)
+	order, err := ParseMarginOrder(usdt, "BUY:"+btc.Hex()+":20000:3")
+	if err != nil {
+		t.Fatalf("failed to parse margin buy order: %v", err)
+	}
+	if order.Side != tradingstate.Bid || order.BaseToken != btc || order.QuoteToken != usdt || order.Price.Int64() != 20000 || order.Leverage != 3 {
+		t.Errorf("wrong margin buy order: %+v", order)
+	}
+	// buying 3 btc at 20000 spends 60000 usdt, two thirds of it borrowed
+	if spent, borrowed := order.MarginBorrow(big.NewInt(3000), big.NewInt(1000)); spent.Int64() != 60000 || borrowed.Int64() != 40000 {
+		t.Errorf("wrong margin buy borrow: spent %v, borrowed %v", spent, borrowed)
+	}
+	order, err = ParseMarginOrder(btc, "SELL:"+usdt.Hex()+":20000:2")
+	if err != nil {
+		t.Fatalf("failed to parse margin sell order: %v", err)
+	}
+	if order.Side != tradingstate.Ask || order.BaseToken != btc || order.QuoteToken != usdt {
+		t.Errorf("wrong margin sell order: %+v", order)
+	}
+	// selling 3 btc spends 3 btc, half of it borrowed
+	if spent, borrowed := order.MarginBorrow(big.NewInt(3000), big.NewInt(1000)); spent.Int64() != 3000 || borrowed.Int64() != 1500 {
+		t.Errorf("wrong margin sell borrow: spent %v, borrowed %v", spent, borrowed)
+	}
+
+	for _, extraData := range []string{
+		"",
+		"BUY:" + btc.Hex() + ":20000",
+		"HOLD:" + btc.Hex() + ":20000:3",
+		"BUY:" + usdt.Hex() + ":20000:3",
+		"BUY:" + btc.Hex() + ":0:3",
+		"BUY:" + btc.Hex() + ":20000:1",
+		"BUY:" + btc.Hex() + ":20000:6",
+	} {
+		if _, err := ParseMarginOrder(usdt, extraData); err == nil {
+			t.Errorf("expected an error parsing %q", extraData)
+		}
+	}
+}
+
+func TestMarginPositions(t *testing.T) {
+	lendingStateDB, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingBook := GetLendingOrderBookHash(common.HexToAddress("0x10"), 86400)
+	if _, ok := lendingStateDB.GetMarginPosition(lendingBook, 1); ok {
+		t.Fatalf("unexpected margin position")
+	}
+	position := MarginPosition{TradeId: 1, BaseToken: common.HexToAddress("0x20"), QuoteToken: common.HexToAddress("0x10"), OrderId: 7, OrderHash: common.HexToHash("0x77")}
+	lendingStateDB.SetMarginPosition(lendingBook, position)
+	lendingStateDB.SetMarginPosition(lendingBook, MarginPosition{TradeId: 2, OrderId: 8})
+	root, err := lendingStateDB.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	lendingStateDB, _ = New(root, lendingStateDB.Database())
+	if have, ok := lendingStateDB.GetMarginPosition(lendingBook, 1); !ok || have != position {
+		t.Errorf("wrong margin position: have %+v, want %+v", have, position)
+	}
+
+	snap := lendingStateDB.Snapshot()
+	lendingStateDB.RemoveMarginPosition(lendingBook, 1)
+	if positions := lendingStateDB.GetMarginPositions(lendingBook); len(positions) != 1 || positions[0].TradeId != 2 {
+		t.Errorf("wrong margin positions after removal: %+v", positions)
+	}
+	lendingStateDB.RevertToSnapshot(snap)
+	if positions := lendingStateDB.GetMarginPositions(lendingBook); len(positions) != 2 {
+		t.Errorf("wrong margin positions after revert: %+v", positions)
+	}
+}
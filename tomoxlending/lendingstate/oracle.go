@@ -0,0 +1,274 @@
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// The collateral tokens are priced by the lending registration contract, or by the medium price of
+// the last epoch in tomox. The moderator of the lending registration contract can price a collateral
+// token with a price oracle instead, set by a PriceOracle item whose CollateralToken is the token
+// and ExtraData the oracle (see ParsePriceOracle):
+//   - MEDIAN:minReports:reporter,... is the median of the prices reported during the epoch by the
+//     registered reporters with PriceReport items, if at least minReports of them reported
+//   - TWAP:epochs is the time weighted average price of the tomox pair of the collateral token and
+//     the lending token over the last epochs, see tradingstate.Twap
+//   - SIGNED:signer,... is the last price of the epoch signed by a registered signer, relayed by
+//     anyone with a PriceReport item whose ExtraData is the signing time and the signature, see
+//     RecoverPriceReporter
+//
+// An empty ExtraData removes the oracle. The oracle of a collateral token is the book returned by
+// GetPriceOracleHash, its reporters or signers the items of the book returned by
+// GetPriceReportersHash. The last report of each reporter is the book returned by
+// GetPriceReportHash.
+const (
+	PriceOracleMedian = "MEDIAN"
+	PriceOracleTWAP   = "TWAP"
+	PriceOracleSigned = "SIGNED"
+)
+
+const (
+	priceOracleKindId  = uint64(1)
+	priceOracleParamId = uint64(2) // minReports of median oracles, epochs of TWAP oracles
+
+	priceReportPriceId = uint64(1)
+	priceReportBlockId = uint64(2)
+	priceReportTimeId  = uint64(3) // signing time of the signed reports
+)
+
+var priceOracleKinds = map[string]uint64{
+	PriceOracleMedian: 1,
+	PriceOracleTWAP:   2,
+	PriceOracleSigned: 3,
+}
+
+// MaxPriceReporters is the highest number of reporters or signers of a price oracle.
+const MaxPriceReporters = 16
+
+// MaxTwapEpochs is the longest window of a TWAP oracle, in epochs.
+const MaxTwapEpochs = tradingstate.MaxTwapWindow
+
+// LendingPriceOracle prices a collateral token.
+type LendingPriceOracle struct {
+	Kind       string           `json:"kind"` // empty if the token has no oracle
+	MinReports uint64           `json:"minReports,omitempty"`
+	Epochs     uint64           `json:"epochs,omitempty"`
+	Reporters  []common.Address `json:"reporters,omitempty"`
+}
+
+// LendingPriceReport is the last price of a collateral token in a lending token reported by a
+// reporter.
+type LendingPriceReport struct {
+	Reporter    common.Address `json:"reporter"`
+	Price       *big.Int       `json:"price"`
+	BlockNumber uint64         `json:"blockNumber"`
+	Time        uint64         `json:"time,omitempty"` // signing time of a signed report
+}
+
+// ParsePriceOracle parses the oracle set by a PriceOracle item.
+func ParsePriceOracle(extraData string) (LendingPriceOracle, error) {
+	if extraData == "" {
+		return LendingPriceOracle{}, nil
+	}
+	params := strings.Split(extraData, ":")
+	oracle := LendingPriceOracle{Kind: params[0]}
+	var err error
+	switch oracle.Kind {
+	case PriceOracleMedian:
+		if len(params) != 3 {
+			return LendingPriceOracle{}, fmt.Errorf("invalid price oracle %s", extraData)
+		}
+		if oracle.Reporters, err = parsePriceReporters(params[2]); err != nil {
+			return LendingPriceOracle{}, err
+		}
+		oracle.MinReports, err = strconv.ParseUint(params[1], 10, 64)
+		if err != nil || oracle.MinReports == 0 || oracle.MinReports > uint64(len(oracle.Reporters)) {
+			return LendingPriceOracle{}, fmt.Errorf("invalid price oracle min reports %s", params[1])
+		}
+	case PriceOracleTWAP:
+		if len(params) != 2 {
+			return LendingPriceOracle{}, fmt.Errorf("invalid price oracle %s", extraData)
+		}
+		oracle.Epochs, err = strconv.ParseUint(params[1], 10, 64)
+		if err != nil || oracle.Epochs == 0 || oracle.Epochs > MaxTwapEpochs {
+			return LendingPriceOracle{}, fmt.Errorf("invalid price oracle epochs %s", params[1])
+		}
+	case PriceOracleSigned:
+		if len(params) != 2 {
+			return LendingPriceOracle{}, fmt.Errorf("invalid price oracle %s", extraData)
+		}
+		if oracle.Reporters, err = parsePriceReporters(params[1]); err != nil {
+			return LendingPriceOracle{}, err
+		}
+	default:
+		return LendingPriceOracle{}, fmt.Errorf("invalid price oracle kind %s", oracle.Kind)
+	}
+	return oracle, nil
+}
+
+func parsePriceReporters(list string) ([]common.Address, error) {
+	reporters := []common.Address{}
+	seen := map[common.Address]bool{}
+	for _, param := range strings.Split(list, ",") {
+		if !common.IsHexAddress(param) || common.HexToAddress(param) == (common.Address{}) {
+			return nil, fmt.Errorf("invalid price reporter %s", param)
+		}
+		reporter := common.HexToAddress(param)
+		if seen[reporter] {
+			return nil, fmt.Errorf("duplicate price reporter %s", param)
+		}
+		seen[reporter] = true
+		reporters = append(reporters, reporter)
+	}
+	if len(reporters) > MaxPriceReporters {
+		return nil, fmt.Errorf("too many price reporters: %d", len(reporters))
+	}
+	return reporters, nil
+}
+
+// PriceReportHash returns the hash signed by the signer of a signed price report.
+func PriceReportHash(collateralToken common.Address, lendingToken common.Address, price *big.Int, time uint64) common.Hash {
+	return crypto.Keccak256Hash(
+		collateralToken.Bytes(),
+		lendingToken.Bytes(),
+		common.BigToHash(price).Bytes(),
+		common.BigToHash(new(big.Int).SetUint64(time)).Bytes(),
+	)
+}
+
+// RecoverPriceReporter returns the signer and the signing time of a signed price report, whose
+// ExtraData is time:signature. The signature is the 65 bytes signature of the message of
+// PriceReportHash, with the prefix of the signed messages.
+func RecoverPriceReporter(collateralToken common.Address, lendingToken common.Address, price *big.Int, extraData string) (common.Address, uint64, error) {
+	params := strings.Split(extraData, ":")
+	if len(params) != 2 {
+		return common.Address{}, 0, fmt.Errorf("invalid signed price report %s", extraData)
+	}
+	time, err := strconv.ParseUint(params[0], 10, 64)
+	if err != nil || time == 0 {
+		return common.Address{}, 0, fmt.Errorf("invalid signed price report time %s", params[0])
+	}
+	sig, err := hexutil.Decode(params[1])
+	if err != nil || len(sig) != 65 {
+		return common.Address{}, 0, fmt.Errorf("invalid signed price report signature %s", params[1])
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	message := crypto.Keccak256(
+		[]byte("\x19Ethereum Signed Message:\n32"),
+		PriceReportHash(collateralToken, lendingToken, price, time).Bytes(),
+	)
+	pubKey, err := crypto.SigToPub(message, sig)
+	if err != nil {
+		return common.Address{}, 0, err
+	}
+	return crypto.PubkeyToAddress(*pubKey), time, nil
+}
+
+// GetPriceOracle returns the oracle of a collateral token.
+func (self *LendingStateDB) GetPriceOracle(collateralToken common.Address) LendingPriceOracle {
+	oracleBook := GetPriceOracleHash(collateralToken)
+	code := self.getItemVolume(oracleBook, priceOracleKindId).Uint64()
+	oracle := LendingPriceOracle{}
+	for kind, kindCode := range priceOracleKinds {
+		if kindCode == code {
+			oracle.Kind = kind
+		}
+	}
+	switch oracle.Kind {
+	case PriceOracleMedian:
+		oracle.MinReports = self.getItemVolume(oracleBook, priceOracleParamId).Uint64()
+	case PriceOracleTWAP:
+		oracle.Epochs = self.getItemVolume(oracleBook, priceOracleParamId).Uint64()
+	}
+	for _, item := range self.getItems(GetPriceReportersHash(collateralToken)) {
+		oracle.Reporters = append(oracle.Reporters, item.UserAddress)
+	}
+	return oracle
+}
+
+// SetPriceOracle sets the oracle of a collateral token, an empty oracle removes it. The reporters
+// removed from the oracle are unregistered, their last reports are left unused.
+func (self *LendingStateDB) SetPriceOracle(collateralToken common.Address, oracle LendingPriceOracle) {
+	oracleBook := GetPriceOracleHash(collateralToken)
+	param := oracle.MinReports
+	if oracle.Kind == PriceOracleTWAP {
+		param = oracle.Epochs
+	}
+	self.setItemVolume(oracleBook, LendingItem{LendingId: priceOracleKindId, CollateralToken: collateralToken, Type: PriceOracle}, new(big.Int).SetUint64(priceOracleKinds[oracle.Kind]))
+	self.setItemVolume(oracleBook, LendingItem{LendingId: priceOracleParamId, CollateralToken: collateralToken, Type: PriceOracle}, new(big.Int).SetUint64(param))
+
+	registered := map[common.Address]bool{}
+	for _, reporter := range oracle.Reporters {
+		registered[reporter] = true
+	}
+	reportersBook := GetPriceReportersHash(collateralToken)
+	known := map[common.Address]bool{}
+	if self.Exist(reportersBook) {
+		if dump, err := self.DumpLendingOrderTrie(reportersBook); err == nil {
+			for _, item := range dump {
+				known[item.UserAddress] = true
+				volume := new(big.Int)
+				if registered[item.UserAddress] {
+					volume.SetUint64(1)
+				}
+				if item.Quantity != nil && item.Quantity.Cmp(volume) != 0 {
+					self.setItemVolume(reportersBook, item, volume)
+				}
+			}
+		}
+	}
+	for _, reporter := range oracle.Reporters {
+		if known[reporter] {
+			continue
+		}
+		id := self.GetNonce(reportersBook) + 1
+		self.SetNonce(reportersBook, id)
+		self.setItemVolume(reportersBook, LendingItem{LendingId: id, UserAddress: reporter, CollateralToken: collateralToken, Type: PriceOracle}, common.Big1)
+	}
+}
+
+// IsPriceReporter returns whether a reporter or a signer is registered with the oracle of a
+// collateral token.
+func (self *LendingStateDB) IsPriceReporter(collateralToken common.Address, reporter common.Address) bool {
+	for _, item := range self.getItems(GetPriceReportersHash(collateralToken)) {
+		if item.UserAddress == reporter {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPriceReport returns the last price of a collateral token in a lending token reported by a
+// reporter, with a nil price if it never reported.
+func (self *LendingStateDB) GetPriceReport(collateralToken common.Address, lendingToken common.Address, reporter common.Address) LendingPriceReport {
+	reportBook := GetPriceReportHash(collateralToken, lendingToken, reporter)
+	report := LendingPriceReport{Reporter: reporter}
+	if price := self.getItemVolume(reportBook, priceReportPriceId); price.Sign() > 0 {
+		report.Price = price
+		report.BlockNumber = self.getItemVolume(reportBook, priceReportBlockId).Uint64()
+		report.Time = self.getItemVolume(reportBook, priceReportTimeId).Uint64()
+	}
+	return report
+}
+
+// SetPriceReport records the last price of a collateral token in a lending token reported by a
+// reporter.
+func (self *LendingStateDB) SetPriceReport(collateralToken common.Address, lendingToken common.Address, report LendingPriceReport) {
+	reportBook := GetPriceReportHash(collateralToken, lendingToken, report.Reporter)
+	template := LendingItem{UserAddress: report.Reporter, CollateralToken: collateralToken, LendingToken: lendingToken, Type: PriceReport}
+	template.LendingId = priceReportPriceId
+	self.setItemVolume(reportBook, template, report.Price)
+	template.LendingId = priceReportBlockId
+	self.setItemVolume(reportBook, template, new(big.Int).SetUint64(report.BlockNumber))
+	template.LendingId = priceReportTimeId
+	self.setItemVolume(reportBook, template, new(big.Int).SetUint64(report.Time))
+}
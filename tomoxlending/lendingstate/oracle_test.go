@@ -0,0 +1,114 @@
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+func TestParsePriceOracle(t *testing.T) {
+	reporterA, reporterB := common.HexToAddress("0x11"), common.HexToAddress("0x12")
+	for _, test := range []struct {
+		extraData string
+		expected  LendingPriceOracle
+		valid     bool
+	}{
+		{"", LendingPriceOracle{}, true},
+		{fmt.Sprintf("MEDIAN:2:%s,%s", reporterA.Hex(), reporterB.Hex()), LendingPriceOracle{Kind: PriceOracleMedian, MinReports: 2, Reporters: []common.Address{reporterA, reporterB}}, true},
+		{"TWAP:24", LendingPriceOracle{Kind: PriceOracleTWAP, Epochs: 24}, true},
+		{fmt.Sprintf("SIGNED:%s", reporterA.Hex()), LendingPriceOracle{Kind: PriceOracleSigned, Reporters: []common.Address{reporterA}}, true},
+		// more reports required than reporters
+		{fmt.Sprintf("MEDIAN:2:%s", reporterA.Hex()), LendingPriceOracle{}, false},
+		{fmt.Sprintf("MEDIAN:1:%s,%s", reporterA.Hex(), reporterA.Hex()), LendingPriceOracle{}, false},
+		{"TWAP:0", LendingPriceOracle{}, false},
+		{"TWAP:49", LendingPriceOracle{}, false},
+		{"SIGNED:0x0", LendingPriceOracle{}, false},
+		{"SPOT:1", LendingPriceOracle{}, false},
+	} {
+		oracle, err := ParsePriceOracle(test.extraData)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: have error %v, want valid %v", test.extraData, err, test.valid)
+			continue
+		}
+		if fmt.Sprint(oracle) != fmt.Sprint(test.expected) {
+			t.Errorf("%q: have %v, want %v", test.extraData, oracle, test.expected)
+		}
+	}
+}
+
+func TestPriceOracle(t *testing.T) {
+	lendingStateDB, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		collateralToken = common.HexToAddress("0x20")
+		lendingToken    = common.HexToAddress("0x30")
+		reporterA       = common.HexToAddress("0x11")
+		reporterB       = common.HexToAddress("0x12")
+		reporterC       = common.HexToAddress("0x13")
+	)
+	if oracle := lendingStateDB.GetPriceOracle(collateralToken); oracle.Kind != "" {
+		t.Fatalf("price oracle set by default: %v", oracle)
+	}
+	lendingStateDB.SetPriceOracle(collateralToken, LendingPriceOracle{Kind: PriceOracleMedian, MinReports: 1, Reporters: []common.Address{reporterA, reporterB}})
+	lendingStateDB.SetPriceReport(collateralToken, lendingToken, LendingPriceReport{Reporter: reporterA, Price: big.NewInt(100), BlockNumber: 900})
+	root, err := lendingStateDB.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	lendingStateDB, _ = New(root, lendingStateDB.Database())
+	oracle := lendingStateDB.GetPriceOracle(collateralToken)
+	if oracle.Kind != PriceOracleMedian || oracle.MinReports != 1 || len(oracle.Reporters) != 2 {
+		t.Fatalf("price oracle not committed: %v", oracle)
+	}
+	if report := lendingStateDB.GetPriceReport(collateralToken, lendingToken, reporterA); report.Price.Int64() != 100 || report.BlockNumber != 900 {
+		t.Errorf("price report not committed: %v", report)
+	}
+	if report := lendingStateDB.GetPriceReport(collateralToken, lendingToken, reporterB); report.Price != nil {
+		t.Errorf("price reported by a silent reporter: %v", report)
+	}
+
+	// replacing the reporters unregisters the removed ones
+	lendingStateDB.SetPriceOracle(collateralToken, LendingPriceOracle{Kind: PriceOracleSigned, Reporters: []common.Address{reporterB, reporterC}})
+	if lendingStateDB.IsPriceReporter(collateralToken, reporterA) || !lendingStateDB.IsPriceReporter(collateralToken, reporterB) || !lendingStateDB.IsPriceReporter(collateralToken, reporterC) {
+		t.Errorf("wrong reporters: %v", lendingStateDB.GetPriceOracle(collateralToken).Reporters)
+	}
+	lendingStateDB.SetPriceOracle(collateralToken, LendingPriceOracle{})
+	if oracle := lendingStateDB.GetPriceOracle(collateralToken); oracle.Kind != "" || len(oracle.Reporters) != 0 {
+		t.Errorf("price oracle not removed: %v", oracle)
+	}
+}
+
+func TestRecoverPriceReporter(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	collateralToken, lendingToken := common.HexToAddress("0x20"), common.HexToAddress("0x30")
+	price, time := big.NewInt(12345), uint64(1600000000)
+	message := crypto.Keccak256(
+		[]byte("\x19Ethereum Signed Message:\n32"),
+		PriceReportHash(collateralToken, lendingToken, price, time).Bytes(),
+	)
+	sig, err := crypto.Sign(message, key)
+	if err != nil {
+		t.Fatalf("failed to sign price report: %v", err)
+	}
+	sig[64] += 27
+	extraData := fmt.Sprintf("%d:%s", time, hexutil.Encode(sig))
+
+	signer, signedTime, err := RecoverPriceReporter(collateralToken, lendingToken, price, extraData)
+	if err != nil {
+		t.Fatalf("failed to recover price reporter: %v", err)
+	}
+	if signer != crypto.PubkeyToAddress(key.PublicKey) || signedTime != time {
+		t.Errorf("wrong signer or time: have %s %d", signer.Hex(), signedTime)
+	}
+	// another price recovers another signer
+	if signer, _, _ := RecoverPriceReporter(collateralToken, lendingToken, big.NewInt(12346), extraData); signer == crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("signature valid for another price")
+	}
+	if _, _, err := RecoverPriceReporter(collateralToken, lendingToken, price, hexutil.Encode(sig)); err == nil {
+		t.Errorf("signed price report without time accepted")
+	}
+}
@@ -0,0 +1,267 @@
+package lendingstate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb/memorydb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// LendingLevel is an interest level of a lending book as stored in the investing or borrowing
+// trie of the book: the volume of its items and the root of the trie of its items.
+type LendingLevel struct {
+	Interest  *big.Int    `json:"interest"`
+	Volume    *big.Int    `json:"volume"`
+	ItemsRoot common.Hash `json:"itemsRoot"`
+}
+
+// LendingBookProof proves the interest levels of a lending book against a lending state root.
+// BookProof holds the nodes of the lending state trie on the path to the lending book, which
+// commits to the roots of its investing and borrowing tries. Investing and Borrowing hold every
+// level of these tries, sorted by ascending interest, so that the tries can be rebuilt from them:
+// levels can neither be altered nor left out.
+type LendingBookProof struct {
+	Root      common.Hash     `json:"root"`
+	BookProof []hexutil.Bytes `json:"bookProof"`
+	Investing []LendingLevel  `json:"investing"`
+	Borrowing []LendingLevel  `json:"borrowing"`
+}
+
+// Tries of a lending book, as selected by the light client proofs.
+const (
+	LendingItemTrie     = iota // open lending items, keyed by lending id
+	LendingTradeTrie           // open lending trades, keyed by trade id
+	InvestingTrie              // investing interest levels, keyed by interest
+	BorrowingTrie              // borrowing interest levels, keyed by interest
+	LiquidationTimeTrie        // liquidation times of the lending trades, keyed by time
+)
+
+// LendingBookTrieRoot returns the root of a trie of a lending book, from the lending book as
+// encoded in the lending state trie.
+func LendingBookTrieRoot(enc []byte, bookTrie uint) (common.Hash, error) {
+	var data lendingObject
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		return common.Hash{}, fmt.Errorf("can't decode lending book: %v", err)
+	}
+	switch bookTrie {
+	case LendingItemTrie:
+		return data.LendingItemRoot, nil
+	case LendingTradeTrie:
+		return data.LendingTradeRoot, nil
+	case InvestingTrie:
+		return data.InvestingRoot, nil
+	case BorrowingTrie:
+		return data.BorrowingRoot, nil
+	case LiquidationTimeTrie:
+		return data.LiquidationTimeRoot, nil
+	default:
+		return common.Hash{}, fmt.Errorf("unknown lending book trie %d", bookTrie)
+	}
+}
+
+// proofList collects the nodes written by a trie proof.
+type proofList []hexutil.Bytes
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, common.CopyBytes(value))
+	return nil
+}
+
+func (n *proofList) Delete(key []byte) error {
+	panic("not supported")
+}
+
+// ProveLendingBook returns the proof of the interest levels of a lending book. The lending state
+// must be opened at a committed root and left unchanged, the proof is built from the committed
+// tries. The proof of a book missing from the state proves its absence, without any level.
+func (self *LendingStateDB) ProveLendingBook(orderBook common.Hash) (*LendingBookProof, error) {
+	proof := &LendingBookProof{Root: self.trie.Hash(), Investing: []LendingLevel{}, Borrowing: []LendingLevel{}}
+	var nodes proofList
+	if err := self.trie.Prove(orderBook[:], 0, &nodes); err != nil {
+		return nil, err
+	}
+	proof.BookProof = nodes
+	stateObject := self.getLendingExchange(orderBook)
+	if stateObject == nil {
+		return proof, nil
+	}
+	var err error
+	if proof.Investing, err = lendingLevels(stateObject.getInvestingTrie(self.db)); err != nil {
+		return nil, err
+	}
+	if proof.Borrowing, err = lendingLevels(stateObject.getBorrowingTrie(self.db)); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// lendingLevels returns the levels of an investing or borrowing trie, by ascending interest.
+func lendingLevels(tr Trie) ([]LendingLevel, error) {
+	levels := []LendingLevel{}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		var data itemList
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return nil, fmt.Errorf("can't decode interest level %x: %v", it.Key, err)
+		}
+		levels = append(levels, LendingLevel{Interest: new(big.Int).SetBytes(it.Key), Volume: data.Volume, ItemsRoot: data.Root})
+	}
+	return levels, it.Err
+}
+
+// VerifyLendingBookProof checks a proof of the interest levels of a lending book against the
+// lending state root of the proof, which the caller must compare to a trusted root, such as the
+// one committed by a block.
+func VerifyLendingBookProof(orderBook common.Hash, proof *LendingBookProof) error {
+	proofDb := memorydb.New()
+	for _, node := range proof.BookProof {
+		proofDb.Put(crypto.Keccak256(node), node)
+	}
+	value, err := trie.VerifyProof(proof.Root, orderBook[:], proofDb)
+	if err != nil {
+		return fmt.Errorf("invalid lending book proof: %v", err)
+	}
+	if value == nil {
+		if len(proof.Investing) > 0 || len(proof.Borrowing) > 0 {
+			return errors.New("interest levels of a missing lending book")
+		}
+		return nil
+	}
+	var data lendingObject
+	if err := rlp.DecodeBytes(value, &data); err != nil {
+		return fmt.Errorf("can't decode lending book: %v", err)
+	}
+	if err := verifyLendingLevels(data.InvestingRoot, proof.Investing); err != nil {
+		return fmt.Errorf("investing: %v", err)
+	}
+	if err := verifyLendingLevels(data.BorrowingRoot, proof.Borrowing); err != nil {
+		return fmt.Errorf("borrowing: %v", err)
+	}
+	return nil
+}
+
+// verifyLendingLevels checks that the levels are all the levels of the trie of the given root.
+func verifyLendingLevels(root common.Hash, levels []LendingLevel) error {
+	tr, err := trie.New(common.Hash{}, trie.NewDatabase(memorydb.New()))
+	if err != nil {
+		return err
+	}
+	for i, level := range levels {
+		if level.Interest == nil || level.Volume == nil {
+			return fmt.Errorf("incomplete interest level %d", i)
+		}
+		if i > 0 && level.Interest.Cmp(levels[i-1].Interest) <= 0 {
+			return errors.New("interest levels not sorted by ascending interest")
+		}
+		value, _ := rlp.EncodeToBytes(itemList{Volume: level.Volume, Root: level.ItemsRoot})
+		if err := tr.TryUpdate(common.BigToHash(level.Interest).Bytes(), value); err != nil {
+			return err
+		}
+	}
+	if root == EmptyHash {
+		root = EmptyRoot
+	}
+	if hash := tr.Hash(); hash != root {
+		return fmt.Errorf("interest levels root mismatch: have %x, want %x", hash, root)
+	}
+	return nil
+}
+
+// LendingTradeProof proves a lending trade of a lending book against a lending state root.
+// BookProof holds the nodes of the lending state trie on the path to the lending book, which
+// commits to the root of its trade trie, and TradeProof the nodes of the trade trie on the path to
+// the trade id. Trade is nil if the proof proves the trade missing from the book: never opened, or
+// closed and removed.
+type LendingTradeProof struct {
+	Root       common.Hash     `json:"root"`
+	BookProof  []hexutil.Bytes `json:"bookProof"`
+	TradeProof []hexutil.Bytes `json:"tradeProof"`
+	Trade      *LendingTrade   `json:"trade"`
+}
+
+// ProveLendingTrade returns the proof of a lending trade of a lending book. Like ProveLendingBook,
+// the lending state must be opened at a committed root and left unchanged.
+func (self *LendingStateDB) ProveLendingTrade(orderBook common.Hash, tradeId common.Hash) (*LendingTradeProof, error) {
+	proof := &LendingTradeProof{Root: self.trie.Hash(), TradeProof: []hexutil.Bytes{}}
+	var nodes proofList
+	if err := self.trie.Prove(orderBook[:], 0, &nodes); err != nil {
+		return nil, err
+	}
+	proof.BookProof = nodes
+	stateObject := self.getLendingExchange(orderBook)
+	if stateObject == nil {
+		return proof, nil
+	}
+	tr := stateObject.getLendingTradeTrie(self.db)
+	var tradeNodes proofList
+	if err := tr.Prove(tradeId[:], 0, &tradeNodes); err != nil {
+		return nil, err
+	}
+	proof.TradeProof = tradeNodes
+	enc, err := tr.TryGet(tradeId[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(enc) > 0 {
+		var trade LendingTrade
+		if err := rlp.DecodeBytes(enc, &trade); err != nil {
+			return nil, fmt.Errorf("can't decode lending trade %x: %v", tradeId, err)
+		}
+		proof.Trade = &trade
+	}
+	return proof, nil
+}
+
+// VerifyLendingTradeProof checks a proof of a lending trade against the lending state root of the
+// proof, which the caller must compare to a trusted root, such as the one committed by a block.
+func VerifyLendingTradeProof(orderBook common.Hash, tradeId common.Hash, proof *LendingTradeProof) error {
+	proofDb := memorydb.New()
+	for _, node := range proof.BookProof {
+		proofDb.Put(crypto.Keccak256(node), node)
+	}
+	value, err := trie.VerifyProof(proof.Root, orderBook[:], proofDb)
+	if err != nil {
+		return fmt.Errorf("invalid lending book proof: %v", err)
+	}
+	if value == nil {
+		if proof.Trade != nil {
+			return errors.New("lending trade of a missing lending book")
+		}
+		return nil
+	}
+	tradeRoot, err := LendingBookTrieRoot(value, LendingTradeTrie)
+	if err != nil {
+		return err
+	}
+	if tradeRoot == EmptyHash {
+		tradeRoot = EmptyRoot
+	}
+	tradeDb := memorydb.New()
+	for _, node := range proof.TradeProof {
+		tradeDb.Put(crypto.Keccak256(node), node)
+	}
+	enc, err := trie.VerifyProof(tradeRoot, tradeId[:], tradeDb)
+	if err != nil {
+		return fmt.Errorf("invalid lending trade proof: %v", err)
+	}
+	if enc == nil {
+		if proof.Trade != nil {
+			return errors.New("missing lending trade")
+		}
+		return nil
+	}
+	if proof.Trade == nil {
+		return errors.New("lending trade left out")
+	}
+	if have, err := rlp.EncodeToBytes(proof.Trade); err != nil || !bytes.Equal(have, enc) {
+		return errors.New("lending trade mismatch")
+	}
+	return nil
+}
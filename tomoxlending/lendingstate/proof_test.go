@@ -0,0 +1,164 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestProveLendingBook(t *testing.T) {
+	orderBook := GetLendingOrderBookHash(common.HexToAddress("0x1"), 60)
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, stateCache)
+	items := []LendingItem{
+		{LendingId: 1, Quantity: big.NewInt(10), Interest: big.NewInt(5), Side: Investing},
+		{LendingId: 2, Quantity: big.NewInt(20), Interest: big.NewInt(5), Side: Investing},
+		{LendingId: 3, Quantity: big.NewInt(30), Interest: big.NewInt(7), Side: Investing},
+		{LendingId: 4, Quantity: big.NewInt(40), Interest: big.NewInt(3), Side: Borrowing},
+	}
+	for _, item := range items {
+		statedb.InsertLendingItem(orderBook, common.Uint64ToHash(item.LendingId), item)
+	}
+	// another book, so that the state trie has more than one leaf
+	statedb.SetNonce(common.HexToHash("0x2"), 1)
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	statedb, _ = New(root, stateCache)
+
+	proof, err := statedb.ProveLendingBook(orderBook)
+	if err != nil {
+		t.Fatalf("failed to prove lending book: %v", err)
+	}
+	if proof.Root != root {
+		t.Fatalf("wrong proof root: have %x, want %x", proof.Root, root)
+	}
+	if len(proof.Investing) != 2 || proof.Investing[0].Volume.Int64() != 30 || proof.Investing[1].Volume.Int64() != 30 {
+		t.Fatalf("wrong investing levels: %v", ToJSON(proof.Investing))
+	}
+	if len(proof.Borrowing) != 1 || proof.Borrowing[0].Interest.Int64() != 3 {
+		t.Fatalf("wrong borrowing levels: %v", ToJSON(proof.Borrowing))
+	}
+	if err := VerifyLendingBookProof(orderBook, proof); err != nil {
+		t.Fatalf("failed to verify proof: %v", err)
+	}
+
+	// altered volume
+	proof.Investing[0].Volume = big.NewInt(31)
+	if err := VerifyLendingBookProof(orderBook, proof); err == nil {
+		t.Error("proof with an altered volume verified")
+	}
+	proof.Investing[0].Volume = big.NewInt(30)
+	// missing level
+	investing := proof.Investing
+	proof.Investing = investing[1:]
+	if err := VerifyLendingBookProof(orderBook, proof); err == nil {
+		t.Error("proof with a missing level verified")
+	}
+	proof.Investing = investing
+	// other root
+	proof.Root = common.HexToHash("0x3")
+	if err := VerifyLendingBookProof(orderBook, proof); err == nil {
+		t.Error("proof against another root verified")
+	}
+
+	// a missing book is proven empty
+	missing := GetLendingOrderBookHash(common.HexToAddress("0x1"), 30)
+	proof, err = statedb.ProveLendingBook(missing)
+	if err != nil {
+		t.Fatalf("failed to prove missing lending book: %v", err)
+	}
+	if err := VerifyLendingBookProof(missing, proof); err != nil {
+		t.Fatalf("failed to verify proof of missing lending book: %v", err)
+	}
+	proof.Borrowing = []LendingLevel{{Interest: big.NewInt(3), Volume: big.NewInt(40)}}
+	if err := VerifyLendingBookProof(missing, proof); err == nil {
+		t.Error("levels of a missing lending book verified")
+	}
+}
+
+func TestProveLendingTrade(t *testing.T) {
+	orderBook := GetLendingOrderBookHash(common.HexToAddress("0x1"), 60)
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, stateCache)
+	for id := uint64(1); id <= 3; id++ {
+		statedb.InsertTradingItem(orderBook, id, LendingTrade{
+			TradeId:         id,
+			Borrower:        common.HexToAddress("0x2"),
+			Amount:          big.NewInt(int64(100 * id)),
+			Interest:        10,
+			Term:            60,
+			Status:          TradeStatusOpen,
+			Hash:            common.BigToHash(big.NewInt(int64(id))),
+			LiquidationTime: 1000,
+		})
+	}
+	statedb.SetNonce(common.HexToHash("0x2"), 1)
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	statedb, _ = New(root, stateCache)
+
+	proof, err := statedb.ProveLendingTrade(orderBook, common.Uint64ToHash(2))
+	if err != nil {
+		t.Fatalf("failed to prove lending trade: %v", err)
+	}
+	if proof.Root != root || proof.Trade == nil || proof.Trade.TradeId != 2 || proof.Trade.Amount.Int64() != 200 {
+		t.Fatalf("wrong proof: %v", ToJSON(proof))
+	}
+	if err := VerifyLendingTradeProof(orderBook, common.Uint64ToHash(2), proof); err != nil {
+		t.Fatalf("failed to verify proof: %v", err)
+	}
+
+	// altered amount
+	proof.Trade.Amount = big.NewInt(300)
+	if err := VerifyLendingTradeProof(orderBook, common.Uint64ToHash(2), proof); err == nil {
+		t.Error("proof with an altered amount verified")
+	}
+	proof.Trade.Amount = big.NewInt(200)
+	// another trade id
+	if err := VerifyLendingTradeProof(orderBook, common.Uint64ToHash(3), proof); err == nil {
+		t.Error("proof of another trade verified")
+	}
+	// left out trade
+	trade := proof.Trade
+	proof.Trade = nil
+	if err := VerifyLendingTradeProof(orderBook, common.Uint64ToHash(2), proof); err == nil {
+		t.Error("proof leaving out the trade verified")
+	}
+	proof.Trade = trade
+	// other root
+	proof.Root = common.HexToHash("0x3")
+	if err := VerifyLendingTradeProof(orderBook, common.Uint64ToHash(2), proof); err == nil {
+		t.Error("proof against another root verified")
+	}
+
+	// a missing trade is proven missing
+	proof, err = statedb.ProveLendingTrade(orderBook, common.Uint64ToHash(9))
+	if err != nil {
+		t.Fatalf("failed to prove missing lending trade: %v", err)
+	}
+	if proof.Trade != nil {
+		t.Fatalf("missing trade returned: %v", ToJSON(proof.Trade))
+	}
+	if err := VerifyLendingTradeProof(orderBook, common.Uint64ToHash(9), proof); err != nil {
+		t.Fatalf("failed to verify proof of missing lending trade: %v", err)
+	}
+	proof.Trade = trade
+	if err := VerifyLendingTradeProof(orderBook, common.Uint64ToHash(9), proof); err == nil {
+		t.Error("trade presented as a missing one verified")
+	}
+
+	// as is a trade of a missing book
+	missing := GetLendingOrderBookHash(common.HexToAddress("0x1"), 30)
+	if proof, err = statedb.ProveLendingTrade(missing, common.Uint64ToHash(2)); err != nil {
+		t.Fatalf("failed to prove trade of missing lending book: %v", err)
+	}
+	if err := VerifyLendingTradeProof(missing, common.Uint64ToHash(2), proof); err != nil || proof.Trade != nil {
+		t.Fatalf("failed to verify proof of trade of missing lending book: %v", err)
+	}
+}
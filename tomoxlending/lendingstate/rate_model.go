@@ -0,0 +1,162 @@
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// The interest rate model of a lending book is the book returned by GetLendingRateModelHash, each
+// of its items holds one value in its quantity. It is set by the owner of a relayer listing the
+// lending book with a RateModel item, whose ExtraData is the model and its parameters, e.g.
+// KINKED:<base>:<slope1>:<slope2>:<kink>. An empty ExtraData removes the model.
+//
+// The kinked model prices the lending book by its utilization, the amount of the open trades over
+// the amount of the open trades and the investing items:
+//   - up to the kink, the rate grows linearly from base to base+slope1
+//   - over the kink, it grows linearly from base+slope1 to base+slope1+slope2 at full utilization
+//
+// Rates are interests of lending items, the utilization and the kink are in basis points.
+const (
+	rateModelKindId   = uint64(1) // model, zero if the book has none
+	rateModelBaseId   = uint64(2)
+	rateModelSlope1Id = uint64(3)
+	rateModelSlope2Id = uint64(4)
+	rateModelKinkId   = uint64(5)
+)
+
+// RateModelKinked is the kinked utilization rate model, the only model so far.
+const RateModelKinked = "KINKED"
+
+// rateModelKinds are the ids of the models stored in the lending state.
+var rateModelKinds = map[string]uint64{
+	RateModelKinked: 1,
+}
+
+// utilizationBasis is the basis of the utilization: 10000 basis points are 100%.
+var utilizationBasis = big.NewInt(10000)
+
+// LendingRateModel is the interest rate model of a lending book.
+type LendingRateModel struct {
+	Model  string `json:"model"` // empty if the book has no model
+	Base   uint64 `json:"base"`
+	Slope1 uint64 `json:"slope1"`
+	Slope2 uint64 `json:"slope2"`
+	Kink   uint64 `json:"kink"` // in basis points
+}
+
+// ParseLendingRateModel parses the model set by a RateModel item.
+func ParseLendingRateModel(extraData string) (LendingRateModel, error) {
+	if extraData == "" {
+		return LendingRateModel{}, nil
+	}
+	params := strings.Split(extraData, ":")
+	if _, ok := rateModelKinds[params[0]]; !ok || len(params) != 5 {
+		return LendingRateModel{}, fmt.Errorf("invalid rate model %s", extraData)
+	}
+	values := make([]uint64, 4)
+	for i := range values {
+		value, err := strconv.ParseUint(params[i+1], 10, 64)
+		if err != nil {
+			return LendingRateModel{}, fmt.Errorf("invalid rate model %s: %v", extraData, err)
+		}
+		values[i] = value
+	}
+	model := LendingRateModel{Model: params[0], Base: values[0], Slope1: values[1], Slope2: values[2], Kink: values[3]}
+	if model.Kink == 0 || model.Kink > utilizationBasis.Uint64() {
+		return LendingRateModel{}, fmt.Errorf("invalid rate model kink %d", model.Kink)
+	}
+	if model.Base+model.Slope1+model.Slope2 == 0 {
+		return LendingRateModel{}, fmt.Errorf("invalid rate model %s: zero rates", extraData)
+	}
+	return model, nil
+}
+
+// Rate returns the rate of the model at a utilization in basis points.
+func (m LendingRateModel) Rate(utilization uint64) *big.Int {
+	if utilization > utilizationBasis.Uint64() {
+		utilization = utilizationBasis.Uint64()
+	}
+	rate := new(big.Int).SetUint64(m.Base)
+	if utilization <= m.Kink {
+		// base + slope1 * utilization / kink
+		slope := new(big.Int).Mul(new(big.Int).SetUint64(m.Slope1), new(big.Int).SetUint64(utilization))
+		return rate.Add(rate, slope.Div(slope, new(big.Int).SetUint64(m.Kink)))
+	}
+	// base + slope1 + slope2 * (utilization - kink) / (10000 - kink)
+	rate.Add(rate, new(big.Int).SetUint64(m.Slope1))
+	slope := new(big.Int).Mul(new(big.Int).SetUint64(m.Slope2), new(big.Int).SetUint64(utilization-m.Kink))
+	return rate.Add(rate, slope.Div(slope, new(big.Int).SetUint64(utilizationBasis.Uint64()-m.Kink)))
+}
+
+// setRateModelValue sets a value of the rate model of a lending book.
+func (self *LendingStateDB) setRateModelValue(lendingBook common.Hash, id uint64, value uint64) {
+	modelBook := GetLendingRateModelHash(lendingBook)
+	if value == 0 && self.getItemVolume(modelBook, id).Sign() == 0 {
+		return
+	}
+	self.setItemVolume(modelBook, LendingItem{LendingId: id, Type: RateModel}, new(big.Int).SetUint64(value))
+}
+
+// GetLendingRateModel returns the rate model of a lending book.
+func (self *LendingStateDB) GetLendingRateModel(lendingBook common.Hash) LendingRateModel {
+	modelBook := GetLendingRateModelHash(lendingBook)
+	kind := self.getItemVolume(modelBook, rateModelKindId).Uint64()
+	model := LendingRateModel{}
+	for name, id := range rateModelKinds {
+		if id == kind {
+			model.Model = name
+		}
+	}
+	if model.Model == "" {
+		return LendingRateModel{}
+	}
+	model.Base = self.getItemVolume(modelBook, rateModelBaseId).Uint64()
+	model.Slope1 = self.getItemVolume(modelBook, rateModelSlope1Id).Uint64()
+	model.Slope2 = self.getItemVolume(modelBook, rateModelSlope2Id).Uint64()
+	model.Kink = self.getItemVolume(modelBook, rateModelKinkId).Uint64()
+	return model
+}
+
+// SetLendingRateModel sets the rate model of a lending book, an empty model removes it.
+func (self *LendingStateDB) SetLendingRateModel(lendingBook common.Hash, model LendingRateModel) {
+	self.setRateModelValue(lendingBook, rateModelKindId, rateModelKinds[model.Model])
+	self.setRateModelValue(lendingBook, rateModelBaseId, model.Base)
+	self.setRateModelValue(lendingBook, rateModelSlope1Id, model.Slope1)
+	self.setRateModelValue(lendingBook, rateModelSlope2Id, model.Slope2)
+	self.setRateModelValue(lendingBook, rateModelKinkId, model.Kink)
+}
+
+// GetLendingUtilization returns the utilization of a lending book in basis points: the amount of
+// its open trades over the amount of its open trades and investing items.
+func (self *LendingStateDB) GetLendingUtilization(lendingBook common.Hash) (uint64, error) {
+	if !self.Exist(lendingBook) {
+		return 0, nil
+	}
+	borrowed := new(big.Int)
+	trades, err := self.DumpLendingTradeTrie(lendingBook)
+	if err != nil {
+		return 0, err
+	}
+	for _, trade := range trades {
+		if trade.Amount != nil && trade.Amount.Sign() > 0 {
+			borrowed.Add(borrowed, trade.Amount)
+		}
+	}
+	investings, err := self.GetInvestings(lendingBook)
+	if err != nil {
+		return 0, err
+	}
+	total := new(big.Int).Set(borrowed)
+	for _, volume := range investings {
+		total.Add(total, volume)
+	}
+	if total.Sign() == 0 {
+		return 0, nil
+	}
+	utilization := new(big.Int).Mul(borrowed, utilizationBasis)
+	return utilization.Div(utilization, total).Uint64(), nil
+}
@@ -0,0 +1,57 @@
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// A relayer shares its borrowing fees with the referrers its owner registers with a Referral item,
+// whose ExtraData is the referrer and Quantity its share of the fees in basis points. The share is
+// held by the book returned by GetLendingReferralHash, a zero share unregisters the referrer.
+// A limit or market item names its referrer in the last part of its ExtraData (see
+// types.SplitLendingReferrer), the fees paid to a referrer in each lending token are summed in the
+// book returned by GetLendingReferralFeeHash.
+const (
+	referralShareId = uint64(1)
+	referralFeeId   = uint64(1)
+)
+
+// ReferralShareBasis is the basis of the referral shares: 10000 basis points are the whole fee.
+var ReferralShareBasis = big.NewInt(10000)
+
+// ParseLendingReferral parses the referrer registered by a Referral item.
+func ParseLendingReferral(extraData string) (common.Address, error) {
+	if !common.IsHexAddress(extraData) || common.HexToAddress(extraData) == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("invalid referrer %s", extraData)
+	}
+	return common.HexToAddress(extraData), nil
+}
+
+// GetReferralShare returns the share in basis points of the borrowing fees of a relayer paid to a
+// referrer, zero if the referrer isn't registered with the relayer.
+func (self *LendingStateDB) GetReferralShare(relayer common.Address, referrer common.Address) *big.Int {
+	return self.getItemVolume(GetLendingReferralHash(relayer, referrer), referralShareId)
+}
+
+// SetReferralShare registers a referrer with a relayer, a zero share unregisters it.
+func (self *LendingStateDB) SetReferralShare(relayer common.Address, referrer common.Address, share *big.Int) {
+	referralBook := GetLendingReferralHash(relayer, referrer)
+	if share.Sign() == 0 && self.getItemVolume(referralBook, referralShareId).Sign() == 0 {
+		return
+	}
+	self.setItemVolume(referralBook, LendingItem{LendingId: referralShareId, Relayer: relayer, UserAddress: referrer, Type: Referral}, share)
+}
+
+// GetReferralFees returns the fees paid to a referrer in a lending token.
+func (self *LendingStateDB) GetReferralFees(referrer common.Address, lendingToken common.Address) *big.Int {
+	return self.getItemVolume(GetLendingReferralFeeHash(referrer, lendingToken), referralFeeId)
+}
+
+// AddReferralFee adds a fee paid to a referrer in a lending token.
+func (self *LendingStateDB) AddReferralFee(referrer common.Address, lendingToken common.Address, amount *big.Int) {
+	feeBook := GetLendingReferralFeeHash(referrer, lendingToken)
+	total := new(big.Int).Add(self.getItemVolume(feeBook, referralFeeId), amount)
+	self.setItemVolume(feeBook, LendingItem{LendingId: referralFeeId, UserAddress: referrer, LendingToken: lendingToken, Type: Referral}, total)
+}
@@ -0,0 +1,24 @@
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// The creation of a lending item since TIPTomoXLendingReplayProtection is recorded in the book
+// returned by GetLendingConsumedHashBookHash for its hash. The quantity of the single item of the
+// book is the number of the block which created the lending item, so that an item can't be
+// created twice through the same or another relayer.
+const consumedHashId = uint64(1)
+
+// GetLendingHashConsumedAt returns the number of the block which created the lending item with the
+// given hash, zero if the hash hasn't been consumed.
+func (self *LendingStateDB) GetLendingHashConsumedAt(hash common.Hash) uint64 {
+	return self.getItemVolume(GetLendingConsumedHashBookHash(hash), consumedHashId).Uint64()
+}
+
+// ConsumeLendingHash records the hash of a lending item created at a block.
+func (self *LendingStateDB) ConsumeLendingHash(hash common.Hash, blockNumber uint64) {
+	self.setItemVolume(GetLendingConsumedHashBookHash(hash), LendingItem{LendingId: consumedHashId, Hash: hash}, new(big.Int).SetUint64(blockNumber))
+}
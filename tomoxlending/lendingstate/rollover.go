@@ -0,0 +1,30 @@
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// GetRolloverInterest returns the highest interest at which the borrower of a lending trade
+// accepts to roll it over at maturity, zero if the trade isn't to be rolled over.
+func (self *LendingStateDB) GetRolloverInterest(lendingBook common.Hash, tradeId uint64) *big.Int {
+	rolloverBook := GetLendingRolloverBookHash(lendingBook)
+	if !self.Exist(rolloverBook) {
+		return new(big.Int)
+	}
+	stateItem := self.getLendingExchange(rolloverBook).getLendingItem(self.db, common.BigToHash(new(big.Int).SetUint64(tradeId)))
+	if stateItem == nil || stateItem.empty() {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(stateItem.Quantity())
+}
+
+// SetRolloverInterest sets the highest interest at which a lending trade is rolled over at
+// maturity, zero cancels the rollover.
+func (self *LendingStateDB) SetRolloverInterest(lendingBook common.Hash, tradeId uint64, interest *big.Int) {
+	if interest.Sign() == 0 && self.GetRolloverInterest(lendingBook, tradeId).Sign() == 0 {
+		return
+	}
+	self.setItemVolume(GetLendingRolloverBookHash(lendingBook), LendingItem{LendingId: tradeId, Type: Rollover}, interest)
+}
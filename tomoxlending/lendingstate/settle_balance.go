@@ -12,6 +12,7 @@ const DefaultFeeRate = 100 // 100 / TomoXBaseFee = 100 / 10000 = 1%
 var (
 	ErrQuantityTradeTooSmall  = errors.New("quantity trade too small")
 	ErrInvalidCollateralPrice = errors.New("unable to retrieve price of this collateral. Please try another collateral")
+	ErrLowHealthFactor        = errors.New("health factor of the lending trade is too low to recall collateral")
 )
 
 type TradeResult struct {
@@ -254,3 +255,32 @@ func CalculateTotalRepayValue(finalizeTime, liquidationTime, term uint64, apr ui
 	paymentBalance = new(big.Int).Div(paymentBalance, baseInterestDecimal)
 	return paymentBalance
 }
+
+// CalculateHealthFactor returns the collateral price of a lending trade over its liquidation price,
+// in percent of common.BaseRecall.
+// Eg: collateralPrice = 150, liquidationPrice = 100 => healthFactor = 150
+func CalculateHealthFactor(collateralPrice, liquidationPrice *big.Int) *big.Int {
+	if liquidationPrice == nil || liquidationPrice.Sign() <= 0 {
+		return Zero
+	}
+	healthFactor := new(big.Int).Mul(collateralPrice, common.BaseRecall)
+	return new(big.Int).Div(healthFactor, liquidationPrice)
+}
+
+// CalculateMaxRecallAmount returns the amount of collateral which can be recalled from a lending trade
+// without bringing its health factor below the one of a new trade: depositRate / liquidationRate.
+func CalculateMaxRecallAmount(lockedAmount, liquidationPrice, collateralPrice, depositRate, liquidationRate *big.Int) *big.Int {
+	if collateralPrice == nil || collateralPrice.Sign() <= 0 || liquidationRate == nil || liquidationRate.Sign() <= 0 {
+		return Zero
+	}
+	// minLockedAmount = lockedAmount * liquidationPrice * depositRate / (collateralPrice * liquidationRate), rounded up
+	minLockedAmount := new(big.Int).Mul(lockedAmount, liquidationPrice)
+	minLockedAmount = new(big.Int).Mul(minLockedAmount, depositRate)
+	denominator := new(big.Int).Mul(collateralPrice, liquidationRate)
+	minLockedAmount = new(big.Int).Add(minLockedAmount, new(big.Int).Sub(denominator, common.Big1))
+	minLockedAmount = new(big.Int).Div(minLockedAmount, denominator)
+	if minLockedAmount.Cmp(lockedAmount) >= 0 {
+		return Zero
+	}
+	return new(big.Int).Sub(lockedAmount, minLockedAmount)
+}
@@ -459,3 +459,31 @@ func TestCalculateTotalRepayValue(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateMaxRecallAmount(t *testing.T) {
+	// deposit rate 150%, liquidation rate 110%
+	// the trade was opened at price 150 with 1000 of collateral, its liquidation price is 110
+	depositRate, liquidationRate := big.NewInt(150), big.NewInt(110)
+	lockedAmount, liquidationPrice := big.NewInt(1000), big.NewInt(110)
+	tests := []struct {
+		collateralPrice int64
+		healthFactor    int64
+		maxRecallAmount int64
+	}{
+		{100, 90, 0},
+		{150, 136, 0},
+		// half of the collateral backs the debt at the deposit rate
+		{300, 272, 500},
+		// the minimum locked amount is rounded up
+		{330, 300, 545},
+	}
+	for i, test := range tests {
+		collateralPrice := big.NewInt(test.collateralPrice)
+		if healthFactor := CalculateHealthFactor(collateralPrice, liquidationPrice); healthFactor.Cmp(big.NewInt(test.healthFactor)) != 0 {
+			t.Errorf("test %d: wrong health factor: have %v, want %d", i, healthFactor, test.healthFactor)
+		}
+		if amount := CalculateMaxRecallAmount(lockedAmount, liquidationPrice, collateralPrice, depositRate, liquidationRate); amount.Cmp(big.NewInt(test.maxRecallAmount)) != 0 {
+			t.Errorf("test %d: wrong max recall amount: have %v, want %d", i, amount, test.maxRecallAmount)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package lendingstate
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// SnapshotVersion is the version of the lending state snapshot format.
+const SnapshotVersion = 1
+
+// SnapshotHeader is the first record of a lending state snapshot. It is followed by
+// the trie nodes of the lending state (lending books, orders, trades and liquidation times).
+type SnapshotHeader struct {
+	Version     uint64
+	BlockNumber uint64
+	BlockHash   common.Hash
+	Root        common.Hash
+}
+
+// SnapshotStats summarizes the content of a lending state snapshot.
+type SnapshotStats struct {
+	LendingBooks     int
+	LendingItems     int
+	LendingTrades    int
+	LiquidationTimes int
+	Nodes            int
+}
+
+type snapshotNode struct {
+	Hash common.Hash
+	Blob []byte
+}
+
+// snapshotWalker iterates all the tries of a lending state.
+type snapshotWalker struct {
+	db    *trie.Database
+	seen  map[common.Hash]struct{}
+	emit  func(node snapshotNode) error // called once for every trie node, if set
+	stats SnapshotStats
+}
+
+func (w *snapshotWalker) walkTrie(root common.Hash, onLeaf func(blob []byte) error) error {
+	if root == EmptyRoot || common.EmptyHash(root) {
+		return nil
+	}
+	t, err := trie.New(root, w.db)
+	if err != nil {
+		return err
+	}
+	it := t.NodeIterator(nil)
+	for it.Next(true) {
+		if hash := it.Hash(); w.emit != nil && !common.EmptyHash(hash) {
+			if _, ok := w.seen[hash]; !ok {
+				w.seen[hash] = struct{}{}
+				blob, err := w.db.Node(hash)
+				if err != nil {
+					return err
+				}
+				if err := w.emit(snapshotNode{Hash: hash, Blob: blob}); err != nil {
+					return err
+				}
+				w.stats.Nodes++
+			}
+		}
+		if it.Leaf() && onLeaf != nil {
+			if err := onLeaf(it.LeafBlob()); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
+
+func (w *snapshotWalker) walkItemLists(root common.Hash, count *int) error {
+	return w.walkTrie(root, func(blob []byte) error {
+		var data itemList
+		if err := rlp.DecodeBytes(blob, &data); err != nil {
+			return err
+		}
+		if count != nil {
+			*count++
+		}
+		return w.walkTrie(data.Root, nil)
+	})
+}
+
+func (w *snapshotWalker) walk(root common.Hash) error {
+	countLeaves := func(count *int) func([]byte) error {
+		return func([]byte) error {
+			*count++
+			return nil
+		}
+	}
+	return w.walkTrie(root, func(blob []byte) error {
+		var data lendingObject
+		if err := rlp.DecodeBytes(blob, &data); err != nil {
+			return err
+		}
+		w.stats.LendingBooks++
+		if err := w.walkItemLists(data.InvestingRoot, nil); err != nil {
+			return err
+		}
+		if err := w.walkItemLists(data.BorrowingRoot, nil); err != nil {
+			return err
+		}
+		if err := w.walkItemLists(data.LiquidationTimeRoot, &w.stats.LiquidationTimes); err != nil {
+			return err
+		}
+		if err := w.walkTrie(data.LendingItemRoot, countLeaves(&w.stats.LendingItems)); err != nil {
+			return err
+		}
+		return w.walkTrie(data.LendingTradeRoot, countLeaves(&w.stats.LendingTrades))
+	})
+}
+
+// ExportSnapshot writes the lending state with the root given in the header to w.
+func ExportSnapshot(db Database, header SnapshotHeader, w io.Writer) (*SnapshotStats, error) {
+	header.Version = SnapshotVersion
+	if err := rlp.Encode(w, &header); err != nil {
+		return nil, err
+	}
+	walker := &snapshotWalker{
+		db:   db.TrieDB(),
+		seen: make(map[common.Hash]struct{}),
+		emit: func(node snapshotNode) error {
+			return rlp.Encode(w, &node)
+		},
+	}
+	if err := walker.walk(header.Root); err != nil {
+		return nil, err
+	}
+	return &walker.stats, nil
+}
+
+// ImportSnapshot reads a lending state snapshot from r, writes its trie nodes to diskdb
+// and verifies that the whole lending state can be read back.
+func ImportSnapshot(diskdb ethdb.Database, r io.Reader) (*SnapshotHeader, *SnapshotStats, error) {
+	stream := rlp.NewStream(r, 0)
+	var header SnapshotHeader
+	if err := stream.Decode(&header); err != nil {
+		return nil, nil, err
+	}
+	if header.Version != SnapshotVersion {
+		return nil, nil, fmt.Errorf("unsupported lending snapshot version %d, want %d", header.Version, SnapshotVersion)
+	}
+	nodes := 0
+	batch := diskdb.NewBatch()
+	for {
+		var node snapshotNode
+		if err := stream.Decode(&node); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("node %d: %v", nodes, err)
+		}
+		if crypto.Keccak256Hash(node.Blob) != node.Hash {
+			return nil, nil, fmt.Errorf("node %d: hash mismatch %x", nodes, node.Hash)
+		}
+		if err := batch.Put(node.Hash.Bytes(), node.Blob); err != nil {
+			return nil, nil, err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, nil, err
+			}
+			batch.Reset()
+		}
+		nodes++
+	}
+	if err := batch.Write(); err != nil {
+		return nil, nil, err
+	}
+	walker := &snapshotWalker{db: trie.NewDatabase(diskdb)}
+	if err := walker.walk(header.Root); err != nil {
+		return nil, nil, fmt.Errorf("incomplete lending snapshot: %v", err)
+	}
+	walker.stats.Nodes = nodes
+	return &header, &walker.stats, nil
+}
@@ -0,0 +1,55 @@
+package lendingstate
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestSnapshotExportImport(t *testing.T) {
+	orderBook := common.StringToHash("USDT/60")
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, stateCache)
+	statedb.SetNonce(orderBook, 3)
+	for i := uint64(1); i <= 3; i++ {
+		item := LendingItem{LendingId: i, Quantity: big.NewInt(int64(i)), Interest: big.NewInt(int64(10 * i)), Side: Investing, Signature: &Signature{V: 1}}
+		statedb.InsertLendingItem(orderBook, common.Uint64ToHash(i), item)
+		statedb.InsertTradingItem(orderBook, i, LendingTrade{TradeId: i, Amount: big.NewInt(int64(i))})
+		statedb.InsertLiquidationTime(orderBook, big.NewInt(int64(100+i)), i)
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+
+	var buf bytes.Buffer
+	exported, err := ExportSnapshot(stateCache, SnapshotHeader{BlockNumber: 1, Root: root}, &buf)
+	if err != nil {
+		t.Fatalf("failed to export lending snapshot: %v", err)
+	}
+	if exported.LendingBooks != 1 || exported.LendingItems != 3 || exported.LendingTrades != 3 || exported.LiquidationTimes != 3 {
+		t.Fatalf("unexpected export stats: %+v", exported)
+	}
+
+	diskdb := rawdb.NewMemoryDatabase()
+	header, imported, err := ImportSnapshot(diskdb, &buf)
+	if err != nil {
+		t.Fatalf("failed to import lending snapshot: %v", err)
+	}
+	if header.Root != root || header.BlockNumber != 1 || *imported != *exported {
+		t.Fatalf("import mismatch: header %+v, stats %+v, want %+v", header, imported, exported)
+	}
+	importedState, err := New(root, NewDatabase(diskdb))
+	if err != nil {
+		t.Fatalf("failed to open imported lending state: %v", err)
+	}
+	if nonce := importedState.GetNonce(orderBook); nonce != 3 {
+		t.Fatalf("nonce mismatch: have %d, want 3", nonce)
+	}
+	if trade := importedState.GetLendingTrade(orderBook, common.Uint64ToHash(2)); trade.Amount.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("trade mismatch: have %v", trade.Amount)
+	}
+}
@@ -0,0 +1,96 @@
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// The shortfall of a liquidation auction the insurance fund can't cover is socialized across the
+// investors of the open trades of the lending book: each trade is charged a haircut, recorded in
+// the book returned by GetLendingHaircutBookHash with the trade id as item id, and the investor of
+// the auction gets a claim of the same total, recorded in the book returned by
+// GetLendingBadDebtClaimBookHash with the auction id as item id. The haircuts are taken from the
+// next payments of the trades to their investors and pay the claims, the oldest first.
+
+// item types of the haircut and claim books, they are never sent by users
+const (
+	haircutItem      = "HAIRCUT"
+	badDebtClaimItem = "BAD_DEBT_CLAIM"
+)
+
+// TradeHaircut is the part of a socialized shortfall charged to the investor of a lending trade.
+type TradeHaircut struct {
+	TradeId  uint64         `json:"tradeId"`
+	Hash     common.Hash    `json:"hash"`
+	Investor common.Address `json:"investor"`
+	Amount   *big.Int       `json:"amount"`
+}
+
+// BadDebtClaim is the part of a socialized shortfall still owed to the investor of a liquidation
+// auction.
+type BadDebtClaim struct {
+	TradeId  uint64         `json:"tradeId"`
+	Hash     common.Hash    `json:"hash"`
+	Investor common.Address `json:"investor"`
+	Amount   *big.Int       `json:"amount"`
+}
+
+// getItemVolume returns the quantity of an item of a book holding plain quantities.
+func (self *LendingStateDB) getItemVolume(orderBook common.Hash, id uint64) *big.Int {
+	if !self.Exist(orderBook) {
+		return new(big.Int)
+	}
+	stateItem := self.getLendingExchange(orderBook).getLendingItem(self.db, common.BigToHash(new(big.Int).SetUint64(id)))
+	if stateItem == nil || stateItem.empty() {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(stateItem.Quantity())
+}
+
+// GetTradeHaircut returns the haircut charged to a lending trade and not paid yet.
+func (self *LendingStateDB) GetTradeHaircut(lendingBook common.Hash, tradeId uint64) *big.Int {
+	return self.getItemVolume(GetLendingHaircutBookHash(lendingBook), tradeId)
+}
+
+// AddTradeHaircut charges a haircut to a lending trade.
+func (self *LendingStateDB) AddTradeHaircut(lendingBook common.Hash, haircut TradeHaircut) {
+	amount := new(big.Int).Add(self.GetTradeHaircut(lendingBook, haircut.TradeId), haircut.Amount)
+	self.setItemVolume(GetLendingHaircutBookHash(lendingBook), LendingItem{LendingId: haircut.TradeId, Hash: haircut.Hash, UserAddress: haircut.Investor, Type: haircutItem}, amount)
+}
+
+// SetTradeHaircut sets the haircut left to pay of a lending trade, zero once it is paid.
+func (self *LendingStateDB) SetTradeHaircut(lendingBook common.Hash, tradeId uint64, amount *big.Int) {
+	self.setItemVolume(GetLendingHaircutBookHash(lendingBook), LendingItem{LendingId: tradeId, Type: haircutItem}, amount)
+}
+
+// GetTradeHaircuts returns the haircuts of the lending trades of a lending book, by trade id.
+func (self *LendingStateDB) GetTradeHaircuts(lendingBook common.Hash) []TradeHaircut {
+	haircuts := []TradeHaircut{}
+	for _, item := range self.getItems(GetLendingHaircutBookHash(lendingBook)) {
+		haircuts = append(haircuts, TradeHaircut{TradeId: item.LendingId, Hash: item.Hash, Investor: item.UserAddress, Amount: item.Quantity})
+	}
+	return haircuts
+}
+
+// AddBadDebtClaim adds a claim of the investor of a liquidation auction on the haircuts of a
+// lending book.
+func (self *LendingStateDB) AddBadDebtClaim(lendingBook common.Hash, claim BadDebtClaim) {
+	claimBook := GetLendingBadDebtClaimBookHash(lendingBook)
+	amount := new(big.Int).Add(self.getItemVolume(claimBook, claim.TradeId), claim.Amount)
+	self.setItemVolume(claimBook, LendingItem{LendingId: claim.TradeId, Hash: claim.Hash, UserAddress: claim.Investor, Type: badDebtClaimItem}, amount)
+}
+
+// SetBadDebtClaim sets the amount left to pay of a claim, zero once it is paid.
+func (self *LendingStateDB) SetBadDebtClaim(lendingBook common.Hash, tradeId uint64, amount *big.Int) {
+	self.setItemVolume(GetLendingBadDebtClaimBookHash(lendingBook), LendingItem{LendingId: tradeId, Type: badDebtClaimItem}, amount)
+}
+
+// GetBadDebtClaims returns the claims on the haircuts of a lending book, by auction id.
+func (self *LendingStateDB) GetBadDebtClaims(lendingBook common.Hash) []BadDebtClaim {
+	claims := []BadDebtClaim{}
+	for _, item := range self.getItems(GetLendingBadDebtClaimBookHash(lendingBook)) {
+		claims = append(claims, BadDebtClaim{TradeId: item.LendingId, Hash: item.Hash, Investor: item.UserAddress, Amount: item.Quantity})
+	}
+	return claims
+}
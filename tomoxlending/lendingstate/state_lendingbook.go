@@ -21,6 +21,7 @@ import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
 	"io"
 	"math/big"
 )
@@ -513,6 +514,39 @@ func (self *lendingExchangeState) CommitLiquidationTimeTrie(db Database) error {
 	return err
 }
 
+// evictEmptyStates drops from the live sets the emptied interest levels, liquidation times,
+// lending items and lending trades once committed. Their entries have already been deleted from
+// the tries, so the next access finds them absent, as a state opened at the committed root does,
+// and the trie updates of the following commits don't walk the leftovers of filled and cancelled
+// items again.
+func (self *lendingExchangeState) evictEmptyStates() {
+	for rate, orderList := range self.investingStates {
+		if _, isDirty := self.investingStatesDirty[rate]; !isDirty && orderList.empty() {
+			delete(self.investingStates, rate)
+		}
+	}
+	for rate, orderList := range self.borrowingStates {
+		if _, isDirty := self.borrowingStatesDirty[rate]; !isDirty && orderList.empty() {
+			delete(self.borrowingStates, rate)
+		}
+	}
+	for time, itemList := range self.liquidationTimeStates {
+		if _, isDirty := self.liquidationTimestatesDirty[time]; !isDirty && itemList.empty() {
+			delete(self.liquidationTimeStates, time)
+		}
+	}
+	for lendingId, lendingItem := range self.lendingItemStates {
+		if _, isDirty := self.lendingItemStatesDirty[lendingId]; !isDirty && lendingItem.empty() {
+			delete(self.lendingItemStates, lendingId)
+		}
+	}
+	for tradeId, lendingTrade := range self.lendingTradeStates {
+		if _, isDirty := self.lendingTradeStatesDirty[tradeId]; !isDirty && (lendingTrade.data.Amount == nil || lendingTrade.empty()) {
+			delete(self.lendingTradeStates, tradeId)
+		}
+	}
+}
+
 /**
   Get Trie Data
 */
@@ -566,6 +600,37 @@ func (self *lendingExchangeState) getBestBorrowingInterest(db Database) common.H
 	return interest
 }
 
+// getAllLowerLiquidationTime returns the liquidation times up to the limit. It reads the committed
+// liquidation time trie.
+func (self *lendingExchangeState) getAllLowerLiquidationTime(db Database, limit common.Hash) map[common.Hash]*liquidationTimeState {
+	result := map[common.Hash]*liquidationTimeState{}
+	it := trie.NewIterator(self.getLiquidationTimeTrie(db).NodeIterator(nil))
+	for it.Next() {
+		time := common.BytesToHash(it.Key)
+		if time.Big().Cmp(limit.Big()) > 0 {
+			break
+		}
+		obj, exist := self.liquidationTimeStates[time]
+		if !exist {
+			var data itemList
+			if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+				log.Error("Failed to decode state get all lower liquidation time trie", "time", time, "err", err)
+				return result
+			}
+			obj = newLiquidationTimeState(self.lendingBook, time, data, self.MarkLiquidationTimeDirty)
+			self.liquidationTimeStates[time] = obj
+		}
+		if obj.empty() {
+			continue
+		}
+		result[time] = obj
+	}
+	if it.Err != nil {
+		log.Error("Failed get lower liquidation time trie", "lendingBook", self.lendingBook.Hex(), "err", it.Err)
+	}
+	return result
+}
+
 func (self *lendingExchangeState) getLowestLiquidationTime(db Database) (common.Hash, *liquidationTimeState) {
 	trie := self.getLiquidationTimeTrie(db)
 	encKey, encValue, err := trie.TryGetBestLeftKeyAndValue()
@@ -606,31 +671,31 @@ func (self *lendingExchangeState) deepCopy(db *LendingStateDB, onDirty func(hash
 		stateExchanges.lendingItemTrie = db.db.CopyTrie(self.lendingItemTrie)
 	}
 	for key, value := range self.borrowingStates {
-		stateExchanges.borrowingStates[key] = value.deepCopy(db, self.MarkBorrowingDirty)
+		stateExchanges.borrowingStates[key] = value.deepCopy(db, stateExchanges.MarkBorrowingDirty)
 	}
 	for key := range self.borrowingStatesDirty {
 		stateExchanges.borrowingStatesDirty[key] = struct{}{}
 	}
 	for key, value := range self.investingStates {
-		stateExchanges.investingStates[key] = value.deepCopy(db, self.MarkInvestingDirty)
+		stateExchanges.investingStates[key] = value.deepCopy(db, stateExchanges.MarkInvestingDirty)
 	}
 	for key := range self.investingStatesDirty {
 		stateExchanges.investingStatesDirty[key] = struct{}{}
 	}
 	for key, value := range self.lendingItemStates {
-		stateExchanges.lendingItemStates[key] = value.deepCopy(self.MarkLendingItemDirty)
+		stateExchanges.lendingItemStates[key] = value.deepCopy(stateExchanges.MarkLendingItemDirty)
 	}
 	for orderId := range self.lendingItemStatesDirty {
 		stateExchanges.lendingItemStatesDirty[orderId] = struct{}{}
 	}
 	for key, value := range self.lendingTradeStates {
-		stateExchanges.lendingTradeStates[key] = value.deepCopy(self.MarkLendingTradeDirty)
+		stateExchanges.lendingTradeStates[key] = value.deepCopy(stateExchanges.MarkLendingTradeDirty)
 	}
 	for orderId := range self.lendingTradeStatesDirty {
 		stateExchanges.lendingTradeStatesDirty[orderId] = struct{}{}
 	}
 	for time, orderList := range self.liquidationTimeStates {
-		stateExchanges.liquidationTimeStates[time] = orderList.deepCopy(db, self.MarkLiquidationTimeDirty)
+		stateExchanges.liquidationTimeStates[time] = orderList.deepCopy(db, stateExchanges.MarkLiquidationTimeDirty)
 	}
 	for time := range self.liquidationTimestatesDirty {
 		stateExchanges.liquidationTimestatesDirty[time] = struct{}{}
@@ -674,6 +739,20 @@ func (self *lendingExchangeState) removeBorrowingOrderList(db Database, stateOrd
 	self.setError(self.borrowingTrie.TryDelete(stateOrderList.key[:]))
 }
 
+// restoreOrderList puts back into its interest trie an order list removed when it was emptied.
+func (self *lendingExchangeState) restoreOrderList(db Database, side string, stateOrderList *itemListState) {
+	data, err := rlp.EncodeToBytes(stateOrderList)
+	if err != nil {
+		panic(fmt.Errorf("can't encode order list object at %x: %v", stateOrderList.key[:], err))
+	}
+	switch side {
+	case Investing:
+		self.setError(self.getInvestingTrie(db).TryUpdate(stateOrderList.key[:], data))
+	case Borrowing:
+		self.setError(self.getBorrowingTrie(db).TryUpdate(stateOrderList.key[:], data))
+	}
+}
+
 func (self *lendingExchangeState) createInvestingOrderList(db Database, price common.Hash) (newobj *itemListState) {
 	newobj = newItemListState(self.lendingBook, price, itemList{Volume: Zero}, self.MarkInvestingDirty)
 	self.investingStates[price] = newobj
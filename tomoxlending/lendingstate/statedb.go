@@ -54,6 +54,9 @@ type LendingStateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// State objects accessed, only recorded after RecordAccesses.
+	accessed map[common.Hash]struct{}
+
 	lock sync.Mutex
 }
 
@@ -213,6 +216,20 @@ func (self *LendingStateDB) UpdateCollateralLockedAmount(orderBook common.Hash,
 	})
 	stateLendingTrade.SetCollateralLockedAmount(amount)
 }
+func (self *LendingStateDB) UpdateLendingTradeAmount(orderBook common.Hash, tradeId uint64, amount *big.Int) {
+	tradeIdHash := common.Uint64ToHash(tradeId)
+	stateExchange := self.getLendingExchange(orderBook)
+	if stateExchange == nil {
+		stateExchange = self.createLendingExchangeObject(orderBook)
+	}
+	stateLendingTrade := stateExchange.getLendingTrade(self.db, tradeIdHash)
+	self.journal = append(self.journal, lendingTradeAmountChange{
+		orderBook: orderBook,
+		tradeId:   tradeIdHash,
+		prev:      stateLendingTrade.data.Amount,
+	})
+	stateLendingTrade.SetAmount(amount)
+}
 func (self *LendingStateDB) GetLendingOrder(orderBook common.Hash, orderId common.Hash) LendingItem {
 	stateObject := self.GetOrNewLendingExchangeObject(orderBook)
 	if stateObject == nil {
@@ -371,6 +388,12 @@ func (self *LendingStateDB) GetBestBorrowRate(orderBook common.Hash) (*big.Int,
 	return Zero, Zero
 }
 
+// GetBestLendingIdAndAmount returns the item matched first among the items of an interest level,
+// and its amount. This is the tie-breaking rule between items of equal interest, which consensus
+// depends on: the items of a level are keyed by their LendingId, big-endian, in the trie of the
+// level, so the best left key is the lowest LendingId. LendingIds are assigned in increasing order
+// from the nonce of the lending book when an item enters the book, so items of equal interest are
+// matched in the order they entered the book, whatever the order they reached the node in.
 func (self *LendingStateDB) GetBestLendingIdAndAmount(orderBook common.Hash, price *big.Int, side string) (common.Hash, *big.Int, error) {
 	stateObject := self.GetOrNewLendingExchangeObject(orderBook)
 	if stateObject != nil {
@@ -409,6 +432,7 @@ func (self *LendingStateDB) updateLendingExchange(stateObject *lendingExchangeSt
 
 // Retrieve a state object given my the address. Returns nil if not found.
 func (self *LendingStateDB) getLendingExchange(addr common.Hash) (stateObject *lendingExchangeState) {
+	self.recordAccess(addr)
 	// Prefer 'live' objects.
 	if obj := self.lendingExchangeStates[addr]; obj != nil {
 		return obj
@@ -453,6 +477,7 @@ func (self *LendingStateDB) MarkLendingExchangeObjectDirty(addr common.Hash) {
 // createStateOrderListObject creates a new state object. If there is an existing tradeId with
 // the given address, it is overwritten and returned as the second return value.
 func (self *LendingStateDB) createLendingExchangeObject(hash common.Hash) (newobj *lendingExchangeState) {
+	self.recordAccess(hash)
 	newobj = newStateExchanges(self, hash, lendingObject{}, self.MarkLendingExchangeObjectDirty)
 	newobj.setNonce(0) // sets the object to dirty
 	self.setLendingExchangeObject(newobj)
@@ -567,6 +592,7 @@ func (s *LendingStateDB) Commit() (root common.Hash, err error) {
 			if err := stateObject.CommitLiquidationTimeTrie(s.db); err != nil {
 				return EmptyHash, err
 			}
+			stateObject.evictEmptyStates()
 			// Update the object in the main tradeId trie.
 			s.updateLendingExchange(stateObject)
 			delete(s.lendingExchangeStatesDirty, addr)
@@ -635,6 +661,23 @@ func (self *LendingStateDB) RemoveLiquidationTime(lendingBook common.Hash, trade
 	return nil
 }
 
+// GetAllLowerLiquidationTimeData returns the ids of the lending trades of a lending book by
+// liquidation time, for the liquidation times up to the given time. The state must be left
+// unchanged since it was opened.
+func (self *LendingStateDB) GetAllLowerLiquidationTimeData(lendingBook common.Hash, time *big.Int) map[uint64][]common.Hash {
+	result := map[uint64][]common.Hash{}
+	lendingExchangeState := self.getLendingExchange(lendingBook)
+	if lendingExchangeState == nil {
+		return result
+	}
+	for timeHash, liquidationState := range lendingExchangeState.getAllLowerLiquidationTime(self.db, common.BigToHash(time)) {
+		if tradeIds := liquidationState.getAllTradeIds(self.db); len(tradeIds) > 0 {
+			result[timeHash.Big().Uint64()] = tradeIds
+		}
+	}
+	return result
+}
+
 func (self *LendingStateDB) GetLowestLiquidationTime(lendingBook common.Hash, time *big.Int) (*big.Int, []common.Hash) {
 	liquidationData := []common.Hash{}
 	lendingExchangeState := self.getLendingExchange(lendingBook)
@@ -666,3 +709,47 @@ func (self *LendingStateDB) CancelLendingTrade(orderBook common.Hash, tradeId ui
 	lendingTrade.SetAmount(Zero)
 	return nil
 }
+
+// topUpReserveId is the id of the single item of a top-up reserve book, its quantity is the reserve.
+var topUpReserveId = common.BigToHash(common.Big1)
+
+// GetTopUpReserve returns the amount of collateral token which can still be pulled from the balance
+// of the user to top up its lending trades automatically.
+func (self *LendingStateDB) GetTopUpReserve(user common.Address, collateralToken common.Address) *big.Int {
+	reserveBook := GetLendingTopUpReserveHash(user, collateralToken)
+	if !self.Exist(reserveBook) {
+		return new(big.Int)
+	}
+	stateReserveItem := self.getLendingExchange(reserveBook).getLendingItem(self.db, topUpReserveId)
+	if stateReserveItem == nil || stateReserveItem.empty() {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(stateReserveItem.Quantity())
+}
+
+// SetTopUpReserve sets the top-up reserve of the user for a collateral token, zero disables it.
+func (self *LendingStateDB) SetTopUpReserve(user common.Address, collateralToken common.Address, amount *big.Int) {
+	reserveBook := GetLendingTopUpReserveHash(user, collateralToken)
+	stateReserveBook := self.GetOrNewLendingExchangeObject(reserveBook)
+	stateReserveItem := stateReserveBook.getLendingItem(self.db, topUpReserveId)
+	if stateReserveItem == nil {
+		stateReserveItem = stateReserveBook.createLendingItem(self.db, topUpReserveId, LendingItem{
+			Quantity:        new(big.Int),
+			UserAddress:     user,
+			CollateralToken: collateralToken,
+			Type:            TopUpReserve,
+			Signature:       &Signature{}, // a nil signature can't be decoded back from the trie
+			LendingId:       topUpReserveId.Big().Uint64(),
+		})
+	}
+	prev := new(big.Int)
+	if stateReserveItem.Quantity() != nil {
+		prev.Set(stateReserveItem.Quantity())
+	}
+	self.journal = append(self.journal, itemVolumeChange{
+		orderBook: reserveBook,
+		orderId:   topUpReserveId,
+		prev:      prev,
+	})
+	stateReserveItem.setVolume(new(big.Int).Set(amount))
+}
@@ -258,3 +258,245 @@ func TestDumpStates(t *testing.T) {
 	fmt.Println(statedb.DumpBorrowingTrie(orderBook))
 	db.Close()
 }
+
+func TestRevertLendingTradeAmount(t *testing.T) {
+	lendingBook := common.StringToHash("USDT/86400")
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.InsertTradingItem(lendingBook, 1, LendingTrade{TradeId: 1, Amount: big.NewInt(100)})
+
+	snap := statedb.Snapshot()
+	statedb.UpdateLendingTradeAmount(lendingBook, 1, big.NewInt(40))
+	if amount := statedb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)).Amount; amount.Cmp(big.NewInt(40)) != 0 {
+		t.Fatalf("wrong trade amount after update: have %v, want 40", amount)
+	}
+	statedb.RevertToSnapshot(snap)
+	if amount := statedb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)).Amount; amount.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("wrong trade amount after revert: have %v, want 100", amount)
+	}
+}
+
+func TestTopUpReserve(t *testing.T) {
+	user := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	collateral := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, db)
+	if reserve := statedb.GetTopUpReserve(user, collateral); reserve.Sign() != 0 {
+		t.Fatalf("wrong initial reserve: have %v, want 0", reserve)
+	}
+	statedb.SetTopUpReserve(user, collateral, big.NewInt(100))
+
+	snap := statedb.Snapshot()
+	statedb.SetTopUpReserve(user, collateral, big.NewInt(40))
+	if reserve := statedb.GetTopUpReserve(user, collateral); reserve.Cmp(big.NewInt(40)) != 0 {
+		t.Fatalf("wrong reserve after update: have %v, want 40", reserve)
+	}
+	statedb.RevertToSnapshot(snap)
+	if reserve := statedb.GetTopUpReserve(user, collateral); reserve.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("wrong reserve after revert: have %v, want 100", reserve)
+	}
+
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	statedb, _ = New(root, db)
+	if reserve := statedb.GetTopUpReserve(user, collateral); reserve.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("wrong reserve after commit: have %v, want 100", reserve)
+	}
+	if reserve := statedb.GetTopUpReserve(collateral, user); reserve.Sign() != 0 {
+		t.Fatalf("wrong reserve of another user: have %v, want 0", reserve)
+	}
+}
+
+func TestTradeCollaterals(t *testing.T) {
+	lendingBook := common.StringToHash("lendingBook")
+	tokenA := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	tokenB := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, db)
+	if trades := statedb.GetMultiCollateralTrades(lendingBook); len(trades) != 0 {
+		t.Fatalf("wrong initial multi-collateral trades: have %v, want none", trades)
+	}
+	statedb.SetTradeLiquidationThreshold(lendingBook, 7, big.NewInt(1000))
+	statedb.SetTradeCollateral(lendingBook, 7, tokenA, big.NewInt(10))
+
+	snap := statedb.Snapshot()
+	statedb.SetTradeCollateral(lendingBook, 7, tokenB, big.NewInt(20))
+	statedb.SetTradeCollateral(lendingBook, 7, tokenA, big.NewInt(15))
+	if collaterals := statedb.GetTradeCollaterals(lendingBook, 7); len(collaterals) != 2 || collaterals[0].Token != tokenA || collaterals[0].Amount.Cmp(big.NewInt(15)) != 0 {
+		t.Fatalf("wrong collaterals after update: %v", collaterals)
+	}
+	statedb.RevertToSnapshot(snap)
+	if collaterals := statedb.GetTradeCollaterals(lendingBook, 7); len(collaterals) != 1 || collaterals[0].Amount.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("wrong collaterals after revert: %v", collaterals)
+	}
+
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	statedb, _ = New(root, db)
+	if threshold := statedb.GetTradeLiquidationThreshold(lendingBook, 7); threshold.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("wrong threshold after commit: have %v, want 1000", threshold)
+	}
+	if trades := statedb.GetMultiCollateralTrades(lendingBook); len(trades) != 1 || trades[0] != 7 {
+		t.Fatalf("wrong multi-collateral trades after commit: %v", trades)
+	}
+
+	statedb.ClearTradeCollaterals(lendingBook, 7)
+	root, err = statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	statedb, _ = New(root, db)
+	if collaterals := statedb.GetTradeCollaterals(lendingBook, 7); len(collaterals) != 0 {
+		t.Fatalf("wrong collaterals after clear: %v", collaterals)
+	}
+	if trades := statedb.GetMultiCollateralTrades(lendingBook); len(trades) != 0 {
+		t.Fatalf("wrong multi-collateral trades after clear: %v", trades)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	lendingBook := GetLendingOrderBookHash(common.HexToAddress("0x10"), 86400)
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, db)
+
+	// disabled breakers don't track the trades
+	if statedb.RecordCircuitBreakerTrade(lendingBook, 1, 10, big.NewInt(100)) {
+		t.Fatal("disabled circuit breaker tripped")
+	}
+	// 10% threshold, 5 blocks pause
+	statedb.SetCircuitBreaker(lendingBook, big.NewInt(1000), big.NewInt(5))
+	if statedb.RecordCircuitBreakerTrade(lendingBook, 2, 10, big.NewInt(100)) {
+		t.Fatal("circuit breaker tripped without reference")
+	}
+	// the medium interest of block 3 is (10*100 + 12*100) / 200 = 11
+	if statedb.RecordCircuitBreakerTrade(lendingBook, 3, 10, big.NewInt(100)) || statedb.RecordCircuitBreakerTrade(lendingBook, 3, 12, big.NewInt(100)) {
+		t.Fatal("circuit breaker tripped by a move within the threshold")
+	}
+	snap := statedb.Snapshot()
+	if !statedb.RecordCircuitBreakerTrade(lendingBook, 4, 13, big.NewInt(100)) {
+		t.Fatal("circuit breaker not tripped by a move beyond the threshold")
+	}
+	breaker := statedb.GetCircuitBreaker(lendingBook)
+	if breaker != (LendingCircuitBreaker{Threshold: 1000, PauseBlocks: 5, Reference: 11, PausedUntil: 9}) {
+		t.Fatalf("wrong circuit breaker: %+v", breaker)
+	}
+	if !statedb.IsLendingBookPaused(lendingBook, 9) || statedb.IsLendingBookPaused(lendingBook, 10) {
+		t.Fatal("wrong pause")
+	}
+	statedb.RevertToSnapshot(snap)
+	if statedb.IsLendingBookPaused(lendingBook, 4) {
+		t.Fatal("pause not reverted")
+	}
+
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	statedb, _ = New(root, db)
+	if breaker := statedb.GetCircuitBreaker(lendingBook); breaker != (LendingCircuitBreaker{Threshold: 1000, PauseBlocks: 5, Reference: 10}) {
+		t.Fatalf("wrong circuit breaker after commit: %+v", breaker)
+	}
+}
+
+func TestEvictEmptyStates(t *testing.T) {
+	var (
+		lendingBook = common.StringToHash("USDT/86400")
+		db          = NewDatabase(rawdb.NewMemoryDatabase())
+		items       = []LendingItem{
+			{LendingId: 1, Quantity: big.NewInt(100), Interest: big.NewInt(10), Side: Investing, UserAddress: common.HexToAddress("0x1"), Signature: &Signature{}},
+			{LendingId: 2, Quantity: big.NewInt(200), Interest: big.NewInt(10), Side: Investing, UserAddress: common.HexToAddress("0x2"), Signature: &Signature{}},
+			{LendingId: 3, Quantity: big.NewInt(300), Interest: big.NewInt(20), Side: Investing, UserAddress: common.HexToAddress("0x3"), Signature: &Signature{}},
+		}
+	)
+	statedb, _ := New(EmptyRoot, db)
+	for _, item := range items {
+		statedb.InsertLendingItem(lendingBook, common.Uint64ToHash(item.LendingId), item)
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	statedb, _ = New(root, db)
+	// fill the only item at 20% and cancel one of the items at 10%
+	if err := statedb.SubAmountLendingItem(lendingBook, common.Uint64ToHash(3), big.NewInt(20), big.NewInt(300), Investing); err != nil {
+		t.Fatalf("failed to fill: %v", err)
+	}
+	if err := statedb.CancelLendingOrder(lendingBook, &items[0]); err != nil {
+		t.Fatalf("failed to cancel: %v", err)
+	}
+	if root, err = statedb.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	exchange := statedb.getLendingExchange(lendingBook)
+	if _, ok := exchange.investingStates[common.BigToHash(big.NewInt(20))]; ok {
+		t.Error("emptied interest level still live")
+	}
+	for lendingId, item := range exchange.lendingItemStates {
+		if item.empty() {
+			t.Errorf("emptied lending item %x still live", lendingId)
+		}
+	}
+
+	// the committed book is the one of the remaining item alone
+	compact, _ := New(EmptyRoot, db)
+	compact.InsertLendingItem(lendingBook, common.Uint64ToHash(2), items[1])
+	compact.SetNonce(lendingBook, statedb.GetNonce(lendingBook))
+	if compactRoot := compact.IntermediateRoot(); compactRoot != root {
+		t.Errorf("wrong root: have %x, want %x", root, compactRoot)
+	}
+	if item := statedb.GetLendingOrder(lendingBook, common.Uint64ToHash(3)); item != EmptyLendingOrder {
+		t.Errorf("filled item still found: %v", ToJSON(item))
+	}
+
+	// the evicted level is created again by a new item
+	statedb.InsertLendingItem(lendingBook, common.Uint64ToHash(4), LendingItem{LendingId: 4, Quantity: big.NewInt(50), Interest: big.NewInt(5), Side: Investing, Signature: &Signature{}})
+	if best, volume := statedb.GetBestInvestingRate(lendingBook); best.Int64() != 5 || volume.Int64() != 50 {
+		t.Errorf("wrong best investing rate: have %v %v, want 5 50", best, volume)
+	}
+}
+
+func TestConsumeLendingHash(t *testing.T) {
+	hash := common.HexToHash("0x1")
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, db)
+	if number := statedb.GetLendingHashConsumedAt(hash); number != 0 {
+		t.Fatalf("hash consumed before being used: block %d", number)
+	}
+	snap := statedb.Snapshot()
+	statedb.ConsumeLendingHash(hash, 10)
+	if number := statedb.GetLendingHashConsumedAt(hash); number != 10 {
+		t.Fatalf("wrong consuming block: have %d, want 10", number)
+	}
+	statedb.RevertToSnapshot(snap)
+	if number := statedb.GetLendingHashConsumedAt(hash); number != 0 {
+		t.Fatalf("hash consumed after revert: block %d", number)
+	}
+	statedb.ConsumeLendingHash(hash, 12)
+
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	statedb, _ = New(root, db)
+	if number := statedb.GetLendingHashConsumedAt(hash); number != 12 {
+		t.Fatalf("wrong consuming block after commit: have %d, want 12", number)
+	}
+	if number := statedb.GetLendingHashConsumedAt(common.HexToHash("0x2")); number != 0 {
+		t.Fatalf("unused hash consumed: block %d", number)
+	}
+}
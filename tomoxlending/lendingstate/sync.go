@@ -0,0 +1,42 @@
+package lendingstate
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// NewLendingStateSync creates a download scheduler of the lending state trie, with the investing,
+// borrowing, lending item, lending trade and liquidation time tries of every lending book.
+func NewLendingStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	var syncer *trie.Sync
+	// addSubTrie skips the roots of the tries which have never been created
+	addSubTrie := func(root common.Hash, parent common.Hash, callback trie.LeafCallback) {
+		if root != EmptyHash {
+			syncer.AddSubTrie(root, 64, parent, callback)
+		}
+	}
+	itemListCallback := func(leaf []byte, parent common.Hash) error {
+		var list itemList
+		if err := rlp.DecodeBytes(leaf, &list); err != nil {
+			return nil
+		}
+		addSubTrie(list.Root, parent, nil)
+		return nil
+	}
+	callback := func(leaf []byte, parent common.Hash) error {
+		var exchange lendingObject
+		if err := rlp.DecodeBytes(leaf, &exchange); err != nil {
+			return nil
+		}
+		addSubTrie(exchange.InvestingRoot, parent, itemListCallback)
+		addSubTrie(exchange.BorrowingRoot, parent, itemListCallback)
+		addSubTrie(exchange.LendingItemRoot, parent, nil)
+		addSubTrie(exchange.LendingTradeRoot, parent, nil)
+		addSubTrie(exchange.LiquidationTimeRoot, parent, itemListCallback)
+		return nil
+	}
+	syncer = trie.NewSync(root, database, callback, bloom)
+	return syncer
+}
@@ -16,6 +16,7 @@ const (
 	TradeStatusOpen       = "OPEN"
 	TradeStatusClosed     = "CLOSED"
 	TradeStatusLiquidated = "LIQUIDATED"
+	TradeStatusAuction    = "AUCTION" // the collateral is being sold by a liquidation auction
 )
 
 type LendingTrade struct {
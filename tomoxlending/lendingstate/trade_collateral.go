@@ -0,0 +1,149 @@
+package lendingstate
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// The extra collaterals of a multi-collateral lending trade are the items of its collateral book
+// (see GetLendingTradeCollateralBookHash). The item with id tradeThresholdId holds the liquidation
+// threshold of the trade: the liquidation price times the locked amount of its main collateral before
+// any extra collateral was added. The other items hold one extra collateral token each.
+//
+// Multi-collateral trades are indexed by trade id in the multi-collateral book of their lending book.
+
+// tradeThresholdId is the id of the liquidation threshold item of a collateral book.
+const tradeThresholdId = uint64(1)
+
+// TradeCollateral is an extra collateral of a multi-collateral lending trade.
+type TradeCollateral struct {
+	Token  common.Address `json:"token"`
+	Amount *big.Int       `json:"amount"`
+}
+
+// setItemVolume sets the quantity of an item of a book holding plain quantities, creating the item
+// from the template if it does not exist yet.
+func (self *LendingStateDB) setItemVolume(orderBook common.Hash, template LendingItem, amount *big.Int) {
+	orderId := common.BigToHash(new(big.Int).SetUint64(template.LendingId))
+	stateOrderBook := self.GetOrNewLendingExchangeObject(orderBook)
+	stateItem := stateOrderBook.getLendingItem(self.db, orderId)
+	if stateItem == nil {
+		template.Quantity = new(big.Int)
+		template.Signature = &Signature{} // a nil signature can't be decoded back from the trie
+		stateItem = stateOrderBook.createLendingItem(self.db, orderId, template)
+	}
+	prev := new(big.Int)
+	if stateItem.Quantity() != nil {
+		prev.Set(stateItem.Quantity())
+	}
+	self.journal = append(self.journal, itemVolumeChange{
+		orderBook: orderBook,
+		orderId:   orderId,
+		prev:      prev,
+	})
+	stateItem.setVolume(new(big.Int).Set(amount))
+}
+
+// getItems returns the items with a positive quantity of a book holding plain quantities, by id.
+func (self *LendingStateDB) getItems(orderBook common.Hash) []LendingItem {
+	if !self.Exist(orderBook) {
+		return nil
+	}
+	dump, err := self.DumpLendingOrderTrie(orderBook)
+	if err != nil {
+		return nil
+	}
+	items := make([]LendingItem, 0, len(dump))
+	for _, item := range dump {
+		if item.Quantity != nil && item.Quantity.Sign() > 0 {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LendingId < items[j].LendingId
+	})
+	return items
+}
+
+// GetTradeLiquidationThreshold returns the liquidation threshold of a multi-collateral lending trade,
+// zero if the trade has a single collateral.
+func (self *LendingStateDB) GetTradeLiquidationThreshold(lendingBook common.Hash, tradeId uint64) *big.Int {
+	collateralBook := GetLendingTradeCollateralBookHash(lendingBook, tradeId)
+	if !self.Exist(collateralBook) {
+		return new(big.Int)
+	}
+	stateItem := self.getLendingExchange(collateralBook).getLendingItem(self.db, common.BigToHash(new(big.Int).SetUint64(tradeThresholdId)))
+	if stateItem == nil || stateItem.empty() {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(stateItem.Quantity())
+}
+
+// SetTradeLiquidationThreshold sets the liquidation threshold of a lending trade and indexes it as a
+// multi-collateral trade. A zero threshold removes the trade from the index.
+func (self *LendingStateDB) SetTradeLiquidationThreshold(lendingBook common.Hash, tradeId uint64, threshold *big.Int) {
+	collateralBook := GetLendingTradeCollateralBookHash(lendingBook, tradeId)
+	self.setItemVolume(collateralBook, LendingItem{LendingId: tradeThresholdId}, threshold)
+	indexed := new(big.Int)
+	if threshold.Sign() > 0 {
+		indexed.SetUint64(1)
+	}
+	self.setItemVolume(GetLendingMultiCollateralBookHash(lendingBook), LendingItem{LendingId: tradeId}, indexed)
+}
+
+// GetTradeCollaterals returns the extra collaterals of a lending trade in the order they were added.
+func (self *LendingStateDB) GetTradeCollaterals(lendingBook common.Hash, tradeId uint64) []TradeCollateral {
+	collaterals := []TradeCollateral{}
+	for _, item := range self.getItems(GetLendingTradeCollateralBookHash(lendingBook, tradeId)) {
+		if item.LendingId == tradeThresholdId {
+			continue
+		}
+		collaterals = append(collaterals, TradeCollateral{Token: item.CollateralToken, Amount: item.Quantity})
+	}
+	return collaterals
+}
+
+// SetTradeCollateral sets the amount of an extra collateral token of a lending trade.
+func (self *LendingStateDB) SetTradeCollateral(lendingBook common.Hash, tradeId uint64, token common.Address, amount *big.Int) {
+	collateralBook := GetLendingTradeCollateralBookHash(lendingBook, tradeId)
+	if self.Exist(collateralBook) {
+		if dump, err := self.DumpLendingOrderTrie(collateralBook); err == nil {
+			for _, item := range dump {
+				if item.LendingId != tradeThresholdId && item.CollateralToken == token {
+					self.setItemVolume(collateralBook, item, amount)
+					return
+				}
+			}
+		}
+	}
+	// ids of the collateral items follow the threshold item
+	id := self.GetNonce(collateralBook)
+	if id < tradeThresholdId {
+		id = tradeThresholdId
+	}
+	id++
+	self.SetNonce(collateralBook, id)
+	self.setItemVolume(collateralBook, LendingItem{LendingId: id, CollateralToken: token}, amount)
+}
+
+// GetMultiCollateralTrades returns the ids of the multi-collateral trades of a lending book.
+func (self *LendingStateDB) GetMultiCollateralTrades(lendingBook common.Hash) []uint64 {
+	tradeIds := []uint64{}
+	for _, item := range self.getItems(GetLendingMultiCollateralBookHash(lendingBook)) {
+		tradeIds = append(tradeIds, item.LendingId)
+	}
+	return tradeIds
+}
+
+// ClearTradeCollaterals removes the extra collaterals and the liquidation threshold of a lending trade.
+func (self *LendingStateDB) ClearTradeCollaterals(lendingBook common.Hash, tradeId uint64) {
+	collateralBook := GetLendingTradeCollateralBookHash(lendingBook, tradeId)
+	for _, item := range self.getItems(collateralBook) {
+		if item.LendingId != tradeThresholdId {
+			self.setItemVolume(collateralBook, item, new(big.Int))
+		}
+	}
+	self.SetTradeLiquidationThreshold(lendingBook, tradeId, new(big.Int))
+}
@@ -0,0 +1,144 @@
+package lendingstate
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// Changes of a lending trade between two lending states.
+const (
+	TradeChangeOpen   = "OPEN"   // the trade is new
+	TradeChangeUpdate = "UPDATE" // the trade was topped up, partially repaid, recalled...
+	TradeChangeClose  = "CLOSE"  // the trade was repaid or liquidated, given as last found
+)
+
+// LendingTradeChange is a lending trade which differs between two lending states.
+type LendingTradeChange struct {
+	LendingBook common.Hash
+	Change      string
+	Trade       LendingTrade
+}
+
+// lendingTradeRoots returns the lending trade trie roots of the lending books of a lending state.
+func lendingTradeRoots(db Database, root common.Hash) (map[common.Hash]common.Hash, error) {
+	roots := make(map[common.Hash]common.Hash)
+	if root == EmptyRoot || common.EmptyHash(root) {
+		return roots, nil
+	}
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		var data lendingObject
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return nil, err
+		}
+		roots[common.BytesToHash(it.Key)] = data.LendingTradeRoot
+	}
+	return roots, it.Err
+}
+
+// DiffLendingTrades returns the lending trades of a lending state which differ from the ones of
+// its parent state, by lending book then trade id. Only the lending books whose trade trie
+// changed are walked, and only their changed nodes.
+func DiffLendingTrades(db Database, parentRoot, root common.Hash) ([]*LendingTradeChange, error) {
+	parentRoots, err := lendingTradeRoots(db, parentRoot)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := lendingTradeRoots(db, root)
+	if err != nil {
+		return nil, err
+	}
+	books := make([]common.Hash, 0, len(roots)+len(parentRoots))
+	for book, tradeRoot := range roots {
+		if parentRoots[book] != tradeRoot {
+			books = append(books, book)
+		}
+	}
+	for book := range parentRoots {
+		if _, ok := roots[book]; !ok {
+			books = append(books, book)
+		}
+	}
+	sort.Slice(books, func(i, j int) bool {
+		return bytes.Compare(books[i][:], books[j][:]) < 0
+	})
+	var changes []*LendingTradeChange
+	for _, book := range books {
+		bookChanges, err := diffBookTrades(db, book, parentRoots[book], roots[book])
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, bookChanges...)
+	}
+	return changes, nil
+}
+
+// diffBookTrades returns the lending trades of a lending book which differ between two trade
+// tries, by trade id.
+func diffBookTrades(db Database, book common.Hash, parentRoot, root common.Hash) ([]*LendingTradeChange, error) {
+	openTrie := func(root common.Hash) (Trie, error) {
+		if common.EmptyHash(root) {
+			root = EmptyRoot
+		}
+		return db.OpenStorageTrie(book, root)
+	}
+	parent, err := openTrie(parentRoot)
+	if err != nil {
+		return nil, err
+	}
+	current, err := openTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	decode := func(blob []byte) (LendingTrade, error) {
+		var trade LendingTrade
+		err := rlp.DecodeBytes(blob, &trade)
+		return trade, err
+	}
+	var changes []*LendingTradeChange
+	// the trades of the current trie not found as such in the parent one are new or updated
+	diff, _ := trie.NewDifferenceIterator(parent.NodeIterator(nil), current.NodeIterator(nil))
+	it := trie.NewIterator(diff)
+	for it.Next() {
+		trade, err := decode(it.Value)
+		if err != nil {
+			return nil, err
+		}
+		change := TradeChangeOpen
+		if blob, _ := parent.TryGet(it.Key); len(blob) > 0 {
+			change = TradeChangeUpdate
+		}
+		changes = append(changes, &LendingTradeChange{LendingBook: book, Change: change, Trade: trade})
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	// the trades of the parent trie missing from the current one are closed
+	diff, _ = trie.NewDifferenceIterator(current.NodeIterator(nil), parent.NodeIterator(nil))
+	it = trie.NewIterator(diff)
+	for it.Next() {
+		if blob, _ := current.TryGet(it.Key); len(blob) > 0 {
+			continue
+		}
+		trade, err := decode(it.Value)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &LendingTradeChange{LendingBook: book, Change: TradeChangeClose, Trade: trade})
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Trade.TradeId < changes[j].Trade.TradeId
+	})
+	return changes, nil
+}
@@ -0,0 +1,77 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestDiffLendingTrades(t *testing.T) {
+	var (
+		db    = NewDatabase(rawdb.NewMemoryDatabase())
+		book  = common.HexToHash("0x1")
+		other = common.HexToHash("0x2")
+	)
+	statedb, _ := New(EmptyRoot, db)
+	statedb.InsertTradingItem(book, 1, LendingTrade{TradeId: 1, Amount: big.NewInt(100)})
+	statedb.InsertTradingItem(book, 2, LendingTrade{TradeId: 2, Amount: big.NewInt(200)})
+	statedb.InsertTradingItem(other, 3, LendingTrade{TradeId: 3, Amount: big.NewInt(300)})
+	parentRoot, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	changes, err := DiffLendingTrades(db, EmptyRoot, parentRoot)
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	if len(changes) != 3 || changes[0].Trade.TradeId != 1 || changes[2].LendingBook != other {
+		t.Fatalf("wrong changes from the empty state: %v", ToJSON(changes))
+	}
+	for _, change := range changes {
+		if change.Change != TradeChangeOpen {
+			t.Errorf("trade %d: wrong change %s", change.Trade.TradeId, change.Change)
+		}
+	}
+
+	// repay the first trade, top up the second one and open a fourth one, the other book is unchanged
+	statedb, _ = New(parentRoot, db)
+	if err := statedb.CancelLendingTrade(book, 1); err != nil {
+		t.Fatalf("failed to close the trade: %v", err)
+	}
+	statedb.UpdateCollateralLockedAmount(book, 2, big.NewInt(50))
+	statedb.InsertTradingItem(book, 4, LendingTrade{TradeId: 4, Amount: big.NewInt(400)})
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	changes, err = DiffLendingTrades(db, parentRoot, root)
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	want := []struct {
+		tradeId uint64
+		change  string
+		amount  int64
+	}{
+		{1, TradeChangeClose, 100},
+		{2, TradeChangeUpdate, 200},
+		{4, TradeChangeOpen, 400},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("wrong changes: have %d, want %d: %v", len(changes), len(want), ToJSON(changes))
+	}
+	for i, w := range want {
+		change := changes[i]
+		if change.LendingBook != book || change.Trade.TradeId != w.tradeId || change.Change != w.change || change.Trade.Amount.Int64() != w.amount {
+			t.Errorf("change %d: wrong change %v", i, ToJSON(change))
+		}
+	}
+	if locked := changes[1].Trade.CollateralLockedAmount; locked == nil || locked.Int64() != 50 {
+		t.Errorf("wrong updated trade: %v", ToJSON(changes[1]))
+	}
+	if changes, _ := DiffLendingTrades(db, root, root); len(changes) != 0 {
+		t.Errorf("changes of an unchanged state: %v", ToJSON(changes))
+	}
+}
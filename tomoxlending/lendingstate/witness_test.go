@@ -0,0 +1,87 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestWitnessDatabase(t *testing.T) {
+	var (
+		user     = common.HexToAddress("0x01")
+		book     = GetLendingOrderBookHash(common.HexToAddress("0x10"), 60)
+		other    = GetLendingOrderBookHash(common.HexToAddress("0x11"), 60)
+		diskdb   = rawdb.NewMemoryDatabase()
+		db       = NewDatabase(diskdb)
+		state, _ = New(common.Hash{}, db)
+	)
+	newItem := func(id uint64, side string) LendingItem {
+		return LendingItem{LendingId: id, Quantity: big.NewInt(int64(id)), Interest: big.NewInt(int64(id % 5)), Side: side, UserAddress: user, Signature: &Signature{}}
+	}
+	for id := uint64(1); id <= 40; id++ {
+		side := Investing
+		if id%2 == 0 {
+			side = Borrowing
+		}
+		state.InsertLendingItem(book, common.Uint64ToHash(id), newItem(id, side))
+		state.InsertLendingItem(other, common.Uint64ToHash(id), newItem(id, side))
+		state.InsertTradingItem(book, id, LendingTrade{TradeId: id, Amount: big.NewInt(int64(id)), LiquidationTime: 1000 + id})
+		state.InsertLiquidationTime(book, new(big.Int).SetUint64(1000+id), id)
+	}
+	root, err := state.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit the state: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit the trie database: %v", err)
+	}
+
+	// the lending items of a block: a cancellation, a new item, a repaid trade and the reads of the
+	// matching and the liquidations
+	cancelled := newItem(7, Investing)
+	process := func(state *LendingStateDB) common.Hash {
+		state.GetBestInvestingRate(book)
+		state.GetBestBorrowRate(book)
+		state.GetAllLowerLiquidationTimeData(book, big.NewInt(1010))
+		if err := state.CancelLendingOrder(book, &cancelled); err != nil {
+			t.Fatalf("failed to cancel lending item: %v", err)
+		}
+		state.InsertLendingItem(book, common.Uint64ToHash(41), newItem(41, Borrowing))
+		if err := state.RemoveLiquidationTime(book, 3, 1003); err != nil {
+			t.Fatalf("failed to remove liquidation time: %v", err)
+		}
+		if err := state.CancelLendingTrade(book, 3); err != nil {
+			t.Fatalf("failed to cancel lending trade: %v", err)
+		}
+		state.SetNonce(user.Hash(), 1)
+		return state.IntermediateRoot()
+	}
+
+	witnessDB := NewWitnessDatabase(db)
+	proposed, err := New(root, witnessDB)
+	if err != nil {
+		t.Fatalf("failed to open the state on the witness database: %v", err)
+	}
+	want := process(proposed)
+	witness := witnessDB.Witness()
+	nodes := 0
+	for it := diskdb.NewIterator(nil, nil); it.Next(); {
+		nodes++
+	}
+	if len(witness) == 0 || len(witness) >= nodes {
+		t.Fatalf("witness size mismatch: have %d nodes, state has %d", len(witness), nodes)
+	}
+
+	stateless, err := New(root, NewWitnessStateDatabase(witness))
+	if err != nil {
+		t.Fatalf("failed to open the state on the witness: %v", err)
+	}
+	if got := process(stateless); got != want {
+		t.Fatalf("stateless root mismatch: have %x, want %x", got, want)
+	}
+	if _, err := New(root, NewWitnessStateDatabase(nil)); err == nil {
+		t.Error("state opened without witness")
+	}
+}
@@ -0,0 +1,158 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// maxLiquidatablePositions is the maximum number of positions returned by GetLiquidatablePositions.
+const maxLiquidatablePositions = 1000
+
+// LiquidatablePosition is an open lending trade which the next block repays or liquidates by
+// time, or liquidates by price, unless it is topped up first. CollateralPrice is the collateral
+// price the trade is liquidated with, in lending token, nil for the trades due by time.
+type LiquidatablePosition struct {
+	LendingBook            common.Hash    `json:"lendingBook"`
+	TradeId                uint64         `json:"tradeId"`
+	Hash                   common.Hash    `json:"hash"`
+	Borrower               common.Address `json:"borrower"`
+	BorrowingRelayer       common.Address `json:"borrowingRelayer"`
+	LendingToken           common.Address `json:"lendingToken"`
+	CollateralToken        common.Address `json:"collateralToken"`
+	Term                   uint64         `json:"term"`
+	Amount                 *big.Int       `json:"amount"`
+	CollateralLockedAmount *big.Int       `json:"collateralLockedAmount"`
+	LiquidationPrice       *big.Int       `json:"liquidationPrice"`
+	CollateralPrice        *big.Int       `json:"collateralPrice"`
+	LiquidationTime        uint64         `json:"liquidationTime"`
+	AutoTopUp              bool           `json:"autoTopUp"`
+	Reason                 uint64         `json:"reason"` // lendingstate.LiquidatedByTime or LiquidatedByPrice
+}
+
+func newLiquidatablePosition(lendingBook common.Hash, trade *lendingstate.LendingTrade, collateralPrice *big.Int, reason uint64) *LiquidatablePosition {
+	return &LiquidatablePosition{
+		LendingBook:            lendingBook,
+		TradeId:                trade.TradeId,
+		Hash:                   trade.Hash,
+		Borrower:               trade.Borrower,
+		BorrowingRelayer:       trade.BorrowingRelayer,
+		LendingToken:           trade.LendingToken,
+		CollateralToken:        trade.CollateralToken,
+		Term:                   trade.Term,
+		Amount:                 trade.Amount,
+		CollateralLockedAmount: trade.CollateralLockedAmount,
+		LiquidationPrice:       trade.LiquidationPrice,
+		CollateralPrice:        collateralPrice,
+		LiquidationTime:        trade.LiquidationTime,
+		AutoTopUp:              trade.AutoTopUp,
+		Reason:                 reason,
+	}
+}
+
+// scanLiquidatablePositions scans the liquidation time indexes of the lending books for the trades
+// due at the given time, then the liquidation price indexes of the lending pairs for the trades
+// whose liquidation price is above the collateral price. The trades due by time come first, the
+// earliest first, then the trades liquidated by price, the furthest above their liquidation
+// price first. The states must be opened at a committed root.
+func scanLiquidatablePositions(lendingState *lendingstate.LendingStateDB, tradingState *tradingstate.TradingStateDB, lendingBooks []common.Hash, collateralPrices map[lendingstate.LendingPair]*big.Int, time *big.Int) []*LiquidatablePosition {
+	var (
+		byTime  []*LiquidatablePosition
+		byPrice []*LiquidatablePosition
+		listed  = make(map[[2]common.Hash]bool) // lending book and trade id
+	)
+	for _, lendingBook := range lendingBooks {
+		for _, tradeIds := range lendingState.GetAllLowerLiquidationTimeData(lendingBook, time) {
+			for _, tradeId := range tradeIds {
+				trade := lendingState.GetLendingTrade(lendingBook, tradeId)
+				if trade.TradeId == 0 || trade.Amount == nil || trade.Amount.Sign() <= 0 {
+					continue
+				}
+				byTime = append(byTime, newLiquidatablePosition(lendingBook, &trade, nil, lendingstate.LiquidatedByTime))
+				listed[[2]common.Hash{lendingBook, tradeId}] = true
+			}
+		}
+	}
+	for pair, collateralPrice := range collateralPrices {
+		orderBook := tradingstate.GetTradingOrderBookHash(pair.CollateralToken, pair.LendingToken)
+		for _, liquidationData := range tradingState.GetAllHigherLiquidationPriceData(orderBook, collateralPrice) {
+			for lendingBook, tradeIds := range liquidationData {
+				for _, tradeId := range tradeIds {
+					trade := lendingState.GetLendingTrade(lendingBook, tradeId)
+					// the trades due by time are repaid before the liquidations by price
+					if trade.TradeId == 0 || trade.Amount == nil || trade.Amount.Sign() <= 0 || listed[[2]common.Hash{lendingBook, tradeId}] {
+						continue
+					}
+					byPrice = append(byPrice, newLiquidatablePosition(lendingBook, &trade, collateralPrice, lendingstate.LiquidatedByPrice))
+				}
+			}
+		}
+	}
+	sort.Slice(byTime, func(i, j int) bool {
+		if byTime[i].LiquidationTime != byTime[j].LiquidationTime {
+			return byTime[i].LiquidationTime < byTime[j].LiquidationTime
+		}
+		return byTime[i].TradeId < byTime[j].TradeId
+	})
+	sort.Slice(byPrice, func(i, j int) bool {
+		// compare liquidation price / collateral price across pairs
+		a := new(big.Int).Mul(byPrice[i].LiquidationPrice, byPrice[j].CollateralPrice)
+		b := new(big.Int).Mul(byPrice[j].LiquidationPrice, byPrice[i].CollateralPrice)
+		if c := a.Cmp(b); c != 0 {
+			return c > 0
+		}
+		return byPrice[i].TradeId < byPrice[j].TradeId
+	})
+	return append(byTime, byPrice...)
+}
+
+// liquidatablePositions returns at most maxCount positions eligible for liquidation in the
+// current state, see scanLiquidatablePositions.
+func (l *Lending) liquidatablePositions(maxCount int) ([]*LiquidatablePosition, error) {
+	if maxCount <= 0 || maxCount > maxLiquidatablePositions {
+		maxCount = maxLiquidatablePositions
+	}
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := l.tomox.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	allLendingBooks, err := lendingstate.GetAllLendingBooks(statedb)
+	if err != nil {
+		// no lending book registered yet
+		return []*LiquidatablePosition{}, nil
+	}
+	lendingBooks := make([]common.Hash, 0, len(allLendingBooks))
+	for lendingBook := range allLendingBooks {
+		lendingBooks = append(lendingBooks, lendingBook)
+	}
+	collateralPrices := make(map[lendingstate.LendingPair]*big.Int)
+	allPairs, _ := lendingstate.GetAllLendingPairs(statedb)
+	for _, pair := range allPairs {
+		_, collateralPrice, err := l.GetCollateralPrices(block.Header(), l.chain, statedb, tradingState, pair.CollateralToken, pair.LendingToken)
+		if err != nil || collateralPrice == nil || collateralPrice.Sign() == 0 {
+			// the trades of the pair aren't liquidated by price either
+			continue
+		}
+		collateralPrices[pair] = collateralPrice
+	}
+	positions := scanLiquidatablePositions(lendingState, tradingState, lendingBooks, collateralPrices, block.Time())
+	if len(positions) > maxCount {
+		positions = positions[:maxCount]
+	}
+	return positions, nil
+}
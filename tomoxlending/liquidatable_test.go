@@ -0,0 +1,90 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestScanLiquidatablePositions(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		lendingToken = common.HexToAddress(common.TomoNativeAddress)
+		collateral   = common.HexToAddress("0x3")
+		term         = uint64(86400)
+		lendingBook  = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		orderbook    = tradingstate.GetTradingOrderBookHash(collateral, lendingToken)
+		pair         = lendingstate.LendingPair{LendingToken: lendingToken, CollateralToken: collateral}
+	)
+	// liquidation time, liquidation price
+	trades := [][2]int64{{100, 900}, {200, 700}, {200, 500}, {150, 800}, {300, 650}}
+	for i, params := range trades {
+		trade := lendingstate.LendingTrade{
+			TradeId:                uint64(i + 1),
+			Borrower:               common.HexToAddress("0x1"),
+			Investor:               common.BigToAddress(big.NewInt(int64(i + 10))),
+			LendingToken:           lendingToken,
+			CollateralToken:        collateral,
+			Term:                   term,
+			Amount:                 big.NewInt(1000),
+			CollateralLockedAmount: big.NewInt(500),
+			LiquidationTime:        uint64(params[0]),
+			LiquidationPrice:       big.NewInt(params[1]),
+			Status:                 lendingstate.TradeStatusOpen,
+		}
+		trade.Hash = trade.ComputeHash()
+		lendingStateDB.InsertTradingItem(lendingBook, trade.TradeId, trade)
+		lendingStateDB.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(trade.LiquidationTime), trade.TradeId)
+		tradingStateDB.InsertLiquidationPrice(orderbook, trade.LiquidationPrice, lendingBook, trade.TradeId)
+	}
+	lendingRoot, err := lendingStateDB.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	tradingRoot, err := tradingStateDB.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	lendingStateDB, _ = lendingstate.New(lendingRoot, lendingStateDB.Database())
+	tradingStateDB, _ = tradingstate.New(tradingRoot, tradingStateDB.Database())
+
+	positions := scanLiquidatablePositions(lendingStateDB, tradingStateDB, []common.Hash{lendingBook}, map[lendingstate.LendingPair]*big.Int{pair: big.NewInt(650)}, big.NewInt(150))
+	// trades 1 and 4 are due by time, trades 2 then 1 are under their liquidation price but trade 1
+	// is listed once, trade 5 is at its liquidation price
+	want := []struct {
+		tradeId uint64
+		reason  uint64
+	}{
+		{1, lendingstate.LiquidatedByTime},
+		{4, lendingstate.LiquidatedByTime},
+		{2, lendingstate.LiquidatedByPrice},
+	}
+	if len(positions) != len(want) {
+		t.Fatalf("wrong number of positions: have %d, want %d: %v", len(positions), len(want), lendingstate.ToJSON(positions))
+	}
+	for i, position := range positions {
+		if position.TradeId != want[i].tradeId || position.Reason != want[i].reason {
+			t.Errorf("position %d: have trade %d by %d, want trade %d by %d", i, position.TradeId, position.Reason, want[i].tradeId, want[i].reason)
+		}
+	}
+	if positions[2].CollateralPrice.Int64() != 650 || positions[0].CollateralPrice != nil {
+		t.Errorf("wrong collateral prices: %v, %v", positions[0].CollateralPrice, positions[2].CollateralPrice)
+	}
+
+	// a lower collateral price liquidates the trades by decreasing liquidation price
+	positions = scanLiquidatablePositions(lendingStateDB, tradingStateDB, []common.Hash{lendingBook}, map[lendingstate.LendingPair]*big.Int{pair: big.NewInt(400)}, big.NewInt(50))
+	var ids []uint64
+	for _, position := range positions {
+		ids = append(ids, position.TradeId)
+	}
+	if len(ids) != 5 || ids[0] != 1 || ids[1] != 4 || ids[2] != 2 || ids[3] != 5 || ids[4] != 3 {
+		t.Errorf("wrong liquidation order by price: %v", ids)
+	}
+}
@@ -0,0 +1,137 @@
+package tomoxlending
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Since TIPTomoXMargin a Margin lending item places a leveraged spot order (see
+// lendingstate.MarginOrder): the lending engine borrows the leveraged part of the order at market
+// against the collateral of the item, then the spot order is processed as a limit order of the
+// user. The borrow and the spot order are linked while the order rests in the order book, so that
+// liquidating the lending trade cancels what is left of the order, unwinding both positions at once.
+
+// ProcessMarginOrder processes a margin item. It borrows all the leveraged part of its spot order or
+// nothing, and rejects the item if the user can't fund the rest of the order or the spot order is
+// rejected. The caller reverts the state on error.
+func (l *Lending) ProcessMarginOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
+	// the spot order has been checked by VerifyLendingItem
+	margin, _ := lendingstate.ParseMarginOrder(order.LendingToken, order.ExtraData)
+	baseTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, margin.BaseToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	spent, borrowed := margin.MarginBorrow(order.Quantity, baseTokenDecimal)
+	if borrowed.Sign() == 0 {
+		return nil, nil, &lendingstate.RejectError{Reason: lendingstate.RejectReasonInvalidQuantity, Err: fmt.Errorf("nothing to borrow for the margin order. Spent: %v", spent)}
+	}
+	own := new(big.Int).Sub(spent, borrowed)
+	if balance := lendingstate.GetTokenBalance(order.UserAddress, order.LendingToken, statedb); balance.Cmp(own) < 0 {
+		return nil, nil, &lendingstate.RejectError{Reason: lendingstate.RejectReasonInsufficientBalance, Err: fmt.Errorf("not enough balance to fund the margin order. Expected: %v. Have: %v", own, balance)}
+	}
+
+	borrow := *order
+	borrow.Type = lendingstate.Market
+	borrow.Quantity = borrowed
+	borrow.ExtraData = ""
+	trades, rejects, err := l.processMarketOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingBook, &borrow)
+	if err != nil {
+		return nil, nil, err
+	}
+	filled := new(big.Int)
+	for _, trade := range trades {
+		filled.Add(filled, trade.Amount)
+	}
+	if filled.Cmp(borrowed) < 0 {
+		return nil, nil, &lendingstate.RejectError{Reason: lendingstate.RejectReasonMarginNotFilled, Err: fmt.Errorf("margin borrow not filled. Borrowed: %v. Filled: %v", borrowed, filled)}
+	}
+
+	spot := &tradingstate.OrderItem{
+		Quantity:        new(big.Int).Set(order.Quantity),
+		Price:           margin.Price,
+		ExchangeAddress: order.Relayer,
+		UserAddress:     order.UserAddress,
+		BaseToken:       margin.BaseToken,
+		QuoteToken:      margin.QuoteToken,
+		Status:          tradingstate.OrderNew,
+		Side:            margin.Side,
+		Type:            tradingstate.Limit,
+		Hash:            order.Hash,
+		TxHash:          order.TxHash,
+		Signature:       &tradingstate.Signature{},
+		FilledAmount:    new(big.Int),
+		Nonce:           order.Nonce,
+		CreatedAt:       order.CreatedAt,
+		UpdatedAt:       order.UpdatedAt,
+	}
+	if order.Signature != nil {
+		spot.Signature = &tradingstate.Signature{V: order.Signature.V, R: order.Signature.R, S: order.Signature.S}
+	}
+	orderBook := tradingstate.GetTradingOrderBookHash(spot.BaseToken, spot.QuoteToken)
+	_, spotRejects, err := l.tomox.ApplyMarginOrder(header, coinbase, chain, statedb, tradingStateDb, orderBook, spot)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, reject := range spotRejects {
+		if reject == spot {
+			return nil, nil, &lendingstate.RejectError{Reason: lendingstate.RejectReasonMarginNotFilled, Err: fmt.Errorf("margin spot order rejected. Reason: %s", spot.RejectReason)}
+		}
+	}
+	if spot.OrderID > 0 {
+		// the order rests in the order book
+		for _, trade := range trades {
+			lendingStateDB.SetMarginPosition(lendingBook, lendingstate.MarginPosition{
+				TradeId:    trade.TradeId,
+				BaseToken:  spot.BaseToken,
+				QuoteToken: spot.QuoteToken,
+				OrderId:    spot.OrderID,
+				OrderHash:  spot.Hash,
+			})
+		}
+	}
+	log.Debug("Process margin order", "user", order.UserAddress.Hex(), "side", margin.Side, "leverage", margin.Leverage, "borrowed", borrowed, "trades", len(trades), "orderId", spot.OrderID)
+	return trades, rejects, nil
+}
+
+// liquidateMarginPosition cancels what is left of the spot order linked to a liquidated lending
+// trade, and unlinks it.
+func liquidateMarginPosition(lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64) {
+	position, ok := lendingStateDB.GetMarginPosition(lendingBook, lendingTradeId)
+	if !ok {
+		return
+	}
+	lendingStateDB.RemoveMarginPosition(lendingBook, lendingTradeId)
+	orderBook := tradingstate.GetTradingOrderBookHash(position.BaseToken, position.QuoteToken)
+	spot := tradingStateDb.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(position.OrderId)))
+	// the order may have been filled or cancelled by the user since
+	if spot.Hash != position.OrderHash || spot.Quantity == nil || spot.Quantity.Sign() == 0 {
+		return
+	}
+	if err := tradingStateDb.CancelOrder(orderBook, &spot); err != nil {
+		log.Debug("Failed to cancel the margin order of a liquidated trade", "tradeId", lendingTradeId, "orderId", position.OrderId, "err", err)
+		return
+	}
+	log.Debug("Cancel the margin order of a liquidated trade", "tradeId", lendingTradeId, "orderId", position.OrderId)
+}
+
+// rolloverMarginPosition links the spot order linked to a rolled over lending trade to the trades
+// renewing it.
+func rolloverMarginPosition(lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash, lendingTradeId uint64, newTrades []*lendingstate.LendingTrade) {
+	position, ok := lendingStateDB.GetMarginPosition(lendingBook, lendingTradeId)
+	if !ok {
+		return
+	}
+	lendingStateDB.RemoveMarginPosition(lendingBook, lendingTradeId)
+	for _, newTrade := range newTrades {
+		position.TradeId = newTrade.TradeId
+		lendingStateDB.SetMarginPosition(lendingBook, position)
+	}
+}
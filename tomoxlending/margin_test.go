@@ -0,0 +1,69 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestLiquidateMarginPosition(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		usdt        = common.HexToAddress("0x10")
+		btc         = common.HexToAddress("0x20")
+		user        = common.HexToAddress("0x1")
+		relayer     = common.HexToAddress("0x2")
+		lendingBook = lendingstate.GetLendingOrderBookHash(usdt, 86400)
+		orderBook   = tradingstate.GetTradingOrderBookHash(btc, usdt)
+	)
+	spot := tradingstate.OrderItem{
+		OrderID:         1,
+		Quantity:        big.NewInt(3000),
+		Price:           big.NewInt(20000),
+		ExchangeAddress: relayer,
+		UserAddress:     user,
+		BaseToken:       btc,
+		QuoteToken:      usdt,
+		Status:          tradingstate.OrderStatusOpen,
+		Side:            tradingstate.Bid,
+		Type:            tradingstate.Limit,
+		Hash:            common.HexToHash("0x77"),
+		Signature:       &tradingstate.Signature{},
+		FilledAmount:    new(big.Int),
+		Nonce:           new(big.Int),
+	}
+	tradingStateDB.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(1)), spot)
+	// the borrow was matched by two investors, both trades are linked to the spot order
+	for _, tradeId := range []uint64{1, 2} {
+		lendingStateDB.SetMarginPosition(lendingBook, lendingstate.MarginPosition{TradeId: tradeId, BaseToken: btc, QuoteToken: usdt, OrderId: spot.OrderID, OrderHash: spot.Hash})
+	}
+
+	// renewing a trade links its renewals
+	rolloverMarginPosition(lendingStateDB, lendingBook, 2, []*lendingstate.LendingTrade{{TradeId: 3}})
+	if _, ok := lendingStateDB.GetMarginPosition(lendingBook, 2); ok {
+		t.Errorf("rolled over trade still linked")
+	}
+	if position, ok := lendingStateDB.GetMarginPosition(lendingBook, 3); !ok || position.OrderId != spot.OrderID {
+		t.Errorf("wrong position of the renewed trade: %+v", position)
+	}
+
+	liquidateMarginPosition(lendingStateDB, tradingStateDB, lendingBook, 1)
+	if _, ok := lendingStateDB.GetMarginPosition(lendingBook, 1); ok {
+		t.Errorf("liquidated trade still linked")
+	}
+	if price, _ := tradingStateDB.GetBestBidPrice(orderBook); price.Sign() != 0 {
+		t.Errorf("spot order of the liquidated trade not cancelled, best bid %v", price)
+	}
+	// the spot order is gone, liquidating the other trade only unlinks it
+	liquidateMarginPosition(lendingStateDB, tradingStateDB, lendingBook, 3)
+	if positions := lendingStateDB.GetMarginPositions(lendingBook); len(positions) != 0 {
+		t.Errorf("unexpected margin positions: %+v", positions)
+	}
+}
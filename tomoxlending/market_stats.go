@@ -0,0 +1,109 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// defaultStatsWindow is the period covered by the volume of MarketStats if the chain has no epoch.
+const defaultStatsWindow = 24 * time.Hour
+
+// MarketStats summarizes a lending book. Rates and open interest are read from the lending state
+// of the current block, volumes are only available on SDK nodes and cover the last epoch.
+type MarketStats struct {
+	LendingToken        common.Address `json:"lendingToken"`
+	Term                uint64         `json:"term"`
+	BlockHash           common.Hash    `json:"blockHash"`
+	BlockNumber         uint64         `json:"blockNumber"`
+	BestInvestingRate   *big.Int       `json:"bestInvestingRate"` // lowest interest offered by investors
+	BestInvestingVolume *big.Int       `json:"bestInvestingVolume"`
+	BestBorrowingRate   *big.Int       `json:"bestBorrowingRate"` // highest interest accepted by borrowers
+	BestBorrowingVolume *big.Int       `json:"bestBorrowingVolume"`
+	OpenInterest        *big.Int       `json:"openInterest"` // sum of the amounts of the open lending trades
+	OpenTrades          int            `json:"openTrades"`
+	From                time.Time      `json:"from"`
+	To                  time.Time      `json:"to"`
+	BorrowVolume        *big.Int       `json:"borrowVolume,omitempty"` // quantity of the borrowing items placed between From and To
+	SupplyVolume        *big.Int       `json:"supplyVolume,omitempty"` // quantity of the investing items placed between From and To
+	TradeVolume         *big.Int       `json:"tradeVolume,omitempty"`  // amount of the lending trades matched between From and To
+	TradeCount          int            `json:"tradeCount"`
+}
+
+// addLendingVolume adds the quantity of the given lending items and the amount of the given trades to the volumes of stats.
+func (stats *MarketStats) addLendingVolume(items []*lendingstate.LendingItem, trades []*lendingstate.LendingTrade) {
+	stats.BorrowVolume, stats.SupplyVolume, stats.TradeVolume = new(big.Int), new(big.Int), new(big.Int)
+	for _, item := range items {
+		if item.Quantity == nil {
+			continue
+		}
+		switch item.Side {
+		case lendingstate.Borrowing:
+			stats.BorrowVolume.Add(stats.BorrowVolume, item.Quantity)
+		case lendingstate.Investing:
+			stats.SupplyVolume.Add(stats.SupplyVolume, item.Quantity)
+		}
+	}
+	for _, trade := range trades {
+		if trade.Amount == nil {
+			continue
+		}
+		stats.TradeVolume.Add(stats.TradeVolume, trade.Amount)
+		stats.TradeCount++
+	}
+}
+
+// marketStats builds the statistics of a lending book at the current block.
+func (l *Lending) marketStats(lendingToken common.Address, term uint64) (*MarketStats, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	stats := &MarketStats{
+		LendingToken: lendingToken,
+		Term:         term,
+		BlockHash:    block.Hash(),
+		BlockNumber:  block.NumberU64(),
+		OpenInterest: new(big.Int),
+		To:           time.Unix(block.Time().Int64(), 0).UTC(),
+	}
+	investingRate, investingVolume := lendingState.GetBestInvestingRate(lendingBook)
+	borrowingRate, borrowingVolume := lendingState.GetBestBorrowRate(lendingBook)
+	stats.BestInvestingRate, stats.BestInvestingVolume = new(big.Int).Set(investingRate), new(big.Int).Set(investingVolume)
+	stats.BestBorrowingRate, stats.BestBorrowingVolume = new(big.Int).Set(borrowingRate), new(big.Int).Set(borrowingVolume)
+	if lendingState.Exist(lendingBook) {
+		trades, err := lendingState.DumpLendingTradeTrie(lendingBook)
+		if err != nil {
+			return nil, err
+		}
+		for _, trade := range trades {
+			if trade.Amount == nil || trade.Amount.Sign() <= 0 {
+				continue
+			}
+			stats.OpenInterest.Add(stats.OpenInterest, trade.Amount)
+			stats.OpenTrades++
+		}
+	}
+
+	stats.From = stats.To.Add(-defaultStatsWindow)
+	if posv := l.chain.Config().Posv; posv != nil && posv.Epoch > 0 {
+		start := uint64(0)
+		if block.NumberU64() > posv.Epoch {
+			start = block.NumberU64() - posv.Epoch
+		}
+		if startBlock := l.chain.GetBlockByNumber(start); startBlock != nil {
+			stats.From = time.Unix(startBlock.Time().Int64(), 0).UTC()
+		}
+	}
+	if l.tomox.IsSDKNode() {
+		// the lending items and trades of the current block are recorded at its time
+		to := stats.To.Add(time.Second)
+		items, _ := l.GetMongoReadDB().GetLendingListByTime(lendingToken, term, stats.From, to, &lendingstate.LendingItem{}).([]*lendingstate.LendingItem)
+		trades, _ := l.GetMongoReadDB().GetLendingListByTime(lendingToken, term, stats.From, to, &lendingstate.LendingTrade{}).([]*lendingstate.LendingTrade)
+		stats.addLendingVolume(items, trades)
+	}
+	return stats, nil
+}
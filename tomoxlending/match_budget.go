@@ -0,0 +1,32 @@
+package tomoxlending
+
+import (
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The matching work of the lending items of a sealed block can be bounded with
+// tomox.Config.LendingMatchBudget, so that a flood of items crossing deep into the lending books
+// doesn't hold up the sealing of the block. An item costs one unit to commit, plus one unit per
+// trade it opens or settles and per item it rejects. Once the budget is spent the miner stops
+// matching: the remaining items stay in the lending pool and are matched by the next blocks. They
+// are taken by nonce, then by user address, so the items left over only depend on the content of
+// the pool. The budget and this ordering only apply to the miner: validators, like the lending
+// replay (see ReplayBlock), apply the items included in a block in the order of the block, whatever
+// work they take.
+
+// matchBudget counts the matching work spent by the lending items of a block.
+type matchBudget struct {
+	limit uint64 // 0 for no limit
+	spent uint64
+}
+
+// exhausted returns whether no more item can be matched in the block.
+func (b *matchBudget) exhausted() bool {
+	return b.limit > 0 && b.spent >= b.limit
+}
+
+// charge adds the work spent committing an item which opened or settled the given trades and
+// rejected the given items.
+func (b *matchBudget) charge(trades []*lendingstate.LendingTrade, rejects []*lendingstate.LendingItem) {
+	b.spent += 1 + uint64(len(trades)) + uint64(len(rejects))
+}
@@ -0,0 +1,75 @@
+package tomoxlending
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestMatchBudget(t *testing.T) {
+	budget := &matchBudget{limit: 5}
+	budget.charge(make([]*lendingstate.LendingTrade, 2), nil)
+	if budget.exhausted() {
+		t.Fatalf("budget exhausted after %d units", budget.spent)
+	}
+	budget.charge(nil, make([]*lendingstate.LendingItem, 1))
+	if !budget.exhausted() || budget.spent != 5 {
+		t.Fatalf("budget not exhausted after %d units", budget.spent)
+	}
+	// no limit
+	unlimited := &matchBudget{}
+	unlimited.charge(make([]*lendingstate.LendingTrade, 1000), nil)
+	if unlimited.exhausted() {
+		t.Fatal("unlimited budget exhausted")
+	}
+}
+
+func TestProcessOrdersWithinBudget(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var users []common.Address
+	pending := make(map[common.Address]types.LendingTransactions)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		user := crypto.PubkeyToAddress(key.PublicKey)
+		tx := types.NewLendingTransaction(0, big.NewInt(100), 10, 86400, common.HexToAddress("0x21"), user, common.HexToAddress(common.TomoNativeAddress), common.Address{}, false, lendingstate.LendingStatusNew, lendingstate.Investing, lendingstate.Limit, common.Hash{}, 0, 0, "")
+		signed, err := types.LendingSignTx(tx, types.LendingTxSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		pending[user] = types.LendingTransactions{signed}
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return bytes.Compare(users[i].Bytes(), users[j].Bytes()) < 0 })
+
+	// each item is rejected as no relayer registers its pair, which costs two units
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	budget := &matchBudget{limit: 3}
+	items, _ := l.processOrders(header, common.Address{}, &epochTestChain{}, pending, statedb, lendingStateDB, tradingStateDB, budget)
+	if len(items) != 2 || budget.spent != 4 {
+		t.Fatalf("wrong items within budget: have %d items for %d units, want 2 for 4", len(items), budget.spent)
+	}
+	// the items left over are the ones of the highest user addresses
+	for i, item := range items {
+		if item.UserAddress != users[i] {
+			t.Errorf("item %d: wrong user %s, want %s", i, item.UserAddress.Hex(), users[i].Hex())
+		}
+	}
+	if nonce := lendingStateDB.GetNonce(users[2].Hash()); nonce != 0 {
+		t.Errorf("item left over was matched: nonce %d", nonce)
+	}
+}
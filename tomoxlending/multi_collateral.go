@@ -0,0 +1,197 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Since TIPTomoXLendingV2 a borrower can back a lending trade with other collateral tokens than the
+// collateral of the trade with AddCollateral lending items. The extra collaterals are locked in
+// LendingLockAddress and recorded in the collateral book of the trade (see lendingstate.TradeCollateral).
+//
+// The trade stays indexed in the liquidation price trie of its main collateral. When the first extra
+// collateral is added, the liquidation threshold T = LiquidationPrice * CollateralLockedAmount of the
+// trade is recorded: the trade must be liquidated once the value of all its collaterals falls below T.
+// The liquidation price of the trade is then the weighted price (T - E) / CollateralLockedAmount,
+// where E is the value of the extra collaterals in lending token. It is refreshed with the prices of
+// the extra collaterals before the trades are liquidated by price.
+//
+// The extra collaterals follow the main collateral when the trade is closed: they go back to the
+// borrower when the trade is repaid and to the investor when it is liquidated.
+
+// AddCollateralData is recorded in the ExtraData of a lending trade after an extra collateral is added.
+type AddCollateralData struct {
+	Token  common.Address
+	Amount *big.Int
+}
+
+// ProcessAddCollateral locks the extra collateral of an AddCollateral lending item for its trade
+// and updates the liquidation price of the trade.
+func (l *Lending) ProcessAddCollateral(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, order *lendingstate.LendingItem) (*lendingstate.LendingTrade, error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(order.LendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("ProcessAddCollateral for emptyLendingTrade is not allowed. lendingTradeId: %v", order.LendingTradeId)
+	}
+	if order.UserAddress != lendingTrade.Borrower {
+		return nil, fmt.Errorf("ProcessAddCollateral: user %s is not the borrower of lendingTradeId: %v", order.UserAddress.Hex(), order.LendingTradeId)
+	}
+	if order.CollateralToken == lendingTrade.CollateralToken {
+		return nil, fmt.Errorf("ProcessAddCollateral: token %s is the collateral of lendingTradeId: %v", order.CollateralToken.Hex(), order.LendingTradeId)
+	}
+	if order.Quantity == nil || order.Quantity.Sign() <= 0 {
+		return nil, fmt.Errorf("ProcessAddCollateral: invalid quantity %v", order.Quantity)
+	}
+	tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, order.CollateralToken, statedb)
+	if tokenBalance.Cmp(order.Quantity) < 0 {
+		return nil, fmt.Errorf("not enough balance to add collateral. lendingTradeId: %v , Quantity : %v , tokenBalance : %v", order.LendingTradeId, order.Quantity, tokenBalance)
+	}
+	lendingstate.SubTokenBalance(lendingTrade.Borrower, order.Quantity, order.CollateralToken, statedb)
+	lendingstate.AddTokenBalance(common.HexToAddress(common.LendingLockAddress), order.Quantity, order.CollateralToken, statedb)
+
+	if lendingStateDB.GetTradeLiquidationThreshold(lendingBook, lendingTrade.TradeId).Sign() == 0 {
+		threshold := new(big.Int).Mul(lendingTrade.LiquidationPrice, lendingTrade.CollateralLockedAmount)
+		lendingStateDB.SetTradeLiquidationThreshold(lendingBook, lendingTrade.TradeId, threshold)
+	}
+	amount := new(big.Int).Set(order.Quantity)
+	for _, collateral := range lendingStateDB.GetTradeCollaterals(lendingBook, lendingTrade.TradeId) {
+		if collateral.Token == order.CollateralToken {
+			amount = amount.Add(amount, collateral.Amount)
+		}
+	}
+	lendingStateDB.SetTradeCollateral(lendingBook, lendingTrade.TradeId, order.CollateralToken, amount)
+
+	newLendingTrade, err := l.updateWeightedLiquidationPrice(header, chain, lendingStateDB, statedb, tradingStateDb, lendingBook, lendingTrade.TradeId)
+	if err != nil {
+		return nil, err
+	}
+	extraData, _ := json.Marshal(AddCollateralData{Token: order.CollateralToken, Amount: amount})
+	newLendingTrade.ExtraData = string(extraData)
+	log.Debug("ProcessAddCollateral successfully", "tradeId", lendingTrade.TradeId, "token", order.CollateralToken.Hex(), "amount", amount, "price", newLendingTrade.LiquidationPrice)
+	return newLendingTrade, nil
+}
+
+// extraCollateralValue returns the value in lending token of the extra collaterals of a lending trade.
+// Collaterals without a price are worth nothing.
+func (l *Lending) extraCollateralValue(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingTrade *lendingstate.LendingTrade, collaterals []lendingstate.TradeCollateral) *big.Int {
+	value := new(big.Int)
+	collateralTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, lendingTrade.CollateralToken)
+	if err != nil || collateralTokenDecimal.Sign() == 0 {
+		return value
+	}
+	for _, collateral := range collaterals {
+		_, price, err := l.GetCollateralPrices(header, chain, statedb, tradingStateDb, collateral.Token, lendingTrade.LendingToken)
+		if err != nil || price == nil || price.Sign() <= 0 {
+			log.Debug("extraCollateralValue: no price for collateral", "token", collateral.Token.Hex(), "err", err)
+			continue
+		}
+		tokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, collateral.Token)
+		if err != nil || tokenDecimal.Sign() == 0 {
+			continue
+		}
+		// the threshold is expressed in price * amount of the main collateral
+		v := new(big.Int).Mul(collateral.Amount, price)
+		v = new(big.Int).Mul(v, collateralTokenDecimal)
+		v = new(big.Int).Div(v, tokenDecimal)
+		value = value.Add(value, v)
+	}
+	return value
+}
+
+// updateWeightedLiquidationPrice sets the liquidation price of a multi-collateral trade
+// to its weighted price and moves it in the liquidation price trie.
+func (l *Lending) updateWeightedLiquidationPrice(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64) (*lendingstate.LendingTrade, error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("updateWeightedLiquidationPrice for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	threshold := lendingStateDB.GetTradeLiquidationThreshold(lendingBook, lendingTradeId)
+	if threshold.Sign() == 0 || lendingTrade.CollateralLockedAmount.Sign() == 0 {
+		return &lendingTrade, nil
+	}
+	extraValue := l.extraCollateralValue(header, chain, statedb, tradingStateDb, &lendingTrade, lendingStateDB.GetTradeCollaterals(lendingBook, lendingTradeId))
+	newLiquidationPrice := new(big.Int).Sub(threshold, extraValue)
+	newLiquidationPrice = new(big.Int).Div(newLiquidationPrice, lendingTrade.CollateralLockedAmount)
+	if newLiquidationPrice.Sign() <= 0 {
+		// the extra collaterals cover the debt, keep the trade in the liquidation price trie
+		newLiquidationPrice = big.NewInt(1)
+	}
+	if newLiquidationPrice.Cmp(lendingTrade.LiquidationPrice) == 0 {
+		return &lendingTrade, nil
+	}
+	orderbook := tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	if err := tradingStateDb.RemoveLiquidationPrice(orderbook, lendingTrade.LiquidationPrice, lendingBook, lendingTradeId); err != nil {
+		return nil, err
+	}
+	lendingStateDB.UpdateLiquidationPrice(lendingBook, lendingTradeId, newLiquidationPrice)
+	tradingStateDb.InsertLiquidationPrice(orderbook, newLiquidationPrice, lendingBook, lendingTradeId)
+	log.Debug("Update weighted liquidation price", "tradeId", lendingTradeId, "threshold", threshold, "extraValue", extraValue, "old", lendingTrade.LiquidationPrice, "new", newLiquidationPrice)
+	lendingTrade.LiquidationPrice = newLiquidationPrice
+	return &lendingTrade, nil
+}
+
+// updateWeightedLiquidationPrices refreshes the liquidation prices of all the multi-collateral
+// trades of a lending book.
+func (l *Lending) updateWeightedLiquidationPrices(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash) error {
+	for _, tradeId := range lendingStateDB.GetMultiCollateralTrades(lendingBook) {
+		if _, err := l.updateWeightedLiquidationPrice(header, chain, lendingStateDB, statedb, tradingStateDb, lendingBook, tradeId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMultiCollateralTrade returns whether extra collaterals back a lending trade.
+func isMultiCollateralTrade(lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash, lendingTradeId uint64) bool {
+	return lendingStateDB.GetTradeLiquidationThreshold(lendingBook, lendingTradeId).Sign() > 0
+}
+
+// releaseTradeCollaterals unlocks the extra collaterals of a closed lending trade in favor of the receiver.
+func releaseTradeCollaterals(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash, lendingTradeId uint64, receiver common.Address) {
+	if !isMultiCollateralTrade(lendingStateDB, lendingBook, lendingTradeId) {
+		return
+	}
+	for _, collateral := range lendingStateDB.GetTradeCollaterals(lendingBook, lendingTradeId) {
+		lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), collateral.Amount, collateral.Token, statedb)
+		lendingstate.AddTokenBalance(receiver, collateral.Amount, collateral.Token, statedb)
+	}
+	lendingStateDB.ClearTradeCollaterals(lendingBook, lendingTradeId)
+}
+
+// TradeCollaterals is the RPC representation of the collaterals backing a lending trade.
+type TradeCollaterals struct {
+	TradeId                uint64                         `json:"tradeId"`
+	CollateralToken        common.Address                 `json:"collateralToken"`
+	CollateralLockedAmount *big.Int                       `json:"collateralLockedAmount"`
+	LiquidationPrice       *big.Int                       `json:"liquidationPrice"`
+	LiquidationThreshold   *big.Int                       `json:"liquidationThreshold"`
+	ExtraCollaterals       []lendingstate.TradeCollateral `json:"extraCollaterals"`
+}
+
+func (l *Lending) tradeCollaterals(lendingToken common.Address, term uint64, tradeId uint64) (*TradeCollaterals, error) {
+	_, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	lendingTrade := lendingState.GetLendingTrade(lendingBook, common.Uint64ToHash(tradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("lending trade %d not found", tradeId)
+	}
+	return &TradeCollaterals{
+		TradeId:                tradeId,
+		CollateralToken:        lendingTrade.CollateralToken,
+		CollateralLockedAmount: lendingTrade.CollateralLockedAmount,
+		LiquidationPrice:       lendingTrade.LiquidationPrice,
+		LiquidationThreshold:   lendingState.GetTradeLiquidationThreshold(lendingBook, tradeId),
+		ExtraCollaterals:       lendingState.GetTradeCollaterals(lendingBook, tradeId),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package tomoxlending
+
+import (
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/rpc"
+)
+
+// NonceGap is a range of missing nonces, both ends included. Lending items queued after a gap
+// are not processed until the missing nonces are sent.
+type NonceGap struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// LendingOrderNonce describes the lending item nonces of an address.
+type LendingOrderNonce struct {
+	Address      common.Address   `json:"address"`
+	Nonce        hexutil.Uint64   `json:"nonce"`        // next nonce at the requested block, the pending one for "pending"
+	LatestNonce  hexutil.Uint64   `json:"latestNonce"`  // next nonce in the lending state of the current block
+	PendingNonce hexutil.Uint64   `json:"pendingNonce"` // next nonce after the executable lending items of the pool
+	Queued       []hexutil.Uint64 `json:"queued"`       // nonces of the lending items waiting for a missing nonce
+	Gaps         []NonceGap       `json:"gaps"`
+}
+
+// nonceGaps returns the ranges of nonces missing between next and the queued nonces.
+func nonceGaps(next uint64, queued []uint64) []NonceGap {
+	sorted := append([]uint64(nil), queued...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	gaps := []NonceGap{}
+	for _, nonce := range sorted {
+		if nonce < next {
+			continue
+		}
+		if nonce > next {
+			gaps = append(gaps, NonceGap{From: hexutil.Uint64(next), To: hexutil.Uint64(nonce - 1)})
+		}
+		next = nonce + 1
+	}
+	return gaps
+}
+
+// lendingOrderNonce returns the lending item nonces of an address at the given block, with the
+// nonces of its pending and queued lending items.
+func (l *Lending) lendingOrderNonce(address common.Address, blockNr rpc.BlockNumber) (*LendingOrderNonce, error) {
+	_, latestState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	result := &LendingOrderNonce{
+		Address:     address,
+		LatestNonce: hexutil.Uint64(latestState.GetNonce(address.Hash())),
+		Queued:      []hexutil.Uint64{},
+		Gaps:        []NonceGap{},
+	}
+	result.PendingNonce = result.LatestNonce
+	if l.lendingPool != nil {
+		result.PendingNonce = hexutil.Uint64(l.lendingPool.State().GetNonce(address.Hash()))
+		_, queuedTxs := l.lendingPool.ContentFrom(address)
+		queued := make([]uint64, 0, len(queuedTxs))
+		for _, tx := range queuedTxs {
+			queued = append(queued, tx.Nonce())
+			result.Queued = append(result.Queued, hexutil.Uint64(tx.Nonce()))
+		}
+		result.Gaps = nonceGaps(uint64(result.PendingNonce), queued)
+	}
+
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		result.Nonce = result.PendingNonce
+	case rpc.LatestBlockNumber:
+		result.Nonce = result.LatestNonce
+	default:
+		_, lendingState, err := l.lendingStateAt(blockNr)
+		if err != nil {
+			return nil, err
+		}
+		result.Nonce = hexutil.Uint64(lendingState.GetNonce(address.Hash()))
+	}
+	return result, nil
+}
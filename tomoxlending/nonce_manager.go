@@ -0,0 +1,150 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+// With --tomox.lendingnonces, the node assigns the nonces of the lending items submitted to it, so
+// that relayers placing items concurrently for an address don't have to track its nonce and retry
+// on ErrNonceTooHigh or ErrNonceTooLow. The next nonce of each address is persisted in leveldb:
+//
+//	lendingNoncePrefix + address -> lendingNonceEntry
+//
+// A nonce is assigned after the last one assigned to the address, or after the nonce of its pending
+// transactions if it is ahead. The nonces assigned but not received by the pool after
+// lendingNonceTimeout, e.g. reserved by a relayer which never sent the item, are assigned again,
+// so that the items of the address don't stay queued behind a gap.
+var lendingNoncePrefix = []byte("lendingNonce-")
+
+const lendingNonceTimeout = time.Minute // Time a nonce assigned ahead of the pool is reserved
+
+var errLendingNoncesDisabled = errors.New("lending nonce assignment is disabled, see --tomox.lendingnonces")
+
+// lendingNonceEntry is the next nonce assigned to an address, and the time of its last assignment.
+type lendingNonceEntry struct {
+	Next uint64 `json:"next"`
+	Time int64  `json:"time"`
+}
+
+func lendingNonceKey(addr common.Address) []byte {
+	return append(append([]byte{}, lendingNoncePrefix...), addr.Bytes()...)
+}
+
+func (l *Lending) loadLendingNonce(addr common.Address) lendingNonceEntry {
+	var entry lendingNonceEntry
+	if data, err := l.GetLevelDB().Get(lendingNonceKey(addr)); err == nil && len(data) > 0 {
+		json.Unmarshal(data, &entry)
+	}
+	return entry
+}
+
+func (l *Lending) saveLendingNonce(addr common.Address, entry lendingNonceEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return l.GetLevelDB().Put(lendingNonceKey(addr), data)
+}
+
+// reserveLendingNonces assigns n consecutive nonces to an address and returns the first one.
+func (l *Lending) reserveLendingNonces(addr common.Address, n uint64) (uint64, error) {
+	if !l.tomox.LendingNonces() {
+		return 0, errLendingNoncesDisabled
+	}
+	if l.lendingPool == nil {
+		return 0, errLendingStateUnavailable
+	}
+	l.nonceLock.Lock()
+	defer l.nonceLock.Unlock()
+
+	now := time.Now()
+	next := l.lendingPool.State().GetNonce(addr.Hash())
+	entry := l.loadLendingNonce(addr)
+	if entry.Next > next && now.Sub(time.Unix(entry.Time, 0)) < lendingNonceTimeout {
+		next = entry.Next
+	}
+	if err := l.saveLendingNonce(addr, lendingNonceEntry{Next: next + n, Time: now.Unix()}); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// releaseLendingNonces gives back the last nonces assigned to an address, if none was assigned since.
+func (l *Lending) releaseLendingNonces(addr common.Address, first, n uint64) {
+	l.nonceLock.Lock()
+	defer l.nonceLock.Unlock()
+
+	if entry := l.loadLendingNonce(addr); entry.Next == first+n {
+		entry.Next = first
+		l.saveLendingNonce(addr, entry)
+	}
+}
+
+// submitLendingItems assigns the nonces of lending items, signs them with the unlocked accounts of
+// their users and injects them into the lending pool. Either all of them are added or none, in which
+// case their nonces are given back. The hash of an item placed without one is its signing hash.
+// It returns the transaction hashes of the items.
+func (l *Lending) submitLendingItems(items []LendingItemArgs) ([]common.Hash, error) {
+	if len(items) == 0 {
+		return nil, ErrNoLendingItems
+	}
+	if len(items) > maxLendingItemsPerCall {
+		return nil, ErrTooManyLendingItems
+	}
+	// assign the nonces of each user at once, in the order of the items
+	var (
+		users  []common.Address
+		counts = make(map[common.Address]uint64)
+		nonces = make(map[common.Address]uint64)
+	)
+	for _, item := range items {
+		if counts[item.UserAddress] == 0 {
+			users = append(users, item.UserAddress)
+		}
+		counts[item.UserAddress]++
+	}
+	release := func() {
+		for _, user := range users {
+			if first, ok := nonces[user]; ok {
+				l.releaseLendingNonces(user, first, counts[user])
+			}
+		}
+	}
+	for _, user := range users {
+		first, err := l.reserveLendingNonces(user, counts[user])
+		if err != nil {
+			release()
+			return nil, err
+		}
+		nonces[user] = first
+	}
+	assigned := make(map[common.Address]uint64)
+	txs := make([]*types.LendingTransaction, len(items))
+	hashes := make([]common.Hash, len(items))
+	for i := range items {
+		item := items[i]
+		item.AccountNonce = hexutil.Uint64(nonces[item.UserAddress] + assigned[item.UserAddress])
+		assigned[item.UserAddress]++
+		tx := item.toTransaction()
+		if tx.LendingHash() == (common.Hash{}) {
+			tx.SetLendingHash(l.lendingTxSigner().Hash(tx))
+		}
+		signed, err := l.signLendingTx(tx)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		txs[i], hashes[i] = signed, signed.Hash()
+	}
+	if err := l.lendingPool.AddLocalsAtomic(txs); err != nil {
+		release()
+		return nil, err
+	}
+	return hashes, nil
+}
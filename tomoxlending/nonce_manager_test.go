@@ -0,0 +1,68 @@
+package tomoxlending
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// nonceTestPool is a lending pool only serving its nonces.
+type nonceTestPool struct {
+	lendingTxPool
+	state *lendingstate.LendingManagedState
+}
+
+func (p *nonceTestPool) State() *lendingstate.LendingManagedState { return p.state }
+
+func TestReserveLendingNonces(t *testing.T) {
+	var (
+		user  = common.HexToAddress("0x1")
+		tomoX = tomox.New(&tomox.Config{DevDB: true, LendingNonces: true})
+		l     = New(tomoX)
+	)
+	defer tomoX.GetMongoDB().Close()
+	if _, err := New(tomox.New(&tomox.Config{DevDB: true})).reserveLendingNonces(user, 1); err != errLendingNoncesDisabled {
+		t.Fatalf("wrong error with the assignment disabled: %v", err)
+	}
+	lendingState, _ := lendingstate.New(lendingstate.EmptyRoot, l.StateCache)
+	pool := &nonceTestPool{state: lendingstate.ManageState(lendingState)}
+	pool.state.SetNonce(user.Hash(), 5)
+	l.SetLendingPool(pool)
+
+	for i, want := range []uint64{5, 6} {
+		if nonce, err := l.reserveLendingNonces(user, 1); err != nil || nonce != want {
+			t.Fatalf("reservation %d: have %d, %v, want %d", i, nonce, err, want)
+		}
+	}
+	if nonce, _ := l.reserveLendingNonces(user, 3); nonce != 7 {
+		t.Errorf("wrong first nonce of a batch: have %d, want 7", nonce)
+	}
+	// the nonces are given back if nothing was assigned since, and persisted across restarts
+	l.releaseLendingNonces(user, 7, 3)
+	l = New(tomoX)
+	l.SetLendingPool(pool)
+	if nonce, _ := l.reserveLendingNonces(user, 1); nonce != 7 {
+		t.Errorf("wrong nonce after a release and a restart: have %d, want 7", nonce)
+	}
+
+	// the pool ahead of the assignments takes over, and so does the pool behind stale assignments
+	pool.state.SetNonce(user.Hash(), 20)
+	if nonce, _ := l.reserveLendingNonces(user, 1); nonce != 20 {
+		t.Errorf("wrong nonce behind the pool: have %d, want 20", nonce)
+	}
+	l.saveLendingNonce(user, lendingNonceEntry{Next: 30, Time: time.Now().Add(-2 * lendingNonceTimeout).Unix()})
+	if nonce, _ := l.reserveLendingNonces(user, 1); nonce != 20 {
+		t.Errorf("wrong nonce after stale assignments: have %d, want 20", nonce)
+	}
+
+	// the nonces of a batch which can't be signed are given back
+	if _, err := l.submitLendingItems([]LendingItemArgs{{UserAddress: user}, {UserAddress: user}}); err != errAccountManagerUnavailable {
+		t.Fatalf("wrong error without an account manager: %v", err)
+	}
+	if entry := l.loadLendingNonce(user); entry.Next != 21 {
+		t.Errorf("nonces of a failed batch not given back: next %d, want 21", entry.Next)
+	}
+}
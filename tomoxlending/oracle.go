@@ -0,0 +1,198 @@
+package tomoxlending
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// PriceFeed prices a collateral token in a lending token for liquidations, on behalf of the price
+// oracle of the collateral token (see lendingstate.LendingPriceOracle).
+type PriceFeed interface {
+	// Price returns the price of the collateral token in the lending token at a block, nil if the
+	// feed can't price it in the epoch of the block.
+	Price(tradingStateDB *tradingstate.TradingStateDB, lendingStateDB *lendingstate.LendingStateDB, collateralToken common.Address, lendingToken common.Address, blockNumber uint64, epochLength uint64) *big.Int
+}
+
+// priceFeeds builds the feed of each kind of price oracle.
+var priceFeeds = map[string]func(oracle lendingstate.LendingPriceOracle) PriceFeed{
+	lendingstate.PriceOracleMedian: func(oracle lendingstate.LendingPriceOracle) PriceFeed { return medianPriceFeed(oracle) },
+	lendingstate.PriceOracleTWAP:   func(oracle lendingstate.LendingPriceOracle) PriceFeed { return twapPriceFeed(oracle) },
+	lendingstate.PriceOracleSigned: func(oracle lendingstate.LendingPriceOracle) PriceFeed { return signedPriceFeed(oracle) },
+}
+
+// medianPriceFeed is the median of the prices reported during the epoch by the reporters.
+type medianPriceFeed lendingstate.LendingPriceOracle
+
+func (f medianPriceFeed) Price(tradingStateDB *tradingstate.TradingStateDB, lendingStateDB *lendingstate.LendingStateDB, collateralToken common.Address, lendingToken common.Address, blockNumber uint64, epochLength uint64) *big.Int {
+	prices := []*big.Int{}
+	for _, report := range epochPriceReports(lendingStateDB, f.Reporters, collateralToken, lendingToken, blockNumber, epochLength) {
+		prices = append(prices, report.Price)
+	}
+	if len(prices) == 0 || uint64(len(prices)) < f.MinReports {
+		return nil
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Cmp(prices[j]) < 0
+	})
+	middle := len(prices) / 2
+	if len(prices)%2 == 1 {
+		return new(big.Int).Set(prices[middle])
+	}
+	median := new(big.Int).Add(prices[middle-1], prices[middle])
+	return median.Div(median, common.Big2)
+}
+
+// twapPriceFeed is the time weighted average price of the tomox pair of the collateral token and
+// the lending token over the last epochs before the current one (see tradingstate.Twap).
+type twapPriceFeed lendingstate.LendingPriceOracle
+
+func (f twapPriceFeed) Price(tradingStateDB *tradingstate.TradingStateDB, lendingStateDB *lendingstate.LendingStateDB, collateralToken common.Address, lendingToken common.Address, blockNumber uint64, epochLength uint64) *big.Int {
+	epoch := blockNumber / epochLength
+	if epoch == 0 {
+		return nil
+	}
+	return tradingStateDB.GetTwap(tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken), epoch-1, f.Epochs).Twap
+}
+
+// signedPriceFeed is the last price of the epoch signed by one of the signers.
+type signedPriceFeed lendingstate.LendingPriceOracle
+
+func (f signedPriceFeed) Price(tradingStateDB *tradingstate.TradingStateDB, lendingStateDB *lendingstate.LendingStateDB, collateralToken common.Address, lendingToken common.Address, blockNumber uint64, epochLength uint64) *big.Int {
+	var last *lendingstate.LendingPriceReport
+	for _, report := range epochPriceReports(lendingStateDB, f.Reporters, collateralToken, lendingToken, blockNumber, epochLength) {
+		if last == nil || report.Time > last.Time {
+			last = report
+		}
+	}
+	if last == nil {
+		return nil
+	}
+	return last.Price
+}
+
+// epochPriceReports returns the last reports of the reporters of a price oracle made during the
+// epoch of a block.
+func epochPriceReports(lendingStateDB *lendingstate.LendingStateDB, reporters []common.Address, collateralToken common.Address, lendingToken common.Address, blockNumber uint64, epochLength uint64) []*lendingstate.LendingPriceReport {
+	reports := []*lendingstate.LendingPriceReport{}
+	for _, reporter := range reporters {
+		report := lendingStateDB.GetPriceReport(collateralToken, lendingToken, reporter)
+		if report.Price != nil && report.BlockNumber/epochLength == blockNumber/epochLength {
+			reports = append(reports, &report)
+		}
+	}
+	return reports
+}
+
+// oraclePrice returns the price of a collateral token in a lending token given by the price oracle
+// of the collateral token, nil if it has none or the oracle can't price it in the current epoch.
+func oraclePrice(header *types.Header, chain consensus.ChainContext, tradingStateDB *tradingstate.TradingStateDB, lendingStateDB *lendingstate.LendingStateDB, collateralToken common.Address, lendingToken common.Address) *big.Int {
+	if !chain.Config().IsTIPTomoXPriceOracle(header.Number) {
+		return nil
+	}
+	oracle := lendingStateDB.GetPriceOracle(collateralToken)
+	newFeed, ok := priceFeeds[oracle.Kind]
+	if !ok {
+		return nil
+	}
+	price := newFeed(oracle).Price(tradingStateDB, lendingStateDB, collateralToken, lendingToken, header.Number.Uint64(), chain.Config().Posv.Epoch)
+	if price == nil || price.Sign() <= 0 {
+		return nil
+	}
+	return price
+}
+
+// GetLiquidationPrices is GetCollateralPrices for the liquidations: since TIPTomoXPriceOracle the
+// price of a collateral token with a price oracle is given by the oracle, unless it can't price the
+// collateral in the current epoch.
+func (l *Lending) GetLiquidationPrices(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingStateDB *lendingstate.LendingStateDB, collateralToken common.Address, lendingToken common.Address) (*big.Int, *big.Int, error) {
+	if price := oraclePrice(header, chain, tradingStateDb, lendingStateDB, collateralToken, lendingToken); price != nil {
+		log.Debug("Getting collateral/lending token price from price oracle", "collateralToken", collateralToken.Hex(), "lendingToken", lendingToken.Hex(), "price", price)
+		lendTokenTOMOPrice, err := l.GetTOMOBasePrices(header, chain, statedb, tradingStateDb, lendingToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		return lendTokenTOMOPrice, price, nil
+	}
+	return l.GetCollateralPrices(header, chain, statedb, tradingStateDb, collateralToken, lendingToken)
+}
+
+// processPriceReport records the price reported by a PriceReport item: the reporter is the sender
+// of the item, or the signer of its ExtraData for the signed feeds. Signed reports must be newer
+// than the last report of their signer and not signed after the block.
+func processPriceReport(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, order *lendingstate.LendingItem) error {
+	if order.CollateralToken == (common.Address{}) || order.CollateralToken == order.LendingToken {
+		return &lendingstate.RejectError{Reason: lendingstate.RejectReasonInvalidCollateral, Err: fmt.Errorf("invalid reported collateral %s", order.CollateralToken.Hex())}
+	}
+	oracle := lendingStateDB.GetPriceOracle(order.CollateralToken)
+	report := lendingstate.LendingPriceReport{Reporter: order.UserAddress, Price: order.Quantity, BlockNumber: header.Number.Uint64()}
+	switch {
+	case oracle.Kind == lendingstate.PriceOracleMedian && order.ExtraData == "":
+	case oracle.Kind == lendingstate.PriceOracleSigned && order.ExtraData != "":
+		signer, time, err := lendingstate.RecoverPriceReporter(order.CollateralToken, order.LendingToken, order.Quantity, order.ExtraData)
+		if err != nil {
+			return &lendingstate.RejectError{Reason: lendingstate.RejectReasonInvalidPriceReport, Err: err}
+		}
+		if last := lendingStateDB.GetPriceReport(order.CollateralToken, order.LendingToken, signer); time <= last.Time || time > header.Time.Uint64() {
+			return &lendingstate.RejectError{Reason: lendingstate.RejectReasonInvalidPriceReport, Err: fmt.Errorf("stale signed price report. Time: %d. Last: %d", time, last.Time)}
+		}
+		report.Reporter, report.Time = signer, time
+	default:
+		return &lendingstate.RejectError{Reason: lendingstate.RejectReasonInvalidPriceReport, Err: fmt.Errorf("price report not accepted by the %q price oracle of %s", oracle.Kind, order.CollateralToken.Hex())}
+	}
+	if !lendingStateDB.IsPriceReporter(order.CollateralToken, report.Reporter) {
+		return &lendingstate.RejectError{Reason: lendingstate.RejectReasonInvalidPriceReport, Err: fmt.Errorf("price reporter %s not registered", report.Reporter.Hex())}
+	}
+	lendingStateDB.SetPriceReport(order.CollateralToken, order.LendingToken, report)
+	log.Debug("Record price report", "collateralToken", order.CollateralToken.Hex(), "lendingToken", order.LendingToken.Hex(), "reporter", report.Reporter.Hex(), "price", report.Price)
+	return nil
+}
+
+// PriceOracleQuote is the RPC representation of the price oracle of a collateral token, with the
+// last reports of its reporters and the price it gives in a lending token in the current block.
+type PriceOracleQuote struct {
+	CollateralToken common.Address                    `json:"collateralToken"`
+	LendingToken    common.Address                    `json:"lendingToken"`
+	Oracle          lendingstate.LendingPriceOracle   `json:"oracle"`
+	Reports         []lendingstate.LendingPriceReport `json:"reports"`
+	Price           *big.Int                          `json:"price"` // nil if the oracle can't price the collateral in the current epoch
+}
+
+// priceOracleQuote returns the price oracle of a collateral token at the current block.
+func (l *Lending) priceOracleQuote(collateralToken common.Address, lendingToken common.Address) (*PriceOracleQuote, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := l.tomox.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	quote := &PriceOracleQuote{
+		CollateralToken: collateralToken,
+		LendingToken:    lendingToken,
+		Oracle:          lendingState.GetPriceOracle(collateralToken),
+		Reports:         []lendingstate.LendingPriceReport{},
+	}
+	if quote.Oracle.Kind != lendingstate.PriceOracleTWAP {
+		for _, reporter := range quote.Oracle.Reporters {
+			if report := lendingState.GetPriceReport(collateralToken, lendingToken, reporter); report.Price != nil {
+				quote.Reports = append(quote.Reports, report)
+			}
+		}
+	}
+	quote.Price = oraclePrice(block.Header(), l.chain, tradingState, lendingState, collateralToken, lendingToken)
+	return quote, nil
+}
@@ -0,0 +1,128 @@
+package tomoxlending
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestPriceFeeds(t *testing.T) {
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		collateralToken = common.HexToAddress("0x20")
+		lendingToken    = common.HexToAddress("0x30")
+		reporters       = []common.Address{common.HexToAddress("0x11"), common.HexToAddress("0x12"), common.HexToAddress("0x13"), common.HexToAddress("0x14")}
+		epochLength     = uint64(900)
+	)
+	for i, price := range []int64{130, 100, 120} {
+		lendingStateDB.SetPriceReport(collateralToken, lendingToken, lendingstate.LendingPriceReport{Reporter: reporters[i], Price: big.NewInt(price), BlockNumber: 1800 + uint64(i), Time: uint64(10 - i)})
+	}
+	// reported during the last epoch
+	lendingStateDB.SetPriceReport(collateralToken, lendingToken, lendingstate.LendingPriceReport{Reporter: reporters[3], Price: big.NewInt(1), BlockNumber: 1799, Time: 20})
+
+	for _, test := range []struct {
+		oracle   lendingstate.LendingPriceOracle
+		expected *big.Int
+	}{
+		{lendingstate.LendingPriceOracle{Kind: lendingstate.PriceOracleMedian, MinReports: 3, Reporters: reporters}, big.NewInt(120)},
+		{lendingstate.LendingPriceOracle{Kind: lendingstate.PriceOracleMedian, MinReports: 2, Reporters: reporters[:2]}, big.NewInt(115)},
+		{lendingstate.LendingPriceOracle{Kind: lendingstate.PriceOracleMedian, MinReports: 4, Reporters: reporters}, nil},
+		{lendingstate.LendingPriceOracle{Kind: lendingstate.PriceOracleSigned, Reporters: reporters}, big.NewInt(130)},
+		{lendingstate.LendingPriceOracle{Kind: lendingstate.PriceOracleSigned, Reporters: reporters[3:]}, nil},
+	} {
+		price := priceFeeds[test.oracle.Kind](test.oracle).Price(tradingStateDB, lendingStateDB, collateralToken, lendingToken, 2000, epochLength)
+		if fmt.Sprint(price) != fmt.Sprint(test.expected) {
+			t.Errorf("%v: have price %v, want %v", test.oracle, price, test.expected)
+		}
+	}
+
+	twap := lendingstate.LendingPriceOracle{Kind: lendingstate.PriceOracleTWAP, Epochs: 2}
+	if price := priceFeeds[twap.Kind](twap).Price(tradingStateDB, lendingStateDB, collateralToken, lendingToken, 2000, epochLength); price != nil {
+		t.Errorf("TWAP price without recorded prices: %v", price)
+	}
+	orderBook := tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+	tradingStateDB.AddTwapTrade(orderBook, 0, big.NewInt(100), big.NewInt(1))
+	tradingStateDB.AddTwapTrade(orderBook, 1, big.NewInt(200), big.NewInt(1))
+	// the trades of the current epoch are left out
+	tradingStateDB.AddTwapTrade(orderBook, 2, big.NewInt(1000), big.NewInt(1))
+	if price := priceFeeds[twap.Kind](twap).Price(tradingStateDB, lendingStateDB, collateralToken, lendingToken, 2000, epochLength); price.Int64() != 150 {
+		t.Errorf("wrong TWAP price: have %v, want 150", price)
+	}
+}
+
+func TestProcessPriceReport(t *testing.T) {
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		collateralToken = common.HexToAddress("0x20")
+		lendingToken    = common.HexToAddress("0x30")
+		reporter        = common.HexToAddress("0x11")
+		relayer         = common.HexToAddress("0x12")
+		header          = &types.Header{Number: big.NewInt(1000), Time: big.NewInt(1600000000)}
+	)
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	sign := func(price *big.Int, time uint64) string {
+		message := crypto.Keccak256(
+			[]byte("\x19Ethereum Signed Message:\n32"),
+			lendingstate.PriceReportHash(collateralToken, lendingToken, price, time).Bytes(),
+		)
+		sig, _ := crypto.Sign(message, key)
+		return fmt.Sprintf("%d:%s", time, hexutil.Encode(sig))
+	}
+	report := func(user common.Address, price int64, extraData string) *lendingstate.LendingItem {
+		return &lendingstate.LendingItem{
+			UserAddress:     user,
+			CollateralToken: collateralToken,
+			LendingToken:    lendingToken,
+			Quantity:        big.NewInt(price),
+			ExtraData:       extraData,
+			Type:            lendingstate.PriceReport,
+		}
+	}
+
+	// no oracle
+	if err := processPriceReport(header, lendingStateDB, report(reporter, 100, "")); err == nil {
+		t.Fatalf("price report accepted without oracle")
+	}
+	lendingStateDB.SetPriceOracle(collateralToken, lendingstate.LendingPriceOracle{Kind: lendingstate.PriceOracleMedian, MinReports: 1, Reporters: []common.Address{reporter}})
+	if err := processPriceReport(header, lendingStateDB, report(relayer, 100, "")); err == nil {
+		t.Errorf("price report of an unregistered reporter accepted")
+	}
+	if err := processPriceReport(header, lendingStateDB, report(reporter, 100, "")); err != nil {
+		t.Fatalf("failed to process price report: %v", err)
+	}
+	if last := lendingStateDB.GetPriceReport(collateralToken, lendingToken, reporter); last.Price.Int64() != 100 || last.BlockNumber != 1000 {
+		t.Errorf("price report not recorded: %v", last)
+	}
+
+	// signed reports are relayed by anyone
+	lendingStateDB.SetPriceOracle(collateralToken, lendingstate.LendingPriceOracle{Kind: lendingstate.PriceOracleSigned, Reporters: []common.Address{signer}})
+	if err := processPriceReport(header, lendingStateDB, report(signer, 100, "")); err == nil {
+		t.Errorf("unsigned price report accepted by a signed oracle")
+	}
+	if err := processPriceReport(header, lendingStateDB, report(relayer, 100, sign(big.NewInt(100), 1600000001))); err == nil {
+		t.Errorf("price report signed after the block accepted")
+	}
+	if err := processPriceReport(header, lendingStateDB, report(relayer, 101, sign(big.NewInt(100), 1599999990))); err == nil {
+		t.Errorf("price report with a forged price accepted")
+	}
+	if err := processPriceReport(header, lendingStateDB, report(relayer, 100, sign(big.NewInt(100), 1599999990))); err != nil {
+		t.Fatalf("failed to process signed price report: %v", err)
+	}
+	// replayed report
+	if err := processPriceReport(header, lendingStateDB, report(relayer, 100, sign(big.NewInt(100), 1599999990))); err == nil {
+		t.Errorf("replayed price report accepted")
+	}
+	if last := lendingStateDB.GetPriceReport(collateralToken, lendingToken, signer); last.Price.Int64() != 100 || last.Time != 1599999990 {
+		t.Errorf("signed price report not recorded: %v", last)
+	}
+}
@@ -44,6 +44,15 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 	log.Debug("Exchange add user nonce:", "address", order.UserAddress, "status", order.Status, "nonce", nonce+1)
 	lendingStateDB.SetNonce(order.UserAddress.Hash(), nonce+1)
 
+	var expired []*lendingstate.LendingItem
+	if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		// remove the expired good-till-time items before processing the item
+		if expired, err = sweepExpiredLendingItems(header, lendingStateDB, lendingOrderBook); err != nil {
+			return nil, nil, err
+		}
+		rejects = append(rejects, expired...)
+	}
+
 	lendingSnap := lendingStateDB.Snapshot()
 	tradingSnap := tradingStateDb.Snapshot()
 	dbSnap := statedb.Snapshot()
@@ -56,17 +65,87 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		}
 	}()
 
-	if err := order.VerifyLendingItem(statedb); err != nil {
+	if (order.Type == lendingstate.StopLimit || order.Type == lendingstate.TopUpReserve || order.Type == lendingstate.AuctionBid || order.Type == lendingstate.AddCollateral || order.Type == lendingstate.Rollover || order.Type == lendingstate.Iceberg || order.Type == lendingstate.CircuitBreaker || order.Type == lendingstate.RateModel || order.Type == lendingstate.Referral) && !chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		log.Debug("Reject lending order type before TIPTomoXLendingV2", "order", lendingstate.ToJSON(order))
+		rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidType))
+		return trades, rejects, nil
+	}
+	if order.Type == lendingstate.Margin && !chain.Config().IsTIPTomoXMargin(header.Number) {
+		log.Debug("Reject margin order before TIPTomoXMargin", "order", lendingstate.ToJSON(order))
+		rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidType))
+		return trades, rejects, nil
+	}
+	if order.Type == lendingstate.InterestMode && !chain.Config().IsTIPTomoXPeriodicInterest(header.Number) {
+		log.Debug("Reject interest mode before TIPTomoXPeriodicInterest", "order", lendingstate.ToJSON(order))
+		rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidType))
+		return trades, rejects, nil
+	}
+	if (order.Type == lendingstate.PriceOracle || order.Type == lendingstate.PriceReport) && !chain.Config().IsTIPTomoXPriceOracle(header.Number) {
+		log.Debug("Reject price oracle item before TIPTomoXPriceOracle", "order", lendingstate.ToJSON(order))
+		rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidType))
+		return trades, rejects, nil
+	}
+	if order.Status == lendingstate.LendingStatusAmended && !chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		log.Debug("Reject lending amendment before TIPTomoXLendingV2", "order", lendingstate.ToJSON(order))
+		rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidStatus))
+		return trades, rejects, nil
+	}
+	if err := order.VerifyLendingItem(statedb, types.MakeLendingSigner(chain.Config(), header.Number)); err != nil {
 		log.Debug("invalid lending order", "order", lendingstate.ToJSON(order), "err", err)
-		rejects = append(rejects, order)
+		rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonUnknown)))
 		return trades, rejects, nil
 	}
+	if order.Status == lendingstate.LendingStatusNew && isMatchingType(order.Type) && chain.Config().IsTIPTomoXLendingReplayProtection(header.Number) {
+		if err := order.VerifyLendingHash(types.MakeLendingTxSigner(chain.Config(), header.Number)); err != nil {
+			log.Debug("invalid lending order hash", "order", lendingstate.ToJSON(order), "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidHash))
+			return trades, rejects, nil
+		}
+		if number := lendingStateDB.GetLendingHashConsumedAt(order.Hash); number > 0 {
+			log.Debug("Reject replayed lending order", "hash", order.Hash.Hex(), "createdAt", number)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonReplayed))
+			return trades, rejects, nil
+		}
+		lendingStateDB.ConsumeLendingHash(order.Hash, header.Number.Uint64())
+	}
+	timeInForce := lendingstate.TimeInForceGTC
+	if order.Type == lendingstate.Limit && order.Status == lendingstate.LendingStatusNew && chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		if err := order.VerifyLendingTimeInForce(); err != nil {
+			log.Debug("invalid lending order time in force", "order", lendingstate.ToJSON(order), "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidTimeInForce))
+			return trades, rejects, nil
+		}
+		var expiry uint64
+		if timeInForce, expiry = order.TimeInForce(); timeInForce == lendingstate.TimeInForceGTT && expiry <= header.Time.Uint64() {
+			log.Debug("Reject expired good-till-time order", "expiry", expiry, "time", header.Time)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonExpired))
+			return trades, rejects, nil
+		}
+		if timeInForce == lendingstate.TimeInForcePO && !chain.Config().IsTIPTomoXPostOnly(header.Number) {
+			log.Debug("Reject post-only order before TIPTomoXPostOnly")
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidTimeInForce))
+			return trades, rejects, nil
+		}
+	}
+	if order.Status == lendingstate.LendingStatusNew && chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		if referrer := order.Referrer(); referrer != (common.Address{}) && lendingStateDB.GetReferralShare(order.Relayer, referrer).Sign() == 0 {
+			log.Debug("Reject lending order of an unregistered referrer", "relayer", order.Relayer.Hex(), "referrer", referrer.Hex())
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidReferrer))
+			return trades, rejects, nil
+		}
+	}
+
+	if chain.Config().IsTIPTomoXLendingV2(header.Number) && (order.Status == lendingstate.LendingStatusNew || order.Status == lendingstate.LendingStatusAmended) && isMatchingType(order.Type) && lendingStateDB.IsLendingBookPaused(lendingOrderBook, header.Number.Uint64()) {
+		log.Debug("Lending book paused by circuit breaker", "lendingBook", lendingOrderBook.Hex(), "order", order.Hash.Hex())
+		err = ErrLendingBookPaused
+		return nil, nil, err
+	}
 
 	switch order.Type {
 	case lendingstate.TopUp:
 		err, reject, newLendingTrade := l.ProcessTopUp(lendingStateDB, statedb, tradingStateDb, order)
 		if err != nil || reject {
-			rejects = append(rejects, order)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonInvalidTrade)))
 		}
 		trades = append(trades, newLendingTrade)
 		return trades, rejects, nil
@@ -74,17 +153,128 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		lendingTrade, err := l.ProcessRepay(header, chain, lendingStateDB, statedb, tradingStateDb, lendingOrderBook, order)
 		if err != nil {
 			log.Debug("Can not process payment", "err", err)
-			rejects = append(rejects, order)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonInvalidTrade)))
 		}
 		trades = append(trades, lendingTrade)
 		return trades, rejects, nil
+	case lendingstate.TopUpReserve:
+		lendingStateDB.SetTopUpReserve(order.UserAddress, order.CollateralToken, order.Quantity)
+		log.Debug("Set top-up reserve", "user", order.UserAddress.Hex(), "collateral", order.CollateralToken.Hex(), "reserve", order.Quantity)
+		return trades, rejects, nil
+	case lendingstate.CircuitBreaker:
+		lendingStateDB.SetCircuitBreaker(lendingOrderBook, order.Quantity, order.Interest)
+		log.Debug("Set lending circuit breaker", "lendingBook", lendingOrderBook.Hex(), "threshold", order.Quantity, "pause", order.Interest)
+		return trades, rejects, nil
+	case lendingstate.RateModel:
+		// the model has been checked by VerifyLendingItem
+		model, _ := lendingstate.ParseLendingRateModel(order.ExtraData)
+		lendingStateDB.SetLendingRateModel(lendingOrderBook, model)
+		log.Debug("Set lending rate model", "lendingBook", lendingOrderBook.Hex(), "model", order.ExtraData)
+		return trades, rejects, nil
+	case lendingstate.InterestMode:
+		lendingStateDB.SetPeriodicInterest(lendingOrderBook, order.Quantity.Sign() > 0)
+		log.Debug("Set lending interest mode", "lendingBook", lendingOrderBook.Hex(), "periodic", order.Quantity.Sign() > 0)
+		return trades, rejects, nil
+	case lendingstate.PriceOracle:
+		// the oracle has been checked by VerifyLendingItem
+		oracle, _ := lendingstate.ParsePriceOracle(order.ExtraData)
+		lendingStateDB.SetPriceOracle(order.CollateralToken, oracle)
+		log.Debug("Set price oracle", "collateralToken", order.CollateralToken.Hex(), "oracle", order.ExtraData)
+		return trades, rejects, nil
+	case lendingstate.PriceReport:
+		if err := processPriceReport(header, lendingStateDB, order); err != nil {
+			log.Debug("Can not process price report", "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonInvalidPriceReport)))
+		}
+		return trades, rejects, nil
+	case lendingstate.Referral:
+		// the referrer has been checked by VerifyLendingItem
+		referrer, _ := lendingstate.ParseLendingReferral(order.ExtraData)
+		lendingStateDB.SetReferralShare(order.Relayer, referrer, order.Quantity)
+		log.Debug("Set lending referral", "relayer", order.Relayer.Hex(), "referrer", referrer.Hex(), "share", order.Quantity)
+		return trades, rejects, nil
+	case lendingstate.AuctionBid:
+		auction, err := l.ProcessAuctionBid(header, chain, lendingStateDB, statedb, lendingOrderBook, order)
+		if err != nil {
+			log.Debug("Can not process auction bid", "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonInvalidTrade)))
+			return trades, rejects, nil
+		}
+		trades = append(trades, auction)
+		return trades, rejects, nil
+	case lendingstate.AddCollateral:
+		newLendingTrade, err := l.ProcessAddCollateral(header, chain, lendingStateDB, statedb, tradingStateDb, lendingOrderBook, order)
+		if err != nil {
+			log.Debug("Can not add collateral", "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonInvalidTrade)))
+			return trades, rejects, nil
+		}
+		trades = append(trades, newLendingTrade)
+		return trades, rejects, nil
+	case lendingstate.Recall:
+		// before TIPTomoXLendingV2, recall items are processed like any other item
+		if !chain.Config().IsTIPTomoXLendingV2(header.Number) {
+			break
+		}
+		newLendingTrade, err := l.ProcessRecall(header, chain, lendingStateDB, statedb, tradingStateDb, lendingOrderBook, order)
+		if err != nil {
+			log.Debug("Can not process recall", "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonInvalidTrade)))
+			return trades, rejects, nil
+		}
+		trades = append(trades, newLendingTrade)
+		return trades, rejects, nil
+	case lendingstate.Rollover:
+		lendingTrade, err := l.ProcessRollover(header, lendingStateDB, lendingOrderBook, order)
+		if err != nil {
+			log.Debug("Can not process rollover", "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonInvalidTrade)))
+			return trades, rejects, nil
+		}
+		trades = append(trades, lendingTrade)
+		return trades, rejects, nil
+	case lendingstate.Margin:
+		newTrades, newRejects, err := l.ProcessMarginOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order)
+		if err != nil {
+			lendingStateDB.RevertToSnapshot(lendingSnap)
+			tradingStateDb.RevertToSnapshot(tradingSnap)
+			statedb.RevertToSnapshot(dbSnap)
+			log.Debug("Can not process margin order", "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonMarginNotFilled)))
+			return trades, rejects, nil
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+		recordCircuitBreakerTrades(header, lendingStateDB, lendingOrderBook, trades)
+		return trades, rejects, nil
 	default:
 	}
 
+	if order.Status == lendingstate.LendingStatusAmended {
+		var (
+			newTrades  []*lendingstate.LendingTrade
+			newRejects []*lendingstate.LendingItem
+		)
+		newTrades, newRejects, err = l.ProcessAmendOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order)
+		if err != nil {
+			log.Debug("Can not amend order", "err", err)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonAmendFailed))
+			return trades, rejects, nil
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+		// the amended item may have moved the best interest rates across the trigger of stop-limit items
+		stopTrades, stopRejects := l.processTriggeredStopOrders(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook)
+		trades = append(trades, stopTrades...)
+		rejects = append(rejects, stopRejects...)
+		recordCircuitBreakerTrades(header, lendingStateDB, lendingOrderBook, trades)
+		return trades, rejects, nil
+	}
+
 	if order.Status == lendingstate.LendingStatusCancelled {
 		err, reject := l.ProcessCancelOrder(header, lendingStateDB, statedb, tradingStateDb, chain, coinbase, lendingOrderBook, order)
 		if err != nil || reject {
-			rejects = append(rejects, order)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonCancelFailed)))
 		}
 		return trades, rejects, nil
 	}
@@ -92,13 +282,13 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 	if order.Type != lendingstate.Market {
 		if order.Interest.Sign() == 0 || common.BigToHash(order.Interest).Big().Cmp(order.Interest) != 0 {
 			log.Debug("Reject order Interest invalid", "Interest", order.Interest)
-			rejects = append(rejects, order)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidInterest))
 			return trades, rejects, nil
 		}
 	}
 	if order.Quantity.Sign() == 0 || common.BigToHash(order.Quantity).Big().Cmp(order.Quantity) != 0 {
 		log.Debug("Reject order quantity invalid", "quantity", order.Quantity)
-		rejects = append(rejects, order)
+		rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonInvalidQuantity))
 		return trades, rejects, nil
 	}
 	orderType := order.Type
@@ -108,20 +298,49 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		trades, rejects, err = l.processMarketOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order)
 		if err != nil {
 			trades = []*lendingstate.LendingTrade{}
-			rejects = append(rejects, order)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonUnknown)))
+		}
+	} else if orderType == lendingstate.StopLimit {
+		log.Debug("Process stop-limit order", "side", order.Side, "quantity", order.Quantity, "Interest", order.Interest, "trigger", order.ExtraData)
+		trades, rejects, err = l.processStopLimitOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order)
+		if err != nil {
+			trades = []*lendingstate.LendingTrade{}
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonUnknown)))
+		}
+	} else if timeInForce == lendingstate.TimeInForceFOK {
+		log.Debug("Process fill-or-kill order", "side", order.Side, "quantity", order.Quantity, "Interest", order.Interest)
+		trades, rejects, err = l.processFillOrKillOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order)
+		if err != nil {
+			trades = []*lendingstate.LendingTrade{}
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonUnknown)))
 		}
 	} else {
 		log.Debug("Process limit order", "side", order.Side, "quantity", order.Quantity, "Interest", order.Interest)
 		trades, rejects, err = l.processLimitOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order)
 		if err != nil {
 			trades = []*lendingstate.LendingTrade{}
-			rejects = append(rejects, order)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonUnknown)))
 		}
 	}
+	rejects = append(expired, rejects...)
+	if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		// the item may have moved the best interest rates across the trigger of stop-limit items
+		stopTrades, stopRejects := l.processTriggeredStopOrders(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook)
+		trades = append(trades, stopTrades...)
+		rejects = append(rejects, stopRejects...)
+		recordCircuitBreakerTrades(header, lendingStateDB, lendingOrderBook, trades)
+	}
 	return trades, rejects, nil
 }
 
+// rejectLendingItem records the reason why the lending item is rejected, and returns it.
+func rejectLendingItem(item *lendingstate.LendingItem, reason string) *lendingstate.LendingItem {
+	item.RejectReason = reason
+	return item
+}
+
 // processMarketOrder : process the market order
+// Since TIPTomoXLendingV2, the rate model of the lending book bounds the interests the order is matched at.
 func (l *Lending) processMarketOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
 	var (
 		trades     []*lendingstate.LendingTrade
@@ -134,10 +353,16 @@ func (l *Lending) processMarketOrder(header *types.Header, coinbase common.Addre
 	side := order.Side
 	// speedup the comparison, do not assign because it is pointer
 	zero := lendingstate.Zero
+	var modelRate *big.Int
+	if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		if modelRate, err = marketRateLimit(lendingStateDB, lendingOrderBook); err != nil {
+			return nil, nil, err
+		}
+	}
 	if side == lendingstate.Borrowing {
 		bestInterest, volume := lendingStateDB.GetBestInvestingRate(lendingOrderBook)
-		log.Debug("processMarketOrder ", "side", side, "bestInterest", bestInterest, "quantityToTrade", quantityToTrade, "volume", volume)
-		for quantityToTrade.Cmp(zero) > 0 && bestInterest.Cmp(zero) > 0 {
+		log.Debug("processMarketOrder ", "side", side, "bestInterest", bestInterest, "quantityToTrade", quantityToTrade, "volume", volume, "modelRate", modelRate)
+		for quantityToTrade.Cmp(zero) > 0 && bestInterest.Cmp(zero) > 0 && (modelRate == nil || bestInterest.Cmp(modelRate) <= 0) {
 			quantityToTrade, newTrades, newRejects, err = l.processOrderList(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingstate.Investing, lendingOrderBook, bestInterest, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
@@ -149,8 +374,8 @@ func (l *Lending) processMarketOrder(header *types.Header, coinbase common.Addre
 		}
 	} else {
 		bestInterest, volume := lendingStateDB.GetBestBorrowRate(lendingOrderBook)
-		log.Debug("processMarketOrder ", "side", side, "bestInterest", bestInterest, "quantityToTrade", quantityToTrade, "volume", volume)
-		for quantityToTrade.Cmp(zero) > 0 && bestInterest.Cmp(zero) > 0 {
+		log.Debug("processMarketOrder ", "side", side, "bestInterest", bestInterest, "quantityToTrade", quantityToTrade, "volume", volume, "modelRate", modelRate)
+		for quantityToTrade.Cmp(zero) > 0 && bestInterest.Cmp(zero) > 0 && (modelRate == nil || bestInterest.Cmp(modelRate) >= 0) {
 			quantityToTrade, newTrades, newRejects, err = l.processOrderList(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingstate.Borrowing, lendingOrderBook, bestInterest, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
@@ -180,6 +405,10 @@ func (l *Lending) processLimitOrder(header *types.Header, coinbase common.Addres
 
 	// speedup the comparison, do not assign because it is pointer
 	zero := lendingstate.Zero
+	if tif, _ := order.TimeInForce(); tif == lendingstate.TimeInForcePO && postOnlyItemCrosses(lendingStateDB, lendingOrderBook, order) {
+		log.Debug("Reject post-only order crossing the spread", "side", side, "Interest", Interest)
+		return nil, []*lendingstate.LendingItem{rejectLendingItem(order, lendingstate.RejectReasonPostOnly)}, nil
+	}
 	if side == lendingstate.Borrowing {
 		minInterest, volume := lendingStateDB.GetBestInvestingRate(lendingOrderBook)
 		log.Debug("processLimitOrder ", "side", side, "minInterest", minInterest, "orderInterest", Interest, "volume", volume)
@@ -211,13 +440,22 @@ func (l *Lending) processLimitOrder(header *types.Header, coinbase common.Addres
 			log.Debug("processLimitOrder ", "side", side, "maxInterest", maxInterest, "orderInterest", Interest, "volume", volume)
 		}
 	}
+	if quantityToTrade.Cmp(zero) > 0 && chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		// the unmatched part of immediate-or-cancel and fill-or-kill items is not added to the lending book
+		if tif, _ := order.TimeInForce(); tif == lendingstate.TimeInForceIOC || tif == lendingstate.TimeInForceFOK {
+			log.Debug("Reject unmatched part of order", "timeInForce", tif, "quantity", quantityToTrade)
+			rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonTimeInForce))
+			return trades, rejects, nil
+		}
+	}
 	if quantityToTrade.Cmp(zero) > 0 {
+		// the next LendingId of the book puts the item at the end of the queue of its interest level
 		oldOrderId := lendingStateDB.GetNonce(lendingOrderBook)
 		order.LendingId = oldOrderId + 1
 		order.Quantity = quantityToTrade
 		lendingStateDB.SetNonce(lendingOrderBook, oldOrderId+1)
-		orderIdHash := common.BigToHash(new(big.Int).SetUint64(order.LendingId))
-		lendingStateDB.InsertLendingItem(lendingOrderBook, orderIdHash, *order)
+		insertLendingItem(lendingStateDB, lendingOrderBook, order)
+		insertLendingExpiry(lendingStateDB, lendingOrderBook, order)
 		log.Debug("After matching, order (unmatched part) is now added to tree", "side", order.Side, "order", order)
 		investingRate, investingVolume := lendingStateDB.GetBestInvestingRate(lendingOrderBook)
 		borrowingRate, borrowingVolume := lendingStateDB.GetBestBorrowRate(lendingOrderBook)
@@ -227,6 +465,8 @@ func (l *Lending) processLimitOrder(header *types.Header, coinbase common.Addres
 }
 
 // processOrderList : process the order list
+// The items of the interest level are matched by increasing LendingId, i.e. in the order they
+// entered the lending book (see GetBestLendingIdAndAmount), until the quantity is traded.
 func (l *Lending) processOrderList(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, side string, lendingOrderBook common.Hash, Interest *big.Int, quantityStillToTrade *big.Int, order *lendingstate.LendingItem) (*big.Int, []*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
 	quantityToTrade := lendingstate.CloneBigInt(quantityStillToTrade)
 	log.Debug("Process matching between order and orderlist", "quantityToTrade", quantityToTrade)
@@ -247,6 +487,22 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 		if oldestOrder.Quantity == nil || oldestOrder.Quantity.Sign() == 0 && amount.Sign() == 0 {
 			break
 		}
+		if oldestOrder.UserAddress == order.UserAddress && chain.Config().IsTIPTomoXLendingV2(header.Number) {
+			rejectTaker, rejectMaker := selfTradeRejects(lendingstate.SelfTradePrevention)
+			log.Debug("Self-trade", "user", order.UserAddress.Hex(), "policy", lendingstate.SelfTradePrevention, "lending id", oldestOrder.LendingId)
+			if rejectMaker {
+				rejects = append(rejects, rejectLendingItem(&oldestOrder, lendingstate.RejectReasonSelfTrade))
+				if err := cancelLendingItem(lendingStateDB, lendingOrderBook, &oldestOrder); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			if rejectTaker {
+				rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonSelfTrade))
+				quantityToTrade = lendingstate.Zero
+				break
+			}
+			continue
+		}
 		var (
 			tradedQuantity    *big.Int
 			maxTradedQuantity *big.Int
@@ -291,22 +547,22 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 		if err != nil && err == lendingstate.ErrQuantityTradeTooSmall && tradedQuantity != nil && tradedQuantity.Sign() >= 0 {
 			if tradedQuantity.Cmp(maxTradedQuantity) == 0 {
 				if quantityToTrade.Cmp(amount) == 0 { // reject Taker & maker
-					rejects = append(rejects, order)
+					rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonQuantityTooSmall))
 					quantityToTrade = lendingstate.Zero
-					rejects = append(rejects, &oldestOrder)
-					err = lendingStateDB.CancelLendingOrder(lendingOrderBook, &oldestOrder)
+					rejects = append(rejects, rejectLendingItem(&oldestOrder, lendingstate.RejectReasonQuantityTooSmall))
+					err = cancelLendingItem(lendingStateDB, lendingOrderBook, &oldestOrder)
 					log.Debug("Reject order maker", "lending id ", oldestOrder.LendingId, "err", err)
 					if err != nil {
 						return nil, nil, nil, err
 					}
 					break
 				} else if quantityToTrade.Cmp(amount) < 0 { // reject Taker
-					rejects = append(rejects, order)
+					rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonQuantityTooSmall))
 					quantityToTrade = lendingstate.Zero
 					break
 				} else { // reject maker
-					rejects = append(rejects, &oldestOrder)
-					err = lendingStateDB.CancelLendingOrder(lendingOrderBook, &oldestOrder)
+					rejects = append(rejects, rejectLendingItem(&oldestOrder, lendingstate.RejectReasonQuantityTooSmall))
+					err = cancelLendingItem(lendingStateDB, lendingOrderBook, &oldestOrder)
 					log.Debug("Reject order maker", "lending id ", oldestOrder.LendingId, "err", err)
 					if err != nil {
 						return nil, nil, nil, err
@@ -315,15 +571,15 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 				}
 			} else {
 				if rejectMaker { // reject maker
-					rejects = append(rejects, &oldestOrder)
-					err = lendingStateDB.CancelLendingOrder(lendingOrderBook, &oldestOrder)
+					rejects = append(rejects, rejectLendingItem(&oldestOrder, lendingstate.RejectReasonQuantityTooSmall))
+					err = cancelLendingItem(lendingStateDB, lendingOrderBook, &oldestOrder)
 					log.Debug("Reject order maker", "lending id ", oldestOrder.LendingId, "err", err)
 					if err != nil {
 						return nil, nil, nil, err
 					}
 					continue
 				} else { // reject Taker
-					rejects = append(rejects, order)
+					rejects = append(rejects, rejectLendingItem(order, lendingstate.RejectReasonQuantityTooSmall))
 					quantityToTrade = lendingstate.Zero
 					break
 				}
@@ -340,6 +596,11 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 		if tradedQuantity.Sign() > 0 {
 			quantityToTrade = lendingstate.Sub(quantityToTrade, tradedQuantity)
 			lendingStateDB.SubAmountLendingItem(lendingOrderBook, orderId, Interest, tradedQuantity, side)
+			if oldestOrder.Type == lendingstate.Iceberg && tradedQuantity.Cmp(amount) == 0 {
+				if err := refreshIcebergItem(lendingStateDB, lendingOrderBook, &oldestOrder); err != nil {
+					return nil, nil, nil, err
+				}
+			}
 			log.Debug("Update quantity for orderId", "orderId", orderId.Hex())
 			log.Debug("LEND", "lendingOrderBook", lendingOrderBook.Hex(), "Taker Interest", Interest, "maker Interest", order.Interest, "Amount", tradedQuantity, "orderId", orderId, "side", side)
 			tradingId := lendingStateDB.GetTradeNonce(lendingOrderBook) + 1
@@ -404,11 +665,23 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 			lendingStateDB.SetTradeNonce(lendingOrderBook, tradingId)
 			log.Debug("InsertLiquidationPrice", "TradingOrderBookHash", tradingstate.GetTradingOrderBookHash(collateralToken, order.LendingToken).Hex(), "tradingId", tradingId, "lendingOrderBook", lendingOrderBook.Hex(), "liquidationPrice", liquidationPrice)
 			tradingStateDb.InsertLiquidationPrice(tradingstate.GetTradingOrderBookHash(collateralToken, order.LendingToken), liquidationPrice, lendingOrderBook, tradingId)
+			if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+				if err := contributeInsuranceFund(lendingStateDB, statedb, &lendingTrade); err != nil {
+					return nil, nil, nil, err
+				}
+				borrowingOrder := order
+				if order.Side == lendingstate.Investing {
+					borrowingOrder = &oldestOrder
+				}
+				if err := payReferralFee(lendingStateDB, statedb, &lendingTrade, borrowingOrder); err != nil {
+					return nil, nil, nil, err
+				}
+			}
 			trades = append(trades, &lendingTrade)
 		}
 		if rejectMaker {
 			rejects = append(rejects, &oldestOrder)
-			err := lendingStateDB.CancelLendingOrder(lendingOrderBook, &oldestOrder)
+			err := cancelLendingItem(lendingStateDB, lendingOrderBook, &oldestOrder)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -417,6 +690,19 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 	return quantityToTrade, trades, rejects, nil
 }
 
+// selfTradeRejects returns whether the taker and the maker of a self-trade are rejected by the policy.
+// An unknown policy falls back to SelfTradeCancelNewest.
+func selfTradeRejects(policy string) (bool, bool) {
+	switch policy {
+	case lendingstate.SelfTradeCancelOldest:
+		return false, true
+	case lendingstate.SelfTradeCancelBoth:
+		return true, true
+	default:
+		return true, false
+	}
+}
+
 func (l *Lending) getLendQuantity(
 	lendTokenTOMOPrice,
 	collateralPrice,
@@ -426,9 +712,11 @@ func (l *Lending) getLendQuantity(
 	if collateralPrice == nil || collateralPrice.Sign() == 0 {
 		if takerOrder.Side == lendingstate.Borrowing {
 			log.Debug("Reject lending order taker , can not found  collateral price ")
+			takerOrder.RejectReason = lendingstate.RejectReasonInvalidPrice
 			return lendingstate.Zero, lendingstate.Zero, false, nil, nil
 		} else {
 			log.Debug("Reject lending order maker , can not found  collateral price ")
+			makerOrder.RejectReason = lendingstate.RejectReasonInvalidPrice
 			return lendingstate.Zero, lendingstate.Zero, true, nil, nil
 		}
 	}
@@ -447,15 +735,18 @@ func (l *Lending) getLendQuantity(
 	if takerOrder.Relayer.String() == makerOrder.Relayer.String() {
 		if err := lendingstate.CheckRelayerFee(takerOrder.Relayer, new(big.Int).Mul(common.RelayerLendingFee, big.NewInt(2)), statedb); err != nil {
 			log.Debug("Reject order Taker Exchnage = Maker Exchange , relayer not enough fee ", "err", err)
+			takerOrder.RejectReason = lendingstate.RejectReasonInsufficientRelayerFee
 			return lendingstate.Zero, lendingstate.Zero, false, nil, nil
 		}
 	} else {
 		if err := lendingstate.CheckRelayerFee(takerOrder.Relayer, common.RelayerLendingFee, statedb); err != nil {
 			log.Debug("Reject order Taker , relayer not enough fee ", "err", err)
+			takerOrder.RejectReason = lendingstate.RejectReasonInsufficientRelayerFee
 			return lendingstate.Zero, lendingstate.Zero, false, nil, nil
 		}
 		if err := lendingstate.CheckRelayerFee(makerOrder.Relayer, common.RelayerLendingFee, statedb); err != nil {
 			log.Debug("Reject order maker , relayer not enough fee ", "err", err)
+			makerOrder.RejectReason = lendingstate.RejectReasonInsufficientRelayerFee
 			return lendingstate.Zero, lendingstate.Zero, true, nil, nil
 		}
 	}
@@ -476,6 +767,11 @@ func (l *Lending) getLendQuantity(
 	}
 	quantity, rejectMaker := GetLendQuantity(takerOrder.Side, collateralTokenDecimal, depositRate, collateralPrice, takerBalance, makerBalance, quantityToTrade)
 	log.Debug("GetLendQuantity", "side", takerOrder.Side, "takerBalance", takerBalance, "makerBalance", makerBalance, "LendingToken", makerOrder.LendingToken, "CollateralToken", collateralToken, "quantity", quantity, "rejectMaker", rejectMaker)
+	if rejectMaker {
+		makerOrder.RejectReason = lendingstate.RejectReasonInsufficientBalance
+	} else if quantity.Sign() == 0 {
+		takerOrder.RejectReason = lendingstate.RejectReasonInsufficientBalance
+	}
 	if quantity.Sign() > 0 {
 		// Apply Match Order
 		isTomoXLendingFork := chain.Config().IsTIPTomoXLending(header.Number)
@@ -677,6 +973,15 @@ func DoSettleBalance(coinbase common.Address, takerOrder, makerOrder *lendingsta
 
 func (l *Lending) ProcessCancelOrder(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, chain consensus.ChainContext, coinbase common.Address, lendingOrderBook common.Hash, order *lendingstate.LendingItem) (error, bool) {
 	originOrder := lendingStateDB.GetLendingOrder(lendingOrderBook, common.BigToHash(new(big.Int).SetUint64(order.LendingId)))
+	if originOrder.Hash != order.Hash && chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		// the item may be a stop-limit item which has not been triggered yet
+		if triggerBook := lendingstate.GetLendingTriggerBookHash(lendingOrderBook); lendingStateDB.Exist(triggerBook) {
+			if stopOrder := lendingStateDB.GetLendingOrder(triggerBook, common.BigToHash(new(big.Int).SetUint64(order.LendingId))); stopOrder.Hash == order.Hash {
+				originOrder = stopOrder
+				lendingOrderBook = triggerBook
+			}
+		}
+	}
 	if originOrder == lendingstate.EmptyLendingOrder {
 		return fmt.Errorf("lendingOrder not found. Id: %v. LendToken: %s . Term: %v. CollateralToken: %v", order.LendingId, order.LendingToken.Hex(), order.Term, order.CollateralToken.Hex()), false
 	}
@@ -690,6 +995,10 @@ func (l *Lending) ProcessCancelOrder(header *types.Header, lendingStateDB *lendi
 		log.Debug("Relayer not enough fee when cancel order", "err", err)
 		return nil, true
 	}
+	// the cancel fee of an iceberg item is charged on its hidden quantity too
+	if hidden := hiddenQuantity(lendingStateDB, lendingOrderBook, &originOrder); hidden.Sign() > 0 {
+		originOrder.Quantity = new(big.Int).Add(originOrder.Quantity, hidden)
+	}
 	lendTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, originOrder.LendingToken)
 	if err != nil || lendTokenDecimal == nil || lendTokenDecimal.Sign() <= 0 {
 		log.Debug("Fail to get tokenDecimal ", "Token", originOrder.LendingToken.String(), "err", err)
@@ -731,7 +1040,7 @@ func (l *Lending) ProcessCancelOrder(header *types.Header, lendingStateDB *lendi
 		log.Debug("User not enough balance when cancel order", "Side", originOrder.Side, "Interest", originOrder.Interest, "Quantity", originOrder.Quantity, "balance", tokenBalance, "fee", tokenCancelFee)
 		return nil, true
 	}
-	err = lendingStateDB.CancelLendingOrder(lendingOrderBook, &originOrder)
+	err = cancelLendingItem(lendingStateDB, lendingOrderBook, &originOrder)
 	if err != nil {
 		log.Debug("Error when cancel order", "order", &originOrder)
 		return err, false
@@ -798,9 +1107,86 @@ func (l *Lending) ProcessRepay(header *types.Header, chain consensus.ChainContex
 	if order.Relayer.String() != lendingTrade.BorrowingRelayer.String() {
 		return nil, fmt.Errorf("ProcessRepay: invalid relayerAddress . Got: %s . Expect: %s", order.Relayer.Hex(), lendingTrade.BorrowingRelayer.Hex())
 	}
+	if chain.Config().IsTIPTomoXLendingV2(header.Number) && order.Quantity != nil && order.Quantity.Sign() > 0 {
+		paymentBalance := lendingStateDB.GetTotalRepayValue(lendingBook, &lendingTrade, header.Time.Uint64())
+		if order.Quantity.Cmp(paymentBalance) < 0 {
+			return l.ProcessPartialRepayLendingTrade(header, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId, order.Quantity)
+		}
+	}
 	return l.ProcessRepayLendingTrade(header, chain, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId)
 }
 
+// ProcessPartialRepayLendingTrade pays down a part of the debt of a lending trade.
+// The paid quantity settles the principal it is worth at the current time (principal and accrued interest),
+// the remaining principal keeps accruing interest until the trade is repaid or liquidated.
+// The collateral stays locked, so the liquidation price decreases along with the debt.
+func (l *Lending) ProcessPartialRepayLendingTrade(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingstateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, quantity *big.Int) (trade *lendingstate.LendingTrade, err error) {
+	lendingTradeIdHash := common.Uint64ToHash(lendingTradeId)
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeIdHash)
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	time := header.Time.Uint64()
+	if lendingTrade.LiquidationTime <= time {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade: lendingTrade expired. lendingTradeId: %v", lendingTradeId)
+	}
+	paymentBalance := lendingStateDB.GetTotalRepayValue(lendingBook, &lendingTrade, time)
+	if quantity.Cmp(paymentBalance) >= 0 {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade: quantity %v covers the whole payment %v", quantity, paymentBalance)
+	}
+	tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
+	if tokenBalance.Cmp(quantity) < 0 {
+		return nil, fmt.Errorf("Not enough balance need : %s , have : %s ", quantity, tokenBalance)
+	}
+	repaidPrincipal := new(big.Int).Mul(quantity, lendingTrade.Amount)
+	repaidPrincipal = new(big.Int).Div(repaidPrincipal, paymentBalance)
+	if repaidPrincipal.Sign() <= 0 {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade: quantity %v is too small", quantity)
+	}
+	newAmount := new(big.Int).Sub(lendingTrade.Amount, repaidPrincipal)
+	newLiquidationPrice := new(big.Int).Mul(lendingTrade.LiquidationPrice, newAmount)
+	newLiquidationPrice = new(big.Int).Div(newLiquidationPrice, lendingTrade.Amount)
+	log.Debug("ProcessPartialRepay", "quantity", quantity, "totalRepayValue", paymentBalance, "repaidPrincipal", repaidPrincipal, "newAmount", newAmount, "newLiquidationPrice", newLiquidationPrice)
+
+	orderbook := tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	err = tradingstateDB.RemoveLiquidationPrice(orderbook, lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
+	if err != nil {
+		log.Debug("ProcessPartialRepay RemoveLiquidationPrice", "err", err)
+		return nil, err
+	}
+	lendingstate.SubTokenBalance(lendingTrade.Borrower, quantity, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, quantity, lendingTrade.LendingToken, statedb)
+	if _, err = payHaircut(lendingStateDB, statedb, lendingBook, &lendingTrade, quantity); err != nil {
+		return nil, err
+	}
+
+	lendingStateDB.UpdateLendingTradeAmount(lendingBook, lendingTradeId, newAmount)
+	lendingStateDB.UpdateLiquidationPrice(lendingBook, lendingTradeId, newLiquidationPrice)
+	if settled := lendingStateDB.GetSettledInterest(lendingBook, lendingTradeId); settled.Sign() > 0 {
+		// the periodic interest settled so far covers the remaining principal only
+		settled = new(big.Int).Mul(settled, newAmount)
+		lendingStateDB.SetSettledInterest(lendingBook, lendingTradeId, new(big.Int).Div(settled, lendingTrade.Amount))
+	}
+	tradingstateDB.InsertLiquidationPrice(orderbook, newLiquidationPrice, lendingBook, lendingTradeId)
+	if threshold := lendingStateDB.GetTradeLiquidationThreshold(lendingBook, lendingTradeId); threshold.Sign() > 0 {
+		threshold = new(big.Int).Mul(threshold, newAmount)
+		lendingStateDB.SetTradeLiquidationThreshold(lendingBook, lendingTradeId, new(big.Int).Div(threshold, lendingTrade.Amount))
+	}
+
+	newLendingTrade := lendingTrade
+	newLendingTrade.Amount = newAmount
+	newLendingTrade.LiquidationPrice = newLiquidationPrice
+	extraData, _ := json.Marshal(struct {
+		Profit          *big.Int
+		RepaidPrincipal *big.Int
+	}{
+		Profit:          new(big.Int).Sub(quantity, repaidPrincipal),
+		RepaidPrincipal: repaidPrincipal,
+	})
+	newLendingTrade.ExtraData = string(extraData)
+	return &newLendingTrade, nil
+}
+
 // return liquidatedTrade
 func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingstateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64) (*lendingstate.LendingTrade, error) {
 	lendingTradeIdHash := common.Uint64ToHash(lendingTradeId)
@@ -810,7 +1196,7 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 	}
 	repayAmount := lendingTrade.CollateralLockedAmount
 
-	_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingstateDB, lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	_, collateralPrice, err := l.GetLiquidationPrices(header, chain, statedb, tradingstateDB, lendingStateDB, lendingTrade.CollateralToken, lendingTrade.LendingToken)
 	if err != nil || collateralPrice == nil || collateralPrice.Sign() <= 0 {
 		// if cannot get collateralPrice, liquidate all collateral
 		log.Error("LiquidationExpiredTrade: cannot get collateralPrice", "err", err)
@@ -823,6 +1209,14 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 		collateralAmount = new(big.Int).Div(collateralAmount, liquidationRate)
 		totalCollateralAmount := lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, collateralAmount)
 		interestAmount := new(big.Int).Sub(totalCollateralAmount, collateralAmount)
+		if settled := lendingStateDB.GetSettledInterest(lendingBook, lendingTradeId); settled.Sign() > 0 {
+			// the periodic interest settled so far, valued in collateral
+			settled = new(big.Int).Mul(settled, collateralAmount)
+			interestAmount.Sub(interestAmount, settled.Div(settled, lendingTrade.Amount))
+			if interestAmount.Sign() < 0 {
+				interestAmount.SetInt64(0)
+			}
+		}
 		repayAmount = new(big.Int).Add(repayAmount, interestAmount)
 	}
 
@@ -835,6 +1229,10 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 	}
 	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
 	lendingstate.AddTokenBalance(lendingTrade.Investor, repayAmount, lendingTrade.CollateralToken, statedb)
+	releaseTradeCollaterals(lendingStateDB, statedb, lendingBook, lendingTradeId, lendingTrade.Investor)
+	forfeitHaircut(lendingStateDB, lendingBook, lendingTradeId)
+	clearSettledInterest(lendingStateDB, lendingBook, lendingTradeId)
+	liquidateMarginPosition(lendingStateDB, tradingstateDB, lendingBook, lendingTradeId)
 
 	err = lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
 	if err != nil {
@@ -863,7 +1261,9 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 	return &lendingTrade, nil
 }
 
-// return liquidatedTrade
+// LiquidationTrade seizes the locked collateral of a lending trade in favor of the investor,
+// removes the trade from the liquidation time and price indexes and closes it.
+// It returns the liquidated trade.
 func (l *Lending) LiquidationTrade(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingstateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64) (*lendingstate.LendingTrade, error) {
 	lendingTradeIdHash := common.Uint64ToHash(lendingTradeId)
 	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeIdHash)
@@ -872,6 +1272,10 @@ func (l *Lending) LiquidationTrade(lendingStateDB *lendingstate.LendingStateDB,
 	}
 	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
 	lendingstate.AddTokenBalance(lendingTrade.Investor, lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
+	releaseTradeCollaterals(lendingStateDB, statedb, lendingBook, lendingTradeId, lendingTrade.Investor)
+	forfeitHaircut(lendingStateDB, lendingBook, lendingTradeId)
+	clearSettledInterest(lendingStateDB, lendingBook, lendingTradeId)
+	liquidateMarginPosition(lendingStateDB, tradingstateDB, lendingBook, lendingTradeId)
 
 	err := lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
 	if err != nil {
@@ -1067,14 +1471,7 @@ func (l *Lending) AutoTopUp(statedb *state.StateDB, tradingState *tradingstate.T
 	if currentPrice.Cmp(lendingTrade.LiquidationPrice) >= 0 {
 		return nil, fmt.Errorf("CurrentPrice is still higher than or equal to LiquidationPrice. current price: %v  , liquidation price : %v  ", currentPrice, lendingTrade.LiquidationPrice)
 	}
-	// newLiquidationPrice = currentPrice * 90%
-	newLiquidationPrice := new(big.Int).Mul(currentPrice, common.RateTopUp)
-	newLiquidationPrice = new(big.Int).Div(newLiquidationPrice, common.BaseTopUp)
-	// newLockedAmount = CollateralLockedAmount *  LiquidationPrice / newLiquidationPrice
-	newLockedAmount := new(big.Int).Mul(lendingTrade.CollateralLockedAmount, lendingTrade.LiquidationPrice)
-	newLockedAmount = new(big.Int).Div(newLockedAmount, newLiquidationPrice)
-
-	requiredDepositAmount := new(big.Int).Sub(newLockedAmount, lendingTrade.CollateralLockedAmount)
+	requiredDepositAmount := requiredTopUpAmount(&lendingTrade, currentPrice)
 	tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.CollateralToken, statedb)
 	if tokenBalance.Cmp(requiredDepositAmount) < 0 {
 		return nil, fmt.Errorf("not enough balance to AutoTopUp. requiredDepositAmount: %v . tokenBalance: %v . Token: %s", requiredDepositAmount, tokenBalance, lendingTrade.CollateralToken.Hex())
@@ -1121,7 +1518,7 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 	}
 	time := header.Time.Uint64()
 	tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
-	paymentBalance := lendingstate.CalculateTotalRepayValue(time, lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+	paymentBalance := lendingStateDB.GetTotalRepayValue(lendingBook, &lendingTrade, time)
 	log.Debug("ProcessRepay", "totalInterest", new(big.Int).Sub(paymentBalance, lendingTrade.Amount), "totalRepayValue", paymentBalance, "token", lendingTrade.LendingToken.Hex())
 
 	if tokenBalance.Cmp(paymentBalance) < 0 {
@@ -1130,6 +1527,11 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 		}
 		newLendingTrade := &lendingstate.LendingTrade{}
 		var err error
+		if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+			if _, collateralPrice, priceErr := l.GetLiquidationPrices(header, chain, statedb, tradingstateDB, lendingStateDB, lendingTrade.CollateralToken, lendingTrade.LendingToken); priceErr == nil && collateralPrice != nil && collateralPrice.Sign() > 0 {
+				return l.openLiquidationAuction(header, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId, collateralPrice, lendingstate.LiquidatedByTime)
+			}
+		}
 		if chain.Config().IsTIPTomoXLending(header.Number) {
 			newLendingTrade, err = l.LiquidationExpiredTrade(header, chain, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId)
 		} else {
@@ -1155,9 +1557,15 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 	} else {
 		lendingstate.SubTokenBalance(lendingTrade.Borrower, paymentBalance, lendingTrade.LendingToken, statedb)
 		lendingstate.AddTokenBalance(lendingTrade.Investor, paymentBalance, lendingTrade.LendingToken, statedb)
+		if _, err = payHaircut(lendingStateDB, statedb, lendingBook, &lendingTrade, paymentBalance); err != nil {
+			return nil, err
+		}
 
 		lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
 		lendingstate.AddTokenBalance(lendingTrade.Borrower, lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
+		releaseTradeCollaterals(lendingStateDB, statedb, lendingBook, lendingTradeId, lendingTrade.Borrower)
+		lendingStateDB.RemoveMarginPosition(lendingBook, lendingTradeId)
+		clearSettledInterest(lendingStateDB, lendingBook, lendingTradeId)
 
 		err = lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
 		if err != nil {
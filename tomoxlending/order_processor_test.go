@@ -539,3 +539,21 @@ func TestGetLendQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestSelfTradeRejects(t *testing.T) {
+	tests := []struct {
+		policy                   string
+		rejectTaker, rejectMaker bool
+	}{
+		{lendingstate.SelfTradeCancelNewest, true, false},
+		{lendingstate.SelfTradeCancelOldest, false, true},
+		{lendingstate.SelfTradeCancelBoth, true, true},
+		{"unknown", true, false},
+	}
+	for _, test := range tests {
+		rejectTaker, rejectMaker := selfTradeRejects(test.policy)
+		if rejectTaker != test.rejectTaker || rejectMaker != test.rejectMaker {
+			t.Errorf("policy %s: have (%v, %v), want (%v, %v)", test.policy, rejectTaker, rejectMaker, test.rejectTaker, test.rejectMaker)
+		}
+	}
+}
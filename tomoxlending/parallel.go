@@ -0,0 +1,188 @@
+package tomoxlending
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The pending lending transactions are split into groups of accounts whose transactions use
+// tokens no other group uses, as lending token or as collateral of a borrowing. The lending
+// books of a token share its balance locked in LendingLockAddress, so they are always matched in
+// the same group. The groups are matched concurrently, each on its own copy of the states, and
+// their changes are merged into the states in the order of the groups. The matching of a group
+// still touches state shared with other groups (the TOMO balances of relayers and users, the
+// collateral of the trades of an account), so a group is only merged if it accessed nothing
+// written by the groups merged before it, and it accessed nothing they wrote. The other groups
+// are matched again one after the other once the merge is done. Either way the items of a group
+// are listed after the items of the groups merged before it, so validators applying the items in
+// block order reach the same states.
+
+// orderGroup is a set of accounts whose pending lending transactions use the same tokens.
+type orderGroup struct {
+	token   common.Address // smallest address of the tokens of the group, orders the groups
+	pending map[common.Address]types.LendingTransactions
+}
+
+// groupPendingByToken splits the pending lending transactions into groups of accounts using
+// disjoint sets of tokens, ordered by their smallest token address.
+func groupPendingByToken(pending map[common.Address]types.LendingTransactions) []*orderGroup {
+	parent := make(map[common.Address]common.Address)
+	var find func(token common.Address) common.Address
+	find = func(token common.Address) common.Address {
+		p, ok := parent[token]
+		if !ok {
+			parent[token] = token
+			return token
+		}
+		if p != token {
+			p = find(p)
+			parent[token] = p
+		}
+		return p
+	}
+	union := func(a, b common.Address) {
+		ra, rb := find(a), find(b)
+		if ra == rb {
+			return
+		}
+		// keep the smallest address as root, so that it identifies the group
+		if bytes.Compare(ra[:], rb[:]) < 0 {
+			parent[rb] = ra
+		} else {
+			parent[ra] = rb
+		}
+	}
+	accountTokens := make(map[common.Address]common.Address, len(pending))
+	for addr, txs := range pending {
+		if len(txs) == 0 {
+			continue
+		}
+		first := txs[0].LendingToken()
+		find(first)
+		for _, tx := range txs {
+			union(first, tx.LendingToken())
+			if tx.Side() == lendingstate.Borrowing && tx.CollateralToken() != (common.Address{}) {
+				union(first, tx.CollateralToken())
+			}
+		}
+		accountTokens[addr] = first
+	}
+	groups := make(map[common.Address]*orderGroup)
+	for addr, token := range accountTokens {
+		root := find(token)
+		group := groups[root]
+		if group == nil {
+			group = &orderGroup{token: root, pending: make(map[common.Address]types.LendingTransactions)}
+			groups[root] = group
+		}
+		group.pending[addr] = pending[addr]
+	}
+	sorted := make([]*orderGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].token[:], sorted[j].token[:]) < 0
+	})
+	return sorted
+}
+
+// groupMatch is the result of the matching of a group on copies of the states.
+type groupMatch struct {
+	items        []*lendingstate.LendingItem
+	results      map[common.Hash]lendingstate.MatchingResult
+	statedb      *state.StateDB
+	lendingState *lendingstate.LendingStateDB
+	tradingState *tradingstate.TradingStateDB
+}
+
+// processOrderGroups matches the groups of pending lending transactions concurrently with at most
+// the given number of workers, and merges their changes into the states.
+func (l *Lending) processOrderGroups(header *types.Header, coinbase common.Address, chain consensus.ChainContext, groups []*orderGroup, workers int, statedb *state.StateDB, lendingStatedb *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB) ([]*lendingstate.LendingItem, map[common.Hash]lendingstate.MatchingResult) {
+	var (
+		matches = make([]*groupMatch, len(groups))
+		sem     = make(chan struct{}, workers)
+		wg      sync.WaitGroup
+	)
+	for i, group := range groups {
+		match := &groupMatch{
+			statedb:      statedb.Copy(),
+			lendingState: lendingStatedb.Copy(),
+			tradingState: tradingStateDb.Copy(),
+		}
+		match.statedb.RecordAccesses()
+		match.lendingState.RecordAccesses()
+		match.tradingState.RecordAccesses()
+		matches[i] = match
+
+		wg.Add(1)
+		go func(group *orderGroup, match *groupMatch) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			match.items, match.results = l.processOrders(header, coinbase, chain, group.pending, match.statedb, match.lendingState, match.tradingState, &matchBudget{})
+		}(group, match)
+	}
+	wg.Wait()
+
+	var (
+		lendingItems    = []*lendingstate.LendingItem{}
+		matchingResults = map[common.Hash]lendingstate.MatchingResult{}
+		stateAccesses   = state.NewAccessSet()
+		lendingAccesses = make(map[common.Hash]struct{})
+		tradingAccesses = make(map[common.Hash]struct{})
+		conflicting     []*orderGroup
+	)
+	for i, match := range matches {
+		if match.statedb.Accesses().Conflicts(stateAccesses) || overlaps(match.lendingState.Accessed(), lendingAccesses) || overlaps(match.tradingState.Accessed(), tradingAccesses) {
+			conflicting = append(conflicting, groups[i])
+			continue
+		}
+		statedb.ApplyWrites(match.statedb)
+		lendingStatedb.ApplyAccessed(match.lendingState)
+		tradingStateDb.ApplyAccessed(match.tradingState)
+
+		stateAccesses.Add(match.statedb.Accesses())
+		for hash := range match.lendingState.Accessed() {
+			lendingAccesses[hash] = struct{}{}
+		}
+		for hash := range match.tradingState.Accessed() {
+			tradingAccesses[hash] = struct{}{}
+		}
+		lendingItems = append(lendingItems, match.items...)
+		for key, result := range match.results {
+			matchingResults[key] = result
+		}
+	}
+	for _, group := range conflicting {
+		items, results := l.processOrders(header, coinbase, chain, group.pending, statedb, lendingStatedb, tradingStateDb, &matchBudget{})
+		lendingItems = append(lendingItems, items...)
+		for key, result := range results {
+			matchingResults[key] = result
+		}
+	}
+	log.Debug("Matched lending books concurrently", "groups", len(groups), "rematched", len(conflicting), "items", len(lendingItems))
+	return lendingItems, matchingResults
+}
+
+// overlaps returns whether two sets of state objects have an object in common.
+func overlaps(a, b map[common.Hash]struct{}) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for hash := range a {
+		if _, ok := b[hash]; ok {
+			return true
+		}
+	}
+	return false
+}
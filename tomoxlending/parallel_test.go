@@ -0,0 +1,94 @@
+package tomoxlending
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestGroupPendingByToken(t *testing.T) {
+	var (
+		usdt, btc  = common.HexToAddress("0x10"), common.HexToAddress("0x20")
+		eth        = common.HexToAddress("0x30")
+		alice, bob = common.HexToAddress("0x1"), common.HexToAddress("0x2")
+		carol, dan = common.HexToAddress("0x3"), common.HexToAddress("0x4")
+	)
+	newTx := func(user common.Address, nonce uint64, token common.Address, term uint64, side string, collateral common.Address) *types.LendingTransaction {
+		return types.NewLendingTransaction(nonce, big.NewInt(1), 10, term, common.Address{}, user, token, collateral, false, lendingstate.LendingStatusNew, side, lendingstate.Limit, common.Hash{}, 0, 0, "")
+	}
+	// alice and bob use distinct usdt books, which share the usdt locked for the trades, carol
+	// lends btc, and dan borrows btc against eth collateral
+	pending := map[common.Address]types.LendingTransactions{
+		alice: {newTx(alice, 0, usdt, 86400, lendingstate.Investing, common.Address{})},
+		bob:   {newTx(bob, 0, usdt, 604800, lendingstate.Investing, common.Address{})},
+		carol: {newTx(carol, 0, btc, 86400, lendingstate.Investing, common.Address{})},
+		dan:   {newTx(dan, 0, btc, 86400, lendingstate.Borrowing, eth)},
+	}
+	groups := groupPendingByToken(pending)
+	if len(groups) != 2 {
+		t.Fatalf("wrong number of groups: have %d, want 2", len(groups))
+	}
+	for i := 1; i < len(groups); i++ {
+		if bytes.Compare(groups[i-1].token[:], groups[i].token[:]) >= 0 {
+			t.Fatal("groups not ordered by token")
+		}
+	}
+	for _, group := range groups {
+		_, hasAlice := group.pending[alice]
+		_, hasBob := group.pending[bob]
+		_, hasCarol := group.pending[carol]
+		_, hasDan := group.pending[dan]
+		if hasAlice != hasBob || hasCarol != hasDan || hasAlice == hasCarol {
+			t.Fatalf("wrong group: %v", group.pending)
+		}
+	}
+	// an investment in eth links the eth collateral of dan to the btc group
+	pending[alice] = append(pending[alice], newTx(alice, 1, eth, 86400, lendingstate.Investing, common.Address{}))
+	if groups := groupPendingByToken(pending); len(groups) != 1 {
+		t.Fatalf("wrong number of groups with a shared collateral: have %d, want 1", len(groups))
+	}
+}
+
+func TestMergeLendingStateCopies(t *testing.T) {
+	var (
+		bookA = lendingstate.GetLendingOrderBookHash(common.HexToAddress("0x10"), 86400)
+		bookB = lendingstate.GetLendingOrderBookHash(common.HexToAddress("0x20"), 86400)
+		db    = lendingstate.NewDatabase(rawdb.NewMemoryDatabase())
+	)
+	insert := func(s *lendingstate.LendingStateDB, book common.Hash, id uint64) {
+		s.InsertLendingItem(book, common.Uint64ToHash(id), lendingstate.LendingItem{LendingId: id, Quantity: big.NewInt(int64(id)), Interest: big.NewInt(10), Side: lendingstate.Investing, Signature: &lendingstate.Signature{}})
+	}
+	base, _ := lendingstate.New(lendingstate.EmptyRoot, db)
+	insert(base, bookA, 1)
+
+	serial := base.Copy()
+	insert(serial, bookA, 2)
+	insert(serial, bookB, 3)
+	want := serial.IntermediateRoot()
+
+	copyA, copyB := base.Copy(), base.Copy()
+	copyA.RecordAccesses()
+	copyB.RecordAccesses()
+	insert(copyA, bookA, 2)
+	insert(copyB, bookB, 3)
+	if overlaps(copyA.Accessed(), copyB.Accessed()) {
+		t.Fatal("independent books reported as overlapping")
+	}
+	base.ApplyAccessed(copyA)
+	base.ApplyAccessed(copyB)
+	if root := base.IntermediateRoot(); root != want {
+		t.Fatalf("wrong merged root: have %x, want %x", root, want)
+	}
+
+	copyC := base.Copy()
+	copyC.RecordAccesses()
+	copyC.GetBestInvestingRate(bookA)
+	if !overlaps(copyC.Accessed(), copyA.Accessed()) {
+		t.Fatal("read of a merged book not reported as overlapping")
+	}
+}
@@ -0,0 +1,267 @@
+package tomoxlending
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// peer represents a tomoxlending protocol peer connection.
+type peer struct {
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+
+	id              string
+	knownLendingTxs mapset.Set                     // Set of lending transaction hashes known to be known by this peer
+	queuedTxs       chan types.LendingTransactions // Queue of lending transactions to broadcast to the peer
+	term            chan struct{}                  // Termination channel to stop the broadcaster
+
+	orderBookRequests map[common.Hash]int // Lending books requested from the peer and not answered yet
+	orderBookLock     sync.Mutex
+}
+
+func newPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	id := p.ID()
+	return &peer{
+		Peer:              p,
+		rw:                rw,
+		id:                fmt.Sprintf("%x", id[:8]),
+		knownLendingTxs:   mapset.NewSet(),
+		queuedTxs:         make(chan types.LendingTransactions, maxQueuedLendingTxs),
+		term:              make(chan struct{}),
+		orderBookRequests: make(map[common.Hash]int),
+	}
+}
+
+// broadcast is a write loop that multiplexes the lending transactions to the remote peer. The
+// goal is to have an async writer that does not lock up the node internals.
+func (p *peer) broadcast() {
+	for {
+		select {
+		case txs := <-p.queuedTxs:
+			if err := p.SendLendingTransactions(txs); err != nil {
+				return
+			}
+			p.Log().Trace("Broadcast lending transactions", "count", len(txs))
+
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// close signals the broadcast goroutine to terminate.
+func (p *peer) close() {
+	close(p.term)
+}
+
+// MarkLendingTransaction marks a lending transaction as known for the peer, ensuring that it
+// will never be propagated to this particular peer.
+func (p *peer) MarkLendingTransaction(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known transaction hash
+	for p.knownLendingTxs.Cardinality() >= maxKnownLendingTxs {
+		p.knownLendingTxs.Pop()
+	}
+	p.knownLendingTxs.Add(hash)
+}
+
+// SendLendingTransactions sends lending transactions (new orders and cancellations)
+// to the peer and includes the hashes in its transaction hash set for future reference.
+func (p *peer) SendLendingTransactions(txs types.LendingTransactions) error {
+	for _, tx := range txs {
+		p.MarkLendingTransaction(tx.Hash())
+	}
+	return p2p.Send(p.rw, LendingTxMsg, txs)
+}
+
+// AsyncSendLendingTransactions queues a list of lending transactions to propagate to the peer. If
+// the broadcast queue of the peer is full, the transactions are silently dropped.
+func (p *peer) AsyncSendLendingTransactions(txs types.LendingTransactions) {
+	select {
+	case p.queuedTxs <- txs:
+		for _, tx := range txs {
+			p.MarkLendingTransaction(tx.Hash())
+		}
+	default:
+		p.Log().Debug("Dropping lending transaction propagation", "count", len(txs))
+	}
+}
+
+// RequestOrderBook asks the peer for the snapshot of a lending book.
+func (p *peer) RequestOrderBook(lendingToken common.Address, term uint64) error {
+	p.Log().Debug("Fetching lending orderbook", "lendingToken", lendingToken.Hex(), "term", term)
+	book := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	p.orderBookLock.Lock()
+	p.orderBookRequests[book]++
+	p.orderBookLock.Unlock()
+	return p2p.Send(p.rw, GetOrderBookMsg, &getOrderBookData{LendingToken: lendingToken, Term: term})
+}
+
+// answerOrderBook marks a request of the snapshot of a lending book as answered, returning false
+// if the lending book wasn't requested from the peer.
+func (p *peer) answerOrderBook(lendingToken common.Address, term uint64) bool {
+	book := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	p.orderBookLock.Lock()
+	defer p.orderBookLock.Unlock()
+
+	if p.orderBookRequests[book] == 0 {
+		return false
+	}
+	if p.orderBookRequests[book]--; p.orderBookRequests[book] == 0 {
+		delete(p.orderBookRequests, book)
+	}
+	return true
+}
+
+// SendOrderBook sends a lending book snapshot to the peer.
+func (p *peer) SendOrderBook(snapshot *OrderBookSnapshot) error {
+	return p2p.Send(p.rw, OrderBookMsg, snapshot)
+}
+
+// RequestTrieNodes asks the peer for trading and lending state trie nodes by hash.
+func (p *peer) RequestTrieNodes(hashes []common.Hash) error {
+	p.Log().Debug("Fetching TomoX state trie nodes", "count", len(hashes))
+	return p2p.Send(p.rw, GetTrieNodesMsg, hashes)
+}
+
+// SendTrieNodes sends a batch of state trie nodes to the peer.
+func (p *peer) SendTrieNodes(nodes [][]byte) error {
+	return p2p.Send(p.rw, TrieNodesMsg, nodes)
+}
+
+// Handshake executes the tomoxlending protocol handshake, negotiating version number
+// and genesis block.
+func (p *peer) Handshake(genesis common.Hash) error {
+	// Send out own handshake in a new thread
+	errc := make(chan error, 2)
+	var status statusData // safe to read after two values have been received from errc
+
+	go func() {
+		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+			ProtocolVersion: ProtocolVersion,
+			Genesis:         genesis,
+		})
+	}()
+	go func() {
+		errc <- p.readStatus(&status, genesis)
+	}()
+	timeout := time.NewTimer(handshakeTimeout)
+	defer timeout.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return p2p.DiscReadTimeout
+		}
+	}
+	return nil
+}
+
+func (p *peer) readStatus(status *statusData, genesis common.Hash) (err error) {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+	if msg.Code != StatusMsg {
+		return fmt.Errorf("%v: first msg has code %x (!= %x)", errNoStatusMsg, msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return fmt.Errorf("%v: %v > %v", errMsgTooLarge, msg.Size, ProtocolMaxMsgSize)
+	}
+	if err := msg.Decode(status); err != nil {
+		return fmt.Errorf("%v: msg %v: %v", errDecode, msg, err)
+	}
+	if status.Genesis != genesis {
+		return fmt.Errorf("%v: %x (!= %x)", errGenesisBlockMismatch, status.Genesis[:8], genesis[:8])
+	}
+	if status.ProtocolVersion != ProtocolVersion {
+		return fmt.Errorf("%v: %d (!= %d)", errProtocolVersionMismatch, status.ProtocolVersion, ProtocolVersion)
+	}
+	return nil
+}
+
+// String implements fmt.Stringer.
+func (p *peer) String() string {
+	return fmt.Sprintf("Peer %s [%s]", p.id, fmt.Sprintf("%s/%2d", ProtocolName, ProtocolVersion))
+}
+
+// peerSet represents the collection of active peers currently participating in
+// the tomoxlending sub-protocol.
+type peerSet struct {
+	peers map[discover.NodeID]*peer
+	lock  sync.RWMutex
+}
+
+// newPeerSet creates a new peer set to track the active participants.
+func newPeerSet() *peerSet {
+	return &peerSet{
+		peers: make(map[discover.NodeID]*peer),
+	}
+}
+
+// Register injects a new peer into the working set.
+func (ps *peerSet) Register(p *peer) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	ps.peers[p.ID()] = p
+	go p.broadcast()
+}
+
+// Unregister removes a remote peer from the active set.
+func (ps *peerSet) Unregister(p *peer) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.peers[p.ID()]; !ok {
+		return
+	}
+	delete(ps.peers, p.ID())
+	p.close()
+}
+
+// Len returns the current number of peers in the set.
+func (ps *peerSet) Len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.peers)
+}
+
+// Peers retrieves all peers of the set.
+func (ps *peerSet) Peers() []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		list = append(list, p)
+	}
+	return list
+}
+
+// PeersWithoutLendingTx retrieves a list of peers that do not have a given lending transaction
+// in their set of known hashes.
+func (ps *peerSet) PeersWithoutLendingTx(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownLendingTxs.Contains(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
@@ -0,0 +1,91 @@
+package tomoxlending
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+// RPCLendingTransaction represents a lending transaction of the pool that will serialize to the RPC
+// representation.
+type RPCLendingTransaction struct {
+	Hash            common.Hash    `json:"hash"`
+	Nonce           hexutil.Uint64 `json:"nonce"`
+	UserAddress     common.Address `json:"userAddress"`
+	RelayerAddress  common.Address `json:"relayerAddress"`
+	LendingToken    common.Address `json:"lendingToken"`
+	CollateralToken common.Address `json:"collateralToken"`
+	Term            hexutil.Uint64 `json:"term"`
+	Interest        hexutil.Uint64 `json:"interest"`
+	Side            string         `json:"side"`
+	Type            string         `json:"type"`
+	Status          string         `json:"status"`
+	Quantity        *hexutil.Big   `json:"quantity"`
+	AutoTopUp       bool           `json:"autoTopUp"`
+	LendingHash     common.Hash    `json:"lendingHash"`
+	LendingId       hexutil.Uint64 `json:"lendingId"`
+	LendingTradeId  hexutil.Uint64 `json:"lendingTradeId"`
+	ExtraData       string         `json:"extraData"`
+	V               *hexutil.Big   `json:"v"`
+	R               *hexutil.Big   `json:"r"`
+	S               *hexutil.Big   `json:"s"`
+}
+
+func newRPCLendingTransaction(tx *types.LendingTransaction) *RPCLendingTransaction {
+	v, r, s := tx.Signature()
+	return &RPCLendingTransaction{
+		Hash:            tx.Hash(),
+		Nonce:           hexutil.Uint64(tx.Nonce()),
+		UserAddress:     tx.UserAddress(),
+		RelayerAddress:  tx.RelayerAddress(),
+		LendingToken:    tx.LendingToken(),
+		CollateralToken: tx.CollateralToken(),
+		Term:            hexutil.Uint64(tx.Term()),
+		Interest:        hexutil.Uint64(tx.Interest()),
+		Side:            tx.Side(),
+		Type:            tx.Type(),
+		Status:          tx.Status(),
+		Quantity:        (*hexutil.Big)(tx.Quantity()),
+		AutoTopUp:       tx.AutoTopUp(),
+		LendingHash:     tx.LendingHash(),
+		LendingId:       hexutil.Uint64(tx.LendingId()),
+		LendingTradeId:  hexutil.Uint64(tx.LendingTradeId()),
+		ExtraData:       tx.ExtraData(),
+		V:               (*hexutil.Big)(v),
+		R:               (*hexutil.Big)(r),
+		S:               (*hexutil.Big)(s),
+	}
+}
+
+// pendingLendingOrders returns the pending and queued transactions of the lending pool by account
+// and nonce, like txpool_content, restricted to the lending books of a lending token and of a term
+// if given (0 for every term).
+func (l *Lending) pendingLendingOrders(lendingToken *common.Address, term uint64) (map[string]map[string]map[string]*RPCLendingTransaction, error) {
+	if l.lendingPool == nil {
+		return nil, errLendingStateUnavailable
+	}
+	content := map[string]map[string]map[string]*RPCLendingTransaction{
+		"pending": make(map[string]map[string]*RPCLendingTransaction),
+		"queued":  make(map[string]map[string]*RPCLendingTransaction),
+	}
+	pending, queued := l.lendingPool.Content()
+	flatten := func(txsByAccount map[common.Address]types.LendingTransactions, dump map[string]map[string]*RPCLendingTransaction) {
+		for account, txs := range txsByAccount {
+			txDump := make(map[string]*RPCLendingTransaction)
+			for _, tx := range txs {
+				if lendingToken != nil && tx.LendingToken() != *lendingToken || term > 0 && tx.Term() != term {
+					continue
+				}
+				txDump[fmt.Sprintf("%d", tx.Nonce())] = newRPCLendingTransaction(tx)
+			}
+			if len(txDump) > 0 {
+				dump[account.Hex()] = txDump
+			}
+		}
+	}
+	flatten(pending, content["pending"])
+	flatten(queued, content["queued"])
+	return content, nil
+}
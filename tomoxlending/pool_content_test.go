@@ -0,0 +1,67 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// contentTestPool is a lending pool only serving its content.
+type contentTestPool struct {
+	lendingTxPool
+	pending, queued map[common.Address]types.LendingTransactions
+}
+
+func (p *contentTestPool) Content() (map[common.Address]types.LendingTransactions, map[common.Address]types.LendingTransactions) {
+	return p.pending, p.queued
+}
+
+func TestPendingLendingOrders(t *testing.T) {
+	var (
+		user, other = common.HexToAddress("0x1"), common.HexToAddress("0x2")
+		usdt, btc   = common.HexToAddress("0x10"), common.HexToAddress("0x20")
+	)
+	newTx := func(user common.Address, nonce uint64, lendingToken common.Address, term uint64) *types.LendingTransaction {
+		return types.NewLendingTransaction(nonce, big.NewInt(100), 10, term, common.Address{}, user, lendingToken, common.Address{}, false,
+			lendingstate.LendingStatusNew, lendingstate.Investing, lendingstate.Limit, common.Hash{}, 0, 0, "")
+	}
+	l := &Lending{}
+	if _, err := l.pendingLendingOrders(nil, 0); err != errLendingStateUnavailable {
+		t.Fatalf("wrong error without a lending pool: %v", err)
+	}
+	l.SetLendingPool(&contentTestPool{
+		pending: map[common.Address]types.LendingTransactions{
+			user:  {newTx(user, 0, usdt, 60), newTx(user, 1, btc, 60)},
+			other: {newTx(other, 4, usdt, 86400)},
+		},
+		queued: map[common.Address]types.LendingTransactions{
+			user: {newTx(user, 3, usdt, 60)},
+		},
+	})
+
+	content, err := l.pendingLendingOrders(nil, 0)
+	if err != nil {
+		t.Fatalf("failed to get the pool content: %v", err)
+	}
+	if len(content["pending"][user.Hex()]) != 2 || len(content["pending"][other.Hex()]) != 1 || len(content["queued"][user.Hex()]) != 1 {
+		t.Errorf("wrong pool content: %v", content)
+	}
+	if tx := content["queued"][user.Hex()]["3"]; tx == nil || tx.LendingToken != usdt || uint64(tx.Term) != 60 || tx.Quantity.ToInt().Int64() != 100 {
+		t.Errorf("wrong queued transaction: %+v", tx)
+	}
+
+	// the accounts without a transaction in the lending books are left out
+	content, err = l.pendingLendingOrders(&usdt, 60)
+	if err != nil {
+		t.Fatalf("failed to get the pool content: %v", err)
+	}
+	if pending := content["pending"]; len(pending) != 1 || len(pending[user.Hex()]) != 1 || pending[user.Hex()]["0"] == nil {
+		t.Errorf("wrong pending transactions of the lending book: %v", pending)
+	}
+	if len(content["queued"][user.Hex()]) != 1 {
+		t.Errorf("wrong queued transactions of the lending book: %v", content["queued"])
+	}
+}
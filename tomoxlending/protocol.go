@@ -0,0 +1,78 @@
+package tomoxlending
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// tomoxlending protocol message codes
+const (
+	StatusMsg       = 0x00
+	LendingTxMsg    = 0x01
+	GetOrderBookMsg = 0x02
+	OrderBookMsg    = 0x03
+	GetTrieNodesMsg = 0x04
+	TrieNodesMsg    = 0x05
+)
+
+const (
+	ProtocolLength     = uint64(6)       // Number of implemented message codes
+	ProtocolMaxMsgSize = 2 * 1024 * 1024 // Maximum cap on the size of a protocol message
+
+	handshakeTimeout      = 5 * time.Second
+	maxKnownLendingTxs    = 32768 // Maximum lending transaction hashes to keep in the known list (prevent DOS)
+	maxQueuedLendingTxs   = 128   // Maximum lending transaction lists to queue up before dropping broadcasts
+	txSyncPackSize        = 100 * 1024
+	lendingTxChanSize     = 4096
+	lendingEventChanSize  = 256
+	lendingEventQueueSize = 256 // Lending events queued for a slow subscriber before they are dropped
+
+	maxTrieNodesFetch    = 384                    // Maximum number of trie nodes requested from a peer at once
+	trieNodesSoftLimit   = ProtocolMaxMsgSize / 2 // Target size of a trie nodes answer
+	trieNodesTimeout     = 10 * time.Second       // Time allowance for a peer to answer a trie nodes request
+	maxStateSyncTimeouts = 8                      // Number of failed trie nodes requests in a row before a state sync fails
+)
+
+var (
+	errMsgTooLarge             = errors.New("message too long")
+	errDecode                  = errors.New("invalid message")
+	errInvalidMsgCode          = errors.New("invalid message code")
+	errNoStatusMsg             = errors.New("no status message")
+	errProtocolVersionMismatch = errors.New("protocol version mismatch")
+	errGenesisBlockMismatch    = errors.New("genesis block mismatch")
+	errInvalidOrderBook        = errors.New("invalid lending orderbook")
+	errLendingStateUnavailable = errors.New("lending state is unavailable")
+	errLendingTradeNotFound    = errors.New("lending trade not found")
+)
+
+// statusData is the network packet for the status message.
+type statusData struct {
+	ProtocolVersion uint64
+	Genesis         common.Hash
+}
+
+// getOrderBookData is the network packet requesting an orderbook snapshot of a lending book.
+type getOrderBookData struct {
+	LendingToken common.Address
+	Term         uint64
+}
+
+// OrderBookLevel is the aggregated volume of a lending book at one interest rate.
+type OrderBookLevel struct {
+	Interest *big.Int `json:"interest"`
+	Volume   *big.Int `json:"volume"`
+}
+
+// OrderBookSnapshot is the network packet answering an orderbook snapshot request.
+// Investing levels are sorted by ascending interest, borrowing levels by descending interest.
+type OrderBookSnapshot struct {
+	LendingToken common.Address   `json:"lendingToken"`
+	Term         uint64           `json:"term"`
+	BlockHash    common.Hash      `json:"blockHash"`
+	BlockNumber  uint64           `json:"blockNumber"`
+	Investing    []OrderBookLevel `json:"investing"`
+	Borrowing    []OrderBookLevel `json:"borrowing"`
+}
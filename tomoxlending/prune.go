@@ -0,0 +1,167 @@
+package tomoxlending
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/ethdb/memorydb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxDAO"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// The trading and lending state tries share the tomox leveldb, and a trie node can't be told to
+// belong to one or the other, so pruning keeps the nodes reachable from the retained roots of
+// both tries and removes any other trie node. Non trie data (lending index, SDK records of the
+// leveldb engine) isn't touched: a key is only a trie node if it is the hash of its value.
+
+var errNodeNotRetained = errors.New("trie node not retained")
+
+// PruneStats reports the result of a state pruning.
+type PruneStats struct {
+	Roots   int                // retained state roots
+	Marked  uint64             // trie nodes reachable from the retained roots
+	Scanned uint64             // trie nodes found in the database
+	Deleted uint64             // unreachable trie nodes removed
+	Size    common.StorageSize // size of the removed trie nodes
+}
+
+// stateMarker is the set of the trie nodes reachable from the retained roots. It serves as the
+// database of the trie sync schedulers walking the retained tries, so that the nodes shared
+// between several roots are only walked once; only the keys are kept in memory.
+type stateMarker struct {
+	db     ethdb.KeyValueReader
+	marked map[common.Hash]struct{}
+	size   int
+}
+
+func (m *stateMarker) Has(key []byte) (bool, error) {
+	_, ok := m.marked[common.BytesToHash(key)]
+	return ok, nil
+}
+
+func (m *stateMarker) Get(key []byte) ([]byte, error) {
+	if _, ok := m.marked[common.BytesToHash(key)]; !ok {
+		return nil, errNodeNotRetained
+	}
+	return m.db.Get(key)
+}
+
+func (m *stateMarker) Put(key []byte, value []byte) error {
+	m.marked[common.BytesToHash(key)] = struct{}{}
+	m.size += len(value)
+	return nil
+}
+
+func (m *stateMarker) Delete(key []byte) error {
+	delete(m.marked, common.BytesToHash(key))
+	return nil
+}
+
+func (m *stateMarker) ValueSize() int                      { return m.size }
+func (m *stateMarker) Write() error                        { return nil }
+func (m *stateMarker) Reset()                              { m.size = 0 }
+func (m *stateMarker) Replay(w ethdb.KeyValueWriter) error { return nil }
+
+// PruneStateTries removes from the tomox leveldb the trie nodes which aren't reachable from the
+// given trading and lending state roots. It fails without removing anything if a retained trie
+// is incomplete. The progress is logged periodically.
+func PruneStateTries(db tomoxDAO.TomoXDAO, tradingRoots, lendingRoots []common.Hash) (*PruneStats, error) {
+	var (
+		start  = time.Now()
+		stats  = &PruneStats{}
+		marker = &stateMarker{db: db, marked: make(map[common.Hash]struct{})}
+		bloom  = trie.NewSyncBloom(1, memorydb.New())
+	)
+	defer bloom.Close()
+
+	// mark the nodes reachable from the retained roots
+	var scheds []*trie.Sync
+	for _, root := range tradingRoots {
+		scheds = append(scheds, tradingstate.NewTradingStateSync(root, marker, bloom))
+	}
+	for _, root := range lendingRoots {
+		scheds = append(scheds, lendingstate.NewLendingStateSync(root, marker, bloom))
+	}
+	report := time.Now()
+	for i, sched := range scheds {
+		for {
+			hashes := sched.Missing(maxTrieNodesFetch)
+			if len(hashes) == 0 {
+				break
+			}
+			results := make([]trie.SyncResult, len(hashes))
+			for j, hash := range hashes {
+				data, err := db.Get(hash.Bytes())
+				if err != nil || len(data) == 0 {
+					return nil, fmt.Errorf("missing trie node %x of retained state, nothing pruned", hash)
+				}
+				results[j] = trie.SyncResult{Hash: hash, Data: data}
+			}
+			if _, _, err := sched.Process(results); err != nil {
+				return nil, err
+			}
+			if err := sched.Commit(marker); err != nil {
+				return nil, err
+			}
+			if time.Since(report) > 8*time.Second {
+				log.Info("Marking retained TomoX state", "roots", i, "total", len(scheds), "nodes", len(marker.marked), "elapsed", common.PrettyDuration(time.Since(start)))
+				report = time.Now()
+			}
+		}
+		stats.Roots++
+	}
+	stats.Marked = uint64(len(marker.marked))
+	log.Info("Marked retained TomoX state", "roots", stats.Roots, "nodes", stats.Marked, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// sweep the unreachable trie nodes
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+	batch := db.NewBatch()
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+		if len(key) != common.HashLength || crypto.Keccak256Hash(value) != common.BytesToHash(key) {
+			continue
+		}
+		stats.Scanned++
+		if _, ok := marker.marked[common.BytesToHash(key)]; ok {
+			continue
+		}
+		if err := batch.Delete(common.CopyBytes(key)); err != nil {
+			return nil, err
+		}
+		stats.Deleted++
+		stats.Size += common.StorageSize(len(key) + len(value))
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+		if time.Since(report) > 8*time.Second {
+			log.Info("Pruning TomoX state", "scanned", stats.Scanned, "deleted", stats.Deleted, "size", stats.Size, "elapsed", common.PrettyDuration(time.Since(start)))
+			report = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	log.Info("Pruned TomoX state", "scanned", stats.Scanned, "deleted", stats.Deleted, "size", stats.Size, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// reclaim the disk space of the removed nodes
+	if stats.Deleted > 0 {
+		if err := db.Compact(nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,92 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestPruneStateTries(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	db := l.GetLevelDB()
+
+	var (
+		usdt        = common.HexToAddress("0x10")
+		orderBook   = tradingstate.GetTradingOrderBookHash(common.HexToAddress("0x20"), usdt)
+		lendingBook = lendingstate.GetLendingOrderBookHash(usdt, 86400)
+	)
+	commitLending := func(root common.Hash, id int64) common.Hash {
+		lendingState, err := lendingstate.New(root, l.StateCache)
+		if err != nil {
+			t.Fatalf("failed to open lending state: %v", err)
+		}
+		lendingState.InsertLendingItem(lendingBook, common.BigToHash(big.NewInt(id)), lendingstate.LendingItem{LendingId: uint64(id), Quantity: big.NewInt(id), Interest: big.NewInt(10), Side: lendingstate.Investing, Signature: &lendingstate.Signature{}})
+		root, err = lendingState.Commit()
+		if err != nil {
+			t.Fatalf("failed to commit lending state: %v", err)
+		}
+		if err := l.StateCache.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("failed to flush lending state: %v", err)
+		}
+		return root
+	}
+	oldRoot := commitLending(lendingstate.EmptyRoot, 1)
+	newRoot := commitLending(oldRoot, 2)
+
+	tradingState, err := tradingstate.New(tradingstate.EmptyRoot, l.tomox.StateCache)
+	if err != nil {
+		t.Fatalf("failed to create trading state: %v", err)
+	}
+	tradingState.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(1)), tradingstate.OrderItem{OrderID: 1, Quantity: big.NewInt(5), Price: big.NewInt(3), Side: tradingstate.Ask, Signature: &tradingstate.Signature{}})
+	tradingRoot, err := tradingState.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	if err := l.tomox.StateCache.TrieDB().Commit(tradingRoot, false); err != nil {
+		t.Fatalf("failed to flush trading state: %v", err)
+	}
+	otherKey := common.HexToHash("0xff")
+	if err := db.Put(otherKey.Bytes(), []byte("not a trie node")); err != nil {
+		t.Fatalf("failed to put data: %v", err)
+	}
+
+	if _, err := PruneStateTries(db, []common.Hash{common.HexToHash("0x1234")}, nil); err == nil {
+		t.Fatal("pruned with a missing retained root")
+	}
+	stats, err := PruneStateTries(db, []common.Hash{tradingRoot}, []common.Hash{newRoot})
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if stats.Roots != 2 || stats.Deleted == 0 || stats.Scanned != stats.Marked+stats.Deleted {
+		t.Fatalf("wrong stats: %+v", stats)
+	}
+
+	// the retained state is complete, the old lending root is gone
+	fresh := New(l.tomox)
+	if ok, _ := db.Has(oldRoot.Bytes()); ok {
+		t.Fatal("old lending root not pruned")
+	}
+	lendingState, err := lendingstate.New(newRoot, fresh.StateCache)
+	if err != nil {
+		t.Fatalf("failed to open retained lending state: %v", err)
+	}
+	for _, id := range []int64{1, 2} {
+		if item := lendingState.GetLendingOrder(lendingBook, common.BigToHash(big.NewInt(id))); item.Quantity == nil || item.Quantity.Int64() != id {
+			t.Fatalf("wrong retained lending item %d: %+v", id, item)
+		}
+	}
+	tradingState, err = tradingstate.New(tradingRoot, tradingstate.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open retained trading state: %v", err)
+	}
+	if order := tradingState.GetOrder(orderBook, common.BigToHash(big.NewInt(1))); order.Quantity == nil || order.Quantity.Int64() != 5 {
+		t.Fatalf("wrong retained order: %+v", order)
+	}
+	if ok, _ := db.Has(otherKey.Bytes()); !ok {
+		t.Fatal("non trie data pruned")
+	}
+}
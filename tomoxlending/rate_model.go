@@ -0,0 +1,50 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// marketRateLimit returns the rate of the model of a lending book at its current utilization, nil
+// if the book has no model. A borrowing market item isn't matched with investing items over this
+// rate, an investing market item isn't matched with borrowing items under it, and the rest of the
+// item is dropped like the part of a market item the lending book can't fill.
+func marketRateLimit(lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash) (*big.Int, error) {
+	model := lendingStateDB.GetLendingRateModel(lendingBook)
+	if model.Model == "" {
+		return nil, nil
+	}
+	utilization, err := lendingStateDB.GetLendingUtilization(lendingBook)
+	if err != nil {
+		return nil, err
+	}
+	return model.Rate(utilization), nil
+}
+
+// LendingRateQuote is the RPC representation of the rate model of a lending book, with the rate
+// market items are priced at in the current block.
+type LendingRateQuote struct {
+	LendingBook common.Hash                   `json:"lendingBook"`
+	Model       lendingstate.LendingRateModel `json:"model"`
+	Utilization uint64                        `json:"utilization"` // in basis points
+	Rate        *big.Int                      `json:"rate"`        // nil if the book has no model
+}
+
+// rateQuote returns the rate model of a lending book at the current block.
+func (l *Lending) rateQuote(lendingToken common.Address, term uint64) (*LendingRateQuote, error) {
+	_, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	quote := &LendingRateQuote{LendingBook: lendingBook, Model: lendingState.GetLendingRateModel(lendingBook)}
+	if quote.Utilization, err = lendingState.GetLendingUtilization(lendingBook); err != nil {
+		return nil, err
+	}
+	if quote.Model.Model != "" {
+		quote.Rate = quote.Model.Rate(quote.Utilization)
+	}
+	return quote, nil
+}
@@ -0,0 +1,67 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestParseLendingRateModel(t *testing.T) {
+	model, err := lendingstate.ParseLendingRateModel("KINKED:100:400:3000:8000")
+	if err != nil {
+		t.Fatalf("failed to parse the model: %v", err)
+	}
+	if model != (lendingstate.LendingRateModel{Model: lendingstate.RateModelKinked, Base: 100, Slope1: 400, Slope2: 3000, Kink: 8000}) {
+		t.Errorf("wrong model: %v", model)
+	}
+	if model, err := lendingstate.ParseLendingRateModel(""); err != nil || model.Model != "" {
+		t.Errorf("an empty model doesn't remove the model: %v, %v", model, err)
+	}
+	for _, extraData := range []string{"LINEAR:1:2:3:4", "KINKED:1:2:3", "KINKED:1:2:3:0", "KINKED:1:2:3:10001", "KINKED:0:0:0:5000", "KINKED:a:2:3:4"} {
+		if _, err := lendingstate.ParseLendingRateModel(extraData); err == nil {
+			t.Errorf("invalid model %s accepted", extraData)
+		}
+	}
+
+	// utilization: rate
+	for utilization, want := range map[uint64]int64{0: 100, 4000: 300, 8000: 500, 9000: 2000, 10000: 3500, 12000: 3500} {
+		if rate := model.Rate(utilization); rate.Int64() != want {
+			t.Errorf("wrong rate at utilization %d: have %v, want %d", utilization, rate, want)
+		}
+	}
+}
+
+func TestMarketRateLimit(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	lendingBook := lendingstate.GetLendingOrderBookHash(common.HexToAddress(common.TomoNativeAddress), 86400)
+
+	if rate, err := marketRateLimit(lendingStateDB, lendingBook); err != nil || rate != nil {
+		t.Fatalf("market items bounded without a model: %v, %v", rate, err)
+	}
+	model, _ := lendingstate.ParseLendingRateModel("KINKED:100:400:3000:8000")
+	lendingStateDB.SetLendingRateModel(lendingBook, model)
+	if have := lendingStateDB.GetLendingRateModel(lendingBook); have != model {
+		t.Fatalf("wrong model: have %v, want %v", have, model)
+	}
+
+	// 900 borrowed over 1000 investing items and open trades: 90%
+	trade := lendingstate.LendingTrade{TradeId: 1, Amount: big.NewInt(900)}
+	lendingStateDB.InsertTradingItem(lendingBook, trade.TradeId, trade)
+	lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(1), lendingstate.LendingItem{LendingId: 1, Quantity: big.NewInt(100), Interest: big.NewInt(10), Side: lendingstate.Investing, Signature: &lendingstate.Signature{}})
+	if utilization, err := lendingStateDB.GetLendingUtilization(lendingBook); err != nil || utilization != 9000 {
+		t.Fatalf("wrong utilization: %v, %v", utilization, err)
+	}
+	if rate, err := marketRateLimit(lendingStateDB, lendingBook); err != nil || rate.Int64() != 2000 {
+		t.Errorf("wrong market rate limit: %v, %v", rate, err)
+	}
+
+	// an empty model removes the model
+	lendingStateDB.SetLendingRateModel(lendingBook, lendingstate.LendingRateModel{})
+	if rate, _ := marketRateLimit(lendingStateDB, lendingBook); rate != nil {
+		t.Errorf("market items bounded by a removed model: %v", rate)
+	}
+}
@@ -0,0 +1,92 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// RecallData is recorded in the ExtraData of a lending trade after a Recall lending item.
+type RecallData struct {
+	Price        *big.Int // collateral price the health factor was checked against
+	HealthFactor *big.Int // health factor of the trade before the recall
+	RecallAmount *big.Int // collateral given back to the borrower
+}
+
+// ProcessRecall gives back to the borrower the quantity of collateral requested by a Recall lending item.
+// The health factor of the trade at the latest medium collateral price must exceed its recall rate,
+// and must not go below the one of a new trade after the recall.
+func (l *Lending) ProcessRecall(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, order *lendingstate.LendingItem) (*lendingstate.LendingTrade, error) {
+	lendingTradeId := order.LendingTradeId
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade || lendingTrade.TradeId != lendingTradeId {
+		return nil, fmt.Errorf("ProcessRecall for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	if order.UserAddress != lendingTrade.Borrower {
+		return nil, fmt.Errorf("ProcessRecall: invalid userAddress . UserAddress: %s . Borrower: %s", order.UserAddress.Hex(), lendingTrade.Borrower.Hex())
+	}
+	if order.Relayer != lendingTrade.BorrowingRelayer {
+		return nil, fmt.Errorf("ProcessRecall: invalid relayerAddress . Got: %s . Expect: %s", order.Relayer.Hex(), lendingTrade.BorrowingRelayer.Hex())
+	}
+	// the weighted liquidation price of a multi-collateral trade doesn't allow to recall its main collateral
+	if isMultiCollateralTrade(lendingStateDB, lendingBook, lendingTradeId) {
+		return nil, fmt.Errorf("ProcessRecall: lendingTradeId %v has extra collaterals", lendingTradeId)
+	}
+	if order.Quantity == nil || order.Quantity.Sign() <= 0 || order.Quantity.Cmp(lendingTrade.CollateralLockedAmount) >= 0 {
+		return nil, fmt.Errorf("ProcessRecall: invalid quantity %v", order.Quantity)
+	}
+	_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingStateDb, lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	if err != nil {
+		return nil, err
+	}
+	if collateralPrice == nil || collateralPrice.Sign() <= 0 {
+		return nil, lendingstate.ErrInvalidCollateralPrice
+	}
+	depositRate, liquidationRate, recallRate := lendingTrade.DepositRate, lendingTrade.LiquidationRate, lendingTrade.RecallRate
+	if depositRate == nil || liquidationRate == nil || recallRate == nil || liquidationRate.Sign() <= 0 {
+		depositRate, liquidationRate, recallRate = lendingstate.GetCollateralDetail(statedb, lendingTrade.CollateralToken)
+	}
+	healthFactor := lendingstate.CalculateHealthFactor(collateralPrice, lendingTrade.LiquidationPrice)
+	if healthFactor.Cmp(recallRate) <= 0 {
+		return nil, lendingstate.ErrLowHealthFactor
+	}
+	maxRecallAmount := lendingstate.CalculateMaxRecallAmount(lendingTrade.CollateralLockedAmount, lendingTrade.LiquidationPrice, collateralPrice, depositRate, liquidationRate)
+	if order.Quantity.Cmp(maxRecallAmount) > 0 {
+		return nil, fmt.Errorf("ProcessRecall: quantity %v exceeds the recallable collateral %v", order.Quantity, maxRecallAmount)
+	}
+
+	// the liquidation price rises as the collateral backing the same debt decreases
+	newLockedAmount := new(big.Int).Sub(lendingTrade.CollateralLockedAmount, order.Quantity)
+	newLiquidationPrice := new(big.Int).Mul(lendingTrade.LiquidationPrice, lendingTrade.CollateralLockedAmount)
+	newLiquidationPrice = new(big.Int).Div(newLiquidationPrice, newLockedAmount)
+	orderbook := tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	if err := tradingStateDb.RemoveLiquidationPrice(orderbook, lendingTrade.LiquidationPrice, lendingBook, lendingTradeId); err != nil {
+		return nil, err
+	}
+	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), order.Quantity, lendingTrade.CollateralToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Borrower, order.Quantity, lendingTrade.CollateralToken, statedb)
+
+	lendingStateDB.UpdateLiquidationPrice(lendingBook, lendingTradeId, newLiquidationPrice)
+	lendingStateDB.UpdateCollateralLockedAmount(lendingBook, lendingTradeId, newLockedAmount)
+	tradingStateDb.InsertLiquidationPrice(orderbook, newLiquidationPrice, lendingBook, lendingTradeId)
+	log.Debug("ProcessRecall", "lendingTradeId", lendingTradeId, "collateralPrice", collateralPrice, "healthFactor", healthFactor, "recallAmount", order.Quantity, "newLockedAmount", newLockedAmount, "newLiquidationPrice", newLiquidationPrice)
+
+	newLendingTrade := lendingTrade
+	newLendingTrade.CollateralLockedAmount = newLockedAmount
+	newLendingTrade.LiquidationPrice = newLiquidationPrice
+	extraData, _ := json.Marshal(RecallData{
+		Price:        collateralPrice,
+		HealthFactor: healthFactor,
+		RecallAmount: order.Quantity,
+	})
+	newLendingTrade.ExtraData = string(extraData)
+	return &newLendingTrade, nil
+}
@@ -0,0 +1,70 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Since TIPTomoXLendingV2 a limit or market item can name a referrer registered with its relayer
+// (see lendingstate/referral.go), an item naming an unregistered referrer is rejected. When the
+// borrowing item of a new trade names a referrer, the owner of the borrowing relayer, who has just
+// been paid the borrowing fee, pays the share of the fee registered for the referrer to it.
+
+// referralFee returns the share of a borrowing fee paid to a referrer.
+func referralFee(borrowingFee *big.Int, share *big.Int) *big.Int {
+	if borrowingFee == nil || borrowingFee.Sign() <= 0 || share.Sign() <= 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Div(new(big.Int).Mul(borrowingFee, share), lendingstate.ReferralShareBasis)
+}
+
+// payReferralFee pays the referrer named by the borrowing item of a new lending trade its share
+// of the borrowing fee, from the owner of the borrowing relayer.
+func payReferralFee(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, trade *lendingstate.LendingTrade, borrowingItem *lendingstate.LendingItem) error {
+	referrer := borrowingItem.Referrer()
+	if referrer == (common.Address{}) {
+		return nil
+	}
+	amount := referralFee(trade.BorrowingFee, lendingStateDB.GetReferralShare(trade.BorrowingRelayer, referrer))
+	if amount.Sign() == 0 {
+		return nil
+	}
+	owner := lendingstate.GetRelayerOwner(trade.BorrowingRelayer, statedb)
+	if err := lendingstate.SubTokenBalance(owner, amount, trade.LendingToken, statedb); err != nil {
+		return err
+	}
+	if err := lendingstate.AddTokenBalance(referrer, amount, trade.LendingToken, statedb); err != nil {
+		return err
+	}
+	lendingStateDB.AddReferralFee(referrer, trade.LendingToken, amount)
+	return nil
+}
+
+// LendingReferrer is the RPC representation of a referrer: its share of the borrowing fees of a
+// relayer and the fees it has been paid in a lending token, by all relayers.
+type LendingReferrer struct {
+	Referrer     common.Address `json:"referrer"`
+	Relayer      common.Address `json:"relayer"`
+	Share        *big.Int       `json:"share"` // in basis points, zero if the referrer isn't registered
+	LendingToken common.Address `json:"lendingToken"`
+	Fees         *big.Int       `json:"fees"`
+}
+
+// referrer returns the registration of a referrer with a relayer and its fees in a lending token
+// at the current block.
+func (l *Lending) referrer(relayer common.Address, referrer common.Address, lendingToken common.Address) (*LendingReferrer, error) {
+	_, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	return &LendingReferrer{
+		Referrer:     referrer,
+		Relayer:      relayer,
+		Share:        lendingState.GetReferralShare(relayer, referrer),
+		LendingToken: lendingToken,
+		Fees:         lendingState.GetReferralFees(referrer, lendingToken),
+	}, nil
+}
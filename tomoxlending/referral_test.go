@@ -0,0 +1,103 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestSplitLendingReferrer(t *testing.T) {
+	referrer := common.HexToAddress("0x0000000000000000000000000000000000000031")
+	for extraData, want := range map[string]struct {
+		rest     string
+		referrer common.Address
+	}{
+		"":                                     {"", common.Address{}},
+		"GTT:1600000000":                       {"GTT:1600000000", common.Address{}},
+		"REF:" + referrer.Hex():                {"", referrer},
+		"IOC;REF:" + referrer.Hex():            {"IOC", referrer},
+		"GTT:1600000000;REF:" + referrer.Hex(): {"GTT:1600000000", referrer},
+		"IOCREF:" + referrer.Hex():             {"IOCREF:" + referrer.Hex(), common.Address{}},
+		"REF:0x31":                             {"REF:0x31", common.Address{}},
+	} {
+		if rest, have := types.SplitLendingReferrer(extraData); rest != want.rest || have != want.referrer {
+			t.Errorf("%q: have %q %s, want %q %s", extraData, rest, have.Hex(), want.rest, want.referrer.Hex())
+		}
+	}
+
+	// the referrer follows the time in force of a limit item
+	item := &lendingstate.LendingItem{Type: lendingstate.Limit, ExtraData: "GTT:1600000000;REF:" + referrer.Hex()}
+	if tif, expiry := item.TimeInForce(); tif != lendingstate.TimeInForceGTT || expiry != 1600000000 || item.VerifyLendingTimeInForce() != nil {
+		t.Errorf("wrong time in force: %s %d", tif, expiry)
+	}
+	if item.Referrer() != referrer {
+		t.Errorf("wrong referrer: %s", item.Referrer().Hex())
+	}
+	// only limit and market items name a referrer, and the referrer of a market item is signed
+	if item := (&lendingstate.LendingItem{Type: lendingstate.Iceberg, ExtraData: "REF:" + referrer.Hex()}); item.Referrer() != (common.Address{}) {
+		t.Errorf("iceberg item with a referrer")
+	}
+	market := &lendingstate.LendingItem{Type: lendingstate.Market, Status: lendingstate.LendingStatusNew, Quantity: big.NewInt(1), Nonce: big.NewInt(1)}
+	hash := market.ComputeHash(true)
+	if market.ExtraData = "REF:" + referrer.Hex(); market.ComputeHash(true) == hash {
+		t.Errorf("referrer not part of the market item hash")
+	}
+	if referred := market.ComputeHash(false); referred != (&lendingstate.LendingItem{Type: lendingstate.Market, Status: lendingstate.LendingStatusNew, Quantity: big.NewInt(1), Nonce: big.NewInt(1)}).ComputeHash(false) {
+		t.Errorf("referrer part of the market item hash before TIPTomoXLendingV2")
+	}
+}
+
+func TestReferralFee(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+
+	var (
+		lendingToken = common.HexToAddress(common.TomoNativeAddress)
+		relayer      = common.HexToAddress("0x21")
+		owner        = common.HexToAddress("0x22")
+		referrer     = common.HexToAddress("0x31")
+	)
+	locRelayer := new(big.Int).Add(lendingstate.GetLocMappingAtKey(relayer.Hash(), lendingstate.RelayerMappingSlot["RELAYER_LIST"]), lendingstate.RelayerStructMappingSlot["_owner"])
+	statedb.SetState(common.HexToAddress(common.RelayerRegistrationSMC), common.BigToHash(locRelayer), owner.Hash())
+	statedb.AddBalance(owner, big.NewInt(1000))
+
+	trade := &lendingstate.LendingTrade{TradeId: 1, LendingToken: lendingToken, BorrowingRelayer: relayer, BorrowingFee: big.NewInt(1000)}
+	borrowing := &lendingstate.LendingItem{Type: lendingstate.Limit, ExtraData: "REF:" + referrer.Hex()}
+
+	// an unregistered referrer isn't paid
+	if err := payReferralFee(lendingStateDB, statedb, trade, borrowing); err != nil {
+		t.Fatalf("failed to pay the referral fee: %v", err)
+	}
+	if balance := statedb.GetBalance(referrer); balance.Sign() != 0 {
+		t.Fatalf("unregistered referrer paid %v", balance)
+	}
+
+	// the referrer is paid 25% of the borrowing fee by the relayer owner
+	lendingStateDB.SetReferralShare(relayer, referrer, big.NewInt(2500))
+	for i := 0; i < 2; i++ {
+		if err := payReferralFee(lendingStateDB, statedb, trade, borrowing); err != nil {
+			t.Fatalf("failed to pay the referral fee: %v", err)
+		}
+	}
+	if balance := statedb.GetBalance(owner); balance.Int64() != 500 {
+		t.Errorf("wrong relayer owner balance: have %v, want 500", balance)
+	}
+	if balance := statedb.GetBalance(referrer); balance.Int64() != 500 {
+		t.Errorf("wrong referrer balance: have %v, want 500", balance)
+	}
+	if fees := lendingStateDB.GetReferralFees(referrer, lendingToken); fees.Int64() != 500 {
+		t.Errorf("wrong referral fees: have %v, want 500", fees)
+	}
+
+	// a zero share unregisters the referrer
+	lendingStateDB.SetReferralShare(relayer, referrer, new(big.Int))
+	if share := lendingStateDB.GetReferralShare(relayer, referrer); share.Sign() != 0 {
+		t.Errorf("referrer still registered: %v", share)
+	}
+}
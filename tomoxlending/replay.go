@@ -0,0 +1,131 @@
+package tomoxlending
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// ReplayResult is the outcome of replaying the lending transactions of a block.
+type ReplayResult struct {
+	Number   uint64
+	Hash     common.Hash
+	Items    int         // lending items recorded in the block
+	Replayed int         // lending items accepted by the replay
+	Root     common.Hash // lending state root after the replay
+	Expected common.Hash // lending state root recorded in the block
+}
+
+// Match returns whether the replay reproduced the lending state recorded in the block.
+func (r *ReplayResult) Match() bool {
+	return r.Root == r.Expected && r.Items == r.Replayed
+}
+
+// replayLendingBatch applies the lending items of a batch strictly in the order of the block, as
+// ValidateLendingOrder does, and returns the number of items applied. The items aren't matched
+// again through processOrders, whose ordering and budget only concern the miner.
+func replayLendingBatch(batch lendingstate.TxLendingBatch, apply func(item *lendingstate.LendingItem) error) int {
+	replayed := 0
+	for _, item := range batch.Data {
+		if err := apply(item); err != nil {
+			log.Debug("Failed to replay lending item", "hash", item.Hash.Hex(), "err", err)
+			continue
+		}
+		replayed++
+	}
+	return replayed
+}
+
+// ReplayBlock re-executes the order transactions of a block on top of the recorded trading and
+// lending state of its parent, the same way the validators do: lending items are applied in the
+// order of the block, and lending trades are liquidated at the liquidation block of an epoch.
+// The resulting lending state root is compared with the root recorded in the block.
+//
+// The state of the parent block must be available, nothing is written to the databases.
+func (l *Lending) ReplayBlock(chain blockChain, block *types.Block) (*ReplayResult, error) {
+	config := chain.Config()
+	if config.Posv == nil || config.Posv.Epoch == 0 {
+		return nil, fmt.Errorf("lending replay requires a posv chain")
+	}
+	if block.NumberU64() == 0 {
+		return nil, fmt.Errorf("can't replay the genesis block")
+	}
+	parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent block #%d not found", block.NumberU64()-1)
+	}
+	author, err := chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	parentAuthor, err := chain.Engine().Author(parent.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, fmt.Errorf("state of block #%d not available: %v", parent.NumberU64(), err)
+	}
+	tradingState, err := l.tomox.GetTradingState(parent, parentAuthor)
+	if err != nil {
+		return nil, fmt.Errorf("trading state of block #%d not available: %v", parent.NumberU64(), err)
+	}
+	lendingState, err := l.GetLendingState(parent, parentAuthor)
+	if err != nil {
+		return nil, fmt.Errorf("lending state of block #%d not available: %v", parent.NumberU64(), err)
+	}
+	expected, err := l.GetLendingStateRoot(block, author)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReplayResult{Number: block.NumberU64(), Hash: block.Hash(), Expected: expected}
+
+	header := block.Header()
+	if block.NumberU64()%config.Posv.Epoch == 0 {
+		if err := l.tomox.UpdateMediumPriceBeforeEpoch(block.NumberU64()/config.Posv.Epoch, tradingState, statedb); err != nil {
+			return nil, err
+		}
+	} else {
+		txMatchBatches, err := core.ExtractTradingTransactions(block.Transactions())
+		if err != nil {
+			return nil, err
+		}
+		for _, txMatchBatch := range txMatchBatches {
+			for _, txMatch := range txMatchBatch.Data {
+				order, err := txMatch.DecodeOrder()
+				if err != nil {
+					continue
+				}
+				if _, _, err := l.tomox.ApplyOrder(header, author, chain, statedb, tradingState, tradingstate.GetTradingOrderBookHash(order.BaseToken, order.QuoteToken), order); err != nil {
+					return nil, fmt.Errorf("failed to apply trading order %s: %v", order.Hash.Hex(), err)
+				}
+			}
+		}
+		batches, err := core.ExtractLendingTransactions(block.Transactions())
+		if err != nil {
+			return nil, err
+		}
+		for _, batch := range batches {
+			result.Items += len(batch.Data)
+			result.Replayed += replayLendingBatch(batch, func(item *lendingstate.LendingItem) error {
+				_, _, err := l.ApplyOrder(header, author, chain, statedb, lendingState, tradingState, lendingstate.GetLendingOrderBookHash(item.LendingToken, item.Term), item)
+				return err
+			})
+		}
+		if _, err := l.SweepExpiredLendingItems(header, chain, statedb, lendingState); err != nil {
+			return nil, fmt.Errorf("failed to sweep expired lending items: %v", err)
+		}
+		if block.NumberU64()%config.Posv.Epoch == common.LiquidateLendingTradeBlock {
+			if _, _, _, _, _, err := l.ProcessLiquidationData(header, chain, statedb, tradingState, lendingState); err != nil {
+				return nil, fmt.Errorf("failed to process liquidation data: %v", err)
+			}
+		}
+	}
+	result.Root = lendingState.IntermediateRoot()
+	return result, nil
+}
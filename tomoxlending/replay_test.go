@@ -0,0 +1,60 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestReplayLendingBatchOrder(t *testing.T) {
+	var (
+		userA = common.HexToAddress("0x2")
+		userB = common.HexToAddress("0x1") // sorted before userA by the nonce heap of the miner
+		batch = lendingstate.TxLendingBatch{Data: []*lendingstate.LendingItem{
+			{UserAddress: userA, Nonce: big.NewInt(0), Hash: common.HexToHash("0xa0")},
+			{UserAddress: userA, Nonce: big.NewInt(1), Hash: common.HexToHash("0xa1")},
+			{UserAddress: userB, Nonce: big.NewInt(0), Hash: common.HexToHash("0xb0")},
+			{UserAddress: userA, Nonce: big.NewInt(2), Hash: common.HexToHash("0xa2")},
+			{UserAddress: userB, Nonce: big.NewInt(1), Hash: common.HexToHash("0xb1")},
+		}}
+		applied []common.Hash
+	)
+	replayed := replayLendingBatch(batch, func(item *lendingstate.LendingItem) error {
+		applied = append(applied, item.Hash)
+		if item.Hash == common.HexToHash("0xb1") {
+			return ErrNonceTooHigh
+		}
+		return nil
+	})
+	// the items of the two users are applied in the order of the block, not by nonce and user
+	if len(applied) != len(batch.Data) {
+		t.Fatalf("applied items mismatch: have %d, want %d", len(applied), len(batch.Data))
+	}
+	for i, item := range batch.Data {
+		if applied[i] != item.Hash {
+			t.Errorf("item %d: applied %x, want %x", i, applied[i], item.Hash)
+		}
+	}
+	if replayed != len(batch.Data)-1 {
+		t.Errorf("replayed items mismatch: have %d, want %d", replayed, len(batch.Data)-1)
+	}
+}
+
+func TestGetLendingStateRootCache(t *testing.T) {
+	l := &Lending{lendingRoots: newLendingCache(rootsCache, lendingRootsLimit)}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10)})
+	root, err := l.GetLendingStateRoot(block, common.Address{})
+	if err != nil {
+		t.Fatalf("failed to get lending state root: %v", err)
+	}
+	if root != lendingstate.EmptyRoot {
+		t.Fatalf("wrong lending state root: have %x, want %x", root, lendingstate.EmptyRoot)
+	}
+	cached, ok := l.lendingRoots.Get(block.Hash())
+	if !ok || cached.(common.Hash) != root {
+		t.Fatalf("lending state root of block %x not cached", block.Hash())
+	}
+}
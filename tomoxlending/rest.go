@@ -0,0 +1,139 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+)
+
+// The lending REST gateway lets relayers submit signed lending orders over plain HTTP, without a
+// JSON-RPC client:
+//
+//	POST /api/v1/lending/orders
+//
+// The body is a lending item, or an array of at most maxLendingItemsPerCall items, with the json
+// fields of tomox_sendLending. Each item is signed by its user over the hash of the lending order
+// (LendingTxSigner.Hash) prefixed with "\x19Ethereum Signed Message:\n32", so the items signed for
+// tomox_sendLending are accepted as is, or since TIPTomoXLendingEIP712 over its EIP-712 typed data
+// (see tomoxlending_getLendingTypedData). The gateway recovers the signer of every item
+// and rejects the request if one of them isn't signed by its user; otherwise the items are added
+// to the lending pool together, or none of them. It replies with the transaction hashes:
+//
+//	{"hashes": ["0x..."]}
+//
+// or with an error status and {"error": "..."}.
+const restOrdersPath = "/api/v1/lending/orders"
+
+const maxRESTBodySize = 1024 * 1024 // Maximum size of a request body
+
+var errInvalidLendingSignature = errors.New("lending item not signed by its user")
+
+// restReply is the json body of the replies of the REST gateway.
+type restReply struct {
+	Hashes []common.Hash `json:"hashes,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// startREST serves the lending REST gateway on a TCP address until the service stops.
+func (l *Lending) startREST(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	l.rest = &http.Server{
+		Handler:      newRESTHandler(l),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	go l.rest.Serve(listener)
+	log.Info("Lending REST gateway opened", "addr", listener.Addr(), "path", restOrdersPath)
+	return nil
+}
+
+// newRESTHandler returns the http handler of the lending REST gateway.
+func newRESTHandler(l *Lending) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(restOrdersPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeRESTReply(w, http.StatusMethodNotAllowed, restReply{Error: "method not allowed"})
+			return
+		}
+		items, err := decodeRESTItems(io.LimitReader(r.Body, maxRESTBodySize))
+		if err != nil {
+			writeRESTReply(w, http.StatusBadRequest, restReply{Error: err.Error()})
+			return
+		}
+		hashes, err := l.submitSignedLendingItems(items)
+		switch err {
+		case nil:
+			writeRESTReply(w, http.StatusOK, restReply{Hashes: hashes})
+		case errInvalidLendingSignature:
+			writeRESTReply(w, http.StatusUnauthorized, restReply{Error: err.Error()})
+		case errLendingStateUnavailable:
+			writeRESTReply(w, http.StatusServiceUnavailable, restReply{Error: err.Error()})
+		default:
+			writeRESTReply(w, http.StatusBadRequest, restReply{Error: err.Error()})
+		}
+	})
+	return mux
+}
+
+// decodeRESTItems decodes a lending item or an array of lending items.
+func decodeRESTItems(r io.Reader) ([]LendingItemArgs, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var items []LendingItemArgs
+	if err := json.Unmarshal(body, &items); err == nil {
+		return items, nil
+	}
+	var item LendingItemArgs
+	if err := json.Unmarshal(body, &item); err != nil {
+		return nil, err
+	}
+	return []LendingItemArgs{item}, nil
+}
+
+// submitSignedLendingItems checks that lending items are signed by their users and adds them to
+// the lending pool at once.
+func (l *Lending) submitSignedLendingItems(items []LendingItemArgs) ([]common.Hash, error) {
+	if len(items) == 0 {
+		return nil, ErrNoLendingItems
+	}
+	if len(items) > maxLendingItemsPerCall {
+		return nil, ErrTooManyLendingItems
+	}
+	if l.lendingPool == nil {
+		return nil, errLendingStateUnavailable
+	}
+	signer := l.lendingSigner()
+	txs := make([]*types.LendingTransaction, len(items))
+	hashes := make([]common.Hash, len(items))
+	for i := range items {
+		tx := items[i].toTransaction()
+		if from, err := types.LendingSender(signer, tx); err != nil || from != tx.UserAddress() {
+			return nil, errInvalidLendingSignature
+		}
+		txs[i], hashes[i] = tx, tx.Hash()
+	}
+	if err := l.lendingPool.AddLocalsAtomic(txs); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func writeRESTReply(w http.ResponseWriter, status int, reply restReply) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(reply)
+}
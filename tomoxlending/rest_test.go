@@ -0,0 +1,105 @@
+package tomoxlending
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// restTestPool is a lending pool recording the transactions added to it.
+type restTestPool struct {
+	lendingTxPool
+	added []*types.LendingTransaction
+}
+
+func (p *restTestPool) AddLocalsAtomic(txs []*types.LendingTransaction) error {
+	p.added = append(p.added, txs...)
+	return nil
+}
+
+func TestRESTSubmitLendingOrders(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	tx := types.NewLendingTransaction(3, big.NewInt(1000), 10, 86400, common.HexToAddress("0x1"), user, common.HexToAddress("0x2"), common.HexToAddress("0x3"),
+		true, lendingstate.LendingStatusNew, lendingstate.Investing, lendingstate.Limit, common.Hash{}, 0, 0, "")
+	signed, err := types.LendingSignTx(tx, types.LendingTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign lending transaction: %v", err)
+	}
+	V, R, S := signed.Signature()
+	item := LendingItemArgs{
+		AccountNonce:    hexutil.Uint64(signed.Nonce()),
+		Quantity:        hexutil.Big(*signed.Quantity()),
+		RelayerAddress:  signed.RelayerAddress(),
+		UserAddress:     user,
+		CollateralToken: signed.CollateralToken(),
+		AutoTopUp:       true,
+		LendingToken:    signed.LendingToken(),
+		Term:            hexutil.Uint64(signed.Term()),
+		Interest:        hexutil.Uint64(signed.Interest()),
+		Status:          signed.Status(),
+		Side:            signed.Side(),
+		Type:            signed.Type(),
+		V:               hexutil.Big(*V),
+		R:               hexutil.Big(*R),
+		S:               hexutil.Big(*S),
+	}
+	forged := item
+	forged.UserAddress = common.HexToAddress("0x4")
+
+	l := New(tomox.New(&tomox.Config{DevDB: true}))
+	defer l.tomox.GetMongoDB().Close()
+	server := httptest.NewServer(newRESTHandler(l))
+	defer server.Close()
+	post := func(body interface{}) (int, restReply) {
+		data, _ := json.Marshal(body)
+		resp, err := http.Post(server.URL+restOrdersPath, "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var reply restReply
+		json.NewDecoder(resp.Body).Decode(&reply)
+		return resp.StatusCode, reply
+	}
+	if status, _ := post(item); status != http.StatusServiceUnavailable {
+		t.Errorf("wrong status without a lending pool: have %d, want %d", status, http.StatusServiceUnavailable)
+	}
+	pool := &restTestPool{}
+	l.SetLendingPool(pool)
+
+	if status, reply := post([]LendingItemArgs{item, forged}); status != http.StatusUnauthorized || reply.Error != errInvalidLendingSignature.Error() {
+		t.Errorf("wrong reply to a forged item: %d %v", status, reply)
+	}
+	if len(pool.added) != 0 {
+		t.Fatalf("items of a rejected request added to the pool")
+	}
+	status, reply := post(item)
+	if status != http.StatusOK || len(reply.Hashes) != 1 || reply.Hashes[0] != signed.Hash() {
+		t.Fatalf("wrong reply to a signed item: %d %v", status, reply)
+	}
+	if len(pool.added) != 1 || pool.added[0].Hash() != signed.Hash() {
+		t.Errorf("signed item not added to the pool")
+	}
+	if status, _ := post([]LendingItemArgs{}); status != http.StatusBadRequest {
+		t.Errorf("wrong status of an empty request: %d", status)
+	}
+	resp, err := http.Get(server.URL + restOrdersPath)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("wrong status of a GET request: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,187 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// A borrower renews a lending trade by sending a Rollover lending item with the highest interest
+// it accepts to pay for another term. At maturity, instead of being repaid or liquidated by time,
+// the trade is refinanced by the investing items of its lending book at an interest up to this one:
+// the new investors pay back the principal to the investor of the trade, the borrower pays the
+// interest of the expired term, and the trade is replaced by one new trade per investing item,
+// sharing its locked collateral. No borrowing fee is charged on a rollover.
+// If the investing items can't refinance the whole principal, the trade expires as usual.
+
+var errRolloverNotFilled = errors.New("not enough investing items to roll over the lending trade")
+
+// RolloverData is recorded in the ExtraData of a lending trade replaced by a rollover.
+// The ExtraData of the trade returned for a Rollover lending item only holds the requested interest.
+type RolloverData struct {
+	MaxInterest *big.Int      `json:",omitempty"` // highest interest accepted by the borrower
+	Profit      *big.Int      `json:",omitempty"` // interest of the expired term paid to the investor
+	NewTrades   []common.Hash `json:",omitempty"` // hashes of the trades renewing the lending trade
+}
+
+// ProcessRollover records the rollover request of a Rollover lending item for its trade. A zero
+// interest cancels the request. It returns the trade, with the request in its ExtraData.
+func (l *Lending) ProcessRollover(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash, order *lendingstate.LendingItem) (*lendingstate.LendingTrade, error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(order.LendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("ProcessRollover for emptyLendingTrade is not allowed. lendingTradeId: %v", order.LendingTradeId)
+	}
+	if order.UserAddress != lendingTrade.Borrower {
+		return nil, fmt.Errorf("ProcessRollover: user %s is not the borrower of lendingTradeId: %v", order.UserAddress.Hex(), order.LendingTradeId)
+	}
+	if lendingTrade.LiquidationTime <= header.Time.Uint64() {
+		return nil, fmt.Errorf("ProcessRollover: lendingTrade expired. lendingTradeId: %v", order.LendingTradeId)
+	}
+	if isMultiCollateralTrade(lendingStateDB, lendingBook, lendingTrade.TradeId) {
+		return nil, fmt.Errorf("ProcessRollover: lendingTradeId %v has extra collaterals", order.LendingTradeId)
+	}
+	if order.Interest == nil || common.BigToHash(order.Interest).Big().Cmp(order.Interest) != 0 {
+		return nil, fmt.Errorf("ProcessRollover: invalid interest %v", order.Interest)
+	}
+	lendingStateDB.SetRolloverInterest(lendingBook, lendingTrade.TradeId, order.Interest)
+	log.Debug("ProcessRollover", "tradeId", lendingTrade.TradeId, "maxInterest", order.Interest)
+
+	extraData, _ := json.Marshal(RolloverData{MaxInterest: order.Interest})
+	lendingTrade.ExtraData = string(extraData)
+	return &lendingTrade, nil
+}
+
+// rolloverLendingTrade renews a lending trade which reached maturity with the investing items of
+// its lending book, if its borrower requested it. It returns the closed trade and the new trades.
+// Nothing is changed if the trade can't be rolled over, but the request is consumed.
+func (l *Lending) rolloverLendingTrade(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64) (closedTrade *lendingstate.LendingTrade, newTrades []*lendingstate.LendingTrade, err error) {
+	maxInterest := lendingStateDB.GetRolloverInterest(lendingBook, lendingTradeId)
+	if maxInterest.Sign() == 0 {
+		return nil, nil, nil
+	}
+	lendingStateDB.SetRolloverInterest(lendingBook, lendingTradeId, new(big.Int))
+
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade.TradeId != lendingTradeId {
+		return nil, nil, fmt.Errorf("Lending Trade Id not found : %d ", lendingTradeId)
+	}
+	if isMultiCollateralTrade(lendingStateDB, lendingBook, lendingTradeId) {
+		return nil, nil, fmt.Errorf("rolloverLendingTrade: lendingTradeId %v has extra collaterals", lendingTradeId)
+	}
+	time := header.Time.Uint64()
+	paymentBalance := lendingStateDB.GetTotalRepayValue(lendingBook, &lendingTrade, time)
+	interestAmount := new(big.Int).Sub(paymentBalance, lendingTrade.Amount)
+	if tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb); tokenBalance.Cmp(interestAmount) < 0 {
+		return nil, nil, fmt.Errorf("Not enough balance to pay the interest need : %s , have : %s ", interestAmount, tokenBalance)
+	}
+
+	lendingSnap := lendingStateDB.Snapshot()
+	tradingSnap := tradingStateDB.Snapshot()
+	dbSnap := statedb.Snapshot()
+	defer func() {
+		if err != nil {
+			lendingStateDB.RevertToSnapshot(lendingSnap)
+			tradingStateDB.RevertToSnapshot(tradingSnap)
+			statedb.RevertToSnapshot(dbSnap)
+			newTrades = nil
+		}
+	}()
+
+	orderbook := tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	remaining := new(big.Int).Set(lendingTrade.Amount)
+	remainingLocked := new(big.Int).Set(lendingTrade.CollateralLockedAmount)
+	for remaining.Sign() > 0 {
+		interest, _ := lendingStateDB.GetBestInvestingRate(lendingBook)
+		if interest.Sign() <= 0 || interest.Cmp(maxInterest) > 0 {
+			return nil, nil, errRolloverNotFilled
+		}
+		orderId, amount, err := lendingStateDB.GetBestLendingIdAndAmount(lendingBook, interest, lendingstate.Investing)
+		if err != nil {
+			return nil, nil, err
+		}
+		if amount.Sign() <= 0 {
+			return nil, nil, errRolloverNotFilled
+		}
+		investingItem := lendingStateDB.GetLendingOrder(lendingBook, orderId)
+		quantity := lendingstate.CloneBigInt(remaining)
+		if amount.Cmp(quantity) < 0 {
+			quantity = lendingstate.CloneBigInt(amount)
+		}
+		if tokenBalance := lendingstate.GetTokenBalance(investingItem.UserAddress, lendingTrade.LendingToken, statedb); tokenBalance.Cmp(quantity) < 0 {
+			return nil, nil, fmt.Errorf("rolloverLendingTrade: not enough balance for investing item %d need : %s , have : %s ", investingItem.LendingId, quantity, tokenBalance)
+		}
+		lockedAmount := remainingLocked
+		if quantity.Cmp(remaining) < 0 {
+			lockedAmount = new(big.Int).Mul(lendingTrade.CollateralLockedAmount, quantity)
+			lockedAmount = new(big.Int).Div(lockedAmount, lendingTrade.Amount)
+		}
+		if err := lendingStateDB.SubAmountLendingItem(lendingBook, orderId, interest, quantity, lendingstate.Investing); err != nil {
+			return nil, nil, err
+		}
+		lendingstate.SubTokenBalance(investingItem.UserAddress, quantity, lendingTrade.LendingToken, statedb)
+		lendingstate.AddTokenBalance(lendingTrade.Investor, quantity, lendingTrade.LendingToken, statedb)
+
+		tradeId := lendingStateDB.GetTradeNonce(lendingBook) + 1
+		newTrade := lendingTrade
+		newTrade.TradeId = tradeId
+		newTrade.Amount = quantity
+		newTrade.CollateralLockedAmount = lockedAmount
+		newTrade.Interest = investingItem.Interest.Uint64()
+		newTrade.LiquidationTime = time + lendingTrade.Term
+		newTrade.Investor = investingItem.UserAddress
+		newTrade.InvestingRelayer = investingItem.Relayer
+		newTrade.InvestingOrderHash = investingItem.Hash
+		newTrade.BorrowingOrderHash = lendingTrade.Hash
+		newTrade.TakerOrderSide = lendingstate.Borrowing
+		newTrade.TakerOrderType = lendingstate.Rollover
+		newTrade.MakerOrderType = investingItem.Type
+		newTrade.BorrowingFee = lendingstate.Zero
+		newTrade.InvestingFee = lendingstate.Zero
+		newTrade.Status = lendingstate.TradeStatusOpen
+		newTrade.ExtraData = ""
+		newTrade.Hash = newTrade.ComputeHash()
+
+		lendingStateDB.InsertTradingItem(lendingBook, tradeId, newTrade)
+		lendingStateDB.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(newTrade.LiquidationTime), tradeId)
+		lendingStateDB.SetTradeNonce(lendingBook, tradeId)
+		tradingStateDB.InsertLiquidationPrice(orderbook, newTrade.LiquidationPrice, lendingBook, tradeId)
+		log.Debug("Rollover lending trade", "lendingBook", lendingBook.Hex(), "tradeId", lendingTradeId, "newTradeId", tradeId, "amount", quantity, "interest", newTrade.Interest, "lockedAmount", lockedAmount)
+		newTrades = append(newTrades, &newTrade)
+
+		remaining = new(big.Int).Sub(remaining, quantity)
+		remainingLocked = new(big.Int).Sub(remainingLocked, lockedAmount)
+	}
+	lendingstate.SubTokenBalance(lendingTrade.Borrower, interestAmount, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, interestAmount, lendingTrade.LendingToken, statedb)
+	if _, err = payHaircut(lendingStateDB, statedb, lendingBook, &lendingTrade, new(big.Int).Add(lendingTrade.Amount, interestAmount)); err != nil {
+		return nil, nil, err
+	}
+
+	if err = lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime); err != nil {
+		return nil, nil, err
+	}
+	if err = tradingStateDB.RemoveLiquidationPrice(orderbook, lendingTrade.LiquidationPrice, lendingBook, lendingTradeId); err != nil {
+		return nil, nil, err
+	}
+	rolloverMarginPosition(lendingStateDB, lendingBook, lendingTradeId, newTrades)
+	clearSettledInterest(lendingStateDB, lendingBook, lendingTradeId)
+	if err = lendingStateDB.CancelLendingTrade(lendingBook, lendingTradeId); err != nil {
+		return nil, nil, err
+	}
+	rolloverData := RolloverData{Profit: interestAmount}
+	for _, newTrade := range newTrades {
+		rolloverData.NewTrades = append(rolloverData.NewTrades, newTrade.Hash)
+	}
+	extraData, _ := json.Marshal(rolloverData)
+	lendingTrade.Status = lendingstate.TradeStatusClosed
+	lendingTrade.ExtraData = string(extraData)
+	return &lendingTrade, newTrades, nil
+}
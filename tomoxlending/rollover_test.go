@@ -0,0 +1,130 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestRolloverLendingTrade(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		lendingToken = common.HexToAddress(common.TomoNativeAddress)
+		collateral   = common.HexToAddress("0x3")
+		borrower     = common.HexToAddress("0x1")
+		investor     = common.HexToAddress("0x2")
+		makers       = []common.Address{common.HexToAddress("0x4"), common.HexToAddress("0x5")}
+		term         = uint64(86400)
+		lendingBook  = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		orderbook    = tradingstate.GetTradingOrderBookHash(collateral, lendingToken)
+		endTime      = uint64(1600000000)
+		ether        = big.NewInt(1e18)
+	)
+	interest := func(percent int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(percent), common.BaseLendingInterest)
+	}
+	trade := lendingstate.LendingTrade{
+		TradeId:                1,
+		Borrower:               borrower,
+		Investor:               investor,
+		LendingToken:           lendingToken,
+		CollateralToken:        collateral,
+		Term:                   term,
+		Interest:               interest(10).Uint64(),
+		Amount:                 new(big.Int).Mul(big.NewInt(1000), ether),
+		CollateralLockedAmount: big.NewInt(500),
+		LiquidationPrice:       big.NewInt(700),
+		LiquidationTime:        endTime,
+		Status:                 lendingstate.TradeStatusOpen,
+	}
+	trade.Hash = trade.ComputeHash()
+	lendingStateDB.InsertTradingItem(lendingBook, trade.TradeId, trade)
+	lendingStateDB.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(endTime), trade.TradeId)
+	lendingStateDB.SetTradeNonce(lendingBook, trade.TradeId)
+	tradingStateDB.InsertLiquidationPrice(orderbook, trade.LiquidationPrice, lendingBook, trade.TradeId)
+
+	// two investing items at 5% and 8% refinancing 600 and 400 of the principal
+	for i, maker := range makers {
+		item := lendingstate.LendingItem{
+			LendingId:    uint64(i + 1),
+			Quantity:     new(big.Int).Mul(big.NewInt(int64(600+400*i)), ether),
+			Interest:     interest(int64(5 + 3*i)),
+			Side:         lendingstate.Investing,
+			Type:         lendingstate.Limit,
+			LendingToken: lendingToken,
+			Term:         term,
+			UserAddress:  maker,
+			Status:       lendingstate.LendingStatusOpen,
+			Signature:    &lendingstate.Signature{V: 1},
+			Hash:         common.BigToHash(big.NewInt(int64(i + 1))),
+		}
+		lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(item.LendingId), item)
+		statedb.AddBalance(maker, item.Quantity)
+	}
+	interestAmount := new(big.Int).Sub(lendingstate.CalculateTotalRepayValue(endTime, endTime, term, trade.Interest, trade.Amount), trade.Amount)
+	statedb.AddBalance(borrower, interestAmount)
+	root, err := lendingStateDB.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	lendingStateDB, _ = lendingstate.New(root, lendingStateDB.Database())
+
+	l := &Lending{}
+	header := &types.Header{Time: new(big.Int).SetUint64(endTime)}
+	if closed, _, err := l.rolloverLendingTrade(header, lendingStateDB, statedb, tradingStateDB, lendingBook, trade.TradeId); closed != nil || err != nil {
+		t.Fatalf("rolled over a trade without request: %v, %v", closed, err)
+	}
+
+	// the investing items up to 5% can't refinance the whole principal
+	lendingStateDB.SetRolloverInterest(lendingBook, trade.TradeId, interest(5))
+	if _, _, err := l.rolloverLendingTrade(header, lendingStateDB, statedb, tradingStateDB, lendingBook, trade.TradeId); err != errRolloverNotFilled {
+		t.Fatalf("wrong error: have %v, want %v", err, errRolloverNotFilled)
+	}
+	if balance := statedb.GetBalance(investor); balance.Sign() != 0 {
+		t.Fatalf("balance not reverted: have %v, want 0", balance)
+	}
+	if _, amount, _ := lendingStateDB.GetBestLendingIdAndAmount(lendingBook, interest(5), lendingstate.Investing); amount.Cmp(new(big.Int).Mul(big.NewInt(600), ether)) != 0 {
+		t.Fatalf("investing item not reverted: have %v", amount)
+	}
+	if maxInterest := lendingStateDB.GetRolloverInterest(lendingBook, trade.TradeId); maxInterest.Sign() != 0 {
+		t.Fatalf("rollover request not consumed: %v", maxInterest)
+	}
+
+	lendingStateDB.SetRolloverInterest(lendingBook, trade.TradeId, interest(8))
+	closed, newTrades, err := l.rolloverLendingTrade(header, lendingStateDB, statedb, tradingStateDB, lendingBook, trade.TradeId)
+	if err != nil {
+		t.Fatalf("failed to roll over: %v", err)
+	}
+	if closed.Status != lendingstate.TradeStatusClosed || len(newTrades) != 2 {
+		t.Fatalf("wrong rollover result: %v, %v", closed, newTrades)
+	}
+	for i, newTrade := range newTrades {
+		wantAmount := new(big.Int).Mul(big.NewInt(int64(600-200*i)), ether)
+		wantLocked := big.NewInt(int64(300 - 100*i))
+		if newTrade.Investor != makers[i] || newTrade.Amount.Cmp(wantAmount) != 0 || newTrade.CollateralLockedAmount.Cmp(wantLocked) != 0 || newTrade.LiquidationTime != endTime+term {
+			t.Errorf("wrong new trade %d: %v", i, newTrade)
+		}
+		if stored := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(newTrade.TradeId)); stored.Hash != newTrade.Hash {
+			t.Errorf("new trade %d not stored", i)
+		}
+	}
+	if balance, want := statedb.GetBalance(investor), new(big.Int).Add(trade.Amount, interestAmount); balance.Cmp(want) != 0 {
+		t.Errorf("wrong investor balance: have %v, want %v", balance, want)
+	}
+	if balance := statedb.GetBalance(borrower); balance.Sign() != 0 {
+		t.Errorf("wrong borrower balance: have %v, want 0", balance)
+	}
+	if stored := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(trade.TradeId)); stored != lendingstate.EmptyLendingTrade {
+		t.Errorf("trade still open after rollover: %v", stored)
+	}
+}
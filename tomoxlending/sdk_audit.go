@@ -0,0 +1,250 @@
+package tomoxlending
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+const maxSDKAuditRange = 10000 // Maximum number of blocks scanned by an SDK data audit
+
+var (
+	errSDKAuditRange   = errors.New("SDK data audit exceeds the maximum block range")
+	errSDKAuditNotNode = errors.New("SDK data audit requires an SDK node")
+)
+
+// SDKAuditArgs selects the blocks whose lending items and trades are audited by AuditSDKData.
+// With Repair set, the divergent records are rewritten from the lending state.
+type SDKAuditArgs struct {
+	FromBlock *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`
+	Repair    bool             `json:"repair"`
+}
+
+// SDKDivergence is a field of an SDK record which doesn't match the lending state.
+type SDKDivergence struct {
+	Collection string      `json:"collection"` // lending_items or lending_trades
+	Hash       common.Hash `json:"hash"`
+	Field      string      `json:"field"`
+	SDK        string      `json:"sdk"`   // value recorded in the SDK database
+	State      string      `json:"state"` // value derived from the lending state
+	Repaired   bool        `json:"repaired"`
+}
+
+// SDKAuditResult is the result of an SDK data audit, against the lending state of the current block.
+type SDKAuditResult struct {
+	FromBlock   uint64           `json:"fromBlock"`
+	ToBlock     uint64           `json:"toBlock"`
+	StateBlock  uint64           `json:"stateBlock"`
+	Items       int              `json:"items"`
+	Trades      int              `json:"trades"`
+	Divergences []*SDKDivergence `json:"divergences"`
+}
+
+// auditSDKData cross-checks the lending items and trades sent in a range of canonical blocks, as
+// recorded in the SDK database, against the lending state of the current block, since the SDK
+// database reflects the current block:
+//   - a limit item still in its lending book must be OPEN or PARTIAL_FILLED, filled for its
+//     quantity minus the quantity left in the book,
+//   - a limit item no longer in its book must be FILLED, CANCELLED or REJECTED,
+//   - a trade still in its lending book must be OPEN with the amount, locked collateral and
+//     liquidation price of the state, and a trade no longer in the book must not be OPEN.
+//
+// The divergences are reported and, if requested, repaired when the state tells the right
+// record: a closed item which was cancelled in the range becomes CANCELLED, any other FILLED.
+// A trade no longer in the book is only reported, its status can't be told from the state.
+func (l *Lending) auditSDKData(args SDKAuditArgs) (*SDKAuditResult, error) {
+	if !l.tomox.IsSDKNode() {
+		return nil, errSDKAuditNotNode
+	}
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	head := block.NumberU64()
+	from, to := resolveLendingLogsBlock(args.FromBlock, head), resolveLendingLogsBlock(args.ToBlock, head)
+	if to > head {
+		to = head
+	}
+	result := &SDKAuditResult{FromBlock: from, ToBlock: to, StateBlock: head, Divergences: []*SDKDivergence{}}
+	if from > to {
+		return result, nil
+	}
+	if to-from >= maxSDKAuditRange {
+		return nil, errSDKAuditRange
+	}
+
+	var (
+		db         = l.GetMongoDB()
+		itemHashes []common.Hash
+		txHashes   []common.Hash
+		seen       = make(map[common.Hash]bool)
+		cancelled  = make(map[common.Hash]bool)
+	)
+	for number := from; number <= to; number++ {
+		block := l.chain.GetBlockByNumber(number)
+		if block == nil {
+			continue
+		}
+		batches, err := core.ExtractLendingTransactions(block.Transactions())
+		if err != nil {
+			return nil, err
+		}
+		for _, batch := range batches {
+			txHashes = append(txHashes, batch.TxHash)
+			for _, item := range batch.Data {
+				if item.Type != lendingstate.Limit {
+					continue
+				}
+				if item.Status == lendingstate.LendingStatusCancelled {
+					cancelled[item.Hash] = true
+				}
+				if !seen[item.Hash] {
+					seen[item.Hash] = true
+					itemHashes = append(itemHashes, item.Hash)
+				}
+			}
+		}
+	}
+
+	var dirty []interface{}
+	for _, hash := range itemHashes {
+		val, err := db.GetObject(hash, &lendingstate.LendingItem{})
+		if err != nil || val == nil {
+			result.Divergences = append(result.Divergences, &SDKDivergence{Collection: "lending_items", Hash: hash, Field: "record", SDK: "missing", State: "present"})
+			continue
+		}
+		item := val.(*lendingstate.LendingItem)
+		result.Items++
+		if item.Quantity == nil {
+			continue
+		}
+		filled := item.FilledAmount
+		if filled == nil {
+			filled = new(big.Int)
+		}
+		wantStatus, wantFilled := stateLendingItemStatus(lendingState, item, filled, cancelled[item.Hash])
+		divergences := auditField(nil, "lending_items", hash, "status", item.Status, wantStatus)
+		divergences = auditField(divergences, "lending_items", hash, "filledAmount", filled.String(), wantFilled.String())
+		if len(divergences) == 0 {
+			continue
+		}
+		result.Divergences = append(result.Divergences, divergences...)
+		if args.Repair {
+			item.Status, item.FilledAmount = wantStatus, new(big.Int).Set(wantFilled)
+			dirty = append(dirty, item)
+			markRepaired(divergences)
+		}
+	}
+
+	for _, txHash := range txHashes {
+		trades, _ := db.GetListItemByTxHash(txHash, &lendingstate.LendingTrade{}).([]*lendingstate.LendingTrade)
+		for _, trade := range trades {
+			result.Trades++
+			book := lendingstate.GetLendingOrderBookHash(trade.LendingToken, trade.Term)
+			stateTrade := lendingState.GetLendingTrade(book, common.Uint64ToHash(trade.TradeId))
+			if stateTrade.Amount == nil || stateTrade.Amount.Sign() == 0 {
+				if trade.Status == lendingstate.TradeStatusOpen {
+					result.Divergences = append(result.Divergences, &SDKDivergence{Collection: "lending_trades", Hash: trade.Hash, Field: "status", SDK: trade.Status, State: "not " + lendingstate.TradeStatusOpen})
+				}
+				continue
+			}
+			divergences := auditField(nil, "lending_trades", trade.Hash, "status", trade.Status, lendingstate.TradeStatusOpen)
+			divergences = auditField(divergences, "lending_trades", trade.Hash, "amount", bigString(trade.Amount), bigString(stateTrade.Amount))
+			divergences = auditField(divergences, "lending_trades", trade.Hash, "collateralLockedAmount", bigString(trade.CollateralLockedAmount), bigString(stateTrade.CollateralLockedAmount))
+			divergences = auditField(divergences, "lending_trades", trade.Hash, "liquidationPrice", bigString(trade.LiquidationPrice), bigString(stateTrade.LiquidationPrice))
+			if len(divergences) == 0 {
+				continue
+			}
+			result.Divergences = append(result.Divergences, divergences...)
+			if args.Repair {
+				trade.Status = lendingstate.TradeStatusOpen
+				trade.Amount = lendingstate.CloneBigInt(stateTrade.Amount)
+				trade.CollateralLockedAmount = lendingstate.CloneBigInt(stateTrade.CollateralLockedAmount)
+				trade.LiquidationPrice = lendingstate.CloneBigInt(stateTrade.LiquidationPrice)
+				dirty = append(dirty, trade)
+				markRepaired(divergences)
+			}
+		}
+	}
+	if len(dirty) > 0 {
+		if err := l.repairSDKData(dirty); err != nil {
+			return nil, err
+		}
+		log.Info("Repaired SDK lending data", "from", from, "to", to, "records", len(dirty))
+	}
+	return result, nil
+}
+
+// stateLendingItemStatus returns the status and filled amount of a limit item recorded with the
+// given filled amount, as told by the lending state: an item still in its lending book is OPEN or
+// PARTIAL_FILLED, an item which left its book was CANCELLED if it was seen cancelled and FILLED
+// otherwise. The status of an item already closed is kept.
+func stateLendingItemStatus(lendingState *lendingstate.LendingStateDB, item *lendingstate.LendingItem, filled *big.Int, cancelled bool) (string, *big.Int) {
+	book := lendingstate.GetLendingOrderBookHash(item.LendingToken, item.Term)
+	remaining := lendingState.GetLendingOrder(book, common.BigToHash(new(big.Int).SetUint64(item.LendingId))).Quantity
+	if remaining != nil && remaining.Sign() > 0 {
+		filled = new(big.Int).Sub(item.Quantity, remaining)
+		if filled.Sign() > 0 {
+			return lendingstate.LendingStatusPartialFilled, filled
+		}
+		return lendingstate.LendingStatusOpen, filled
+	}
+	if item.Status != lendingstate.LendingStatusOpen && item.Status != lendingstate.LendingStatusPartialFilled {
+		return item.Status, filled
+	}
+	if cancelled {
+		return lendingstate.LendingStatusCancelled, filled
+	}
+	return lendingstate.LendingStatusFilled, item.Quantity
+}
+
+// repairSDKData rewrites audited or backfilled records to the SDK database, in between two
+// synchronisations.
+func (l *Lending) repairSDKData(records []interface{}) error {
+	l.sdkSyncLock.Lock()
+	defer l.sdkSyncLock.Unlock()
+
+	db := l.GetMongoDB()
+	db.InitLendingBulk()
+	now := time.Now().UTC()
+	for _, record := range records {
+		var hash common.Hash
+		switch r := record.(type) {
+		case *lendingstate.LendingItem:
+			r.UpdatedAt, hash = now, r.Hash
+		case *lendingstate.LendingTrade:
+			r.UpdatedAt, hash = now, r.Hash
+		}
+		if err := db.PutObject(hash, record); err != nil {
+			return err
+		}
+	}
+	return db.CommitLendingBulk()
+}
+
+func auditField(divergences []*SDKDivergence, collection string, hash common.Hash, field, sdk, state string) []*SDKDivergence {
+	if sdk == state {
+		return divergences
+	}
+	return append(divergences, &SDKDivergence{Collection: collection, Hash: hash, Field: field, SDK: sdk, State: state})
+}
+
+func markRepaired(divergences []*SDKDivergence) {
+	for _, divergence := range divergences {
+		divergence.Repaired = true
+	}
+}
+
+func bigString(n *big.Int) string {
+	if n == nil {
+		return "0"
+	}
+	return n.String()
+}
@@ -0,0 +1,130 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// auditTestEngine attributes every block to the zero address.
+type auditTestEngine struct {
+	consensus.Engine
+}
+
+func (auditTestEngine) Author(header *types.Header) (common.Address, error) {
+	return common.Address{}, nil
+}
+
+// auditTestChain is a fixed canonical chain with an engine.
+type auditTestChain struct {
+	logsTestChain
+}
+
+func (c *auditTestChain) Engine() consensus.Engine { return auditTestEngine{} }
+
+func TestAuditSDKData(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir(), DBEngine: "badger"}))
+	defer l.GetMongoDB().Close()
+
+	var (
+		usdt = common.HexToAddress("0x10")
+		user = common.HexToAddress("0x1")
+		book = lendingstate.GetLendingOrderBookHash(usdt, 86400)
+	)
+	newItem := func(id uint64) *lendingstate.LendingItem {
+		return &lendingstate.LendingItem{
+			Quantity:     big.NewInt(100),
+			Interest:     big.NewInt(10),
+			Side:         lendingstate.Borrowing,
+			Type:         lendingstate.Limit,
+			LendingToken: usdt,
+			Term:         86400,
+			Status:       lendingstate.LendingStatusNew,
+			UserAddress:  user,
+			LendingId:    id,
+			Hash:         common.BigToHash(new(big.Int).SetUint64(id)),
+			Signature:    &lendingstate.Signature{},
+		}
+	}
+	data, err := lendingstate.EncodeTxLendingBatch(lendingstate.TxLendingBatch{Data: []*lendingstate.LendingItem{newItem(1), newItem(2)}})
+	if err != nil {
+		t.Fatalf("failed to encode batch: %v", err)
+	}
+	tx := types.NewTransaction(0, common.HexToAddress(common.TomoXLendingAddress), new(big.Int), 0, new(big.Int), data)
+	chain := &auditTestChain{}
+	chain.blocks = []*types.Block{
+		types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), Time: big.NewInt(0)}),
+		types.NewBlock(&types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}, []*types.Transaction{tx}, nil, nil),
+	}
+	l.chain = chain
+
+	// item 1 has 60 left in its book, item 2 is closed, trade 1 is open
+	lendingState, err := lendingstate.New(lendingstate.EmptyRoot, l.StateCache)
+	if err != nil {
+		t.Fatalf("failed to create lending state: %v", err)
+	}
+	open := newItem(1)
+	open.Quantity = big.NewInt(60)
+	lendingState.InsertLendingItem(book, common.BigToHash(big.NewInt(1)), *open)
+	lendingState.InsertTradingItem(book, 1, lendingstate.LendingTrade{TradeId: 1, Amount: big.NewInt(40), CollateralLockedAmount: big.NewInt(10), LiquidationPrice: big.NewInt(7)})
+	root, err := lendingState.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	l.lendingRoots.Add(chain.CurrentBlock().Hash(), root)
+
+	// the SDK database missed the trade of the items
+	db := l.GetMongoDB()
+	db.InitLendingBulk()
+	for _, id := range []uint64{1, 2} {
+		item := newItem(id)
+		item.Status, item.FilledAmount, item.TxHash, item.CreatedAt = lendingstate.LendingStatusOpen, new(big.Int), tx.Hash(), time.Unix(1, 0)
+		db.PutObject(item.Hash, item)
+	}
+	trade := &lendingstate.LendingTrade{TradeId: 1, LendingToken: usdt, Term: 86400, Borrower: user, Amount: big.NewInt(40), CollateralLockedAmount: big.NewInt(5), LiquidationPrice: big.NewInt(7),
+		Status: lendingstate.TradeStatusOpen, Hash: common.HexToHash("0x100"), TxHash: tx.Hash(), CreatedAt: time.Unix(1, 0)}
+	db.PutObject(trade.Hash, trade)
+	if err := db.CommitLendingBulk(); err != nil {
+		t.Fatalf("failed to commit bulk: %v", err)
+	}
+
+	from := rpc.BlockNumber(0)
+	result, err := l.auditSDKData(SDKAuditArgs{FromBlock: &from})
+	if err != nil {
+		t.Fatalf("failed to audit: %v", err)
+	}
+	if result.Items != 2 || result.Trades != 1 || len(result.Divergences) != 5 {
+		t.Fatalf("wrong audit: %+v", result)
+	}
+	want := map[string]string{
+		open.Hash.Hex() + "status":                  lendingstate.LendingStatusPartialFilled,
+		open.Hash.Hex() + "filledAmount":            "40",
+		newItem(2).Hash.Hex() + "status":            lendingstate.LendingStatusFilled,
+		newItem(2).Hash.Hex() + "filledAmount":      "100",
+		trade.Hash.Hex() + "collateralLockedAmount": "10",
+	}
+	for _, divergence := range result.Divergences {
+		if state, ok := want[divergence.Hash.Hex()+divergence.Field]; !ok || state != divergence.State || divergence.Repaired {
+			t.Errorf("wrong divergence: %+v", divergence)
+		}
+	}
+
+	if result, err = l.auditSDKData(SDKAuditArgs{FromBlock: &from, Repair: true}); err != nil || len(result.Divergences) != 5 || !result.Divergences[0].Repaired {
+		t.Fatalf("failed to repair: %+v, %v", result, err)
+	}
+	if result, err = l.auditSDKData(SDKAuditArgs{FromBlock: &from}); err != nil || len(result.Divergences) != 0 {
+		t.Fatalf("divergences left after the repair: %+v, %v", result, err)
+	}
+
+	l.tomox = tomox.New(&tomox.Config{DataDir: t.TempDir()})
+	if _, err := l.auditSDKData(SDKAuditArgs{}); err != errSDKAuditNotNode {
+		t.Fatalf("wrong error on a non-SDK node: %v", err)
+	}
+}
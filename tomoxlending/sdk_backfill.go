@@ -0,0 +1,191 @@
+package tomoxlending
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The SDK database misses the lending items and trades of the blocks processed while the node
+// didn't run as an SDK node. When a query by hash misses a record, it is rebuilt from the data
+// the node still has, cached to the SDK database and returned:
+//   - the lending history index left in leveldb, if the node ran with --tomox.lendingindex before,
+//   - for a limit item, the lending transactions of the last maxSDKBackfillRange blocks, along with
+//     the lending state of the current block telling whether it is still in its lending book,
+//   - for a trade, the lending books of the current block, which only hold the open trades.
+//
+// Records of the index are taken as of the last block indexed, tomoxlending_auditSDKData repairs
+// their later changes. The hashes which can't be backfilled are remembered, so that queries for unknown hashes don't
+// scan the chain again. A record synchronised later on is found in the SDK database first.
+
+const maxSDKBackfillRange = 10000 // Maximum number of recent blocks scanned to backfill a lending item
+
+var errLendingItemNotFound = errors.New("lending item not found")
+
+// getSDKLendingItem returns a lending item of the SDK database, backfilling it if missing.
+func (l *Lending) getSDKLendingItem(hash common.Hash) (*lendingstate.LendingItem, error) {
+	val, err := l.GetMongoReadDB().GetObject(hash, &lendingstate.LendingItem{})
+	if err == nil && val != nil {
+		return val.(*lendingstate.LendingItem), nil
+	}
+	if l.sdkBackfillMisses.Contains(hash) {
+		return nil, errLendingItemNotFound
+	}
+	item, err := l.backfillLendingItem(hash)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		l.sdkBackfillMisses.Add(hash, struct{}{})
+		return nil, errLendingItemNotFound
+	}
+	if err := l.repairSDKData([]interface{}{item}); err != nil {
+		log.Warn("Failed to cache backfilled lending item", "hash", hash.Hex(), "err", err)
+	}
+	return item, nil
+}
+
+// getSDKLendingTrade returns a lending trade of the SDK database, backfilling it if missing.
+func (l *Lending) getSDKLendingTrade(hash common.Hash) (*lendingstate.LendingTrade, error) {
+	val, err := l.GetMongoReadDB().GetObject(hash, &lendingstate.LendingTrade{})
+	if err == nil && val != nil {
+		return val.(*lendingstate.LendingTrade), nil
+	}
+	if l.sdkBackfillMisses.Contains(hash) {
+		return nil, errLendingTradeNotFound
+	}
+	trade, err := l.backfillLendingTrade(hash)
+	if err != nil {
+		return nil, err
+	}
+	if trade == nil {
+		l.sdkBackfillMisses.Add(hash, struct{}{})
+		return nil, errLendingTradeNotFound
+	}
+	if err := l.repairSDKData([]interface{}{trade}); err != nil {
+		log.Warn("Failed to cache backfilled lending trade", "hash", hash.Hex(), "err", err)
+	}
+	return trade, nil
+}
+
+// backfillLendingItem rebuilds a lending item missing from the SDK database, nil if not found.
+func (l *Lending) backfillLendingItem(hash common.Hash) (*lendingstate.LendingItem, error) {
+	if item := l.getIndexedItem(hash); item != nil {
+		return item, nil
+	}
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		head      = block.NumberU64()
+		cancelled = false
+	)
+	for number := head; number > 0 && head-number < maxSDKBackfillRange; number-- {
+		block := l.chain.GetBlockByNumber(number)
+		if block == nil {
+			continue
+		}
+		batches, err := core.ExtractLendingTransactions(block.Transactions())
+		if err != nil {
+			return nil, err
+		}
+		for _, batch := range batches {
+			for _, recorded := range batch.Data {
+				if recorded.Hash != hash || recorded.Type != lendingstate.Limit {
+					continue
+				}
+				// blocks are walked backward, so a cancellation comes before the creation
+				if recorded.Status == lendingstate.LendingStatusCancelled {
+					cancelled = true
+				}
+				if recorded.Status != lendingstate.LendingStatusNew {
+					continue
+				}
+				item := *recorded
+				item.Status = lendingstate.LendingStatusOpen
+				item.TxHash = batch.TxHash
+				item.CreatedAt = time.Unix(block.Time().Int64(), 0).UTC()
+				item.Status, item.FilledAmount = stateLendingItemStatus(lendingState, &item, new(big.Int), cancelled)
+				log.Info("Backfilled lending item of the SDK database", "hash", hash.Hex(), "block", number, "status", item.Status)
+				return &item, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// backfillLendingTrade rebuilds a lending trade missing from the SDK database, nil if not found.
+func (l *Lending) backfillLendingTrade(hash common.Hash) (*lendingstate.LendingTrade, error) {
+	if trade := l.getIndexedTrade(hash); trade != nil {
+		return trade, nil
+	}
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	trade, err := l.scanLendingTrade(block, lendingState, hash)
+	if err == errLendingTradeNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	trade.Status = lendingstate.TradeStatusOpen
+	log.Info("Backfilled lending trade of the SDK database", "hash", hash.Hex(), "block", block.NumberU64())
+	return trade, nil
+}
+
+// getLendingItem returns a lending item by hash, from the SDK database or the lending history index.
+func (l *Lending) getLendingItem(hash common.Hash) (*lendingstate.LendingItem, error) {
+	if l.tomox.IsSDKNode() {
+		return l.getSDKLendingItem(hash)
+	}
+	if !l.HasLendingIndex() {
+		return nil, errLendingHistoryUnavailable
+	}
+	if item := l.getIndexedItem(hash); item != nil {
+		return item, nil
+	}
+	return nil, errLendingItemNotFound
+}
+
+// isLendingHashUsed returns whether a lending item with the given hash has been created, from the
+// lending state since TIPTomoXLendingReplayProtection and from the SDK database or the lending
+// history index before. A rejected item counts, its nonce has been used.
+func (l *Lending) isLendingHashUsed(hash common.Hash) (bool, error) {
+	_, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return false, err
+	}
+	if lendingState.GetLendingHashConsumedAt(hash) > 0 {
+		return true, nil
+	}
+	switch _, err := l.getLendingItem(hash); err {
+	case nil:
+		return true, nil
+	case errLendingItemNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// getLendingTrade returns a lending trade by hash, from the SDK database or the lending history index.
+func (l *Lending) getLendingTrade(hash common.Hash) (*lendingstate.LendingTrade, error) {
+	if l.tomox.IsSDKNode() {
+		return l.getSDKLendingTrade(hash)
+	}
+	if !l.HasLendingIndex() {
+		return nil, errLendingHistoryUnavailable
+	}
+	if trade := l.getIndexedTrade(hash); trade != nil {
+		return trade, nil
+	}
+	return nil, errLendingTradeNotFound
+}
@@ -0,0 +1,115 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestSDKBackfill(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DevDB: true}))
+	defer l.GetMongoDB().Close()
+
+	var (
+		usdt = common.HexToAddress("0x10")
+		user = common.HexToAddress("0x1")
+		book = lendingstate.GetLendingOrderBookHash(usdt, 86400)
+	)
+	newItem := func(id uint64, status string) *lendingstate.LendingItem {
+		return &lendingstate.LendingItem{
+			Quantity:     big.NewInt(100),
+			Interest:     big.NewInt(10),
+			Side:         lendingstate.Borrowing,
+			Type:         lendingstate.Limit,
+			LendingToken: usdt,
+			Term:         86400,
+			Status:       status,
+			UserAddress:  user,
+			LendingId:    id,
+			Hash:         common.BigToHash(new(big.Int).SetUint64(id)),
+			Signature:    &lendingstate.Signature{},
+		}
+	}
+	newTx := func(items ...*lendingstate.LendingItem) *types.Transaction {
+		data, err := lendingstate.EncodeTxLendingBatch(lendingstate.TxLendingBatch{Data: items})
+		if err != nil {
+			t.Fatalf("failed to encode batch: %v", err)
+		}
+		return types.NewTransaction(0, common.HexToAddress(common.TomoXLendingAddress), new(big.Int), 0, new(big.Int), data)
+	}
+	// item 1 and 2 are placed in block 1, item 2 is cancelled in block 2
+	placed := newTx(newItem(1, lendingstate.LendingStatusNew), newItem(2, lendingstate.LendingStatusNew))
+	chain := &auditTestChain{}
+	chain.blocks = []*types.Block{
+		types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), Time: big.NewInt(0)}),
+		types.NewBlock(&types.Header{Number: big.NewInt(1), Time: big.NewInt(10)}, []*types.Transaction{placed}, nil, nil),
+		types.NewBlock(&types.Header{Number: big.NewInt(2), Time: big.NewInt(20)}, []*types.Transaction{newTx(newItem(2, lendingstate.LendingStatusCancelled))}, nil, nil),
+	}
+	l.chain = chain
+
+	// item 1 has 60 left in its book
+	lendingState, err := lendingstate.New(lendingstate.EmptyRoot, l.StateCache)
+	if err != nil {
+		t.Fatalf("failed to create lending state: %v", err)
+	}
+	open := newItem(1, lendingstate.LendingStatusOpen)
+	open.Quantity = big.NewInt(60)
+	lendingState.InsertLendingItem(book, common.BigToHash(big.NewInt(1)), *open)
+	root, err := lendingState.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	l.lendingRoots.Add(chain.CurrentBlock().Hash(), root)
+
+	// the trade was indexed while the node ran with --tomox.lendingindex
+	trade := &lendingstate.LendingTrade{TradeId: 1, LendingToken: usdt, Term: 86400, Borrower: user, Amount: big.NewInt(40),
+		Status: lendingstate.TradeStatusOpen, Hash: common.HexToHash("0x100"), CreatedAt: time.Unix(10, 0)}
+	batch := l.GetLevelDB().NewBatch()
+	if err := putIndexedTrade(batch, trade); err != nil {
+		t.Fatalf("failed to index trade: %v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write index: %v", err)
+	}
+
+	want := map[uint64]struct {
+		status string
+		filled int64
+	}{
+		1: {lendingstate.LendingStatusPartialFilled, 40},
+		2: {lendingstate.LendingStatusCancelled, 0},
+	}
+	for id, w := range want {
+		item, err := l.getLendingItem(newItem(id, "").Hash)
+		if err != nil {
+			t.Fatalf("item %d: failed to backfill: %v", id, err)
+		}
+		if item.Status != w.status || item.FilledAmount.Int64() != w.filled || item.TxHash != placed.Hash() || item.CreatedAt.Unix() != 10 {
+			t.Errorf("item %d: wrong backfilled item %v", id, lendingstate.ToJSON(item))
+		}
+		// the backfilled item is cached to the SDK database
+		if val, err := l.GetMongoReadDB().GetObject(item.Hash, &lendingstate.LendingItem{}); err != nil || val == nil || val.(*lendingstate.LendingItem).Status != w.status {
+			t.Errorf("item %d: backfilled item not cached: %v, %v", id, val, err)
+		}
+	}
+	backfilled, err := l.getLendingTrade(trade.Hash)
+	if err != nil || backfilled.TradeId != 1 || backfilled.Amount.Int64() != 40 {
+		t.Fatalf("failed to backfill the indexed trade: %v, %v", backfilled, err)
+	}
+	if has, _ := l.GetMongoReadDB().HasObject(trade.Hash, &lendingstate.LendingTrade{}); !has {
+		t.Error("backfilled trade not cached")
+	}
+
+	unknown := common.HexToHash("0xdead")
+	if _, err := l.getLendingItem(unknown); err != errLendingItemNotFound {
+		t.Fatalf("wrong error for an unknown item: %v", err)
+	}
+	if !l.sdkBackfillMisses.Contains(unknown) {
+		t.Error("unknown item not remembered")
+	}
+}
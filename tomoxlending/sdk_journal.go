@@ -0,0 +1,154 @@
+package tomoxlending
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+)
+
+// The lending transactions recorded to the SDK database by each block are journaled into the
+// tomox leveldb, along with the reorg history of the records they update (see history.go), for the
+// last tomox.Config.SDKJournalDepth blocks. The records of any of these blocks can be rolled back,
+// whatever the depth of the reorg and whether the node restarted in between, and the SDK database
+// can be rewound to one of these blocks with 'tomo tomox rollback'.
+var sdkJournalPrefix = []byte("lendingSDKJournal-") // sdkJournalPrefix + number (uint64 big endian) -> sdkJournalEntry
+
+const defaultSDKJournalDepth = 4500 // Default number of recent blocks whose SDK records can be rolled back
+
+// sdkJournalEntry is the journal of the lending transactions recorded to the SDK database by a block.
+type sdkJournalEntry struct {
+	Hash     common.Hash   `json:"hash"`
+	Time     uint64        `json:"time"`
+	TxHashes []common.Hash `json:"txHashes"`
+}
+
+func sdkJournalKey(number uint64) []byte {
+	key := make([]byte, len(sdkJournalPrefix)+8)
+	copy(key, sdkJournalPrefix)
+	binary.BigEndian.PutUint64(key[len(sdkJournalPrefix):], number)
+	return key
+}
+
+// sdkJournalDepth returns the number of recent blocks journaled.
+func (l *Lending) sdkJournalDepth() uint64 {
+	if depth := l.tomox.SDKJournalDepth(); depth > 0 {
+		return depth
+	}
+	return defaultSDKJournalDepth
+}
+
+// JournalLendingData records the lending transactions of a block written to the SDK database, in
+// the order they were recorded. It replaces the journal of the block previously recorded at the
+// same height, and prunes the journal and the reorg history of the blocks out of the depth.
+func (l *Lending) JournalLendingData(block *types.Block, txHashes []common.Hash) error {
+	if len(txHashes) == 0 {
+		return l.GetLevelDB().Delete(sdkJournalKey(block.NumberU64()))
+	}
+	data, err := json.Marshal(sdkJournalEntry{Hash: block.Hash(), Time: block.Time().Uint64(), TxHashes: txHashes})
+	if err != nil {
+		return err
+	}
+	if err := l.GetLevelDB().Put(sdkJournalKey(block.NumberU64()), data); err != nil {
+		return err
+	}
+	if depth := l.sdkJournalDepth(); block.NumberU64() >= depth {
+		return l.pruneSDKJournal(block.NumberU64() - depth + 1)
+	}
+	return nil
+}
+
+// pruneSDKJournal removes the journal and the reorg history of the blocks below the given number.
+func (l *Lending) pruneSDKJournal(before uint64) error {
+	db := l.GetLevelDB()
+	it := db.NewIterator(sdkJournalPrefix, nil)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(sdkJournalPrefix)+8 {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(sdkJournalPrefix):]) >= before {
+			break
+		}
+		var entry sdkJournalEntry
+		if err := json.Unmarshal(it.Value(), &entry); err == nil {
+			txTime := time.Unix(int64(entry.Time), 0).UTC()
+			for _, txHash := range entry.TxHashes {
+				batch.Delete(lendingHistoryKey(txHash))
+				batch.Delete(lendingHistoryIndexKey(txTime, txHash))
+			}
+		}
+		batch.Delete(common.CopyBytes(key))
+	}
+	return batch.Write()
+}
+
+// oldestSDKJournalTime returns the time of the oldest block journaled, false if none is.
+func (l *Lending) oldestSDKJournalTime() (time.Time, bool) {
+	it := l.GetLevelDB().NewIterator(sdkJournalPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		var entry sdkJournalEntry
+		if err := json.Unmarshal(it.Value(), &entry); err == nil {
+			return time.Unix(int64(entry.Time), 0).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// RollbackSDKData rewinds the SDK lending records to the given block: the lending transactions
+// journaled by the blocks above it are rolled back, the latest first, and their journal is removed.
+// It returns the number of blocks rolled back. The records of the canonical blocks above the given
+// one have to be recorded again, with 'tomo tomox reindex'.
+func (l *Lending) RollbackSDKData(to uint64) (int, error) {
+	if !l.tomox.IsSDKNode() {
+		return 0, errSDKAuditNotNode
+	}
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, to+1)
+	db := l.GetLevelDB()
+	it := db.NewIterator(sdkJournalPrefix, start)
+	var (
+		numbers []uint64
+		entries []sdkJournalEntry
+	)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(sdkJournalPrefix)+8 {
+			continue
+		}
+		var entry sdkJournalEntry
+		if err := json.Unmarshal(it.Value(), &entry); err != nil {
+			it.Release()
+			return 0, fmt.Errorf("failed to decode the SDK journal of block #%d: %v", binary.BigEndian.Uint64(key[len(sdkJournalPrefix):]), err)
+		}
+		numbers = append(numbers, binary.BigEndian.Uint64(key[len(sdkJournalPrefix):]))
+		entries = append(entries, entry)
+	}
+	it.Release()
+
+	l.sdkSyncLock.Lock()
+	defer l.sdkSyncLock.Unlock()
+	for i := len(entries) - 1; i >= 0; i-- {
+		for j := len(entries[i].TxHashes) - 1; j >= 0; j-- {
+			txHash := entries[i].TxHashes[j]
+			if err := l.rollbackLendingData(context.Background(), txHash); err != nil {
+				return len(entries) - 1 - i, fmt.Errorf("failed to roll back block #%d: %v", numbers[i], err)
+			}
+		}
+		if err := db.Delete(sdkJournalKey(numbers[i])); err != nil {
+			return len(entries) - 1 - i, err
+		}
+		log.Info("Rolled back SDK lending data", "number", numbers[i], "hash", entries[i].Hash.Hex(), "txs", len(entries[i].TxHashes))
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,91 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestRollbackSDKData(t *testing.T) {
+	tomoX := tomox.New(&tomox.Config{DataDir: t.TempDir(), DBEngine: "badger", SDKJournalDepth: 2})
+	defer tomoX.GetMongoDB().Close()
+	l := New(tomoX)
+
+	var (
+		usdt  = common.HexToAddress("0x10")
+		hash  = common.HexToHash("0x1")
+		txA   = common.HexToHash("0xa")
+		txB   = common.HexToHash("0xb")
+		key   = lendingstate.GetLendingItemHistoryKey(usdt, common.Address{}, hash)
+		block = func(number int64) *types.Block {
+			return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Time: big.NewInt(number)})
+		}
+	)
+	record := func(txHash common.Hash, status string, filled int64, number int64) {
+		db := l.GetMongoDB()
+		db.InitLendingBulk()
+		item := &lendingstate.LendingItem{Hash: hash, LendingToken: usdt, Term: 86400, Type: lendingstate.Limit, Quantity: big.NewInt(100),
+			Status: status, FilledAmount: big.NewInt(filled), TxHash: txHash, UpdatedAt: time.Unix(number, 0).UTC()}
+		if err := db.PutObject(hash, item); err != nil {
+			t.Fatalf("failed to put item: %v", err)
+		}
+		if err := db.CommitLendingBulk(); err != nil {
+			t.Fatalf("failed to commit bulk: %v", err)
+		}
+		l.saveLendingHistory(txHash, time.Unix(number, 0).UTC())
+		if err := l.JournalLendingData(block(number), []common.Hash{txHash}); err != nil {
+			t.Fatalf("failed to journal block %d: %v", number, err)
+		}
+	}
+	// the item is placed in block 1 and filled in block 2
+	l.UpdateLendingItemCache(usdt, common.Address{}, hash, txA, lendingstate.LendingItemHistoryItem{})
+	record(txA, lendingstate.LendingStatusOpen, 0, 1)
+	l.UpdateLendingItemCache(usdt, common.Address{}, hash, txB, lendingstate.LendingItemHistoryItem{TxHash: txA, Status: lendingstate.LendingStatusOpen, FilledAmount: new(big.Int), UpdatedAt: time.Unix(1, 0).UTC()})
+	record(txB, lendingstate.LendingStatusFilled, 100, 2)
+	if history, ok := l.getLendingItemHistory(txB); !ok || history[key].TxHash != txA {
+		t.Fatalf("wrong history of block 2: %v", history)
+	}
+
+	// the node restarts, the records are rolled back from the journal
+	l = New(tomoX)
+	if n, err := l.RollbackSDKData(1); err != nil || n != 1 {
+		t.Fatalf("failed to roll back to block 1: %d blocks, %v", n, err)
+	}
+	val, err := l.GetMongoDB().GetObject(hash, &lendingstate.LendingItem{})
+	if err != nil || val == nil {
+		t.Fatalf("item removed by the rollback to block 1: %v", err)
+	}
+	if item := val.(*lendingstate.LendingItem); item.Status != lendingstate.LendingStatusOpen || item.FilledAmount.Sign() != 0 || item.TxHash != txA {
+		t.Errorf("wrong item after the rollback to block 1: %v", lendingstate.ToJSON(item))
+	}
+	if n, err := l.RollbackSDKData(1); err != nil || n != 0 {
+		t.Errorf("block 2 rolled back twice: %d blocks, %v", n, err)
+	}
+	if n, err := l.RollbackSDKData(0); err != nil || n != 1 {
+		t.Fatalf("failed to roll back to block 0: %d blocks, %v", n, err)
+	}
+	if has, _ := l.GetMongoDB().HasObject(hash, &lendingstate.LendingItem{}); has {
+		t.Error("item placed in block 1 not removed")
+	}
+
+	// blocks out of the depth are pruned along with their history, an empty block clears its height
+	record(txA, lendingstate.LendingStatusOpen, 0, 1)
+	record(txB, lendingstate.LendingStatusFilled, 100, 3)
+	if history := l.loadLendingHistory(txA); history != nil {
+		t.Errorf("history of block 1 not pruned: %v", history)
+	}
+	if _, err := l.GetLevelDB().Get(sdkJournalKey(1)); err == nil {
+		t.Error("journal of block 1 not pruned")
+	}
+	if err := l.JournalLendingData(block(3), nil); err != nil {
+		t.Fatalf("failed to journal an empty block: %v", err)
+	}
+	if _, err := l.GetLevelDB().Get(sdkJournalKey(3)); err == nil {
+		t.Error("journal of a replaced block not removed")
+	}
+}
@@ -0,0 +1,170 @@
+package tomoxlending
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+const (
+	// maxSDKSyncQueue is the maximum number of transactions waiting to be replayed to the SDK database.
+	maxSDKSyncQueue = 4096
+	// sdkSyncRetryInterval is the interval between two replays of the queued transactions.
+	sdkSyncRetryInterval = 3 * time.Second
+)
+
+var errSDKSyncPending = errors.New("earlier lending data is waiting to be recorded")
+
+// sdkSyncItem holds the arguments of one SyncDataToSDKNode call.
+type sdkSyncItem struct {
+	chain       consensus.ChainContext
+	statedb     *state.StateDB
+	block       *types.Block
+	item        lendingstate.LendingItem
+	txMatchTime time.Time
+	trades      []*lendingstate.LendingTrade
+	rejected    []*lendingstate.LendingItem
+}
+
+// sdkSyncTask holds the lending items of a transaction recorded to the SDK database.
+type sdkSyncTask struct {
+	txHash common.Hash
+	items  []sdkSyncItem
+}
+
+// SyncDataToSDKNode records a processed lending item, its trades and rejected items to the SDK database.
+//
+// The round-trips to the SDK database have the deadline configured by tomox.Config.SDKTimeout, so a
+// stalled SDK database doesn't stall the block import. If the SDK database fails or the deadline
+// expires, the whole transaction is queued and a *lendingstate.SDKSyncError with Queued set is
+// returned. Queued transactions are replayed in order in the background, and later data is queued
+// behind them: the data already recorded for a transaction is rolled back first, so a replay is
+// idempotent.
+func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state.StateDB, block *types.Block, takerLendingItem *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedItems []*lendingstate.LendingItem, dirtyOrderCount *uint64) error {
+	l.sdkSyncLock.Lock()
+	defer l.sdkSyncLock.Unlock()
+
+	if l.sdkSyncCurrent == nil || l.sdkSyncCurrent.txHash != txHash {
+		l.sdkSyncCurrent = &sdkSyncTask{txHash: txHash}
+	}
+	task := l.sdkSyncCurrent
+	task.items = append(task.items, sdkSyncItem{
+		chain:       chain,
+		statedb:     statedb,
+		block:       block,
+		item:        *takerLendingItem,
+		txMatchTime: txMatchTime,
+		trades:      trades,
+		rejected:    rejectedItems,
+	})
+
+	if len(l.sdkSyncQueue) > 0 {
+		if l.sdkSyncQueue[len(l.sdkSyncQueue)-1] == task {
+			// the transaction is already queued, keep its items together
+			return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKCommitBulk, TxHash: txHash, Err: errSDKSyncPending, Queued: true}
+		}
+		return l.queueSDKSyncTask(task, &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKCommitBulk, TxHash: txHash, Err: errSDKSyncPending})
+	}
+	ctx, cancel := l.sdkSyncContext()
+	defer cancel()
+	if err := l.syncDataToSDKNode(ctx, chain, statedb, block, takerLendingItem, txHash, txMatchTime, trades, rejectedItems, dirtyOrderCount); err != nil {
+		return l.queueSDKSyncTask(task, err)
+	}
+	return nil
+}
+
+// sdkSyncContext returns the context of the round-trips to the SDK database recording one lending item.
+func (l *Lending) sdkSyncContext() (context.Context, context.CancelFunc) {
+	if timeout := l.tomox.SDKTimeout(); timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// queueSDKSyncTask queues a transaction which failed to be recorded because of the SDK database.
+// Other errors are returned as they are.
+func (l *Lending) queueSDKSyncTask(task *sdkSyncTask, err error) error {
+	var syncErr *lendingstate.SDKSyncError
+	if !errors.As(err, &syncErr) || len(l.sdkSyncQueue) >= maxSDKSyncQueue {
+		return err
+	}
+	if len(l.sdkSyncQueue) == 0 || l.sdkSyncQueue[len(l.sdkSyncQueue)-1] != task {
+		l.sdkSyncQueue = append(l.sdkSyncQueue, task)
+	}
+	log.Warn("Queued lending data for SDK node", "txhash", task.txHash.Hex(), "queued", len(l.sdkSyncQueue), "err", err)
+	syncErr.Queued = true
+	return syncErr
+}
+
+// sdkSyncLoop replays the queued transactions to the SDK database until the service stops.
+func (l *Lending) sdkSyncLoop() {
+	ticker := time.NewTicker(sdkSyncRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.replaySDKSyncQueue(); err != nil {
+				log.Warn("Failed to replay lending data to SDK node", "err", err)
+			}
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// replaySDKSyncQueue replays the queued transactions in order, stopping at the first failure. The
+// lock is only held while a transaction is replayed, so the block import records its data in
+// between.
+func (l *Lending) replaySDKSyncQueue() error {
+	for {
+		select {
+		case <-l.quit:
+			return nil
+		default:
+		}
+		l.sdkSyncLock.Lock()
+		if len(l.sdkSyncQueue) == 0 {
+			l.sdkSyncLock.Unlock()
+			return nil
+		}
+		task := l.sdkSyncQueue[0]
+		err := l.replaySDKSyncTask(task)
+		if err == nil {
+			l.sdkSyncQueue = l.sdkSyncQueue[1:]
+			log.Info("Replayed lending data to SDK node", "txhash", task.txHash.Hex(), "items", len(task.items), "queued", len(l.sdkSyncQueue))
+		}
+		l.sdkSyncLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// replaySDKSyncTask rolls back the data recorded for a queued transaction and records its items again.
+func (l *Lending) replaySDKSyncTask(task *sdkSyncTask) error {
+	ctx, cancel := l.sdkSyncContext()
+	err := l.rollbackLendingData(ctx, task.txHash)
+	cancel()
+	if err != nil {
+		return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKCommitBulk, TxHash: task.txHash, Err: err}
+	}
+	dirtyOrderCount := uint64(0)
+	for _, item := range task.items {
+		takerLendingItem := item.item
+		ctx, cancel := l.sdkSyncContext()
+		err := l.syncDataToSDKNode(ctx, item.chain, item.statedb, item.block, &takerLendingItem, task.txHash, item.txMatchTime, item.trades, item.rejected, &dirtyOrderCount)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
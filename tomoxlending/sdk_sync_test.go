@@ -0,0 +1,76 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func newSDKSyncTestItem(id uint64) *lendingstate.LendingItem {
+	return &lendingstate.LendingItem{
+		Quantity:     big.NewInt(100),
+		Interest:     big.NewInt(10),
+		Side:         lendingstate.Investing,
+		Type:         lendingstate.Limit,
+		LendingToken: common.HexToAddress("0x10"),
+		Term:         86400,
+		Status:       lendingstate.LendingStatusOpen,
+		UserAddress:  common.HexToAddress("0x1"),
+		Hash:         common.BigToHash(new(big.Int).SetUint64(id)),
+	}
+}
+
+func TestSDKSyncTimeout(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir(), DBEngine: "badger", SDKTimeout: time.Nanosecond}))
+	defer l.GetMongoDB().Close()
+
+	item := newSDKSyncTestItem(1)
+	var dirty uint64
+	err := l.SyncDataToSDKNode(nil, nil, nil, item, common.HexToHash("0x1"), time.Now(), nil, nil, &dirty)
+	if !lendingstate.IsQueuedSDKSyncError(err) {
+		t.Fatalf("expected a queued error, got %v", err)
+	}
+	if len(l.sdkSyncQueue) != 1 {
+		t.Fatalf("queue length mismatch: have %d, want 1", len(l.sdkSyncQueue))
+	}
+	if val, _ := l.GetMongoDB().GetObject(item.Hash, &lendingstate.LendingItem{}); val != nil {
+		t.Fatalf("item recorded despite the expired deadline")
+	}
+}
+
+func TestSDKSyncReplay(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir(), DBEngine: "badger"}))
+	defer l.GetMongoDB().Close()
+
+	// a transaction failed to be recorded earlier
+	first := newSDKSyncTestItem(1)
+	l.sdkSyncQueue = []*sdkSyncTask{{
+		txHash: common.HexToHash("0x1"),
+		items:  []sdkSyncItem{{item: *first, txMatchTime: time.Now()}},
+	}}
+	// later transactions wait for it instead of blocking the import
+	second := newSDKSyncTestItem(2)
+	var dirty uint64
+	err := l.SyncDataToSDKNode(nil, nil, nil, second, common.HexToHash("0x2"), time.Now(), nil, nil, &dirty)
+	if !lendingstate.IsQueuedSDKSyncError(err) {
+		t.Fatalf("expected a queued error, got %v", err)
+	}
+	if val, _ := l.GetMongoDB().GetObject(second.Hash, &lendingstate.LendingItem{}); val != nil {
+		t.Fatalf("item recorded ahead of the queue")
+	}
+	if err := l.replaySDKSyncQueue(); err != nil {
+		t.Fatalf("failed to replay queue: %v", err)
+	}
+	if len(l.sdkSyncQueue) != 0 {
+		t.Fatalf("queue length mismatch: have %d, want 0", len(l.sdkSyncQueue))
+	}
+	for _, item := range []*lendingstate.LendingItem{first, second} {
+		if val, err := l.GetMongoDB().GetObject(item.Hash, &lendingstate.LendingItem{}); err != nil || val == nil {
+			t.Fatalf("item %x not recorded: %v", item.Hash, err)
+		}
+	}
+}
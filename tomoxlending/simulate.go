@@ -0,0 +1,122 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// SimulatedLendingItem is the expected outcome of a lending item matched in the next block: the
+// trades it would open, their amount weighted interest and fees, and the position of its user
+// afterwards. RejectReason is set if the item would be rejected.
+type SimulatedLendingItem struct {
+	BlockNumber       uint64                       `json:"blockNumber"`
+	Hash              common.Hash                  `json:"hash"`
+	RejectReason      string                       `json:"rejectReason,omitempty"`
+	Trades            []*lendingstate.LendingTrade `json:"trades"`
+	FilledQuantity    *big.Int                     `json:"filledQuantity"`
+	RestingQuantity   *big.Int                     `json:"restingQuantity"` // left in the lending book
+	AverageInterest   *big.Int                     `json:"averageInterest"`
+	BorrowingFee      *big.Int                     `json:"borrowingFee"`
+	InvestingFee      *big.Int                     `json:"investingFee"`
+	CollateralLocked  *big.Int                     `json:"collateralLocked"`
+	LendingBalance    *big.Int                     `json:"lendingBalance"`              // lending token balance of the user after matching
+	CollateralBalance *big.Int                     `json:"collateralBalance,omitempty"` // collateral balance of the user after matching
+}
+
+// summarizeTrades sums the trades opened by a lending item into its simulation.
+func (s *SimulatedLendingItem) summarizeTrades(itemHash common.Hash, trades []*lendingstate.LendingTrade) {
+	s.Trades = []*lendingstate.LendingTrade{}
+	s.FilledQuantity, s.AverageInterest = new(big.Int), new(big.Int)
+	s.BorrowingFee, s.InvestingFee, s.CollateralLocked = new(big.Int), new(big.Int), new(big.Int)
+	weightedInterest := new(big.Int)
+	for _, trade := range trades {
+		// Repay and TopUp items return the trade they settle, which isn't a fill of the item
+		if trade == nil || trade.Amount == nil || (trade.BorrowingOrderHash != itemHash && trade.InvestingOrderHash != itemHash) {
+			continue
+		}
+		s.Trades = append(s.Trades, trade)
+		s.FilledQuantity.Add(s.FilledQuantity, trade.Amount)
+		weightedInterest.Add(weightedInterest, new(big.Int).Mul(trade.Amount, new(big.Int).SetUint64(trade.Interest)))
+		if trade.BorrowingFee != nil {
+			s.BorrowingFee.Add(s.BorrowingFee, trade.BorrowingFee)
+		}
+		if trade.InvestingFee != nil {
+			s.InvestingFee.Add(s.InvestingFee, trade.InvestingFee)
+		}
+		if trade.CollateralLockedAmount != nil {
+			s.CollateralLocked.Add(s.CollateralLocked, trade.CollateralLockedAmount)
+		}
+	}
+	if s.FilledQuantity.Sign() > 0 {
+		s.AverageInterest.Div(weightedInterest, s.FilledQuantity)
+	}
+}
+
+// simulateLendingItem matches a lending item in a block on top of copies of the given states, which
+// are left untouched. The item takes the next nonce of its user and doesn't need to be signed.
+func (l *Lending) simulateLendingItem(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingState *lendingstate.LendingStateDB, tradingState *tradingstate.TradingStateDB, item *lendingstate.LendingItem) (*SimulatedLendingItem, error) {
+	statedb, lendingState, tradingState = statedb.Copy(), lendingState.Copy(), tradingState.Copy()
+
+	item.Nonce = new(big.Int).SetUint64(lendingState.GetNonce(item.UserAddress.Hash()))
+	item.Hash = item.ComputeHash(chain.Config().IsTIPTomoXLendingV2(header.Number))
+	lendingBook := lendingstate.GetLendingOrderBookHash(item.LendingToken, item.Term)
+	trades, rejects, err := l.CommitOrder(header, coinbase, chain, statedb, lendingState, tradingState, lendingBook, item)
+	if err != nil {
+		return nil, err
+	}
+	simulation := &SimulatedLendingItem{BlockNumber: header.Number.Uint64(), Hash: item.Hash, RestingQuantity: new(big.Int)}
+	for _, reject := range rejects {
+		if reject.Hash == item.Hash {
+			simulation.RejectReason = reject.RejectReason
+		}
+	}
+	simulation.summarizeTrades(item.Hash, trades)
+	if simulation.RejectReason == "" && item.LendingId != 0 {
+		if resting := lendingState.GetLendingOrder(lendingBook, common.Uint64ToHash(item.LendingId)); resting.Quantity != nil {
+			simulation.RestingQuantity = resting.Quantity
+		}
+	}
+	simulation.LendingBalance = lendingstate.GetTokenBalance(item.UserAddress, item.LendingToken, statedb)
+	if item.CollateralToken != (common.Address{}) {
+		simulation.CollateralBalance = lendingstate.GetTokenBalance(item.UserAddress, item.CollateralToken, statedb)
+	}
+	return simulation, nil
+}
+
+// simulateOrder matches a lending item against the lending books of the current block as the next
+// block would, without committing anything.
+func (l *Lending) simulateOrder(item *lendingstate.LendingItem) (*SimulatedLendingItem, error) {
+	block, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := l.tomox.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	var period uint64 = 1
+	if config := l.chain.Config(); config.Posv != nil && config.Posv.Period > 0 {
+		period = config.Posv.Period
+	}
+	header := &types.Header{
+		ParentHash: block.Hash(),
+		Number:     new(big.Int).Add(block.Number(), common.Big1),
+		Time:       new(big.Int).Add(block.Time(), new(big.Int).SetUint64(period)),
+		Coinbase:   author,
+	}
+	return l.simulateLendingItem(header, author, l.chain, statedb, lendingState, tradingState, item)
+}
@@ -0,0 +1,75 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestSummarizeSimulatedTrades(t *testing.T) {
+	var (
+		itemHash = common.HexToHash("0x1")
+		other    = common.HexToHash("0x2")
+	)
+	trades := []*lendingstate.LendingTrade{
+		{BorrowingOrderHash: itemHash, InvestingOrderHash: other, Amount: big.NewInt(300), Interest: 10, BorrowingFee: big.NewInt(3), CollateralLockedAmount: big.NewInt(450)},
+		{BorrowingOrderHash: itemHash, InvestingOrderHash: other, Amount: big.NewInt(100), Interest: 20, BorrowingFee: big.NewInt(1), CollateralLockedAmount: big.NewInt(150)},
+		// the trade settled by a Repay or TopUp item isn't a fill
+		{BorrowingOrderHash: other, Amount: big.NewInt(1000), Interest: 50},
+		nil,
+	}
+	simulation := &SimulatedLendingItem{}
+	simulation.summarizeTrades(itemHash, trades)
+	if len(simulation.Trades) != 2 || simulation.FilledQuantity.Int64() != 400 || simulation.CollateralLocked.Int64() != 600 {
+		t.Fatalf("wrong fills: %v", lendingstate.ToJSON(simulation))
+	}
+	// (300 * 10 + 100 * 20) / 400
+	if simulation.AverageInterest.Int64() != 12 || simulation.BorrowingFee.Int64() != 4 || simulation.InvestingFee.Sign() != 0 {
+		t.Errorf("wrong interest or fees: %v", lendingstate.ToJSON(simulation))
+	}
+	simulation.summarizeTrades(itemHash, nil)
+	if len(simulation.Trades) != 0 || simulation.FilledQuantity.Sign() != 0 || simulation.AverageInterest.Sign() != 0 {
+		t.Errorf("wrong empty simulation: %v", lendingstate.ToJSON(simulation))
+	}
+}
+
+func TestSimulateLendingItemLeavesState(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	user := common.HexToAddress("0x41")
+	lendingStateDB.SetNonce(user.Hash(), 3)
+	item := &lendingstate.LendingItem{
+		Quantity:     big.NewInt(100),
+		Interest:     big.NewInt(10),
+		Side:         lendingstate.Investing,
+		Type:         lendingstate.Limit,
+		Status:       lendingstate.LendingStatusNew,
+		LendingToken: common.HexToAddress(common.TomoNativeAddress),
+		Term:         86400,
+		Relayer:      common.HexToAddress("0x21"),
+		UserAddress:  user,
+	}
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+	// no relayer registers the lending pair
+	simulation, err := l.simulateLendingItem(header, common.Address{}, &epochTestChain{}, statedb, lendingStateDB, tradingStateDB, item)
+	if err != nil {
+		t.Fatalf("failed to simulate: %v", err)
+	}
+	if simulation.RejectReason != lendingstate.RejectReasonInvalidPair || len(simulation.Trades) != 0 || item.Nonce.Uint64() != 3 {
+		t.Errorf("wrong simulation: %v", lendingstate.ToJSON(simulation))
+	}
+	if nonce := lendingStateDB.GetNonce(user.Hash()); nonce != 3 {
+		t.Errorf("simulation changed the nonce of the user: %d", nonce)
+	}
+}
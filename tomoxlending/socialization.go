@@ -0,0 +1,156 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The shortfall of a liquidation auction left once the insurance fund is empty is socialized across
+// the investors of the open trades of the lending book (see lendingstate/socialization.go):
+//   - each open trade is charged a haircut pro rata of its amount, rounded down, the rounding and
+//     the shortfall over the total amount of the open trades stay uncovered
+//   - the investor of the auction gets a claim of the charged total
+//   - the haircut of a trade is taken from its next repayments and rollover to its investor and pays
+//     the claims of the lending book, the oldest auction first. A trade which is liquidated forfeits
+//     its haircut.
+//
+// The haircuts are recorded in the ExtraData of the auction, SDK nodes record a LendingInsuranceRecord
+// for each of them.
+
+// socializeShortfall charges the shortfall of a liquidation auction of a lending book the insurance
+// fund couldn't cover to the open trades of the book and gives the investor of the auction a claim
+// on the haircuts. It returns the haircuts and their total.
+func socializeShortfall(lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash, auction *lendingstate.LendingTrade, uncovered *big.Int) ([]lendingstate.TradeHaircut, *big.Int, error) {
+	socialized := new(big.Int)
+	if uncovered.Sign() <= 0 || !lendingStateDB.Exist(lendingBook) {
+		return nil, socialized, nil
+	}
+	dump, err := lendingStateDB.DumpLendingTradeTrie(lendingBook)
+	if err != nil {
+		return nil, nil, err
+	}
+	trades := []lendingstate.LendingTrade{}
+	total := new(big.Int)
+	for _, trade := range dump {
+		if trade.Amount == nil || trade.Amount.Sign() <= 0 {
+			continue
+		}
+		trades = append(trades, trade)
+		total.Add(total, trade.Amount)
+	}
+	if total.Sign() == 0 {
+		return nil, socialized, nil
+	}
+	sort.Slice(trades, func(i, j int) bool {
+		return trades[i].TradeId < trades[j].TradeId
+	})
+	charged := lendingstate.CloneBigInt(uncovered)
+	if charged.Cmp(total) > 0 {
+		charged = total
+	}
+	haircuts := []lendingstate.TradeHaircut{}
+	for _, trade := range trades {
+		// haircut = charged * amount / total
+		amount := new(big.Int).Div(new(big.Int).Mul(charged, trade.Amount), total)
+		if amount.Sign() == 0 {
+			continue
+		}
+		haircut := lendingstate.TradeHaircut{TradeId: trade.TradeId, Hash: trade.Hash, Investor: trade.Investor, Amount: amount}
+		lendingStateDB.AddTradeHaircut(lendingBook, haircut)
+		haircuts = append(haircuts, haircut)
+		socialized.Add(socialized, amount)
+	}
+	if socialized.Sign() > 0 {
+		lendingStateDB.AddBadDebtClaim(lendingBook, lendingstate.BadDebtClaim{TradeId: auction.TradeId, Hash: auction.Hash, Investor: auction.Investor, Amount: socialized})
+		lendingStateDB.SocializeInsuranceUncovered(auction.LendingToken, socialized)
+	}
+	return haircuts, socialized, nil
+}
+
+// resolveShortfall draws the shortfall of a liquidation auction of a lending book from the
+// insurance fund and socializes the part the fund couldn't cover. It returns the amount drawn,
+// the haircuts and their total.
+func resolveShortfall(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash, auction *lendingstate.LendingTrade, shortfall *big.Int) (*big.Int, []lendingstate.TradeHaircut, *big.Int, error) {
+	drawn, err := coverShortfall(lendingStateDB, statedb, auction, shortfall)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	haircuts, socialized, err := socializeShortfall(lendingStateDB, lendingBook, auction, new(big.Int).Sub(shortfall, drawn))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return drawn, haircuts, socialized, nil
+}
+
+// payHaircut takes the haircut of a lending trade, up to the given payment the investor of the
+// trade has just received, and pays the claims of the lending book with it. It returns the amount
+// taken from the investor.
+func payHaircut(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash, trade *lendingstate.LendingTrade, payment *big.Int) (*big.Int, error) {
+	haircut := lendingStateDB.GetTradeHaircut(lendingBook, trade.TradeId)
+	if haircut.Sign() == 0 {
+		return haircut, nil
+	}
+	taken := lendingstate.CloneBigInt(haircut)
+	if taken.Cmp(payment) > 0 {
+		taken = lendingstate.CloneBigInt(payment)
+	}
+	rest := lendingstate.CloneBigInt(taken)
+	for _, claim := range lendingStateDB.GetBadDebtClaims(lendingBook) {
+		if rest.Sign() == 0 {
+			break
+		}
+		paid := lendingstate.CloneBigInt(claim.Amount)
+		if paid.Cmp(rest) > 0 {
+			paid = lendingstate.CloneBigInt(rest)
+		}
+		if err := lendingstate.SubTokenBalance(trade.Investor, paid, trade.LendingToken, statedb); err != nil {
+			return nil, err
+		}
+		if err := lendingstate.AddTokenBalance(claim.Investor, paid, trade.LendingToken, statedb); err != nil {
+			return nil, err
+		}
+		lendingStateDB.SetBadDebtClaim(lendingBook, claim.TradeId, new(big.Int).Sub(claim.Amount, paid))
+		rest.Sub(rest, paid)
+	}
+	if rest.Sign() > 0 {
+		// the claims are paid, the haircut is dropped
+		taken.Sub(taken, rest)
+		lendingStateDB.SetTradeHaircut(lendingBook, trade.TradeId, new(big.Int))
+		return taken, nil
+	}
+	lendingStateDB.SetTradeHaircut(lendingBook, trade.TradeId, new(big.Int).Sub(haircut, taken))
+	return taken, nil
+}
+
+// forfeitHaircut drops the haircut of a liquidated lending trade.
+func forfeitHaircut(lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash, lendingTradeId uint64) {
+	if lendingStateDB.GetTradeHaircut(lendingBook, lendingTradeId).Sign() > 0 {
+		lendingStateDB.SetTradeHaircut(lendingBook, lendingTradeId, new(big.Int))
+	}
+}
+
+// BadDebt is the RPC representation of the socialized shortfalls of a lending book: the haircuts
+// of its open trades and the claims of the investors of its liquidation auctions on them.
+type BadDebt struct {
+	LendingBook common.Hash                 `json:"lendingBook"`
+	Haircuts    []lendingstate.TradeHaircut `json:"haircuts"`
+	Claims      []lendingstate.BadDebtClaim `json:"claims"`
+}
+
+// badDebt returns the haircuts and claims of a lending book at the current block.
+func (l *Lending) badDebt(lendingToken common.Address, term uint64) (*BadDebt, error) {
+	_, lendingState, err := l.currentLendingState()
+	if err != nil {
+		return nil, err
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	return &BadDebt{
+		LendingBook: lendingBook,
+		Haircuts:    lendingState.GetTradeHaircuts(lendingBook),
+		Claims:      lendingState.GetBadDebtClaims(lendingBook),
+	}, nil
+}
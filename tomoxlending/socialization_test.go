@@ -0,0 +1,120 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestSocializeShortfall(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+
+	var (
+		lendingToken = common.HexToAddress(common.TomoNativeAddress)
+		term         = uint64(86400)
+		lendingBook  = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		investorA    = common.HexToAddress("0x21")
+		investorB    = common.HexToAddress("0x22")
+	)
+	// open trades of the lending book, trade 3 is closed
+	for id, params := range map[uint64]struct {
+		investor common.Address
+		amount   int64
+	}{1: {investorA, 300}, 2: {investorB, 100}, 3: {investorB, 0}} {
+		trade := lendingstate.LendingTrade{TradeId: id, Investor: params.investor, LendingToken: lendingToken, Term: term, Amount: big.NewInt(params.amount)}
+		trade.Hash = trade.ComputeHash()
+		lendingStateDB.InsertTradingItem(lendingBook, id, trade)
+	}
+	first := &lendingstate.LendingTrade{TradeId: 5, Investor: common.HexToAddress("0x23"), LendingToken: lendingToken, Term: term}
+	second := &lendingstate.LendingTrade{TradeId: 6, Investor: common.HexToAddress("0x24"), LendingToken: lendingToken, Term: term}
+
+	// the insurance fund is empty, the whole shortfall is charged pro rata of the amounts
+	drawn, haircuts, socialized, err := resolveShortfall(lendingStateDB, statedb, lendingBook, first, big.NewInt(80))
+	if err != nil {
+		t.Fatalf("failed to resolve the shortfall: %v", err)
+	}
+	if drawn.Sign() != 0 || socialized.Int64() != 80 || len(haircuts) != 2 || haircuts[0].Amount.Int64() != 60 || haircuts[1].Amount.Int64() != 20 {
+		t.Fatalf("wrong socialization: drawn %v, socialized %v, haircuts %v", drawn, socialized, lendingstate.ToJSON(haircuts))
+	}
+	// the shortfall over the open amount stays uncovered
+	if _, _, socialized, _ = resolveShortfall(lendingStateDB, statedb, lendingBook, second, big.NewInt(1000)); socialized.Int64() != 400 {
+		t.Fatalf("wrong second socialization: %v", socialized)
+	}
+	fund := lendingStateDB.GetInsuranceFund(lendingToken)
+	if fund.Uncovered.Int64() != 600 || fund.Socialized.Int64() != 480 {
+		t.Errorf("wrong fund: %v", lendingstate.ToJSON(fund))
+	}
+	if haircut := lendingStateDB.GetTradeHaircut(lendingBook, 1); haircut.Int64() != 360 {
+		t.Errorf("wrong haircut of trade 1: have %v, want 360", haircut)
+	}
+
+	// the haircuts pay the oldest claims first
+	trade1 := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(1))
+	statedb.AddBalance(investorA, big.NewInt(200))
+	if taken, err := payHaircut(lendingStateDB, statedb, lendingBook, &trade1, big.NewInt(200)); err != nil || taken.Int64() != 200 {
+		t.Fatalf("wrong haircut paid by trade 1: %v, %v", taken, err)
+	}
+	trade2 := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(2))
+	statedb.AddBalance(investorB, big.NewInt(500))
+	if taken, err := payHaircut(lendingStateDB, statedb, lendingBook, &trade2, big.NewInt(500)); err != nil || taken.Int64() != 120 {
+		t.Fatalf("wrong haircut paid by trade 2: %v, %v", taken, err)
+	}
+	if balance := statedb.GetBalance(investorA); balance.Sign() != 0 {
+		t.Errorf("wrong balance of investor A: have %v, want 0", balance)
+	}
+	if balance := statedb.GetBalance(investorB); balance.Int64() != 380 {
+		t.Errorf("wrong balance of investor B: have %v, want 380", balance)
+	}
+	if balance := statedb.GetBalance(first.Investor); balance.Int64() != 80 {
+		t.Errorf("wrong balance of the first claimant: have %v, want 80", balance)
+	}
+	if balance := statedb.GetBalance(second.Investor); balance.Int64() != 240 {
+		t.Errorf("wrong balance of the second claimant: have %v, want 240", balance)
+	}
+	claims := lendingStateDB.GetBadDebtClaims(lendingBook)
+	if len(claims) != 1 || claims[0].TradeId != 6 || claims[0].Amount.Int64() != 160 {
+		t.Errorf("wrong claims: %v", lendingstate.ToJSON(claims))
+	}
+
+	// a liquidated trade forfeits its haircut
+	forfeitHaircut(lendingStateDB, lendingBook, 1)
+	if haircuts := lendingStateDB.GetTradeHaircuts(lendingBook); len(haircuts) != 0 {
+		t.Errorf("haircuts left: %v", lendingstate.ToJSON(haircuts))
+	}
+}
+
+func TestHaircutRecords(t *testing.T) {
+	var (
+		usdt   = common.HexToAddress("0x10")
+		txHash = common.HexToHash("0x1")
+		txTime = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	)
+	haircut := lendingstate.TradeHaircut{TradeId: 1, Hash: common.HexToHash("0x11"), Investor: common.HexToAddress("0x21"), Amount: big.NewInt(7)}
+	auctions := []*lendingstate.LendingTrade{}
+	for id := uint64(5); id <= 6; id++ {
+		extraData, _ := json.Marshal(lendingstate.LiquidationData{Reason: lendingstate.LiquidatedByAuction, Shortfall: big.NewInt(10), InsuranceDraw: big.NewInt(3), Socialized: big.NewInt(7), Haircuts: []lendingstate.TradeHaircut{haircut}})
+		auction := &lendingstate.LendingTrade{TradeId: id, InvestingOrderHash: common.Uint64ToHash(id), LendingToken: usdt, Term: 86400, Status: lendingstate.TradeStatusLiquidated, ExtraData: string(extraData)}
+		auction.Hash = auction.ComputeHash()
+		auctions = append(auctions, auction)
+	}
+	records := insuranceRecords(nil, auctions, txHash, txTime)
+	if len(records) != 4 || records[1].Kind != lendingstate.InsuranceHaircut || records[3].Kind != lendingstate.InsuranceHaircut {
+		t.Fatalf("wrong records: %v", lendingstate.ToJSON(records))
+	}
+	record := records[1]
+	if record.TradeId != 1 || record.TradeHash != haircut.Hash || record.Investor != haircut.Investor || record.Amount.Int64() != 7 || record.AuctionHash != auctions[0].Hash {
+		t.Errorf("wrong haircut record: %v", lendingstate.ToJSON(record))
+	}
+	// the same trade charged by two auctions of a transaction has two records
+	if records[1].Hash == records[3].Hash {
+		t.Errorf("haircut records share the hash %s", record.Hash.Hex())
+	}
+}
@@ -0,0 +1,193 @@
+package tomoxlending
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Non-SDK nodes started with --tomox.lendingindex also keep the diff of the lending books made by
+// each block in the tomox leveldb, so that downstream indexers can follow the lending state block
+// by block without mongodb. The diffs are built from the lending history index once the items of
+// the block are indexed, keyed by block number and hash, and only the ones of canonical blocks are
+// returned. Blocks without lending activity have no record and an empty diff.
+var lendingStateDiffPrefix = []byte("lendingStateDiff-") // lendingStateDiffPrefix + number + hash -> LendingStateDiff
+
+// LendingStateDiff holds the changes of the lending books and trades made by a block: the items
+// entering a lending book, the resting items partially filled or amended, the resting items
+// leaving their book (filled, cancelled or rejected) with their last state, and the lending trades
+// opened by the matched items or closed by a repayment or a liquidation.
+type LendingStateDiff struct {
+	BlockNumber  uint64                       `json:"blockNumber"`
+	BlockHash    common.Hash                  `json:"blockHash"`
+	AddedItems   []*lendingstate.LendingItem  `json:"addedItems"`
+	UpdatedItems []*lendingstate.LendingItem  `json:"updatedItems"`
+	RemovedItems []*lendingstate.LendingItem  `json:"removedItems"`
+	OpenedTrades []*lendingstate.LendingTrade `json:"openedTrades"`
+	ClosedTrades []*lendingstate.LendingTrade `json:"closedTrades"`
+}
+
+func lendingStateDiffKey(number uint64, hash common.Hash) []byte {
+	key := make([]byte, len(lendingStateDiffPrefix)+8+common.HashLength)
+	copy(key, lendingStateDiffPrefix)
+	binary.BigEndian.PutUint64(key[len(lendingStateDiffPrefix):], number)
+	copy(key[len(lendingStateDiffPrefix)+8:], hash.Bytes())
+	return key
+}
+
+func newLendingStateDiff(number uint64, hash common.Hash) *LendingStateDiff {
+	return &LendingStateDiff{
+		BlockNumber:  number,
+		BlockHash:    hash,
+		AddedItems:   []*lendingstate.LendingItem{},
+		UpdatedItems: []*lendingstate.LendingItem{},
+		RemovedItems: []*lendingstate.LendingItem{},
+		OpenedTrades: []*lendingstate.LendingTrade{},
+		ClosedTrades: []*lendingstate.LendingTrade{},
+	}
+}
+
+func (d *LendingStateDiff) empty() bool {
+	return len(d.AddedItems) == 0 && len(d.UpdatedItems) == 0 && len(d.RemovedItems) == 0 && len(d.OpenedTrades) == 0 && len(d.ClosedTrades) == 0
+}
+
+// isRestingItem returns whether an indexed item entering the lending book with the given status
+// stays in it: market items and the remainders of immediate or cancel and fill or kill items are
+// dropped instead.
+func isRestingItem(item *lendingstate.LendingItem) bool {
+	if item.Status != lendingstate.LendingStatusOpen && item.Status != lendingstate.LendingStatusPartialFilled {
+		return false
+	}
+	if item.Type == lendingstate.Market {
+		return false
+	}
+	tif, _ := item.TimeInForce()
+	return tif != lendingstate.TimeInForceIOC && tif != lendingstate.TimeInForceFOK
+}
+
+func isClosedTrade(trade *lendingstate.LendingTrade) bool {
+	return trade.Status == lendingstate.TradeStatusClosed || trade.Status == lendingstate.TradeStatusLiquidated
+}
+
+// blockStateDiff returns the diff of the lending items of a block, with their trades and rejected
+// items, and of its finalized trades. It reads the items from the lending history index, which
+// must hold the items of the block.
+func (l *Lending) blockStateDiff(block *types.Block, items []*lendingstate.LendingItem, trades [][]*lendingstate.LendingTrade, rejected [][]*lendingstate.LendingItem, finalizedTrades map[common.Hash]*lendingstate.LendingTrade) *LendingStateDiff {
+	var (
+		diff     = newLendingStateDiff(block.NumberU64(), block.Hash())
+		touched  = []common.Hash{}
+		seen     = map[common.Hash]bool{}
+		newItems = map[common.Hash]bool{}
+	)
+	touch := func(hash common.Hash) {
+		if hash != (common.Hash{}) && !seen[hash] {
+			seen[hash] = true
+			touched = append(touched, hash)
+		}
+	}
+	for i, item := range items {
+		isOrder := item.Type == lendingstate.Limit || item.Type == lendingstate.Market || item.Type == lendingstate.StopLimit || item.Type == lendingstate.Iceberg
+		if isOrder {
+			switch item.Status {
+			case lendingstate.LendingStatusNew:
+				newItems[item.Hash] = true
+				touch(item.Hash)
+			case lendingstate.LendingStatusCancelled, lendingstate.LendingStatusAmended:
+				if len(rejected[i]) == 0 {
+					touch(item.Hash)
+				}
+			}
+		}
+		for _, trade := range trades[i] {
+			if trade == nil {
+				continue
+			}
+			touch(trade.BorrowingOrderHash)
+			touch(trade.InvestingOrderHash)
+			switch {
+			case isOrder && item.Status == lendingstate.LendingStatusNew && trade.Status == lendingstate.TradeStatusOpen:
+				diff.OpenedTrades = append(diff.OpenedTrades, trade)
+			case isClosedTrade(trade):
+				diff.ClosedTrades = append(diff.ClosedTrades, trade)
+			}
+		}
+		for _, item := range rejected[i] {
+			touch(item.Hash)
+		}
+	}
+	finalized := make([]*lendingstate.LendingTrade, 0, len(finalizedTrades))
+	for _, trade := range finalizedTrades {
+		if isClosedTrade(trade) {
+			finalized = append(finalized, trade)
+		}
+	}
+	sort.Slice(finalized, func(i, j int) bool {
+		return bytes.Compare(finalized[i].Hash.Bytes(), finalized[j].Hash.Bytes()) < 0
+	})
+	diff.ClosedTrades = append(diff.ClosedTrades, finalized...)
+
+	for _, hash := range touched {
+		item := l.getIndexedItem(hash)
+		if item == nil {
+			continue
+		}
+		resting := isRestingItem(item)
+		switch {
+		case newItems[hash]:
+			// an item filled or rejected as it is placed never enters the lending book
+			if resting {
+				diff.AddedItems = append(diff.AddedItems, item)
+			}
+		case resting:
+			diff.UpdatedItems = append(diff.UpdatedItems, item)
+		default:
+			diff.RemovedItems = append(diff.RemovedItems, item)
+		}
+	}
+	return diff
+}
+
+// IndexStateDiff records the diff of the lending books made by the lending items of a block, with
+// their trades and rejected items, and by its finalized trades. It must be called once the items of
+// the block are indexed by IndexLendingData.
+func (l *Lending) IndexStateDiff(block *types.Block, items []*lendingstate.LendingItem, trades [][]*lendingstate.LendingTrade, rejected [][]*lendingstate.LendingItem, finalizedTrades map[common.Hash]*lendingstate.LendingTrade) error {
+	if !l.HasLendingIndex() {
+		return nil
+	}
+	diff := l.blockStateDiff(block, items, trades, rejected, finalizedTrades)
+	if diff.empty() {
+		return nil
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	return l.GetLevelDB().Put(lendingStateDiffKey(block.NumberU64(), block.Hash()), data)
+}
+
+// getStateDiff returns the diff of the lending books made by a canonical block.
+func (l *Lending) getStateDiff(blockNr rpc.BlockNumber) (*LendingStateDiff, error) {
+	if !l.HasLendingIndex() {
+		return nil, errLendingHistoryUnavailable
+	}
+	block, err := l.blockByNumberOrHash(rpc.BlockNumberOrHashWithNumber(blockNr))
+	if err != nil {
+		return nil, err
+	}
+	data, err := l.GetLevelDB().Get(lendingStateDiffKey(block.NumberU64(), block.Hash()))
+	if err != nil || len(data) == 0 {
+		return newLendingStateDiff(block.NumberU64(), block.Hash()), nil
+	}
+	diff := &LendingStateDiff{}
+	if err := json.Unmarshal(data, diff); err != nil {
+		return nil, fmt.Errorf("corrupted state diff of block %d: %v", block.NumberU64(), err)
+	}
+	return diff, nil
+}
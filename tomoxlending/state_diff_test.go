@@ -0,0 +1,129 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestLendingStateDiff(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	if _, err := l.getStateDiff(rpc.LatestBlockNumber); err != errLendingHistoryUnavailable {
+		t.Fatalf("state diff without index: have %v, want %v", err, errLendingHistoryUnavailable)
+	}
+
+	l = New(tomox.New(&tomox.Config{DataDir: t.TempDir(), LendingIndex: true}))
+	var (
+		borrower = common.HexToAddress("0x1")
+		investor = common.HexToAddress("0x2")
+		usdt     = common.HexToAddress("0x10")
+		chain    = &logsTestChain{}
+		parent   = common.Hash{}
+		now      = time.Unix(1600000000, 0).UTC()
+	)
+	for i := int64(0); i < 4; i++ {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(i), Time: big.NewInt(i), ParentHash: parent})
+		chain.blocks = append(chain.blocks, block)
+		parent = block.Hash()
+	}
+	l.chain = chain
+
+	newItem := func(user common.Address, side string, quantity int64, n int64) *lendingstate.LendingItem {
+		return &lendingstate.LendingItem{
+			Quantity:     big.NewInt(quantity),
+			Interest:     big.NewInt(10),
+			Side:         side,
+			Type:         lendingstate.Limit,
+			LendingToken: usdt,
+			Status:       lendingstate.LendingStatusNew,
+			Term:         86400,
+			UserAddress:  user,
+			Hash:         common.BigToHash(big.NewInt(n)),
+		}
+	}
+	newTrade := func(borrowing, investing *lendingstate.LendingItem, amount int64, n int64) *lendingstate.LendingTrade {
+		return &lendingstate.LendingTrade{
+			Borrower:           borrower,
+			Investor:           investor,
+			LendingToken:       usdt,
+			BorrowingOrderHash: borrowing.Hash,
+			InvestingOrderHash: investing.Hash,
+			Term:               86400,
+			Amount:             big.NewInt(amount),
+			Status:             lendingstate.TradeStatusOpen,
+			Hash:               common.BigToHash(big.NewInt(n)),
+		}
+	}
+	index := func(block *types.Block, items []*lendingstate.LendingItem, trades [][]*lendingstate.LendingTrade, rejected [][]*lendingstate.LendingItem, finalized map[common.Hash]*lendingstate.LendingTrade) {
+		for i, item := range items {
+			if err := l.IndexLendingData(item, common.BigToHash(big.NewInt(int64(100+i))), now, trades[i], rejected[i]); err != nil {
+				t.Fatalf("failed to index item %x: %v", item.Hash, err)
+			}
+		}
+		if err := l.IndexStateDiff(block, items, trades, rejected, finalized); err != nil {
+			t.Fatalf("failed to index the state diff of block %d: %v", block.NumberU64(), err)
+		}
+	}
+
+	// block 1: two borrowing items enter the book, an immediate or cancel one doesn't
+	first, second, ioc := newItem(borrower, lendingstate.Borrowing, 100, 1), newItem(borrower, lendingstate.Borrowing, 100, 2), newItem(borrower, lendingstate.Borrowing, 100, 3)
+	ioc.ExtraData = lendingstate.TimeInForceIOC
+	index(chain.blocks[1], []*lendingstate.LendingItem{first, second, ioc}, make([][]*lendingstate.LendingTrade, 3), make([][]*lendingstate.LendingItem, 3), nil)
+
+	diff, err := l.getStateDiff(1)
+	if err != nil {
+		t.Fatalf("failed to get the diff of block 1: %v", err)
+	}
+	if diff.BlockHash != chain.blocks[1].Hash() || len(diff.AddedItems) != 2 || diff.AddedItems[0].Hash != first.Hash || diff.AddedItems[1].Hash != second.Hash || len(diff.UpdatedItems) != 0 || len(diff.RemovedItems) != 0 || len(diff.OpenedTrades) != 0 {
+		t.Fatalf("wrong diff of block 1: %v", lendingstate.ToJSON(diff))
+	}
+
+	// block 2: an investing item fills the first item and part of the second one as it is placed
+	investing := newItem(investor, lendingstate.Investing, 150, 4)
+	filled, partial := newTrade(first, investing, 100, 10), newTrade(second, investing, 50, 11)
+	index(chain.blocks[2], []*lendingstate.LendingItem{investing}, [][]*lendingstate.LendingTrade{{filled, partial}}, make([][]*lendingstate.LendingItem, 1), nil)
+
+	if diff, err = l.getStateDiff(2); err != nil {
+		t.Fatalf("failed to get the diff of block 2: %v", err)
+	}
+	if len(diff.AddedItems) != 0 || len(diff.OpenedTrades) != 2 || len(diff.ClosedTrades) != 0 {
+		t.Fatalf("wrong diff of block 2: %v", lendingstate.ToJSON(diff))
+	}
+	if len(diff.RemovedItems) != 1 || diff.RemovedItems[0].Hash != first.Hash || diff.RemovedItems[0].Status != lendingstate.LendingStatusFilled {
+		t.Errorf("wrong removed items of block 2: %v", lendingstate.ToJSON(diff.RemovedItems))
+	}
+	if len(diff.UpdatedItems) != 1 || diff.UpdatedItems[0].Hash != second.Hash || diff.UpdatedItems[0].FilledAmount.Int64() != 50 {
+		t.Errorf("wrong updated items of block 2: %v", lendingstate.ToJSON(diff.UpdatedItems))
+	}
+
+	// block 3: the second item is cancelled and the first trade liquidated
+	cancel := *second
+	cancel.Status = lendingstate.LendingStatusCancelled
+	liquidated := *filled
+	liquidated.Status = lendingstate.TradeStatusLiquidated
+	index(chain.blocks[3], []*lendingstate.LendingItem{&cancel}, make([][]*lendingstate.LendingTrade, 1), make([][]*lendingstate.LendingItem, 1), map[common.Hash]*lendingstate.LendingTrade{liquidated.Hash: &liquidated})
+
+	if diff, err = l.getStateDiff(rpc.LatestBlockNumber); err != nil {
+		t.Fatalf("failed to get the diff of block 3: %v", err)
+	}
+	if diff.BlockNumber != 3 || len(diff.RemovedItems) != 1 || diff.RemovedItems[0].Hash != second.Hash || diff.RemovedItems[0].Status != lendingstate.LendingStatusCancelled {
+		t.Errorf("wrong removed items of block 3: %v", lendingstate.ToJSON(diff))
+	}
+	if len(diff.ClosedTrades) != 1 || diff.ClosedTrades[0].Hash != filled.Hash {
+		t.Errorf("wrong closed trades of block 3: %v", lendingstate.ToJSON(diff.ClosedTrades))
+	}
+
+	// a block without lending activity has an empty diff
+	if diff, err = l.getStateDiff(0); err != nil || !diff.empty() || diff.BlockHash != chain.blocks[0].Hash() {
+		t.Errorf("wrong diff of block 0: %v, %v", lendingstate.ToJSON(diff), err)
+	}
+	if _, err := l.getStateDiff(9); err == nil {
+		t.Error("diff of a missing block returned")
+	}
+}
@@ -0,0 +1,252 @@
+package tomoxlending
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb/memorydb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// The trading and lending state tries of a checkpoint block can be downloaded from the
+// tomoxlending peers instead of being rebuilt by re-executing every order transaction, the same
+// way the eth protocol downloads the account state: the missing trie nodes are requested by hash
+// with GetTrieNodesMsg and served from the tomox leveldb, which holds both tries.
+
+var (
+	errStateSyncRunning = errors.New("a TomoX state sync is already running")
+	errNoStateSyncPeers = errors.New("no tomoxlending peer to download the state from")
+	errStateSyncStopped = errors.New("TomoX state sync stopped")
+)
+
+// StateSyncProgress reports the download of the trading and lending state of a checkpoint block.
+type StateSyncProgress struct {
+	BlockNumber uint64      `json:"blockNumber"`
+	BlockHash   common.Hash `json:"blockHash"`
+	TradingRoot common.Hash `json:"tradingRoot"`
+	LendingRoot common.Hash `json:"lendingRoot"`
+	Processed   uint64      `json:"processed"` // trie nodes downloaded
+	Pending     int         `json:"pending"`   // trie nodes known to be missing
+	Done        bool        `json:"done"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// trieNodesDelivery is an answer to a trie nodes request.
+type trieNodesDelivery struct {
+	peer  *peer
+	nodes [][]byte
+}
+
+// stateSyncTrie is the download of one of the state tries, along with the nodes which were
+// requested but not delivered.
+type stateSyncTrie struct {
+	sched *trie.Sync
+	retry []common.Hash
+}
+
+// trieNodes returns the state trie nodes of the given hashes known to the node, up to the soft
+// size limit of an answer.
+func (l *Lending) trieNodes(hashes []common.Hash) [][]byte {
+	var (
+		nodes [][]byte
+		size  int
+	)
+	for i, hash := range hashes {
+		if i >= maxTrieNodesFetch || size >= trieNodesSoftLimit {
+			break
+		}
+		if data, err := l.GetLevelDB().Get(hash.Bytes()); err == nil && len(data) > 0 {
+			nodes = append(nodes, data)
+			size += len(data)
+		}
+	}
+	return nodes
+}
+
+// deliverTrieNodes hands an answer to a trie nodes request to the running state sync, if any.
+func (l *Lending) deliverTrieNodes(p *peer, nodes [][]byte) {
+	l.stateSyncLock.Lock()
+	ch := l.stateSyncCh
+	l.stateSyncLock.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- &trieNodesDelivery{peer: p, nodes: nodes}:
+	default:
+		p.Log().Debug("Dropped unexpected TomoX state trie nodes", "count", len(nodes))
+	}
+}
+
+// StateSyncProgress returns the progress of the last state sync, nil if none was started.
+func (l *Lending) StateSyncProgress() *StateSyncProgress {
+	l.stateSyncLock.Lock()
+	defer l.stateSyncLock.Unlock()
+
+	if l.stateSyncProgress == nil {
+		return nil
+	}
+	progress := *l.stateSyncProgress
+	return &progress
+}
+
+// SyncState downloads the trading and lending state tries of a checkpoint block from the peers,
+// so that the blocks following it can be processed without re-executing the order transactions
+// of the chain. It returns once both tries are complete in the tomox leveldb.
+func (l *Lending) SyncState(block *types.Block) error {
+	if l.chain == nil {
+		return errLendingStateUnavailable
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return err
+	}
+	tradingRoot, err := l.tomox.GetTradingStateRoot(block, author)
+	if err != nil {
+		return err
+	}
+	lendingRoot, err := l.GetLendingStateRoot(block, author)
+	if err != nil {
+		return err
+	}
+
+	l.stateSyncLock.Lock()
+	if l.stateSyncCh != nil {
+		l.stateSyncLock.Unlock()
+		return errStateSyncRunning
+	}
+	ch := make(chan *trieNodesDelivery, 1)
+	l.stateSyncCh = ch
+	l.stateSyncProgress = &StateSyncProgress{BlockNumber: block.NumberU64(), BlockHash: block.Hash(), TradingRoot: tradingRoot, LendingRoot: lendingRoot}
+	l.stateSyncLock.Unlock()
+
+	err = l.syncStateTries(ch, tradingRoot, lendingRoot)
+
+	l.stateSyncLock.Lock()
+	l.stateSyncCh = nil
+	l.stateSyncProgress.Done = err == nil
+	if err != nil {
+		l.stateSyncProgress.Error = err.Error()
+	}
+	l.stateSyncLock.Unlock()
+	if err != nil {
+		log.Error("TomoX state sync failed", "number", block.NumberU64(), "err", err)
+		return err
+	}
+	log.Info("TomoX state sync completed", "number", block.NumberU64(), "tradingRoot", tradingRoot, "lendingRoot", lendingRoot)
+	return nil
+}
+
+func (l *Lending) syncStateTries(ch chan *trieNodesDelivery, tradingRoot, lendingRoot common.Hash) error {
+	var (
+		db       = l.GetLevelDB()
+		bloom    = trie.NewSyncBloom(1, memorydb.New())
+		tries    = []*stateSyncTrie{{sched: tradingstate.NewTradingStateSync(tradingRoot, db, bloom)}, {sched: lendingstate.NewLendingStateSync(lendingRoot, db, bloom)}}
+		failures = 0
+		next     = 0
+	)
+	defer bloom.Close()
+
+	for {
+		// assemble the next request from the undelivered nodes first
+		owners := make(map[common.Hash]*stateSyncTrie)
+		var hashes []common.Hash
+		pending := 0
+		for _, t := range tries {
+			for len(t.retry) > 0 && len(hashes) < maxTrieNodesFetch {
+				hashes, owners[t.retry[0]] = append(hashes, t.retry[0]), t
+				t.retry = t.retry[1:]
+			}
+			if len(hashes) < maxTrieNodesFetch {
+				for _, hash := range t.sched.Missing(maxTrieNodesFetch - len(hashes)) {
+					hashes, owners[hash] = append(hashes, hash), t
+				}
+			}
+			pending += t.sched.Pending()
+		}
+		l.stateSyncLock.Lock()
+		l.stateSyncProgress.Pending = pending
+		l.stateSyncLock.Unlock()
+		if len(hashes) == 0 {
+			if pending == 0 {
+				return nil
+			}
+			return fmt.Errorf("%d trie nodes pending without request", pending)
+		}
+
+		peers := l.peers.Peers()
+		if len(peers) == 0 {
+			return errNoStateSyncPeers
+		}
+		p := peers[next%len(peers)]
+		next++
+		if err := p.RequestTrieNodes(hashes); err != nil {
+			p.Log().Debug("Failed to request TomoX state trie nodes", "err", err)
+		}
+		timer := time.NewTimer(trieNodesTimeout)
+		var nodes [][]byte
+	wait:
+		for {
+			select {
+			case delivery := <-ch:
+				if delivery.peer != p {
+					continue
+				}
+				nodes = delivery.nodes
+				break wait
+			case <-timer.C:
+				break wait
+			case <-l.quit:
+				timer.Stop()
+				return errStateSyncStopped
+			}
+		}
+		timer.Stop()
+
+		processed := 0
+		for _, data := range nodes {
+			hash := crypto.Keccak256Hash(data)
+			t := owners[hash]
+			if t == nil {
+				continue
+			}
+			delete(owners, hash)
+			if _, _, err := t.sched.Process([]trie.SyncResult{{Hash: hash, Data: data}}); err != nil && err != trie.ErrAlreadyProcessed {
+				p.Log().Debug("Invalid TomoX state trie node", "hash", hash, "err", err)
+				owners[hash] = t
+				continue
+			}
+			processed++
+		}
+		for hash, t := range owners {
+			t.retry = append(t.retry, hash)
+		}
+		// give up after too many requests in a row answered with no useful node
+		if processed == 0 {
+			if failures++; failures > maxStateSyncTimeouts {
+				return fmt.Errorf("%d trie nodes requests in a row failed", failures)
+			}
+		} else {
+			failures = 0
+		}
+		batch := db.NewBatch()
+		for _, t := range tries {
+			if err := t.sched.Commit(batch); err != nil {
+				return err
+			}
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		l.stateSyncLock.Lock()
+		l.stateSyncProgress.Processed += uint64(processed)
+		l.stateSyncLock.Unlock()
+	}
+}
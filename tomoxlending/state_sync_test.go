@@ -0,0 +1,98 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestSyncStateTries(t *testing.T) {
+	source := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	dest := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+
+	var (
+		usdt        = common.HexToAddress("0x10")
+		tomo        = common.HexToAddress("0x20")
+		orderBook   = tradingstate.GetTradingOrderBookHash(tomo, usdt)
+		lendingBook = lendingstate.GetLendingOrderBookHash(usdt, 86400)
+		orderId     = common.BigToHash(big.NewInt(1))
+	)
+	tradingState, err := tradingstate.New(tradingstate.EmptyRoot, source.tomox.StateCache)
+	if err != nil {
+		t.Fatalf("failed to create trading state: %v", err)
+	}
+	tradingState.InsertOrderItem(orderBook, orderId, tradingstate.OrderItem{OrderID: 1, Quantity: big.NewInt(5), Price: big.NewInt(3), Side: tradingstate.Ask, Signature: &tradingstate.Signature{}})
+	tradingState.InsertLiquidationPrice(orderBook, big.NewInt(2), lendingBook, 1)
+	tradingRoot, err := tradingState.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	if err := source.tomox.StateCache.TrieDB().Commit(tradingRoot, false); err != nil {
+		t.Fatalf("failed to flush trading state: %v", err)
+	}
+
+	lendingState, err := lendingstate.New(lendingstate.EmptyRoot, source.StateCache)
+	if err != nil {
+		t.Fatalf("failed to create lending state: %v", err)
+	}
+	lendingState.InsertLendingItem(lendingBook, orderId, lendingstate.LendingItem{LendingId: 1, Quantity: big.NewInt(100), Interest: big.NewInt(10), Side: lendingstate.Investing, Signature: &lendingstate.Signature{}})
+	lendingState.InsertTradingItem(lendingBook, 1, lendingstate.LendingTrade{TradeId: 1, Amount: big.NewInt(40), LiquidationTime: 100})
+	lendingRoot, err := lendingState.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	if err := source.StateCache.TrieDB().Commit(lendingRoot, false); err != nil {
+		t.Fatalf("failed to flush lending state: %v", err)
+	}
+
+	// connect the destination to the source
+	sourceRW, destRW := p2p.MsgPipe()
+	defer sourceRW.Close()
+	sourcePeer := newPeer(p2p.NewPeer(discover.NodeID{2}, "dest", nil), sourceRW)
+	destPeer := newPeer(p2p.NewPeer(discover.NodeID{1}, "source", nil), destRW)
+	go func() {
+		for source.handleMsg(sourcePeer) == nil {
+		}
+	}()
+	go func() {
+		for dest.handleMsg(destPeer) == nil {
+		}
+	}()
+	dest.peers.Register(destPeer)
+
+	ch := make(chan *trieNodesDelivery, 1)
+	dest.stateSyncCh, dest.stateSyncProgress = ch, &StateSyncProgress{TradingRoot: tradingRoot, LendingRoot: lendingRoot}
+	if err := dest.syncStateTries(ch, tradingRoot, lendingRoot); err != nil {
+		t.Fatalf("failed to sync state: %v", err)
+	}
+	if progress := dest.StateSyncProgress(); progress.Processed == 0 || progress.Pending != 0 {
+		t.Fatalf("wrong progress: %+v", progress)
+	}
+
+	synced, err := tradingstate.New(tradingRoot, dest.tomox.StateCache)
+	if err != nil {
+		t.Fatalf("failed to open synced trading state: %v", err)
+	}
+	if order := synced.GetOrder(orderBook, orderId); order.Quantity == nil || order.Quantity.Cmp(big.NewInt(5)) != 0 {
+		t.Fatalf("wrong synced order: %+v", order)
+	}
+	if price, trades := synced.GetHighestLiquidationPriceData(orderBook, big.NewInt(1)); price == nil || price.Cmp(big.NewInt(2)) != 0 || len(trades[lendingBook]) != 1 {
+		t.Fatalf("wrong synced liquidation price: %v %v", price, trades)
+	}
+	syncedLending, err := lendingstate.New(lendingRoot, dest.StateCache)
+	if err != nil {
+		t.Fatalf("failed to open synced lending state: %v", err)
+	}
+	if item := syncedLending.GetLendingOrder(lendingBook, orderId); item.Quantity == nil || item.Quantity.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("wrong synced lending item: %+v", item)
+	}
+	if trade := syncedLending.GetLendingTrade(lendingBook, common.Uint64ToHash(1)); trade.Amount == nil || trade.Amount.Cmp(big.NewInt(40)) != 0 {
+		t.Fatalf("wrong synced lending trade: %+v", trade)
+	}
+}
@@ -0,0 +1,119 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// maxTriggeredStopOrders is the maximum number of stop-limit items triggered by a single lending item.
+const maxTriggeredStopOrders = 20
+
+// Stop-limit items waiting for their trigger are kept in the trigger book of their lending book
+// (see lendingstate.GetLendingTriggerBookHash). They are indexed by trigger interest: the stored
+// item carries the trigger in Interest and its limit interest in ExtraData.
+func toTriggerItem(order *lendingstate.LendingItem) lendingstate.LendingItem {
+	item := *order
+	item.Interest = order.TriggerInterest()
+	item.ExtraData = order.Interest.String()
+	return item
+}
+
+func fromTriggerItem(item lendingstate.LendingItem) lendingstate.LendingItem {
+	order := item
+	order.Interest, _ = new(big.Int).SetString(item.ExtraData, 10)
+	order.ExtraData = item.Interest.String()
+	return order
+}
+
+// stopOrderTriggered returns whether the best interest rates of a lending book crossed the trigger
+// of a stop-limit item: a borrowing item is triggered once investors offer an interest as low as
+// the trigger, an investing item once borrowers ask for an interest as high as the trigger.
+func stopOrderTriggered(lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, side string, trigger *big.Int) bool {
+	switch side {
+	case lendingstate.Borrowing:
+		bestInvesting, _ := lendingStateDB.GetBestInvestingRate(lendingOrderBook)
+		return bestInvesting.Sign() > 0 && bestInvesting.Cmp(trigger) <= 0
+	case lendingstate.Investing:
+		bestBorrowing, _ := lendingStateDB.GetBestBorrowRate(lendingOrderBook)
+		return bestBorrowing.Sign() > 0 && bestBorrowing.Cmp(trigger) >= 0
+	}
+	return false
+}
+
+// processStopLimitOrder puts a stop-limit item into the trigger book of the lending book,
+// or processes it as a limit order right away if its trigger has already been crossed.
+func (l *Lending) processStopLimitOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
+	if stopOrderTriggered(lendingStateDB, lendingOrderBook, order.Side, order.TriggerInterest()) {
+		return l.processLimitOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order)
+	}
+	triggerBook := lendingstate.GetLendingTriggerBookHash(lendingOrderBook)
+	order.LendingId = lendingStateDB.GetNonce(triggerBook) + 1
+	lendingStateDB.SetNonce(triggerBook, order.LendingId)
+	lendingStateDB.InsertLendingItem(triggerBook, common.BigToHash(new(big.Int).SetUint64(order.LendingId)), toTriggerItem(order))
+	log.Debug("Stop-limit order added to trigger book", "side", order.Side, "LendingId", order.LendingId, "trigger", order.ExtraData)
+	return nil, nil, nil
+}
+
+// processTriggeredStopOrders moves the stop-limit items whose trigger has been crossed from the
+// trigger book into the lending book, processing them as limit orders. A triggered item failing
+// as a limit order is rejected, leaving the states as they were before its matching.
+func (l *Lending) processTriggeredStopOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem) {
+	var (
+		trades  []*lendingstate.LendingTrade
+		rejects []*lendingstate.LendingItem
+	)
+	triggerBook := lendingstate.GetLendingTriggerBookHash(lendingOrderBook)
+	if !lendingStateDB.Exist(triggerBook) {
+		return trades, rejects
+	}
+	for i := 0; i < maxTriggeredStopOrders; i++ {
+		var (
+			side    string
+			trigger *big.Int
+		)
+		if highest, _ := lendingStateDB.GetBestBorrowRate(triggerBook); highest.Sign() > 0 && stopOrderTriggered(lendingStateDB, lendingOrderBook, lendingstate.Borrowing, highest) {
+			side, trigger = lendingstate.Borrowing, highest
+		} else if lowest, _ := lendingStateDB.GetBestInvestingRate(triggerBook); lowest.Sign() > 0 && stopOrderTriggered(lendingStateDB, lendingOrderBook, lendingstate.Investing, lowest) {
+			side, trigger = lendingstate.Investing, lowest
+		} else {
+			break
+		}
+		lendingId, amount, err := lendingStateDB.GetBestLendingIdAndAmount(triggerBook, trigger, side)
+		if err != nil {
+			log.Error("Failed to get triggered stop-limit order", "lendingBook", lendingOrderBook.Hex(), "trigger", trigger, "side", side, "err", err)
+			break
+		}
+		item := lendingStateDB.GetLendingOrder(triggerBook, lendingId)
+		if err := lendingStateDB.SubAmountLendingItem(triggerBook, lendingId, trigger, amount, side); err != nil {
+			log.Error("Failed to remove triggered stop-limit order", "lendingBook", lendingOrderBook.Hex(), "lendingId", lendingId.Hex(), "err", err)
+			break
+		}
+		order := fromTriggerItem(item)
+		order.Quantity = amount
+		log.Debug("Process triggered stop-limit order", "side", order.Side, "quantity", order.Quantity, "Interest", order.Interest, "trigger", trigger)
+		lendingSnap := lendingStateDB.Snapshot()
+		tradingSnap := tradingStateDb.Snapshot()
+		dbSnap := statedb.Snapshot()
+		newTrades, newRejects, err := l.processLimitOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, &order)
+		if err != nil {
+			// the item is cancelled: its matching is reverted, and it isn't put back into the
+			// trigger book, where it would hold back the items triggered after it
+			lendingStateDB.RevertToSnapshot(lendingSnap)
+			tradingStateDb.RevertToSnapshot(tradingSnap)
+			statedb.RevertToSnapshot(dbSnap)
+			log.Debug("Cancel triggered stop-limit order", "lendingId", lendingId.Hex(), "err", err)
+			rejects = append(rejects, rejectLendingItem(&order, lendingstate.GetRejectReason(err, lendingstate.RejectReasonUnknown)))
+			continue
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+	}
+	return trades, rejects
+}
@@ -0,0 +1,92 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestStopOrderTriggered(t *testing.T) {
+	lendingBook := common.StringToHash("USDT/60")
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(1), lendingstate.LendingItem{LendingId: 1, Quantity: big.NewInt(1), Interest: big.NewInt(5), Side: lendingstate.Investing})
+	lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(2), lendingstate.LendingItem{LendingId: 2, Quantity: big.NewInt(1), Interest: big.NewInt(3), Side: lendingstate.Borrowing})
+
+	tests := []struct {
+		side    string
+		trigger int64
+		want    bool
+	}{
+		{lendingstate.Borrowing, 6, true},
+		{lendingstate.Borrowing, 5, true},
+		{lendingstate.Borrowing, 4, false},
+		{lendingstate.Investing, 2, true},
+		{lendingstate.Investing, 3, true},
+		{lendingstate.Investing, 4, false},
+	}
+	for _, tt := range tests {
+		if got := stopOrderTriggered(lendingStateDB, lendingBook, tt.side, big.NewInt(tt.trigger)); got != tt.want {
+			t.Errorf("side %s trigger %d: have %v, want %v", tt.side, tt.trigger, got, tt.want)
+		}
+	}
+}
+
+func TestTriggerItemRoundTrip(t *testing.T) {
+	order := &lendingstate.LendingItem{Type: lendingstate.StopLimit, Interest: big.NewInt(7), ExtraData: "9"}
+	item := toTriggerItem(order)
+	if item.Interest.Cmp(big.NewInt(9)) != 0 || item.ExtraData != "7" {
+		t.Fatalf("unexpected trigger item: interest %v, extraData %s", item.Interest, item.ExtraData)
+	}
+	restored := fromTriggerItem(item)
+	if restored.Interest.Cmp(order.Interest) != 0 || restored.ExtraData != order.ExtraData {
+		t.Fatalf("unexpected restored item: interest %v, extraData %s", restored.Interest, restored.ExtraData)
+	}
+}
+
+func TestTriggeredStopOrderFailure(t *testing.T) {
+	policy := lendingstate.SelfTradePrevention
+	lendingstate.SelfTradePrevention = lendingstate.SelfTradeCancelOldest
+	defer func() { lendingstate.SelfTradePrevention = policy }()
+
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+	var (
+		lendingBook = common.StringToHash("USDT/60")
+		triggerBook = lendingstate.GetLendingTriggerBookHash(lendingBook)
+		user        = common.HexToAddress("0x1")
+		other       = common.HexToAddress("0x2")
+		header      = &types.Header{Number: new(big.Int).Set(common.TIPTomoXLendingV2), Time: big.NewInt(1)}
+	)
+	// the oldest investing item is the user's own, cancelled as a self-trade, before the
+	// triggered item fails to match the other one as it locks no collateral
+	for id, investor := range []common.Address{user, other} {
+		lendingId := uint64(id + 1)
+		lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(lendingId), lendingstate.LendingItem{LendingId: lendingId, Quantity: big.NewInt(10), Interest: big.NewInt(5),
+			Side: lendingstate.Investing, UserAddress: investor, Signature: &lendingstate.Signature{}})
+	}
+	stop := &lendingstate.LendingItem{LendingId: 1, Quantity: big.NewInt(15), Interest: big.NewInt(6), ExtraData: "5", Side: lendingstate.Borrowing,
+		Type: lendingstate.StopLimit, Status: lendingstate.LendingStatusNew, UserAddress: user, Signature: &lendingstate.Signature{}}
+	lendingStateDB.InsertLendingItem(triggerBook, common.Uint64ToHash(1), toTriggerItem(stop))
+
+	trades, rejects := l.processTriggeredStopOrders(header, common.Address{}, &epochTestChain{}, statedb, lendingStateDB, tradingStateDB, lendingBook)
+	if len(trades) != 0 || len(rejects) != 1 || rejects[0].UserAddress != user || rejects[0].RejectReason != lendingstate.RejectReasonUnknown {
+		t.Fatalf("triggered item not rejected: %d trades, rejects %v", len(trades), rejects)
+	}
+	// the self-trade cancellation is reverted, and the item isn't put back into the trigger book
+	if item := lendingStateDB.GetLendingOrder(lendingBook, common.Uint64ToHash(1)); item.Quantity == nil || item.Quantity.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("matching of the failed item not reverted: investing item %v", item.Quantity)
+	}
+	if rate, _ := lendingStateDB.GetBestBorrowRate(triggerBook); rate.Sign() != 0 {
+		t.Errorf("failed item put back into the trigger book at %v", rate)
+	}
+}
@@ -0,0 +1,125 @@
+package tomoxlending
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Since TIPTomoXLendingV2 a limit item can set its time in force in ExtraData (see lendingstate.TimeInForceGTC),
+// and since TIPTomoXPostOnly make itself post only.
+// Good-till-time items resting in a lending book are indexed by expiry time in the expiry book of the lending
+// book (see lendingstate.GetLendingExpiryBookHash). The expired items are removed from the lending book before
+// processing any item of the book, so they are never matched, and from every lending book once per block by
+// SweepExpiredLendingItems, so they don't rest in the books no item targets.
+
+// processFillOrKillOrder processes a fill-or-kill item as a limit order, and reverts the matching
+// unless the whole quantity of the item is filled.
+func (l *Lending) processFillOrKillOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
+	lendingSnap := lendingStateDB.Snapshot()
+	tradingSnap := tradingStateDb.Snapshot()
+	dbSnap := statedb.Snapshot()
+	trades, rejects, err := l.processLimitOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, reject := range rejects {
+		if reject == order {
+			log.Debug("Fill-or-kill order not filled", "LendingId", order.LendingId, "quantity", order.Quantity, "reason", order.RejectReason)
+			lendingStateDB.RevertToSnapshot(lendingSnap)
+			tradingStateDb.RevertToSnapshot(tradingSnap)
+			statedb.RevertToSnapshot(dbSnap)
+			return []*lendingstate.LendingTrade{}, []*lendingstate.LendingItem{order}, nil
+		}
+	}
+	return trades, rejects, nil
+}
+
+// postOnlyItemCrosses returns whether a post-only item would match a resting item of the lending book
+// instead of resting in the book as a maker.
+func postOnlyItemCrosses(lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) bool {
+	if order.Side == lendingstate.Borrowing {
+		minInterest, _ := lendingStateDB.GetBestInvestingRate(lendingOrderBook)
+		return minInterest.Sign() > 0 && order.Interest.Cmp(minInterest) >= 0
+	}
+	maxInterest, _ := lendingStateDB.GetBestBorrowRate(lendingOrderBook)
+	return maxInterest.Sign() > 0 && order.Interest.Cmp(maxInterest) <= 0
+}
+
+// insertLendingExpiry indexes a good-till-time item added to the lending book by expiry time.
+func insertLendingExpiry(lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) {
+	if tif, expiry := order.TimeInForce(); tif == lendingstate.TimeInForceGTT {
+		lendingStateDB.InsertLiquidationTime(lendingstate.GetLendingExpiryBookHash(lendingOrderBook), new(big.Int).SetUint64(expiry), order.LendingId)
+	}
+}
+
+// sweepExpiredLendingItems removes from the lending book the good-till-time items expired at the time
+// of the header, and returns them.
+func sweepExpiredLendingItems(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash) ([]*lendingstate.LendingItem, error) {
+	var expired []*lendingstate.LendingItem
+	expiryBook := lendingstate.GetLendingExpiryBookHash(lendingOrderBook)
+	if !lendingStateDB.Exist(expiryBook) {
+		return expired, nil
+	}
+	expiry, lendingIds := lendingStateDB.GetLowestLiquidationTime(expiryBook, header.Time)
+	for expiry.Sign() > 0 && expiry.Cmp(header.Time) <= 0 {
+		for _, lendingId := range lendingIds {
+			if err := lendingStateDB.RemoveLiquidationTime(expiryBook, lendingId.Big().Uint64(), expiry.Uint64()); err != nil {
+				return nil, err
+			}
+			item := lendingStateDB.GetLendingOrder(lendingOrderBook, common.BigToHash(lendingId.Big()))
+			// the item may have been filled or cancelled already
+			if item.Quantity == nil || item.Quantity.Sign() == 0 {
+				continue
+			}
+			if tif, itemExpiry := item.TimeInForce(); tif != lendingstate.TimeInForceGTT || itemExpiry != expiry.Uint64() {
+				continue
+			}
+			if err := cancelLendingItem(lendingStateDB, lendingOrderBook, &item); err != nil {
+				return nil, err
+			}
+			log.Debug("Good-till-time order expired", "LendingId", item.LendingId, "expiry", expiry, "quantity", item.Quantity)
+			expired = append(expired, rejectLendingItem(&item, lendingstate.RejectReasonExpired))
+		}
+		expiry, lendingIds = lendingStateDB.GetLowestLiquidationTime(expiryBook, header.Time)
+	}
+	return expired, nil
+}
+
+// SweepExpiredLendingItems removes the good-till-time items expired at the time of the header from
+// every lending book, and returns them. It runs on every block after the lending items of the
+// block, on the miner and on the validators alike.
+func (l *Lending) SweepExpiredLendingItems(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingState *lendingstate.LendingStateDB) ([]*lendingstate.LendingItem, error) {
+	var expired []*lendingstate.LendingItem
+	if !chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		return expired, nil
+	}
+	allLendingBooks, err := lendingstate.GetAllLendingBooks(statedb)
+	if err != nil {
+		log.Debug("Not found all lending books", "error", err)
+		return expired, nil
+	}
+	books := make([]common.Hash, 0, len(allLendingBooks))
+	for lendingBook := range allLendingBooks {
+		books = append(books, lendingBook)
+	}
+	sort.Slice(books, func(i, j int) bool {
+		return bytes.Compare(books[i][:], books[j][:]) < 0
+	})
+	for _, lendingBook := range books {
+		items, err := sweepExpiredLendingItems(header, lendingState, lendingBook)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, items...)
+	}
+	return expired, nil
+}
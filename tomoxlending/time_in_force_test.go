@@ -0,0 +1,136 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestSweepExpiredLendingItems(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	lendingBook := lendingstate.GetLendingOrderBookHash(common.HexToAddress(common.TomoNativeAddress), 86400)
+
+	items := []*lendingstate.LendingItem{
+		{LendingId: 1, ExtraData: "GTT:1000"},
+		{LendingId: 2, ExtraData: "GTT:2000"},
+		{LendingId: 3, ExtraData: "GTT:1000"},
+		{LendingId: 4, ExtraData: "GTC"},
+	}
+	for _, item := range items {
+		item.Quantity = big.NewInt(100)
+		item.Interest = big.NewInt(5)
+		item.Side = lendingstate.Borrowing
+		item.Type = lendingstate.Limit
+		item.Hash = common.BigToHash(new(big.Int).SetUint64(item.LendingId))
+		insertLendingItem(lendingStateDB, lendingBook, item)
+		insertLendingExpiry(lendingStateDB, lendingBook, item)
+	}
+	// the third item is filled before its expiry
+	if err := lendingStateDB.SubAmountLendingItem(lendingBook, items[2].Hash, items[2].Interest, items[2].Quantity, items[2].Side); err != nil {
+		t.Fatalf("failed to fill item: %v", err)
+	}
+
+	sweep := func(time int64) []*lendingstate.LendingItem {
+		expired, err := sweepExpiredLendingItems(&types.Header{Time: big.NewInt(time)}, lendingStateDB, lendingBook)
+		if err != nil {
+			t.Fatalf("failed to sweep at %d: %v", time, err)
+		}
+		return expired
+	}
+	if expired := sweep(999); len(expired) != 0 {
+		t.Fatalf("items expired too early: %v", expired)
+	}
+	expired := sweep(1500)
+	if len(expired) != 1 || expired[0].LendingId != 1 || expired[0].RejectReason != lendingstate.RejectReasonExpired {
+		t.Fatalf("wrong expired items at 1500: %v", expired)
+	}
+	if expired := sweep(1500); len(expired) != 0 {
+		t.Fatalf("items expired twice: %v", expired)
+	}
+	if expired := sweep(2000); len(expired) != 1 || expired[0].LendingId != 2 {
+		t.Fatalf("wrong expired items at 2000: %v", expired)
+	}
+	if id, amount, _ := lendingStateDB.GetBestLendingIdAndAmount(lendingBook, big.NewInt(5), lendingstate.Borrowing); id != items[3].Hash || amount.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("good-till-cancelled item not in the lending book: %v %v", id.Hex(), amount)
+	}
+}
+
+func TestSweepExpiredLendingItemsOfIdleBooks(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	var (
+		usdt, btc = common.HexToAddress("0x10"), common.HexToAddress("0x20")
+		usdtBook  = lendingstate.GetLendingOrderBookHash(usdt, 86400)
+		btcBook   = lendingstate.GetLendingOrderBookHash(btc, 86400)
+		chain     = &epochTestChain{}
+	)
+	setStateArray(statedb, state.GetLocSimpleVariable(lendingstate.SupportedBaseSlot), usdt.Hash(), btc.Hash())
+	setStateArray(statedb, state.GetLocSimpleVariable(lendingstate.SupportedTermSlot), common.Uint64ToHash(86400))
+
+	// no item is sent to the books, their good-till-time items expire with the blocks
+	for id, book := range []common.Hash{usdtBook, btcBook} {
+		item := &lendingstate.LendingItem{LendingId: uint64(id + 1), ExtraData: "GTT:1000", Quantity: big.NewInt(100), Interest: big.NewInt(5), Side: lendingstate.Investing, Type: lendingstate.Limit}
+		item.Hash = common.BigToHash(new(big.Int).SetUint64(item.LendingId))
+		insertLendingItem(lendingStateDB, book, item)
+		insertLendingExpiry(lendingStateDB, book, item)
+	}
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	preFork := &types.Header{Number: new(big.Int).Sub(common.TIPTomoXLendingV2, common.Big1), Time: big.NewInt(1500)}
+	if expired, err := l.SweepExpiredLendingItems(preFork, chain, statedb, lendingStateDB); err != nil || len(expired) != 0 {
+		t.Fatalf("items expired before TIPTomoXLendingV2: %v, %v", expired, err)
+	}
+	header := &types.Header{Number: common.TIPTomoXLendingV2, Time: big.NewInt(999)}
+	if expired, err := l.SweepExpiredLendingItems(header, chain, statedb, lendingStateDB); err != nil || len(expired) != 0 {
+		t.Fatalf("items expired too early: %v, %v", expired, err)
+	}
+	header.Time = big.NewInt(1500)
+	expired, err := l.SweepExpiredLendingItems(header, chain, statedb, lendingStateDB)
+	if err != nil {
+		t.Fatalf("failed to sweep: %v", err)
+	}
+	if len(expired) != 2 || expired[0].RejectReason != lendingstate.RejectReasonExpired || expired[1].RejectReason != lendingstate.RejectReasonExpired {
+		t.Fatalf("wrong expired items: %v", expired)
+	}
+	for _, book := range []common.Hash{usdtBook, btcBook} {
+		if rate, _ := lendingStateDB.GetBestInvestingRate(book); rate.Sign() != 0 {
+			t.Errorf("expired item still in lending book %x at rate %v", book, rate)
+		}
+	}
+}
+
+func TestPostOnlyItemCrosses(t *testing.T) {
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingBook := lendingstate.GetLendingOrderBookHash(common.HexToAddress(common.TomoNativeAddress), 86400)
+	if postOnlyItemCrosses(lendingStateDB, lendingBook, &lendingstate.LendingItem{Side: lendingstate.Borrowing, Interest: big.NewInt(5)}) {
+		t.Fatalf("post-only item crosses an empty lending book")
+	}
+	lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(1), lendingstate.LendingItem{LendingId: 1, Quantity: big.NewInt(1), Interest: big.NewInt(5), Side: lendingstate.Investing})
+	lendingStateDB.InsertLendingItem(lendingBook, common.Uint64ToHash(2), lendingstate.LendingItem{LendingId: 2, Quantity: big.NewInt(1), Interest: big.NewInt(3), Side: lendingstate.Borrowing})
+
+	tests := []struct {
+		side     string
+		interest int64
+		want     bool
+	}{
+		{lendingstate.Borrowing, 4, false},
+		{lendingstate.Borrowing, 5, true},
+		{lendingstate.Borrowing, 6, true},
+		{lendingstate.Investing, 4, false},
+		{lendingstate.Investing, 3, true},
+		{lendingstate.Investing, 2, true},
+	}
+	for _, tt := range tests {
+		item := &lendingstate.LendingItem{Side: tt.side, Interest: big.NewInt(tt.interest)}
+		if got := postOnlyItemCrosses(lendingStateDB, lendingBook, item); got != tt.want {
+			t.Errorf("side %s interest %d: have %v, want %v", tt.side, tt.interest, got, tt.want)
+		}
+	}
+}
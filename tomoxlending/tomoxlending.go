@@ -1,22 +1,28 @@
 package tomoxlending
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/tomochain/tomochain/accounts"
 	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core"
 	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
 	"github.com/tomochain/tomochain/p2p"
 	"github.com/tomochain/tomochain/tomox"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"github.com/tomochain/tomochain/tomoxDAO"
 	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"google.golang.org/grpc"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 	"math/big"
+	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
-	lru "github.com/hashicorp/golang-lru"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/log"
@@ -25,14 +31,18 @@ import (
 
 const (
 	ProtocolName       = "tomoxlending"
-	ProtocolVersion    = uint64(1)
-	ProtocolVersionStr = "1.0"
+	ProtocolVersion    = uint64(2)
+	ProtocolVersionStr = "2.0"
 	defaultCacheLimit  = 1024
+	lendingRootsLimit  = 4096 // number of lending state roots of blocks to keep in memory
 )
 
 var (
 	ErrNonceTooHigh = errors.New("nonce too high")
 	ErrNonceTooLow  = errors.New("nonce too low")
+	// ErrLendingBookPaused is returned for the items matching in a lending book paused by its
+	// circuit breaker, they stay in the pool until the pause is over.
+	ErrLendingBookPaused = errors.New("lending book paused by circuit breaker")
 )
 
 type Lending struct {
@@ -42,15 +52,72 @@ type Lending struct {
 	orderNonce map[common.Address]*big.Int
 
 	tomox               *tomox.TomoX
-	lendingItemHistory  *lru.Cache
-	lendingTradeHistory *lru.Cache
+	lendingItemHistory  *lendingCache
+	lendingTradeHistory *lendingCache
+	lendingRoots        *lendingCache // lending state roots of recent blocks, by block hash
+	sdkBackfillMisses   *lendingCache // hashes of the SDK records which couldn't be backfilled
+	lastHistoryPrune    time.Time
+
+	nonceLock sync.Mutex // serialises the lending nonce assignments, see nonce_manager.go
+
+	sdkSyncLock    sync.Mutex
+	sdkSyncCurrent *sdkSyncTask   // transaction being recorded to the SDK database
+	sdkSyncQueue   []*sdkSyncTask // transactions waiting to be replayed to the SDK database
+
+	stateSyncLock     sync.Mutex
+	stateSyncCh       chan *trieNodesDelivery // answers to the trie nodes requests of the running state sync
+	stateSyncProgress *StateSyncProgress
+
+	chain       blockChain
+	lendingPool lendingTxPool
+	peers       *peerSet
+
+	accountManager *accounts.Manager // keys signing the lending transactions generated by the node
+
+	lendingTxCh     chan core.LendingTxPreEvent
+	lendingTxSub    event.Subscription
+	orderBookFeed   event.Feed
+	tradeFeed       event.Feed
+	itemFeed        event.Feed
+	liquidationFeed event.Feed
+	scope           event.SubscriptionScope
+	grpc            *grpc.Server
+	rest            *http.Server
+	quit            chan struct{}
 }
 
+// Protocols returns the tomoxlending sub-protocol, gossiping lending orders, cancellations
+// and orderbook snapshots between full nodes.
 func (l *Lending) Protocols() []p2p.Protocol {
-	return []p2p.Protocol{}
+	return []p2p.Protocol{
+		{
+			Name:    ProtocolName,
+			Version: uint(ProtocolVersion),
+			Length:  ProtocolLength,
+			Run:     l.runPeer,
+		},
+	}
 }
 
 func (l *Lending) Start(server *p2p.Server) error {
+	if l.lendingPool != nil {
+		l.lendingTxCh = make(chan core.LendingTxPreEvent, lendingTxChanSize)
+		l.lendingTxSub = l.lendingPool.SubscribeTxPreEvent(l.lendingTxCh)
+		go l.lendingTxBroadcastLoop()
+	}
+	if l.tomox.IsSDKNode() {
+		go l.sdkSyncLoop()
+	}
+	if addr := l.tomox.LendingGRPC(); addr != "" {
+		if err := l.startGRPC(addr); err != nil {
+			return err
+		}
+	}
+	if addr := l.tomox.LendingREST(); addr != "" {
+		if err := l.startREST(addr); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -58,17 +125,37 @@ func (l *Lending) SaveData() {
 }
 
 func (l *Lending) Stop() error {
+	if l.lendingTxSub != nil {
+		l.lendingTxSub.Unsubscribe()
+	}
+	if l.grpc != nil {
+		l.grpc.Stop()
+	}
+	if l.rest != nil {
+		l.rest.Close()
+	}
+	l.scope.Close()
+	close(l.quit)
 	return nil
 }
 
 func New(tomox *tomox.TomoX) *Lending {
-	itemCache, _ := lru.New(defaultCacheLimit)
-	lendingTradeCache, _ := lru.New(defaultCacheLimit)
+	cacheLimit, rootsLimit := tomox.LendingCacheSizes()
+	if cacheLimit <= 0 {
+		cacheLimit = defaultCacheLimit
+	}
+	if rootsLimit <= 0 {
+		rootsLimit = lendingRootsLimit
+	}
 	lending := &Lending{
 		orderNonce:          make(map[common.Address]*big.Int),
 		Triegc:              prque.New(),
-		lendingItemHistory:  itemCache,
-		lendingTradeHistory: lendingTradeCache,
+		lendingItemHistory:  newLendingCache(itemHistoryCache, cacheLimit),
+		lendingTradeHistory: newLendingCache(tradeHistoryCache, cacheLimit),
+		lendingRoots:        newLendingCache(rootsCache, rootsLimit),
+		sdkBackfillMisses:   newLendingCache(backfillMissesCache, cacheLimit),
+		peers:               newPeerSet(),
+		quit:                make(chan struct{}),
 	}
 	lending.StateCache = lendingstate.NewDatabase(tomox.GetLevelDB())
 	lending.tomox = tomox
@@ -83,6 +170,11 @@ func (l *Lending) GetMongoDB() tomoxDAO.TomoXDAO {
 	return l.tomox.GetMongoDB()
 }
 
+// GetMongoReadDB returns the SDK database serving the read-only queries of the APIs.
+func (l *Lending) GetMongoReadDB() tomoxDAO.TomoXDAO {
+	return l.tomox.GetMongoReadDB()
+}
+
 // APIs returns the RPC descriptors the Lending implementation offers
 func (l *Lending) APIs() []rpc.API {
 	return []rpc.API{
@@ -92,6 +184,18 @@ func (l *Lending) APIs() []rpc.API {
 			Service:   NewPublicTomoXLendingAPI(l),
 			Public:    true,
 		},
+		{
+			Namespace: ProtocolName,
+			Version:   ProtocolVersionStr,
+			Service:   NewPrivateTomoXLendingAPI(l),
+			Public:    false,
+		},
+		{
+			Namespace: tomox.ProtocolName,
+			Version:   ProtocolVersionStr,
+			Service:   NewPublicTomoXEscrowAPI(l),
+			Public:    true,
+		},
 	}
 }
 
@@ -100,16 +204,35 @@ func (l *Lending) Version() uint64 {
 	return ProtocolVersion
 }
 
+// ProcessOrderPending matches the pending lending transactions. The transactions of independent
+// lending tokens are matched concurrently if the node is configured to (see processOrderGroups).
 func (l *Lending) ProcessOrderPending(header *types.Header, coinbase common.Address, chain consensus.ChainContext, pending map[common.Address]types.LendingTransactions, statedb *state.StateDB, lendingStatedb *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB) ([]*lendingstate.LendingItem, map[common.Hash]lendingstate.MatchingResult) {
+	// a bounded block is matched serially, the items it leaves over wouldn't be deterministic otherwise
+	budget := &matchBudget{limit: l.tomox.LendingMatchBudget()}
+	if workers := l.tomox.LendingMatchWorkers(); workers > 1 && budget.limit == 0 {
+		if groups := groupPendingByToken(pending); len(groups) > 1 {
+			return l.processOrderGroups(header, coinbase, chain, groups, workers, statedb, lendingStatedb, tradingStateDb)
+		}
+	}
+	return l.processOrders(header, coinbase, chain, pending, statedb, lendingStatedb, tradingStateDb, budget)
+}
+
+// processOrders matches the pending lending transactions one after the other, until the matching
+// budget of the block is spent.
+func (l *Lending) processOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, pending map[common.Address]types.LendingTransactions, statedb *state.StateDB, lendingStatedb *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, budget *matchBudget) ([]*lendingstate.LendingItem, map[common.Hash]lendingstate.MatchingResult) {
 	lendingItems := []*lendingstate.LendingItem{}
 	matchingResults := map[common.Hash]lendingstate.MatchingResult{}
 
-	txs := types.NewLendingTransactionByNonce(types.LendingTxSigner{}, pending)
+	txs := types.NewLendingTransactionByNonce(types.MakeLendingSigner(chain.Config(), header.Number), pending)
 	for {
 		tx := txs.Peek()
 		if tx == nil {
 			break
 		}
+		if budget.exhausted() {
+			log.Debug("Lending matching budget spent, leaving the remaining items to the next block", "spent", budget.spent, "items", len(lendingItems))
+			break
+		}
 		log.Debug("ProcessOrderPending start", "len", len(pending))
 		log.Debug("Get pending orders to process", "address", tx.UserAddress(), "nonce", tx.Nonce())
 		V, R, S := tx.Signature()
@@ -158,6 +281,7 @@ func (l *Lending) ProcessOrderPending(header *types.Header, coinbase common.Addr
 		}
 
 		newTrades, newRejectedOrders, err := l.CommitOrder(header, coinbase, chain, statedb, lendingStatedb, tradingStateDb, lendingstate.GetLendingOrderBookHash(order.LendingToken, order.Term), order)
+		budget.charge(newTrades, newRejectedOrders)
 		for _, reject := range newRejectedOrders {
 			log.Debug("Reject order", "reject", *reject)
 		}
@@ -175,6 +299,12 @@ func (l *Lending) ProcessOrderPending(header *types.Header, coinbase common.Addr
 			txs.Pop()
 			continue
 
+		case ErrLendingBookPaused:
+			// Matching paused in the lending book, keep the account's items queued
+			log.Debug("Skipping order account of paused lending book", "sender", tx.UserAddress(), "nonce", tx.Nonce())
+			txs.Pop()
+			continue
+
 		case nil:
 			// everything ok
 			txs.Shift()
@@ -204,12 +334,15 @@ func (l *Lending) ProcessOrderPending(header *types.Header, coinbase common.Addr
 // 2.a Update status, filledAmount of makerLendingItem
 // 2.b. Put lendingTrade to database
 // 3. Update status of rejected items
-func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state.StateDB, block *types.Block, takerLendingItem *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedItems []*lendingstate.LendingItem, dirtyOrderCount *uint64) error {
+// The round-trips to the SDK database give up once ctx is done.
+func (l *Lending) syncDataToSDKNode(ctx context.Context, chain consensus.ChainContext, statedb *state.StateDB, block *types.Block, takerLendingItem *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedItems []*lendingstate.LendingItem, dirtyOrderCount *uint64) error {
 	var (
 		// originTakerLendingItem: item getting from database
 		originTakerLendingItem, updatedTakerLendingItem *lendingstate.LendingItem
 		makerDirtyHashes                                []string
 		makerDirtyFilledAmount                          map[string]*big.Int
+		newTrades                                       []*lendingstate.LendingTrade
+		dirtyItems                                      []*lendingstate.LendingItem
 		err                                             error
 	)
 	db := l.GetMongoDB()
@@ -219,11 +352,25 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		log.Debug("Cancel order is rejected", "order", lendingstate.ToJSON(takerLendingItem))
 		return nil
 	}
+	if takerLendingItem.Status == lendingstate.LendingStatusAmended && isRejectedAmendment(takerLendingItem, rejectedItems) {
+		// amendment is rejected -> nothing change
+		log.Debug("Amendment is rejected", "order", lendingstate.ToJSON(takerLendingItem))
+		return nil
+	}
+	if isForeignLendingHash(takerLendingItem, rejectedItems) {
+		// replayed or forged hash -> the item with this hash is not changed
+		log.Debug("Lending item rejected for its hash", "order", lendingstate.ToJSON(takerLendingItem))
+		return nil
+	}
 	// 1. put processed takerLendingItem to database
 	lastState := lendingstate.LendingItemHistoryItem{}
 	// Typically, takerItem has never existed in database
 	// except cancel case: in this case, item existed in database with status = OPEN, then use send another lendingItem to cancel it
-	val, err := db.GetObject(takerLendingItem.Hash, &lendingstate.LendingItem{Type: takerLendingItem.Type})
+	val, err := db.GetObjectContext(ctx, takerLendingItem.Hash, &lendingstate.LendingItem{Type: takerLendingItem.Type})
+	if err != nil && ctx.Err() != nil {
+		// the item may exist, it can't be taken for a new one
+		return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKGetObject, TxHash: txHash, Hash: takerLendingItem.Hash, Err: err}
+	}
 	if err == nil && val != nil {
 		originTakerLendingItem = val.(*lendingstate.LendingItem)
 		lastState = lendingstate.LendingItemHistoryItem{
@@ -245,6 +392,8 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 	} else if takerLendingItem.Status == lendingstate.LendingStatusCancelled {
 		updatedTakerLendingItem.Status = lendingstate.LendingStatusCancelled
 		updatedTakerLendingItem.ExtraData = takerLendingItem.ExtraData
+	} else if takerLendingItem.Status == lendingstate.LendingStatusAmended {
+		amendLendingItemRecord(updatedTakerLendingItem, takerLendingItem)
 	}
 	updatedTakerLendingItem.TxHash = txHash
 	if updatedTakerLendingItem.CreatedAt.IsZero() {
@@ -269,10 +418,12 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		if tradeRecord == nil {
 			continue
 		}
-		if updatedTakerLendingItem.Type == lendingstate.Repay || updatedTakerLendingItem.Type == lendingstate.TopUp || updatedTakerLendingItem.Type == lendingstate.Recall {
+		if updatedTakerLendingItem.Type == lendingstate.Repay || updatedTakerLendingItem.Type == lendingstate.TopUp || updatedTakerLendingItem.Type == lendingstate.Recall || updatedTakerLendingItem.Type == lendingstate.AuctionBid || updatedTakerLendingItem.Type == lendingstate.AddCollateral || updatedTakerLendingItem.Type == lendingstate.Rollover {
 			// repay, topup: assign hash = trade.hash
 			updatedTakerLendingItem.Hash = tradeRecord.Hash
-			updatedTakerLendingItem.CollateralToken = tradeRecord.CollateralToken
+			if updatedTakerLendingItem.Type != lendingstate.AddCollateral {
+				updatedTakerLendingItem.CollateralToken = tradeRecord.CollateralToken
+			}
 			updatedTakerLendingItem.FilledAmount = updatedTakerLendingItem.Quantity
 			updatedTakerLendingItem.Interest = new(big.Int).SetUint64(tradeRecord.Interest)
 			switch updatedTakerLendingItem.Type {
@@ -288,15 +439,37 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				updatedTakerLendingItem.AutoTopUp = false
 			case lendingstate.Repay:
 				updatedTakerLendingItem.Status = lendingstate.Repay
-				paymentBalance := lendingstate.CalculateTotalRepayValue(block.Time().Uint64(), tradeRecord.LiquidationTime, tradeRecord.Term, tradeRecord.Interest, tradeRecord.Amount)
-				updatedTakerLendingItem.Quantity = paymentBalance
-				updatedTakerLendingItem.FilledAmount = paymentBalance
+				if tradeRecord.Status == lendingstate.TradeStatusOpen {
+					// partial repayment: the trade stays open, the item records the paid quantity
+					updatedTakerLendingItem.ExtraData = tradeRecord.ExtraData
+				} else {
+					paymentBalance := lendingstate.CalculateTotalRepayValue(block.Time().Uint64(), tradeRecord.LiquidationTime, tradeRecord.Term, tradeRecord.Interest, tradeRecord.Amount)
+					updatedTakerLendingItem.Quantity = paymentBalance
+					updatedTakerLendingItem.FilledAmount = paymentBalance
+				}
 				// manual repay item
 				updatedTakerLendingItem.AutoTopUp = false
 			case lendingstate.Recall:
 				updatedTakerLendingItem.Status = lendingstate.Recall
+				// the item records the collateral price and the health factor of the trade
+				updatedTakerLendingItem.ExtraData = tradeRecord.ExtraData
 				// manual recall item
 				updatedTakerLendingItem.AutoTopUp = false
+			case lendingstate.AuctionBid:
+				// the item records the collateral bought and the price paid
+				updatedTakerLendingItem.Status = lendingstate.AuctionBid
+				updatedTakerLendingItem.ExtraData = tradeRecord.ExtraData
+				updatedTakerLendingItem.AutoTopUp = false
+			case lendingstate.AddCollateral:
+				// the item records the total amount of the added collateral token
+				updatedTakerLendingItem.Status = lendingstate.AddCollateral
+				updatedTakerLendingItem.ExtraData = tradeRecord.ExtraData
+				updatedTakerLendingItem.AutoTopUp = false
+			case lendingstate.Rollover:
+				// the item records the highest interest accepted for the rollover
+				updatedTakerLendingItem.Status = lendingstate.Rollover
+				updatedTakerLendingItem.ExtraData = tradeRecord.ExtraData
+				updatedTakerLendingItem.AutoTopUp = false
 			}
 
 			log.Debug("UpdateLendingTrade:", "type", updatedTakerLendingItem.Type, "hash", tradeRecord.Hash.Hex(), "status", tradeRecord.Status, "tradeId", tradeRecord.TradeId)
@@ -311,6 +484,7 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		tradeRecord.TxHash = txHash
 		tradeRecord.Hash = tradeRecord.ComputeHash()
 		tradeList[tradeRecord.Hash] = tradeRecord
+		newTrades = append(newTrades, tradeRecord)
 
 		// 2.b. update status and filledAmount
 		filledAmount := new(big.Int)
@@ -318,25 +492,31 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 			filledAmount = lendingstate.CloneBigInt(tradeRecord.Amount)
 		}
 		// maker dirty order
-		makerFilledAmount := big.NewInt(0)
-		makerOrderHash := common.Hash{}
-		if updatedTakerLendingItem.Side == lendingstate.Borrowing {
-			makerOrderHash = tradeRecord.InvestingOrderHash
+		var makerOrderHashes []common.Hash
+		triggered := tradeRecord.InvestingOrderHash != updatedTakerLendingItem.Hash && tradeRecord.BorrowingOrderHash != updatedTakerLendingItem.Hash
+		if triggered {
+			// trade of a stop-limit item triggered by the taker: both items have already been recorded
+			makerOrderHashes = []common.Hash{tradeRecord.InvestingOrderHash, tradeRecord.BorrowingOrderHash}
+		} else if updatedTakerLendingItem.Side == lendingstate.Borrowing {
+			makerOrderHashes = []common.Hash{tradeRecord.InvestingOrderHash}
 		} else {
-			makerOrderHash = tradeRecord.BorrowingOrderHash
+			makerOrderHashes = []common.Hash{tradeRecord.BorrowingOrderHash}
 		}
-		if amount, ok := makerDirtyFilledAmount[makerOrderHash.Hex()]; ok {
-			makerFilledAmount = lendingstate.CloneBigInt(amount)
+		for _, makerOrderHash := range makerOrderHashes {
+			makerFilledAmount := big.NewInt(0)
+			if amount, ok := makerDirtyFilledAmount[makerOrderHash.Hex()]; ok {
+				makerFilledAmount = lendingstate.CloneBigInt(amount)
+			}
+			makerFilledAmount = new(big.Int).Add(makerFilledAmount, filledAmount)
+			makerDirtyFilledAmount[makerOrderHash.Hex()] = makerFilledAmount
+			makerDirtyHashes = append(makerDirtyHashes, makerOrderHash.Hex())
 		}
-		makerFilledAmount = new(big.Int).Add(makerFilledAmount, filledAmount)
-		makerDirtyFilledAmount[makerOrderHash.Hex()] = makerFilledAmount
-		makerDirtyHashes = append(makerDirtyHashes, makerOrderHash.Hex())
 
-		if updatedTakerLendingItem.Type == lendingstate.Limit || updatedTakerLendingItem.Type == lendingstate.Market {
+		if !triggered && (updatedTakerLendingItem.Type == lendingstate.Limit || updatedTakerLendingItem.Type == lendingstate.Market || updatedTakerLendingItem.Type == lendingstate.StopLimit || updatedTakerLendingItem.Type == lendingstate.Iceberg || updatedTakerLendingItem.Type == lendingstate.Margin) {
 			//updatedTakerOrder = l.updateMatchedOrder(updatedTakerOrder, filledAmount, txMatchTime, txHash)
 			//  update filledAmount, status of takerOrder
 			updatedTakerLendingItem.FilledAmount = new(big.Int).Add(updatedTakerLendingItem.FilledAmount, filledAmount)
-			if updatedTakerLendingItem.FilledAmount.Cmp(updatedTakerLendingItem.Quantity) < 0 && updatedTakerLendingItem.Type == lendingstate.Limit {
+			if updatedTakerLendingItem.FilledAmount.Cmp(updatedTakerLendingItem.Quantity) < 0 && updatedTakerLendingItem.Type != lendingstate.Market {
 				updatedTakerLendingItem.Status = lendingstate.LendingStatusPartialFilled
 			} else {
 				updatedTakerLendingItem.Status = lendingstate.LendingStatusFilled
@@ -344,13 +524,13 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		}
 	}
 	if err := l.UpdateLendingTrade(tradeList, txHash, txMatchTime); err != nil {
-		return err
+		return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKPutObject, TxHash: txHash, Err: err}
 	}
 
-	// for Market orders
+	// for Market orders, and Margin orders whose borrow is a market order
 	// filledAmount > 0 : FILLED
 	// otherwise: REJECTED
-	if updatedTakerLendingItem.Type == lendingstate.Market {
+	if updatedTakerLendingItem.Type == lendingstate.Market || updatedTakerLendingItem.Type == lendingstate.Margin {
 		if updatedTakerLendingItem.FilledAmount.Sign() > 0 {
 			updatedTakerLendingItem.Status = lendingstate.LendingStatusFilled
 		} else {
@@ -363,13 +543,17 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		"Interest", updatedTakerLendingItem.Interest, "quantity", updatedTakerLendingItem.Quantity, "filledAmount", updatedTakerLendingItem.FilledAmount, "status", updatedTakerLendingItem.Status,
 		"hash", updatedTakerLendingItem.Hash.Hex(), "txHash", updatedTakerLendingItem.TxHash.Hex())
 
-	if !(updatedTakerLendingItem.Type == lendingstate.Repay || updatedTakerLendingItem.Type == lendingstate.TopUp || updatedTakerLendingItem.Type == lendingstate.Recall) || updatedTakerLendingItem.Status != lendingstate.LendingStatusOpen {
+	if !(updatedTakerLendingItem.Type == lendingstate.Repay || updatedTakerLendingItem.Type == lendingstate.TopUp || updatedTakerLendingItem.Type == lendingstate.Recall || updatedTakerLendingItem.Type == lendingstate.AuctionBid || updatedTakerLendingItem.Type == lendingstate.AddCollateral || updatedTakerLendingItem.Type == lendingstate.Rollover) || updatedTakerLendingItem.Status != lendingstate.LendingStatusOpen {
 		if err := db.PutObject(updatedTakerLendingItem.Hash, updatedTakerLendingItem); err != nil {
-			return fmt.Errorf("SDKNode: failed to put processed takerOrder. Hash: %s Error: %s", updatedTakerLendingItem.Hash.Hex(), err.Error())
+			return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKPutObject, TxHash: txHash, Hash: updatedTakerLendingItem.Hash, Err: err}
 		}
 	}
+	dirtyItems = append(dirtyItems, updatedTakerLendingItem)
 
-	items := db.GetListItemByHashes(makerDirtyHashes, &lendingstate.LendingItem{})
+	items, err := db.GetListItemByHashesContext(ctx, makerDirtyHashes, &lendingstate.LendingItem{})
+	if err != nil {
+		return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKGetObject, TxHash: txHash, Err: err}
+	}
 	if items != nil {
 		makerItems := items.([]*lendingstate.LendingItem)
 		log.Debug("Maker dirty lendingItem", "len", len(makerItems), "txhash", txHash.Hex())
@@ -398,8 +582,9 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				"Interest", m.Interest, "quantity", m.Quantity, "filledAmount", m.FilledAmount, "status", m.Status,
 				"hash", m.Hash.Hex(), "txHash", m.TxHash.Hex())
 			if err := db.PutObject(m.Hash, m); err != nil {
-				return fmt.Errorf("SDKNode: failed to put processed makerOrder. Hash: %s Error: %s", m.Hash.Hex(), err.Error())
+				return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKPutObject, TxHash: txHash, Hash: m.Hash, Err: err}
 			}
+			dirtyItems = append(dirtyItems, m)
 		}
 	}
 
@@ -408,9 +593,11 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 
 	if len(rejectedItems) > 0 {
 		var rejectedHashes []string
+		rejectReasons := make(map[common.Hash]string, len(rejectedItems))
 		// updateRejectedOrders
 		for _, r := range rejectedItems {
 			rejectedHashes = append(rejectedHashes, r.Hash.Hex())
+			rejectReasons[r.Hash] = r.RejectReason
 			if updatedTakerLendingItem.Hash == r.Hash && !txMatchTime.Before(r.UpdatedAt) {
 				// cache r history for handling reorg
 				historyRecord := lendingstate.LendingItemHistoryItem{
@@ -427,14 +614,18 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				} else {
 					updatedTakerLendingItem.Status = lendingstate.LendingStatusReject
 				}
+				updatedTakerLendingItem.RejectReason = r.RejectReason
 				updatedTakerLendingItem.TxHash = txHash
 				updatedTakerLendingItem.UpdatedAt = txMatchTime
 				if err := db.PutObject(updatedTakerLendingItem.Hash, updatedTakerLendingItem); err != nil {
-					return fmt.Errorf("SDKNode: failed to reject takerOrder. Hash: %s Error: %s", updatedTakerLendingItem.Hash.Hex(), err.Error())
+					return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKPutObject, TxHash: txHash, Hash: updatedTakerLendingItem.Hash, Err: err}
 				}
 			}
 		}
-		items := db.GetListItemByHashes(rejectedHashes, &lendingstate.LendingItem{})
+		items, err := db.GetListItemByHashesContext(ctx, rejectedHashes, &lendingstate.LendingItem{})
+		if err != nil {
+			return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKGetObject, TxHash: txHash, Err: err}
+		}
 		if items != nil {
 			dirtyRejectedItems := items.([]*lendingstate.LendingItem)
 			for _, r := range dirtyRejectedItems {
@@ -461,18 +652,38 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				} else {
 					r.Status = lendingstate.LendingStatusReject
 				}
+				r.RejectReason = rejectReasons[r.Hash]
 				r.TxHash = txHash
 				r.UpdatedAt = txMatchTime
 				if err = db.PutObject(r.Hash, r); err != nil {
-					return fmt.Errorf("SDKNode: failed to update rejectedOder to sdkNode %s", err.Error())
+					return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKPutObject, TxHash: txHash, Hash: r.Hash, Err: err}
 				}
+				dirtyItems = append(dirtyItems, r)
 			}
 		}
 	}
 
-	if err := db.CommitLendingBulk(); err != nil {
-		return fmt.Errorf("SDKNode fail to commit bulk update lendingItem/lendingTrades at txhash %s . Error: %s", txHash.Hex(), err.Error())
+	// 4. put the insurance fund records: contributions of the new trades, draw of an auction bid
+	var contributions, auctions []*lendingstate.LendingTrade
+	if len(newTrades) > 0 && chain.Config().IsTIPTomoXLendingV2(block.Number()) {
+		contributions = newTrades
+	}
+	if updatedTakerLendingItem.Type == lendingstate.AuctionBid {
+		auctions = trades
+	}
+	if err := putInsuranceRecords(db, insuranceRecords(contributions, auctions, txHash, txMatchTime)); err != nil {
+		return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKPutObject, TxHash: txHash, Err: err}
+	}
+
+	if err := db.CommitLendingBulkContext(ctx); err != nil {
+		return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKCommitBulk, TxHash: txHash, Err: err}
 	}
+	if err := l.updateCandles(candleTimes(newTrades)); err != nil {
+		return &lendingstate.SDKSyncError{Kind: lendingstate.ErrSDKCommitBulk, TxHash: txHash, Err: err}
+	}
+	l.saveLendingHistory(txHash, txMatchTime)
+	l.postLendingTrades(newTrades)
+	l.postLendingItems(dirtyItems)
 	return nil
 }
 
@@ -486,6 +697,11 @@ func (l *Lending) UpdateLiquidatedTrade(blockTime uint64, result lendingstate.Fi
 		return err
 	}
 
+	// update the investing items filled by rollover trades
+	if err := l.updateRolloverInvestingItems(trades, txhash, txTime); err != nil {
+		return err
+	}
+
 	// adding auto repay transaction
 	if len(result.AutoRepay) > 0 {
 		for _, hash := range result.AutoRepay {
@@ -615,10 +831,22 @@ func (l *Lending) UpdateLiquidatedTrade(blockTime uint64, result lendingstate.Fi
 		}
 	}
 
+	// adding the insurance fund draws of the settled auctions
+	auctions := []*lendingstate.LendingTrade{}
+	for _, trade := range trades {
+		if trade.Status == lendingstate.TradeStatusLiquidated {
+			auctions = append(auctions, trade)
+		}
+	}
+	if err := putInsuranceRecords(db, insuranceRecords(nil, auctions, txhash, txTime)); err != nil {
+		return err
+	}
+
 	if err := db.CommitLendingBulk(); err != nil {
 		return fmt.Errorf("failed to updateLendingTrade . Err: %v", err)
 	}
-
+	l.saveLendingHistory(txhash, txTime)
+	l.postLiquidations(trades)
 	return nil
 }
 
@@ -632,12 +860,15 @@ func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.Lendin
 		hashQuery = append(hashQuery, trade.Hash.Hex())
 	}
 	items := db.GetListItemByHashes(hashQuery, &lendingstate.LendingTrade{})
+	updated := map[common.Hash]bool{}
 	if items != nil && len(items.([]*lendingstate.LendingTrade)) > 0 {
 		for _, trade := range items.([]*lendingstate.LendingTrade) {
+			updated[trade.Hash] = true
 			history := lendingstate.LendingTradeHistoryItem{
 				TxHash:                 trade.TxHash,
 				CollateralLockedAmount: trade.CollateralLockedAmount,
 				LiquidationPrice:       trade.LiquidationPrice,
+				Amount:                 trade.Amount,
 				Status:                 trade.Status,
 				UpdatedAt:              trade.UpdatedAt,
 			}
@@ -647,6 +878,7 @@ func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.Lendin
 
 			newTrade := trades[trade.Hash]
 			trade.CollateralLockedAmount = newTrade.CollateralLockedAmount
+			trade.Amount = newTrade.Amount
 			trade.Status = newTrade.Status
 			trade.LiquidationPrice = newTrade.LiquidationPrice
 			trade.ExtraData = newTrade.ExtraData
@@ -656,12 +888,68 @@ func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.Lendin
 			}
 		}
 		log.Debug("UpdateLendingTrade successfully", "txhash", txhash, "hash", hashQuery)
-	} else {
-		// not update, just upsert
-		for _, trade := range trades {
-			if err := db.PutObject(trade.Hash, trade); err != nil {
-				return err
-			}
+	}
+	// not update, just upsert the trades created by this transaction (e.g: rollover)
+	for _, trade := range trades {
+		if updated[trade.Hash] {
+			continue
+		}
+		if trade.TxHash == (common.Hash{}) {
+			trade.TxHash = txhash
+			trade.CreatedAt = txTime
+			trade.UpdatedAt = txTime
+		}
+		if err := db.PutObject(trade.Hash, trade); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRolloverInvestingItems adds the amounts of the trades created by rollovers to the filled
+// amounts of their investing items.
+func (l *Lending) updateRolloverInvestingItems(trades map[common.Hash]*lendingstate.LendingTrade, txhash common.Hash, txTime time.Time) error {
+	db := l.GetMongoDB()
+	makerHashes := []string{}
+	makerFilledAmount := map[common.Hash]*big.Int{}
+	for _, trade := range trades {
+		if trade.TakerOrderType != lendingstate.Rollover || trade.Status != lendingstate.TradeStatusOpen {
+			continue
+		}
+		if _, ok := makerFilledAmount[trade.InvestingOrderHash]; !ok {
+			makerHashes = append(makerHashes, trade.InvestingOrderHash.Hex())
+			makerFilledAmount[trade.InvestingOrderHash] = new(big.Int)
+		}
+		makerFilledAmount[trade.InvestingOrderHash].Add(makerFilledAmount[trade.InvestingOrderHash], trade.Amount)
+	}
+	if len(makerHashes) == 0 {
+		return nil
+	}
+	items := db.GetListItemByHashes(makerHashes, &lendingstate.LendingItem{})
+	if items == nil {
+		return nil
+	}
+	for _, m := range items.([]*lendingstate.LendingItem) {
+		if txTime.Before(m.UpdatedAt) {
+			continue
+		}
+		lastState := lendingstate.LendingItemHistoryItem{
+			TxHash:       m.TxHash,
+			FilledAmount: lendingstate.CloneBigInt(m.FilledAmount),
+			Status:       m.Status,
+			UpdatedAt:    m.UpdatedAt,
+		}
+		l.UpdateLendingItemCache(m.LendingToken, m.CollateralToken, m.Hash, txhash, lastState)
+		m.TxHash = txhash
+		m.UpdatedAt = txTime
+		m.FilledAmount = new(big.Int).Add(m.FilledAmount, makerFilledAmount[m.Hash])
+		if m.FilledAmount.Cmp(m.Quantity) < 0 {
+			m.Status = lendingstate.LendingStatusPartialFilled
+		} else {
+			m.Status = lendingstate.LendingStatusFilled
+		}
+		if err := db.PutObject(m.Hash, m); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -682,6 +970,138 @@ func (l *Lending) GetLendingState(block *types.Block, author common.Address) (*l
 	return state, err
 }
 
+// currentLendingState returns the current block of the injected chain along with its lending state.
+func (l *Lending) currentLendingState() (*types.Block, *lendingstate.LendingStateDB, error) {
+	if l.chain == nil {
+		return nil, nil, errLendingStateUnavailable
+	}
+	block := l.chain.CurrentBlock()
+	if block == nil {
+		return nil, nil, errors.New("Current block not found")
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, nil, err
+	}
+	lendingState, err := l.GetLendingState(block, author)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, lendingState, nil
+}
+
+// lendingSigner returns the signer of the lending transactions mined in the next block.
+func (l *Lending) lendingSigner() types.LendingSigner {
+	if l.chain == nil || l.chain.CurrentBlock() == nil {
+		return types.LendingTxSigner{}
+	}
+	return types.MakeLendingSigner(l.chain.Config(), new(big.Int).Add(l.chain.CurrentBlock().Number(), common.Big1))
+}
+
+// lendingTxSigner returns the LendingTxSigner hashing the lending transactions mined in the next block.
+func (l *Lending) lendingTxSigner() types.LendingTxSigner {
+	if l.chain == nil || l.chain.CurrentBlock() == nil {
+		return types.LendingTxSigner{}
+	}
+	return types.MakeLendingTxSigner(l.chain.Config(), new(big.Int).Add(l.chain.CurrentBlock().Number(), common.Big1))
+}
+
+// blockByNumberOrHash returns the block selected by number or by hash, the current block for
+// rpc.LatestBlockNumber and rpc.PendingBlockNumber.
+func (l *Lending) blockByNumberOrHash(blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if l.chain == nil {
+		return nil, errLendingStateUnavailable
+	}
+	var block *types.Block
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block = l.chain.GetBlockByHash(hash)
+		if block == nil {
+			return nil, fmt.Errorf("block %x not found", hash)
+		}
+		return block, nil
+	}
+	blockNr, _ := blockNrOrHash.Number()
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+		block = l.chain.CurrentBlock()
+	} else {
+		block = l.chain.GetBlockByNumber(uint64(blockNr.Int64()))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", blockNr.Int64())
+	}
+	return block, nil
+}
+
+// lendingStateAt returns the block with the given number, the latest one for rpc.LatestBlockNumber,
+// along with its lending state.
+func (l *Lending) lendingStateAt(blockNr rpc.BlockNumber) (*types.Block, *lendingstate.LendingStateDB, error) {
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+		return l.currentLendingState()
+	}
+	if l.chain == nil {
+		return nil, nil, errLendingStateUnavailable
+	}
+	block := l.chain.GetBlockByNumber(uint64(blockNr.Int64()))
+	if block == nil {
+		return nil, nil, fmt.Errorf("block %d not found", blockNr.Int64())
+	}
+	author, err := l.chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, nil, err
+	}
+	lendingState, err := l.GetLendingState(block, author)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, lendingState, nil
+}
+
+// findLendingTrade looks up a lending trade by hash in the lending state of a block. SDK nodes read
+// the lending book and trade id of the trade from their database, backfilling it if missing, other
+// nodes scan all lending books.
+func (l *Lending) findLendingTrade(block *types.Block, lendingState *lendingstate.LendingStateDB, hash common.Hash) (*lendingstate.LendingTrade, error) {
+	if l.tomox.IsSDKNode() {
+		record, err := l.getSDKLendingTrade(hash)
+		if err != nil {
+			return nil, err
+		}
+		lendingBook := lendingstate.GetLendingOrderBookHash(record.LendingToken, record.Term)
+		trade := lendingState.GetLendingTrade(lendingBook, common.Uint64ToHash(record.TradeId))
+		if trade == lendingstate.EmptyLendingTrade || trade.Hash != hash {
+			return nil, errLendingTradeNotFound
+		}
+		return &trade, nil
+	}
+	return l.scanLendingTrade(block, lendingState, hash)
+}
+
+// scanLendingTrade looks up a lending trade by hash in all the lending books of the lending state of a block.
+func (l *Lending) scanLendingTrade(block *types.Block, lendingState *lendingstate.LendingStateDB, hash common.Hash) (*lendingstate.LendingTrade, error) {
+	statedb, err := l.chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	lendingBooks, err := lendingstate.GetAllLendingBooks(statedb)
+	if err != nil {
+		return nil, err
+	}
+	for lendingBook := range lendingBooks {
+		if !lendingState.Exist(lendingBook) {
+			continue
+		}
+		trades, err := lendingState.DumpLendingTradeTrie(lendingBook)
+		if err != nil {
+			return nil, err
+		}
+		for _, trade := range trades {
+			if trade.Hash == hash {
+				return &trade, nil
+			}
+		}
+	}
+	return nil, errLendingTradeNotFound
+}
+
 func (l *Lending) GetStateCache() lendingstate.Database {
 	return l.StateCache
 }
@@ -702,16 +1122,28 @@ func (l *Lending) GetTriegc() *prque.Prque {
 	return l.Triegc
 }
 
+// LendingStateRetention returns whether the lending state tries are never garbage collected,
+// and otherwise the number of recent epochs whose lending state is kept.
+func (l *Lending) LendingStateRetention() (bool, uint64) {
+	return l.tomox.LendingStateRetention()
+}
+
 func (l *Lending) GetLendingStateRoot(block *types.Block, author common.Address) (common.Hash, error) {
+	if root, ok := l.lendingRoots.Get(block.Hash()); ok {
+		return root.(common.Hash), nil
+	}
+	root := lendingstate.EmptyRoot
 	for _, tx := range block.Transactions() {
 		from := *(tx.From())
 		if tx.To() != nil && tx.To().Hex() == common.TradingStateAddr && from.String() == author.String() {
 			if len(tx.Data()) >= 64 {
-				return common.BytesToHash(tx.Data()[32:]), nil
+				root = common.BytesToHash(tx.Data()[32:])
+				break
 			}
 		}
 	}
-	return lendingstate.EmptyRoot, nil
+	l.lendingRoots.Add(block.Hash(), root)
+	return root, nil
 }
 
 func (l *Lending) UpdateLendingItemCache(LendingToken, CollateralToken common.Address, hash common.Hash, txhash common.Hash, lastState lendingstate.LendingItemHistoryItem) {
@@ -746,15 +1178,23 @@ func (l *Lending) UpdateLendingTradeCache(hash common.Hash, txhash common.Hash,
 }
 
 func (l *Lending) RollbackLendingData(txhash common.Hash) error {
+	return l.rollbackLendingData(context.Background(), txhash)
+}
+
+// rollbackLendingData is RollbackLendingData giving up on the SDK database once ctx is done.
+func (l *Lending) rollbackLendingData(ctx context.Context, txhash common.Hash) error {
 	db := l.GetMongoDB()
 	db.InitLendingBulk()
 
 	// rollback lendingItem
-	items := db.GetListItemByTxHash(txhash, &lendingstate.LendingItem{})
+	items, err := db.GetListItemByTxHashContext(ctx, txhash, &lendingstate.LendingItem{})
+	if err != nil {
+		return fmt.Errorf("failed to RollbackLendingData. %v", err)
+	}
 	if items != nil {
 		for _, item := range items.([]*lendingstate.LendingItem) {
-			c, ok := l.lendingItemHistory.Get(txhash)
-			log.Debug("tomoxlending reorg: rollback lendingItem", "txhash", txhash.Hex(), "item", lendingstate.ToJSON(item), "lendingItemHistory", c)
+			cacheAtTxHash, ok := l.getLendingItemHistory(txhash)
+			log.Debug("tomoxlending reorg: rollback lendingItem", "txhash", txhash.Hex(), "item", lendingstate.ToJSON(item), "lendingItemHistory", cacheAtTxHash)
 			if !ok {
 				log.Debug("tomoxlending reorg: remove item due to no lendingItemHistory", "item", lendingstate.ToJSON(item))
 				if err := db.DeleteObject(item.Hash, &lendingstate.LendingItem{}); err != nil {
@@ -762,7 +1202,6 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 				}
 				continue
 			}
-			cacheAtTxHash := c.(map[common.Hash]lendingstate.LendingItemHistoryItem)
 			lendingItemHistory, _ := cacheAtTxHash[lendingstate.GetLendingItemHistoryKey(item.LendingToken, item.CollateralToken, item.Hash)]
 			if (lendingItemHistory == lendingstate.LendingItemHistoryItem{}) {
 				log.Debug("tomoxlending reorg: remove item due to empty lendingItemHistory", "item", lendingstate.ToJSON(item))
@@ -775,6 +1214,9 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 			item.Status = lendingItemHistory.Status
 			item.FilledAmount = lendingstate.CloneBigInt(lendingItemHistory.FilledAmount)
 			item.UpdatedAt = lendingItemHistory.UpdatedAt
+			if item.Status != lendingstate.LendingStatusReject {
+				item.RejectReason = ""
+			}
 			log.Debug("tomoxlending reorg: update item to the last lendingItemHistory", "item", lendingstate.ToJSON(item), "lendingItemHistory", lendingItemHistory)
 			if err := db.PutObject(item.Hash, item); err != nil {
 				return fmt.Errorf("failed to update reorg LendingItem. Err: %v . Item: %s", err.Error(), lendingstate.ToJSON(item))
@@ -783,11 +1225,16 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 	}
 
 	// rollback lendingTrade
-	items = db.GetListItemByTxHash(txhash, &lendingstate.LendingTrade{})
+	var candleTrades []*lendingstate.LendingTrade
+	items, err = db.GetListItemByTxHashContext(ctx, txhash, &lendingstate.LendingTrade{})
+	if err != nil {
+		return fmt.Errorf("failed to RollbackLendingData. %v", err)
+	}
 	if items != nil {
-		for _, trade := range items.([]*lendingstate.LendingTrade) {
-			c, ok := l.lendingTradeHistory.Get(txhash)
-			log.Debug("tomoxlending reorg: rollback LendingTrade", "txhash", txhash.Hex(), "trade", lendingstate.ToJSON(trade), "LendingTradeHistory", c)
+		candleTrades = items.([]*lendingstate.LendingTrade)
+		for _, trade := range candleTrades {
+			cacheAtTxHash, ok := l.getLendingTradeHistory(txhash)
+			log.Debug("tomoxlending reorg: rollback LendingTrade", "txhash", txhash.Hex(), "trade", lendingstate.ToJSON(trade), "LendingTradeHistory", cacheAtTxHash)
 			if !ok {
 				log.Debug("tomoxlending reorg: remove trade due to no LendingTradeHistory", "trade", lendingstate.ToJSON(trade))
 				if err := db.DeleteObject(trade.Hash, &lendingstate.LendingTrade{}); err != nil {
@@ -795,7 +1242,6 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 				}
 				continue
 			}
-			cacheAtTxHash := c.(map[common.Hash]lendingstate.LendingTradeHistoryItem)
 			lendingTradeHistoryItem, _ := cacheAtTxHash[trade.Hash]
 			if (lendingTradeHistoryItem == lendingstate.LendingTradeHistoryItem{}) {
 				log.Debug("tomoxlending reorg: remove trade due to empty LendingTradeHistory", "trade", lendingstate.ToJSON(trade))
@@ -808,6 +1254,9 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 			trade.Status = lendingTradeHistoryItem.Status
 			trade.CollateralLockedAmount = lendingstate.CloneBigInt(lendingTradeHistoryItem.CollateralLockedAmount)
 			trade.LiquidationPrice = lendingstate.CloneBigInt(lendingTradeHistoryItem.LiquidationPrice)
+			if lendingTradeHistoryItem.Amount != nil {
+				trade.Amount = lendingstate.CloneBigInt(lendingTradeHistoryItem.Amount)
+			}
 			trade.UpdatedAt = lendingTradeHistoryItem.UpdatedAt
 			log.Debug("tomoxlending reorg: update trade to the last lendingTradeHistoryItem", "trade", lendingstate.ToJSON(trade), "lendingTradeHistoryItem", lendingTradeHistoryItem)
 			if err := db.PutObject(trade.Hash, trade); err != nil {
@@ -816,17 +1265,40 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 		}
 	}
 
-	// remove repay/topup/recall history
+	// remove repay/topup/recall/auction bid/add collateral/rollover history and insurance fund records
 	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.Repay})
 	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.TopUp})
 	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.Recall})
+	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.AuctionBid})
+	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.AddCollateral})
+	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.Rollover})
+	db.DeleteItemByTxHash(txhash, &lendingstate.LendingInsuranceRecord{})
 
-	if err := db.CommitLendingBulk(); err != nil {
+	if err := db.CommitLendingBulkContext(ctx); err != nil {
+		return fmt.Errorf("failed to RollbackLendingData. %v", err)
+	}
+	// rebuild the candles of the removed trades
+	if err := l.updateCandles(candleTimes(candleTrades)); err != nil {
 		return fmt.Errorf("failed to RollbackLendingData. %v", err)
 	}
 	return nil
 }
 
+// ProcessLiquidationData closes the lending trades which are due at the given header:
+//   - trades whose liquidation time has passed are repaid, or liquidated if the borrower can't pay
+//   - trades whose liquidation price is above the collateral price are topped up automatically
+//     if requested or if the borrower's top-up reserve covers the deposit, otherwise liquidated:
+//     the locked collateral is transferred to the investor
+//   - trades whose collateral price rose above the recall rate release the extra collateral
+//
+// Since TIPTomoXLendingV2 the collateral of liquidated trades is sold by a liquidation auction
+// (see auction.go) instead, and the unsold collateral of expired auctions goes to the investor.
+// Since TIPTomoXPeriodicInterest the open trades of lending books with periodic interest settle
+// their interest first (see funding.go).
+// Since TIPTomoXPriceOracle the collateral tokens with a price oracle are priced by their oracle
+// (see oracle.go).
+//
+// The returned trades are recorded to the SDK node by UpdateLiquidatedTrade.
 func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) (updatedTrades map[common.Hash]*lendingstate.LendingTrade, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades []*lendingstate.LendingTrade, err error) {
 	time := header.Time
 	updatedTrades = map[common.Hash]*lendingstate.LendingTrade{} // sum of liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades
@@ -846,12 +1318,48 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 		return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, nil
 	}
 
+	if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		// seize the unsold collateral of expired liquidation auctions
+		for lendingBook := range allLendingBooks {
+			settledAuctions, err := l.settleExpiredAuctions(header, chain, lendingState, statedb, lendingBook)
+			if err != nil {
+				log.Error("Fail when settle liquidation auctions", "time", time, "lendingBook", lendingBook.Hex(), "error", err)
+				return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+			}
+			for _, auction := range settledAuctions {
+				liquidatedTrades = append(liquidatedTrades, auction)
+				updatedTrades[auction.Hash] = auction
+			}
+		}
+	}
+
+	if chain.Config().IsTIPTomoXPeriodicInterest(header.Number) {
+		for lendingBook := range allLendingBooks {
+			if err := settlePeriodicInterest(header, lendingState, statedb, lendingBook); err != nil {
+				log.Error("Fail when settle periodic interest", "time", time, "lendingBook", lendingBook.Hex(), "error", err)
+				return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+			}
+		}
+	}
+
 	// liquidate trades by time
 	for lendingBook := range allLendingBooks {
 		lowestTime, tradingIds := lendingState.GetLowestLiquidationTime(lendingBook, time)
 		log.Debug("ProcessLiquidationData time", "tradeIds", len(tradingIds))
 		for lowestTime.Sign() > 0 && lowestTime.Cmp(time) < 0 {
 			for _, tradingId := range tradingIds {
+				if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+					closedTrade, newTrades, err := l.rolloverLendingTrade(header, lendingState, statedb, tradingState, lendingBook, tradingId.Big().Uint64())
+					if err != nil {
+						log.Debug("Can not roll over lending trade", "lendingBook", lendingBook.Hex(), "tradingId", tradingId.Hex(), "err", err)
+					} else if closedTrade != nil {
+						updatedTrades[closedTrade.Hash] = closedTrade
+						for _, newTrade := range newTrades {
+							updatedTrades[newTrade.Hash] = newTrade
+						}
+						continue
+					}
+				}
 				log.Debug("ProcessRepay", "lowestTime", lowestTime, "time", time, "lendingBook", lendingBook.Hex(), "tradingId", tradingId.Hex())
 				trade, err := l.ProcessRepayLendingTrade(header, chain, lendingState, statedb, tradingState, lendingBook, tradingId.Big().Uint64())
 				if err != nil {
@@ -860,7 +1368,7 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 				}
 				if trade != nil && trade.Hash != (common.Hash{}) {
 					updatedTrades[trade.Hash] = trade
-					if trade.Status == lendingstate.TradeStatusLiquidated {
+					if trade.Status == lendingstate.TradeStatusLiquidated || trade.Status == lendingstate.TradeStatusAuction {
 						liquidatedTrades = append(liquidatedTrades, trade)
 					} else if trade.Status == lendingstate.TradeStatusClosed {
 						autoRepayTrades = append(autoRepayTrades, trade)
@@ -871,9 +1379,19 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 		}
 	}
 
+	if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+		// follow the prices of the extra collaterals before liquidating trades by price
+		for lendingBook := range allLendingBooks {
+			if err := l.updateWeightedLiquidationPrices(header, chain, lendingState, statedb, tradingState, lendingBook); err != nil {
+				log.Error("Fail when update weighted liquidation prices", "time", time, "lendingBook", lendingBook.Hex(), "error", err)
+				return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+			}
+		}
+	}
+
 	for _, lendingPair := range allPairs {
 		orderbook := tradingstate.GetTradingOrderBookHash(lendingPair.CollateralToken, lendingPair.LendingToken)
-		_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingState, lendingPair.CollateralToken, lendingPair.LendingToken)
+		_, collateralPrice, err := l.GetLiquidationPrices(header, chain, statedb, tradingState, lendingState, lendingPair.CollateralToken, lendingPair.LendingToken)
 		if err != nil || collateralPrice == nil || collateralPrice.Sign() == 0 {
 			log.Error("Fail when get price collateral/lending ", "CollateralToken", lendingPair.CollateralToken.Hex(), "LendingToken", lendingPair.LendingToken.Hex(), "error", err)
 			// ignore this pair, do not throw error
@@ -893,6 +1411,24 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 							updatedTrades[newTrade.Hash] = newTrade
 							continue
 						}
+					} else if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+						if newTrade, err := l.ReserveTopUp(statedb, tradingState, lendingState, lendingBook, tradingIdHash, collateralPrice); err == nil {
+							log.Debug("ReserveTopUp", "borrower", trade.Borrower.Hex(), "collateral", newTrade.CollateralToken.Hex(), "tradingIdHash", tradingIdHash.Hex(), "newLockedAmount", newTrade.CollateralLockedAmount)
+							autoTopUpTrades = append(autoTopUpTrades, newTrade)
+							updatedTrades[newTrade.Hash] = newTrade
+							continue
+						}
+					}
+					if chain.Config().IsTIPTomoXLendingV2(header.Number) {
+						log.Debug("Liquidation auction", "highestLiquidatePrice", highestLiquidatePrice, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex())
+						newTrade, err := l.openLiquidationAuction(header, lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64(), collateralPrice, lendingstate.LiquidatedByPrice)
+						if err != nil {
+							log.Error("Fail when open liquidation auction", "time", time, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "error", err)
+							return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+						}
+						liquidatedTrades = append(liquidatedTrades, newTrade)
+						updatedTrades[newTrade.Hash] = newTrade
+						continue
 					}
 					log.Debug("LiquidationTrade", "highestLiquidatePrice", highestLiquidatePrice, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex())
 					newTrade, err := l.LiquidationTrade(lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64())
@@ -932,7 +1468,8 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 						log.Debug("Process Recall", "price", price, "lendingBook", lendingBook, "tradingIdHash", tradingIdHash.Hex())
 						trade := lendingState.GetLendingTrade(lendingBook, tradingIdHash)
 						log.Debug("TestRecall", "borrower", trade.Borrower.Hex(), "lendingToken", trade.LendingToken.Hex(), "collateral", trade.CollateralToken.Hex(), "price", price, "tradingIdHash", tradingIdHash.Hex())
-						if trade.AutoTopUp {
+						// the weighted liquidation price of a multi-collateral trade doesn't allow to recall its main collateral
+						if trade.AutoTopUp && !isMultiCollateralTrade(lendingState, lendingBook, trade.TradeId) {
 							err, _, newTrade := l.ProcessRecallLendingTrade(lendingState, statedb, tradingState, lendingBook, tradingIdHash, newLiquidatePrice)
 							if err != nil {
 								log.Error("ProcessRecallLendingTrade", "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "newLiquidatePrice", newLiquidatePrice, "err", err)
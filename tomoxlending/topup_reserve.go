@@ -0,0 +1,50 @@
+package tomoxlending
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// requiredTopUpAmount returns the collateral to deposit to move the liquidation price of a trade
+// to 90% of the current collateral price.
+func requiredTopUpAmount(lendingTrade *lendingstate.LendingTrade, currentPrice *big.Int) *big.Int {
+	// newLiquidationPrice = currentPrice * 90%
+	newLiquidationPrice := new(big.Int).Mul(currentPrice, common.RateTopUp)
+	newLiquidationPrice = new(big.Int).Div(newLiquidationPrice, common.BaseTopUp)
+	// newLockedAmount = CollateralLockedAmount *  LiquidationPrice / newLiquidationPrice
+	newLockedAmount := new(big.Int).Mul(lendingTrade.CollateralLockedAmount, lendingTrade.LiquidationPrice)
+	newLockedAmount = new(big.Int).Div(newLockedAmount, newLiquidationPrice)
+	return new(big.Int).Sub(newLockedAmount, lendingTrade.CollateralLockedAmount)
+}
+
+// ReserveTopUp tops up a trade about to be liquidated with the collateral of the borrower,
+// as long as the required amount fits in the top-up reserve the borrower configured with a
+// TopUpReserve lending item. The reserve is decreased by the deposited amount.
+func (l *Lending) ReserveTopUp(statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB, lendingBook, lendingTradeId common.Hash, currentPrice *big.Int) (*lendingstate.LendingTrade, error) {
+	lendingTrade := lendingState.GetLendingTrade(lendingBook, lendingTradeId)
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("process deposit for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId.Hex())
+	}
+	if currentPrice.Cmp(lendingTrade.LiquidationPrice) >= 0 {
+		return nil, fmt.Errorf("CurrentPrice is still higher than or equal to LiquidationPrice. current price: %v  , liquidation price : %v  ", currentPrice, lendingTrade.LiquidationPrice)
+	}
+	reserve := lendingState.GetTopUpReserve(lendingTrade.Borrower, lendingTrade.CollateralToken)
+	if reserve.Sign() == 0 {
+		return nil, fmt.Errorf("no top-up reserve. borrower: %s . Token: %s", lendingTrade.Borrower.Hex(), lendingTrade.CollateralToken.Hex())
+	}
+	requiredDepositAmount := requiredTopUpAmount(&lendingTrade, currentPrice)
+	if reserve.Cmp(requiredDepositAmount) < 0 {
+		return nil, fmt.Errorf("not enough top-up reserve. requiredDepositAmount: %v . reserve: %v . Token: %s", requiredDepositAmount, reserve, lendingTrade.CollateralToken.Hex())
+	}
+	err, _, newTrade := l.ProcessTopUpLendingTrade(lendingState, statedb, tradingState, lendingTradeId, lendingBook, requiredDepositAmount)
+	if err != nil {
+		return nil, err
+	}
+	lendingState.SetTopUpReserve(lendingTrade.Borrower, lendingTrade.CollateralToken, new(big.Int).Sub(reserve, requiredDepositAmount))
+	return newTrade, nil
+}
@@ -0,0 +1,166 @@
+package tomoxlending
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Formats of a lending trade export.
+const (
+	TradeExportRLP     = "rlp"
+	TradeExportCSV     = "csv"
+	TradeExportParquet = "parquet"
+)
+
+// LendingTradeRecord is a change of a lending trade by a block, as exported for analytics.
+type LendingTradeRecord struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	LendingBook common.Hash
+	Change      string // lendingstate.TradeChangeOpen, TradeChangeUpdate or TradeChangeClose
+	Trade       lendingstate.LendingTrade
+}
+
+// LendingTradeWriter writes lending trade records into an export.
+type LendingTradeWriter interface {
+	Write(record *LendingTradeRecord) error
+	Flush() error
+}
+
+// NewLendingTradeWriter returns a writer of lending trade records in the given format.
+func NewLendingTradeWriter(w io.Writer, format string) (LendingTradeWriter, error) {
+	switch format {
+	case TradeExportRLP:
+		return &rlpTradeWriter{w: w}, nil
+	case TradeExportCSV:
+		writer := &csvTradeWriter{w: csv.NewWriter(w)}
+		if err := writer.w.Write(csvTradeHeader); err != nil {
+			return nil, err
+		}
+		return writer, nil
+	case TradeExportParquet:
+		return nil, fmt.Errorf("%s export is not supported by this build, export as %s and convert it", format, TradeExportCSV)
+	default:
+		return nil, fmt.Errorf("unknown lending trade export format %q", format)
+	}
+}
+
+// rlpTradeWriter writes lending trade records as a stream of RLP lists, which rlp.Stream reads back.
+type rlpTradeWriter struct {
+	w io.Writer
+}
+
+func (r *rlpTradeWriter) Write(record *LendingTradeRecord) error {
+	return rlp.Encode(r.w, record)
+}
+
+func (r *rlpTradeWriter) Flush() error {
+	return nil
+}
+
+var csvTradeHeader = []string{
+	"blockNumber", "blockHash", "lendingBook", "change", "tradeId", "hash", "txHash", "status",
+	"borrower", "investor", "lendingToken", "collateralToken", "borrowingRelayer", "investingRelayer",
+	"borrowingOrderHash", "investingOrderHash", "term", "interest", "amount", "collateralLockedAmount",
+	"collateralPrice", "liquidationPrice", "depositRate", "liquidationRate", "recallRate",
+	"borrowingFee", "investingFee", "autoTopUp", "liquidationTime", "takerOrderSide",
+	"takerOrderType", "makerOrderType", "extraData",
+}
+
+// csvTradeWriter writes lending trade records as CSV rows, amounts in base units.
+type csvTradeWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvTradeWriter) Write(record *LendingTradeRecord) error {
+	trade := &record.Trade
+	amount := func(v *big.Int) string {
+		if v == nil {
+			return "0"
+		}
+		return v.String()
+	}
+	return c.w.Write([]string{
+		strconv.FormatUint(record.BlockNumber, 10), record.BlockHash.Hex(), record.LendingBook.Hex(), record.Change,
+		strconv.FormatUint(trade.TradeId, 10), trade.Hash.Hex(), trade.TxHash.Hex(), trade.Status,
+		trade.Borrower.Hex(), trade.Investor.Hex(), trade.LendingToken.Hex(), trade.CollateralToken.Hex(),
+		trade.BorrowingRelayer.Hex(), trade.InvestingRelayer.Hex(), trade.BorrowingOrderHash.Hex(), trade.InvestingOrderHash.Hex(),
+		strconv.FormatUint(trade.Term, 10), strconv.FormatUint(trade.Interest, 10), amount(trade.Amount), amount(trade.CollateralLockedAmount),
+		amount(trade.CollateralPrice), amount(trade.LiquidationPrice), amount(trade.DepositRate), amount(trade.LiquidationRate), amount(trade.RecallRate),
+		amount(trade.BorrowingFee), amount(trade.InvestingFee), strconv.FormatBool(trade.AutoTopUp), strconv.FormatUint(trade.LiquidationTime, 10),
+		trade.TakerOrderSide, trade.TakerOrderType, trade.MakerOrderType, trade.ExtraData,
+	})
+}
+
+func (c *csvTradeWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ExportLendingTrades writes the lending trades opened, updated or closed by the blocks of the
+// given range, by block then lending book then trade id. The changes are found by diffing the
+// lending trade tries of each block against the ones of its parent, so the lending state of the
+// blocks must still be available, which usually requires an archive node. It returns the number
+// of records written.
+func (l *Lending) ExportLendingTrades(chain blockChain, from, to uint64, w LendingTradeWriter) (int, error) {
+	if from == 0 || from > to {
+		return 0, fmt.Errorf("invalid block range %d - %d", from, to)
+	}
+	lendingRoot := func(block *types.Block) (common.Hash, error) {
+		author, err := chain.Engine().Author(block.Header())
+		if err != nil {
+			return common.Hash{}, err
+		}
+		return l.GetLendingStateRoot(block, author)
+	}
+	parent := chain.GetBlockByNumber(from - 1)
+	if parent == nil {
+		return 0, fmt.Errorf("block #%d not found", from-1)
+	}
+	parentRoot, err := lendingRoot(parent)
+	if err != nil {
+		return 0, err
+	}
+	written := 0
+	for number := from; number <= to; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			return written, fmt.Errorf("block #%d not found", number)
+		}
+		root, err := lendingRoot(block)
+		if err != nil {
+			return written, err
+		}
+		// blocks without a lending state root (before the lending fork) change no trade
+		if root == lendingstate.EmptyRoot || root == parentRoot {
+			continue
+		}
+		changes, err := lendingstate.DiffLendingTrades(l.GetStateCache(), parentRoot, root)
+		if err != nil {
+			return written, fmt.Errorf("lending state of block #%d not available: %v", number, err)
+		}
+		for _, change := range changes {
+			record := &LendingTradeRecord{
+				BlockNumber: number,
+				BlockHash:   block.Hash(),
+				LendingBook: change.LendingBook,
+				Change:      change.Change,
+				Trade:       change.Trade,
+			}
+			if err := w.Write(record); err != nil {
+				return written, err
+			}
+			written++
+		}
+		parentRoot = root
+	}
+	return written, w.Flush()
+}
@@ -0,0 +1,73 @@
+package tomoxlending
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestLendingTradeWriters(t *testing.T) {
+	records := []*LendingTradeRecord{
+		{BlockNumber: 10, BlockHash: common.HexToHash("0xa"), LendingBook: common.HexToHash("0x1"), Change: lendingstate.TradeChangeOpen,
+			Trade: lendingstate.LendingTrade{TradeId: 1, Amount: big.NewInt(100), CollateralLockedAmount: big.NewInt(150), Interest: 10, Term: 86400}},
+		{BlockNumber: 11, BlockHash: common.HexToHash("0xb"), LendingBook: common.HexToHash("0x1"), Change: lendingstate.TradeChangeClose,
+			Trade: lendingstate.LendingTrade{TradeId: 1, Amount: big.NewInt(100), Status: lendingstate.TradeStatusClosed}},
+	}
+
+	var buf bytes.Buffer
+	writer, err := NewLendingTradeWriter(&buf, TradeExportRLP)
+	if err != nil {
+		t.Fatalf("failed to create the rlp writer: %v", err)
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+	stream := rlp.NewStream(&buf, 0)
+	for i, want := range records {
+		var record LendingTradeRecord
+		if err := stream.Decode(&record); err != nil {
+			t.Fatalf("record %d: failed to decode: %v", i, err)
+		}
+		if record.BlockNumber != want.BlockNumber || record.Change != want.Change || record.Trade.TradeId != want.Trade.TradeId || record.Trade.Amount.Cmp(want.Trade.Amount) != 0 {
+			t.Errorf("record %d: wrong record %v", i, lendingstate.ToJSON(record))
+		}
+	}
+	if err := stream.Decode(new(LendingTradeRecord)); err != io.EOF {
+		t.Errorf("trailing data after the records: %v", err)
+	}
+
+	buf.Reset()
+	if writer, err = NewLendingTradeWriter(&buf, TradeExportCSV); err != nil {
+		t.Fatalf("failed to create the csv writer: %v", err)
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read the csv export: %v", err)
+	}
+	if len(rows) != 3 || len(rows[1]) != len(csvTradeHeader) {
+		t.Fatalf("wrong csv export: %v", rows)
+	}
+	if row := rows[1]; row[0] != "10" || row[3] != lendingstate.TradeChangeOpen || row[4] != "1" || row[18] != "100" || row[19] != "150" || row[20] != "0" {
+		t.Errorf("wrong csv row: %v", row)
+	}
+
+	if _, err := NewLendingTradeWriter(&buf, TradeExportParquet); err == nil {
+		t.Error("parquet export accepted")
+	}
+}
@@ -0,0 +1,389 @@
+package tomoxlending
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Non-SDK nodes started with --tomox.lendingindex keep the lending items and trades of each user
+// in the tomox leveldb, so that wallets can page through their lending history without mongodb.
+// The user keys are sorted by descending creation time.
+var (
+	lendingUserItemPrefix   = []byte("lendingUserItem-")   // lendingUserItemPrefix + user + ^createdAt + hash -> nil
+	lendingUserTradePrefix  = []byte("lendingUserTrade-")  // lendingUserTradePrefix + user + ^createdAt + hash -> nil
+	lendingIndexItemPrefix  = []byte("lendingIndexItem-")  // lendingIndexItemPrefix + hash -> lending item
+	lendingIndexTradePrefix = []byte("lendingIndexTrade-") // lendingIndexTradePrefix + hash -> lending trade
+	lendingItemFillPrefix   = []byte("lendingItemFill-")   // lendingItemFillPrefix + item hash + trade hash -> filled amount
+)
+
+const maxLendingHistoryLimit = 100 // Maximum number of records returned by a page of lending history
+
+var errLendingHistoryUnavailable = errors.New("lending history requires an SDK node or --tomox.lendingindex")
+
+func lendingUserKey(prefix []byte, user common.Address, createdAt time.Time, hash common.Hash) []byte {
+	key := make([]byte, len(prefix)+common.AddressLength+8+common.HashLength)
+	copy(key, prefix)
+	copy(key[len(prefix):], user.Bytes())
+	binary.BigEndian.PutUint64(key[len(prefix)+common.AddressLength:], math.MaxUint64-uint64(createdAt.UnixNano()))
+	copy(key[len(prefix)+common.AddressLength+8:], hash.Bytes())
+	return key
+}
+
+func lendingIndexKey(prefix []byte, hash common.Hash) []byte {
+	return append(append([]byte{}, prefix...), hash.Bytes()...)
+}
+
+func lendingItemFillKey(itemHash, tradeHash common.Hash) []byte {
+	return append(append(append([]byte{}, lendingItemFillPrefix...), itemHash.Bytes()...), tradeHash.Bytes()...)
+}
+
+// HasLendingIndex returns whether the node indexes the lending history of each user in leveldb.
+func (l *Lending) HasLendingIndex() bool {
+	return l.tomox.HasLendingIndex()
+}
+
+func (l *Lending) getIndexedItem(hash common.Hash) *lendingstate.LendingItem {
+	data, err := l.GetLevelDB().Get(lendingIndexKey(lendingIndexItemPrefix, hash))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	item := &lendingstate.LendingItem{}
+	if err := json.Unmarshal(data, item); err != nil {
+		log.Error("Failed to decode indexed lending item", "hash", hash.Hex(), "err", err)
+		return nil
+	}
+	return item
+}
+
+func (l *Lending) getIndexedTrade(hash common.Hash) *lendingstate.LendingTrade {
+	data, err := l.GetLevelDB().Get(lendingIndexKey(lendingIndexTradePrefix, hash))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	trade := &lendingstate.LendingTrade{}
+	if err := json.Unmarshal(data, trade); err != nil {
+		log.Error("Failed to decode indexed lending trade", "hash", hash.Hex(), "err", err)
+		return nil
+	}
+	return trade
+}
+
+func putIndexedItem(batch ethdb.Batch, item *lendingstate.LendingItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(lendingIndexKey(lendingIndexItemPrefix, item.Hash), data); err != nil {
+		return err
+	}
+	return batch.Put(lendingUserKey(lendingUserItemPrefix, item.UserAddress, item.CreatedAt, item.Hash), nil)
+}
+
+func putIndexedTrade(batch ethdb.Batch, trade *lendingstate.LendingTrade) error {
+	data, err := json.Marshal(trade)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(lendingIndexKey(lendingIndexTradePrefix, trade.Hash), data); err != nil {
+		return err
+	}
+	if err := batch.Put(lendingUserKey(lendingUserTradePrefix, trade.Borrower, trade.CreatedAt, trade.Hash), nil); err != nil {
+		return err
+	}
+	return batch.Put(lendingUserKey(lendingUserTradePrefix, trade.Investor, trade.CreatedAt, trade.Hash), nil)
+}
+
+// filledAmount sums the amounts of the indexed trades of a lending item.
+func (l *Lending) filledAmount(itemHash common.Hash) *big.Int {
+	prefix := append(append([]byte{}, lendingItemFillPrefix...), itemHash.Bytes()...)
+	it := l.GetLevelDB().NewIterator(prefix, nil)
+	defer it.Release()
+
+	filled := new(big.Int)
+	for it.Next() {
+		filled.Add(filled, new(big.Int).SetBytes(it.Value()))
+	}
+	return filled
+}
+
+// indexLendingTrade records a lending trade and returns the lending items it fills. The creation
+// time of a trade already indexed is kept, so that updates don't move it in the user history.
+func (l *Lending) indexLendingTrade(batch ethdb.Batch, trade *lendingstate.LendingTrade, txHash common.Hash, txMatchTime time.Time, filling bool) ([]common.Hash, error) {
+	record := *trade
+	if record.Hash == (common.Hash{}) {
+		record.Hash = record.ComputeHash()
+	}
+	if origin := l.getIndexedTrade(record.Hash); origin != nil {
+		record.CreatedAt = origin.CreatedAt
+	} else if record.CreatedAt.IsZero() {
+		record.CreatedAt = txMatchTime
+	}
+	record.TxHash = txHash
+	record.UpdatedAt = txMatchTime
+	if err := putIndexedTrade(batch, &record); err != nil {
+		return nil, err
+	}
+	if !filling || record.Amount == nil {
+		return nil, nil
+	}
+	var filled []common.Hash
+	for _, itemHash := range []common.Hash{record.BorrowingOrderHash, record.InvestingOrderHash} {
+		if err := batch.Put(lendingItemFillKey(itemHash, record.Hash), record.Amount.Bytes()); err != nil {
+			return nil, err
+		}
+		filled = append(filled, itemHash)
+	}
+	return filled, nil
+}
+
+// IndexLendingData records the result of a lending item processed in a block into the lending
+// history index. It is the counterpart of SyncDataToSDKNode for non-SDK nodes, and does nothing
+// unless the index is enabled. Records are keyed by hash, so indexing a transaction again after
+// a reorg overwrites them.
+func (l *Lending) IndexLendingData(takerItem *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedItems []*lendingstate.LendingItem) error {
+	if !l.HasLendingIndex() || isForeignLendingHash(takerItem, rejectedItems) {
+		return nil
+	}
+	db := l.GetLevelDB()
+	batch := db.NewBatch()
+
+	isOrder := takerItem.Type == lendingstate.Limit || takerItem.Type == lendingstate.Market || takerItem.Type == lendingstate.StopLimit || takerItem.Type == lendingstate.Iceberg
+	if isOrder {
+		switch takerItem.Status {
+		case lendingstate.LendingStatusNew:
+			item := *takerItem
+			item.Status = lendingstate.LendingStatusOpen
+			item.FilledAmount = new(big.Int)
+			item.TxHash = txHash
+			if item.CreatedAt.IsZero() {
+				item.CreatedAt = txMatchTime
+			}
+			item.UpdatedAt = txMatchTime
+			if err := putIndexedItem(batch, &item); err != nil {
+				return err
+			}
+		case lendingstate.LendingStatusCancelled:
+			if len(rejectedItems) > 0 {
+				// cancel order is rejected -> nothing change
+				return nil
+			}
+			if item := l.getIndexedItem(takerItem.Hash); item != nil {
+				item.Status = lendingstate.LendingStatusCancelled
+				item.TxHash = txHash
+				item.UpdatedAt = txMatchTime
+				if err := putIndexedItem(batch, item); err != nil {
+					return err
+				}
+			}
+		case lendingstate.LendingStatusAmended:
+			if isRejectedAmendment(takerItem, rejectedItems) {
+				// amendment is rejected -> nothing change
+				return nil
+			}
+			if item := l.getIndexedItem(takerItem.Hash); item != nil {
+				item.FilledAmount = l.filledAmount(item.Hash)
+				amendLendingItemRecord(item, takerItem)
+				item.TxHash = txHash
+				item.UpdatedAt = txMatchTime
+				if err := putIndexedItem(batch, item); err != nil {
+					return err
+				}
+			}
+		}
+		// the fill amounts of the items must be readable to update their status
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch = db.NewBatch()
+	}
+
+	filledItems := map[common.Hash]bool{}
+	for _, trade := range trades {
+		if trade == nil {
+			continue
+		}
+		filled, err := l.indexLendingTrade(batch, trade, txHash, txMatchTime, isOrder)
+		if err != nil {
+			return err
+		}
+		for _, hash := range filled {
+			filledItems[hash] = true
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
+	batch = db.NewBatch()
+	for hash := range filledItems {
+		item := l.getIndexedItem(hash)
+		if item == nil {
+			continue
+		}
+		item.FilledAmount = l.filledAmount(hash)
+		if item.FilledAmount.Cmp(item.Quantity) < 0 && item.Type != lendingstate.Market {
+			item.Status = lendingstate.LendingStatusPartialFilled
+		} else {
+			item.Status = lendingstate.LendingStatusFilled
+		}
+		item.TxHash = txHash
+		item.UpdatedAt = txMatchTime
+		if err := putIndexedItem(batch, item); err != nil {
+			return err
+		}
+	}
+	for _, rejected := range rejectedItems {
+		item := l.getIndexedItem(rejected.Hash)
+		if item == nil {
+			if rejected.Type != lendingstate.Limit && rejected.Type != lendingstate.Market && rejected.Type != lendingstate.StopLimit && rejected.Type != lendingstate.Iceberg {
+				continue
+			}
+			clone := *rejected
+			item = &clone
+			item.FilledAmount = new(big.Int)
+			item.CreatedAt = txMatchTime
+		}
+		item.Status = lendingstate.LendingStatusReject
+		item.RejectReason = rejected.RejectReason
+		item.TxHash = txHash
+		item.UpdatedAt = txMatchTime
+		if err := putIndexedItem(batch, item); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// IndexLiquidatedTrades records the trades closed at the liquidation block of an epoch into the
+// lending history index.
+func (l *Lending) IndexLiquidatedTrades(blockTime uint64, result lendingstate.FinalizedResult, trades map[common.Hash]*lendingstate.LendingTrade) error {
+	if !l.HasLendingIndex() {
+		return nil
+	}
+	txTime := time.Unix(int64(blockTime), 0).UTC()
+	batch := l.GetLevelDB().NewBatch()
+	for _, trade := range trades {
+		if _, err := l.indexLendingTrade(batch, trade, result.TxHash, txTime, false); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// iterateLendingHistory walks the records indexed for a user, the most recent first, until
+// the callback returns false.
+func (l *Lending) iterateLendingHistory(prefix []byte, user common.Address, fn func(hash common.Hash) bool) {
+	userPrefix := append(append([]byte{}, prefix...), user.Bytes()...)
+	it := l.GetLevelDB().NewIterator(userPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(userPrefix)+8+common.HashLength {
+			continue
+		}
+		if !fn(common.BytesToHash(key[len(userPrefix)+8:])) {
+			return
+		}
+	}
+}
+
+// matchRelayer returns whether a record of the given relayers matches the relayer of a history
+// query, the empty relayer matching every record.
+func matchRelayer(relayer common.Address, recordRelayers ...common.Address) bool {
+	if relayer == (common.Address{}) {
+		return true
+	}
+	for _, recordRelayer := range recordRelayers {
+		if recordRelayer == relayer {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLendingBook returns whether a record of the given lending book matches the filter of a
+// history query.
+func matchLendingBook(lendingToken common.Address, term uint64, status string, recordToken common.Address, recordTerm uint64, recordStatus string) bool {
+	if lendingToken != (common.Address{}) && lendingToken != recordToken {
+		return false
+	}
+	if term > 0 && term != recordTerm {
+		return false
+	}
+	return status == "" || status == recordStatus
+}
+
+func lendingHistoryPage(page, limit int) (offset int, size int) {
+	if limit <= 0 || limit > maxLendingHistoryLimit {
+		limit = maxLendingHistoryLimit
+	}
+	if page < 0 {
+		page = 0
+	}
+	return page * limit, limit
+}
+
+// getLendingItemsByUser returns a page of the lending items placed by a user through a relayer (any
+// relayer if empty), the most recent first. Pages are numbered from zero.
+func (l *Lending) getLendingItemsByUser(user, lendingToken common.Address, term uint64, status string, relayer common.Address, page, limit int) ([]*lendingstate.LendingItem, error) {
+	offset, limit := lendingHistoryPage(page, limit)
+	if l.tomox.IsSDKNode() {
+		items, _ := l.GetMongoReadDB().GetLendingListByUser(user, lendingToken, term, status, offset, limit, &lendingstate.LendingItem{Relayer: relayer}).([]*lendingstate.LendingItem)
+		return items, nil
+	}
+	if !l.HasLendingIndex() {
+		return nil, errLendingHistoryUnavailable
+	}
+	items := []*lendingstate.LendingItem{}
+	l.iterateLendingHistory(lendingUserItemPrefix, user, func(hash common.Hash) bool {
+		item := l.getIndexedItem(hash)
+		if item == nil || !matchLendingBook(lendingToken, term, status, item.LendingToken, item.Term, item.Status) || !matchRelayer(relayer, item.Relayer) {
+			return true
+		}
+		if offset > 0 {
+			offset--
+			return true
+		}
+		items = append(items, item)
+		return len(items) < limit
+	})
+	return items, nil
+}
+
+// getLendingTradesByUser returns a page of the lending trades of a user as a borrower or an
+// investor, the most recent first. A relayer restricts the page to the trades in which it is the
+// borrowing or the investing relayer. Pages are numbered from zero.
+func (l *Lending) getLendingTradesByUser(user, lendingToken common.Address, term uint64, status string, relayer common.Address, page, limit int) ([]*lendingstate.LendingTrade, error) {
+	offset, limit := lendingHistoryPage(page, limit)
+	if l.tomox.IsSDKNode() {
+		trades, _ := l.GetMongoReadDB().GetLendingListByUser(user, lendingToken, term, status, offset, limit, &lendingstate.LendingTrade{BorrowingRelayer: relayer}).([]*lendingstate.LendingTrade)
+		return trades, nil
+	}
+	if !l.HasLendingIndex() {
+		return nil, errLendingHistoryUnavailable
+	}
+	trades := []*lendingstate.LendingTrade{}
+	l.iterateLendingHistory(lendingUserTradePrefix, user, func(hash common.Hash) bool {
+		trade := l.getIndexedTrade(hash)
+		if trade == nil || !matchLendingBook(lendingToken, term, status, trade.LendingToken, trade.Term, trade.Status) || !matchRelayer(relayer, trade.BorrowingRelayer, trade.InvestingRelayer) {
+			return true
+		}
+		if offset > 0 {
+			offset--
+			return true
+		}
+		trades = append(trades, trade)
+		return len(trades) < limit
+	})
+	return trades, nil
+}
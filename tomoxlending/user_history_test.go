@@ -0,0 +1,102 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestLendingIndexByUser(t *testing.T) {
+	l := New(tomox.New(&tomox.Config{DataDir: t.TempDir()}))
+	if _, err := l.getLendingItemsByUser(common.Address{}, common.Address{}, 0, "", common.Address{}, 0, 10); err != errLendingHistoryUnavailable {
+		t.Fatalf("history without index: have %v, want %v", err, errLendingHistoryUnavailable)
+	}
+
+	l = New(tomox.New(&tomox.Config{DataDir: t.TempDir(), LendingIndex: true}))
+	var (
+		borrower = common.HexToAddress("0x1")
+		investor = common.HexToAddress("0x2")
+		usdt     = common.HexToAddress("0x10")
+		btc      = common.HexToAddress("0x11")
+		now      = time.Unix(1600000000, 0).UTC()
+	)
+	newItem := func(user common.Address, side string, term uint64, n int64) *lendingstate.LendingItem {
+		return &lendingstate.LendingItem{
+			Quantity:     big.NewInt(100),
+			Interest:     big.NewInt(10),
+			Side:         side,
+			Type:         lendingstate.Limit,
+			LendingToken: usdt,
+			Status:       lendingstate.LendingStatusNew,
+			Term:         term,
+			UserAddress:  user,
+			Hash:         common.BigToHash(big.NewInt(n)),
+		}
+	}
+	// three borrowing items, the last one in another lending book
+	for i := int64(1); i <= 3; i++ {
+		term := uint64(86400)
+		if i == 3 {
+			term = 2 * 86400
+		}
+		if err := l.IndexLendingData(newItem(borrower, lendingstate.Borrowing, term, i), common.BigToHash(big.NewInt(100+i)), now.Add(time.Duration(i)*time.Second), nil, nil); err != nil {
+			t.Fatalf("failed to index item %d: %v", i, err)
+		}
+	}
+	// an investing item partially filling the first borrowing item
+	investing := newItem(investor, lendingstate.Investing, 86400, 4)
+	investing.Quantity = big.NewInt(40)
+	trade := &lendingstate.LendingTrade{
+		Borrower:           borrower,
+		Investor:           investor,
+		LendingToken:       usdt,
+		CollateralToken:    btc,
+		BorrowingOrderHash: common.BigToHash(big.NewInt(1)),
+		InvestingOrderHash: investing.Hash,
+		Term:               86400,
+		Amount:             big.NewInt(40),
+		Status:             lendingstate.TradeStatusOpen,
+		Hash:               common.HexToHash("0xabc"),
+	}
+	if err := l.IndexLendingData(investing, common.BigToHash(big.NewInt(104)), now.Add(4*time.Second), []*lendingstate.LendingTrade{trade}, nil); err != nil {
+		t.Fatalf("failed to index trade: %v", err)
+	}
+
+	items, err := l.getLendingItemsByUser(borrower, common.Address{}, 0, "", common.Address{}, 0, 2)
+	if err != nil {
+		t.Fatalf("failed to get items: %v", err)
+	}
+	if len(items) != 2 || items[0].Hash != common.BigToHash(big.NewInt(3)) || items[1].Hash != common.BigToHash(big.NewInt(2)) {
+		t.Fatalf("first page mismatch: %v", items)
+	}
+	items, _ = l.getLendingItemsByUser(borrower, common.Address{}, 0, "", common.Address{}, 1, 2)
+	if len(items) != 1 || items[0].Status != lendingstate.LendingStatusPartialFilled || items[0].FilledAmount.Cmp(big.NewInt(40)) != 0 {
+		t.Fatalf("second page mismatch: %v", items)
+	}
+	if items, _ = l.getLendingItemsByUser(borrower, usdt, 86400, lendingstate.LendingStatusOpen, common.Address{}, 0, 10); len(items) != 1 || items[0].Hash != common.BigToHash(big.NewInt(2)) {
+		t.Fatalf("filtered items mismatch: %v", items)
+	}
+	if items, _ = l.getLendingItemsByUser(investor, common.Address{}, 0, lendingstate.LendingStatusFilled, common.Address{}, 0, 10); len(items) != 1 {
+		t.Fatalf("filled investing item not found: %v", items)
+	}
+
+	// closing the trade updates its record in place
+	closed := *trade
+	closed.Status = lendingstate.TradeStatusClosed
+	if err := l.IndexLiquidatedTrades(uint64(now.Add(time.Hour).Unix()), lendingstate.FinalizedResult{TxHash: common.HexToHash("0xdef")}, map[common.Hash]*lendingstate.LendingTrade{closed.Hash: &closed}); err != nil {
+		t.Fatalf("failed to index liquidated trade: %v", err)
+	}
+	for _, user := range []common.Address{borrower, investor} {
+		trades, err := l.getLendingTradesByUser(user, usdt, 0, "", common.Address{}, 0, 10)
+		if err != nil {
+			t.Fatalf("failed to get trades: %v", err)
+		}
+		if len(trades) != 1 || trades[0].Status != lendingstate.TradeStatusClosed || !trades[0].CreatedAt.Equal(now.Add(4*time.Second)) {
+			t.Fatalf("trades of %x mismatch: %v", user, trades)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package tomoxlending
+
+import (
+	"errors"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// GetWitnessedLendingState returns the lending state of a block opened on a database recording the
+// trie nodes read through it, for the witness of the child block. See tomox.BlockWitness.
+func (l *Lending) GetWitnessedLendingState(block *types.Block, author common.Address) (*lendingstate.LendingStateDB, *lendingstate.WitnessDatabase, error) {
+	root, err := l.GetLendingStateRoot(block, author)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l.StateCache == nil {
+		return nil, nil, errors.New("Not initialized tomox")
+	}
+	db := lendingstate.NewWitnessDatabase(l.StateCache)
+	state, err := lendingstate.New(root, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return state, db, nil
+}
+
+// GetLendingStateFromWitness returns the lending state of a block opened on the witness of its
+// child block, holding only the trie nodes read by the matching of the child block.
+func (l *Lending) GetLendingStateFromWitness(block *types.Block, author common.Address, child common.Hash) (*lendingstate.LendingStateDB, error) {
+	root, err := l.GetLendingStateRoot(block, author)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := l.tomox.ReadLendingWitness(child)
+	if err != nil {
+		return nil, err
+	}
+	return lendingstate.New(root, lendingstate.NewWitnessStateDatabase(nodes))
+}